@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/testvectors"
+)
+
+func runPubKey(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("pubkey", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected a VASP domain")
+	}
+	pubKeyResponse, err := fetchPubKeys(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return printJson(stdout, pubKeyResponse)
+}
+
+func runLnurlp(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("lnurlp", flag.ContinueOnError)
+	keyHex := flags.String("key", os.Getenv("UMA_SIGNING_KEY"), "hex-encoded signing private key of the sending VASP")
+	vaspDomain := flags.String("domain", "", "domain of the sending VASP, from which the receiver fetches its public keys")
+	umaVersion := flags.String("version", uma.UmaProtocolVersion, "UMA version of the request")
+	travelRule := flags.Bool("travel-rule", true, "whether the sending VASP is subject to the travel rule")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected a receiver address")
+	}
+	if *vaspDomain == "" {
+		return errors.New("-domain is required")
+	}
+	receiverAddress := flags.Arg(0)
+	signer, err := signerFromHex(*keyHex)
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+	request, err := uma.SignLnurlpRequest(protocol.LnurlpRequest{
+		ReceiverAddress:       receiverAddress,
+		VaspDomain:            vaspDomain,
+		UmaVersion:            umaVersion,
+		IsSubjectToTravelRule: travelRule,
+	}, signer)
+	if err != nil {
+		return err
+	}
+	response, err := uma.SendLnurlpRequest(context.Background(), *request)
+	if err != nil {
+		var unsupportedVersionError uma.UnsupportedVersionError
+		if errors.As(err, &unsupportedVersionError) {
+			return fmt.Errorf("receiver does not support UMA %s, supported major versions: %v",
+				unsupportedVersionError.UnsupportedVersion, unsupportedVersionError.SupportedMajorVersions)
+		}
+		return err
+	}
+	if err = printJson(stdout, response); err != nil {
+		return err
+	}
+	if !response.IsUmaResponse() {
+		return errors.New("response is not an UMA lnurlp response")
+	}
+	receiverDomain, err := uma.GetVaspDomainFromUmaAddress(receiverAddress)
+	if err != nil {
+		return err
+	}
+	pubKeyResponse, err := fetchPubKeys(receiverDomain)
+	if err != nil {
+		return err
+	}
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(*response.AsUmaResponse(), *pubKeyResponse, nil, receiverAddress)
+	if err != nil {
+		return fmt.Errorf("response signature is invalid: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, "response signature is valid")
+	return err
+}
+
+func runPayReq(args []string, _ io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("payreq", flag.ContinueOnError)
+	keyHex := flags.String("key", os.Getenv("UMA_SIGNING_KEY"), "hex-encoded signing private key of the sending VASP")
+	payerIdentifier := flags.String("payer", "", "UMA address of the sender, e.g. $alice@vasp1.com")
+	amount := flags.Int64("amount", 0, "amount to send, in the smallest unit of -currency, or msats with -msats")
+	currency := flags.String("currency", "", "code of the currency the receiver will receive")
+	amountInMsats := flags.Bool("msats", false, "whether -amount is in msats rather than the receiving currency")
+	callback := flags.String("callback", "", "pay request callback URL. Defaults to the callback from an lnurlp request")
+	vaspDomain := flags.String("domain", "", "domain of the sending VASP, used to send an lnurlp request for -callback")
+	utxoCallback := flags.String("utxo-callback", "", "URL at which the sending VASP accepts post transaction callbacks")
+	umaMajorVersion := flags.Int("major-version", uma.MAJOR_VERSION, "UMA major version of the request")
+	send := flags.Bool("send", false, "send the pay request and print the response")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected a receiver address")
+	}
+	if *payerIdentifier == "" || *currency == "" || *amount <= 0 {
+		return errors.New("-payer, -currency and a positive -amount are required")
+	}
+	receiverAddress := flags.Arg(0)
+	signer, err := signerFromHex(*keyHex)
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+	receiverDomain, err := uma.GetVaspDomainFromUmaAddress(receiverAddress)
+	if err != nil {
+		return err
+	}
+	pubKeyResponse, err := fetchPubKeys(receiverDomain)
+	if err != nil {
+		return err
+	}
+	encryptionPubKey, err := pubKeyResponse.EncryptionPubKey()
+	if err != nil {
+		return err
+	}
+	if *callback == "" {
+		if *vaspDomain == "" {
+			return errors.New("either -callback or -domain is required")
+		}
+		request, err := uma.SignLnurlpRequest(protocol.LnurlpRequest{
+			ReceiverAddress: receiverAddress,
+			VaspDomain:      vaspDomain,
+		}, signer)
+		if err != nil {
+			return err
+		}
+		lnurlpResponse, err := uma.SendLnurlpRequest(context.Background(), *request)
+		if err != nil {
+			return err
+		}
+		*callback = lnurlpResponse.Callback
+	}
+	payRequest, err := uma.GetUmaPayRequest(
+		*amount,
+		encryptionPubKey,
+		signer,
+		*currency,
+		!*amountInMsats,
+		*payerIdentifier,
+		*umaMajorVersion,
+		nil,
+		nil,
+		nil,
+		nil,
+		protocol.KycStatusVerified,
+		nil,
+		nil,
+		*utxoCallback,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	if !*send {
+		return printJson(stdout, payRequest)
+	}
+	response, err := uma.SendPayRequest(context.Background(), *callback, payRequest)
+	if err != nil {
+		return err
+	}
+	if err = printJson(stdout, response); err != nil {
+		return err
+	}
+	err = uma.VerifyPayReqResponseSignature(response, *pubKeyResponse, nil, *payerIdentifier, receiverAddress)
+	if err != nil {
+		return fmt.Errorf("response signature is invalid: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, "response signature is valid")
+	return err
+}
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	messageType := flags.String("type", "", "message type: lnurlp_request (a URL), lnurlp_response, payreq, "+
+		"payreq_response, post_transaction_callback or invoice (bech32)")
+	pubKeyHex := flags.String("pubkey", "", "hex-encoded signing public key of the VASP which signed the message")
+	vaspDomain := flags.String("vasp", "", "domain of the VASP which signed the message, to fetch its public key")
+	payerIdentifier := flags.String("payer", "", "payer identifier, for payreq_response messages")
+	payeeIdentifier := flags.String("payee", "", "payee identifier, for payreq_response messages")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *messageType == "" {
+		return errors.New("-type is required")
+	}
+	message, err := messageFromArgsOrStdin(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	if *pubKeyHex == "" {
+		if *vaspDomain == "" {
+			return errors.New("either -pubkey or -vasp is required")
+		}
+		pubKeyResponse, err := fetchPubKeys(*vaspDomain)
+		if err != nil {
+			return err
+		}
+		signingPubKey, err := pubKeyResponse.SigningPubKey()
+		if err != nil {
+			return err
+		}
+		*pubKeyHex = hex.EncodeToString(signingPubKey)
+	}
+	rawMessage := json.RawMessage(message)
+	if *messageType == string(testvectors.MessageTypeLnurlpRequest) || *messageType == string(testvectors.MessageTypeInvoice) {
+		// These messages are strings rather than JSON objects.
+		if rawMessage, err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+	vector := testvectors.Vector{
+		Type:             testvectors.MessageType(*messageType),
+		SigningPubKeyHex: *pubKeyHex,
+		Message:          rawMessage,
+		PayerIdentifier:  *payerIdentifier,
+		PayeeIdentifier:  *payeeIdentifier,
+	}
+	if err = vector.Verify(); err != nil {
+		return fmt.Errorf("signature is invalid: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, "signature is valid")
+	return err
+}
+
+func runDecodeInvoice(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("decode-invoice", flag.ContinueOnError)
+	verify := flags.Bool("verify", true, "fetch the receiving VASP's public key and verify the invoice signature")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	encodedInvoice, err := messageFromArgsOrStdin(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	invoice, err := uma.DecodeUmaInvoice(encodedInvoice)
+	if err != nil {
+		return err
+	}
+	if err = printJson(stdout, invoice); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "expires at %s\n", time.Unix(int64(invoice.Expiration), 0).UTC().Format(time.RFC3339))
+	if err != nil || !*verify {
+		return err
+	}
+	receiverDomain, err := uma.GetVaspDomainFromUmaAddress(invoice.ReceiverUma)
+	if err != nil {
+		return err
+	}
+	pubKeyResponse, err := fetchPubKeys(receiverDomain)
+	if err != nil {
+		return err
+	}
+	if err = uma.VerifyUmaInvoiceSignature(*invoice, *pubKeyResponse); err != nil {
+		return fmt.Errorf("invoice signature is invalid: %w", err)
+	}
+	_, err = fmt.Fprintln(stdout, "invoice signature is valid")
+	return err
+}
+
+func fetchPubKeys(vaspDomain string) (*protocol.PubKeyResponse, error) {
+	return uma.FetchPublicKeyForVasp(vaspDomain, uma.NewInMemoryPublicKeyCache())
+}
+
+func signerFromHex(keyHex string) (*uma.PrivateKeyHandle, error) {
+	if keyHex == "" {
+		return nil, errors.New("a signing key is required, set -key or UMA_SIGNING_KEY")
+	}
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	defer clear(keyBytes)
+	return uma.NewPrivateKeyHandle(keyBytes, false)
+}
+
+func messageFromArgsOrStdin(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("expected a single message argument")
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	message, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(string(message))) == 0 {
+		return "", errors.New("expected a message argument or a message on stdin")
+	}
+	return strings.TrimSpace(string(message)), nil
+}
+
+func printJson(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(value)
+}
@@ -0,0 +1,75 @@
+// Command uma is a debugging tool for UMA integrations. It can fetch a VASP's public keys, send signed lnurlp
+// requests, construct pay requests, verify signatures on pasted messages and decode UMA invoices, which helps when
+// diagnosing interop issues between VASPs.
+//
+// Usage:
+//
+//	uma pubkey <vasp domain>
+//	uma lnurlp -key <hex private key> -domain <sending vasp domain> <receiver address>
+//	uma payreq -key <hex private key> -payer <payer address> -amount <amount> -currency <code> [-send] <receiver address>
+//	uma verify -type <message type> (-pubkey <hex> | -vasp <domain>) [message]
+//	uma decode-invoice <invoice>
+//
+// The private key may also be set with the UMA_SIGNING_KEY environment variable. Messages passed to verify are read
+// from stdin if not given as an argument.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"pubkey", "fetch and print a VASP's public keys", runPubKey},
+	{"lnurlp", "send a signed lnurlp request and verify the response", runLnurlp},
+	{"payreq", "construct a signed pay request, and optionally send it", runPayReq},
+	{"verify", "verify the signature on a pasted message", runVerify},
+	{"decode-invoice", "decode an UMA invoice and verify its signature", runDecodeInvoice},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			err := cmd.run(args[1:], stdin, stdout)
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "uma %s: %v\n", cmd.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+	_, _ = fmt.Fprintf(stderr, "uma: unknown command %q\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "usage: uma <command> [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %-15s %s\n", cmd.name, cmd.summary)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run 'uma <command> -h' for the arguments of a command.")
+}
@@ -0,0 +1,104 @@
+package uma
+
+import (
+	"fmt"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// AmountOutOfRangeError is returned when the amount chosen by a sender is outside the range the receiver accepts in
+// its lnurlp response.
+type AmountOutOfRangeError struct {
+	Amount int64
+	// CurrencyCode is the currency of Amount, Min and Max, in its smallest unit, or empty for millisatoshis.
+	CurrencyCode string
+	Min          int64
+	Max          int64
+}
+
+func (e AmountOutOfRangeError) Error() string {
+	if e.CurrencyCode == "" {
+		return fmt.Sprintf("amount of %d msats is outside the sendable range %d-%d msats", e.Amount, e.Min, e.Max)
+	}
+	return fmt.Sprintf("amount of %d %s is outside the sendable range %d-%d %s", e.Amount, e.CurrencyCode, e.Min,
+		e.Max, e.CurrencyCode)
+}
+
+// UnsupportedCurrencyError is returned when a sender chooses a receiving currency which is not in the receiver's
+// lnurlp response.
+type UnsupportedCurrencyError struct {
+	CurrencyCode string
+}
+
+func (e UnsupportedCurrencyError) Error() string {
+	return fmt.Sprintf("the receiver does not support the currency %s", e.CurrencyCode)
+}
+
+// ValidatePayAmount Checks the amount a sender chose for an any-amount payment against the range the receiver
+// advertises in its lnurlp response: the convertible range of the receiving currency for amounts in that currency, or
+// MinSendable and MaxSendable for amounts in millisatoshis. It returns an AmountOutOfRangeError for amounts outside
+// the range, and an UnsupportedCurrencyError for currencies the receiver does not support. Currencies without a
+// convertible range, i.e. with a MaxSendable of 0, accept any amount. SendPayment calls it with the pay request of UMA
+// payments.
+//
+// Args:
+//
+//	lnurlpResponse: the receiver's lnurlp response.
+//	amount: the amount chosen by the sender, in the smallest unit of the receiving currency if
+//		isAmountInReceivingCurrency is true, and in millisatoshis otherwise.
+//	receivingCurrencyCode: the currency the receiver will receive, e.g. USD, or empty when paying in millisatoshis
+//		without a receiving currency.
+//	isAmountInReceivingCurrency: whether the amount is in the receiving currency rather than millisatoshis.
+func ValidatePayAmount(
+	lnurlpResponse protocol.LnurlpResponse,
+	amount int64,
+	receivingCurrencyCode string,
+	isAmountInReceivingCurrency bool,
+) error {
+	var currency *protocol.Currency
+	if lnurlpResponse.Currencies != nil && (isAmountInReceivingCurrency || receivingCurrencyCode != "") {
+		for i := range *lnurlpResponse.Currencies {
+			if (*lnurlpResponse.Currencies)[i].Code == receivingCurrencyCode {
+				currency = &(*lnurlpResponse.Currencies)[i]
+			}
+		}
+		if currency == nil {
+			return UnsupportedCurrencyError{CurrencyCode: receivingCurrencyCode}
+		}
+	}
+	if !isAmountInReceivingCurrency {
+		if amount < lnurlpResponse.MinSendable || amount > lnurlpResponse.MaxSendable {
+			return AmountOutOfRangeError{Amount: amount, Min: lnurlpResponse.MinSendable, Max: lnurlpResponse.MaxSendable}
+		}
+		return nil
+	}
+	if currency == nil {
+		return UnsupportedCurrencyError{CurrencyCode: receivingCurrencyCode}
+	}
+	return checkConvertibleAmount(*currency, amount)
+}
+
+// checkConvertibleAmount returns an AmountOutOfRangeError if an amount in the smallest unit of a currency is outside
+// its convertible range.
+func checkConvertibleAmount(currency protocol.Currency, amount int64) error {
+	if currency.Convertible.Contains(amount) {
+		return nil
+	}
+	return AmountOutOfRangeError{
+		Amount:       amount,
+		CurrencyCode: currency.Code,
+		Min:          currency.Convertible.MinSendable,
+		Max:          currency.Convertible.MaxSendable,
+	}
+}
+
+// validatePayRequestAmount checks the amount of an UMA pay request against the receiver's lnurlp response with
+// ValidatePayAmount.
+func validatePayRequestAmount(lnurlpResponse protocol.LnurlpResponse, request *protocol.PayRequest) error {
+	receivingCurrencyCode := ""
+	if request.ReceivingCurrencyCode != nil {
+		receivingCurrencyCode = *request.ReceivingCurrencyCode
+	}
+	return ValidatePayAmount(
+		lnurlpResponse, request.Amount, receivingCurrencyCode, request.SendingAmountCurrencyCode != nil)
+}
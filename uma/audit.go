@@ -0,0 +1,136 @@
+package uma
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditDecision is the outcome of verifying a signed message from a counterparty VASP.
+type AuditDecision string
+
+const (
+	// AuditDecisionAccepted means the nonce was fresh and the signature was valid.
+	AuditDecisionAccepted AuditDecision = "ACCEPTED"
+	// AuditDecisionNonceRejected means the nonce was reused or the timestamp was stale.
+	AuditDecisionNonceRejected AuditDecision = "NONCE_REJECTED"
+	// AuditDecisionSignatureInvalid means the signature did not match any of the counterparty's keys.
+	AuditDecisionSignatureInvalid AuditDecision = "SIGNATURE_INVALID"
+)
+
+// VerificationAuditRecord records the verification of one signed message, so that a VASP can show regulators which
+// messages it accepted and why it rejected others.
+type VerificationAuditRecord struct {
+	// VerifiedAt is the time of the verification, from the Clock set with WithClock.
+	VerifiedAt time.Time `json:"verifiedAt"`
+	// MessageType is the type of the verified message.
+	MessageType MessageType `json:"messageType"`
+	// Counterparty is the domain of the VASP which signed the message.
+	Counterparty string `json:"counterparty"`
+	// Nonce is the nonce of the message.
+	Nonce string `json:"nonce"`
+	// MessageTimestamp is the timestamp the counterparty signed the message with.
+	MessageTimestamp time.Time `json:"messageTimestamp"`
+	// MessageDigest is the hex-encoded SHA-256 digest of the signable payload of the message.
+	MessageDigest string `json:"messageDigest"`
+	// KeyId is the id of the key which matched the signature, if the counterparty publishes key ids.
+	KeyId string `json:"keyId,omitempty"`
+	// Decision is the outcome of the verification.
+	Decision AuditDecision `json:"decision"`
+	// Error is the reason the message was rejected, if it was.
+	Error string `json:"error,omitempty"`
+	// CorrelationId is the ID set with WithCorrelationId, if any.
+	CorrelationId string `json:"correlationId,omitempty"`
+}
+
+// AuditWriter receives a VerificationAuditRecord for every signed message verified by the SDK. If writing the record
+// of an accepted message fails, the verification fails too, so that no message is acted on without an audit trail.
+//
+// Implementations of this interface should be thread-safe.
+type AuditWriter interface {
+	WriteAuditRecord(ctx context.Context, record VerificationAuditRecord) error
+}
+
+// WithAuditWriter sets the AuditWriter which records signature verification decisions. By default nothing is recorded.
+func WithAuditWriter(writer AuditWriter) Option {
+	return func(o *options) {
+		o.auditWriter = writer
+	}
+}
+
+// JsonLinesAuditWriter is an AuditWriter which writes each record as a line of JSON, e.g. to an append-only file.
+type JsonLinesAuditWriter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJsonLinesAuditWriter Creates a JsonLinesAuditWriter writing to w.
+func NewJsonLinesAuditWriter(w io.Writer) *JsonLinesAuditWriter {
+	return &JsonLinesAuditWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *JsonLinesAuditWriter) WriteAuditRecord(_ context.Context, record VerificationAuditRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.encoder.Encode(record)
+}
+
+// NonceCacheEntry is a nonce saved in a NonceCache, with the timestamp of the message it was used in.
+type NonceCacheEntry struct {
+	Nonce     string    `json:"nonce"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ExportNonceCacheEntries Writes nonce cache entries as lines of JSON, for example to show regulators which nonces a
+// VASP held to prevent replays at a point in time.
+//
+// Args:
+//
+//	w: the writer to export to.
+//	entries: the entries to export, e.g. from InMemoryNonceCache.Entries.
+func ExportNonceCacheEntries(w io.Writer, entries []NonceCacheEntry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to export nonce %s: %w", entry.Nonce, err)
+		}
+	}
+	return nil
+}
+
+// Entries returns the nonces in the cache, ordered by timestamp.
+func (c *InMemoryNonceCache) Entries() []NonceCacheEntry {
+	var entries []NonceCacheEntry
+	c.cache.Range(func(key, value interface{}) bool {
+		entries = append(entries, NonceCacheEntry{Nonce: key.(string), Timestamp: value.(time.Time)})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries
+}
+
+// writeAuditRecord writes the record of a verification to the AuditWriter, if any.
+func (o *options) writeAuditRecord(ctx context.Context, record VerificationAuditRecord, payload []byte, err error) error {
+	if o.auditWriter == nil {
+		return nil
+	}
+	digest := sha256.Sum256(payload)
+	record.VerifiedAt = o.clock.Now()
+	record.MessageDigest = hex.EncodeToString(digest[:])
+	record.CorrelationId = o.correlationIdFor(ctx)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if auditErr := o.auditWriter.WriteAuditRecord(ctx, record); auditErr != nil {
+		return fmt.Errorf("failed to write audit record: %w", auditErr)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package uma
+
+import (
+	"errors"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// AppendPayRequestBackingSignature Appends a backing signature to the compliance payer data of a signed pay request.
+// Backing VASPs sign the same payload as the sending VASP.
+//
+// Args:
+//
+//	request: the signed pay request. It is modified in place.
+//	signer: the UmaSigner of the backing VASP.
+//	domain: the domain of the backing VASP. Its public keys will be fetched from this domain during verification.
+func AppendPayRequestBackingSignature(request *protocol.PayRequest, signer UmaSigner, domain string) error {
+	complianceData, err := request.PayerData.Compliance()
+	if err != nil {
+		return err
+	}
+	if complianceData == nil {
+		return errors.New("missing compliance data")
+	}
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return err
+	}
+	complianceData.BackingSignatures = appendBackingSignature(complianceData.BackingSignatures, domain, *signature)
+	request.PayerData, err = payerDataWithCompliance(*request.PayerData, complianceData)
+	return err
+}
+
+// VerifyPayReqBackingSignatures Verifies all backing signatures on a pay request. The public keys of each backing
+// VASP are fetched from its domain (or from the cache) and checked against the signed payload.
+//
+// Args:
+//
+//	request: the pay request to verify.
+//	cache: the PublicKeyCache cache to use for backing VASP public keys.
+//	opts: optional settings such as WithSignatureVerifier and WithRequestDoer.
+func VerifyPayReqBackingSignatures(request *protocol.PayRequest, cache PublicKeyCache, opts ...Option) error {
+	complianceData, err := request.PayerData.Compliance()
+	if err != nil {
+		return err
+	}
+	if complianceData == nil {
+		return errors.New("missing compliance data")
+	}
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return err
+	}
+	return verifyBackingSignatures(signablePayload, complianceData.BackingSignatures, cache, opts)
+}
+
+// AppendPayReqResponseBackingSignature Appends a backing signature to the compliance payee data of a signed pay
+// request response. Backing VASPs sign the same payload as the receiving VASP.
+//
+// Args:
+//
+//	response: the signed pay request response. It is modified in place.
+//	signer: the UmaSigner of the backing VASP.
+//	domain: the domain of the backing VASP. Its public keys will be fetched from this domain during verification.
+//	payerIdentifier: the identifier of the sender. For example, $alice@vasp1.com
+//	payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
+func AppendPayReqResponseBackingSignature(
+	response *protocol.PayReqResponse,
+	signer UmaSigner,
+	domain string,
+	payerIdentifier string,
+	payeeIdentifier string,
+) error {
+	complianceData, err := response.PayeeData.Compliance()
+	if err != nil {
+		return err
+	}
+	if complianceData == nil {
+		return errors.New("missing compliance data")
+	}
+	signablePayload, err := complianceData.SignablePayload(payerIdentifier, payeeIdentifier)
+	if err != nil {
+		return err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return err
+	}
+	complianceData.BackingSignatures = appendBackingSignature(complianceData.BackingSignatures, domain, *signature)
+	response.PayeeData, err = payeeDataWithCompliance(*response.PayeeData, complianceData)
+	return err
+}
+
+// VerifyPayReqResponseBackingSignatures Verifies all backing signatures on a pay request response. The public keys
+// of each backing VASP are fetched from its domain (or from the cache) and checked against the signed payload.
+//
+// Args:
+//
+//	response: the pay request response to verify.
+//	cache: the PublicKeyCache cache to use for backing VASP public keys.
+//	payerIdentifier: the identifier of the sender. For example, $alice@vasp1.com
+//	payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
+//	opts: optional settings such as WithSignatureVerifier and WithRequestDoer.
+func VerifyPayReqResponseBackingSignatures(
+	response *protocol.PayReqResponse,
+	cache PublicKeyCache,
+	payerIdentifier string,
+	payeeIdentifier string,
+	opts ...Option,
+) error {
+	complianceData, err := response.PayeeData.Compliance()
+	if err != nil {
+		return err
+	}
+	if complianceData == nil {
+		return errors.New("missing compliance data")
+	}
+	signablePayload, err := complianceData.SignablePayload(payerIdentifier, payeeIdentifier)
+	if err != nil {
+		return err
+	}
+	return verifyBackingSignatures(signablePayload, complianceData.BackingSignatures, cache, opts)
+}
+
+func appendBackingSignature(
+	backingSignatures *[]protocol.BackingSignature,
+	domain string,
+	signature string,
+) *[]protocol.BackingSignature {
+	var result []protocol.BackingSignature
+	if backingSignatures != nil {
+		result = append(result, *backingSignatures...)
+	}
+	result = append(result, protocol.BackingSignature{Domain: domain, Signature: signature})
+	return &result
+}
+
+func verifyBackingSignatures(
+	payload []byte,
+	backingSignatures *[]protocol.BackingSignature,
+	cache PublicKeyCache,
+	opts []Option,
+) error {
+	if backingSignatures == nil {
+		return nil
+	}
+	o := newOptions(opts)
+	for _, backingSignature := range *backingSignatures {
+		pubKeyResponse, err := FetchPublicKeyForVasp(backingSignature.Domain, cache, opts...)
+		if err != nil {
+			return err
+		}
+		keyId, err := o.verifySignature(payload, backingSignature.Signature, *pubKeyResponse)
+		if err != nil {
+			return errors.New("invalid backing signature from " + backingSignature.Domain)
+		}
+		if o.signingKeyMatchHandler != nil {
+			o.signingKeyMatchHandler(keyId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package uma
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// WithAllowedCallbackDomains accepts lnurlp response callbacks on the given domains even when they are not on the
+// receiver's registrable domain, e.g. for a VASP which serves its API from a separate domain. Entries of the form
+// "*.example.com" match any subdomain of example.com.
+func WithAllowedCallbackDomains(domains ...string) Option {
+	return func(o *options) {
+		o.allowedCallbackDomains = append(o.allowedCallbackDomains, domains...)
+	}
+}
+
+// CallbackUrlError is returned when the callback URL of an lnurlp response is not safe to send a pay request to.
+type CallbackUrlError struct {
+	Callback string
+	Reason   string
+}
+
+func (e CallbackUrlError) Error() string {
+	return fmt.Sprintf("invalid lnurlp response callback %s: %s", e.Callback, e.Reason)
+}
+
+// ValidateLnurlpCallback Checks that the callback URL of an lnurlp response is safe to send a pay request to, since a
+// receiving VASP could otherwise direct the sender's pay request, including its payer data, to an attacker. The
+// callback must use HTTPS unless it is on localhost, must not be a private IP address, and must be on the same
+// registrable domain as the receiver address per utils.RegistrableDomain, e.g. api.vasp2.com for $bob@vasp2.com,
+// unless its domain is allowed with WithAllowedCallbackDomains. Domains are not resolved here; use WithDomainPolicy
+// with BlockPrivateIps to also check the addresses they resolve to when the pay request is sent.
+//
+// Args:
+//
+//	callback: the callback URL from the lnurlp response.
+//	receiverAddress: the receiver address which was queried, e.g. $bob@vasp2.com.
+//	opts: optional settings such as WithAllowedCallbackDomains.
+func ValidateLnurlpCallback(callback string, receiverAddress string, opts ...Option) error {
+	o := newOptions(opts)
+	callbackUrl, err := url.Parse(callback)
+	if err != nil || callbackUrl.Host == "" {
+		return CallbackUrlError{Callback: callback, Reason: "not an absolute URL"}
+	}
+	callbackHost := strings.ToLower(callbackUrl.Hostname())
+	isLocalhost := utils.IsDomainLocalhost(callbackHost)
+	if callbackUrl.Scheme != "https" && (callbackUrl.Scheme != "http" || !isLocalhost) {
+		return CallbackUrlError{Callback: callback, Reason: "scheme " + callbackUrl.Scheme + " is not allowed"}
+	}
+	if ip := net.ParseIP(callbackHost); ip != nil && !isLocalhost && utils.IsPrivateIp(ip) {
+		return CallbackUrlError{Callback: callback, Reason: "private IP addresses are not allowed"}
+	}
+
+	receiverDomain, err := GetVaspDomainFromUmaAddress(receiverAddress)
+	if err != nil {
+		return err
+	}
+	if isLocalhost && utils.IsDomainLocalhost(receiverDomain) {
+		return nil
+	}
+	if utils.IsSameRegistrableDomain(callbackHost, receiverDomain) {
+		return nil
+	}
+	if len(o.allowedCallbackDomains) > 0 {
+		allowlist := utils.DomainPolicy{AllowedDomains: o.allowedCallbackDomains}
+		if allowlist.CheckDomain(callbackHost) == nil {
+			return nil
+		}
+	}
+	return CallbackUrlError{Callback: callback, Reason: "not on the receiver's domain " + receiverDomain}
+}
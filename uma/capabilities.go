@@ -0,0 +1,202 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// CounterpartyFeature is an optional protocol feature which a counterparty VASP may support.
+type CounterpartyFeature string
+
+const (
+	// FeatureInvoices indicates that the VASP accepts UMA invoices.
+	FeatureInvoices CounterpartyFeature = "invoices"
+	// FeaturePaymentStatusWebhooks indicates that the VASP accepts payment status webhooks.
+	FeaturePaymentStatusWebhooks CounterpartyFeature = "payment_status_webhooks"
+	// FeaturePayReqCancellation indicates that the VASP accepts pay request cancellations.
+	FeaturePayReqCancellation CounterpartyFeature = "payreq_cancellation"
+	// FeaturePaymentMandates indicates that the VASP supports recurring payment mandates.
+	FeaturePaymentMandates CounterpartyFeature = "payment_mandates"
+	// FeatureSigningKeyRotation indicates that the VASP advertises key IDs or additional signing keys in its
+	// PubKeyResponse.
+	FeatureSigningKeyRotation CounterpartyFeature = "signing_key_rotation"
+	// FeatureJcsSignatures indicates that the VASP accepts messages signed with SignatureSchemeJcs.
+	FeatureJcsSignatures CounterpartyFeature = "jcs_signatures"
+)
+
+// CounterpartyCapabilities describes what a counterparty VASP supports, so that senders can tailor their UX before
+// the user enters an amount.
+type CounterpartyCapabilities struct {
+	// VaspDomain is the domain of the VASP.
+	VaspDomain string
+	// UmaMajorVersions are the major versions of the UMA protocol the VASP supports, or nil if it does not publish an
+	// UMA configuration. Version negotiation in the lnurlp request still applies in that case.
+	UmaMajorVersions []int
+	// Currencies are the currencies the VASP advertises, or nil if it does not advertise any.
+	Currencies []protocol.Currency
+	// Features are the optional features the VASP supports.
+	Features []CounterpartyFeature
+	// PubKeyResponse is the VASP's public keys, as published when the capabilities were fetched.
+	PubKeyResponse *protocol.PubKeyResponse
+	// Configuration is the VASP's UMA configuration, or nil if it does not publish one.
+	Configuration *protocol.UmaConfiguration
+	// FetchedAt is when the capabilities were fetched.
+	FetchedAt time.Time
+}
+
+// SupportsFeature returns whether the VASP supports an optional feature.
+func (c *CounterpartyCapabilities) SupportsFeature(feature CounterpartyFeature) bool {
+	for _, supported := range c.Features {
+		if supported == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsMajorVersion returns whether the VASP supports a major version of the UMA protocol. If the VASP does not
+// publish its versions, it is assumed to support all versions supported by the SDK.
+func (c *CounterpartyCapabilities) SupportsMajorVersion(majorVersion int) bool {
+	versions := c.UmaMajorVersions
+	if versions == nil {
+		versions = GetSupportedMajorVersions()
+	}
+	for _, version := range versions {
+		if version == majorVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitiesCache caches the capabilities of counterparty VASPs.
+type CapabilitiesCache interface {
+	// FetchCapabilities returns the cached capabilities of a VASP, or nil if none are cached or they are stale.
+	FetchCapabilities(vaspDomain string) *CounterpartyCapabilities
+	// AddCapabilities caches the capabilities of a VASP.
+	AddCapabilities(vaspDomain string, capabilities *CounterpartyCapabilities)
+}
+
+// InMemoryCapabilitiesCache is a CapabilitiesCache which keeps capabilities in memory for a fixed time.
+type InMemoryCapabilitiesCache struct {
+	mutex sync.RWMutex
+	ttl   time.Duration
+	cache map[string]*CounterpartyCapabilities
+	clock Clock
+}
+
+// NewInMemoryCapabilitiesCache Creates an InMemoryCapabilitiesCache.
+//
+// Args:
+//
+//	ttl: how long capabilities are cached, e.g. an hour.
+func NewInMemoryCapabilitiesCache(ttl time.Duration) *InMemoryCapabilitiesCache {
+	return &InMemoryCapabilitiesCache{ttl: ttl, cache: make(map[string]*CounterpartyCapabilities), clock: SystemClock}
+}
+
+// SetClock sets the Clock used to check whether cached capabilities have expired. Defaults to SystemClock.
+func (c *InMemoryCapabilitiesCache) SetClock(clock Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clockOrSystem(clock)
+}
+
+func (c *InMemoryCapabilitiesCache) FetchCapabilities(vaspDomain string) *CounterpartyCapabilities {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry := c.cache[vaspDomain]
+	if entry == nil || c.clock.Now().Sub(entry.FetchedAt) >= c.ttl {
+		return nil
+	}
+	return entry
+}
+
+func (c *InMemoryCapabilitiesCache) AddCapabilities(vaspDomain string, capabilities *CounterpartyCapabilities) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache[vaspDomain] = capabilities
+}
+
+// GetCounterpartyCapabilities Discovers what a counterparty VASP supports from its public keys at
+// /.well-known/lnurlpubkey and, if it publishes one, its configuration at /.well-known/uma-configuration. Domains
+// without public keys are not UMA VASPs, and return an error.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	vaspDomain: the domain of the VASP, e.g. vasp2.com.
+//	cache: the cache to use for the capabilities.
+//	opts: optional settings such as WithRequestDoer.
+func GetCounterpartyCapabilities(
+	ctx context.Context,
+	vaspDomain string,
+	cache CapabilitiesCache,
+	opts ...Option,
+) (*CounterpartyCapabilities, error) {
+	if capabilities := cache.FetchCapabilities(vaspDomain); capabilities != nil {
+		return capabilities, nil
+	}
+	o := newOptions(opts)
+	pubKeyResponse, err := o.fetchPublicKey(ctx, vaspDomain)
+	if err != nil {
+		return nil, err
+	}
+	configuration, err := o.fetchUmaConfiguration(ctx, vaspDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := &CounterpartyCapabilities{
+		VaspDomain:     vaspDomain,
+		PubKeyResponse: pubKeyResponse,
+		Configuration:  configuration,
+		FetchedAt:      o.clock.Now(),
+	}
+	if pubKeyResponse.SigningKeyId != nil || len(pubKeyResponse.SigningKeys) > 0 {
+		capabilities.Features = append(capabilities.Features, FeatureSigningKeyRotation)
+	}
+	if configuration != nil {
+		capabilities.UmaMajorVersions = configuration.UmaMajorVersions
+		if configuration.Currencies != nil {
+			capabilities.Currencies = *configuration.Currencies
+		}
+		if configuration.UmaRequestEndpoint != nil {
+			capabilities.Features = append(capabilities.Features, FeatureInvoices)
+		}
+		for _, feature := range configuration.Features {
+			if !capabilities.SupportsFeature(CounterpartyFeature(feature)) {
+				capabilities.Features = append(capabilities.Features, CounterpartyFeature(feature))
+			}
+		}
+	}
+	cache.AddCapabilities(vaspDomain, capabilities)
+	return capabilities, nil
+}
+
+// fetchUmaConfiguration fetches the UMA configuration of a VASP, or returns nil if it does not publish one.
+func (o *options) fetchUmaConfiguration(ctx context.Context, vaspDomain string) (*protocol.UmaConfiguration, error) {
+	responseBodyBytes, err := o.sendRequest(ctx, outboundRequest{
+		method:      http.MethodGet,
+		url:         o.domainPolicy.Scheme(vaspDomain) + "://" + vaspDomain + "/.well-known/uma-configuration",
+		idempotent:  true,
+		expectsJson: true,
+	})
+	var invalidResponseError InvalidResponseError
+	if errors.As(err, &invalidResponseError) && invalidResponseError.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var configuration protocol.UmaConfiguration
+	if err = json.Unmarshal(responseBodyBytes, &configuration); err != nil {
+		return nil, err
+	}
+	return &configuration, nil
+}
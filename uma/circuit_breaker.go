@@ -0,0 +1,161 @@
+package uma
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when requests to a counterparty domain are rejected because its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open for this VASP domain")
+
+// ErrRateLimited is returned when requests to a counterparty domain are rejected by the rate limiter.
+var ErrRateLimited = errors.New("rate limit exceeded for this VASP domain")
+
+// CircuitBreakerConfig configures the per-domain circuit breaker and rate limiter.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures after which the circuit opens. Zero disables the breaker.
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before allowing a single trial request.
+	OpenDuration time.Duration
+	// RequestsPerSecond is the sustained rate of requests allowed per domain. Zero disables rate limiting.
+	RequestsPerSecond float64
+	// Burst is the maximum number of requests allowed at once per domain.
+	Burst int
+}
+
+// DefaultCircuitBreakerConfig returns a config which opens the circuit after 5 consecutive failures for 30 seconds
+// and allows 10 requests per second per domain with a burst of 20.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold:  5,
+		OpenDuration:      30 * time.Second,
+		RequestsPerSecond: 10,
+		Burst:             20,
+	}
+}
+
+// DomainState is the circuit breaker and rate limiter state for a single counterparty domain.
+type DomainState struct {
+	// ConsecutiveFailures is the number of failed requests since the last success.
+	ConsecutiveFailures int
+	// OpenedAt is when the circuit was last opened. Zero if the circuit is closed.
+	OpenedAt time.Time
+	// Tokens is the number of requests currently available in the rate limiter bucket.
+	Tokens float64
+	// LastRefill is when the rate limiter bucket was last refilled.
+	LastRefill time.Time
+}
+
+// CircuitBreakerStore stores per-domain circuit breaker state. Implement this to share state across instances, for
+// example in Redis.
+//
+// Implementations of this interface should be thread-safe.
+type CircuitBreakerStore interface {
+	// UpdateDomainState atomically reads, updates, and saves the state of a domain. The update function receives
+	// nil if there is no state for the domain yet.
+	UpdateDomainState(domain string, update func(state *DomainState) *DomainState) error
+}
+
+// InMemoryCircuitBreakerStore is an in-memory implementation of CircuitBreakerStore.
+type InMemoryCircuitBreakerStore struct {
+	mu     sync.Mutex
+	states map[string]*DomainState
+}
+
+func NewInMemoryCircuitBreakerStore() *InMemoryCircuitBreakerStore {
+	return &InMemoryCircuitBreakerStore{states: make(map[string]*DomainState)}
+}
+
+func (s *InMemoryCircuitBreakerStore) UpdateDomainState(domain string, update func(state *DomainState) *DomainState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current *DomainState
+	if state, ok := s.states[domain]; ok {
+		stateCopy := *state
+		current = &stateCopy
+	}
+	s.states[domain] = update(current)
+	return nil
+}
+
+// CircuitBreakerRequestDoer wraps a RequestDoer with a per-domain circuit breaker and rate limiter, so that one
+// misbehaving counterparty cannot exhaust connections or retry budgets.
+type CircuitBreakerRequestDoer struct {
+	next   RequestDoer
+	config CircuitBreakerConfig
+	store  CircuitBreakerStore
+	clock  Clock
+}
+
+// NewCircuitBreakerRequestDoer creates a CircuitBreakerRequestDoer. If store is nil, an in-memory store is used.
+func NewCircuitBreakerRequestDoer(next RequestDoer, config CircuitBreakerConfig, store CircuitBreakerStore) *CircuitBreakerRequestDoer {
+	if store == nil {
+		store = NewInMemoryCircuitBreakerStore()
+	}
+	return &CircuitBreakerRequestDoer{next: next, config: config, store: store, clock: SystemClock}
+}
+
+// SetClock sets the Clock used for open circuits and rate limits. Defaults to SystemClock. It must be called before
+// the CircuitBreakerRequestDoer is used.
+func (d *CircuitBreakerRequestDoer) SetClock(clock Clock) {
+	d.clock = clockOrSystem(clock)
+}
+
+func (d *CircuitBreakerRequestDoer) Do(req *http.Request) (*http.Response, error) {
+	domain := req.URL.Host
+	var rejection error
+	err := d.store.UpdateDomainState(domain, func(state *DomainState) *DomainState {
+		now := d.clock.Now()
+		if state == nil {
+			state = &DomainState{Tokens: float64(d.config.Burst), LastRefill: now}
+		}
+		if d.config.FailureThreshold > 0 && !state.OpenedAt.IsZero() {
+			if now.Sub(state.OpenedAt) < d.config.OpenDuration {
+				rejection = ErrCircuitOpen
+				return state
+			}
+			// Half-open: let this request through as a trial, and re-open immediately if it fails.
+			state.OpenedAt = time.Time{}
+			state.ConsecutiveFailures = d.config.FailureThreshold - 1
+		}
+		if d.config.RequestsPerSecond > 0 {
+			elapsed := now.Sub(state.LastRefill).Seconds()
+			state.Tokens = math.Min(float64(d.config.Burst), state.Tokens+elapsed*d.config.RequestsPerSecond)
+			state.LastRefill = now
+			if state.Tokens < 1 {
+				rejection = ErrRateLimited
+				return state
+			}
+			state.Tokens--
+		}
+		return state
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		return nil, rejection
+	}
+
+	resp, err := d.next.Do(req)
+	failed := err != nil || (resp != nil && resp.StatusCode >= 500)
+	// Failing to record the outcome shouldn't fail the request itself.
+	_ = d.store.UpdateDomainState(domain, func(state *DomainState) *DomainState {
+		if state == nil {
+			state = &DomainState{Tokens: float64(d.config.Burst), LastRefill: d.clock.Now()}
+		}
+		if !failed {
+			state.ConsecutiveFailures = 0
+			return state
+		}
+		state.ConsecutiveFailures++
+		if d.config.FailureThreshold > 0 && state.ConsecutiveFailures >= d.config.FailureThreshold {
+			state.OpenedAt = d.clock.Now()
+		}
+		return state
+	})
+	return resp, err
+}
@@ -0,0 +1,52 @@
+package uma
+
+import "time"
+
+// Clock tells the SDK the current time. It is used wherever the SDK reads the time, e.g. for signature timestamps,
+// key and quote expiry, cache TTLs and rate limits, so that tests can freeze or advance time to check expiration logic
+// deterministically. See umatest.FakeClock.
+//
+// SDK functions and handlers use the clock set with WithClock. Stateful components such as InMemoryPublicKeyCache and
+// RetryBudget use the clock set with their SetClock method.
+//
+// Implementations of this interface should be thread-safe.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// TimerClock is a Clock which can also wait for its time to pass. The SDK waits between retries, pending pay request
+// polls and public key refreshes with the Clock set with WithClock if it is a TimerClock, e.g. umatest.FakeClock, and
+// with the system clock otherwise.
+type TimerClock interface {
+	Clock
+	// After returns a channel which receives the current time once the duration has passed.
+	After(duration time.Duration) <-chan time.Time
+}
+
+// ClockFunc is an adapter to use an ordinary function as a Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock used when none is set, which returns the time of the system clock.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// WithClock sets the Clock used to read the current time. Defaults to SystemClock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// clockOrSystem returns the given clock, or SystemClock if it is nil.
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
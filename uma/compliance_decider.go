@@ -0,0 +1,166 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ErrComplianceHeld is returned when a ComplianceDecider holds a pay request for review, so that no invoice is created
+// for it yet.
+var ErrComplianceHeld = errors.New("held for compliance review")
+
+// ComplianceDecision is the outcome of a ComplianceDecider for a pay request.
+type ComplianceDecision int
+
+const (
+	// ComplianceDecisionAccept lets the receiving VASP create an invoice for the pay request.
+	ComplianceDecisionAccept ComplianceDecision = iota
+	// ComplianceDecisionReject refuses the payment. The sender gets a 403 Forbidden error response.
+	ComplianceDecisionReject
+	// ComplianceDecisionHold defers the payment, for example for manual review. With WithHeldPayRequestStore, the
+	// sender gets a PendingPayReqResponse to poll for the final response with. Otherwise, the sender gets a 409
+	// Conflict error response, and may send a new pay request once the review is complete.
+	ComplianceDecisionHold
+)
+
+func (d ComplianceDecision) String() string {
+	switch d {
+	case ComplianceDecisionAccept:
+		return "accept"
+	case ComplianceDecisionReject:
+		return "reject"
+	case ComplianceDecisionHold:
+		return "hold"
+	default:
+		return fmt.Sprintf("ComplianceDecision(%d)", int(d))
+	}
+}
+
+// ComplianceVerdict is the decision of a ComplianceDecider and the reason for it, which is sent to the sender with
+// rejections and holds.
+type ComplianceVerdict struct {
+	Decision ComplianceDecision
+	Reason   string
+}
+
+// ComplianceDecisionRequest describes a verified pay request for a ComplianceDecider.
+type ComplianceDecisionRequest struct {
+	// PayRequest is the pay request, as parsed.
+	PayRequest *protocol.PayRequest
+	// PayerData is a copy of the payer data of the pay request, with the fields encrypted with EncryptPayerDataFields
+	// decrypted.
+	PayerData protocol.PayerData
+	// TravelRuleInfo is the decrypted travel rule information of the sender, if any.
+	TravelRuleInfo *string
+	// TravelRuleFormat is the format of TravelRuleInfo, if the sender set one.
+	TravelRuleFormat *protocol.TravelRuleFormat
+	// Amount is the amount of the payment in the smallest unit of SendingAmountCurrencyCode, or in millisatoshis if
+	// SendingAmountCurrencyCode is nil.
+	Amount int64
+	// SendingAmountCurrencyCode is the currency of Amount, or nil for millisatoshis.
+	SendingAmountCurrencyCode *string
+	// ReceivingCurrencyCode is the currency the receiver receives, if set.
+	ReceivingCurrencyCode *string
+}
+
+// ComplianceDecider decides whether the receiving VASP creates an invoice for a verified pay request. It is called by
+// PayRequestHandler after the signature of the request is verified, and before the invoice is created. Returning an
+// error responds with an internal server error.
+type ComplianceDecider func(ctx context.Context, request ComplianceDecisionRequest) (ComplianceVerdict, error)
+
+// WithComplianceDecider sets the ComplianceDecider of a PayRequestHandler.
+//
+// Args:
+//
+//	decider: called with each verified pay request before its invoice is created.
+//	encryptionPrivateKey: the encryption private key of the receiving VASP, used to decrypt the travel rule
+//		information and encrypted payer data fields for the decider.
+func WithComplianceDecider(decider ComplianceDecider, encryptionPrivateKey []byte) Option {
+	return func(o *options) {
+		o.complianceDecider = decider
+		o.complianceDeciderEncryptionPrivateKey = encryptionPrivateKey
+	}
+}
+
+// decideCompliance runs the ComplianceDecider for a verified pay request, and returns an error with the status code to
+// respond with unless the request is accepted.
+func (o *options) decideCompliance(ctx context.Context, request *protocol.PayRequest) error {
+	if o.complianceDecider == nil {
+		return nil
+	}
+	decisionRequest, err := o.complianceDecisionRequest(request)
+	if err != nil {
+		return payRequestHandlerError{http.StatusBadRequest, err}
+	}
+	verdict, err := o.complianceDecider(ctx, *decisionRequest)
+	if err != nil {
+		return fmt.Errorf("compliance decision failed: %w", err)
+	}
+	if verdict.Decision != ComplianceDecisionAccept {
+		o.log(ctx, slog.LevelWarn, LogEventPayReqRejected, slog.String("counterparty", payRequestSenderDomain(request)),
+			slog.String("decision", verdict.Decision.String()), slog.String("reason", verdict.Reason))
+	}
+	switch verdict.Decision {
+	case ComplianceDecisionAccept:
+		return nil
+	case ComplianceDecisionReject:
+		return payRequestHandlerError{http.StatusForbidden, complianceVerdictError(ErrComplianceRejected, verdict)}
+	case ComplianceDecisionHold:
+		if o.heldPayRequestStore != nil {
+			return o.holdPayRequest(ctx, request, verdict.Reason)
+		}
+		return payRequestHandlerError{http.StatusConflict, complianceVerdictError(ErrComplianceHeld, verdict)}
+	default:
+		return fmt.Errorf("unknown compliance decision %v", verdict.Decision)
+	}
+}
+
+// complianceDecisionRequest creates the ComplianceDecisionRequest of a pay request, decrypting its travel rule
+// information and payer data fields.
+func (o *options) complianceDecisionRequest(request *protocol.PayRequest) (*ComplianceDecisionRequest, error) {
+	decisionRequest := &ComplianceDecisionRequest{
+		PayRequest:                request,
+		Amount:                    request.Amount,
+		SendingAmountCurrencyCode: request.SendingAmountCurrencyCode,
+		ReceivingCurrencyCode:     request.ReceivingCurrencyCode,
+	}
+	if request.PayerData == nil {
+		return decisionRequest, nil
+	}
+	decisionRequest.PayerData = make(protocol.PayerData, len(*request.PayerData))
+	for field, value := range *request.PayerData {
+		decisionRequest.PayerData[field] = value
+	}
+	if err := DecryptPayerDataFields(decisionRequest.PayerData, o.complianceDeciderEncryptionPrivateKey); err != nil {
+		return nil, err
+	}
+	compliance, err := request.PayerData.Compliance()
+	if err != nil {
+		return nil, err
+	}
+	if compliance == nil {
+		return decisionRequest, nil
+	}
+	decisionRequest.TravelRuleFormat = compliance.TravelRuleFormat
+	if compliance.EncryptedTravelRuleInfo != nil {
+		travelRuleInfo, err := eciesDecryptHex(o.complianceDeciderEncryptionPrivateKey, *compliance.EncryptedTravelRuleInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt travel rule info: %w", err)
+		}
+		travelRuleInfoString := string(travelRuleInfo)
+		decisionRequest.TravelRuleInfo = &travelRuleInfoString
+	}
+	return decisionRequest, nil
+}
+
+func complianceVerdictError(err error, verdict ComplianceVerdict) error {
+	if verdict.Reason == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, verdict.Reason)
+}
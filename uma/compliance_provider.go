@@ -0,0 +1,83 @@
+package uma
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrComplianceRejected should be wrapped by ComplianceProvider implementations when a counterparty or payment is
+// rejected by screening, so callers can distinguish rejections from provider failures with errors.Is.
+var ErrComplianceRejected = errors.New("rejected by compliance screening")
+
+// PaymentDirection is the direction of a payment from the point of view of the calling VASP.
+type PaymentDirection int
+
+const (
+	PaymentDirectionOutgoing PaymentDirection = iota
+	PaymentDirectionIncoming
+)
+
+// UtxoScreeningRequest describes counterparty UTXOs to screen before continuing with a payment.
+type UtxoScreeningRequest struct {
+	Direction PaymentDirection
+	// CounterpartyIdentifier is the UMA address of the counterparty, if known. For example, $bob@vasp2.com.
+	CounterpartyIdentifier string
+	// Utxos are the counterparty's channel UTXOs which are likely to be used for the payment.
+	Utxos []string
+	// NodePubKey is the public key of the counterparty's node, if known.
+	NodePubKey *string
+}
+
+// PaymentRegistration describes a payment which is about to be made or received.
+type PaymentRegistration struct {
+	Direction PaymentDirection
+	// CounterpartyIdentifier is the UMA address of the counterparty, if known.
+	CounterpartyIdentifier string
+	// EncodedInvoice is the bolt11 invoice for the payment.
+	EncodedInvoice string
+	// CounterpartyUtxos are the counterparty's channel UTXOs which are likely to be used for the payment.
+	CounterpartyUtxos []string
+	// CounterpartyNodePubKey is the public key of the counterparty's node, if known.
+	CounterpartyNodePubKey *string
+}
+
+// ComplianceProvider plugs KYT and sanctions screening into the protocol flow. The SDK calls it at these points:
+//
+//   - PreScreenPayee: in SendLnurlpRequest, before contacting the receiving VASP. With WithVaspDirectory, the
+//     directory record of the receiving VASP is available via VaspRecordFromContext.
+//   - ScreenUtxos: in SendPayRequest for the payee's UTXOs, and in GetPayReqResponseWithSigner for the payer's UTXOs.
+//   - RegisterPayment: in SendPayRequest once an invoice is received, and in GetPayReqResponseWithSigner once an
+//     invoice is created.
+//
+// Returning an error from any method aborts the flow. Implementations should wrap ErrComplianceRejected for
+// rejections. Implementations of this interface should be thread-safe.
+type ComplianceProvider interface {
+	PreScreenPayee(ctx context.Context, payeeIdentifier string) error
+	ScreenUtxos(ctx context.Context, request UtxoScreeningRequest) error
+	RegisterPayment(ctx context.Context, registration PaymentRegistration) error
+}
+
+// NoopComplianceProvider is a ComplianceProvider which allows everything. It is the default.
+type NoopComplianceProvider struct{}
+
+func (NoopComplianceProvider) PreScreenPayee(context.Context, string) error {
+	return nil
+}
+
+func (NoopComplianceProvider) ScreenUtxos(context.Context, UtxoScreeningRequest) error {
+	return nil
+}
+
+func (NoopComplianceProvider) RegisterPayment(context.Context, PaymentRegistration) error {
+	return nil
+}
+
+// WithComplianceProvider sets the ComplianceProvider called during the protocol flow. Defaults to
+// NoopComplianceProvider.
+func WithComplianceProvider(provider ComplianceProvider) Option {
+	return func(o *options) {
+		if provider != nil {
+			o.complianceProvider = provider
+		}
+	}
+}
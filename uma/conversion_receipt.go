@@ -0,0 +1,75 @@
+package uma
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// ConversionReceipt records the currency conversion applied to a payment, so that finance teams can reconcile the FX
+// rate and fees of each payment. It is attached to the InvoiceValidatedEvent of senders and the
+// PaymentRegisteredEvent of receivers, and can be persisted alongside a PaymentReceipt.
+type ConversionReceipt struct {
+	// InputAmount is the amount the receiver receives, in the smallest unit of InputCurrencyCode.
+	InputAmount int64 `json:"inputAmount"`
+	// InputCurrencyCode is the currency the receiver receives, e.g. USD.
+	InputCurrencyCode string `json:"inputCurrency"`
+	// InputCurrencyDecimals is the number of decimal places of the input currency.
+	InputCurrencyDecimals int `json:"inputCurrencyDecimals"`
+	// Multiplier is the conversion rate, in millisatoshis per the smallest unit of the input currency.
+	Multiplier float64 `json:"multiplier"`
+	// FeesMillisatoshi are the receiving VASP's fees for the conversion.
+	FeesMillisatoshi int64 `json:"feesMsats"`
+	// OutputMillisatoshi is the amount of the invoice, including the fees.
+	OutputMillisatoshi int64 `json:"outputMsats"`
+	// Timestamp is the time the conversion was recorded, from the Clock set with WithClock.
+	Timestamp time.Time `json:"timestamp"`
+	// QuoteId is the id of the receiving VASP's quote, if any. See WithQuote.
+	QuoteId string `json:"quoteId,omitempty"`
+}
+
+// NewConversionReceipt Creates the ConversionReceipt of a pay request response from its payment info and invoice.
+// For UMA v0 responses, which do not include the receiving amount, it is derived from the invoice amount.
+//
+// Args:
+//
+//	response: the pay request response.
+//	opts: optional settings such as WithClock.
+func NewConversionReceipt(response *protocol.PayReqResponse, opts ...Option) (*ConversionReceipt, error) {
+	if response.PaymentInfo == nil {
+		return nil, errors.New("missing payment info")
+	}
+	invoice, err := utils.DecodeBolt11(response.EncodedInvoice)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode invoice: %w", err)
+	}
+	if invoice.AmountMsats == nil {
+		return nil, errors.New("invoice has no amount")
+	}
+	return newOptions(opts).conversionReceipt(response.PaymentInfo, *invoice.AmountMsats), nil
+}
+
+func (o *options) conversionReceipt(paymentInfo *protocol.PayReqResponsePaymentInfo, outputMsats int64) *ConversionReceipt {
+	receipt := &ConversionReceipt{
+		InputCurrencyCode:     paymentInfo.CurrencyCode,
+		InputCurrencyDecimals: paymentInfo.Decimals,
+		Multiplier:            paymentInfo.Multiplier,
+		FeesMillisatoshi:      paymentInfo.ExchangeFeesMillisatoshi,
+		OutputMillisatoshi:    outputMsats,
+		Timestamp:             o.clock.Now(),
+	}
+	if paymentInfo.Amount != nil {
+		receipt.InputAmount = *paymentInfo.Amount
+	} else if paymentInfo.Multiplier != 0 {
+		receipt.InputAmount = int64(math.Round(
+			float64(outputMsats-paymentInfo.ExchangeFeesMillisatoshi) / paymentInfo.Multiplier))
+	}
+	if paymentInfo.QuoteId != nil {
+		receipt.QuoteId = *paymentInfo.QuoteId
+	}
+	return receipt
+}
@@ -0,0 +1,75 @@
+package uma
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CorrelationIdHeader is the HTTP header which carries the correlation ID of a payment flow between VASPs. The SDK sets
+// it on every outbound request, and the handlers of the SDK read it from inbound requests and echo it on their
+// responses, so that a multi-step failure can be traced in the logs of both VASPs.
+const CorrelationIdHeader = "X-Correlation-Id"
+
+// maxCorrelationIdLength is the longest correlation ID accepted from a counterparty.
+const maxCorrelationIdLength = 128
+
+type correlationIdKey struct{}
+
+// GenerateCorrelationId Generates a random correlation ID for a payment flow.
+func GenerateCorrelationId() string {
+	return uuid.NewString()
+}
+
+// ContextWithCorrelationId returns a copy of ctx carrying a correlation ID. The SDK uses it for requests, log events,
+// events and audit records made with the context when no ID is set with WithCorrelationId.
+func ContextWithCorrelationId(ctx context.Context, correlationId string) context.Context {
+	return context.WithValue(ctx, correlationIdKey{}, correlationId)
+}
+
+// CorrelationIdFromContext returns the correlation ID carried by ctx, or an empty string if there is none. Within the
+// response functions of the SDK's handlers, it is the ID of the inbound request, which can be passed on with
+// WithCorrelationId, e.g. to GetPayReqResponseWithSigner.
+func CorrelationIdFromContext(ctx context.Context) string {
+	correlationId, _ := ctx.Value(correlationIdKey{}).(string)
+	return correlationId
+}
+
+// correlationIdFor returns the correlation ID set with WithCorrelationId, or else the one carried by ctx.
+func (o *options) correlationIdFor(ctx context.Context) string {
+	if o.correlationId != "" {
+		return o.correlationId
+	}
+	return CorrelationIdFromContext(ctx)
+}
+
+// withRequestCorrelationId returns an inbound request carrying its correlation ID in its context, and the options of
+// a handler with the ID set, and echoes the ID on the response. The ID is taken from the CorrelationIdHeader, or else
+// WithCorrelationId, or else generated, so that the handling of every request can be correlated.
+func withRequestCorrelationId(w http.ResponseWriter, r *http.Request, opts []Option) (*http.Request, []Option) {
+	correlationId := r.Header.Get(CorrelationIdHeader)
+	if !isValidCorrelationId(correlationId) {
+		correlationId = newOptions(opts).correlationId
+	}
+	if correlationId == "" {
+		correlationId = GenerateCorrelationId()
+	}
+	w.Header().Set(CorrelationIdHeader, correlationId)
+	opts = append(opts[:len(opts):len(opts)], WithCorrelationId(correlationId))
+	return r.WithContext(ContextWithCorrelationId(r.Context(), correlationId)), opts
+}
+
+// isValidCorrelationId returns whether a correlation ID is non-empty, reasonably short and made of visible ASCII
+// characters, so that it can be sent in a header and is safe to log.
+func isValidCorrelationId(correlationId string) bool {
+	if correlationId == "" || len(correlationId) > maxCorrelationIdLength {
+		return false
+	}
+	for i := 0; i < len(correlationId); i++ {
+		if correlationId[i] < '!' || correlationId[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
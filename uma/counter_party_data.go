@@ -0,0 +1,168 @@
+package uma
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// umaUsernameRegex matches the username part of an UMA address, as accepted in lnurlp request paths.
+var umaUsernameRegex = regexp.MustCompile(`^[$a-zA-Z0-9._\-+]+$`)
+
+// FieldError describes a problem with one field of payer or payee data.
+type FieldError struct {
+	// Field is the name of the field, e.g. "email".
+	Field string
+	// Message describes the problem, e.g. "is mandatory but missing".
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidateCounterPartyData Checks payer or payee data against the CounterPartyDataOptions that requested it, and
+// returns every problem found, so that they can all be reported at once. Each mandatory field must be present and not
+// empty, and the standard fields must be well-formed: the identifier must be an UMA address, the name a string, the
+// email a valid address, the country code an ISO 3166-1 alpha-2 code, the postal address a valid
+// protocol.PostalAddress, the settlement account a valid protocol.SettlementAccount, and the compliance data an
+// object. Fields which were not requested are allowed. Returns nil if the data is valid.
+//
+// Sending VASPs can use it to check their payer data before sending a pay request, and receiving VASPs to check the
+// payer data they receive. The same goes for payee data in the other direction.
+//
+// Args:
+//
+//	options: the requested data, i.e. LnurlpResponse.RequiredPayerData or PayRequest.RequestedPayeeData.
+//	data: the payer or payee data, e.g. a protocol.PayerData or protocol.PayeeData.
+func ValidateCounterPartyData(options protocol.CounterPartyDataOptions, data map[string]interface{}) []FieldError {
+	var fieldErrors []FieldError
+	fields := make([]string, 0, len(options))
+	for field := range options {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if options[field].Mandatory && isEmptyCounterPartyDataValue(data[field]) {
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "is mandatory but missing"})
+		}
+	}
+
+	formatChecks := []struct {
+		field protocol.CounterPartyDataField
+		check func(value string) string
+	}{
+		{protocol.CounterPartyDataFieldIdentifier, checkIdentifierFormat},
+		{protocol.CounterPartyDataFieldName, nil},
+		{protocol.CounterPartyDataFieldEmail, checkEmailFormat},
+		{protocol.CounterPartyDataFieldCountryCode, checkCountryCodeFormat},
+	}
+	for _, formatCheck := range formatChecks {
+		value, ok := data[formatCheck.field.String()]
+		if !ok || isEmptyCounterPartyDataValue(value) {
+			continue
+		}
+		stringValue, isString := value.(string)
+		if !isString {
+			fieldErrors = append(fieldErrors, FieldError{Field: formatCheck.field.String(), Message: "must be a string"})
+			continue
+		}
+		if formatCheck.check == nil {
+			continue
+		}
+		if message := formatCheck.check(stringValue); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: formatCheck.field.String(), Message: message})
+		}
+	}
+	if value, ok := data[protocol.CounterPartyDataFieldPostalAddress.String()]; ok && !isEmptyCounterPartyDataValue(value) {
+		if message := checkPostalAddressFormat(value); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   protocol.CounterPartyDataFieldPostalAddress.String(),
+				Message: message,
+			})
+		}
+	}
+	if value, ok := data[protocol.CounterPartyDataFieldSettlementAccount.String()]; ok && !isEmptyCounterPartyDataValue(value) {
+		if message := checkSettlementAccountFormat(value); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   protocol.CounterPartyDataFieldSettlementAccount.String(),
+				Message: message,
+			})
+		}
+	}
+	if value, ok := data[protocol.CounterPartyDataFieldCompliance.String()]; ok && value != nil {
+		if _, isMap := value.(map[string]interface{}); !isMap {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   protocol.CounterPartyDataFieldCompliance.String(),
+				Message: "must be an object",
+			})
+		}
+	}
+	return fieldErrors
+}
+
+func isEmptyCounterPartyDataValue(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(typedValue) == ""
+	case map[string]interface{}:
+		return len(typedValue) == 0
+	case []interface{}:
+		return len(typedValue) == 0
+	}
+	return false
+}
+
+func checkIdentifierFormat(identifier string) string {
+	parts := strings.Split(identifier, "@")
+	if len(parts) != 2 || !umaUsernameRegex.MatchString(parts[0]) || parts[1] == "" {
+		return fmt.Sprintf("%q is not a valid UMA address", identifier)
+	}
+	return ""
+}
+
+func checkEmailFormat(email string) string {
+	address, err := mail.ParseAddress(email)
+	// ParseAddress also accepts display names, e.g. "Alice <alice@vasp1.com>", which are not valid here.
+	if err != nil || address.Address != email {
+		return fmt.Sprintf("%q is not a valid email address", email)
+	}
+	return ""
+}
+
+func checkCountryCodeFormat(countryCode string) string {
+	if !protocol.IsValidCountryCode(countryCode) {
+		return fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 country code", countryCode)
+	}
+	return ""
+}
+
+func checkPostalAddressFormat(value interface{}) string {
+	payerData := protocol.PayerData{protocol.CounterPartyDataFieldPostalAddress.String(): value}
+	address, err := payerData.PostalAddress()
+	if err != nil {
+		return err.Error()
+	}
+	if err = address.Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func checkSettlementAccountFormat(value interface{}) string {
+	payeeData := protocol.PayeeData{protocol.CounterPartyDataFieldSettlementAccount.String(): value}
+	account, err := payeeData.SettlementAccount()
+	if err != nil {
+		return err.Error()
+	}
+	if err = account.Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ErrCorridorRestricted is returned when a receiving VASP may not offer any currency, or the requested currency, to a
+// sending VASP because of the jurisdiction of the sender. The LnurlpRequestHandler and PayRequestHandler respond with
+// 403 Forbidden and an error status response instead of a quote.
+var ErrCorridorRestricted = errors.New("payments from the sender's jurisdiction are restricted")
+
+// AllCurrencies restricts every currency in CurrencyCorridorPolicy.RestrictedCurrencies.
+const AllCurrencies = "*"
+
+// CurrencyCorridorPolicy restricts the currencies a receiving VASP offers to sending VASPs based on the sender's
+// jurisdiction, since some corridors are not allowed. Set it with WithCurrencyCorridorPolicy.
+type CurrencyCorridorPolicy struct {
+	// ResolveJurisdiction returns the jurisdiction of the sending VASP with the given domain, for example from a
+	// VaspDirectory. It is not called for non-UMA requests, whose jurisdiction is the empty string.
+	ResolveJurisdiction func(ctx context.Context, senderVaspDomain string) (string, error)
+	// RestrictedCurrencies maps jurisdictions to the codes of the currencies which senders from them may not pay in, or
+	// AllCurrencies.
+	RestrictedCurrencies map[string][]string
+}
+
+// WithCurrencyCorridorPolicy sets the CurrencyCorridorPolicy which GetLnurlpResponseWithSigner uses to filter the
+// currencies it advertises, and GetPayReqResponseWithSigner uses to refuse quotes in restricted currencies.
+func WithCurrencyCorridorPolicy(policy CurrencyCorridorPolicy) Option {
+	return func(o *options) {
+		o.currencyCorridorPolicy = &policy
+	}
+}
+
+// FilterCurrencies Returns the currencies which may be offered to a sending VASP, or an error wrapping
+// ErrCorridorRestricted if there are none.
+//
+// Args:
+//
+//	ctx: the context for the jurisdiction resolver.
+//	senderVaspDomain: the domain of the sending VASP, or an empty string for non-UMA requests.
+//	currencies: the currencies the receiving VASP supports.
+func (p CurrencyCorridorPolicy) FilterCurrencies(
+	ctx context.Context,
+	senderVaspDomain string,
+	currencies []protocol.Currency,
+) ([]protocol.Currency, error) {
+	restricted, err := p.restrictedCurrencies(ctx, senderVaspDomain)
+	if err != nil {
+		return nil, err
+	}
+	var allowedCurrencies []protocol.Currency
+	for _, currency := range currencies {
+		if !isCurrencyRestricted(restricted, currency.Code) {
+			allowedCurrencies = append(allowedCurrencies, currency)
+		}
+	}
+	if len(allowedCurrencies) == 0 {
+		return nil, fmt.Errorf("%w: no currency is available", ErrCorridorRestricted)
+	}
+	return allowedCurrencies, nil
+}
+
+// CheckCurrency Returns an error wrapping ErrCorridorRestricted if a currency may not be offered to a sending VASP.
+//
+// Args:
+//
+//	ctx: the context for the jurisdiction resolver.
+//	senderVaspDomain: the domain of the sending VASP, or an empty string for non-UMA requests.
+//	currencyCode: the code of the currency, e.g. USD.
+func (p CurrencyCorridorPolicy) CheckCurrency(ctx context.Context, senderVaspDomain string, currencyCode string) error {
+	restricted, err := p.restrictedCurrencies(ctx, senderVaspDomain)
+	if err != nil {
+		return err
+	}
+	if isCurrencyRestricted(restricted, currencyCode) {
+		return fmt.Errorf("%w: %s is not available", ErrCorridorRestricted, currencyCode)
+	}
+	return nil
+}
+
+// restrictedCurrencies returns the currencies which may not be offered to a sending VASP.
+func (p CurrencyCorridorPolicy) restrictedCurrencies(ctx context.Context, senderVaspDomain string) ([]string, error) {
+	jurisdiction := ""
+	if senderVaspDomain != "" && p.ResolveJurisdiction != nil {
+		var err error
+		jurisdiction, err = p.ResolveJurisdiction(ctx, senderVaspDomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the jurisdiction of %s: %w", senderVaspDomain, err)
+		}
+	}
+	return p.RestrictedCurrencies[jurisdiction], nil
+}
+
+func isCurrencyRestricted(restricted []string, currencyCode string) bool {
+	return slices.Contains(restricted, AllCurrencies) || slices.Contains(restricted, currencyCode)
+}
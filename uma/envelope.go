@@ -0,0 +1,202 @@
+package uma
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// Envelope wraps an UMA protocol message with its message type, so that it can be carried over transports other than
+// HTTP, such as message queues or gRPC between an UMA gateway and internal services.
+//
+// The payload holds the message in its HTTP wire format, so signatures remain verifiable after decoding. Lnurlp
+// requests are carried as their full URL, and UMA invoices as their bech32 string, both encoded as JSON strings.
+type Envelope struct {
+	// Type is the type of the message in the payload.
+	Type MessageType `json:"type"`
+	// CorrelationId is the correlation id set with WithCorrelationId when the envelope was encoded, if any.
+	CorrelationId string `json:"correlationId,omitempty"`
+	// Payload is the wire encoding of the message.
+	Payload json.RawMessage `json:"payload"`
+	// Message is the message, set by NewEnvelope and DecodeMessage. It is one of *protocol.LnurlpRequest,
+	// *protocol.LnurlpResponse, *protocol.PayRequest, *protocol.PayReqResponse, *protocol.PostTransactionCallback,
+	// *protocol.UmaInvoice, *protocol.PaymentStatusWebhook, *protocol.PayReqCancellation, *protocol.PaymentMandate,
+	// *protocol.PaymentPullRequest, *protocol.PaymentMandateRevocation, *protocol.PaymentReceipt or
+	// *protocol.PubKeyResponse, depending on Type.
+	Message interface{} `json:"-"`
+}
+
+// EncodeEnvelope Encodes a protocol message into a JSON Envelope tagged with its message type.
+//
+// Args:
+//
+//	message: a pointer to one of the protocol message types listed on Envelope.Message.
+//	opts: optional settings such as WithCorrelationId.
+func EncodeEnvelope(message interface{}, opts ...Option) ([]byte, error) {
+	envelope, err := NewEnvelope(message, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// NewEnvelope Creates an Envelope for a protocol message without encoding the envelope itself. This is useful for
+// transports with their own framing, such as the UmaEnvelope protobuf message in proto/uma/v1/uma.proto, whose fields
+// map one to one onto Envelope.
+//
+// Args:
+//
+//	message: a pointer to one of the protocol message types listed on Envelope.Message.
+//	opts: optional settings such as WithCorrelationId.
+func NewEnvelope(message interface{}, opts ...Option) (*Envelope, error) {
+	var messageType MessageType
+	var payload []byte
+	var err error
+	switch m := message.(type) {
+	case *protocol.LnurlpRequest:
+		messageType = MessageTypeLnurlpRequest
+		var requestUrl *url.URL
+		requestUrl, err = m.EncodeToUrl()
+		if err == nil {
+			payload, err = json.Marshal(requestUrl.String())
+		}
+	case *protocol.LnurlpResponse:
+		messageType = MessageTypeLnurlpResponse
+		payload, err = json.Marshal(m)
+	case *protocol.PayRequest:
+		messageType = MessageTypePayRequest
+		payload, err = m.MarshalJSON()
+	case *protocol.PayReqResponse:
+		messageType = MessageTypePayReqResponse
+		payload, err = m.MarshalJSON()
+	case *protocol.PostTransactionCallback:
+		messageType = MessageTypePostTransactionCallback
+		payload, err = json.Marshal(m)
+	case *protocol.UmaInvoice:
+		messageType = MessageTypeInvoice
+		var bech32Invoice string
+		bech32Invoice, err = m.ToBech32String()
+		if err == nil {
+			payload, err = json.Marshal(bech32Invoice)
+		}
+	case *protocol.PaymentStatusWebhook:
+		messageType = MessageTypePaymentStatusWebhook
+		payload, err = json.Marshal(m)
+	case *protocol.PayReqCancellation:
+		messageType = MessageTypePayReqCancellation
+		payload, err = json.Marshal(m)
+	case *protocol.PaymentMandate:
+		messageType = MessageTypePaymentMandate
+		payload, err = json.Marshal(m)
+	case *protocol.PaymentPullRequest:
+		messageType = MessageTypePaymentPullRequest
+		payload, err = json.Marshal(m)
+	case *protocol.PaymentMandateRevocation:
+		messageType = MessageTypePaymentMandateRevocation
+		payload, err = json.Marshal(m)
+	case *protocol.PaymentReceipt:
+		messageType = MessageTypePaymentReceipt
+		payload, err = json.Marshal(m)
+	case *protocol.PubKeyResponse:
+		messageType = MessageTypePubKeyResponse
+		payload, err = m.MarshalJSON()
+	default:
+		return nil, fmt.Errorf("unsupported envelope message type %T", message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Type:          messageType,
+		CorrelationId: newOptions(opts).correlationId,
+		Payload:       payload,
+		Message:       message,
+	}, nil
+}
+
+// DecodeEnvelope Decodes an Envelope created by EncodeEnvelope and parses its payload into Envelope.Message. Signatures
+// are not verified; use the Verify functions for the message type as with messages received over HTTP.
+//
+// Args:
+//
+//	data: the encoded envelope.
+//	opts: optional settings such as WithRawPayloadCapture, which applies to the payload.
+func DecodeEnvelope(data []byte, opts ...Option) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if err := envelope.DecodeMessage(opts...); err != nil {
+		return nil, err
+	}
+	return &envelope, nil
+}
+
+// DecodeMessage Parses the payload of an Envelope into Envelope.Message according to its Type. Use this for envelopes
+// received over transports with their own framing; DecodeEnvelope calls it for JSON envelopes.
+//
+// Args:
+//
+//	opts: optional settings such as WithRawPayloadCapture, which applies to the payload.
+func (envelope *Envelope) DecodeMessage(opts ...Option) error {
+	if len(envelope.Payload) == 0 {
+		return errors.New("missing envelope payload")
+	}
+	var err error
+	switch envelope.Type {
+	case MessageTypeLnurlpRequest:
+		var requestUrl *url.URL
+		requestUrl, err = parseEnvelopeUrl(envelope.Payload)
+		if err == nil {
+			envelope.Message, err = ParseLnurlpRequest(*requestUrl, opts...)
+		}
+	case MessageTypeLnurlpResponse:
+		envelope.Message, err = ParseLnurlpResponse(envelope.Payload, opts...)
+	case MessageTypePayRequest:
+		envelope.Message, err = ParsePayRequest(envelope.Payload, opts...)
+	case MessageTypePayReqResponse:
+		envelope.Message, err = ParsePayReqResponse(envelope.Payload, opts...)
+	case MessageTypePostTransactionCallback:
+		envelope.Message, err = ParsePostTransactionCallback(envelope.Payload, opts...)
+	case MessageTypeInvoice:
+		var bech32Invoice string
+		if err = json.Unmarshal(envelope.Payload, &bech32Invoice); err == nil {
+			envelope.Message, err = DecodeUmaInvoice(bech32Invoice, opts...)
+		}
+	case MessageTypePaymentStatusWebhook:
+		envelope.Message, err = ParsePaymentStatusWebhook(envelope.Payload, opts...)
+	case MessageTypePayReqCancellation:
+		envelope.Message, err = ParsePayReqCancellation(envelope.Payload, opts...)
+	case MessageTypePaymentMandate:
+		envelope.Message, err = ParsePaymentMandate(envelope.Payload, opts...)
+	case MessageTypePaymentPullRequest:
+		envelope.Message, err = ParsePaymentPullRequest(envelope.Payload, opts...)
+	case MessageTypePaymentMandateRevocation:
+		envelope.Message, err = ParsePaymentMandateRevocation(envelope.Payload, opts...)
+	case MessageTypePaymentReceipt:
+		envelope.Message, err = ParsePaymentReceipt(envelope.Payload, opts...)
+	case MessageTypePubKeyResponse:
+		var pubKeyResponse protocol.PubKeyResponse
+		if err = json.Unmarshal(envelope.Payload, &pubKeyResponse); err == nil {
+			envelope.Message = &pubKeyResponse
+		}
+	default:
+		return fmt.Errorf("unknown envelope message type: %s", envelope.Type)
+	}
+	if err != nil {
+		envelope.Message = nil
+		return fmt.Errorf("invalid %s payload: %w", envelope.Type, err)
+	}
+	return nil
+}
+
+func parseEnvelopeUrl(payload json.RawMessage) (*url.URL, error) {
+	var rawUrl string
+	if err := json.Unmarshal(payload, &rawUrl); err != nil {
+		return nil, err
+	}
+	return url.Parse(rawUrl)
+}
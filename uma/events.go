@@ -0,0 +1,170 @@
+package uma
+
+import (
+	"context"
+	"time"
+)
+
+// EventType identifies the protocol state transition reported by an Event.
+type EventType string
+
+const (
+	// EventTypeLnurlpSent is reported by SendLnurlpRequest when a sending VASP sends an lnurlp request.
+	EventTypeLnurlpSent EventType = "LNURLP_SENT"
+	// EventTypeLnurlpVerified is reported by VerifyUmaLnurlpQuerySignature when a receiving VASP verifies the
+	// signature of an lnurlp request.
+	EventTypeLnurlpVerified EventType = "LNURLP_VERIFIED"
+	// EventTypePayReqBuilt is reported by GetUmaPayRequestWithInvoice when a sending VASP builds a pay request.
+	EventTypePayReqBuilt EventType = "PAYREQ_BUILT"
+	// EventTypeInvoiceValidated is reported by VerifyPayReqResponse when a sending VASP has validated the invoice of a
+	// pay request response and can pay it.
+	EventTypeInvoiceValidated EventType = "INVOICE_VALIDATED"
+	// EventTypePaymentRegistered is reported when a payment was registered with the ComplianceProvider: by
+	// SendPayRequest on the sending side, and by GetPayReqResponseWithSigner on the receiving side.
+	EventTypePaymentRegistered EventType = "PAYMENT_REGISTERED"
+)
+
+// Event is a protocol state transition reported to an EventSink. Its concrete type is one of *LnurlpSentEvent,
+// *LnurlpVerifiedEvent, *PayReqBuiltEvent, *InvoiceValidatedEvent and *PaymentRegisteredEvent.
+type Event interface {
+	// Type returns the type of the event.
+	Type() EventType
+	// Metadata returns the fields common to all events.
+	Metadata() EventMetadata
+}
+
+// EventMetadata holds the fields common to all events.
+type EventMetadata struct {
+	// Timestamp is the time of the transition, from the Clock set with WithClock.
+	Timestamp time.Time
+	// CorrelationId is the ID set with WithCorrelationId, or else carried by the context, if any, so that events from
+	// one payment flow can be joined.
+	CorrelationId string
+}
+
+func (m EventMetadata) Metadata() EventMetadata {
+	return m
+}
+
+func (m *EventMetadata) setCorrelationId(correlationId string) {
+	m.CorrelationId = correlationId
+}
+
+// correlationIdSetter is implemented by the pointer types of events, through their EventMetadata.
+type correlationIdSetter interface {
+	setCorrelationId(correlationId string)
+}
+
+// LnurlpSentEvent is reported when a sending VASP sends an lnurlp request.
+type LnurlpSentEvent struct {
+	EventMetadata
+	// ReceiverAddress is the address of the receiver, e.g. $bob@vasp2.com.
+	ReceiverAddress string
+	// UmaVersion is the UMA version of the request, or empty for non-UMA requests.
+	UmaVersion string
+}
+
+func (*LnurlpSentEvent) Type() EventType {
+	return EventTypeLnurlpSent
+}
+
+// LnurlpVerifiedEvent is reported when a receiving VASP verifies the signature of an lnurlp request.
+type LnurlpVerifiedEvent struct {
+	EventMetadata
+	// SenderVaspDomain is the domain of the sending VASP.
+	SenderVaspDomain string
+	// ReceiverAddress is the address of the receiver, e.g. $bob@vasp2.com.
+	ReceiverAddress string
+	// UmaVersion is the UMA version of the request.
+	UmaVersion string
+}
+
+func (*LnurlpVerifiedEvent) Type() EventType {
+	return EventTypeLnurlpVerified
+}
+
+// PayReqBuiltEvent is reported when a sending VASP builds a pay request.
+type PayReqBuiltEvent struct {
+	EventMetadata
+	// PayerIdentifier is the identifier of the sender, e.g. $alice@vasp1.com.
+	PayerIdentifier string
+	// Amount is the amount of the payment, in millisatoshis or in the smallest unit of the receiving currency.
+	Amount int64
+	// AmountInReceivingCurrency is whether Amount is in the receiving currency rather than millisatoshis.
+	AmountInReceivingCurrency bool
+	// ReceivingCurrencyCode is the code of the currency the receiver will receive, e.g. USD.
+	ReceivingCurrencyCode string
+	// UmaMajorVersion is the major UMA version of the pay request.
+	UmaMajorVersion int
+}
+
+func (*PayReqBuiltEvent) Type() EventType {
+	return EventTypePayReqBuilt
+}
+
+// InvoiceValidatedEvent is reported when a sending VASP has validated the invoice of a pay request response.
+type InvoiceValidatedEvent struct {
+	EventMetadata
+	// PayeeIdentifier is the identifier of the receiver, e.g. $bob@vasp2.com.
+	PayeeIdentifier string
+	// ReceivingCurrencyCode is the code of the currency the receiver will receive, e.g. USD.
+	ReceivingCurrencyCode string
+	// EncodedInvoice is the validated BOLT11 invoice.
+	EncodedInvoice string
+	// Conversion records the currency conversion of the payment.
+	Conversion *ConversionReceipt
+}
+
+func (*InvoiceValidatedEvent) Type() EventType {
+	return EventTypeInvoiceValidated
+}
+
+// PaymentRegisteredEvent is reported when a payment was registered with the ComplianceProvider.
+type PaymentRegisteredEvent struct {
+	EventMetadata
+	// Registration is the registration passed to the ComplianceProvider.
+	Registration PaymentRegistration
+	// Conversion records the currency conversion of the payment. It is only set on the receiving side, by
+	// GetPayReqResponseWithSigner, for responses with payment info.
+	Conversion *ConversionReceipt
+}
+
+func (*PaymentRegisteredEvent) Type() EventType {
+	return EventTypePaymentRegistered
+}
+
+// EventSink receives the protocol events of the SDK, for example to feed funnel analytics and drop-off dashboards.
+// Events are reported synchronously from the goroutine making the transition, so implementations should return
+// quickly, e.g. by buffering events, and must be thread-safe.
+type EventSink interface {
+	HandleEvent(ctx context.Context, event Event)
+}
+
+// EventSinkFunc adapts a function to an EventSink.
+type EventSinkFunc func(ctx context.Context, event Event)
+
+func (f EventSinkFunc) HandleEvent(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// WithEventSink sets the EventSink which receives protocol events. By default events are dropped.
+func WithEventSink(sink EventSink) Option {
+	return func(o *options) {
+		o.eventSink = sink
+	}
+}
+
+// eventMetadata returns the metadata for an event reported now.
+func (o *options) eventMetadata() EventMetadata {
+	return EventMetadata{Timestamp: o.clock.Now(), CorrelationId: o.correlationId}
+}
+
+func (o *options) emitEvent(ctx context.Context, event Event) {
+	if o.eventSink == nil {
+		return
+	}
+	if setter, ok := event.(correlationIdSetter); ok && event.Metadata().CorrelationId == "" {
+		setter.setCorrelationId(CorrelationIdFromContext(ctx))
+	}
+	o.eventSink.HandleEvent(ctx, event)
+}
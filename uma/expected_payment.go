@@ -0,0 +1,188 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// InvoiceAmountTolerance is how far the amount of an invoice may be from the amount implied by the payment info of a
+// pay request response, to allow for floating-point conversion rates. The larger of the two tolerances applies.
+type InvoiceAmountTolerance struct {
+	// Msats is an absolute tolerance in millisatoshis.
+	Msats int64
+	// Bps is a tolerance relative to the implied amount, in basis points, i.e. hundredths of a percent.
+	Bps int64
+}
+
+// DefaultInvoiceAmountTolerance is the InvoiceAmountTolerance used unless WithInvoiceAmountTolerance is set. It allows
+// the invoice to be off by 1 millisatoshi, which covers the rounding of the conversion to millisatoshis.
+var DefaultInvoiceAmountTolerance = InvoiceAmountTolerance{Msats: 1}
+
+// WithInvoiceAmountTolerance sets the InvoiceAmountTolerance used by VerifyPayReqResponse. The AmountToleranceMsats of
+// an ExpectedPayment takes precedence over its Msats. Defaults to DefaultInvoiceAmountTolerance.
+func WithInvoiceAmountTolerance(tolerance InvoiceAmountTolerance) Option {
+	return func(o *options) {
+		o.invoiceAmountTolerance = &tolerance
+	}
+}
+
+// ExpectedPayment describes the payment a sending VASP asked for in its pay request, so that the receiving VASP's
+// response can be checked against it with VerifyPayReqResponse.
+type ExpectedPayment struct {
+	// PayeeIdentifier is the identifier of the receiver, e.g. $bob@vasp2.com.
+	PayeeIdentifier string
+	// ReceivingCurrencyCode is the currency the receiver should receive, e.g. USD.
+	ReceivingCurrencyCode string
+	// Amount is the amount from the pay request. It is in the smallest unit of the receiving currency if
+	// AmountInReceivingCurrency is true, and in millisatoshis otherwise.
+	Amount int64
+	// AmountInReceivingCurrency is whether Amount is in the receiving currency rather than millisatoshis.
+	AmountInReceivingCurrency bool
+	// RequestedPayeeData is the payee data requested in the pay request. If set, the response must contain every
+	// mandatory field.
+	RequestedPayeeData *protocol.CounterPartyDataOptions
+	// AmountToleranceMsats is how far the invoice amount may be from the amount implied by the payment info, on top of
+	// the rounding of the receiving currency amount. Defaults to the Msats of the InvoiceAmountTolerance set with
+	// WithInvoiceAmountTolerance.
+	AmountToleranceMsats int64
+}
+
+// ExpectedPaymentFromPayRequest Creates the ExpectedPayment for a pay request.
+//
+// Args:
+//
+//	request: the pay request sent to the receiving VASP.
+//	payeeIdentifier: the identifier of the receiver, e.g. $bob@vasp2.com.
+func ExpectedPaymentFromPayRequest(request protocol.PayRequest, payeeIdentifier string) ExpectedPayment {
+	expected := ExpectedPayment{
+		PayeeIdentifier:           payeeIdentifier,
+		Amount:                    request.Amount,
+		AmountInReceivingCurrency: request.SendingAmountCurrencyCode != nil,
+		RequestedPayeeData:        request.RequestedPayeeData,
+	}
+	if request.ReceivingCurrencyCode != nil {
+		expected.ReceivingCurrencyCode = *request.ReceivingCurrencyCode
+	}
+	return expected
+}
+
+// VerifyPayReqResponse Checks a pay request response before paying its invoice. In addition to the payee compliance
+// signature, it checks that the response is for the expected currency, that the invoice is for the Network set with
+// WithNetwork (mainnet by default), that the invoice amount matches the payment info and the requested amount within
+// rounding tolerance, that any itemized fees add up, that the quote has not expired, that the mandatory requested
+// payee data was returned, and that the response is not marked as non-disposable.
+//
+// Args:
+//
+//	response: the pay request response from the receiving VASP.
+//	expected: the payment that was requested. See ExpectedPaymentFromPayRequest.
+//	otherVaspPubKeyResponse: the public keys of the receiving VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	payerIdentifier: the identifier of the sender, e.g. $alice@vasp1.com.
+//	opts: optional settings such as WithNetwork, WithInvoiceAmountTolerance, WithLogger and WithEventSink.
+func VerifyPayReqResponse(
+	response *protocol.PayReqResponse,
+	expected ExpectedPayment,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	payerIdentifier string,
+	opts ...Option,
+) error {
+	if !response.IsUmaResponse() {
+		return errors.New("response is not an UMA pay request response")
+	}
+	err := VerifyPayReqResponseSignature(
+		response, otherVaspPubKeyResponse, nonceCache, payerIdentifier, expected.PayeeIdentifier, opts...)
+	if err != nil {
+		return err
+	}
+	if response.Disposable != nil && !*response.Disposable {
+		return errors.New("UMA pay request responses must not set disposable to false")
+	}
+	if response.PaymentInfo.CurrencyCode != expected.ReceivingCurrencyCode {
+		return fmt.Errorf("expected currency %s, but the response is for %s",
+			expected.ReceivingCurrencyCode, response.PaymentInfo.CurrencyCode)
+	}
+	o := newOptions(opts)
+	if err = o.verifyInvoiceAmount(response, expected); err != nil {
+		return err
+	}
+	if err = verifyFeeBreakdown(response.PaymentInfo); err != nil {
+		return err
+	}
+	if err = ValidateQuoteExpiry(response); err != nil {
+		return err
+	}
+	if expected.RequestedPayeeData != nil {
+		if err = ValidatePayeeData(*expected.RequestedPayeeData, *response.PayeeData); err != nil {
+			return err
+		}
+	}
+	conversion, err := NewConversionReceipt(response, opts...)
+	if err != nil {
+		return err
+	}
+	o.emitEvent(context.Background(), &InvoiceValidatedEvent{
+		EventMetadata:         o.eventMetadata(),
+		PayeeIdentifier:       expected.PayeeIdentifier,
+		ReceivingCurrencyCode: expected.ReceivingCurrencyCode,
+		EncodedInvoice:        response.EncodedInvoice,
+		Conversion:            conversion,
+	})
+	return nil
+}
+
+func (o *options) verifyInvoiceAmount(response *protocol.PayReqResponse, expected ExpectedPayment) error {
+	invoice, err := utils.DecodeBolt11(response.EncodedInvoice)
+	if err != nil {
+		return fmt.Errorf("unable to decode invoice: %w", err)
+	}
+	if err = o.checkInvoiceNetwork(invoice); err != nil {
+		return err
+	}
+	if invoice.AmountMsats == nil {
+		return errors.New("invoice has no amount")
+	}
+	invoiceMsats := *invoice.AmountMsats
+	paymentInfo := response.PaymentInfo
+
+	amountTolerance := DefaultInvoiceAmountTolerance
+	if o.invoiceAmountTolerance != nil {
+		amountTolerance = *o.invoiceAmountTolerance
+	}
+	if expected.AmountToleranceMsats != 0 {
+		amountTolerance.Msats = expected.AmountToleranceMsats
+	}
+	tolerance := 0.0
+	var receivingAmount *int64
+	if expected.AmountInReceivingCurrency {
+		if paymentInfo.Amount != nil && *paymentInfo.Amount != expected.Amount {
+			return fmt.Errorf("expected the receiver to receive %d, but the response is for %d",
+				expected.Amount, *paymentInfo.Amount)
+		}
+		receivingAmount = &expected.Amount
+	} else {
+		if invoiceMsats != expected.Amount {
+			return fmt.Errorf("expected an invoice for %d msats, but it is for %d msats", expected.Amount, invoiceMsats)
+		}
+		// The receiving amount was rounded from the invoice amount, so it can be off by up to half a unit.
+		receivingAmount = paymentInfo.Amount
+		tolerance += paymentInfo.Multiplier / 2
+	}
+	if receivingAmount == nil {
+		// UMA v0 responses do not include the receiving amount.
+		return nil
+	}
+	impliedMsats := float64(*receivingAmount)*paymentInfo.Multiplier + float64(paymentInfo.ExchangeFeesMillisatoshi)
+	tolerance += math.Max(float64(amountTolerance.Msats), impliedMsats*float64(amountTolerance.Bps)/10_000)
+	if math.Abs(float64(invoiceMsats)-impliedMsats) > tolerance {
+		return fmt.Errorf("invoice amount of %d msats does not match the payment info, which implies %.0f msats",
+			invoiceMsats, impliedMsats)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package uma
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// WithFeeBreakdown sets the itemized fees advertised in the PaymentInfo of pay request responses, so that sending
+// VASPs can disclose them to their users before the payment is authorized. The exchange and service fees must add up
+// to the receiver fees passed to GetPayReqResponse.
+func WithFeeBreakdown(fees protocol.PaymentFees) Option {
+	return func(o *options) {
+		o.feeBreakdown = &fees
+	}
+}
+
+func (o *options) applyFeeBreakdown(response *protocol.PayReqResponse) error {
+	if o.feeBreakdown == nil || response.PaymentInfo == nil {
+		return nil
+	}
+	if response.UmaMajorVersion == 0 {
+		return errors.New("fee breakdowns are not supported in UMA v0")
+	}
+	fees := *o.feeBreakdown
+	response.PaymentInfo.Fees = &fees
+	return verifyFeeBreakdown(response.PaymentInfo)
+}
+
+// verifyFeeBreakdown checks that the itemized fees of the payment info, if any, add up to its total fees.
+func verifyFeeBreakdown(paymentInfo *protocol.PayReqResponsePaymentInfo) error {
+	if paymentInfo == nil || paymentInfo.Fees == nil {
+		return nil
+	}
+	fees := paymentInfo.Fees
+	if fees.ExchangeFeeMillisatoshi < 0 || fees.ServiceFeeMillisatoshi < 0 ||
+		(fees.NetworkFeeEstimateMillisatoshi != nil && *fees.NetworkFeeEstimateMillisatoshi < 0) {
+		return errors.New("fees must not be negative")
+	}
+	if itemized := fees.ExchangeFeeMillisatoshi + fees.ServiceFeeMillisatoshi; itemized != paymentInfo.ExchangeFeesMillisatoshi {
+		return fmt.Errorf("itemized fees of %d msats do not add up to the total fees of %d msats",
+			itemized, paymentInfo.ExchangeFeesMillisatoshi)
+	}
+	return nil
+}
+
+// PaymentCostSummary summarizes what a payment costs the sender, for display before they authorize it. All amounts
+// are in millisatoshis, except ReceivingAmount.
+type PaymentCostSummary struct {
+	// ReceivingAmount is the amount the receiver will receive in the smallest unit of the receiving currency, or nil
+	// if the response does not include it (UMA v0).
+	ReceivingAmount *int64
+	// ReceivingCurrencyCode is the currency the receiver will receive, e.g. USD.
+	ReceivingCurrencyCode string
+	// ReceivingCurrencyDecimals is the number of decimal places of the receiving currency.
+	ReceivingCurrencyDecimals int
+	// InvoiceAmountMillisatoshi is the amount of the invoice, including the receiving VASP's fees.
+	InvoiceAmountMillisatoshi int64
+	// ExchangeFeeMillisatoshi is the receiving VASP's fee for converting to the receiving currency. If the receiving
+	// VASP did not itemize its fees, this is all of its fees.
+	ExchangeFeeMillisatoshi int64
+	// ServiceFeeMillisatoshi is the receiving VASP's other fees.
+	ServiceFeeMillisatoshi int64
+	// NetworkFeeEstimateMillisatoshi is the estimated lightning routing fee, or 0 if the receiving VASP did not
+	// provide an estimate.
+	NetworkFeeEstimateMillisatoshi int64
+	// SenderFeeMillisatoshi is the sending VASP's own fee.
+	SenderFeeMillisatoshi int64
+	// TotalFeesMillisatoshi is the sum of all fees.
+	TotalFeesMillisatoshi int64
+	// TotalCostMillisatoshi is the total amount the sender pays: the invoice amount, the network fee estimate and the
+	// sending VASP's fee.
+	TotalCostMillisatoshi int64
+}
+
+// GetPaymentCostSummary Computes the total cost of paying a pay request response, itemizing the fees, so that it can
+// be shown to the sender before they authorize the payment. The itemized fees are checked to add up to the total fees
+// of the payment info.
+//
+// Args:
+//
+//	response: the pay request response from the receiving VASP.
+//	senderFeesMillisats: the fees charged by the sending VASP for this payment, in millisatoshis.
+func GetPaymentCostSummary(response *protocol.PayReqResponse, senderFeesMillisats int64) (*PaymentCostSummary, error) {
+	paymentInfo := response.PaymentInfo
+	if paymentInfo == nil {
+		return nil, errors.New("missing payment info")
+	}
+	if err := verifyFeeBreakdown(paymentInfo); err != nil {
+		return nil, err
+	}
+	invoice, err := utils.DecodeBolt11(response.EncodedInvoice)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode invoice: %w", err)
+	}
+	if invoice.AmountMsats == nil {
+		return nil, errors.New("invoice has no amount")
+	}
+	summary := &PaymentCostSummary{
+		ReceivingAmount:           paymentInfo.Amount,
+		ReceivingCurrencyCode:     paymentInfo.CurrencyCode,
+		ReceivingCurrencyDecimals: paymentInfo.Decimals,
+		InvoiceAmountMillisatoshi: *invoice.AmountMsats,
+		ExchangeFeeMillisatoshi:   paymentInfo.ExchangeFeesMillisatoshi,
+		SenderFeeMillisatoshi:     senderFeesMillisats,
+	}
+	if fees := paymentInfo.Fees; fees != nil {
+		summary.ExchangeFeeMillisatoshi = fees.ExchangeFeeMillisatoshi
+		summary.ServiceFeeMillisatoshi = fees.ServiceFeeMillisatoshi
+		if fees.NetworkFeeEstimateMillisatoshi != nil {
+			summary.NetworkFeeEstimateMillisatoshi = *fees.NetworkFeeEstimateMillisatoshi
+		}
+	}
+	summary.TotalFeesMillisatoshi = summary.ExchangeFeeMillisatoshi + summary.ServiceFeeMillisatoshi +
+		summary.NetworkFeeEstimateMillisatoshi + summary.SenderFeeMillisatoshi
+	summary.TotalCostMillisatoshi = summary.InvoiceAmountMillisatoshi + summary.NetworkFeeEstimateMillisatoshi +
+		summary.SenderFeeMillisatoshi
+	return summary, nil
+}
@@ -0,0 +1,522 @@
+package uma
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RequestDoer performs outbound HTTP requests on behalf of the SDK. *http.Client satisfies this interface, so a
+// custom client with its own transport can be passed directly.
+type RequestDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DefaultRequestTimeout is the timeout applied to outbound requests made with the DefaultRequestDoer.
+const DefaultRequestTimeout = 20 * time.Second
+
+// DefaultRequestDoer is the RequestDoer used when none is provided via WithRequestDoer.
+var DefaultRequestDoer RequestDoer = &http.Client{Timeout: DefaultRequestTimeout}
+
+// NewRequestDoer creates a RequestDoer which uses the given transport and the default timeout.
+func NewRequestDoer(transport http.RoundTripper) RequestDoer {
+	return &http.Client{Transport: transport, Timeout: DefaultRequestTimeout}
+}
+
+// maxRedirects is the number of redirects followed by clientWithDomainPolicy, like the default of http.Client.
+const maxRedirects = 10
+
+// clientWithDomainPolicy returns a copy of an *http.Client which checks the URL of each redirect against a
+// DomainPolicy, so that counterparties cannot redirect requests to hosts the policy blocks.
+func clientWithDomainPolicy(client *http.Client, policy *utils.DomainPolicy) *http.Client {
+	checkRedirect := client.CheckRedirect
+	policyClient := *client
+	policyClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := policy.CheckUrl(req.Context(), req.URL.String()); err != nil {
+			return err
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &policyClient
+}
+
+// RequestDoerFunc is an adapter to use an ordinary function as a RequestDoer.
+type RequestDoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f RequestDoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor wraps the RequestDoer used for outbound requests, so that users can inject auth headers, sign requests
+// at the transport layer, log requests or inject faults without replacing the RequestDoer. An interceptor typically
+// returns a RequestDoerFunc which modifies the request, calls next, and inspects the response:
+//
+//	func(next uma.RequestDoer) uma.RequestDoer {
+//		return uma.RequestDoerFunc(func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("Authorization", "Bearer "+token)
+//			return next.Do(req)
+//		})
+//	}
+type Interceptor func(next RequestDoer) RequestDoer
+
+// WithInterceptors adds Interceptors to all outbound HTTP requests made by the SDK, including retries. The first
+// interceptor is the outermost one, i.e. it sees each request first and each response last. Interceptors wrap the
+// RequestDoer set with WithRequestDoer, whichever option comes first.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *options) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// ChainInterceptors Wraps a RequestDoer with Interceptors. The first interceptor is the outermost one.
+func ChainInterceptors(doer RequestDoer, interceptors ...Interceptor) RequestDoer {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		doer = interceptors[i](doer)
+	}
+	return doer
+}
+
+// DefaultMaxResponseBodySize is the maximum size of a response body from a counterparty VASP, unless
+// WithMaxResponseBodySize is set.
+const DefaultMaxResponseBodySize = 1024 * 1024
+
+// WithMaxResponseBodySize caps the size of response bodies read from counterparty VASPs, after decompression, so that
+// a hostile counterparty cannot make a VASP read unbounded data, e.g. with an endless chunked body or a gzip bomb.
+// Larger responses are rejected with a ResponseTooLargeError. Defaults to DefaultMaxResponseBodySize.
+func WithMaxResponseBodySize(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxResponseBodySize = maxBytes
+	}
+}
+
+// ResponseTooLargeError is returned when the response body from a counterparty VASP exceeds the size set with
+// WithMaxResponseBodySize.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response from VASP exceeds the limit of %d bytes", e.Limit)
+}
+
+// InvalidResponseError is returned when a counterparty VASP responds with a non-200 status code.
+type InvalidResponseError struct {
+	StatusCode int
+	Body       []byte
+	// CorrelationId is the correlation ID sent with the request, if any, to look the failure up in the counterparty's
+	// logs.
+	CorrelationId string
+}
+
+func (e InvalidResponseError) Error() string {
+	if e.CorrelationId != "" {
+		return fmt.Sprintf("invalid response from VASP: status %d (correlation ID %s)", e.StatusCode, e.CorrelationId)
+	}
+	return fmt.Sprintf("invalid response from VASP: status %d", e.StatusCode)
+}
+
+// ErrCounterpartyMalformedResponse is matched with errors.Is by every CounterpartyMalformedResponseError.
+var ErrCounterpartyMalformedResponse = errors.New("malformed response from counterparty VASP")
+
+// maxResponseSnippetLength is the maximum length of the body snippet in a CounterpartyMalformedResponseError.
+const maxResponseSnippetLength = 256
+
+// CounterpartyMalformedResponseError is returned when a counterparty VASP responds with a 200 status code but a body
+// which is not JSON, e.g. an HTML error page from a CDN or a captive portal, instead of an opaque JSON syntax error.
+type CounterpartyMalformedResponseError struct {
+	StatusCode  int
+	ContentType string
+	// BodySnippet is the start of the response body, truncated to 256 bytes.
+	BodySnippet string
+}
+
+func (e CounterpartyMalformedResponseError) Error() string {
+	return fmt.Sprintf("malformed response from VASP: status %d, content type %q, body %q", e.StatusCode,
+		e.ContentType, e.BodySnippet)
+}
+
+func (e CounterpartyMalformedResponseError) Is(target error) bool {
+	return target == ErrCounterpartyMalformedResponse
+}
+
+// checkJsonResponse returns a CounterpartyMalformedResponseError if a response body is not JSON, judging by its
+// content type and its first non-whitespace character.
+func checkJsonResponse(statusCode int, contentType string, body []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmedBody := bytes.TrimSpace(body)
+	isHtml := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !isHtml && len(trimmedBody) > 0 && (trimmedBody[0] == '{' || trimmedBody[0] == '[') {
+		return nil
+	}
+	return CounterpartyMalformedResponseError{
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		BodySnippet: truncateSnippet(trimmedBody),
+	}
+}
+
+// truncateSnippet truncates a response body to maxResponseSnippetLength bytes without splitting a UTF-8 character.
+func truncateSnippet(body []byte) string {
+	if len(body) <= maxResponseSnippetLength {
+		return string(body)
+	}
+	end := maxResponseSnippetLength
+	for end > 0 && !utf8.RuneStart(body[end]) {
+		end--
+	}
+	return string(body[:end])
+}
+
+// outboundRequest is a request to a counterparty VASP.
+type outboundRequest struct {
+	method string
+	url    string
+	body   []byte
+	// idempotent indicates that the request can safely be retried.
+	idempotent bool
+	// expectsJson indicates that the response body is parsed as JSON, so that non-JSON bodies are rejected with a
+	// CounterpartyMalformedResponseError.
+	expectsJson bool
+	// resign regenerates the request with a fresh signature. Nil if the request is not signed.
+	resign func(signer UmaSigner, clock Clock) (*outboundRequest, error)
+	// vaspDomain, if set, is the domain of the VASP the request is for. Responses served from another registrable
+	// domain after a redirect are rejected, so that a VASP cannot answer on behalf of another one.
+	vaspDomain string
+}
+
+// sendRequest sends a request to a counterparty VASP and returns the response body if the response status is 200.
+// Transient failures are retried if a RetryPolicy is configured.
+func (o *options) sendRequest(ctx context.Context, request outboundRequest) (responseBodyBytes []byte, err error) {
+	ctx, span := o.startSpan(ctx, "uma.SendRequest",
+		attribute.String("http.request.method", request.method), attribute.String("url.full", redactQuery(request.url)))
+	defer func() { endSpan(span, err) }()
+	if err = o.domainPolicy.CheckUrl(ctx, request.url); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		var statusCode int
+		var contentType string
+		statusCode, contentType, responseBodyBytes, err = o.sendRequestOnce(ctx, request)
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode), attribute.Int("uma.attempt", attempt))
+		if err == nil && statusCode == http.StatusOK {
+			if request.expectsJson {
+				if err = checkJsonResponse(statusCode, contentType, responseBodyBytes); err != nil {
+					return nil, err
+				}
+			}
+			return responseBodyBytes, nil
+		}
+		retryable := o.retryPolicy != nil && o.retryPolicy.shouldRetry(attempt, request.idempotent, statusCode, err)
+		if err == nil {
+			err = InvalidResponseError{
+				StatusCode:    statusCode,
+				Body:          responseBodyBytes,
+				CorrelationId: o.correlationIdFor(ctx),
+			}
+		}
+		if !retryable {
+			return nil, err
+		}
+		o.log(ctx, slog.LevelWarn, LogEventRequestRetried,
+			slog.String("url", redactQuery(request.url)), slog.Int("attempt", attempt), slog.String("error", err.Error()))
+		if sleepErr := sleepWithContext(ctx, o.clock, o.retryPolicy.backoff(attempt)); sleepErr != nil {
+			return nil, err
+		}
+		if o.retryPolicy.ResignWith != nil && request.resign != nil {
+			resignedRequest, resignErr := request.resign(o.retryPolicy.ResignWith, o.clock)
+			if resignErr != nil {
+				err = resignErr
+				return nil, err
+			}
+			request = *resignedRequest
+		}
+	}
+}
+
+func (o *options) sendRequestOnce(ctx context.Context, request outboundRequest) (int, string, []byte, error) {
+	var bodyReader io.Reader
+	if request.body != nil {
+		bodyReader = bytes.NewReader(request.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, request.method, request.url, bodyReader)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	// Setting Accept-Encoding disables the transparent decompression of http.Transport, so that gzip is handled the
+	// same way for any RequestDoer, and the size limit applies to the decompressed body.
+	req.Header.Set("Accept-Encoding", "gzip")
+	if request.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if correlationId := o.correlationIdFor(ctx); isValidCorrelationId(correlationId) {
+		req.Header.Set(CorrelationIdHeader, correlationId)
+	}
+	resp, err := o.requestDoer.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+	// RequestDoers other than *http.Client may have followed redirects without checking the DomainPolicy.
+	if resp.Request != nil && resp.Request.URL != nil && resp.Request.URL.String() != request.url {
+		if err = o.domainPolicy.CheckUrl(ctx, resp.Request.URL.String()); err != nil {
+			return 0, "", nil, err
+		}
+		if request.vaspDomain != "" && !utils.IsSameRegistrableDomain(resp.Request.URL.Host, request.vaspDomain) {
+			return 0, "", nil, fmt.Errorf("request to %s was redirected to %s, which is not on its registrable domain",
+				request.vaspDomain, resp.Request.URL.Host)
+		}
+	}
+
+	responseBodyBytes, err := o.readResponseBody(resp)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), responseBodyBytes, nil
+}
+
+// readResponseBody reads a response body, decompressing it if it is gzip-encoded, up to the maximum response size.
+func (o *options) readResponseBody(resp *http.Response) ([]byte, error) {
+	limit := o.maxResponseBodySize
+	if limit <= 0 {
+		limit = DefaultMaxResponseBodySize
+	}
+	body := resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip response body: %w", err)
+		}
+		defer func() {
+			_ = gzipReader.Close()
+		}()
+		body = gzipReader
+	case "":
+		if resp.ContentLength > limit {
+			return nil, ResponseTooLargeError{Limit: limit}
+		}
+	}
+	responseBodyBytes, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(responseBodyBytes)) > limit {
+		return nil, ResponseTooLargeError{Limit: limit}
+	}
+	return responseBodyBytes, nil
+}
+
+// SendLnurlpRequest Sends a signed lnurlp request to the receiving VASP and parses the response.
+//
+// If the receiving VASP does not support the requested UMA version, an UnsupportedVersionError is returned with the
+// major versions it does support, so the request can be re-signed at a compatible version.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	request: the lnurlp request to send. For UMA, this should be signed with SignLnurlpRequest.
+//	opts: optional settings such as WithRequestDoer and WithVaspDirectory.
+func SendLnurlpRequest(ctx context.Context, request protocol.LnurlpRequest, opts ...Option) (*protocol.LnurlpResponse, error) {
+	o := newOptions(opts)
+	ctx, err := o.withVaspRecord(ctx, domainOfIdentifier(request.ReceiverAddress))
+	if err != nil {
+		return nil, err
+	}
+	if err = o.complianceProvider.PreScreenPayee(ctx, request.ReceiverAddress); err != nil {
+		return nil, err
+	}
+	outbound, err := newLnurlpOutboundRequest(request, o.domainPolicy)
+	if err != nil {
+		return nil, err
+	}
+	counterparty := slog.String("counterparty", domainOfIdentifier(request.ReceiverAddress))
+	o.log(ctx, slog.LevelInfo, LogEventLnurlpSent, counterparty, slog.String("receiver", request.ReceiverAddress))
+	event := &LnurlpSentEvent{EventMetadata: o.eventMetadata(), ReceiverAddress: request.ReceiverAddress}
+	if request.UmaVersion != nil {
+		event.UmaVersion = *request.UmaVersion
+	}
+	o.emitEvent(ctx, event)
+	responseBodyBytes, err := o.sendRequest(ctx, *outbound)
+	if err != nil {
+		var invalidResponseError InvalidResponseError
+		if errors.As(err, &invalidResponseError) && invalidResponseError.StatusCode == http.StatusPreconditionFailed {
+			var unsupportedVersionError UnsupportedVersionError
+			if json.Unmarshal(invalidResponseError.Body, &unsupportedVersionError) == nil {
+				o.log(ctx, slog.LevelWarn, LogEventVersionUnsupported, counterparty,
+					slog.String("requested_version", unsupportedVersionError.UnsupportedVersion),
+					slog.Any("supported_major_versions", unsupportedVersionError.SupportedMajorVersions))
+				o.recordVersionDowngrade(ctx, unsupportedVersionError.UnsupportedVersion)
+				o.recordVersionNegotiation(ctx, VersionNegotiationUnsupported)
+				return nil, unsupportedVersionError
+			}
+		}
+		return nil, err
+	}
+	return ParseLnurlpResponse(responseBodyBytes, opts...)
+}
+
+// redactQuery strips the query string from a URL for logging, since it may contain signatures or payer data.
+func redactQuery(rawUrl string) string {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	parsedUrl.RawQuery = ""
+	return parsedUrl.String()
+}
+
+func newLnurlpOutboundRequest(request protocol.LnurlpRequest, policy *utils.DomainPolicy) (*outboundRequest, error) {
+	requestUrl, err := request.EncodeToUrlWithPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	outbound := outboundRequest{method: http.MethodGet, url: requestUrl.String(), idempotent: true, expectsJson: true}
+	if request.IsUmaRequest() {
+		outbound.resign = func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedRequest, err := SignLnurlpRequest(request, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newLnurlpOutboundRequest(*resignedRequest, policy)
+		}
+	}
+	return &outbound, nil
+}
+
+// SendPayRequest Sends a pay request to the callback URL from the receiving VASP's lnurlp response and parses the
+// response. UMA requests are sent as a JSON POST body, while non-UMA LNURL requests are sent as GET query parameters.
+// If the receiving VASP holds the pay request for review, a *PayReqPendingError is returned.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	callback: the callback URL from the lnurlp response.
+//	request: the pay request to send.
+//	opts: optional settings such as WithRequestDoer.
+func SendPayRequest(ctx context.Context, callback string, request *protocol.PayRequest, opts ...Option) (*protocol.PayReqResponse, error) {
+	o := newOptions(opts)
+	outbound, err := newPayReqOutboundRequest(callback, *request)
+	if err != nil {
+		return nil, err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPayReqSent, slog.String("url", redactQuery(callback)))
+	start := time.Now()
+	responseBodyBytes, err := o.sendRequest(ctx, *outbound)
+	o.recordPayReqDuration(ctx, start, err)
+	if err != nil {
+		return nil, payReqPendingError(err)
+	}
+	return o.parsePayReqResponse(ctx, responseBodyBytes, opts)
+}
+
+// parsePayReqResponse parses a pay request response, validates its quote and screens it with the ComplianceProvider.
+func (o *options) parsePayReqResponse(ctx context.Context, responseBodyBytes []byte, opts []Option) (*protocol.PayReqResponse, error) {
+	response, err := ParsePayReqResponse(responseBodyBytes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateQuoteExpiry(response, opts...); err != nil {
+		return nil, err
+	}
+	if err = o.screenPayReqResponse(ctx, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (o *options) screenPayReqResponse(ctx context.Context, response *protocol.PayReqResponse) error {
+	complianceData, err := response.PayeeData.Compliance()
+	if err != nil {
+		return err
+	}
+	payeeIdentifier := ""
+	if response.PayeeData != nil {
+		payeeIdentifier, _ = (*response.PayeeData)[protocol.CounterPartyDataFieldIdentifier.String()].(string)
+	}
+	registration := PaymentRegistration{
+		Direction:              PaymentDirectionOutgoing,
+		CounterpartyIdentifier: payeeIdentifier,
+		EncodedInvoice:         response.EncodedInvoice,
+	}
+	if complianceData != nil {
+		err = o.complianceProvider.ScreenUtxos(ctx, UtxoScreeningRequest{
+			Direction:              PaymentDirectionOutgoing,
+			CounterpartyIdentifier: payeeIdentifier,
+			Utxos:                  complianceData.Utxos,
+			NodePubKey:             complianceData.NodePubKey,
+		})
+		if err != nil {
+			return err
+		}
+		registration.CounterpartyUtxos = complianceData.Utxos
+		registration.CounterpartyNodePubKey = complianceData.NodePubKey
+	}
+	if err = o.complianceProvider.RegisterPayment(ctx, registration); err != nil {
+		return err
+	}
+	o.emitEvent(ctx, &PaymentRegisteredEvent{EventMetadata: o.eventMetadata(), Registration: registration})
+	return nil
+}
+
+func newPayReqOutboundRequest(callback string, request protocol.PayRequest) (*outboundRequest, error) {
+	if request.IsUmaRequest() {
+		body, err := request.Encode()
+		if err != nil {
+			return nil, err
+		}
+		return &outboundRequest{
+			method: http.MethodPost,
+			url:    callback,
+			body:   body,
+			// With an idempotency key, the receiver returns the original response to a duplicate request.
+			idempotent:  request.IdempotencyKey != nil,
+			expectsJson: true,
+			resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+				resignedRequest, err := SignPayRequest(request, signer, WithClock(clock))
+				if err != nil {
+					return nil, err
+				}
+				return newPayReqOutboundRequest(callback, *resignedRequest)
+			},
+		}, nil
+	}
+	callbackUrl, err := url.Parse(callback)
+	if err != nil {
+		return nil, err
+	}
+	params, err := request.EncodeAsUrlParams()
+	if err != nil {
+		return nil, err
+	}
+	query := callbackUrl.Query()
+	for key, values := range *params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	callbackUrl.RawQuery = query.Encode()
+	return &outboundRequest{method: http.MethodGet, url: callbackUrl.String(), expectsJson: true}, nil
+}
@@ -0,0 +1,447 @@
+package uma
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HttpSignatureLabel is the label of the HTTP message signatures created by SignHttpRequest.
+	HttpSignatureLabel = "uma"
+	// HttpSignatureAlgorithm is the alg parameter of the HTTP message signatures created by SignHttpRequest: ECDSA over
+	// secp256k1 with SHA-256, encoded as the 32-byte big-endian r and s values as for the ECDSA algorithms of RFC 9421.
+	HttpSignatureAlgorithm = "ecdsa-secp256k1-sha256"
+
+	// maxHttpSignatureAge is how old the created parameter of an HTTP message signature may be.
+	maxHttpSignatureAge = 5 * time.Minute
+	// maxHttpSignatureClockSkew is how far in the future the created parameter of an HTTP message signature may be.
+	maxHttpSignatureClockSkew = time.Minute
+	// maxHttpSignedBodyBytes is the largest request body read by HttpMessageSignatureHandler.
+	maxHttpSignedBodyBytes = 1024 * 1024
+)
+
+// SignHttpRequest Signs an entire HTTP request with an HTTP message signature (RFC 9421), in addition to the UMA
+// signatures in the body of protocol messages, for VASPs which require transport-level request authentication. The
+// signature covers the method, the target URI and, for requests with a body, the Content-Digest (RFC 9530) and
+// Content-Type headers. It is added in the Signature-Input and Signature headers with the label HttpSignatureLabel,
+// and identifies the signing VASP by its domain in the keyid parameter, so that the receiver can verify it with the
+// public keys published at that domain.
+//
+// To sign all outbound requests of the SDK, use HttpMessageSigningInterceptor.
+//
+// Args:
+//
+//	req: the request to sign. Its body is read and replaced.
+//	vaspDomain: the domain of the signing VASP.
+//	signer: the UmaSigner of the signing VASP.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignHttpRequest(req *http.Request, vaspDomain string, signer UmaSigner, opts ...Option) error {
+	if signer == nil {
+		return errors.New("missing signer")
+	}
+	if vaspDomain == "" {
+		return errors.New("missing vaspDomain")
+	}
+	o := newOptions(opts)
+	components := []string{"@method", "@target-uri"}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Header.Set("Content-Digest", contentDigest(body))
+		components = append(components, "content-digest")
+		if req.Header.Get("Content-Type") != "" {
+			components = append(components, "content-type")
+		}
+	}
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return err
+	}
+	quotedComponents := make([]string, len(components))
+	for i, component := range components {
+		quotedComponents[i] = strconv.Quote(component)
+	}
+	signatureParams := fmt.Sprintf("(%s);created=%d;keyid=%s;nonce=%s;alg=%s",
+		strings.Join(quotedComponents, " "), o.clock.Now().Unix(), strconv.Quote(vaspDomain), strconv.Quote(*nonce),
+		strconv.Quote(HttpSignatureAlgorithm))
+	targetUri := req.URL.Scheme + "://" + req.URL.Host + req.URL.RequestURI()
+	signatureBase, err := httpSignatureBase(req, targetUri, components, signatureParams)
+	if err != nil {
+		return err
+	}
+	derSignature, err := signer.Sign(signatureBase)
+	if err != nil {
+		return err
+	}
+	rawSignature, err := derToRawSignature(derSignature)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature-Input", HttpSignatureLabel+"="+signatureParams)
+	req.Header.Set("Signature", HttpSignatureLabel+"=:"+base64.StdEncoding.EncodeToString(rawSignature)+":")
+	return nil
+}
+
+// HttpMessageSigningInterceptor Creates an Interceptor which signs each outbound request with SignHttpRequest,
+// including each retry. Pass it to WithInterceptors.
+//
+// Args:
+//
+//	vaspDomain: the domain of the signing VASP.
+//	signer: the UmaSigner of the signing VASP.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func HttpMessageSigningInterceptor(vaspDomain string, signer UmaSigner, opts ...Option) Interceptor {
+	return func(next RequestDoer) RequestDoer {
+		return RequestDoerFunc(func(req *http.Request) (*http.Response, error) {
+			if err := SignHttpRequest(req, vaspDomain, signer, opts...); err != nil {
+				return nil, fmt.Errorf("failed to sign request: %w", err)
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// VerifyHttpRequestSignature Verifies the HTTP message signature of an incoming request, created with
+// SignHttpRequest, against the public keys of the VASP in its keyid parameter. The target URI is reconstructed from
+// the Host header, with the scheme used for that domain, so requests must reach the server with the host name the
+// sender used. Returns the domain of the VASP which signed the request.
+//
+// Args:
+//
+//	r: the incoming request. Its body is read and replaced.
+//	publicKeyCache: the cache used when fetching the public keys of the signing VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func VerifyHttpRequestSignature(
+	r *http.Request,
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	opts ...Option,
+) (string, error) {
+	o := newOptions(opts)
+	params, err := parseHttpSignatureInput(r.Header.Get("Signature-Input"), HttpSignatureLabel)
+	if err != nil {
+		return "", err
+	}
+	if params.alg != "" && params.alg != HttpSignatureAlgorithm {
+		return "", fmt.Errorf("unsupported signature algorithm %q", params.alg)
+	}
+	if params.keyId == "" || params.nonce == "" {
+		return "", errors.New("signature is missing keyid or nonce")
+	}
+	created := time.Unix(params.created, 0)
+	now := o.clock.Now()
+	if created.Before(now.Add(-maxHttpSignatureAge)) || created.After(now.Add(maxHttpSignatureClockSkew)) {
+		return "", errors.New("signature is expired or not yet valid")
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxHttpSignedBodyBytes+1))
+		_ = r.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if len(body) > maxHttpSignedBodyBytes {
+			return "", errors.New("request body is too large")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !slices.Contains(params.components, "@method") || !slices.Contains(params.components, "@target-uri") {
+		return "", errors.New("signature must cover @method and @target-uri")
+	}
+	if len(body) > 0 || r.Header.Get("Content-Digest") != "" {
+		if !slices.Contains(params.components, "content-digest") {
+			return "", errors.New("signature must cover content-digest")
+		}
+		if r.Header.Get("Content-Digest") != contentDigest(body) {
+			return "", errors.New("content digest does not match the body")
+		}
+	}
+
+	rawSignature, err := parseHttpSignature(r.Header.Get("Signature"), HttpSignatureLabel)
+	if err != nil {
+		return "", err
+	}
+	derSignature, err := rawToDerSignature(rawSignature)
+	if err != nil {
+		return "", err
+	}
+	targetUri := o.domainPolicy.Scheme(r.Host) + "://" + r.Host + r.URL.RequestURI()
+	signatureBase, err := httpSignatureBase(r, targetUri, params.components, params.raw)
+	if err != nil {
+		return "", err
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(r.Context(), params.keyId, publicKeyCache, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch public keys: %w", err)
+	}
+	err = o.verifySignedMessage(
+		MessageTypeHttpRequest,
+		params.keyId,
+		nonceCache,
+		params.nonce,
+		created,
+		signatureBase,
+		hex.EncodeToString(derSignature),
+		*pubKeyResponse,
+	)
+	if err != nil {
+		return "", err
+	}
+	return params.keyId, nil
+}
+
+// httpSignatureContextKey is the context key of the domain of the VASP which signed a request.
+type httpSignatureContextKey struct{}
+
+// HttpSignatureVaspDomain returns the domain of the VASP which signed the request verified by
+// HttpMessageSignatureHandler, or an empty string if the context is not from such a request.
+func HttpSignatureVaspDomain(ctx context.Context) string {
+	vaspDomain, _ := ctx.Value(httpSignatureContextKey{}).(string)
+	return vaspDomain
+}
+
+// HttpMessageSignatureHandler is an http.Handler which only passes requests with a valid HTTP message signature on
+// to the next handler, and responds to others with 401 Unauthorized. The next handler can read the domain of the
+// VASP which signed the request with HttpSignatureVaspDomain.
+type HttpMessageSignatureHandler struct {
+	next           http.Handler
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	opts           []Option
+}
+
+// NewHttpMessageSignatureHandler Creates an HttpMessageSignatureHandler.
+//
+// Args:
+//
+//	next: the handler of requests with a valid signature, e.g. a PayRequestHandler.
+//	publicKeyCache: the cache used when fetching the public keys of the signing VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks. It must not be the same instance as the one
+//		used for the UMA signatures of the next handler, since the nonces of both signatures may collide.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewHttpMessageSignatureHandler(
+	next http.Handler,
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	opts ...Option,
+) *HttpMessageSignatureHandler {
+	return &HttpMessageSignatureHandler{
+		next:           next,
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		opts:           opts,
+	}
+}
+
+func (h *HttpMessageSignatureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vaspDomain, err := VerifyHttpRequestSignature(r, h.publicKeyCache, h.nonceCache, h.opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusUnauthorized, err)
+		return
+	}
+	h.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpSignatureContextKey{}, vaspDomain)))
+}
+
+// httpSignatureParams are the parameters of an HTTP message signature, parsed from its Signature-Input member.
+type httpSignatureParams struct {
+	// raw is the serialized signature parameters, which are the last line of the signature base.
+	raw        string
+	components []string
+	created    int64
+	keyId      string
+	nonce      string
+	alg        string
+}
+
+// httpSignatureBase returns the signature base of a request, as in section 2.5 of RFC 9421.
+func httpSignatureBase(
+	r *http.Request,
+	targetUri string,
+	components []string,
+	signatureParams string,
+) ([]byte, error) {
+	var base strings.Builder
+	for _, component := range components {
+		var value string
+		switch component {
+		case "@method":
+			value = r.Method
+		case "@target-uri":
+			value = targetUri
+		default:
+			if strings.HasPrefix(component, "@") {
+				return nil, fmt.Errorf("unsupported derived component %q", component)
+			}
+			values := r.Header.Values(component)
+			if len(values) == 0 {
+				return nil, fmt.Errorf("missing covered header %q", component)
+			}
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			value = strings.Join(values, ", ")
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid value of component %q", component)
+		}
+		base.WriteString(strconv.Quote(component) + ": " + value + "\n")
+	}
+	base.WriteString(`"@signature-params": ` + signatureParams)
+	return []byte(base.String()), nil
+}
+
+// parseHttpSignatureInput parses the member of a Signature-Input header with the given label.
+func parseHttpSignatureInput(header string, label string) (*httpSignatureParams, error) {
+	member, err := httpSignatureDictionaryMember(header, label)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(member, "(") {
+		return nil, errors.New("invalid Signature-Input header")
+	}
+	end := strings.Index(member, ")")
+	if end < 0 {
+		return nil, errors.New("invalid Signature-Input header")
+	}
+	params := &httpSignatureParams{raw: member}
+	for _, component := range strings.Fields(member[1:end]) {
+		unquoted, err := strconv.Unquote(component)
+		if err != nil {
+			return nil, fmt.Errorf("invalid covered component %s", component)
+		}
+		params.components = append(params.components, strings.ToLower(unquoted))
+	}
+	for _, param := range splitOutsideStrings(member[end+1:], ';')[1:] {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid signature parameter %q", param)
+		}
+		if name == "created" {
+			params.created, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid created parameter: %w", err)
+			}
+			continue
+		}
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			// Ignore parameters which are not strings, such as expires.
+			continue
+		}
+		switch name {
+		case "keyid":
+			params.keyId = unquoted
+		case "nonce":
+			params.nonce = unquoted
+		case "alg":
+			params.alg = unquoted
+		}
+	}
+	if params.created == 0 {
+		return nil, errors.New("signature is missing the created parameter")
+	}
+	return params, nil
+}
+
+// parseHttpSignature parses the member of a Signature header with the given label.
+func parseHttpSignature(header string, label string) ([]byte, error) {
+	member, err := httpSignatureDictionaryMember(header, label)
+	if err != nil {
+		return nil, err
+	}
+	if len(member) < 2 || member[0] != ':' || member[len(member)-1] != ':' {
+		return nil, errors.New("invalid Signature header")
+	}
+	return base64.StdEncoding.DecodeString(member[1 : len(member)-1])
+}
+
+// httpSignatureDictionaryMember returns the value of the member of a structured field dictionary with the given
+// label.
+func httpSignatureDictionaryMember(header string, label string) (string, error) {
+	for _, member := range splitOutsideStrings(header, ',') {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if ok && name == label {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("missing HTTP message signature %q", label)
+}
+
+// splitOutsideStrings splits a structured field around a separator, ignoring separators in quoted strings.
+func splitOutsideStrings(field string, separator byte) []string {
+	var parts []string
+	inString := false
+	start := 0
+	for i := 0; i < len(field); i++ {
+		switch {
+		case field[i] == '\\' && inString:
+			i++
+		case field[i] == '"':
+			inString = !inString
+		case field[i] == separator && !inString:
+			parts = append(parts, field[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, field[start:])
+}
+
+// contentDigest returns the Content-Digest header of a body, as in RFC 9530.
+func contentDigest(body []byte) string {
+	digest := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(digest[:]) + ":"
+}
+
+// ecdsaSignature is the ASN.1 structure of a DER-encoded ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// derToRawSignature converts a DER-encoded secp256k1 signature to its 32-byte r and s values.
+func derToRawSignature(derSignature []byte) ([]byte, error) {
+	var signature ecdsaSignature
+	rest, err := asn1.Unmarshal(derSignature, &signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 || signature.R.BitLen() > 256 || signature.S.BitLen() > 256 {
+		return nil, errors.New("invalid DER signature")
+	}
+	rawSignature := make([]byte, 64)
+	signature.R.FillBytes(rawSignature[:32])
+	signature.S.FillBytes(rawSignature[32:])
+	return rawSignature, nil
+}
+
+// rawToDerSignature converts the 32-byte r and s values of a secp256k1 signature to its DER encoding.
+func rawToDerSignature(rawSignature []byte) ([]byte, error) {
+	if len(rawSignature) != 64 {
+		return nil, errors.New("invalid signature length")
+	}
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(rawSignature[:32]),
+		S: new(big.Int).SetBytes(rawSignature[32:]),
+	})
+}
@@ -0,0 +1,201 @@
+package uma
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrIdempotentRequestInProgress is returned by ProcessIdempotently when a request with the same idempotency key is
+// still being processed. The sender should retry later to get the original response.
+var ErrIdempotentRequestInProgress = errors.New("a request with this idempotency key is still being processed")
+
+// ErrIdempotencyKeyReused is returned to a request whose idempotency key was already used by the same sender for a
+// different request. Senders should generate a new key for each payment attempt, and only reuse it for retries.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// GenerateIdempotencyKey Generates a random idempotency key for a PayRequest or PostTransactionCallback. Keep the key
+// when retrying the same payment so that the receiver can detect duplicates.
+func GenerateIdempotencyKey() string {
+	return uuid.NewString()
+}
+
+// IdempotencyStore records the responses to requests carrying an idempotency key, so that a receiving VASP returns
+// the original response to duplicated HTTP deliveries instead of creating duplicate invoices or double-registering
+// compliance events. See ProcessIdempotently.
+//
+// Implementations of this interface should be thread-safe.
+type IdempotencyStore interface {
+	// Claim atomically claims the key for processing and returns true if it was not claimed before. Otherwise, it
+	// returns false and the stored response, which is nil if the original request has not completed yet.
+	Claim(ctx context.Context, key string) (claimed bool, response []byte, err error)
+	// Complete stores the response for a claimed key.
+	Complete(ctx context.Context, key string, response []byte) error
+	// Release removes the claim on a key whose request failed, so that it can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// ProcessIdempotently Runs process at most once per idempotency key and returns its response. Duplicate requests get
+// the stored response of the original request, or ErrIdempotentRequestInProgress if it has not completed yet. If
+// process fails, the key is released so that the request can be retried. Requests without a key are always processed.
+//
+// Args:
+//
+//	ctx: the context passed to the store.
+//	store: the IdempotencyStore of the receiving VASP.
+//	idempotencyKey: the idempotency key of the request, e.g. PayRequest.IdempotencyKey. May be nil.
+//	process: handles the request and returns the encoded response.
+func ProcessIdempotently(
+	ctx context.Context,
+	store IdempotencyStore,
+	idempotencyKey *string,
+	process func() ([]byte, error),
+) ([]byte, error) {
+	if idempotencyKey == nil || *idempotencyKey == "" {
+		return process()
+	}
+	claimed, storedResponse, err := store.Claim(ctx, *idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if storedResponse == nil {
+			return nil, ErrIdempotentRequestInProgress
+		}
+		return storedResponse, nil
+	}
+	response, err := process()
+	if err != nil {
+		return nil, errors.Join(err, store.Release(ctx, *idempotencyKey))
+	}
+	if err = store.Complete(ctx, *idempotencyKey, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// senderIdempotentResponse is the response stored by processIdempotentlyForSender, with the hash of the request it
+// responds to.
+type senderIdempotentResponse struct {
+	RequestHash string          `json:"requestHash"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// processIdempotentlyForSender is ProcessIdempotently for the requests of a sender VASP whose signature was verified.
+// Keys are scoped by the domain of the sender, so that a VASP cannot get or block the responses to the requests of
+// another VASP, and the stored response is only returned for a request with the same hash as the original one. A
+// request reusing the key of a different request gets ErrIdempotencyKeyReused. The response must be JSON.
+func processIdempotentlyForSender(
+	ctx context.Context,
+	store IdempotencyStore,
+	senderDomain string,
+	idempotencyKey *string,
+	requestHash string,
+	process func() ([]byte, error),
+) ([]byte, error) {
+	if idempotencyKey == nil || *idempotencyKey == "" {
+		return process()
+	}
+	scopedKey := senderDomain + "|" + *idempotencyKey
+	storedResponse, err := ProcessIdempotently(ctx, store, &scopedKey, func() ([]byte, error) {
+		response, err := process()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(senderIdempotentResponse{RequestHash: requestHash, Response: response})
+	})
+	if err != nil {
+		return nil, err
+	}
+	var stored senderIdempotentResponse
+	if err = json.Unmarshal(storedResponse, &stored); err != nil {
+		return nil, fmt.Errorf("invalid stored response: %w", err)
+	}
+	if stored.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return stored.Response, nil
+}
+
+// hashRequest returns the hex-encoded SHA-256 hash of the JSON encoding of a request.
+func hashRequest(request interface{}) (string, error) {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// InMemoryIdempotencyStore is an in-memory implementation of IdempotencyStore.
+// It is not recommended to use this in production, as it will not persist across restarts or be shared between
+// instances. You likely want to implement your own IdempotencyStore backed by a database.
+type InMemoryIdempotencyStore struct {
+	mutex   sync.Mutex
+	entries map[string]idempotencyEntry
+	clock   Clock
+}
+
+type idempotencyEntry struct {
+	claimedAt time.Time
+	response  []byte
+}
+
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{entries: make(map[string]idempotencyEntry), clock: SystemClock}
+}
+
+// SetClock sets the Clock used to record when keys are claimed, see PurgeKeysOlderThan. Defaults to SystemClock.
+func (s *InMemoryIdempotencyStore) SetClock(clock Clock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clockOrSystem(clock)
+}
+
+func (s *InMemoryIdempotencyStore) Claim(_ context.Context, key string) (bool, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		return false, entry.response, nil
+	}
+	s.entries[key] = idempotencyEntry{claimedAt: s.clock.Now()}
+	return true, nil, nil
+}
+
+func (s *InMemoryIdempotencyStore) Complete(_ context.Context, key string, response []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return errors.New("idempotency key was not claimed")
+	}
+	entry.response = response
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *InMemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// PurgeKeysOlderThan removes all keys claimed before the given time. This allows the store to be pruned periodically,
+// for example once senders are no longer expected to retry.
+func (s *InMemoryIdempotencyStore) PurgeKeysOlderThan(timestamp time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, entry := range s.entries {
+		if entry.claimedAt.Before(timestamp) {
+			delete(s.entries, key)
+		}
+	}
+}
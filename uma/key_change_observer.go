@@ -0,0 +1,93 @@
+package uma
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// KeyChange describes a change of the public keys of a counterparty VASP, detected when its keys were fetched again.
+type KeyChange struct {
+	// VaspDomain is the domain of the VASP.
+	VaspDomain string
+	// Previous are the keys which were cached before.
+	Previous *protocol.PubKeyResponse
+	// Current are the newly fetched keys.
+	Current *protocol.PubKeyResponse
+	// Url is the URL the new keys were fetched from.
+	Url string
+	// FetchedAt is when the new keys were fetched.
+	FetchedAt time.Time
+}
+
+// KeyChangeObserver is called when the newly fetched public keys of a VASP differ from the keys cached before, for
+// example to alert a security team on unexpected key changes, as in a certificate transparency audit log.
+//
+// It is called synchronously before the new keys are cached, so it should not block.
+type KeyChangeObserver func(ctx context.Context, change KeyChange)
+
+// WithKeyChangeObserver sets a KeyChangeObserver called by FetchPublicKeyForVasp when the public keys it fetches for a
+// VASP differ from the previously cached ones. Changes are only detected if the PublicKeyCache implements
+// LastKnownPublicKeyCache, as InMemoryPublicKeyCache does, since expired keys are needed for the comparison. A change
+// is also logged as LogEventPubKeyChanged.
+func WithKeyChangeObserver(observer KeyChangeObserver) Option {
+	return func(o *options) {
+		o.keyChangeObserver = observer
+	}
+}
+
+func (o *options) observeKeyChange(
+	ctx context.Context,
+	vaspDomain string,
+	cache PublicKeyCache,
+	current *protocol.PubKeyResponse,
+) {
+	lastKnownCache, ok := cache.(LastKnownPublicKeyCache)
+	if !ok || (o.keyChangeObserver == nil && o.logger == nil) {
+		return
+	}
+	previous := lastKnownCache.LastKnownPublicKeyForVasp(vaspDomain)
+	if previous == nil || samePubKeys(previous, current) {
+		return
+	}
+	o.log(ctx, slog.LevelWarn, LogEventPubKeyChanged, slog.String("counterparty", vaspDomain))
+	if o.keyChangeObserver != nil {
+		o.keyChangeObserver(ctx, KeyChange{
+			VaspDomain: vaspDomain,
+			Previous:   previous,
+			Current:    current,
+			Url:        o.publicKeyUrl(vaspDomain),
+			FetchedAt:  o.clock.Now(),
+		})
+	}
+}
+
+// samePubKeys returns whether two public key responses advertise the same signing and encryption keys, regardless of
+// their expiration.
+func samePubKeys(a *protocol.PubKeyResponse, b *protocol.PubKeyResponse) bool {
+	signingKeyA, errA := a.SigningPubKey()
+	signingKeyB, errB := b.SigningPubKey()
+	if (errA == nil) != (errB == nil) || (errA == nil && !samePubKey(signingKeyA, signingKeyB)) {
+		return false
+	}
+	if a.SigningPubKeyAlgorithm() != b.SigningPubKeyAlgorithm() {
+		return false
+	}
+	encryptionKeyA, errA := a.EncryptionPubKey()
+	encryptionKeyB, errB := b.EncryptionPubKey()
+	if (errA == nil) != (errB == nil) || (errA == nil && !samePubKey(encryptionKeyA, encryptionKeyB)) {
+		return false
+	}
+	if len(a.SigningKeys) != len(b.SigningKeys) {
+		return false
+	}
+	for i := range a.SigningKeys {
+		if a.SigningKeys[i].KeyId != b.SigningKeys[i].KeyId || a.SigningKeys[i].PubKeyHex != b.SigningKeys[i].PubKeyHex ||
+			a.SigningKeys[i].PubKeyAlgorithm() != b.SigningKeys[i].PubKeyAlgorithm() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+// Package lightning contains implementations of the uma InvoiceCreator and UtxoProvider interfaces for common
+// Lightning node backends.
+package lightning
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+func postJson(ctx context.Context, doer uma.RequestDoer, url string, headers map[string]string, body interface{}, result interface{}) error {
+	return doJson(ctx, doer, http.MethodPost, url, headers, body, result)
+}
+
+func getJson(ctx context.Context, doer uma.RequestDoer, url string, headers map[string]string, result interface{}) error {
+	return doJson(ctx, doer, http.MethodGet, url, headers, nil, result)
+}
+
+func doJson(
+	ctx context.Context,
+	doer uma.RequestDoer,
+	method string,
+	url string,
+	headers map[string]string,
+	body interface{},
+	result interface{},
+) error {
+	var bodyReader io.Reader
+	if body != nil {
+		requestBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(requestBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if doer == nil {
+		doer = uma.DefaultRequestDoer
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return uma.InvalidResponseError{StatusCode: resp.StatusCode, Body: responseBody}
+	}
+	// Streaming endpoints return newline-delimited JSON objects, so only decode the first one.
+	return json.NewDecoder(bytes.NewReader(responseBody)).Decode(result)
+}
+
+func metadataHash(metadata string) []byte {
+	hash := sha256.Sum256([]byte(metadata))
+	return hash[:]
+}
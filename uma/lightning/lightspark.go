@@ -0,0 +1,306 @@
+package lightning
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// DefaultLightsparkApiEndpoint is the Lightspark GraphQL API endpoint used when none is configured.
+const DefaultLightsparkApiEndpoint = "https://api.lightspark.com/graphql/server/2023-09-13"
+
+const createUmaInvoiceMutation = `
+mutation CreateUmaInvoice($node_id: ID!, $amount_msats: Long!, $metadata_hash: String!, $expiry_secs: Int) {
+    create_uma_invoice(input: {
+        node_id: $node_id
+        amount_msats: $amount_msats
+        metadata_hash: $metadata_hash
+        expiry_secs: $expiry_secs
+    }) {
+        invoice {
+            data {
+                encoded_payment_request
+            }
+        }
+    }
+}`
+
+// LightsparkInvoiceCreator creates UMA invoices on a Lightspark node using the Lightspark API.
+type LightsparkInvoiceCreator struct {
+	// ClientId and ClientSecret are the Lightspark API token credentials.
+	ClientId     string
+	ClientSecret string
+	// NodeId is the ID of the Lightspark node which will receive payments.
+	NodeId string
+	// ApiEndpoint is the Lightspark GraphQL endpoint. Defaults to DefaultLightsparkApiEndpoint.
+	ApiEndpoint string
+	// DefaultExpirySecs is the invoice expiry used by CreateInvoice. Nil means the Lightspark default.
+	DefaultExpirySecs *int64
+	// RequestDoer is used to make requests to the Lightspark API. Defaults to uma.DefaultRequestDoer.
+	RequestDoer uma.RequestDoer
+}
+
+func NewLightsparkInvoiceCreator(clientId string, clientSecret string, nodeId string) *LightsparkInvoiceCreator {
+	return &LightsparkInvoiceCreator{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		NodeId:       nodeId,
+		ApiEndpoint:  DefaultLightsparkApiEndpoint,
+	}
+}
+
+func (c *LightsparkInvoiceCreator) CreateInvoice(amountMsats int64, metadata string, receiverIdentifier *string) (*string, error) {
+	return c.CreateInvoiceWithExpiry(amountMsats, metadata, receiverIdentifier, c.DefaultExpirySecs)
+}
+
+func (c *LightsparkInvoiceCreator) CreateInvoiceWithExpiry(
+	amountMsats int64,
+	metadata string,
+	_ *string,
+	expirySecs *int64,
+) (*string, error) {
+	variables := map[string]interface{}{
+		"node_id":       c.NodeId,
+		"amount_msats":  amountMsats,
+		"metadata_hash": hex.EncodeToString(metadataHash(metadata)),
+	}
+	if expirySecs != nil {
+		variables["expiry_secs"] = *expirySecs
+	}
+	var response struct {
+		CreateUmaInvoice *struct {
+			Invoice struct {
+				Data struct {
+					EncodedPaymentRequest string `json:"encoded_payment_request"`
+				} `json:"data"`
+			} `json:"invoice"`
+		} `json:"create_uma_invoice"`
+	}
+	err := callLightsparkGraphql(
+		context.Background(),
+		c.RequestDoer,
+		c.ApiEndpoint,
+		c.ClientId,
+		c.ClientSecret,
+		"CreateUmaInvoice",
+		createUmaInvoiceMutation,
+		variables,
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if response.CreateUmaInvoice == nil {
+		return nil, errors.New("lightspark API returned no invoice")
+	}
+	encodedInvoice := response.CreateUmaInvoice.Invoice.Data.EncodedPaymentRequest
+	return &encodedInvoice, nil
+}
+
+func callLightsparkGraphql(
+	ctx context.Context,
+	doer uma.RequestDoer,
+	endpoint string,
+	clientId string,
+	clientSecret string,
+	operationName string,
+	query string,
+	variables map[string]interface{},
+	data interface{},
+) error {
+	if endpoint == "" {
+		endpoint = DefaultLightsparkApiEndpoint
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(clientId + ":" + clientSecret))
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := postJson(
+		ctx,
+		doer,
+		endpoint,
+		map[string]string{"Authorization": "Basic " + credentials},
+		map[string]interface{}{
+			"operationName": operationName,
+			"query":         query,
+			"variables":     variables,
+		},
+		&response,
+	)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		messages := make([]string, len(response.Errors))
+		for i, graphqlError := range response.Errors {
+			messages[i] = graphqlError.Message
+		}
+		return errors.New("lightspark API error: " + strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(response.Data, data)
+}
+
+const nodeUtxosQuery = `
+query NodeUmaPrescreeningUtxos($node_id: ID!) {
+    entity(id: $node_id) {
+        ... on LightsparkNode {
+            uma_prescreening_utxos
+        }
+    }
+}`
+
+const paymentUtxosFragment = `
+payments {
+    uma_post_transaction_data {
+        utxo
+        amount {
+            original_value
+            original_unit
+        }
+    }
+}`
+
+const outgoingPaymentUtxosQuery = `
+query OutgoingPaymentsForPaymentHash($payment_hash: Hash32!) {
+    outgoing_payments_for_payment_hash(input: { payment_hash: $payment_hash, statuses: [SUCCESS] }) {` +
+	paymentUtxosFragment + `
+    }
+}`
+
+const incomingPaymentUtxosQuery = `
+query IncomingPaymentsForPaymentHash($payment_hash: Hash32!) {
+    incoming_payments_for_payment_hash(input: { payment_hash: $payment_hash, statuses: [SUCCESS] }) {` +
+	paymentUtxosFragment + `
+    }
+}`
+
+// LightsparkUtxoProvider fetches channel UTXOs from a Lightspark node using the Lightspark API.
+type LightsparkUtxoProvider struct {
+	// ClientId and ClientSecret are the Lightspark API token credentials.
+	ClientId     string
+	ClientSecret string
+	// NodeId is the ID of the Lightspark node.
+	NodeId string
+	// ApiEndpoint is the Lightspark GraphQL endpoint. Defaults to DefaultLightsparkApiEndpoint.
+	ApiEndpoint string
+	// RequestDoer is used to make requests to the Lightspark API. Defaults to uma.DefaultRequestDoer.
+	RequestDoer uma.RequestDoer
+}
+
+func NewLightsparkUtxoProvider(clientId string, clientSecret string, nodeId string) *LightsparkUtxoProvider {
+	return &LightsparkUtxoProvider{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		NodeId:       nodeId,
+		ApiEndpoint:  DefaultLightsparkApiEndpoint,
+	}
+}
+
+func (p *LightsparkUtxoProvider) GetChannelUtxos(ctx context.Context) ([]string, error) {
+	var response struct {
+		Entity *struct {
+			UmaPrescreeningUtxos []string `json:"uma_prescreening_utxos"`
+		} `json:"entity"`
+	}
+	err := callLightsparkGraphql(
+		ctx,
+		p.RequestDoer,
+		p.ApiEndpoint,
+		p.ClientId,
+		p.ClientSecret,
+		"NodeUmaPrescreeningUtxos",
+		nodeUtxosQuery,
+		map[string]interface{}{"node_id": p.NodeId},
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if response.Entity == nil {
+		return nil, errors.New("lightspark node not found: " + p.NodeId)
+	}
+	return response.Entity.UmaPrescreeningUtxos, nil
+}
+
+type lightsparkPostTransactionData struct {
+	Utxo   string `json:"utxo"`
+	Amount struct {
+		OriginalValue int64  `json:"original_value"`
+		OriginalUnit  string `json:"original_unit"`
+	} `json:"amount"`
+}
+
+type lightsparkPaymentsConnection struct {
+	Payments []struct {
+		UmaPostTransactionData []lightsparkPostTransactionData `json:"uma_post_transaction_data"`
+	} `json:"payments"`
+}
+
+func (p *LightsparkUtxoProvider) GetPaymentUtxos(
+	ctx context.Context,
+	paymentHash string,
+	direction uma.PaymentDirection,
+) ([]protocol.UtxoWithAmount, error) {
+	operationName, query := "OutgoingPaymentsForPaymentHash", outgoingPaymentUtxosQuery
+	if direction == uma.PaymentDirectionIncoming {
+		operationName, query = "IncomingPaymentsForPaymentHash", incomingPaymentUtxosQuery
+	}
+	var response struct {
+		OutgoingPayments *lightsparkPaymentsConnection `json:"outgoing_payments_for_payment_hash"`
+		IncomingPayments *lightsparkPaymentsConnection `json:"incoming_payments_for_payment_hash"`
+	}
+	err := callLightsparkGraphql(
+		ctx,
+		p.RequestDoer,
+		p.ApiEndpoint,
+		p.ClientId,
+		p.ClientSecret,
+		operationName,
+		query,
+		map[string]interface{}{"payment_hash": paymentHash},
+		&response,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payments := response.OutgoingPayments
+	if direction == uma.PaymentDirectionIncoming {
+		payments = response.IncomingPayments
+	}
+	if payments == nil || len(payments.Payments) == 0 {
+		return nil, errors.New("no successful payments found for payment " + paymentHash)
+	}
+	var utxos []protocol.UtxoWithAmount
+	for _, payment := range payments.Payments {
+		for _, data := range payment.UmaPostTransactionData {
+			amountMsats, err := lightsparkAmountToMsats(data.Amount.OriginalValue, data.Amount.OriginalUnit)
+			if err != nil {
+				return nil, err
+			}
+			utxos = append(utxos, protocol.UtxoWithAmount{Utxo: data.Utxo, Amount: amountMsats})
+		}
+	}
+	return utxos, nil
+}
+
+func lightsparkAmountToMsats(value int64, unit string) (int64, error) {
+	switch unit {
+	case "MILLISATOSHI":
+		return value, nil
+	case "SATOSHI":
+		return value * 1000, nil
+	case "BITCOIN":
+		return value * 100_000_000_000, nil
+	default:
+		return 0, errors.New("unsupported lightspark currency unit: " + unit)
+	}
+}
@@ -0,0 +1,43 @@
+package uma
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// FetchLnurlpForLightningAddress Fetches the lnurlp response of an ordinary lightning address (LUD-16), such as
+// carol@wallet.com, with a plain unsigned lnurlp request. The response is usually not an UMA response, and can be paid
+// with SendPayment and AllowNonUmaFallback.
+//
+// Args:
+//
+//	address: the lightning address, optionally prefixed with "lightning:".
+//	opts: optional settings such as WithRequestDoer and WithDomainPolicy.
+func FetchLnurlpForLightningAddress(address string, opts ...Option) (*protocol.LnurlpResponse, error) {
+	return FetchLnurlpForLightningAddressWithContext(context.Background(), address, opts...)
+}
+
+// FetchLnurlpForLightningAddressWithContext is the same as FetchLnurlpForLightningAddress, but uses the given context
+// for the outbound request.
+func FetchLnurlpForLightningAddressWithContext(
+	ctx context.Context,
+	address string,
+	opts ...Option,
+) (*protocol.LnurlpResponse, error) {
+	address = strings.TrimPrefix(address, "lightning:")
+	username, domain, ok := strings.Cut(address, "@")
+	if !ok || domain == "" || !umaUsernameRegex.MatchString(username) {
+		return nil, fmt.Errorf("invalid lightning address %q", address)
+	}
+	response, err := SendLnurlpRequest(ctx, protocol.LnurlpRequest{ReceiverAddress: address}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Tag != "payRequest" {
+		return nil, fmt.Errorf("unexpected lnurl tag %q", response.Tag)
+	}
+	return response, nil
+}
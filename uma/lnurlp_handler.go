@@ -0,0 +1,180 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// LnurlpRequestInfo describes an incoming lnurlp request before its signature is verified.
+type LnurlpRequestInfo struct {
+	// VaspDomain is the domain the sending VASP claims, or empty for non-UMA requests. It is not verified yet.
+	VaspDomain string
+	// ReceiverAddress is the address of the receiver, e.g. $bob@vasp2.com.
+	ReceiverAddress string
+	// RemoteIp is the IP address the request came from. Behind a reverse proxy, this is the proxy's address unless
+	// the handler is wrapped in a middleware which sets http.Request.RemoteAddr from a trusted header.
+	RemoteIp string
+}
+
+// LnurlpRequestHook observes incoming lnurlp requests before any cryptographic work is done, so that abusive senders
+// can be denylisted or throttled cheaply. Returning an error rejects the request: with the status code of a
+// RequestRejectedError, or 403 Forbidden for any other error.
+type LnurlpRequestHook func(ctx context.Context, info LnurlpRequestInfo) error
+
+// WithLnurlpRequestHook adds a LnurlpRequestHook to an LnurlpRequestHandler. Hooks run in the order they were added,
+// until one rejects the request.
+func WithLnurlpRequestHook(hook LnurlpRequestHook) Option {
+	return func(o *options) {
+		o.lnurlpRequestHooks = append(o.lnurlpRequestHooks, hook)
+	}
+}
+
+// RequestRejectedError is returned by a LnurlpRequestHook to reject a request with a specific status code, for
+// example 429 Too Many Requests when throttling a sender.
+type RequestRejectedError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e RequestRejectedError) Error() string {
+	return e.Reason
+}
+
+// LnurlpResponseFunc creates the response to a verified lnurlp request, for example with GetLnurlpResponseWithSigner.
+// Returning an UnsupportedVersionError responds with 412 Precondition Failed so that the sender can negotiate
+// another version, an error wrapping ErrCorridorRestricted responds with 403 Forbidden, and any other error responds
+// with an internal server error.
+type LnurlpResponseFunc func(ctx context.Context, request *protocol.LnurlpRequest) (*protocol.LnurlpResponse, error)
+
+// LnurlpRequestHandler is an http.Handler which serves lnurlp requests at /.well-known/lnurlp/<username>. It parses
+// each request, runs the LnurlpRequestHooks set with WithLnurlpRequestHook, looks up the receiver with the
+// ReceiverResolver set with WithReceiverResolver, if any, fetches the sender's public keys and verifies the signature
+// of UMA requests, and responds with the response from a LnurlpResponseFunc.
+type LnurlpRequestHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	respond        LnurlpResponseFunc
+	opts           []Option
+}
+
+// NewLnurlpRequestHandler Creates a LnurlpRequestHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	respond: called with each verified request to create its response.
+//	opts: optional settings such as WithLnurlpRequestHook and WithReceiverResolver, and settings used when fetching
+//		public keys and verifying signatures, such as WithLogger.
+func NewLnurlpRequestHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	respond LnurlpResponseFunc,
+	opts ...Option,
+) *LnurlpRequestHandler {
+	return &LnurlpRequestHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		respond:        respond,
+		opts:           opts,
+	}
+}
+
+func (h *LnurlpRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodGet {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	ctx := r.Context()
+	request, err := ParseLnurlpRequestWithReceiverDomain(*r.URL, r.Host, opts...)
+	if err != nil {
+		writeLnurlpError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	o := newOptions(opts)
+	info := LnurlpRequestInfo{ReceiverAddress: request.ReceiverAddress, RemoteIp: remoteIp(r)}
+	if request.VaspDomain != nil {
+		info.VaspDomain = *request.VaspDomain
+	}
+	for _, hook := range o.lnurlpRequestHooks {
+		if err = hook(ctx, info); err != nil {
+			o.log(ctx, slog.LevelWarn, LogEventLnurlpRejected, slog.String("counterparty", info.VaspDomain),
+				slog.String("remote_ip", info.RemoteIp), slog.String("error", err.Error()))
+			statusCode := http.StatusForbidden
+			var rejectedErr RequestRejectedError
+			if errors.As(err, &rejectedErr) {
+				statusCode = rejectedErr.StatusCode
+			}
+			writeStatusResponse(w, statusCode, err)
+			return
+		}
+	}
+
+	if o.receiverResolver != nil {
+		accountId, err := o.receiverResolver.ResolveReceiver(ctx, request.ReceiverAddress)
+		if errors.Is(err, ErrReceiverNotFound) {
+			writeStatusResponse(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeStatusResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to resolve receiver: %w", err))
+			return
+		}
+		ctx = context.WithValue(ctx, receiverAccountIdKey{}, accountId)
+	}
+
+	if umaRequest := request.AsUmaRequest(); umaRequest != nil {
+		pubKeyResponse, err := FetchPublicKeyForVaspWithContext(ctx, umaRequest.VaspDomain, h.publicKeyCache, opts...)
+		if err != nil {
+			writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+			return
+		}
+		if err = VerifyUmaLnurlpQuerySignature(*umaRequest, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+			writeStatusResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	response, err := h.respond(ctx, request)
+	if errors.Is(err, ErrCorridorRestricted) {
+		writeStatusResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		writeLnurlpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// writeLnurlpError writes an error response, responding to unsupported versions with the supported versions.
+func writeLnurlpError(w http.ResponseWriter, statusCode int, err error) {
+	var unsupportedVersionErr UnsupportedVersionError
+	if !errors.As(err, &unsupportedVersionErr) {
+		writeStatusResponse(w, statusCode, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	_ = json.NewEncoder(w).Encode(&unsupportedVersionErr)
+}
+
+// remoteIp returns the IP address of the client which sent a request.
+func remoteIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package uma
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultLockTtl is how long a PayRequestHandler holds the lock for a pay request at most, in case the instance
+// holding it dies without releasing it.
+const defaultLockTtl = 30 * time.Second
+
+// Locker provides mutual exclusion between the instances of a VASP. The PayRequestHandler uses it, when set with
+// WithLocker, to check the nonce of a pay request and create its invoice atomically across replicas, so that a
+// sender retrying against another instance gets the original response instead of a second invoice.
+//
+// Implementations of this interface should be thread-safe. Distributed implementations are typically backed by the
+// database or cache shared by the instances, e.g. with Redis SET NX PX or PostgreSQL advisory locks.
+type Locker interface {
+	// Lock acquires the lock with the given key, waiting until it is released or ctx is done. The lock is released
+	// by calling the returned function, or automatically after ttl, so that a crashed instance cannot hold it forever.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+}
+
+// WithLocker sets the Locker a PayRequestHandler uses to process each pay request on one instance at a time.
+func WithLocker(locker Locker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// WithIdempotencyStore sets the IdempotencyStore a PayRequestHandler uses to respond to retried pay requests with
+// the original response. See ProcessIdempotently.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(o *options) {
+		o.idempotencyStore = store
+	}
+}
+
+// InMemoryLocker is an in-memory implementation of Locker.
+// It only provides mutual exclusion within one process, so it is not suitable for VASPs running several instances.
+// The ttl passed to Lock is ignored, since a lock cannot outlive the process holding it.
+type InMemoryLocker struct {
+	mutex sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func NewInMemoryLocker() *InMemoryLocker {
+	return &InMemoryLocker{locks: make(map[string]chan struct{})}
+}
+
+func (l *InMemoryLocker) Lock(ctx context.Context, key string, _ time.Duration) (func(), error) {
+	for {
+		l.mutex.Lock()
+		released, held := l.locks[key]
+		if !held {
+			released = make(chan struct{})
+			l.locks[key] = released
+			l.mutex.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					l.mutex.Lock()
+					delete(l.locks, key)
+					l.mutex.Unlock()
+					close(released)
+				})
+			}, nil
+		}
+		l.mutex.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
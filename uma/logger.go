@@ -0,0 +1,59 @@
+package uma
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Structured log events emitted by the SDK when a logger is set with WithLogger. Each event is logged with the event
+// name as the message, and attributes such as the counterparty domain and correlation ID.
+const (
+	LogEventLnurlpRejected                = "uma.lnurlp.rejected"
+	LogEventLnurlpSent                    = "uma.lnurlp.sent"
+	LogEventPayReqSent                    = "uma.payreq.sent"
+	LogEventPayReqRejected                = "uma.payreq.rejected"
+	LogEventPayReqCancellationSent        = "uma.payreq_cancellation.sent"
+	LogEventPayReqResolutionWebhookSent   = "uma.payreq_resolution_webhook.sent"
+	LogEventPaymentStatusWebhookSent      = "uma.payment_status_webhook.sent"
+	LogEventPaymentReceiptSent            = "uma.payment_receipt.sent"
+	LogEventPostTransactionCallbackSent   = "uma.post_transaction_callback.sent"
+	LogEventPaymentCompensationFailed     = "uma.payment.compensation_failed"
+	LogEventPubKeyFetched                 = "uma.pubkey.fetched"
+	LogEventPubKeyPrefetchFailed          = "uma.pubkey.prefetch_failed"
+	LogEventPubKeyPinMismatch             = "uma.pubkey.pin_mismatch"
+	LogEventPubKeyChanged                 = "uma.pubkey.changed"
+	LogEventRequestRetried                = "uma.request.retried"
+	LogEventSignatureVerified             = "uma.signature.verified"
+	LogEventSignatureInvalid              = "uma.signature.invalid"
+	LogEventNonceRejected                 = "uma.nonce.rejected"
+	LogEventPayerIdentifierDomainMismatch = "uma.payer_identifier.domain_mismatch"
+	LogEventVersionNegotiated             = "uma.version.negotiated"
+	LogEventVersionUnsupported            = "uma.version.unsupported"
+)
+
+// WithLogger sets a structured logger which receives protocol events, such as requests sent, signatures verified,
+// versions negotiated and nonces rejected. By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithCorrelationId sets an ID which is attached to every log event, event and audit record, and sent to counterparty
+// VASPs in the CorrelationIdHeader, so that events from a single payment flow can be correlated. Without it, the ID
+// carried by the context is used; see ContextWithCorrelationId.
+func WithCorrelationId(correlationId string) Option {
+	return func(o *options) {
+		o.correlationId = correlationId
+	}
+}
+
+func (o *options) log(ctx context.Context, level slog.Level, event string, attrs ...slog.Attr) {
+	if o.logger == nil {
+		return
+	}
+	if correlationId := o.correlationIdFor(ctx); correlationId != "" {
+		attrs = append(attrs, slog.String("correlation_id", correlationId))
+	}
+	o.logger.LogAttrs(ctx, level, event, attrs...)
+}
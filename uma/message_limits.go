@@ -0,0 +1,160 @@
+package uma
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// MessageLimits caps the size of messages from other VASPs and of their variable-length fields, so that a
+// counterparty cannot make a VASP allocate large amounts of memory with a crafted message. The Parse functions reject
+// messages over the limits with a MessageLimitError. Zero fields use the value from DefaultMessageLimits.
+type MessageLimits struct {
+	// MaxMessageBytes is the size of a whole JSON message, checked before it is decoded.
+	MaxMessageBytes int
+	// MaxCounterPartyDataBytes is the size of the JSON-encoded payer data of a pay request, or payee data of a pay
+	// request response.
+	MaxCounterPartyDataBytes int
+	// MaxCommentLength is the number of characters in the comment of a pay request. Receivers should also check the
+	// comment against the commentAllowed of their lnurlp response.
+	MaxCommentLength int
+	// MaxCurrencies is the number of currencies in an lnurlp response.
+	MaxCurrencies int
+	// MaxUtxos is the number of UTXOs in payer or payee compliance data, or in a post-transaction callback.
+	MaxUtxos int
+}
+
+// DefaultMessageLimits are the MessageLimits used unless WithMessageLimits is set. They leave ample room for
+// spec-compliant messages: messages match the 64 KiB body limit of the SDK's handlers, and comments the largest
+// commentAllowed in common use.
+var DefaultMessageLimits = MessageLimits{
+	MaxMessageBytes:          64 * 1024,
+	MaxCounterPartyDataBytes: 16 * 1024,
+	MaxCommentLength:         2000,
+	MaxCurrencies:            100,
+	MaxUtxos:                 1000,
+}
+
+// WithMessageLimits sets the MessageLimits enforced by the Parse functions. Defaults to DefaultMessageLimits.
+func WithMessageLimits(limits MessageLimits) Option {
+	return func(o *options) {
+		o.messageLimits = limits
+	}
+}
+
+// MessageLimitError is returned by the Parse functions when a message or one of its fields exceeds a MessageLimits
+// limit.
+type MessageLimitError struct {
+	MessageType MessageType
+	// Field is the field over the limit, or empty for the whole message.
+	Field  string
+	Limit  int
+	Actual int
+}
+
+func (e MessageLimitError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s of %d bytes exceeds the limit of %d bytes", e.MessageType, e.Actual, e.Limit)
+	}
+	return fmt.Sprintf("%s field %s of size %d exceeds the limit of %d", e.MessageType, e.Field, e.Actual, e.Limit)
+}
+
+// limits returns the MessageLimits set with WithMessageLimits, with zero fields filled from DefaultMessageLimits.
+func (o *options) limits() MessageLimits {
+	limits := o.messageLimits
+	if limits.MaxMessageBytes == 0 {
+		limits.MaxMessageBytes = DefaultMessageLimits.MaxMessageBytes
+	}
+	if limits.MaxCounterPartyDataBytes == 0 {
+		limits.MaxCounterPartyDataBytes = DefaultMessageLimits.MaxCounterPartyDataBytes
+	}
+	if limits.MaxCommentLength == 0 {
+		limits.MaxCommentLength = DefaultMessageLimits.MaxCommentLength
+	}
+	if limits.MaxCurrencies == 0 {
+		limits.MaxCurrencies = DefaultMessageLimits.MaxCurrencies
+	}
+	if limits.MaxUtxos == 0 {
+		limits.MaxUtxos = DefaultMessageLimits.MaxUtxos
+	}
+	return limits
+}
+
+func checkLimit(messageType MessageType, field string, actual int, limit int) error {
+	if actual > limit {
+		return MessageLimitError{MessageType: messageType, Field: field, Limit: limit, Actual: actual}
+	}
+	return nil
+}
+
+// checkMessageSize checks the size of a message before it is decoded.
+func (o *options) checkMessageSize(messageType MessageType, bytes []byte) error {
+	return checkLimit(messageType, "", len(bytes), o.limits().MaxMessageBytes)
+}
+
+func checkCounterPartyDataSize(
+	messageType MessageType,
+	field string,
+	data map[string]interface{},
+	limit int,
+) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return checkLimit(messageType, field, len(encoded), limit)
+}
+
+func (o *options) checkPayRequestLimits(request *protocol.PayRequest) error {
+	limits := o.limits()
+	if request.Comment != nil {
+		err := checkLimit(MessageTypePayRequest, "comment", utf8.RuneCountInString(*request.Comment),
+			limits.MaxCommentLength)
+		if err != nil {
+			return err
+		}
+	}
+	if request.PayerData == nil {
+		return nil
+	}
+	err := checkCounterPartyDataSize(MessageTypePayRequest, "payerData", *request.PayerData,
+		limits.MaxCounterPartyDataBytes)
+	if err != nil {
+		return err
+	}
+	if compliance, _ := request.PayerData.Compliance(); compliance != nil && compliance.Utxos != nil {
+		return checkLimit(MessageTypePayRequest, "payerData.compliance.utxos", len(*compliance.Utxos),
+			limits.MaxUtxos)
+	}
+	return nil
+}
+
+func (o *options) checkPayReqResponseLimits(response *protocol.PayReqResponse) error {
+	if response.PayeeData == nil {
+		return nil
+	}
+	limits := o.limits()
+	err := checkCounterPartyDataSize(MessageTypePayReqResponse, "payeeData", *response.PayeeData,
+		limits.MaxCounterPartyDataBytes)
+	if err != nil {
+		return err
+	}
+	if compliance, _ := response.PayeeData.Compliance(); compliance != nil {
+		return checkLimit(MessageTypePayReqResponse, "payeeData.compliance.utxos", len(compliance.Utxos),
+			limits.MaxUtxos)
+	}
+	return nil
+}
+
+func (o *options) checkLnurlpResponseLimits(response *protocol.LnurlpResponse) error {
+	if response.Currencies == nil {
+		return nil
+	}
+	return checkLimit(MessageTypeLnurlpResponse, "currencies", len(*response.Currencies), o.limits().MaxCurrencies)
+}
+
+func (o *options) checkPostTransactionCallbackLimits(callback *protocol.PostTransactionCallback) error {
+	return checkLimit(MessageTypePostTransactionCallback, "utxos", len(callback.Utxos), o.limits().MaxUtxos)
+}
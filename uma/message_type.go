@@ -0,0 +1,38 @@
+package uma
+
+// MessageType identifies a kind of UMA protocol message. It is used to tag messages in envelopes, and in the
+// message_type attribute of logs and metrics.
+type MessageType string
+
+const (
+	// MessageTypeLnurlpRequest is an lnurlp request, sent by the sending VASP as a URL.
+	MessageTypeLnurlpRequest MessageType = "lnurlp_request"
+	// MessageTypeLnurlpResponse is the receiving VASP's response to an lnurlp request.
+	MessageTypeLnurlpResponse MessageType = "lnurlp_response"
+	// MessageTypePayRequest is a pay request from the sending VASP.
+	MessageTypePayRequest MessageType = "payreq"
+	// MessageTypePayReqResponse is the receiving VASP's response to a pay request.
+	MessageTypePayReqResponse MessageType = "payreq_response"
+	// MessageTypePostTransactionCallback is a post transaction callback with the UTXOs used by a payment.
+	MessageTypePostTransactionCallback MessageType = "post_transaction_callback"
+	// MessageTypeInvoice is an UMA invoice, encoded as a bech32 string.
+	MessageTypeInvoice MessageType = "invoice"
+	// MessageTypePaymentStatusWebhook is an asynchronous payment status notification.
+	MessageTypePaymentStatusWebhook MessageType = "payment_status_webhook"
+	// MessageTypePayReqResolutionWebhook tells the sending VASP that the review of a held pay request is complete.
+	MessageTypePayReqResolutionWebhook MessageType = "payreq_resolution_webhook"
+	// MessageTypePayReqCancellation tells the receiving VASP that an invoice will not be paid.
+	MessageTypePayReqCancellation MessageType = "payreq_cancellation"
+	// MessageTypePaymentMandate is a sending VASP's authorization for recurring payment pulls.
+	MessageTypePaymentMandate MessageType = "payment_mandate"
+	// MessageTypePaymentPullRequest is a receiving VASP's request to pull a payment under a mandate.
+	MessageTypePaymentPullRequest MessageType = "payment_pull_request"
+	// MessageTypePaymentMandateRevocation revokes a payment mandate.
+	MessageTypePaymentMandateRevocation MessageType = "payment_mandate_revocation"
+	// MessageTypePaymentReceipt is a signed receipt of a settled payment.
+	MessageTypePaymentReceipt MessageType = "payment_receipt"
+	// MessageTypePubKeyResponse is a VASP's public key response.
+	MessageTypePubKeyResponse MessageType = "pubkey_response"
+	// MessageTypeHttpRequest is an HTTP request signed with an HTTP message signature.
+	MessageTypeHttpRequest MessageType = "http_request"
+)
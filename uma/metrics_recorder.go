@@ -0,0 +1,64 @@
+package uma
+
+import (
+	"context"
+	"time"
+)
+
+// VerificationFailureReason is the reason a message from a counterparty VASP failed verification.
+type VerificationFailureReason string
+
+const (
+	// VerificationFailureInvalidSignature means the signature did not match any of the counterparty's keys.
+	VerificationFailureInvalidSignature VerificationFailureReason = "invalid_signature"
+	// VerificationFailureNonceRejected means the nonce was reused or the timestamp was stale.
+	VerificationFailureNonceRejected VerificationFailureReason = "nonce_rejected"
+)
+
+// VersionNegotiationOutcome is the outcome of negotiating the UMA version of an lnurlp exchange.
+type VersionNegotiationOutcome string
+
+const (
+	// VersionNegotiationMatched means the requested version was used.
+	VersionNegotiationMatched VersionNegotiationOutcome = "matched"
+	// VersionNegotiationDowngraded means a lower version than requested was used.
+	VersionNegotiationDowngraded VersionNegotiationOutcome = "downgraded"
+	// VersionNegotiationUnsupported means the receiving VASP supports no compatible version.
+	VersionNegotiationUnsupported VersionNegotiationOutcome = "unsupported"
+)
+
+// MetricsRecorder receives measurements from the SDK, so that they can be exported to a metrics system without
+// OpenTelemetry. The umametrics package implements it for Prometheus. Implementations of this interface should be
+// thread-safe and return quickly.
+type MetricsRecorder interface {
+	// RecordVerificationFailure is called when a message from a counterparty fails verification.
+	RecordVerificationFailure(ctx context.Context, messageType MessageType, reason VerificationFailureReason)
+	// RecordVersionNegotiation is called when the UMA version of an lnurlp exchange is negotiated: by the receiving
+	// VASP in GetLnurlpResponseWithSigner, and by the sending VASP in SendLnurlpRequest when the receiving VASP
+	// supports no compatible version.
+	RecordVersionNegotiation(ctx context.Context, outcome VersionNegotiationOutcome)
+	// RecordPayReqDuration is called when an outbound pay request completes, with the error it failed with, if any.
+	RecordPayReqDuration(ctx context.Context, duration time.Duration, err error)
+	// RecordPublicKeyCacheLookup is called when the public keys of a counterparty are looked up in a PublicKeyCache.
+	RecordPublicKeyCacheLookup(ctx context.Context, hit bool)
+}
+
+// WithMetricsRecorder sets a MetricsRecorder which receives measurements such as verification failures, version
+// negotiation outcomes, pay request latency and public key cache hits. By default nothing is recorded.
+func WithMetricsRecorder(recorder MetricsRecorder) Option {
+	return func(o *options) {
+		o.metricsRecorder = recorder
+	}
+}
+
+func (o *options) recordVersionNegotiation(ctx context.Context, outcome VersionNegotiationOutcome) {
+	if o.metricsRecorder != nil {
+		o.metricsRecorder.RecordVersionNegotiation(ctx, outcome)
+	}
+}
+
+func (o *options) recordPublicKeyCacheLookup(ctx context.Context, hit bool) {
+	if o.metricsRecorder != nil {
+		o.metricsRecorder.RecordPublicKeyCacheLookup(ctx, hit)
+	}
+}
@@ -0,0 +1,81 @@
+package uma
+
+import (
+	"fmt"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// Network is the Bitcoin network which Lightning invoices must be for.
+type Network string
+
+const (
+	NetworkMainnet Network = "mainnet"
+	NetworkTestnet Network = "testnet"
+	NetworkRegtest Network = "regtest"
+	NetworkSignet  Network = "signet"
+)
+
+// bolt11Prefixes are the BOLT11 currency prefixes of each Network, e.g. lnbc for mainnet invoices.
+var bolt11Prefixes = map[Network]string{
+	NetworkMainnet: "bc",
+	NetworkTestnet: "tb",
+	NetworkRegtest: "bcrt",
+	NetworkSignet:  "tbs",
+}
+
+// WithNetwork sets the Network which invoices must be for. Senders reject invoices for other networks in
+// VerifyPayReqResponse and SendPayment, and default to NetworkMainnet, so that test invoices are only accepted when
+// explicitly configured. Receivers which set it also check the invoices created by their InvoiceCreator in
+// GetPayReqResponse, to catch a VASP connected to a node on the wrong network.
+func WithNetwork(network Network) Option {
+	return func(o *options) {
+		o.network = network
+	}
+}
+
+// InvoiceNetworkError is returned when an invoice is for a different Network than the one set with WithNetwork.
+type InvoiceNetworkError struct {
+	Expected Network
+	// InvoicePrefix is the BOLT11 currency prefix of the invoice, e.g. "bcrt".
+	InvoicePrefix string
+}
+
+func (e InvoiceNetworkError) Error() string {
+	return fmt.Sprintf("expected an invoice for %s, but the invoice is for network prefix %q", e.Expected,
+		e.InvoicePrefix)
+}
+
+// networkOrMainnet returns the Network set with WithNetwork, or NetworkMainnet if none was set.
+func (o *options) networkOrMainnet() Network {
+	if o.network == "" {
+		return NetworkMainnet
+	}
+	return o.network
+}
+
+// checkInvoiceNetwork checks that a decoded invoice is for the Network set with WithNetwork, or mainnet by default.
+func (o *options) checkInvoiceNetwork(invoice *utils.Bolt11Invoice) error {
+	network := o.networkOrMainnet()
+	prefix, ok := bolt11Prefixes[network]
+	if !ok {
+		return fmt.Errorf("unknown network %s", network)
+	}
+	if invoice.Network != prefix {
+		return InvoiceNetworkError{Expected: network, InvoicePrefix: invoice.Network}
+	}
+	return nil
+}
+
+// checkCreatedInvoiceNetwork checks that an invoice created by the receiver's InvoiceCreator is for the Network set
+// with WithNetwork. It does nothing unless the receiver set WithNetwork.
+func (o *options) checkCreatedInvoiceNetwork(encodedInvoice string) error {
+	if o.network == "" {
+		return nil
+	}
+	invoice, err := utils.DecodeBolt11(encodedInvoice)
+	if err != nil {
+		return fmt.Errorf("unable to decode the created invoice: %w", err)
+	}
+	return o.checkInvoiceNetwork(invoice)
+}
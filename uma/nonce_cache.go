@@ -6,6 +6,10 @@ import (
 	"time"
 )
 
+// ErrNonceCacheRequired is returned when a signed message is verified without a NonceCache, since its nonce could not
+// be checked to prevent replay attacks.
+var ErrNonceCacheRequired = errors.New("a NonceCache is required to verify signed messages")
+
 // NonceCache is an interface for a caching of nonces used in signatures. This is used to prevent replay attacks.
 //
 // Implementations of this interface should be thread-safe.
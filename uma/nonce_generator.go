@@ -0,0 +1,134 @@
+package uma
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MinNonceEntropyBits is the minimum entropy of the nonces generated by NewNonceGenerator.
+const MinNonceEntropyBits = 64
+
+// NonceGenerator generates the nonces of signed messages, which receivers use to reject replayed messages. Use
+// NewNonceGenerator or NewUuidV7NonceGenerator to meet internal randomness policy requirements, such as the length or
+// alphabet of nonces, or a specific random source.
+//
+// Implementations of this interface should be thread-safe.
+type NonceGenerator interface {
+	// GenerateNonce returns a new random nonce.
+	GenerateNonce() (string, error)
+}
+
+// NonceGeneratorFunc is an adapter to use an ordinary function as a NonceGenerator.
+type NonceGeneratorFunc func() (string, error)
+
+func (f NonceGeneratorFunc) GenerateNonce() (string, error) {
+	return f()
+}
+
+// DefaultNonceGenerator is the NonceGenerator used when none is set with WithNonceGenerator. It generates decimal
+// nonces below 2^32 from crypto/rand.
+var DefaultNonceGenerator NonceGenerator = NonceGeneratorFunc(func() (string, error) {
+	randomBigInt, err := rand.Int(rand.Reader, big.NewInt(0xFFFFFFFF))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(randomBigInt.Uint64(), 10), nil
+})
+
+// WithNonceGenerator sets the NonceGenerator used when signing messages. Defaults to DefaultNonceGenerator.
+func WithNonceGenerator(generator NonceGenerator) Option {
+	return func(o *options) {
+		if generator != nil {
+			o.nonceGenerator = generator
+		}
+	}
+}
+
+// generateNonce returns a new nonce from the NonceGenerator of the options.
+func (o *options) generateNonce() (*string, error) {
+	nonce, err := o.nonceGenerator.GenerateNonce()
+	if err != nil {
+		return nil, err
+	}
+	return &nonce, nil
+}
+
+// alphabetNonceGenerator generates nonces of a fixed length from an alphabet.
+type alphabetNonceGenerator struct {
+	random   io.Reader
+	length   int
+	alphabet string
+}
+
+// NewNonceGenerator Creates a NonceGenerator which generates nonces of a fixed length, with characters picked
+// uniformly from an alphabet. The nonces must have at least MinNonceEntropyBits of entropy, e.g. 11 characters of
+// the 64 characters of base64url, or 16 hex digits.
+//
+// Args:
+//
+//	random: the source of randomness. If nil, crypto/rand is used.
+//	length: the number of characters of each nonce.
+//	alphabet: the characters of nonces. They must be distinct printable ASCII characters other than "|", which
+//		separates the fields of signed payloads.
+func NewNonceGenerator(random io.Reader, length int, alphabet string) (NonceGenerator, error) {
+	if len(alphabet) < 2 {
+		return nil, errors.New("the nonce alphabet must have at least 2 characters")
+	}
+	for i, char := range alphabet {
+		if char < '!' || char > '~' || char == '|' {
+			return nil, fmt.Errorf("invalid nonce character %q", char)
+		}
+		if strings.ContainsRune(alphabet[i+1:], char) {
+			return nil, fmt.Errorf("duplicate nonce character %q", char)
+		}
+	}
+	entropyBits := float64(length) * math.Log2(float64(len(alphabet)))
+	if entropyBits < MinNonceEntropyBits {
+		return nil, fmt.Errorf("nonces of %d characters from an alphabet of %d have %.1f bits of entropy, less than %d",
+			length, len(alphabet), entropyBits, MinNonceEntropyBits)
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return &alphabetNonceGenerator{random: random, length: length, alphabet: alphabet}, nil
+}
+
+func (g *alphabetNonceGenerator) GenerateNonce() (string, error) {
+	alphabetSize := big.NewInt(int64(len(g.alphabet)))
+	nonce := make([]byte, g.length)
+	for i := range nonce {
+		index, err := rand.Int(g.random, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		nonce[i] = g.alphabet[index.Int64()]
+	}
+	return string(nonce), nil
+}
+
+// NewUuidV7NonceGenerator Creates a NonceGenerator which generates UUIDv7 nonces. They start with the time they were
+// generated at, so that they sort chronologically in audit logs, and have 74 random bits.
+//
+// Args:
+//
+//	random: the source of randomness. If nil, crypto/rand is used.
+func NewUuidV7NonceGenerator(random io.Reader) NonceGenerator {
+	if random == nil {
+		random = rand.Reader
+	}
+	return NonceGeneratorFunc(func() (string, error) {
+		nonce, err := uuid.NewV7FromReader(random)
+		if err != nil {
+			return "", err
+		}
+		return nonce.String(), nil
+	})
+}
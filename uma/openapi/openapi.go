@@ -0,0 +1,314 @@
+// Package openapi generates an OpenAPI 3.1 document for the HTTP endpoints a VASP serves with the SDK's handlers:
+// lnurlp requests, pay request callbacks, public keys and post-transaction callbacks. The message schemas come from
+// the schemas package, so the document matches the exact shapes the SDK emits, and can be loaded into API gateways
+// and contract-testing pipelines.
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/schemas"
+)
+
+// Version is the OpenAPI version of the generated documents.
+const Version = "3.1.0"
+
+const (
+	// DefaultPayRequestPath is the default path of the pay request callback, which receiving VASPs set in the callback
+	// of their lnurlp responses.
+	DefaultPayRequestPath = "/api/uma/payreq/{userId}"
+	// DefaultPostTransactionCallbackPath is the default path of the post-transaction callback, which sending VASPs
+	// set in the utxoCallback of their pay requests.
+	DefaultPostTransactionCallbackPath = "/api/uma/utxoCallback"
+)
+
+// componentsRef is the prefix of references to the schemas in the components of a document.
+const componentsRef = "#/components/schemas/"
+
+// Config describes the endpoints of a VASP.
+type Config struct {
+	// Title is the title of the API. Defaults to "UMA".
+	Title string
+	// ApiVersion is the version of the API, not of the OpenAPI specification. Defaults to "1.0.0".
+	ApiVersion string
+	// ServerUrls are the base URLs of the VASP, e.g. "https://vasp.com".
+	ServerUrls []string
+	// PayRequestPath is the path of the pay request callback. Path parameters are written in braces, as in
+	// DefaultPayRequestPath, which is the default.
+	PayRequestPath string
+	// PostTransactionCallbackPath is the path of the post-transaction callback. Defaults to
+	// DefaultPostTransactionCallbackPath.
+	PostTransactionCallbackPath string
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenApi    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info is the metadata of an API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is a base URL of an API.
+type Server struct {
+	Url string `json:"url"`
+}
+
+// PathItem holds the operations of a path.
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+}
+
+// Operation is an API operation, identified by its path and HTTP method.
+type Operation struct {
+	OperationId string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter of an operation.
+type Parameter struct {
+	Name        string          `json:"name"`
+	In          string          `json:"in"`
+	Description string          `json:"description,omitempty"`
+	Required    bool            `json:"required"`
+	Schema      *schemas.Schema `json:"schema"`
+}
+
+// RequestBody describes the body of requests to an operation.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body with a content type.
+type MediaType struct {
+	Schema *schemas.Schema `json:"schema"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Components holds the schemas referenced by a document.
+type Components struct {
+	Schemas map[string]*schemas.Schema `json:"schemas"`
+}
+
+// Generate Creates the OpenAPI document of the endpoints of a VASP.
+//
+// Args:
+//
+//	config: the paths and metadata of the VASP's API.
+func Generate(config Config) (*Document, error) {
+	if config.Title == "" {
+		config.Title = "UMA"
+	}
+	if config.ApiVersion == "" {
+		config.ApiVersion = "1.0.0"
+	}
+	if config.PayRequestPath == "" {
+		config.PayRequestPath = DefaultPayRequestPath
+	}
+	if config.PostTransactionCallbackPath == "" {
+		config.PostTransactionCallbackPath = DefaultPostTransactionCallbackPath
+	}
+	for _, path := range []string{config.PayRequestPath, config.PostTransactionCallbackPath} {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", path)
+		}
+	}
+
+	document := &Document{
+		OpenApi:    Version,
+		Info:       Info{Title: config.Title, Version: config.ApiVersion},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: map[string]*schemas.Schema{"StatusResponse": statusResponseSchema()}},
+	}
+	for _, serverUrl := range config.ServerUrls {
+		document.Servers = append(document.Servers, Server{Url: serverUrl})
+	}
+	for _, message := range []interface{}{
+		protocol.LnurlpResponse{},
+		protocol.PayRequest{},
+		protocol.PayReqResponse{},
+		protocol.PubKeyResponse{},
+		protocol.PostTransactionCallback{},
+		protocol.PendingPayReqResponse{},
+	} {
+		if err := document.addSchema(message); err != nil {
+			return nil, err
+		}
+	}
+
+	document.Paths["/.well-known/lnurlp/{username}"] = &PathItem{Get: &Operation{
+		OperationId: "lnurlp",
+		Summary:     "Returns the payment details of a receiver, signed for UMA requests.",
+		Parameters: []Parameter{
+			pathParameter("username"),
+			queryParameter("signature", "The base64-encoded signature of the sending VASP. Required for UMA.", stringSchema()),
+			queryParameter("vaspDomain", "The domain of the sending VASP. Required for UMA.", stringSchema()),
+			queryParameter("nonce", "The signature nonce. Required for UMA.", stringSchema()),
+			queryParameter("isSubjectToTravelRule", "Whether the sending VASP is subject to the travel rule.",
+				&schemas.Schema{Type: schemas.TypeSet{"boolean"}}),
+			queryParameter("timestamp", "The unix timestamp of the signature. Required for UMA.",
+				&schemas.Schema{Type: schemas.TypeSet{"integer"}}),
+			queryParameter("umaVersion", "The UMA version of the sending VASP. Required for UMA.", stringSchema()),
+		},
+		Responses: responses(ref("LnurlpResponse"), "The payment details of the receiver."),
+	}}
+
+	document.Paths[config.PayRequestPath] = &PathItem{
+		Get: &Operation{
+			OperationId: "payRequestQuery",
+			Summary:     "Returns an invoice for a non-UMA LNURL pay request.",
+			Parameters: append(pathParameters(config.PayRequestPath),
+				Parameter{Name: "amount", In: "query", Required: true, Schema: stringSchema(),
+					Description: "The amount in millisatoshis, or in the smallest unit of a currency as <amount>.<code>."},
+				queryParameter("convert", "The currency code the receiver receives.", stringSchema()),
+				queryParameter("payerData", "The JSON-encoded payer data.", stringSchema()),
+				queryParameter("payeeData", "The JSON-encoded requested payee data.", stringSchema()),
+				queryParameter("comment", "A comment for the receiver.", stringSchema()),
+				queryParameter("retrievalToken", "The retrieval token of a pay request held for review, to poll for "+
+					"its final response instead of sending a pay request.", stringSchema()),
+			),
+			Responses: pendingResponses(ref("PayReqResponse"), "The invoice to pay."),
+		},
+		Post: &Operation{
+			OperationId: "payRequest",
+			Summary:     "Returns a signed invoice for an UMA pay request.",
+			Parameters:  pathParameters(config.PayRequestPath),
+			RequestBody: jsonRequestBody(ref("PayRequest")),
+			Responses:   pendingResponses(ref("PayReqResponse"), "The invoice to pay."),
+		},
+	}
+
+	document.Paths["/.well-known/lnurlpubkey"] = &PathItem{Get: &Operation{
+		OperationId: "pubKeys",
+		Summary:     "Returns the public keys of the VASP.",
+		Responses:   responses(ref("PubKeyResponse"), "The public keys of the VASP."),
+	}}
+
+	document.Paths[config.PostTransactionCallbackPath] = &PathItem{Post: &Operation{
+		OperationId: "postTransactionCallback",
+		Summary:     "Receives the UTXOs of a completed payment from the counterparty VASP.",
+		Parameters:  pathParameters(config.PostTransactionCallbackPath),
+		RequestBody: jsonRequestBody(ref("PostTransactionCallback")),
+		Responses:   responses(ref("StatusResponse"), "The callback was accepted."),
+	}}
+	return document, nil
+}
+
+// addSchema adds the schema of a message and its definitions to the components of the document, named after the
+// message type.
+func (d *Document) addSchema(message interface{}) error {
+	schema, err := schemas.Generate(message)
+	if err != nil {
+		return err
+	}
+	for name, definition := range schema.Defs {
+		rewriteRefs(definition)
+		d.Components.Schemas[name] = definition
+	}
+	schema.Defs = nil
+	schema.Schema = ""
+	rewriteRefs(schema)
+	d.Components.Schemas[schema.Title] = schema
+	return nil
+}
+
+// ref returns a reference to a schema in the components of a document.
+func ref(name string) *schemas.Schema {
+	return &schemas.Schema{Ref: componentsRef + name}
+}
+
+// rewriteRefs points the references to the $defs of a schema document to the components of the OpenAPI document.
+func rewriteRefs(schema *schemas.Schema) {
+	if schema == nil {
+		return
+	}
+	if name, ok := strings.CutPrefix(schema.Ref, "#/$defs/"); ok {
+		schema.Ref = componentsRef + name
+	}
+	for _, property := range schema.Properties {
+		rewriteRefs(property)
+	}
+	rewriteRefs(schema.AdditionalProperties)
+	rewriteRefs(schema.Items)
+	for _, option := range schema.AnyOf {
+		rewriteRefs(option)
+	}
+}
+
+// statusResponseSchema describes the responses of the SDK's handlers which don't return a message, and of all errors.
+func statusResponseSchema() *schemas.Schema {
+	return &schemas.Schema{
+		Type: schemas.TypeSet{"object"},
+		Properties: map[string]*schemas.Schema{
+			"status": {Type: schemas.TypeSet{"string"}, Enum: []interface{}{"OK", "ERROR"}},
+			"reason": stringSchema(),
+		},
+		Required: []string{"status"},
+	}
+}
+
+func stringSchema() *schemas.Schema {
+	return &schemas.Schema{Type: schemas.TypeSet{"string"}}
+}
+
+var pathParameterPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// pathParameters returns the parameters in braces in a path.
+func pathParameters(path string) []Parameter {
+	var parameters []Parameter
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(path, -1) {
+		parameters = append(parameters, pathParameter(match[1]))
+	}
+	return parameters
+}
+
+func pathParameter(name string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Schema: stringSchema()}
+}
+
+func queryParameter(name string, description string, schema *schemas.Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func jsonRequestBody(schema *schemas.Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// pendingResponses returns the responses of a pay request operation, which may be held for review.
+func pendingResponses(schema *schemas.Schema, description string) map[string]*Response {
+	operationResponses := responses(schema, description)
+	operationResponses["202"] = &Response{
+		Description: "The pay request is held for review. Poll with the retrieval token for the final response.",
+		Content:     map[string]MediaType{"application/json": {Schema: ref("PendingPayReqResponse")}},
+	}
+	return operationResponses
+}
+
+// responses returns the responses of an operation: the given schema when successful, and a StatusResponse for errors.
+func responses(schema *schemas.Schema, description string) map[string]*Response {
+	errorContent := map[string]MediaType{"application/json": {Schema: ref("StatusResponse")}}
+	return map[string]*Response{
+		"200":     {Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}},
+		"default": {Description: "An error, with the reason it occurred.", Content: errorContent},
+	}
+}
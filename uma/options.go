@@ -0,0 +1,150 @@
+package uma
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures optional behavior of the SDK helpers, such as how outbound requests to other VASPs are made.
+// Options are passed as trailing variadic arguments, so all helpers keep working without them.
+type Option func(*options)
+
+type options struct {
+	requestDoer  RequestDoer
+	interceptors []Interceptor
+	retryPolicy  *RetryPolicy
+	domainPolicy *utils.DomainPolicy
+
+	maxResponseBodySize    int64
+	allowedCallbackDomains []string
+
+	complianceProvider                    ComplianceProvider
+	utxoProvider                          UtxoProvider
+	complianceDecider                     ComplianceDecider
+	complianceDeciderEncryptionPrivateKey []byte
+	heldPayRequestStore                   HeldPayRequestStore
+	heldPayRequestRetryAfter              time.Duration
+
+	logger        *slog.Logger
+	correlationId string
+	clock         Clock
+	eventSink     EventSink
+
+	nonceGenerator NonceGenerator
+
+	travelRulePolicy       TravelRulePolicy
+	travelRuleJurisdiction string
+
+	tracerProvider  trace.TracerProvider
+	meterProvider   metric.MeterProvider
+	metricsRecorder MetricsRecorder
+
+	captureRawPayload bool
+	parseOptions      ParseOptions
+
+	quote         *Quote
+	invoiceExpiry *time.Duration
+	feeBreakdown  *protocol.PaymentFees
+	network       Network
+
+	invoiceAmountTolerance *InvoiceAmountTolerance
+
+	prefetchParallelism     int
+	trustStore              *TrustStore
+	keyChangeObserver       KeyChangeObserver
+	lnurlpRequestHooks      []LnurlpRequestHook
+	receiverResolver        ReceiverResolver
+	payeeIdentifierRewriter PayeeIdentifierRewriter
+	vaspDirectory           VaspDirectory
+
+	currencyCorridorPolicy *CurrencyCorridorPolicy
+
+	allowNonUmaFallback bool
+
+	locker           Locker
+	idempotencyStore IdempotencyStore
+
+	senderConcurrencyLimiter       *senderConcurrencyLimiter
+	verificationConcurrencyLimiter *senderConcurrencyLimiter
+	invoiceQuota                   InvoiceQuota
+	invoiceQuotaReceiverKey        func(r *http.Request) string
+
+	signatureVerifier        SignatureVerifier
+	signatureEncoding        SignatureEncoding
+	verifyBatchWorkers       int
+	messageLimits            MessageLimits
+	signatureScheme          SignatureScheme
+	acceptedSignatureSchemes []SignatureScheme
+	auditWriter              AuditWriter
+	signingKeyMatchHandler   func(keyId string)
+
+	senderVaspDomain               string
+	skipPayerIdentifierDomainCheck bool
+	expectedReceiver               string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		requestDoer:        DefaultRequestDoer,
+		clock:              SystemClock,
+		nonceGenerator:     DefaultNonceGenerator,
+		complianceProvider: NoopComplianceProvider{},
+		signatureVerifier:  DefaultSignatureVerifier,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	if client, ok := o.requestDoer.(*http.Client); ok && o.domainPolicy != nil {
+		o.requestDoer = clientWithDomainPolicy(client, o.domainPolicy)
+	}
+	o.requestDoer = ChainInterceptors(o.requestDoer, o.interceptors...)
+	return o
+}
+
+// WithRequestDoer sets the RequestDoer used for all outbound HTTP requests. Use this to add proxies, mTLS,
+// observability, or test doubles. Defaults to DefaultRequestDoer.
+func WithRequestDoer(doer RequestDoer) Option {
+	return func(o *options) {
+		if doer != nil {
+			o.requestDoer = doer
+		}
+	}
+}
+
+// WithDomainPolicy restricts which counterparty URLs outbound requests may be sent to. Use this to protect against
+// SSRF via attacker-controlled receiver addresses or callback URLs, for example by blocking private IP ranges.
+//
+// The policy also applies to each redirect followed by a RequestDoer which is an *http.Client, such as
+// DefaultRequestDoer. Other RequestDoers should not follow redirects, since the SDK can only reject the response of
+// a redirect to a host the policy blocks after it was requested.
+func WithDomainPolicy(policy utils.DomainPolicy) Option {
+	return func(o *options) {
+		o.domainPolicy = &policy
+	}
+}
+
+// WithRawPayloadCapture makes the Parse* and Send* helpers keep a copy of the exact bytes each message was parsed
+// from in its RawResponse field. Use this to archive wire-level evidence for compliance audits, or to re-verify
+// signatures later even if the struct serialization changes.
+func WithRawPayloadCapture() Option {
+	return func(o *options) {
+		o.captureRawPayload = true
+	}
+}
+
+// rawPayload returns a copy of the given bytes if raw payload capture is enabled, otherwise nil. The bytes are copied
+// so that callers can safely reuse their buffers.
+func (o *options) rawPayload(payload []byte) []byte {
+	if !o.captureRawPayload {
+		return nil
+	}
+	return append([]byte(nil), payload...)
+}
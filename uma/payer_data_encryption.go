@@ -0,0 +1,134 @@
+package uma
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	eciesgo "github.com/ecies/go/v2"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// encryptedFieldsKey is the payer data field holding the fields encrypted with EncryptPayerDataFields, keyed by field
+// name.
+const encryptedFieldsKey = "encryptedFields"
+
+// EncryptPayerDataFields Encrypts individual payer data fields, e.g. the name, email and postal address of the payer,
+// to the receiving VASP's encryption key, so that personal data is not exposed to TLS-terminating proxies or logs on
+// the way. The encrypted fields are removed from the payer data and added, hex-encoded and keyed by field name, to
+// its "encryptedFields" object. Other fields stay in plaintext.
+//
+// The identifier and compliance fields cannot be encrypted, since the receiving VASP needs them to verify the pay
+// request. Payer data should be validated with ValidateCounterPartyData before its fields are encrypted.
+//
+// Args:
+//
+//	payerData: the payer data of a pay request, modified in place.
+//	receiverEncryptionPubKey: the encryption public key of the receiving VASP, from PubKeyResponse.EncryptionPubKey.
+//	fields: the fields to encrypt. Fields which are not set are skipped.
+func EncryptPayerDataFields(
+	payerData protocol.PayerData,
+	receiverEncryptionPubKey []byte,
+	fields ...protocol.CounterPartyDataField,
+) error {
+	encryptedFields, err := payerDataEncryptedFields(payerData)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		switch field {
+		case protocol.CounterPartyDataFieldIdentifier, protocol.CounterPartyDataFieldCompliance, encryptedFieldsKey:
+			return fmt.Errorf("the %s field cannot be encrypted", field)
+		}
+		value, ok := payerData[field.String()]
+		if !ok {
+			continue
+		}
+		valueJson, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		encryptedValue, err := eciesEncryptHex(receiverEncryptionPubKey, valueJson)
+		if err != nil {
+			return err
+		}
+		encryptedFields[field.String()] = encryptedValue
+		delete(payerData, field.String())
+	}
+	if len(encryptedFields) > 0 {
+		payerData[encryptedFieldsKey] = encryptedFields
+	}
+	return nil
+}
+
+// DecryptPayerDataFields Decrypts the payer data fields encrypted with EncryptPayerDataFields with the receiving
+// VASP's encryption private key, and restores them in the payer data. Payer data without encrypted fields is left
+// unchanged.
+//
+// Args:
+//
+//	payerData: the payer data of a pay request, modified in place.
+//	encryptionPrivateKey: the encryption private key of the receiving VASP.
+func DecryptPayerDataFields(payerData protocol.PayerData, encryptionPrivateKey []byte) error {
+	encryptedFields, err := payerDataEncryptedFields(payerData)
+	if err != nil {
+		return err
+	}
+	decryptedFields := make(map[string]interface{}, len(encryptedFields))
+	for field, encryptedValue := range encryptedFields {
+		encryptedValueHex, ok := encryptedValue.(string)
+		if !ok {
+			return fmt.Errorf("encrypted %s field must be a string", field)
+		}
+		valueJson, err := eciesDecryptHex(encryptionPrivateKey, encryptedValueHex)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt the %s field: %w", field, err)
+		}
+		var value interface{}
+		if err = json.Unmarshal(valueJson, &value); err != nil {
+			return fmt.Errorf("invalid encrypted %s field: %w", field, err)
+		}
+		decryptedFields[field] = value
+	}
+	// Only modify the payer data once every field was decrypted, so that it is left unchanged on errors.
+	for field, value := range decryptedFields {
+		payerData[field] = value
+	}
+	delete(payerData, encryptedFieldsKey)
+	return nil
+}
+
+// payerDataEncryptedFields returns the encrypted fields of payer data, or an empty map if there are none.
+func payerDataEncryptedFields(payerData protocol.PayerData) (map[string]interface{}, error) {
+	value, ok := payerData[encryptedFieldsKey]
+	if !ok || value == nil {
+		return make(map[string]interface{}), nil
+	}
+	encryptedFields, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, fmt.Errorf("%s must be an object", encryptedFieldsKey)
+	}
+	return encryptedFields, nil
+}
+
+// eciesEncryptHex encrypts data to a secp256k1 public key with ECIES, and hex-encodes the result.
+func eciesEncryptHex(encryptionPubKey []byte, data []byte) (string, error) {
+	pubKey, err := eciesgo.NewPublicKeyFromBytes(encryptionPubKey)
+	if err != nil {
+		return "", err
+	}
+	encryptedData, err := eciesgo.Encrypt(pubKey, data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(encryptedData), nil
+}
+
+// eciesDecryptHex decrypts hex-encoded data encrypted with eciesEncryptHex.
+func eciesDecryptHex(encryptionPrivateKey []byte, encryptedDataHex string) ([]byte, error) {
+	encryptedData, err := hex.DecodeString(encryptedDataHex)
+	if err != nil {
+		return nil, err
+	}
+	return eciesgo.Decrypt(eciesgo.NewPrivateKeyFromBytes(encryptionPrivateKey), encryptedData)
+}
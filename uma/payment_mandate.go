@@ -0,0 +1,347 @@
+package uma
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// SignPaymentMandate Signs a payment mandate on behalf of the sending VASP, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	mandate: the mandate approved by the sender. VaspDomain must be the domain of the payer identifier.
+//	signer: the UmaSigner of the sending VASP.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignPaymentMandate(
+	mandate protocol.PaymentMandate,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PaymentMandate, error) {
+	if err := validatePaymentMandateFields(&mandate); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	mandate.Nonce = *nonce
+	mandate.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := mandate.SignablePayload()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	mandate.Signature = *signature
+	return &mandate, nil
+}
+
+func validatePaymentMandateFields(mandate *protocol.PaymentMandate) error {
+	if mandate.MandateId == "" {
+		return errors.New("missing mandateId")
+	}
+	if mandate.PayerIdentifier == "" || mandate.PayeeIdentifier == "" {
+		return errors.New("missing payer or payee identifier")
+	}
+	if mandate.CurrencyCode == "" {
+		return errors.New("missing currency")
+	}
+	if mandate.MaxAmountPerPull <= 0 || mandate.IntervalSeconds <= 0 {
+		return errors.New("maxAmountPerPull and intervalSeconds must be positive")
+	}
+	if !strings.EqualFold(domainOfIdentifier(mandate.PayerIdentifier), mandate.VaspDomain) {
+		return PayerIdentifierDomainMismatchError{
+			PayerIdentifier:  mandate.PayerIdentifier,
+			SenderVaspDomain: mandate.VaspDomain,
+		}
+	}
+	return nil
+}
+
+// ParsePaymentMandate Parses a payment mandate from a raw request body.
+func ParsePaymentMandate(bytes []byte, opts ...Option) (*protocol.PaymentMandate, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePaymentMandate, bytes); err != nil {
+		return nil, err
+	}
+	var mandate protocol.PaymentMandate
+	if err := json.Unmarshal(bytes, &mandate); err != nil {
+		return nil, err
+	}
+	if err := validatePaymentMandateFields(&mandate); err != nil {
+		return nil, err
+	}
+	if err := o.checkStrict(MessageTypePaymentMandate, unknownJsonFields(bytes, &mandate)); err != nil {
+		return nil, err
+	}
+	mandate.RawResponse = o.rawPayload(bytes)
+	return &mandate, nil
+}
+
+// VerifyPaymentMandateSignature Verifies the signature on a payment mandate based on the public key of the sending
+// VASP.
+//
+// Args:
+//
+//	mandate: the signed mandate to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger.
+func VerifyPaymentMandateSignature(
+	mandate *protocol.PaymentMandate,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	if err := validatePaymentMandateFields(mandate); err != nil {
+		return err
+	}
+	signablePayload, err := mandate.SignablePayload()
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessage(
+		MessageTypePaymentMandate,
+		mandate.VaspDomain,
+		nonceCache,
+		mandate.Nonce,
+		time.Unix(mandate.Timestamp, 0),
+		signablePayload,
+		mandate.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SignPaymentPullRequest Signs a payment pull request on behalf of the receiving VASP, generating a fresh nonce and
+// timestamp.
+//
+// Args:
+//
+//	request: the pull request. MandateId, PullId, Amount, EncodedInvoice and VaspDomain must be set.
+//	signer: the UmaSigner of the receiving VASP.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignPaymentPullRequest(
+	request protocol.PaymentPullRequest,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PaymentPullRequest, error) {
+	if err := validatePaymentPullRequestFields(&request); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	request.Nonce = *nonce
+	request.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	request.Signature = *signature
+	return &request, nil
+}
+
+func validatePaymentPullRequestFields(request *protocol.PaymentPullRequest) error {
+	if request.MandateId == "" || request.PullId == "" {
+		return errors.New("missing mandateId or pullId")
+	}
+	if request.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if request.EncodedInvoice == "" {
+		return errors.New("missing invoice")
+	}
+	if request.VaspDomain == "" {
+		return errors.New("missing vaspDomain")
+	}
+	return nil
+}
+
+// ParsePaymentPullRequest Parses a payment pull request from a raw request body.
+func ParsePaymentPullRequest(bytes []byte, opts ...Option) (*protocol.PaymentPullRequest, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePaymentPullRequest, bytes); err != nil {
+		return nil, err
+	}
+	var request protocol.PaymentPullRequest
+	if err := json.Unmarshal(bytes, &request); err != nil {
+		return nil, err
+	}
+	if err := validatePaymentPullRequestFields(&request); err != nil {
+		return nil, err
+	}
+	if err := o.checkStrict(MessageTypePaymentPullRequest, unknownJsonFields(bytes, &request)); err != nil {
+		return nil, err
+	}
+	request.RawResponse = o.rawPayload(bytes)
+	return &request, nil
+}
+
+// VerifyPaymentPullRequestSignature Verifies the signature on a payment pull request based on the public key of the
+// receiving VASP. Use ValidatePaymentPull to check that the pull is allowed by its mandate.
+//
+// Args:
+//
+//	request: the signed pull request to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the receiving VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger.
+func VerifyPaymentPullRequestSignature(
+	request *protocol.PaymentPullRequest,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessage(
+		MessageTypePaymentPullRequest,
+		request.VaspDomain,
+		nonceCache,
+		request.Nonce,
+		time.Unix(request.Timestamp, 0),
+		signablePayload,
+		request.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// ValidatePaymentPull Checks that a pull request is allowed by its mandate: it must be sent by the payee's VASP, must
+// not exceed the maximum amount per pull, must come at least the mandate's interval after the previous pull, and the
+// mandate must not have expired. Revocations are not tracked by the SDK, so callers must also check that the mandate
+// was not revoked.
+//
+// Args:
+//
+//	mandate: the mandate the pull request refers to, as signed by the sending VASP.
+//	request: the pull request, whose signature was verified with VerifyPaymentPullRequestSignature.
+//	lastPullAt: when the previous payment was pulled under the mandate, or nil if this is the first pull.
+func ValidatePaymentPull(
+	mandate protocol.PaymentMandate,
+	request protocol.PaymentPullRequest,
+	lastPullAt *time.Time,
+) error {
+	if request.MandateId != mandate.MandateId {
+		return fmt.Errorf("pull request is for mandate %s, not %s", request.MandateId, mandate.MandateId)
+	}
+	if !strings.EqualFold(request.VaspDomain, domainOfIdentifier(mandate.PayeeIdentifier)) {
+		return fmt.Errorf("pull request from %s, but the mandate was granted to %s",
+			request.VaspDomain, mandate.PayeeIdentifier)
+	}
+	if request.Amount > mandate.MaxAmountPerPull {
+		return fmt.Errorf("amount of %d exceeds the mandate's maximum of %d %s per pull",
+			request.Amount, mandate.MaxAmountPerPull, mandate.CurrencyCode)
+	}
+	pulledAt := time.Unix(request.Timestamp, 0)
+	if !pulledAt.Before(time.Unix(mandate.ExpirationTimestamp, 0)) {
+		return errors.New("the mandate has expired")
+	}
+	if lastPullAt != nil {
+		if nextPullAt := lastPullAt.Add(time.Duration(mandate.IntervalSeconds) * time.Second); pulledAt.Before(nextPullAt) {
+			return fmt.Errorf("the next pull is not allowed before %s", nextPullAt.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// SignPaymentMandateRevocation Signs a payment mandate revocation, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	revocation: the revocation. MandateId and VaspDomain must be set.
+//	signer: the UmaSigner of the VASP revoking the mandate.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignPaymentMandateRevocation(
+	revocation protocol.PaymentMandateRevocation,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PaymentMandateRevocation, error) {
+	if revocation.MandateId == "" || revocation.VaspDomain == "" {
+		return nil, errors.New("missing mandateId or vaspDomain")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	revocation.Nonce = *nonce
+	revocation.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := revocation.SignablePayload()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	revocation.Signature = *signature
+	return &revocation, nil
+}
+
+// ParsePaymentMandateRevocation Parses a payment mandate revocation from a raw request body.
+func ParsePaymentMandateRevocation(bytes []byte, opts ...Option) (*protocol.PaymentMandateRevocation, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePaymentMandateRevocation, bytes); err != nil {
+		return nil, err
+	}
+	var revocation protocol.PaymentMandateRevocation
+	if err := json.Unmarshal(bytes, &revocation); err != nil {
+		return nil, err
+	}
+	if revocation.MandateId == "" || revocation.VaspDomain == "" {
+		return nil, errors.New("missing mandateId or vaspDomain")
+	}
+	if err := o.checkStrict(MessageTypePaymentMandateRevocation, unknownJsonFields(bytes, &revocation)); err != nil {
+		return nil, err
+	}
+	revocation.RawResponse = o.rawPayload(bytes)
+	return &revocation, nil
+}
+
+// VerifyPaymentMandateRevocationSignature Verifies the signature on a payment mandate revocation based on the public
+// key of the revoking VASP. Callers must also check that the revoking VASP is the sending or receiving VASP of the
+// mandate.
+//
+// Args:
+//
+//	revocation: the signed revocation to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the revoking VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger.
+func VerifyPaymentMandateRevocationSignature(
+	revocation *protocol.PaymentMandateRevocation,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := revocation.SignablePayload()
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessage(
+		MessageTypePaymentMandateRevocation,
+		revocation.VaspDomain,
+		nonceCache,
+		revocation.Nonce,
+		time.Unix(revocation.Timestamp, 0),
+		signablePayload,
+		revocation.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
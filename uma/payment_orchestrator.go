@@ -0,0 +1,254 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// compensationTimeout bounds the compensation hooks of SendUmaPayment, which run after the payment deadline may
+// already have passed.
+const compensationTimeout = 10 * time.Second
+
+// PaymentStage is a stage of a payment run by SendUmaPayment.
+type PaymentStage string
+
+const (
+	// PaymentStageLnurlp sends the lnurlp request and verifies the receiver's response.
+	PaymentStageLnurlp PaymentStage = "lnurlp"
+	// PaymentStagePayRequest creates and sends the pay request and verifies the receiver's response.
+	PaymentStagePayRequest PaymentStage = "payreq"
+	// PaymentStagePay pays the invoice from the pay request response.
+	PaymentStagePay PaymentStage = "pay"
+	// PaymentStagePostTransaction sends the post transaction callback for the completed payment.
+	PaymentStagePostTransaction PaymentStage = "post_transaction"
+)
+
+// PaymentStageError is returned by SendUmaPayment when a stage fails. Err is the error of the stage, which wraps
+// context.DeadlineExceeded if the payment deadline passed before the stage completed.
+type PaymentStageError struct {
+	Stage PaymentStage
+	Err   error
+	// CompensationErr is the error of the compensation hook run for the failure, if any.
+	CompensationErr error
+	// CorrelationId is the correlation ID of the payment, which the receiving VASP received with each request.
+	CorrelationId string
+}
+
+func (e *PaymentStageError) Error() string {
+	message := fmt.Sprintf("payment failed at %s stage: %v", e.Stage, e.Err)
+	if e.CompensationErr != nil {
+		message += fmt.Sprintf(" (compensation failed: %v)", e.CompensationErr)
+	}
+	if e.CorrelationId != "" {
+		message += fmt.Sprintf(" (correlation ID %s)", e.CorrelationId)
+	}
+	return message
+}
+
+func (e *PaymentStageError) Unwrap() error {
+	return e.Err
+}
+
+// SendUmaPaymentParams are the parameters of SendUmaPayment. Each hook receives the context of the payment, which is
+// cancelled when the payment deadline passes, and carries the correlation ID of the payment; see
+// CorrelationIdFromContext.
+type SendUmaPaymentParams struct {
+	// LnurlpRequest is the lnurlp request to send, signed with SignLnurlpRequest.
+	LnurlpRequest protocol.LnurlpRequest
+	// Timeout is the deadline for the whole payment, from the lnurlp request to the post transaction callback. If
+	// zero, only the deadline of the context passed to SendUmaPayment applies.
+	Timeout time.Duration
+	// VerifyLnurlpResponse verifies the receiver's lnurlp response, for example with
+	// VerifyUmaLnurlpResponseSignature.
+	VerifyLnurlpResponse func(ctx context.Context, response protocol.UmaLnurlpResponse) error
+	// CreatePayRequest creates the signed pay request for the receiver, for example with GetUmaPayRequest.
+	CreatePayRequest func(ctx context.Context, lnurlpResponse protocol.UmaLnurlpResponse) (*protocol.PayRequest, error)
+	// VerifyPayReqResponse verifies the receiver's pay request response, for example with
+	// VerifyPayReqResponseSignature and VerifyPayReqResponse. If it fails, CancelPayRequest is called.
+	VerifyPayReqResponse func(ctx context.Context, request *protocol.PayRequest, response *protocol.PayReqResponse) error
+	// PayInvoice pays the invoice of the pay request response and returns its hex-encoded payment hash. It must only
+	// return an error if the payment definitely did not complete, since CancelPayRequest is called on failure.
+	PayInvoice func(ctx context.Context, response *protocol.PayReqResponse) (paymentHash string, err error)
+	// SendPostTransactionCallback optionally sends the post transaction callback for the completed payment, for example
+	// with GetPostTransactionCallbackForPayment.
+	SendPostTransactionCallback func(ctx context.Context, response *protocol.PayReqResponse, paymentHash string) error
+	// CancelPayRequest optionally compensates for a payment which failed after the receiver created an invoice, for
+	// example with CancelPayRequestCompensation. It is called with a context which is not cancelled by the payment
+	// deadline.
+	CancelPayRequest func(ctx context.Context, request *protocol.PayRequest, response *protocol.PayReqResponse) error
+}
+
+// SendUmaPaymentResult is the result of a payment completed by SendUmaPayment.
+type SendUmaPaymentResult struct {
+	// LnurlpResponse is the receiver's verified lnurlp response.
+	LnurlpResponse *protocol.UmaLnurlpResponse
+	// PayRequest is the pay request sent to the receiver.
+	PayRequest *protocol.PayRequest
+	// PayReqResponse is the receiver's verified pay request response.
+	PayReqResponse *protocol.PayReqResponse
+	// PaymentHash is the hex-encoded payment hash returned by PayInvoice.
+	PaymentHash string
+	// CorrelationId is the correlation ID of the payment, which the receiving VASP received with each request.
+	CorrelationId string
+}
+
+// SendUmaPayment Runs an UMA payment from the lnurlp request to the post transaction callback within a single
+// deadline. If a stage fails, a *PaymentStageError reports which one, and if the receiver had already created an
+// invoice, CancelPayRequest is called so that the receiver can release its quote. Once the invoice is paid the
+// payment cannot be compensated, so a failed post transaction callback is returned along with the result.
+//
+// Every request of the payment carries the same correlation ID in the CorrelationIdHeader, which is reported on the
+// result and on a *PaymentStageError. It is the ID set with WithCorrelationId, or else carried by ctx, or else
+// generated with GenerateCorrelationId.
+//
+// Args:
+//
+//	ctx: the context for the payment.
+//	params: the parameters and hooks of the payment.
+//	opts: optional settings for the outbound requests, such as WithRequestDoer, WithRetryPolicy and
+//		WithCorrelationId.
+func SendUmaPayment(
+	ctx context.Context,
+	params SendUmaPaymentParams,
+	opts ...Option,
+) (result *SendUmaPaymentResult, err error) {
+	if params.VerifyLnurlpResponse == nil || params.CreatePayRequest == nil || params.VerifyPayReqResponse == nil ||
+		params.PayInvoice == nil {
+		return nil, errors.New("missing VerifyLnurlpResponse, CreatePayRequest, VerifyPayReqResponse or PayInvoice")
+	}
+	o := newOptions(opts)
+	correlationId := o.correlationIdFor(ctx)
+	if correlationId == "" {
+		correlationId = GenerateCorrelationId()
+	}
+	ctx = ContextWithCorrelationId(ctx, correlationId)
+	ctx, span := o.startSpan(ctx, "uma.SendUmaPayment",
+		attribute.String("uma.receiver", params.LnurlpRequest.ReceiverAddress))
+	defer func() { endSpan(span, err) }()
+	paymentCtx := ctx
+	if params.Timeout > 0 {
+		var cancel context.CancelFunc
+		paymentCtx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
+
+	result = &SendUmaPaymentResult{CorrelationId: correlationId}
+	if err = runPaymentStage(paymentCtx, func() error {
+		lnurlpResponse, err := SendLnurlpRequest(paymentCtx, params.LnurlpRequest, opts...)
+		if err != nil {
+			return err
+		}
+		result.LnurlpResponse = lnurlpResponse.AsUmaResponse()
+		if result.LnurlpResponse == nil {
+			return ErrNotUmaReceiver
+		}
+		return params.VerifyLnurlpResponse(paymentCtx, *result.LnurlpResponse)
+	}); err != nil {
+		return nil, &PaymentStageError{Stage: PaymentStageLnurlp, Err: err, CorrelationId: correlationId}
+	}
+
+	if err = runPaymentStage(paymentCtx, func() error {
+		request, err := params.CreatePayRequest(paymentCtx, *result.LnurlpResponse)
+		if err != nil {
+			return err
+		}
+		result.PayRequest = request
+		result.PayReqResponse, err = SendPayRequest(paymentCtx, result.LnurlpResponse.Callback, request, opts...)
+		return err
+	}); err != nil {
+		return nil, &PaymentStageError{Stage: PaymentStagePayRequest, Err: err, CorrelationId: correlationId}
+	}
+	if err = runPaymentStage(paymentCtx, func() error {
+		return params.VerifyPayReqResponse(paymentCtx, result.PayRequest, result.PayReqResponse)
+	}); err != nil {
+		return nil, o.compensatePayment(ctx, params, result, PaymentStagePayRequest, err)
+	}
+
+	if err = runPaymentStage(paymentCtx, func() error {
+		paymentHash, err := params.PayInvoice(paymentCtx, result.PayReqResponse)
+		result.PaymentHash = paymentHash
+		return err
+	}); err != nil {
+		return nil, o.compensatePayment(ctx, params, result, PaymentStagePay, err)
+	}
+
+	if params.SendPostTransactionCallback != nil {
+		if err = runPaymentStage(paymentCtx, func() error {
+			return params.SendPostTransactionCallback(paymentCtx, result.PayReqResponse, result.PaymentHash)
+		}); err != nil {
+			return result, &PaymentStageError{Stage: PaymentStagePostTransaction, Err: err, CorrelationId: correlationId}
+		}
+	}
+	return result, nil
+}
+
+// runPaymentStage runs a stage of SendUmaPayment, failing with the context error if the deadline has already passed.
+func runPaymentStage(ctx context.Context, stage func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := stage(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			return fmt.Errorf("%w: %w", ctxErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// compensatePayment calls CancelPayRequest for a payment which failed after the receiver created an invoice.
+func (o *options) compensatePayment(
+	ctx context.Context,
+	params SendUmaPaymentParams,
+	result *SendUmaPaymentResult,
+	stage PaymentStage,
+	err error,
+) error {
+	stageErr := &PaymentStageError{Stage: stage, Err: err, CorrelationId: result.CorrelationId}
+	if params.CancelPayRequest == nil {
+		return stageErr
+	}
+	compensationCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compensationTimeout)
+	defer cancel()
+	stageErr.CompensationErr = params.CancelPayRequest(compensationCtx, result.PayRequest, result.PayReqResponse)
+	if stageErr.CompensationErr != nil {
+		o.log(ctx, slog.LevelError, LogEventPaymentCompensationFailed, slog.String("stage", string(stage)),
+			slog.String("error", stageErr.CompensationErr.Error()))
+	}
+	return stageErr
+}
+
+// CancelPayRequestCompensation Creates a CancelPayRequest hook for SendUmaPayment which sends a signed
+// PayReqCancellation for the invoice to the receiving VASP.
+//
+// Args:
+//
+//	cancellationUrl: the URL at which the receiving VASP accepts pay request cancellations.
+//	vaspDomain: the domain of the sending VASP.
+//	signer: the UmaSigner of the sending VASP.
+//	opts: optional settings such as WithRequestDoer and WithRetryPolicy.
+func CancelPayRequestCompensation(
+	cancellationUrl string,
+	vaspDomain string,
+	signer UmaSigner,
+	opts ...Option,
+) func(ctx context.Context, request *protocol.PayRequest, response *protocol.PayReqResponse) error {
+	return func(ctx context.Context, _ *protocol.PayRequest, response *protocol.PayReqResponse) error {
+		reason := "payment failed"
+		cancellation, err := SignPayReqCancellation(protocol.PayReqCancellation{
+			EncodedInvoice: response.EncodedInvoice,
+			Reason:         &reason,
+			VaspDomain:     vaspDomain,
+		}, signer, opts...)
+		if err != nil {
+			return err
+		}
+		return SendPayReqCancellation(ctx, cancellationUrl, *cancellation, opts...)
+	}
+}
@@ -0,0 +1,325 @@
+package uma
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// maxPaymentReceiptBytes is the largest receipt body accepted by PaymentReceiptHandler.
+const maxPaymentReceiptBytes = 64 * 1024
+
+// GetPaymentHash Extracts the hex-encoded payment hash from a BOLT11 invoice, e.g. the EncodedInvoice of a pay
+// request response, so that the payment can be matched with the payment reported by the Lightning node.
+func GetPaymentHash(encodedInvoice string) (string, error) {
+	invoice, err := utils.DecodeBolt11(encodedInvoice)
+	if err != nil {
+		return "", err
+	}
+	if invoice.PaymentHash == nil {
+		return "", errors.New("invoice has no payment hash")
+	}
+	return hex.EncodeToString(invoice.PaymentHash), nil
+}
+
+// VerifyPaymentPreimage Checks that a hex-encoded payment preimage hashes to a hex-encoded payment hash, i.e. that it
+// proves that the payment was made.
+func VerifyPaymentPreimage(paymentHash string, preimage string) error {
+	preimageBytes, err := hex.DecodeString(preimage)
+	if err != nil {
+		return fmt.Errorf("invalid preimage: %w", err)
+	}
+	expectedHash, err := hex.DecodeString(paymentHash)
+	if err != nil {
+		return fmt.Errorf("invalid payment hash: %w", err)
+	}
+	hash := sha256.Sum256(preimageBytes)
+	if !bytes.Equal(hash[:], expectedHash) {
+		return errors.New("preimage does not match the payment hash")
+	}
+	return nil
+}
+
+// NewPaymentReceipt Creates an unsigned receipt for a settled payment from the pay request and the receiving VASP's
+// response to it. Sign it with SignPaymentReceipt before persisting or sending it.
+//
+// Args:
+//
+//	payRequest: the pay request of the payment.
+//	payReqResponse: the receiving VASP's response, holding the invoice that was paid.
+//	preimage: the hex-encoded payment preimage, if known.
+//	settledAt: the time at which the payment settled.
+//	vaspDomain: the domain of the VASP creating the receipt, i.e. of the sender or the receiver.
+func NewPaymentReceipt(
+	payRequest protocol.PayRequest,
+	payReqResponse protocol.PayReqResponse,
+	preimage *string,
+	settledAt time.Time,
+	vaspDomain string,
+) (*protocol.PaymentReceipt, error) {
+	invoice, err := utils.DecodeBolt11(payReqResponse.EncodedInvoice)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode invoice: %w", err)
+	}
+	if invoice.PaymentHash == nil || invoice.AmountMsats == nil {
+		return nil, errors.New("invoice has no payment hash or amount")
+	}
+	senderIdentifier := payRequest.PayerData.Identifier()
+	receiverIdentifier := payReqResponse.PayeeData.Identifier()
+	if senderIdentifier == nil || receiverIdentifier == nil {
+		return nil, errors.New("missing payer or payee identifier")
+	}
+	receipt := protocol.PaymentReceipt{
+		PaymentHash:        hex.EncodeToString(invoice.PaymentHash),
+		Preimage:           preimage,
+		EncodedInvoice:     payReqResponse.EncodedInvoice,
+		AmountMsats:        *invoice.AmountMsats,
+		SenderIdentifier:   *senderIdentifier,
+		ReceiverIdentifier: *receiverIdentifier,
+		SettledAt:          settledAt.Unix(),
+		VaspDomain:         vaspDomain,
+	}
+	if payReqResponse.PaymentInfo != nil {
+		receipt.ReceivedAmount = payReqResponse.PaymentInfo.Amount
+		receipt.ReceivedCurrencyCode = &payReqResponse.PaymentInfo.CurrencyCode
+	}
+	if err = validatePaymentReceiptFields(&receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// SignPaymentReceipt Signs a payment receipt, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	receipt: the receipt to sign, e.g. created with NewPaymentReceipt.
+//	signer: the UmaSigner of the VASP with the receipt's VaspDomain.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithSignatureScheme.
+func SignPaymentReceipt(
+	receipt protocol.PaymentReceipt,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PaymentReceipt, error) {
+	if err := validatePaymentReceiptFields(&receipt); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	receipt.Nonce = *nonce
+	receipt.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := o.signablePayload(MessageTypePaymentReceipt, receipt.SignablePayload, &receipt, "signature")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Signature = *signature
+	return &receipt, nil
+}
+
+func validatePaymentReceiptFields(receipt *protocol.PaymentReceipt) error {
+	if receipt.EncodedInvoice == "" {
+		return errors.New("missing invoice")
+	}
+	if receipt.SenderIdentifier == "" || receipt.ReceiverIdentifier == "" {
+		return errors.New("missing sender or receiver identifier")
+	}
+	if !strings.EqualFold(domainOfIdentifier(receipt.SenderIdentifier), receipt.VaspDomain) &&
+		!strings.EqualFold(domainOfIdentifier(receipt.ReceiverIdentifier), receipt.VaspDomain) {
+		return fmt.Errorf("vaspDomain %s is neither the sender's nor the receiver's domain", receipt.VaspDomain)
+	}
+	paymentHash, err := GetPaymentHash(receipt.EncodedInvoice)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(paymentHash, receipt.PaymentHash) {
+		return errors.New("paymentHash does not match the invoice")
+	}
+	if receipt.Preimage != nil {
+		return VerifyPaymentPreimage(receipt.PaymentHash, *receipt.Preimage)
+	}
+	return nil
+}
+
+// ParsePaymentReceipt Parses a payment receipt from a raw request body. The payment hash is checked against the
+// invoice, and the preimage, if any, against the payment hash.
+func ParsePaymentReceipt(bytes []byte, opts ...Option) (*protocol.PaymentReceipt, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePaymentReceipt, bytes); err != nil {
+		return nil, err
+	}
+	var receipt protocol.PaymentReceipt
+	if err := json.Unmarshal(bytes, &receipt); err != nil {
+		return nil, err
+	}
+	if err := validatePaymentReceiptFields(&receipt); err != nil {
+		return nil, err
+	}
+	if err := o.checkStrict(MessageTypePaymentReceipt, unknownJsonFields(bytes, &receipt)); err != nil {
+		return nil, err
+	}
+	receipt.RawResponse = o.rawPayload(bytes)
+	return &receipt, nil
+}
+
+// VerifyPaymentReceiptSignature Verifies the signature on a payment receipt based on the public key of the VASP which
+// signed it.
+//
+// Args:
+//
+//	receipt: the signed receipt to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the VASP with the receipt's VaspDomain.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger and WithAcceptedSignatureSchemes.
+func VerifyPaymentReceiptSignature(
+	receipt *protocol.PaymentReceipt,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := receipt.SignablePayload()
+	if err != nil {
+		return err
+	}
+	payloads, err := schemePayloads(signablePayload, receipt, "signature")
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessageWithSchemes(
+		MessageTypePaymentReceipt,
+		receipt.VaspDomain,
+		nonceCache,
+		receipt.Nonce,
+		time.Unix(receipt.Timestamp, 0),
+		payloads,
+		receipt.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SendPaymentReceipt Sends a signed payment receipt to the counterparty VASP.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	receiptUrl: the URL at which the counterparty VASP accepts payment receipts.
+//	receipt: the receipt to send, signed with SignPaymentReceipt.
+//	opts: optional settings such as WithRequestDoer and WithRetryPolicy.
+func SendPaymentReceipt(ctx context.Context, receiptUrl string, receipt protocol.PaymentReceipt, opts ...Option) error {
+	o := newOptions(opts)
+	outbound, err := newPaymentReceiptOutboundRequest(receiptUrl, receipt)
+	if err != nil {
+		return err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPaymentReceiptSent, slog.String("url", redactQuery(receiptUrl)))
+	_, err = o.sendRequest(ctx, *outbound)
+	return err
+}
+
+func newPaymentReceiptOutboundRequest(receiptUrl string, receipt protocol.PaymentReceipt) (*outboundRequest, error) {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundRequest{
+		method: http.MethodPost,
+		url:    receiptUrl,
+		body:   body,
+		// Receipts describe a settled payment, so receiving the same one twice has no further effect.
+		idempotent: true,
+		resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedReceipt, err := SignPaymentReceipt(receipt, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newPaymentReceiptOutboundRequest(receiptUrl, *resignedReceipt)
+		},
+	}, nil
+}
+
+// PaymentReceiptFunc handles a verified payment receipt, e.g. by persisting it next to the VASP's own record of the
+// payment. Returning an error responds to the counterparty VASP with an internal server error, so that it can retry
+// later.
+type PaymentReceiptFunc func(ctx context.Context, receipt *protocol.PaymentReceipt) error
+
+// PaymentReceiptHandler is an http.Handler which receives payment receipts from counterparty VASPs. It parses each
+// receipt, fetches the signing VASP's public keys, verifies the signature and passes the receipt on to a
+// PaymentReceiptFunc.
+type PaymentReceiptHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	onReceipt      PaymentReceiptFunc
+	opts           []Option
+}
+
+// NewPaymentReceiptHandler Creates a PaymentReceiptHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the signing VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	onReceipt: called with each verified receipt.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewPaymentReceiptHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	onReceipt PaymentReceiptFunc,
+	opts ...Option,
+) *PaymentReceiptHandler {
+	return &PaymentReceiptHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		onReceipt:      onReceipt,
+		opts:           opts,
+	}
+}
+
+func (h *PaymentReceiptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodPost {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPaymentReceiptBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	receipt, err := ParsePaymentReceipt(body, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(r.Context(), receipt.VaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+		return
+	}
+	if err = VerifyPaymentReceiptSignature(receipt, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = h.onReceipt(r.Context(), receipt); err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusOK, nil)
+}
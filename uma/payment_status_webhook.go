@@ -0,0 +1,238 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxPaymentStatusWebhookBytes is the largest webhook body accepted by PaymentStatusWebhookHandler.
+const maxPaymentStatusWebhookBytes = 64 * 1024
+
+// SignPaymentStatusWebhook Signs a payment status webhook, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	webhook: the webhook to sign. EncodedInvoice, Status and VaspDomain must be set.
+//	signer: the UmaSigner of the VASP sending the webhook.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithSignatureScheme.
+func SignPaymentStatusWebhook(
+	webhook protocol.PaymentStatusWebhook,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PaymentStatusWebhook, error) {
+	if webhook.VaspDomain == "" {
+		return nil, errors.New("missing vaspDomain")
+	}
+	if webhook.EncodedInvoice == "" {
+		return nil, errors.New("missing invoice")
+	}
+	if !webhook.Status.IsValid() {
+		return nil, fmt.Errorf("invalid payment status: %s", webhook.Status)
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	webhook.Nonce = *nonce
+	webhook.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := o.signablePayload(MessageTypePaymentStatusWebhook, webhook.SignablePayload, &webhook, "signature")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Signature = *signature
+	return &webhook, nil
+}
+
+// ParsePaymentStatusWebhook Parses a payment status webhook from a raw request body.
+func ParsePaymentStatusWebhook(bytes []byte, opts ...Option) (*protocol.PaymentStatusWebhook, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePaymentStatusWebhook, bytes); err != nil {
+		return nil, err
+	}
+	var webhook protocol.PaymentStatusWebhook
+	err := json.Unmarshal(bytes, &webhook)
+	if err != nil {
+		return nil, err
+	}
+	if !webhook.Status.IsValid() {
+		return nil, fmt.Errorf("invalid payment status: %s", webhook.Status)
+	}
+	if err = o.checkStrict(MessageTypePaymentStatusWebhook, unknownJsonFields(bytes, &webhook)); err != nil {
+		return nil, err
+	}
+	webhook.RawResponse = o.rawPayload(bytes)
+	return &webhook, nil
+}
+
+// VerifyPaymentStatusWebhookSignature Verifies the signature on a payment status webhook based on the public key of
+// the counterparty VASP.
+//
+// Args:
+//
+//	webhook: the signed webhook to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the VASP sending the webhook.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger and WithAcceptedSignatureSchemes.
+func VerifyPaymentStatusWebhookSignature(
+	webhook *protocol.PaymentStatusWebhook,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := webhook.SignablePayload()
+	if err != nil {
+		return err
+	}
+	payloads, err := schemePayloads(signablePayload, webhook, "signature")
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessageWithSchemes(
+		MessageTypePaymentStatusWebhook,
+		webhook.VaspDomain,
+		nonceCache,
+		webhook.Nonce,
+		time.Unix(webhook.Timestamp, 0),
+		payloads,
+		webhook.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SendPaymentStatusWebhook Sends a signed payment status webhook to the counterparty VASP.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	webhookUrl: the URL at which the counterparty VASP accepts payment status webhooks.
+//	webhook: the webhook to send, signed with SignPaymentStatusWebhook.
+//	opts: optional settings such as WithRequestDoer and WithRetryPolicy.
+func SendPaymentStatusWebhook(
+	ctx context.Context,
+	webhookUrl string,
+	webhook protocol.PaymentStatusWebhook,
+	opts ...Option,
+) error {
+	o := newOptions(opts)
+	outbound, err := newPaymentStatusWebhookOutboundRequest(webhookUrl, webhook)
+	if err != nil {
+		return err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPaymentStatusWebhookSent,
+		slog.String("url", redactQuery(webhookUrl)), slog.String("status", string(webhook.Status)))
+	_, err = o.sendRequest(ctx, *outbound)
+	return err
+}
+
+func newPaymentStatusWebhookOutboundRequest(webhookUrl string, webhook protocol.PaymentStatusWebhook) (*outboundRequest, error) {
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundRequest{
+		method: http.MethodPost,
+		url:    webhookUrl,
+		body:   body,
+		// Receivers should treat repeated statuses for the same invoice as duplicates.
+		idempotent: true,
+		resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedWebhook, err := SignPaymentStatusWebhook(webhook, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newPaymentStatusWebhookOutboundRequest(webhookUrl, *resignedWebhook)
+		},
+	}, nil
+}
+
+// PaymentStatusWebhookFunc handles a verified payment status webhook. Returning an error responds to the sending
+// VASP with an internal server error, so that it can retry later.
+type PaymentStatusWebhookFunc func(ctx context.Context, webhook *protocol.PaymentStatusWebhook) error
+
+// PaymentStatusWebhookHandler is an http.Handler which receives payment status webhooks from counterparty VASPs. It
+// parses each webhook, fetches the sender's public keys, verifies the signature and passes the webhook on to a
+// PaymentStatusWebhookFunc.
+type PaymentStatusWebhookHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	onStatus       PaymentStatusWebhookFunc
+	opts           []Option
+}
+
+// NewPaymentStatusWebhookHandler Creates a PaymentStatusWebhookHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	onStatus: called with each verified webhook.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewPaymentStatusWebhookHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	onStatus PaymentStatusWebhookFunc,
+	opts ...Option,
+) *PaymentStatusWebhookHandler {
+	return &PaymentStatusWebhookHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		onStatus:       onStatus,
+		opts:           opts,
+	}
+}
+
+func (h *PaymentStatusWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodPost {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPaymentStatusWebhookBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	webhook, err := ParsePaymentStatusWebhook(body, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(r.Context(), webhook.VaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+		return
+	}
+	if err = VerifyPaymentStatusWebhookSignature(webhook, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = h.onStatus(r.Context(), webhook); err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusOK, nil)
+}
+
+// writeStatusResponse writes an LNURL-style status response, with the reason if err is non-nil.
+func writeStatusResponse(w http.ResponseWriter, statusCode int, err error) {
+	response := map[string]string{"status": "OK"}
+	if err != nil {
+		response = map[string]string{"status": "ERROR", "reason": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,233 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxPayReqCancellationBytes is the largest cancellation body accepted by PayReqCancellationHandler.
+const maxPayReqCancellationBytes = 64 * 1024
+
+// SignPayReqCancellation Signs a pay request cancellation, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	cancellation: the cancellation to sign. EncodedInvoice and VaspDomain must be set.
+//	signer: the UmaSigner of the sending VASP.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithSignatureScheme.
+func SignPayReqCancellation(
+	cancellation protocol.PayReqCancellation,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PayReqCancellation, error) {
+	if err := validatePayReqCancellationFields(&cancellation); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	cancellation.Nonce = *nonce
+	cancellation.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := o.signablePayload(MessageTypePayReqCancellation, cancellation.SignablePayload, &cancellation, "signature")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	cancellation.Signature = *signature
+	return &cancellation, nil
+}
+
+func validatePayReqCancellationFields(cancellation *protocol.PayReqCancellation) error {
+	if cancellation.VaspDomain == "" {
+		return errors.New("missing vaspDomain")
+	}
+	if cancellation.EncodedInvoice == "" {
+		return errors.New("missing invoice")
+	}
+	return nil
+}
+
+// ParsePayReqCancellation Parses a pay request cancellation from a raw request body.
+func ParsePayReqCancellation(bytes []byte, opts ...Option) (*protocol.PayReqCancellation, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePayReqCancellation, bytes); err != nil {
+		return nil, err
+	}
+	var cancellation protocol.PayReqCancellation
+	if err := json.Unmarshal(bytes, &cancellation); err != nil {
+		return nil, err
+	}
+	if err := validatePayReqCancellationFields(&cancellation); err != nil {
+		return nil, err
+	}
+	if err := o.checkStrict(MessageTypePayReqCancellation, unknownJsonFields(bytes, &cancellation)); err != nil {
+		return nil, err
+	}
+	cancellation.RawResponse = o.rawPayload(bytes)
+	return &cancellation, nil
+}
+
+// VerifyPayReqCancellationSignature Verifies the signature on a pay request cancellation based on the public key of
+// the sending VASP. Receiving VASPs must also check that the invoice was created for a pay request from that VASP
+// before releasing anything held for it.
+//
+// Args:
+//
+//	cancellation: the signed cancellation to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger and WithAcceptedSignatureSchemes.
+func VerifyPayReqCancellationSignature(
+	cancellation *protocol.PayReqCancellation,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := cancellation.SignablePayload()
+	if err != nil {
+		return err
+	}
+	payloads, err := schemePayloads(signablePayload, cancellation, "signature")
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessageWithSchemes(
+		MessageTypePayReqCancellation,
+		cancellation.VaspDomain,
+		nonceCache,
+		cancellation.Nonce,
+		time.Unix(cancellation.Timestamp, 0),
+		payloads,
+		cancellation.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SendPayReqCancellation Sends a signed pay request cancellation to the receiving VASP.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	cancellationUrl: the URL at which the receiving VASP accepts pay request cancellations.
+//	cancellation: the cancellation to send, signed with SignPayReqCancellation.
+//	opts: optional settings such as WithRequestDoer and WithRetryPolicy.
+func SendPayReqCancellation(
+	ctx context.Context,
+	cancellationUrl string,
+	cancellation protocol.PayReqCancellation,
+	opts ...Option,
+) error {
+	o := newOptions(opts)
+	outbound, err := newPayReqCancellationOutboundRequest(cancellationUrl, cancellation)
+	if err != nil {
+		return err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPayReqCancellationSent, slog.String("url", redactQuery(cancellationUrl)))
+	_, err = o.sendRequest(ctx, *outbound)
+	return err
+}
+
+func newPayReqCancellationOutboundRequest(
+	cancellationUrl string,
+	cancellation protocol.PayReqCancellation,
+) (*outboundRequest, error) {
+	body, err := json.Marshal(cancellation)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundRequest{
+		method: http.MethodPost,
+		url:    cancellationUrl,
+		body:   body,
+		// Cancelling the same invoice twice has no further effect.
+		idempotent: true,
+		resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedCancellation, err := SignPayReqCancellation(cancellation, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newPayReqCancellationOutboundRequest(cancellationUrl, *resignedCancellation)
+		},
+	}, nil
+}
+
+// PayReqCancellationFunc handles a verified pay request cancellation, e.g. by releasing the quote for its invoice.
+// Returning an error responds to the sending VASP with an internal server error, so that it can retry later.
+type PayReqCancellationFunc func(ctx context.Context, cancellation *protocol.PayReqCancellation) error
+
+// PayReqCancellationHandler is an http.Handler which receives pay request cancellations from sending VASPs. It parses
+// each cancellation, fetches the sender's public keys, verifies the signature and passes the cancellation on to a
+// PayReqCancellationFunc.
+type PayReqCancellationHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	onCancel       PayReqCancellationFunc
+	opts           []Option
+}
+
+// NewPayReqCancellationHandler Creates a PayReqCancellationHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	onCancel: called with each verified cancellation.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewPayReqCancellationHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	onCancel PayReqCancellationFunc,
+	opts ...Option,
+) *PayReqCancellationHandler {
+	return &PayReqCancellationHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		onCancel:       onCancel,
+		opts:           opts,
+	}
+}
+
+func (h *PayReqCancellationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodPost {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayReqCancellationBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	cancellation, err := ParsePayReqCancellation(body, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(r.Context(), cancellation.VaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+		return
+	}
+	if err = VerifyPayReqCancellationSignature(cancellation, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = h.onCancel(r.Context(), cancellation); err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,315 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxPayRequestBytes is the largest pay request body accepted by PayRequestHandler.
+const maxPayRequestBytes = 64 * 1024
+
+// PayReqResponseFunc creates the response to a verified pay request, for example with GetPayReqResponseWithSigner.
+// Returning an error responds with an internal server error.
+type PayReqResponseFunc func(ctx context.Context, request *protocol.PayRequest) (*protocol.PayReqResponse, error)
+
+// PayRequestHandler is an http.Handler which serves the pay request callback of a receiving VASP. It parses each pay
+// request, from the JSON body of POST requests or the query of GET requests by non-UMA senders, fetches the sender's
+// public keys and verifies the signature of UMA requests, and responds with the response from a PayReqResponseFunc.
+//
+// VASPs running several instances should set a distributed Locker with WithLocker, and an IdempotencyStore with
+// WithIdempotencyStore. Each pay request is then processed on one instance at a time, keyed by the verified domain of
+// its sender and its idempotency key or signature nonce, and retries get the original response, so that no pay
+// request gets two invoices. The signature is verified before a stored response is returned, and a key reused for a
+// different pay request is rejected with a conflict.
+//
+// To protect the receiver's node from unbounded invoice creation, set WithSenderConcurrencyLimit,
+// WithVerificationConcurrencyLimit and WithInvoiceQuota. To screen payments before an invoice is created, set
+// WithComplianceDecider, and to hold them for review, WithHeldPayRequestStore.
+type PayRequestHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	respond        PayReqResponseFunc
+	opts           []Option
+}
+
+// NewPayRequestHandler Creates a PayRequestHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the sending VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	respond: called with each verified request to create its response.
+//	opts: optional settings such as WithLocker, WithIdempotencyStore, WithSenderConcurrencyLimit,
+//		WithVerificationConcurrencyLimit, WithInvoiceQuota, WithComplianceDecider and WithHeldPayRequestStore, and
+//		settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewPayRequestHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	respond PayReqResponseFunc,
+	opts ...Option,
+) *PayRequestHandler {
+	return &PayRequestHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		respond:        respond,
+		opts:           opts,
+	}
+}
+
+// payRequestHandlerError is an error of a PayRequestHandler with the status code to respond with.
+type payRequestHandlerError struct {
+	statusCode int
+	err        error
+}
+
+func (e payRequestHandlerError) Error() string {
+	return e.err.Error()
+}
+
+func (e payRequestHandlerError) Unwrap() error {
+	return e.err
+}
+
+func (h *PayRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	o := newOptions(opts)
+	if retrievalToken := r.URL.Query().Get(retrievalTokenParam); r.Method == http.MethodGet && retrievalToken != "" &&
+		o.heldPayRequestStore != nil {
+		o.serveHeldPayRequest(w, r, retrievalToken)
+		return
+	}
+
+	var request *protocol.PayRequest
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		request, err = protocol.ParsePayRequestFromQueryParams(r.URL.Query())
+	case http.MethodPost:
+		var body []byte
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayRequestBytes))
+		if err == nil {
+			request, err = ParsePayRequest(body, opts...)
+		}
+	default:
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	releaseVerification := func() {}
+	if o.verificationConcurrencyLimiter != nil {
+		releaseVerification, err = o.verificationConcurrencyLimiter.acquire("")
+		if err != nil {
+			writeStatusResponse(w, http.StatusTooManyRequests, err)
+			return
+		}
+	}
+	senderDomain, err := h.verifySender(ctx, request, opts)
+	releaseVerification()
+	if err != nil {
+		writeStatusResponse(w, statusCodeOfPayRequestError(err), err)
+		return
+	}
+	if o.senderConcurrencyLimiter != nil {
+		release, err := o.senderConcurrencyLimiter.acquire(senderDomain)
+		if err != nil {
+			writeStatusResponse(w, http.StatusTooManyRequests, err)
+			return
+		}
+		defer release()
+	}
+	receiverKey := ""
+	if o.invoiceQuota != nil {
+		receiverKey = o.invoiceQuotaReceiverKey(r)
+	}
+	if lockKey := payRequestLockKey(senderDomain, request); o.locker != nil && lockKey != "" {
+		unlock, err := o.locker.Lock(ctx, lockKey, defaultLockTtl)
+		if err != nil {
+			writeStatusResponse(w, http.StatusServiceUnavailable, fmt.Errorf("failed to lock pay request: %w", err))
+			return
+		}
+		defer unlock()
+	}
+
+	process := func() ([]byte, error) {
+		return h.process(ctx, o, request, receiverKey)
+	}
+	var responseBody []byte
+	if o.idempotencyStore != nil && request.IdempotencyKey != nil {
+		var requestHash string
+		requestHash, err = payRequestHash(request)
+		if err == nil {
+			responseBody, err = processIdempotentlyForSender(
+				ctx, o.idempotencyStore, senderDomain, request.IdempotencyKey, requestHash, process)
+		}
+	} else {
+		responseBody, err = process()
+	}
+	var pendingErr payRequestPendingError
+	if errors.As(err, &pendingErr) {
+		writeJsonResponse(w, http.StatusAccepted, pendingErr.response)
+		return
+	}
+	if err != nil {
+		writeStatusResponse(w, statusCodeOfPayRequestError(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(responseBody)
+}
+
+// statusCodeOfPayRequestError returns the status code to respond to a pay request which failed with err.
+func statusCodeOfPayRequestError(err error) int {
+	var handlerErr payRequestHandlerError
+	switch {
+	case errors.As(err, &handlerErr):
+		return handlerErr.statusCode
+	case errors.Is(err, ErrIdempotentRequestInProgress), errors.Is(err, ErrIdempotencyKeyReused):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvoiceQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrCorridorRestricted):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// verifySender fetches the public keys of the VASP sending an UMA pay request and verifies its signature, and returns
+// the domain of the VASP. It returns an empty domain for non-UMA pay requests, which are not signed. The nonce is
+// checked by process, so that retries of a request which already has a stored response are not rejected as replays.
+func (h *PayRequestHandler) verifySender(ctx context.Context, request *protocol.PayRequest, opts []Option) (string, error) {
+	if !request.IsUmaRequest() {
+		return "", nil
+	}
+	vaspDomain, err := GetVaspDomainFromUmaAddress(*request.PayerData.Identifier())
+	if err != nil {
+		return "", payRequestHandlerError{http.StatusBadRequest, err}
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(ctx, vaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		return "", payRequestHandlerError{http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err)}
+	}
+	o := newOptions(append(opts[:len(opts):len(opts)], WithSenderVaspDomain(vaspDomain)))
+	if err = o.verifyPayReqSignature(request, *pubKeyResponse, nil); err != nil {
+		return "", payRequestHandlerError{http.StatusBadRequest, err}
+	}
+	return vaspDomain, nil
+}
+
+// process checks the nonce of a verified pay request, consumes the invoice quota of its receiver and creates its
+// encoded response.
+func (h *PayRequestHandler) process(
+	ctx context.Context,
+	o *options,
+	request *protocol.PayRequest,
+	receiverKey string,
+) ([]byte, error) {
+	if request.IsUmaRequest() {
+		if err := o.checkPayRequestNonce(ctx, request, h.nonceCache); err != nil {
+			return nil, payRequestHandlerError{http.StatusBadRequest, err}
+		}
+	}
+	if err := o.decideCompliance(ctx, request); err != nil {
+		return nil, err
+	}
+	if o.invoiceQuota != nil {
+		if err := o.invoiceQuota.ConsumeInvoice(ctx, receiverKey); err != nil {
+			return nil, err
+		}
+	}
+	response, err := h.respond(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(response)
+}
+
+// checkPayRequestNonce checks and saves the nonce of an UMA pay request whose signature was verified.
+func (o *options) checkPayRequestNonce(ctx context.Context, request *protocol.PayRequest, nonceCache NonceCache) error {
+	if nonceCache == nil {
+		return ErrNonceCacheRequired
+	}
+	complianceData, err := request.PayerData.Compliance()
+	if err != nil {
+		return err
+	}
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return err
+	}
+	return o.checkNonce(
+		ctx,
+		MessageTypePayRequest,
+		domainOfIdentifier(*request.PayerData.Identifier()),
+		nonceCache,
+		complianceData.SignatureNonce,
+		time.Unix(complianceData.SignatureTimestamp, 0),
+		signablePayload,
+	)
+}
+
+// payRequestHash returns the hash of a pay request without its signature, nonce, timestamp and backing signatures,
+// which change when the sender signs a retry of the request again.
+func payRequestHash(request *protocol.PayRequest) (string, error) {
+	unsignedRequest := *request
+	unsignedRequest.RawResponse = nil
+	if request.PayerData != nil {
+		complianceData, err := request.PayerData.Compliance()
+		if err != nil {
+			return "", err
+		}
+		if complianceData != nil {
+			unsignedComplianceData := *complianceData
+			unsignedComplianceData.Signature = ""
+			unsignedComplianceData.SignatureNonce = ""
+			unsignedComplianceData.SignatureTimestamp = 0
+			unsignedComplianceData.BackingSignatures = nil
+			unsignedRequest.PayerData, err = payerDataWithCompliance(*request.PayerData, &unsignedComplianceData)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hashRequest(&unsignedRequest)
+}
+
+// payRequestSenderDomain returns the domain of the VASP sending a pay request, or an empty string for non-UMA pay
+// requests.
+func payRequestSenderDomain(request *protocol.PayRequest) string {
+	if !request.IsUmaRequest() {
+		return ""
+	}
+	payerIdentifier := request.PayerData.Identifier()
+	if payerIdentifier == nil {
+		return ""
+	}
+	vaspDomain, _ := GetVaspDomainFromUmaAddress(*payerIdentifier)
+	return vaspDomain
+}
+
+// payRequestLockKey returns the key to lock a pay request with: its idempotency key, or else the nonce of its
+// signature, which is the same for all deliveries of an UMA pay request, scoped by the domain of the sender. Returns an
+// empty string for pay requests which have neither.
+func payRequestLockKey(senderDomain string, request *protocol.PayRequest) string {
+	if request.IdempotencyKey != nil && *request.IdempotencyKey != "" {
+		return "uma:payreq:" + senderDomain + ":" + *request.IdempotencyKey
+	}
+	compliance, err := request.PayerData.Compliance()
+	if err != nil || compliance == nil || compliance.SignatureNonce == "" {
+		return ""
+	}
+	return "uma:payreq:" + senderDomain + ":nonce:" + compliance.SignatureNonce
+}
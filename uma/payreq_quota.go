@@ -0,0 +1,173 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSenderConcurrencyLimitExceeded is returned when a sending VASP has more pay requests in flight than allowed by
+// WithSenderConcurrencyLimit. The PayRequestHandler responds with 429 Too Many Requests.
+var ErrSenderConcurrencyLimitExceeded = errors.New("too many concurrent pay requests from the sending VASP")
+
+// ErrVerificationConcurrencyLimitExceeded is returned when more pay requests are being verified at once than allowed
+// by WithVerificationConcurrencyLimit. The PayRequestHandler responds with 429 Too Many Requests.
+var ErrVerificationConcurrencyLimitExceeded = errors.New("too many concurrent pay requests being verified")
+
+// ErrInvoiceQuotaExceeded is returned by an InvoiceQuota when a receiver has been issued too many invoices. The
+// PayRequestHandler responds with 429 Too Many Requests.
+var ErrInvoiceQuotaExceeded = errors.New("invoice quota exceeded for the receiver")
+
+// WithSenderConcurrencyLimit limits how many pay requests from each sending VASP a PayRequestHandler processes at
+// once, so that a buggy or malicious sender cannot tie up the receiver's node. Further pay requests are rejected
+// with ErrSenderConcurrencyLimitExceeded instead of waiting. The limit is keyed by the sender's domain once its
+// signature is verified, so that other parties cannot use up the limit of a sender. Non-UMA pay requests, which do
+// not identify their sender, share a single limit.
+//
+// The limit applies to each instance of the handler separately. A limit of zero or less disables it.
+func WithSenderConcurrencyLimit(limit int) Option {
+	limiter := &senderConcurrencyLimiter{
+		limit:    limit,
+		inFlight: make(map[string]int),
+		err:      ErrSenderConcurrencyLimitExceeded,
+	}
+	return func(o *options) {
+		if limit > 0 {
+			o.senderConcurrencyLimiter = limiter
+		}
+	}
+}
+
+// WithVerificationConcurrencyLimit limits how many pay requests a PayRequestHandler verifies at once, across all
+// senders, since verifying a pay request may fetch the public keys of the VASP it claims to come from. Further pay
+// requests are rejected with ErrVerificationConcurrencyLimitExceeded instead of waiting. Unlike
+// WithSenderConcurrencyLimit, it applies before the sender is verified, and bounds the work done for unverified pay
+// requests.
+//
+// The limit applies to each instance of the handler separately. A limit of zero or less disables it.
+func WithVerificationConcurrencyLimit(limit int) Option {
+	limiter := &senderConcurrencyLimiter{
+		limit:    limit,
+		inFlight: make(map[string]int),
+		err:      ErrVerificationConcurrencyLimitExceeded,
+	}
+	return func(o *options) {
+		if limit > 0 {
+			o.verificationConcurrencyLimiter = limiter
+		}
+	}
+}
+
+// senderConcurrencyLimiter counts the pay requests in flight for each sending VASP, or for all of them under a single
+// key.
+type senderConcurrencyLimiter struct {
+	mutex    sync.Mutex
+	limit    int
+	inFlight map[string]int
+	// err is returned when the limit is exceeded.
+	err error
+}
+
+// acquire reserves a slot for a pay request from the given VASP, and returns the function to release it.
+func (l *senderConcurrencyLimiter) acquire(vaspDomain string) (func(), error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight[vaspDomain] >= l.limit {
+		return nil, l.err
+	}
+	l.inFlight[vaspDomain]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.inFlight[vaspDomain]--
+			if l.inFlight[vaspDomain] == 0 {
+				delete(l.inFlight, vaspDomain)
+			}
+		})
+	}, nil
+}
+
+// InvoiceQuota limits how many invoices are issued for each receiver, so that a sender cannot trigger unbounded
+// invoice creation against the receiver's node.
+//
+// Implementations of this interface should be thread-safe. VASPs running several instances should back it with their
+// shared database or cache, so that the quota applies across instances.
+type InvoiceQuota interface {
+	// ConsumeInvoice records the issuance of an invoice for the given receiver, or returns ErrInvoiceQuotaExceeded if
+	// the receiver's quota is used up.
+	ConsumeInvoice(ctx context.Context, receiverKey string) error
+}
+
+// WithInvoiceQuota sets the InvoiceQuota a PayRequestHandler consumes before creating each invoice. The quota is
+// consumed once the pay request is verified, and is not refunded if the PayReqResponseFunc fails. Retries answered
+// from the IdempotencyStore do not consume it.
+//
+// Args:
+//
+//	quota: the InvoiceQuota to consume.
+//	receiverKey: returns the key of the receiver of a pay request, typically the user in the callback URL path. If
+//		nil, the URL path is used.
+func WithInvoiceQuota(quota InvoiceQuota, receiverKey func(r *http.Request) string) Option {
+	if receiverKey == nil {
+		receiverKey = func(r *http.Request) string {
+			return r.URL.Path
+		}
+	}
+	return func(o *options) {
+		o.invoiceQuota = quota
+		o.invoiceQuotaReceiverKey = receiverKey
+	}
+}
+
+// InMemoryInvoiceQuota is an in-memory implementation of InvoiceQuota, which allows a fixed number of invoices per
+// receiver in each time window. It only applies within one process, so it is not suitable for VASPs running several
+// instances.
+type InMemoryInvoiceQuota struct {
+	mutex   sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*invoiceQuotaWindow
+	clock   Clock
+}
+
+type invoiceQuotaWindow struct {
+	start time.Time
+	count int
+}
+
+// NewInMemoryInvoiceQuota Creates an InMemoryInvoiceQuota allowing limit invoices per receiver in each window.
+func NewInMemoryInvoiceQuota(limit int, window time.Duration) *InMemoryInvoiceQuota {
+	return &InMemoryInvoiceQuota{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*invoiceQuotaWindow),
+		clock:   SystemClock,
+	}
+}
+
+// SetClock sets the Clock used to start and end quota windows. Defaults to SystemClock.
+func (q *InMemoryInvoiceQuota) SetClock(clock Clock) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.clock = clockOrSystem(clock)
+}
+
+func (q *InMemoryInvoiceQuota) ConsumeInvoice(_ context.Context, receiverKey string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := q.clock.Now()
+	window, ok := q.windows[receiverKey]
+	if !ok || now.Sub(window.start) >= q.window {
+		window = &invoiceQuotaWindow{start: now}
+		q.windows[receiverKey] = window
+	}
+	if window.count >= q.limit {
+		return ErrInvoiceQuotaExceeded
+	}
+	window.count++
+	return nil
+}
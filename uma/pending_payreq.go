@@ -0,0 +1,573 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxPayReqResolutionWebhookBytes is the largest webhook body accepted by PayReqResolutionWebhookHandler.
+const maxPayReqResolutionWebhookBytes = 64 * 1024
+
+// retrievalTokenParam is the query parameter of the pay request callback with which senders poll held pay requests.
+const retrievalTokenParam = "retrievalToken"
+
+// ErrHeldPayRequestNotFound is returned by a HeldPayRequestStore when there is no pay request for a retrieval
+// token.
+var ErrHeldPayRequestNotFound = errors.New("held pay request not found")
+
+// HeldPayRequestState is the state of a pay request held for review.
+type HeldPayRequestState string
+
+const (
+	// HeldPayRequestStatePending means the pay request is still under review.
+	HeldPayRequestStatePending HeldPayRequestState = "PENDING"
+	// HeldPayRequestStateAccepted means the review is complete and an invoice was created for the pay request.
+	HeldPayRequestStateAccepted HeldPayRequestState = "ACCEPTED"
+	// HeldPayRequestStateRejected means the review rejected the pay request.
+	HeldPayRequestStateRejected HeldPayRequestState = "REJECTED"
+)
+
+// HeldPayRequest is a pay request held for review by a receiving VASP.
+type HeldPayRequest struct {
+	RetrievalToken string               `json:"retrievalToken"`
+	Request        *protocol.PayRequest `json:"request"`
+	State          HeldPayRequestState  `json:"state"`
+	// Reason is the reason the pay request was held, or rejected once the review is complete.
+	Reason string `json:"reason,omitempty"`
+	// Response is the final response to the pay request, once it is accepted.
+	Response  *protocol.PayReqResponse `json:"response,omitempty"`
+	CreatedAt time.Time                `json:"createdAt"`
+}
+
+// HeldPayRequestStore records the pay requests a receiving VASP holds for review, keyed by retrieval token, so that
+// senders can poll for their final responses. Implementations of this interface should be thread-safe.
+type HeldPayRequestStore interface {
+	// SaveHeldPayRequest creates or replaces the held pay request with the same retrieval token.
+	SaveHeldPayRequest(ctx context.Context, pending HeldPayRequest) error
+	// GetHeldPayRequest returns the held pay request with a retrieval token, or ErrHeldPayRequestNotFound.
+	GetHeldPayRequest(ctx context.Context, retrievalToken string) (*HeldPayRequest, error)
+}
+
+// InMemoryHeldPayRequestStore is an in-memory implementation of HeldPayRequestStore.
+// It is not recommended to use this in production, as it will not persist across restarts or be shared between
+// instances. You likely want to implement your own HeldPayRequestStore backed by a database.
+type InMemoryHeldPayRequestStore struct {
+	mutex   sync.Mutex
+	entries map[string]HeldPayRequest
+}
+
+func NewInMemoryHeldPayRequestStore() *InMemoryHeldPayRequestStore {
+	return &InMemoryHeldPayRequestStore{entries: make(map[string]HeldPayRequest)}
+}
+
+func (s *InMemoryHeldPayRequestStore) SaveHeldPayRequest(_ context.Context, pending HeldPayRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[pending.RetrievalToken] = pending
+	return nil
+}
+
+func (s *InMemoryHeldPayRequestStore) GetHeldPayRequest(_ context.Context, retrievalToken string) (*HeldPayRequest, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending, ok := s.entries[retrievalToken]
+	if !ok {
+		return nil, ErrHeldPayRequestNotFound
+	}
+	return &pending, nil
+}
+
+// PurgeHeldPayRequestsOlderThan removes all pay requests held before the given time. This allows the store to be
+// pruned periodically, for example once their invoices have expired.
+func (s *InMemoryHeldPayRequestStore) PurgeHeldPayRequestsOlderThan(timestamp time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for token, pending := range s.entries {
+		if pending.CreatedAt.Before(timestamp) {
+			delete(s.entries, token)
+		}
+	}
+}
+
+// WithHeldPayRequestStore makes a PayRequestHandler respond to pay requests held by its ComplianceDecider with a
+// PendingPayReqResponse and the status code 202 Accepted, rather than an error, and serve their final responses to
+// senders polling with the retrieval token. Once a review is complete, record its outcome with
+// ResolveHeldPayRequest or RejectHeldPayRequest.
+//
+// Args:
+//
+//	store: the store of the held pay requests.
+//	retryAfter: how long senders should wait between polls. If zero, senders choose.
+func WithHeldPayRequestStore(store HeldPayRequestStore, retryAfter time.Duration) Option {
+	return func(o *options) {
+		o.heldPayRequestStore = store
+		o.heldPayRequestRetryAfter = retryAfter
+	}
+}
+
+// payRequestPendingError is returned by PayRequestHandler.process for pay requests held for review, to respond with a
+// PendingPayReqResponse.
+type payRequestPendingError struct {
+	response protocol.PendingPayReqResponse
+}
+
+func (e payRequestPendingError) Error() string {
+	return "pay request held for review"
+}
+
+// holdPayRequest records a pay request held for review and returns the error to respond with.
+func (o *options) holdPayRequest(ctx context.Context, request *protocol.PayRequest, reason string) error {
+	pending := HeldPayRequest{
+		RetrievalToken: uuid.NewString(),
+		Request:        request,
+		State:          HeldPayRequestStatePending,
+		Reason:         reason,
+		CreatedAt:      o.clock.Now(),
+	}
+	if err := o.heldPayRequestStore.SaveHeldPayRequest(ctx, pending); err != nil {
+		return fmt.Errorf("failed to save held pay request: %w", err)
+	}
+	return payRequestPendingError{response: o.pendingPayReqResponse(pending)}
+}
+
+func (o *options) pendingPayReqResponse(pending HeldPayRequest) protocol.PendingPayReqResponse {
+	response := protocol.PendingPayReqResponse{
+		Status:         protocol.PayReqStatusPending,
+		RetrievalToken: pending.RetrievalToken,
+	}
+	if pending.Reason != "" {
+		response.Reason = &pending.Reason
+	}
+	if o.heldPayRequestRetryAfter > 0 {
+		retryAfterSec := int64(o.heldPayRequestRetryAfter.Seconds())
+		response.RetryAfterSec = &retryAfterSec
+	}
+	return response
+}
+
+// serveHeldPayRequest responds to a sender polling for the final response to a held pay request.
+func (o *options) serveHeldPayRequest(w http.ResponseWriter, r *http.Request, retrievalToken string) {
+	pending, err := o.heldPayRequestStore.GetHeldPayRequest(r.Context(), retrievalToken)
+	if errors.Is(err, ErrHeldPayRequestNotFound) {
+		writeStatusResponse(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch pending.State {
+	case HeldPayRequestStatePending:
+		writeJsonResponse(w, http.StatusAccepted, o.pendingPayReqResponse(*pending))
+	case HeldPayRequestStateAccepted:
+		writeJsonResponse(w, http.StatusOK, pending.Response)
+	default:
+		writeStatusResponse(w, http.StatusForbidden, complianceVerdictError(ErrComplianceRejected,
+			ComplianceVerdict{Decision: ComplianceDecisionReject, Reason: pending.Reason}))
+	}
+}
+
+func writeJsonResponse(w http.ResponseWriter, statusCode int, response interface{}) {
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(responseBody)
+}
+
+// ResolveHeldPayRequest Records the final response to a pay request held for review, once the review accepts it.
+// Senders polling with the retrieval token then get the response. If the pay request has a PendingCallback, notify
+// the sender with SendPayReqResolutionWebhook.
+//
+// Args:
+//
+//	ctx: the context for the store.
+//	store: the HeldPayRequestStore of the PayRequestHandler.
+//	retrievalToken: the retrieval token of the held pay request.
+//	response: the response to the pay request, for example from GetPayReqResponseWithSigner.
+func ResolveHeldPayRequest(
+	ctx context.Context,
+	store HeldPayRequestStore,
+	retrievalToken string,
+	response *protocol.PayReqResponse,
+) (*HeldPayRequest, error) {
+	if response == nil {
+		return nil, errors.New("missing response")
+	}
+	return completeHeldPayRequest(ctx, store, retrievalToken, func(pending *HeldPayRequest) {
+		pending.State = HeldPayRequestStateAccepted
+		pending.Response = response
+	})
+}
+
+// RejectHeldPayRequest Records that the review of a held pay request rejected it. Senders polling with the
+// retrieval token then get a 403 Forbidden error response. If the pay request has a PendingCallback, notify the sender
+// with SendPayReqResolutionWebhook.
+//
+// Args:
+//
+//	ctx: the context for the store.
+//	store: the HeldPayRequestStore of the PayRequestHandler.
+//	retrievalToken: the retrieval token of the held pay request.
+//	reason: the reason of the rejection, which is sent to the sender.
+func RejectHeldPayRequest(
+	ctx context.Context,
+	store HeldPayRequestStore,
+	retrievalToken string,
+	reason string,
+) (*HeldPayRequest, error) {
+	return completeHeldPayRequest(ctx, store, retrievalToken, func(pending *HeldPayRequest) {
+		pending.State = HeldPayRequestStateRejected
+		pending.Reason = reason
+	})
+}
+
+func completeHeldPayRequest(
+	ctx context.Context,
+	store HeldPayRequestStore,
+	retrievalToken string,
+	complete func(pending *HeldPayRequest),
+) (*HeldPayRequest, error) {
+	pending, err := store.GetHeldPayRequest(ctx, retrievalToken)
+	if err != nil {
+		return nil, err
+	}
+	if pending.State != HeldPayRequestStatePending {
+		return nil, fmt.Errorf("pending pay request was already %s", pending.State)
+	}
+	complete(pending)
+	if err = store.SaveHeldPayRequest(ctx, *pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// PayReqPendingError is returned by SendPayRequest and PollPendingPayRequest when the receiving VASP holds the pay
+// request for review. Poll for the final response with PollPendingPayRequest or WaitForPendingPayRequest.
+type PayReqPendingError struct {
+	Response protocol.PendingPayReqResponse
+}
+
+func (e *PayReqPendingError) Error() string {
+	if e.Response.Reason != nil {
+		return "pay request held for review: " + *e.Response.Reason
+	}
+	return "pay request held for review"
+}
+
+// payReqPendingError returns a *PayReqPendingError if an error of sendRequest is a PendingPayReqResponse.
+func payReqPendingError(err error) error {
+	var invalidResponseErr InvalidResponseError
+	if !errors.As(err, &invalidResponseErr) || invalidResponseErr.StatusCode != http.StatusAccepted {
+		return err
+	}
+	var response protocol.PendingPayReqResponse
+	if json.Unmarshal(invalidResponseErr.Body, &response) != nil ||
+		response.Status != protocol.PayReqStatusPending || response.RetrievalToken == "" {
+		return err
+	}
+	return &PayReqPendingError{Response: response}
+}
+
+// PollPendingPayRequest Polls the receiving VASP once for the final response to a pay request it held for review.
+// Returns a *PayReqPendingError if the pay request is still under review.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	callback: the callback URL from the lnurlp response, to which the pay request was sent.
+//	retrievalToken: the retrieval token from the PendingPayReqResponse.
+//	opts: optional settings such as WithRequestDoer.
+func PollPendingPayRequest(
+	ctx context.Context,
+	callback string,
+	retrievalToken string,
+	opts ...Option,
+) (*protocol.PayReqResponse, error) {
+	callbackUrl, err := url.Parse(callback)
+	if err != nil {
+		return nil, err
+	}
+	query := callbackUrl.Query()
+	query.Set(retrievalTokenParam, retrievalToken)
+	callbackUrl.RawQuery = query.Encode()
+	o := newOptions(opts)
+	responseBodyBytes, err := o.sendRequest(ctx, outboundRequest{
+		method:      http.MethodGet,
+		url:         callbackUrl.String(),
+		idempotent:  true,
+		expectsJson: true,
+	})
+	if err != nil {
+		return nil, payReqPendingError(err)
+	}
+	return o.parsePayReqResponse(ctx, responseBodyBytes, opts)
+}
+
+// WaitForPendingPayRequest Polls the receiving VASP until it completes the review of a held pay request, and returns
+// the final response. It waits as long as the receiver asks between polls, or pollInterval otherwise. Cancel the
+// context to stop waiting.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	callback: the callback URL from the lnurlp response, to which the pay request was sent.
+//	pending: the PendingPayReqResponse from the PayReqPendingError of SendPayRequest.
+//	pollInterval: how long to wait between polls if the receiver does not set RetryAfterSec.
+//	opts: optional settings such as WithRequestDoer.
+func WaitForPendingPayRequest(
+	ctx context.Context,
+	callback string,
+	pending protocol.PendingPayReqResponse,
+	pollInterval time.Duration,
+	opts ...Option,
+) (*protocol.PayReqResponse, error) {
+	o := newOptions(opts)
+	for {
+		wait := pollInterval
+		if pending.RetryAfterSec != nil && *pending.RetryAfterSec > 0 {
+			wait = time.Duration(*pending.RetryAfterSec) * time.Second
+		}
+		if err := sleepWithContext(ctx, o.clock, wait); err != nil {
+			return nil, err
+		}
+		response, err := PollPendingPayRequest(ctx, callback, pending.RetrievalToken, opts...)
+		var pendingErr *PayReqPendingError
+		if !errors.As(err, &pendingErr) {
+			return response, err
+		}
+		pending = pendingErr.Response
+	}
+}
+
+// SignPayReqResolutionWebhook Signs a pay request resolution webhook, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	webhook: the webhook to sign. RetrievalToken and VaspDomain must be set.
+//	signer: the UmaSigner of the receiving VASP.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithSignatureScheme.
+func SignPayReqResolutionWebhook(
+	webhook protocol.PayReqResolutionWebhook,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PayReqResolutionWebhook, error) {
+	if err := validatePayReqResolutionWebhookFields(&webhook); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	webhook.Nonce = *nonce
+	webhook.Timestamp = o.clock.Now().Unix()
+	signablePayload, err := o.signablePayload(MessageTypePayReqResolutionWebhook, webhook.SignablePayload, &webhook, "signature")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Signature = *signature
+	return &webhook, nil
+}
+
+func validatePayReqResolutionWebhookFields(webhook *protocol.PayReqResolutionWebhook) error {
+	if webhook.VaspDomain == "" {
+		return errors.New("missing vaspDomain")
+	}
+	if webhook.RetrievalToken == "" {
+		return errors.New("missing retrievalToken")
+	}
+	return nil
+}
+
+// ParsePayReqResolutionWebhook Parses a pay request resolution webhook from a raw request body.
+func ParsePayReqResolutionWebhook(bytes []byte, opts ...Option) (*protocol.PayReqResolutionWebhook, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePayReqResolutionWebhook, bytes); err != nil {
+		return nil, err
+	}
+	var webhook protocol.PayReqResolutionWebhook
+	if err := json.Unmarshal(bytes, &webhook); err != nil {
+		return nil, err
+	}
+	if err := validatePayReqResolutionWebhookFields(&webhook); err != nil {
+		return nil, err
+	}
+	if err := o.checkStrict(MessageTypePayReqResolutionWebhook, unknownJsonFields(bytes, &webhook)); err != nil {
+		return nil, err
+	}
+	webhook.RawResponse = o.rawPayload(bytes)
+	return &webhook, nil
+}
+
+// VerifyPayReqResolutionWebhookSignature Verifies the signature on a pay request resolution webhook based on the
+// public key of the receiving VASP. Sending VASPs must also check that the retrieval token is from a pay request they
+// sent to that VASP.
+//
+// Args:
+//
+//	webhook: the signed webhook to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the receiving VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger and WithAcceptedSignatureSchemes.
+func VerifyPayReqResolutionWebhookSignature(
+	webhook *protocol.PayReqResolutionWebhook,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := webhook.SignablePayload()
+	if err != nil {
+		return err
+	}
+	payloads, err := schemePayloads(signablePayload, webhook, "signature")
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).verifySignedMessageWithSchemes(
+		MessageTypePayReqResolutionWebhook,
+		webhook.VaspDomain,
+		nonceCache,
+		webhook.Nonce,
+		time.Unix(webhook.Timestamp, 0),
+		payloads,
+		webhook.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SendPayReqResolutionWebhook Sends a signed pay request resolution webhook to the PendingCallback of a held pay
+// request.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	pendingCallback: the PendingCallback of the pay request.
+//	webhook: the webhook to send, signed with SignPayReqResolutionWebhook.
+//	opts: optional settings such as WithRequestDoer and WithRetryPolicy.
+func SendPayReqResolutionWebhook(
+	ctx context.Context,
+	pendingCallback string,
+	webhook protocol.PayReqResolutionWebhook,
+	opts ...Option,
+) error {
+	o := newOptions(opts)
+	outbound, err := newPayReqResolutionWebhookOutboundRequest(pendingCallback, webhook)
+	if err != nil {
+		return err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPayReqResolutionWebhookSent, slog.String("url", redactQuery(pendingCallback)))
+	_, err = o.sendRequest(ctx, *outbound)
+	return err
+}
+
+func newPayReqResolutionWebhookOutboundRequest(
+	pendingCallback string,
+	webhook protocol.PayReqResolutionWebhook,
+) (*outboundRequest, error) {
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundRequest{
+		method: http.MethodPost,
+		url:    pendingCallback,
+		body:   body,
+		// The webhook only tells the sender to poll, so duplicates are harmless.
+		idempotent: true,
+		resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedWebhook, err := SignPayReqResolutionWebhook(webhook, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newPayReqResolutionWebhookOutboundRequest(pendingCallback, *resignedWebhook)
+		},
+	}, nil
+}
+
+// PayReqResolutionWebhookFunc handles a verified pay request resolution webhook, typically by fetching the final
+// response with PollPendingPayRequest. Returning an error responds to the receiving VASP with an internal server
+// error, so that it can retry later.
+type PayReqResolutionWebhookFunc func(ctx context.Context, webhook *protocol.PayReqResolutionWebhook) error
+
+// PayReqResolutionWebhookHandler is an http.Handler which receives pay request resolution webhooks from receiving
+// VASPs. It parses each webhook, fetches the receiver's public keys, verifies the signature and passes the webhook on
+// to a PayReqResolutionWebhookFunc.
+type PayReqResolutionWebhookHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	onResolved     PayReqResolutionWebhookFunc
+	opts           []Option
+}
+
+// NewPayReqResolutionWebhookHandler Creates a PayReqResolutionWebhookHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the receiving VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	onResolved: called with each verified webhook.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewPayReqResolutionWebhookHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	onResolved PayReqResolutionWebhookFunc,
+	opts ...Option,
+) *PayReqResolutionWebhookHandler {
+	return &PayReqResolutionWebhookHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		onResolved:     onResolved,
+		opts:           opts,
+	}
+}
+
+func (h *PayReqResolutionWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodPost {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayReqResolutionWebhookBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	webhook, err := ParsePayReqResolutionWebhook(body, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(r.Context(), webhook.VaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+		return
+	}
+	if err = VerifyPayReqResolutionWebhookSignature(webhook, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = h.onResolved(r.Context(), webhook); err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusOK, nil)
+}
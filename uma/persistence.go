@@ -0,0 +1,251 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ErrRecordNotFound is returned by the stores of a PersistenceAdapter when no record exists for a key.
+var ErrRecordNotFound = errors.New("record not found")
+
+// PersistenceAdapter bundles the stores for the protocol state a VASP keeps between requests, so that it can be
+// backed by one database and survive restarts. NewInMemoryPersistenceAdapter is an in-memory implementation for tests,
+// and the umasql package implements it on top of database/sql.
+type PersistenceAdapter interface {
+	// NonceCache returns the cache of nonces used to prevent replay attacks.
+	NonceCache() NonceCache
+	// PublicKeyCache returns the cache of the public keys of other VASPs.
+	PublicKeyCache() PublicKeyCache
+	// PendingPayRequests returns the store of pay requests sent but not yet paid.
+	PendingPayRequests() PendingPayRequestStore
+	// Quotes returns the store of quotes made in pay request responses.
+	Quotes() QuoteStore
+	// PaymentReceipts returns the store of receipts of settled payments.
+	PaymentReceipts() PaymentReceiptStore
+}
+
+// PendingPayRequest is a pay request sent by a sending VASP which has not been paid yet. Keeping it lets the VASP
+// verify the receiving VASP's response with VerifyPayReqResponse and complete the payment after a restart.
+type PendingPayRequest struct {
+	// Id identifies the payment within the sending VASP, e.g. its idempotency key.
+	Id string
+	// PayeeIdentifier is the identifier of the receiver, e.g. $bob@vasp2.com.
+	PayeeIdentifier string
+	// PayRequest is the pay request sent to the receiving VASP.
+	PayRequest protocol.PayRequest
+	// PayReqResponse is the receiving VASP's response, once it was received.
+	PayReqResponse *protocol.PayReqResponse
+	// CreatedAt is the time at which the pay request was sent.
+	CreatedAt time.Time
+}
+
+// ExpectedPayment returns the ExpectedPayment to check the receiving VASP's response against.
+func (p *PendingPayRequest) ExpectedPayment() ExpectedPayment {
+	return ExpectedPaymentFromPayRequest(p.PayRequest, p.PayeeIdentifier)
+}
+
+// PendingPayRequestStore stores the pay requests a sending VASP sent but has not paid yet.
+//
+// Implementations of this interface should be thread-safe.
+type PendingPayRequestStore interface {
+	// SavePendingPayRequest saves a pending pay request, replacing any saved before with the same Id.
+	SavePendingPayRequest(ctx context.Context, pending PendingPayRequest) error
+	// GetPendingPayRequest returns the pending pay request with the given Id, or ErrRecordNotFound.
+	GetPendingPayRequest(ctx context.Context, id string) (*PendingPayRequest, error)
+	// DeletePendingPayRequest deletes the pending pay request with the given Id, e.g. once it was paid or cancelled.
+	DeletePendingPayRequest(ctx context.Context, id string) error
+}
+
+// StoredQuote is a quote a receiving VASP made in a pay request response, kept so that it can honor the conversion
+// rate when the invoice is paid, and answer support requests referencing the quote id.
+type StoredQuote struct {
+	// Quote identifies the quote and holds its expiry.
+	Quote Quote
+	// EncodedInvoice is the invoice of the pay request response.
+	EncodedInvoice string
+	// PaymentInfo is the payment info of the pay request response, holding the conversion rate and fees.
+	PaymentInfo protocol.PayReqResponsePaymentInfo
+	// CreatedAt is the time at which the quote was made.
+	CreatedAt time.Time
+}
+
+// QuoteStore stores the quotes a receiving VASP made.
+//
+// Implementations of this interface should be thread-safe.
+type QuoteStore interface {
+	// SaveQuote saves a quote, replacing any saved before with the same id.
+	SaveQuote(ctx context.Context, quote StoredQuote) error
+	// GetQuote returns the quote with the given id, or ErrRecordNotFound.
+	GetQuote(ctx context.Context, quoteId string) (*StoredQuote, error)
+	// PurgeQuotesExpiredBefore deletes all quotes which expired before the given time.
+	PurgeQuotesExpiredBefore(ctx context.Context, timestamp time.Time) error
+}
+
+// PaymentReceiptStore stores the receipts of settled payments. A payment can have two receipts, one signed by each
+// VASP, so receipts are keyed by payment hash and signing VASP domain.
+//
+// Implementations of this interface should be thread-safe.
+type PaymentReceiptStore interface {
+	// SavePaymentReceipt saves a receipt, replacing any saved before for the same payment hash and VASP domain.
+	SavePaymentReceipt(ctx context.Context, receipt protocol.PaymentReceipt) error
+	// GetPaymentReceipts returns the receipts saved for a payment hash, or an empty slice if there are none.
+	GetPaymentReceipts(ctx context.Context, paymentHash string) ([]protocol.PaymentReceipt, error)
+}
+
+// InMemoryPersistenceAdapter is an in-memory implementation of PersistenceAdapter.
+// It is not recommended to use this in production, as it will not persist across restarts or be shared between
+// instances.
+type InMemoryPersistenceAdapter struct {
+	nonceCache         *InMemoryNonceCache
+	publicKeyCache     *InMemoryPublicKeyCache
+	pendingPayRequests *InMemoryPendingPayRequestStore
+	quotes             *InMemoryQuoteStore
+	paymentReceipts    *InMemoryPaymentReceiptStore
+}
+
+// NewInMemoryPersistenceAdapter Creates an InMemoryPersistenceAdapter. Its NonceCache rejects messages signed before
+// it was created, since it cannot know which nonces were used before.
+func NewInMemoryPersistenceAdapter() *InMemoryPersistenceAdapter {
+	return &InMemoryPersistenceAdapter{
+		nonceCache:         NewInMemoryNonceCache(time.Now()),
+		publicKeyCache:     NewInMemoryPublicKeyCache(),
+		pendingPayRequests: NewInMemoryPendingPayRequestStore(),
+		quotes:             NewInMemoryQuoteStore(),
+		paymentReceipts:    NewInMemoryPaymentReceiptStore(),
+	}
+}
+
+func (a *InMemoryPersistenceAdapter) NonceCache() NonceCache {
+	return a.nonceCache
+}
+
+func (a *InMemoryPersistenceAdapter) PublicKeyCache() PublicKeyCache {
+	return a.publicKeyCache
+}
+
+func (a *InMemoryPersistenceAdapter) PendingPayRequests() PendingPayRequestStore {
+	return a.pendingPayRequests
+}
+
+func (a *InMemoryPersistenceAdapter) Quotes() QuoteStore {
+	return a.quotes
+}
+
+func (a *InMemoryPersistenceAdapter) PaymentReceipts() PaymentReceiptStore {
+	return a.paymentReceipts
+}
+
+// InMemoryPendingPayRequestStore is an in-memory implementation of PendingPayRequestStore.
+type InMemoryPendingPayRequestStore struct {
+	mutex   sync.RWMutex
+	pending map[string]PendingPayRequest
+}
+
+func NewInMemoryPendingPayRequestStore() *InMemoryPendingPayRequestStore {
+	return &InMemoryPendingPayRequestStore{pending: make(map[string]PendingPayRequest)}
+}
+
+func (s *InMemoryPendingPayRequestStore) SavePendingPayRequest(_ context.Context, pending PendingPayRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending[pending.Id] = pending
+	return nil
+}
+
+func (s *InMemoryPendingPayRequestStore) GetPendingPayRequest(_ context.Context, id string) (*PendingPayRequest, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	pending, ok := s.pending[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &pending, nil
+}
+
+func (s *InMemoryPendingPayRequestStore) DeletePendingPayRequest(_ context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pending, id)
+	return nil
+}
+
+// InMemoryQuoteStore is an in-memory implementation of QuoteStore.
+type InMemoryQuoteStore struct {
+	mutex  sync.RWMutex
+	quotes map[string]StoredQuote
+}
+
+func NewInMemoryQuoteStore() *InMemoryQuoteStore {
+	return &InMemoryQuoteStore{quotes: make(map[string]StoredQuote)}
+}
+
+func (s *InMemoryQuoteStore) SaveQuote(_ context.Context, quote StoredQuote) error {
+	if quote.Quote.Id == "" {
+		return errors.New("missing quote id")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.quotes[quote.Quote.Id] = quote
+	return nil
+}
+
+func (s *InMemoryQuoteStore) GetQuote(_ context.Context, quoteId string) (*StoredQuote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	quote, ok := s.quotes[quoteId]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &quote, nil
+}
+
+func (s *InMemoryQuoteStore) PurgeQuotesExpiredBefore(_ context.Context, timestamp time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for quoteId, quote := range s.quotes {
+		if quote.Quote.ExpiresAt.Before(timestamp) {
+			delete(s.quotes, quoteId)
+		}
+	}
+	return nil
+}
+
+// InMemoryPaymentReceiptStore is an in-memory implementation of PaymentReceiptStore.
+type InMemoryPaymentReceiptStore struct {
+	mutex    sync.RWMutex
+	receipts map[string]map[string]protocol.PaymentReceipt
+}
+
+func NewInMemoryPaymentReceiptStore() *InMemoryPaymentReceiptStore {
+	return &InMemoryPaymentReceiptStore{receipts: make(map[string]map[string]protocol.PaymentReceipt)}
+}
+
+func (s *InMemoryPaymentReceiptStore) SavePaymentReceipt(_ context.Context, receipt protocol.PaymentReceipt) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	receiptsByDomain, ok := s.receipts[receipt.PaymentHash]
+	if !ok {
+		receiptsByDomain = make(map[string]protocol.PaymentReceipt)
+		s.receipts[receipt.PaymentHash] = receiptsByDomain
+	}
+	receipt.RawResponse = nil
+	receiptsByDomain[receipt.VaspDomain] = receipt
+	return nil
+}
+
+func (s *InMemoryPaymentReceiptStore) GetPaymentReceipts(
+	_ context.Context,
+	paymentHash string,
+) ([]protocol.PaymentReceipt, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	receipts := make([]protocol.PaymentReceipt, 0, len(s.receipts[paymentHash]))
+	for _, receipt := range s.receipts[paymentHash] {
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
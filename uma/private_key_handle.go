@@ -0,0 +1,111 @@
+package uma
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// ErrPrivateKeyHandleClosed is returned when signing with a PrivateKeyHandle after Close.
+var ErrPrivateKeyHandleClosed = errors.New("private key handle is closed")
+
+// PrivateKeyHandle holds a secp256k1 signing key in memory owned by the handle, so that it can be wiped once it is no
+// longer needed. It is an UmaSigner, and is accepted by all the SDK functions which sign with a private key.
+//
+// The key is never printed: fmt and loggers show a redacted placeholder instead of the key bytes.
+type PrivateKeyHandle struct {
+	mutex  sync.RWMutex
+	key    []byte
+	locked bool
+	closed bool
+}
+
+// NewPrivateKeyHandle Creates a PrivateKeyHandle holding a copy of the given secp256k1 private key. The caller should
+// zero its own copy of the key once the handle is created.
+//
+// Args:
+//
+//	privateKeyBytes: the serialized secp256k1 private key.
+//	lockMemory: whether to lock the key in memory with mlock so that it is never swapped to disk. Returns an error if
+//		the platform does not support it or the process is not allowed to lock memory.
+func NewPrivateKeyHandle(privateKeyBytes []byte, lockMemory bool) (*PrivateKeyHandle, error) {
+	if len(privateKeyBytes) != secp256k1.PrivKeyBytesLen {
+		return nil, errors.New("invalid private key length")
+	}
+	handle := &PrivateKeyHandle{key: make([]byte, len(privateKeyBytes))}
+	copy(handle.key, privateKeyBytes)
+	if lockMemory {
+		if err := lockKeyMemory(handle.key); err != nil {
+			handle.Close()
+			return nil, fmt.Errorf("failed to lock private key memory: %w", err)
+		}
+		handle.locked = true
+	}
+	return handle, nil
+}
+
+// Sign signs the SHA-256 hash of the payload and returns the DER-encoded signature.
+func (h *PrivateKeyHandle) Sign(payload []byte) ([]byte, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.closed {
+		return nil, ErrPrivateKeyHandleClosed
+	}
+	privateKey := secp256k1.PrivKeyFromBytes(h.key)
+	defer privateKey.Zero()
+	return signSecp256k1(privateKey, payload), nil
+}
+
+// PubKey returns the public key of the handle's private key.
+func (h *PrivateKeyHandle) PubKey() (*secp256k1.PublicKey, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.closed {
+		return nil, ErrPrivateKeyHandleClosed
+	}
+	privateKey := secp256k1.PrivKeyFromBytes(h.key)
+	defer privateKey.Zero()
+	return privateKey.PubKey(), nil
+}
+
+// Close zeroes the key and unlocks its memory. The handle cannot sign after it is closed. Closing a handle twice is a
+// no-op.
+func (h *PrivateKeyHandle) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	for i := range h.key {
+		h.key[i] = 0
+	}
+	if h.locked {
+		h.locked = false
+		return unlockKeyMemory(h.key)
+	}
+	return nil
+}
+
+func (h *PrivateKeyHandle) String() string {
+	return "PrivateKeyHandle(REDACTED)"
+}
+
+func (h *PrivateKeyHandle) GoString() string {
+	return h.String()
+}
+
+// Format prints the redacted placeholder for every verb, so that the key cannot be printed with %x or %+v.
+func (h *PrivateKeyHandle) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(h.String()))
+}
+
+// signerForHandle returns the handle as an UmaSigner, or nil if there is no handle.
+func signerForHandle(handle *PrivateKeyHandle) UmaSigner {
+	if handle == nil {
+		return nil
+	}
+	return handle
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package uma
+
+import "syscall"
+
+func lockKeyMemory(key []byte) error {
+	return syscall.Mlock(key)
+}
+
+func unlockKeyMemory(key []byte) error {
+	return syscall.Munlock(key)
+}
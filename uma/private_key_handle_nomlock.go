@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package uma
+
+import "errors"
+
+func lockKeyMemory([]byte) error {
+	return errors.New("locking memory is not supported on this platform")
+}
+
+func unlockKeyMemory([]byte) error {
+	return nil
+}
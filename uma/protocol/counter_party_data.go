@@ -17,12 +17,15 @@ type CounterPartyDataOptions map[string]CounterPartyDataOption
 type CounterPartyDataField string
 
 const (
-	CounterPartyDataFieldIdentifier    CounterPartyDataField = "identifier"
-	CounterPartyDataFieldName          CounterPartyDataField = "name"
-	CounterPartyDataFieldEmail         CounterPartyDataField = "email"
-	CounterPartyDataFieldCountryCode   CounterPartyDataField = "countryCode"
-	CounterPartyDataFieldCompliance    CounterPartyDataField = "compliance"
-	CounterPartyDataFieldAccountNumber CounterPartyDataField = "accountNumber"
+	CounterPartyDataFieldIdentifier                 CounterPartyDataField = "identifier"
+	CounterPartyDataFieldName                       CounterPartyDataField = "name"
+	CounterPartyDataFieldEmail                      CounterPartyDataField = "email"
+	CounterPartyDataFieldCountryCode                CounterPartyDataField = "countryCode"
+	CounterPartyDataFieldCompliance                 CounterPartyDataField = "compliance"
+	CounterPartyDataFieldAccountNumber              CounterPartyDataField = "accountNumber"
+	CounterPartyDataFieldPostalAddress              CounterPartyDataField = "postalAddress"
+	CounterPartyDataFieldSettlementAccount          CounterPartyDataField = "settlementAccount"
+	CounterPartyDataFieldEncryptedSettlementAccount CounterPartyDataField = "encryptedSettlementAccount"
 )
 
 func (c CounterPartyDataField) String() string {
@@ -43,6 +43,17 @@ type ConvertibleCurrency struct {
 	MaxSendable int64 `json:"max"`
 }
 
+// HasRange returns whether the convertible range is set. Currencies without one accept any amount.
+func (c ConvertibleCurrency) HasRange() bool {
+	return c.MaxSendable != 0
+}
+
+// Contains returns whether an amount, in the smallest unit of the currency, is within the convertible range. Amounts
+// are always contained in unset ranges.
+func (c ConvertibleCurrency) Contains(amount int64) bool {
+	return !c.HasRange() || (amount >= c.MinSendable && amount <= c.MaxSendable)
+}
+
 type v0Currency struct {
 	Code                string  `json:"code"`
 	Name                string  `json:"name"`
@@ -73,6 +73,10 @@ type UmaInvoice struct {
 
 	// The signature of the UMA invoice
 	Signature *[]byte `tlv:"100"`
+
+	// RawResponse is the exact bech32 string this invoice was decoded from. It is only set when decoded with
+	// uma.WithRawPayloadCapture, and is not part of the TLV encoding.
+	RawResponse []byte
 }
 
 func (i *UmaInvoice) MarshalTLV() ([]byte, error) {
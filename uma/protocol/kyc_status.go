@@ -1,6 +1,10 @@
 package protocol
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
 
 type KycStatus int
 
@@ -11,23 +15,63 @@ const (
 	KycStatusVerified
 )
 
-func (k *KycStatus) UnmarshalJSON(b []byte) error {
-	var s string
-	if err := json.Unmarshal(b, &s); err != nil {
-		return err
-	}
+// ErrInvalidKycStatus is returned when parsing or marshaling a KycStatus which is not one of the defined values.
+var ErrInvalidKycStatus = errors.New("invalid kyc status")
+
+// StrictKycStatusParsing controls how unrecognized KYC status strings are handled when unmarshaling. When false (the
+// default), they are mapped to KycStatusUnknown. When true, unmarshaling fails with ErrInvalidKycStatus.
+var StrictKycStatusParsing = false
+
+// ParseKycStatus parses a KYC status string such as "VERIFIED", returning ErrInvalidKycStatus if it is not recognized.
+func ParseKycStatus(s string) (KycStatus, error) {
 	switch s {
-	default:
-		*k = KycStatusUnknown
 	case "UNKNOWN":
-		*k = KycStatusUnknown
+		return KycStatusUnknown, nil
 	case "NOT_VERIFIED":
-		*k = KycStatusNotVerified
+		return KycStatusNotVerified, nil
 	case "PENDING":
-		*k = KycStatusPending
+		return KycStatusPending, nil
 	case "VERIFIED":
-		*k = KycStatusVerified
+		return KycStatusVerified, nil
+	default:
+		return KycStatusUnknown, fmt.Errorf("%w: %q", ErrInvalidKycStatus, s)
 	}
+}
+
+// ParseKycStatusLenient parses a KYC status string, mapping unrecognized values to KycStatusUnknown.
+func ParseKycStatusLenient(s string) KycStatus {
+	k, _ := ParseKycStatus(s)
+	return k
+}
+
+func parseKycStatusWithMode(s string) (KycStatus, error) {
+	if StrictKycStatusParsing {
+		return ParseKycStatus(s)
+	}
+	return ParseKycStatusLenient(s), nil
+}
+
+// IsValid returns whether this is one of the defined KYC statuses.
+func (k KycStatus) IsValid() bool {
+	return k >= KycStatusUnknown && k <= KycStatusVerified
+}
+
+// RequiresTravelRule returns whether travel rule information should be exchanged for a user with this KYC status.
+// Only verified users have identity information which can be shared with the counterparty VASP.
+func (k KycStatus) RequiresTravelRule() bool {
+	return k == KycStatusVerified
+}
+
+func (k *KycStatus) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	status, err := parseKycStatusWithMode(s)
+	if err != nil {
+		return err
+	}
+	*k = status
 	return nil
 }
 
@@ -48,28 +92,30 @@ func (k KycStatus) StringValue() string {
 	return s
 }
 
+func (k KycStatus) String() string {
+	return k.StringValue()
+}
+
 func (k KycStatus) MarshalJSON() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidKycStatus, int(k))
+	}
 	s := k.StringValue()
 	return json.Marshal(s)
 }
 
 func (k *KycStatus) MarshalBytes() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidKycStatus, int(*k))
+	}
 	return []byte(k.StringValue()), nil
 }
 
 func (k *KycStatus) UnmarshalBytes(b []byte) error {
-	s := string(b)
-	switch s {
-	default:
-		*k = KycStatusUnknown
-	case "UNKNOWN":
-		*k = KycStatusUnknown
-	case "NOT_VERIFIED":
-		*k = KycStatusNotVerified
-	case "PENDING":
-		*k = KycStatusPending
-	case "VERIFIED":
-		*k = KycStatusVerified
+	status, err := parseKycStatusWithMode(string(b))
+	if err != nil {
+		return err
 	}
+	*k = status
 	return nil
 }
@@ -28,6 +28,9 @@ type LnurlpRequest struct {
 	// UmaVersion is the version of the UMA protocol that VASP1 prefers to use for this transaction. For the version
 	// negotiation flow, see https://static.swimlanes.io/87f5d188e080cb8e0494e46f80f2ae74.png
 	UmaVersion *string
+	// RawResponse is the exact request URL this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture.
+	RawResponse []byte
 }
 
 // AsUmaRequest returns the request as an UmaLnurlpRequest if it is a valid UMA request, otherwise it returns nil.
@@ -54,17 +57,29 @@ func (q *LnurlpRequest) IsUmaRequest() bool {
 }
 
 func (q *LnurlpRequest) EncodeToUrl() (*url.URL, error) {
+	return q.EncodeToUrlWithPolicy(nil)
+}
+
+// EncodeToUrlWithPolicy is the same as EncodeToUrl, but selects the scheme and checks the receiver domain using the
+// given DomainPolicy. Resolved IP addresses are checked when the request is actually sent.
+//
+// Internationalized receiver domains are converted to punycode with utils.ToAsciiDomain. Domains which mix scripts
+// are rejected unless the policy sets AllowMixedScriptDomains.
+func (q *LnurlpRequest) EncodeToUrlWithPolicy(policy *utils.DomainPolicy) (*url.URL, error) {
 	receiverAddressParts := strings.Split(q.ReceiverAddress, "@")
 	if len(receiverAddressParts) != 2 {
 		return nil, errors.New("invalid receiver address")
 	}
-	scheme := "https"
-	if utils.IsDomainLocalhost(receiverAddressParts[1]) {
-		scheme = "http"
+	receiverDomain, err := policy.ToAsciiDomain(receiverAddressParts[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.CheckDomain(receiverDomain); err != nil {
+		return nil, err
 	}
 	lnurlpUrl := url.URL{
-		Scheme: scheme,
-		Host:   receiverAddressParts[1],
+		Scheme: policy.Scheme(receiverDomain),
+		Host:   receiverDomain,
 		Path:   fmt.Sprintf("/.well-known/lnurlp/%s", receiverAddressParts[0]),
 	}
 	queryParams := lnurlpUrl.Query()
@@ -81,6 +96,16 @@ func (q *LnurlpRequest) EncodeToUrl() (*url.URL, error) {
 	return &lnurlpUrl, nil
 }
 
+// EncodeToLnurl encodes the request URL from EncodeToUrl as a bech32 LNURL string (LUD-01), e.g. for QR codes or
+// wallets which only accept LNURL strings.
+func (q *LnurlpRequest) EncodeToLnurl() (string, error) {
+	lnurlpUrl, err := q.EncodeToUrl()
+	if err != nil {
+		return "", err
+	}
+	return utils.EncodeLnurl(lnurlpUrl.String())
+}
+
 // UmaLnurlpRequest is the first request in the UMA protocol.
 // It is sent by the VASP that is sending the payment to find out information about the receiver.
 type UmaLnurlpRequest struct {
@@ -30,6 +30,9 @@ type LnurlpResponse struct {
 	NostrPubkey *string `json:"nostrPubkey,omitempty"`
 	// AllowsNostr should be set to true if the receiving VASP allows nostr zaps (NIP-57).
 	AllowsNostr *bool `json:"allowsNostr,omitempty"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
 }
 
 // LnurlComplianceResponse is the `compliance` field  of the LnurlpResponse.
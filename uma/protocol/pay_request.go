@@ -49,9 +49,18 @@ type PayRequest struct {
 	// InvoiceUUID is the invoice UUID that the sender is paying.
 	// This only exists in the v1 pay request since the v0 SDK won't support invoices.
 	InvoiceUUID *string `json:"invoiceUUID,omitempty"`
+	// IdempotencyKey optionally identifies this payment attempt, so that the receiver can return the original response
+	// if the same request is delivered more than once, rather than creating a duplicate invoice. It is not signed.
+	IdempotencyKey *string `json:"idempotencyKey,omitempty"`
+	// PendingCallback is an optional URL at which the sender accepts PayReqResolutionWebhooks, so that the receiver can
+	// notify it when a pay request it held for review is resolved, rather than being polled. It is not signed.
+	PendingCallback *string `json:"pendingCallback,omitempty"`
 	// UmaMajorVersion is the major version of the UMA protocol that the VASP supports for this currency. This is used
 	// for serialization, but is not serialized itself.
 	UmaMajorVersion int `json:"-"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
 }
 
 type v0PayRequest struct {
@@ -69,6 +78,8 @@ type v1PayRequest struct {
 	RequestedPayeeData    *CounterPartyDataOptions `json:"payeeData,omitempty"`
 	Comment               *string                  `json:"comment,omitempty"`
 	InvoiceUUID           *string                  `json:"invoiceUUID,omitempty"`
+	IdempotencyKey        *string                  `json:"idempotencyKey,omitempty"`
+	PendingCallback       *string                  `json:"pendingCallback,omitempty"`
 }
 
 // IsUmaRequest returns true if the request is a valid UMA request, otherwise, if any fields are missing, it returns false.
@@ -100,13 +111,19 @@ func (p *PayRequest) MarshalJSON() ([]byte, error) {
 	if p.SendingAmountCurrencyCode != nil {
 		amount = fmt.Sprintf("%s.%s", amount, *p.SendingAmountCurrencyCode)
 	}
-	return json.Marshal(&v1PayRequest{
+	v1 := v1PayRequest{
 		ReceivingCurrencyCode: p.ReceivingCurrencyCode,
 		Amount:                amount,
 		PayerData:             p.PayerData,
 		RequestedPayeeData:    p.RequestedPayeeData,
 		Comment:               p.Comment,
-	})
+		IdempotencyKey:        p.IdempotencyKey,
+		PendingCallback:       p.PendingCallback,
+	}
+	if majorVersionSupportsFeature(p.UmaMajorVersion, ProtocolFeatureInvoices) {
+		v1.InvoiceUUID = p.InvoiceUUID
+	}
+	return json.Marshal(&v1)
 }
 
 func (p *PayRequest) UnmarshalJSON(data []byte) error {
@@ -162,6 +179,9 @@ func (p *PayRequest) UnmarshalFromV1(request v1PayRequest) error {
 	p.PayerData = request.PayerData
 	p.RequestedPayeeData = request.RequestedPayeeData
 	p.Comment = request.Comment
+	p.InvoiceUUID = request.InvoiceUUID
+	p.IdempotencyKey = request.IdempotencyKey
+	p.PendingCallback = request.PendingCallback
 	amount := request.Amount
 	amountParts := strings.Split(amount, ".")
 	if len(amountParts) > 2 {
@@ -295,6 +315,11 @@ func ParsePayRequestFromQueryParams(query url.Values) (*PayRequest, error) {
 	if commentParam != "" {
 		comment = &commentParam
 	}
+	idempotencyKeyParam := query.Get("idempotencyKey")
+	var idempotencyKey *string
+	if idempotencyKeyParam != "" {
+		idempotencyKey = &idempotencyKeyParam
+	}
 
 	return &PayRequest{
 		SendingAmountCurrencyCode: sendingAmountCurrencyCode,
@@ -303,6 +328,7 @@ func ParsePayRequestFromQueryParams(query url.Values) (*PayRequest, error) {
 		PayerData:                 payerDataObj,
 		RequestedPayeeData:        requestedPayeeDataObj,
 		Comment:                   comment,
+		IdempotencyKey:            idempotencyKey,
 		UmaMajorVersion:           umaMajorVersion,
 	}, nil
 }
@@ -31,6 +31,54 @@ func (p *PayeeData) Compliance() (*CompliancePayeeData, error) {
 	return nil, nil
 }
 
+func (p *PayeeData) stringField(field string) *string {
+	if p == nil {
+		return nil
+	}
+	if value, ok := (*p)[field]; ok {
+		if stringValue, ok := value.(string); ok {
+			return &stringValue
+		}
+	}
+	return nil
+}
+
+func (p *PayeeData) Identifier() *string {
+	return p.stringField(CounterPartyDataFieldIdentifier.String())
+}
+
+func (p *PayeeData) Name() *string {
+	return p.stringField(CounterPartyDataFieldName.String())
+}
+
+func (p *PayeeData) Email() *string {
+	return p.stringField(CounterPartyDataFieldEmail.String())
+}
+
+// PostalAddress returns the structured postal address, or nil if there is none.
+func (p *PayeeData) PostalAddress() (*PostalAddress, error) {
+	if p == nil {
+		return nil, nil
+	}
+	value, ok := (*p)[CounterPartyDataFieldPostalAddress.String()]
+	return postalAddressFromField(value, ok)
+}
+
+// SettlementAccount returns the unencrypted settlement account, or nil if there is none. Encrypted settlement accounts
+// are decrypted with uma.DecryptSettlementAccount.
+func (p *PayeeData) SettlementAccount() (*SettlementAccount, error) {
+	if p == nil {
+		return nil, nil
+	}
+	value, ok := (*p)[CounterPartyDataFieldSettlementAccount.String()]
+	return settlementAccountFromField(value, ok)
+}
+
+// EncryptedSettlementAccount returns the hex-encoded encrypted settlement account, or nil if there is none.
+func (p *PayeeData) EncryptedSettlementAccount() *string {
+	return p.stringField(CounterPartyDataFieldEncryptedSettlementAccount.String())
+}
+
 type CompliancePayeeData struct {
 	// NodePubKey is the public key of the receiver's node if known.
 	NodePubKey *string `json:"nodePubKey,omitempty"`
@@ -47,6 +95,8 @@ type CompliancePayeeData struct {
 	// SignatureTimestamp is the unix timestamp (in seconds since epoch) of when the request was sent. Used in the signature.
 	// Note: This field is optional for UMA v0.X backwards-compatibility. It is required for UMA v1.X.
 	SignatureTimestamp *int64 `json:"signatureTimestamp,omitempty"`
+	// BackingSignatures is a list of backing signatures from VASPs that can attest to the authenticity of the message.
+	BackingSignatures *[]BackingSignature `json:"backingSignatures,omitempty"`
 }
 
 func (c *CompliancePayeeData) AsMap() (map[string]interface{}, error) {
@@ -53,6 +53,15 @@ func (p *PayerData) Email() *string {
 	return p.stringField("email")
 }
 
+// PostalAddress returns the structured postal address, or nil if there is none.
+func (p *PayerData) PostalAddress() (*PostalAddress, error) {
+	if p == nil {
+		return nil, nil
+	}
+	value, ok := (*p)[CounterPartyDataFieldPostalAddress.String()]
+	return postalAddressFromField(value, ok)
+}
+
 type TravelRuleFormat struct {
 	// Type is the type of the travel rule format (e.g. IVMS).
 	Type string
@@ -107,6 +116,8 @@ type CompliancePayerData struct {
 	SignatureTimestamp int64  `json:"signatureTimestamp"`
 	// UtxoCallback is the URL that the receiver will call to send UTXOs of the channel that the receiver used to receive the payment once it completes.
 	UtxoCallback string `json:"utxoCallback"`
+	// BackingSignatures is a list of backing signatures from VASPs that can attest to the authenticity of the message.
+	BackingSignatures *[]BackingSignature `json:"backingSignatures,omitempty"`
 }
 
 func (c *CompliancePayerData) AsMap() (map[string]interface{}, error) {
@@ -0,0 +1,129 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// PaymentMandate pre-authorizes a receiving VASP, e.g. a merchant's, to pull recurring payments from a sender. It is
+// signed by the sending VASP once its user has approved the mandate, and kept by the receiving VASP, which then sends
+// a PaymentPullRequest for each payment.
+type PaymentMandate struct {
+	// MandateId uniquely identifies the mandate.
+	MandateId string `json:"mandateId"`
+	// PayerIdentifier is the UMA address of the sender whose payments are pulled, e.g. $alice@vasp1.com.
+	PayerIdentifier string `json:"payerIdentifier"`
+	// PayeeIdentifier is the UMA address of the receiver allowed to pull payments, e.g. $shop@vasp2.com.
+	PayeeIdentifier string `json:"payeeIdentifier"`
+	// CurrencyCode is the currency of MaxAmountPerPull, e.g. USD, or SAT for millisatoshis.
+	CurrencyCode string `json:"currency"`
+	// MaxAmountPerPull is the largest amount each pull may request, in the smallest unit of the currency.
+	MaxAmountPerPull int64 `json:"maxAmountPerPull"`
+	// IntervalSeconds is the minimum time between two pulls, e.g. 2592000 for a monthly subscription.
+	IntervalSeconds int64 `json:"intervalSeconds"`
+	// ExpirationTimestamp is the unix timestamp after which no more payments may be pulled.
+	ExpirationTimestamp int64 `json:"expiresAt"`
+	// VaspDomain is the domain of the sending VASP, which signs the mandate.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(MandateId|PayerIdentifier|PayeeIdentifier|CurrencyCode|
+	// MaxAmountPerPull|IntervalSeconds|ExpirationTimestamp|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the mandate was signed. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (m *PaymentMandate) SignablePayload() ([]byte, error) {
+	if m.Nonce == "" || m.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		m.MandateId,
+		m.PayerIdentifier,
+		m.PayeeIdentifier,
+		m.CurrencyCode,
+		strconv.FormatInt(m.MaxAmountPerPull, 10),
+		strconv.FormatInt(m.IntervalSeconds, 10),
+		strconv.FormatInt(m.ExpirationTimestamp, 10),
+		m.Nonce,
+		strconv.FormatInt(m.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
+
+// PaymentPullRequest is sent by the receiving VASP to pull one payment under a PaymentMandate. The sending VASP pays
+// its invoice if the pull is within the limits of the mandate.
+type PaymentPullRequest struct {
+	// MandateId identifies the mandate authorizing the pull.
+	MandateId string `json:"mandateId"`
+	// PullId uniquely identifies the pull, so that the sending VASP can ignore duplicate deliveries.
+	PullId string `json:"pullId"`
+	// Amount is the amount pulled, in the smallest unit of the mandate's currency.
+	Amount int64 `json:"amount"`
+	// EncodedInvoice is the BOLT11 invoice for the pull, which the sending VASP pays.
+	EncodedInvoice string `json:"invoice"`
+	// VaspDomain is the domain of the receiving VASP, which signs the request.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(MandateId|PullId|Amount|EncodedInvoice|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the request was sent. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (r *PaymentPullRequest) SignablePayload() ([]byte, error) {
+	if r.Nonce == "" || r.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		r.MandateId,
+		r.PullId,
+		strconv.FormatInt(r.Amount, 10),
+		r.EncodedInvoice,
+		r.Nonce,
+		strconv.FormatInt(r.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
+
+// PaymentMandateRevocation is sent by either VASP to revoke a PaymentMandate, for example when the sender cancels a
+// subscription. No payments may be pulled under a revoked mandate.
+type PaymentMandateRevocation struct {
+	// MandateId identifies the revoked mandate.
+	MandateId string `json:"mandateId"`
+	// Reason optionally describes why the mandate was revoked.
+	Reason *string `json:"reason,omitempty"`
+	// VaspDomain is the domain of the VASP revoking the mandate, which signs the revocation.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(MandateId|VaspDomain|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the mandate was revoked. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (r *PaymentMandateRevocation) SignablePayload() ([]byte, error) {
+	if r.Nonce == "" || r.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		r.MandateId,
+		r.VaspDomain,
+		r.Nonce,
+		strconv.FormatInt(r.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
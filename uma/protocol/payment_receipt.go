@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// PaymentReceipt is a record of a settled payment which the sending and receiving VASPs can persist and exchange, so
+// that they agree on what was paid when resolving disputes. Each VASP signs the receipts it sends, and the payment
+// preimage proves that the invoice was paid.
+type PaymentReceipt struct {
+	// PaymentHash is the hex-encoded payment hash of the invoice, which identifies the payment.
+	PaymentHash string `json:"paymentHash"`
+	// Preimage is the hex-encoded payment preimage, if known. The sending VASP learns it when the payment settles.
+	Preimage *string `json:"preimage,omitempty"`
+	// EncodedInvoice is the BOLT11 invoice that was paid.
+	EncodedInvoice string `json:"invoice"`
+	// AmountMsats is the amount of the invoice, in millisatoshis.
+	AmountMsats int64 `json:"amountMsats"`
+	// ReceivedAmount is the amount the receiver received, in the smallest unit of ReceivedCurrencyCode, if the
+	// receiving VASP converted the payment.
+	ReceivedAmount *int64 `json:"receivedAmount,omitempty"`
+	// ReceivedCurrencyCode is the currency the receiver received, if the receiving VASP converted the payment.
+	ReceivedCurrencyCode *string `json:"receivedCurrency,omitempty"`
+	// SenderIdentifier is the UMA address of the sender, e.g. $alice@vasp1.com.
+	SenderIdentifier string `json:"senderIdentifier"`
+	// ReceiverIdentifier is the UMA address of the receiver, e.g. $bob@vasp2.com.
+	ReceiverIdentifier string `json:"receiverIdentifier"`
+	// SettledAt is the unix timestamp of when the payment settled.
+	SettledAt int64 `json:"settledAt"`
+	// VaspDomain is the domain of the VASP which signs the receipt. It must be the domain of either the sender or the
+	// receiver.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(PaymentHash|Preimage|AmountMsats|ReceivedAmount|
+	// ReceivedCurrencyCode|SenderIdentifier|ReceiverIdentifier|SettledAt|VaspDomain|Nonce|Timestamp), where missing
+	// optional fields are empty.
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the receipt was signed. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (r *PaymentReceipt) SignablePayload() ([]byte, error) {
+	if r.Nonce == "" || r.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	preimage := ""
+	if r.Preimage != nil {
+		preimage = *r.Preimage
+	}
+	receivedAmount := ""
+	if r.ReceivedAmount != nil {
+		receivedAmount = strconv.FormatInt(*r.ReceivedAmount, 10)
+	}
+	receivedCurrencyCode := ""
+	if r.ReceivedCurrencyCode != nil {
+		receivedCurrencyCode = *r.ReceivedCurrencyCode
+	}
+	payloadString := strings.Join([]string{
+		r.PaymentHash,
+		preimage,
+		strconv.FormatInt(r.AmountMsats, 10),
+		receivedAmount,
+		receivedCurrencyCode,
+		r.SenderIdentifier,
+		r.ReceiverIdentifier,
+		strconv.FormatInt(r.SettledAt, 10),
+		r.VaspDomain,
+		r.Nonce,
+		strconv.FormatInt(r.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
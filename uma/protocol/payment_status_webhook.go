@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// PaymentStatus is the outcome of a payment reported in a PaymentStatusWebhook.
+type PaymentStatus string
+
+const (
+	// PaymentStatusPaid indicates that the invoice was paid.
+	PaymentStatusPaid PaymentStatus = "PAID"
+	// PaymentStatusFailed indicates that the payment failed and will not be retried.
+	PaymentStatusFailed PaymentStatus = "FAILED"
+	// PaymentStatusExpired indicates that the invoice expired before it was paid.
+	PaymentStatusExpired PaymentStatus = "EXPIRED"
+)
+
+// IsValid returns true if the status is one of the known payment statuses.
+func (s PaymentStatus) IsValid() bool {
+	return s == PaymentStatusPaid || s == PaymentStatusFailed || s == PaymentStatusExpired
+}
+
+// PaymentStatusWebhook is sent between VASPs to asynchronously notify the counterparty of the outcome of a payment,
+// after the pay request has been answered.
+type PaymentStatusWebhook struct {
+	// EncodedInvoice is the BOLT11 invoice from the pay request response, which identifies the payment.
+	EncodedInvoice string `json:"invoice"`
+	// Status is the outcome of the payment.
+	Status PaymentStatus `json:"status"`
+	// FailureReason optionally describes why the payment failed or expired.
+	FailureReason *string `json:"failureReason,omitempty"`
+	// VaspDomain is the domain of the VASP that is sending the webhook.
+	// It will be used by the counterparty VASP to fetch the public keys of the sender.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(EncodedInvoice|Status|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the webhook was sent. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (w *PaymentStatusWebhook) SignablePayload() ([]byte, error) {
+	if w.Nonce == "" || w.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		w.EncodedInvoice,
+		string(w.Status),
+		w.Nonce,
+		strconv.FormatInt(w.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
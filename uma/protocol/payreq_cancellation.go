@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// PayReqCancellation is sent by the sending VASP to tell the receiving VASP that the invoice from a pay request
+// response will not be paid, so that the receiving VASP can release any quote or liquidity held for it before the
+// invoice expires.
+type PayReqCancellation struct {
+	// EncodedInvoice is the BOLT11 invoice from the pay request response, which identifies the payment.
+	EncodedInvoice string `json:"invoice"`
+	// Reason optionally describes why the payment was cancelled, e.g. "declined by sender".
+	Reason *string `json:"reason,omitempty"`
+	// VaspDomain is the domain of the sending VASP, which signs the cancellation.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(EncodedInvoice|VaspDomain|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the cancellation was sent. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (c *PayReqCancellation) SignablePayload() ([]byte, error) {
+	if c.Nonce == "" || c.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		c.EncodedInvoice,
+		c.VaspDomain,
+		c.Nonce,
+		strconv.FormatInt(c.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
@@ -24,9 +24,15 @@ type PayReqResponse struct {
 	Disposable *bool `json:"disposable,omitempty"`
 	// SuccessAction defines a struct which can be stored and shown to the user on payment success. See LUD-09.
 	SuccessAction *map[string]string `json:"successAction,omitempty"`
+	// InvoiceExpiry is an optional hint of the number of seconds after which EncodedInvoice expires, as set by the
+	// receiving VASP. The invoice itself remains the source of truth.
+	InvoiceExpiry *int64 `json:"expiry,omitempty"`
 	// UmaMajorVersion is the major version of the UMA protocol that the receiver is using. Only used
 	// for serialization and deserialization. Not included in the JSON response.
 	UmaMajorVersion int `json:"umaMajorVersion"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
 }
 
 func (p *PayReqResponse) IsUmaResponse() bool {
@@ -69,6 +75,27 @@ type PayReqResponsePaymentInfo struct {
 	// ExchangeFeesMillisatoshi is the fees charged (in millisats) by the receiving VASP for this transaction. This is
 	// separate from the Multiplier.
 	ExchangeFeesMillisatoshi int64 `json:"fee"`
+	// QuoteId optionally identifies the quote that the Multiplier comes from, so that it can be referenced later, for
+	// example in support requests.
+	QuoteId *string `json:"quoteId,omitempty"`
+	// ExpiresAt is the optional unix timestamp (in seconds since epoch) until which the receiver commits to the
+	// Multiplier. If set, the invoice must not expire after this time.
+	ExpiresAt *int64 `json:"expiresAt,omitempty"`
+	// Fees optionally itemizes ExchangeFeesMillisatoshi and the expected network fees, so that they can be disclosed
+	// to the sender before they authorize the payment. Only supported from UMA v1.
+	Fees *PaymentFees `json:"fees,omitempty"`
+}
+
+// PaymentFees itemizes the fees of a payment. All amounts are in millisatoshis.
+type PaymentFees struct {
+	// ExchangeFeeMillisatoshi is the fee charged by the receiving VASP to convert to the receiving currency.
+	ExchangeFeeMillisatoshi int64 `json:"exchangeFee"`
+	// ServiceFeeMillisatoshi is any other fee charged by the receiving VASP. Together with ExchangeFeeMillisatoshi, it
+	// must add up to the ExchangeFeesMillisatoshi of the payment info.
+	ServiceFeeMillisatoshi int64 `json:"serviceFee"`
+	// NetworkFeeEstimateMillisatoshi is an optional estimate of the lightning routing fees for paying the invoice. It
+	// is not included in the invoice amount, since the routing fees are paid by the sender's node.
+	NetworkFeeEstimateMillisatoshi *int64 `json:"networkFeeEstimate,omitempty"`
 }
 
 type v0PayReqResponsePaymentInfo struct {
@@ -85,6 +112,7 @@ type v0PayReqResponse struct {
 	PayeeData      *PayeeData                   `json:"payeeData,omitempty"`
 	Disposable     *bool                        `json:"disposable,omitempty"`
 	SuccessAction  *map[string]string           `json:"successAction,omitempty"`
+	InvoiceExpiry  *int64                       `json:"expiry,omitempty"`
 	Compliance     *CompliancePayeeData         `json:"compliance,omitempty"`
 }
 
@@ -95,6 +123,7 @@ type v1PayReqResponse struct {
 	PayeeData      *PayeeData                 `json:"payeeData,omitempty"`
 	Disposable     *bool                      `json:"disposable,omitempty"`
 	SuccessAction  *map[string]string         `json:"successAction,omitempty"`
+	InvoiceExpiry  *int64                     `json:"expiry,omitempty"`
 }
 
 func (p *PayReqResponse) asV0() (*v0PayReqResponse, error) {
@@ -105,6 +134,11 @@ func (p *PayReqResponse) asV0() (*v0PayReqResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	payeeData := p.PayeeData
+	if compliance != nil && !majorVersionSupportsFeature(p.UmaMajorVersion, ProtocolFeaturePayeeBackingSignatures) {
+		compliance.BackingSignatures = nil
+		payeeData = payeeDataWithoutBackingSignatures(*p.PayeeData)
+	}
 	var v0PaymentInfo *v0PayReqResponsePaymentInfo
 	if p.PaymentInfo != nil {
 		v0PaymentInfo = &v0PayReqResponsePaymentInfo{
@@ -118,13 +152,35 @@ func (p *PayReqResponse) asV0() (*v0PayReqResponse, error) {
 		EncodedInvoice: p.EncodedInvoice,
 		Routes:         p.Routes,
 		PaymentInfo:    v0PaymentInfo,
-		PayeeData:      p.PayeeData,
+		PayeeData:      payeeData,
 		Disposable:     p.Disposable,
 		SuccessAction:  p.SuccessAction,
+		InvoiceExpiry:  p.InvoiceExpiry,
 		Compliance:     compliance,
 	}, nil
 }
 
+// payeeDataWithoutBackingSignatures returns a copy of the payee data without the backing signatures of its compliance
+// data, for versions which do not support them.
+func payeeDataWithoutBackingSignatures(payeeData PayeeData) *PayeeData {
+	complianceMap, ok := payeeData["compliance"].(map[string]interface{})
+	if !ok {
+		return &payeeData
+	}
+	payeeDataCopy := make(PayeeData, len(payeeData))
+	for key, value := range payeeData {
+		payeeDataCopy[key] = value
+	}
+	complianceCopy := make(map[string]interface{}, len(complianceMap))
+	for key, value := range complianceMap {
+		if key != "backingSignatures" {
+			complianceCopy[key] = value
+		}
+	}
+	payeeDataCopy["compliance"] = complianceCopy
+	return &payeeDataCopy
+}
+
 func (p *PayReqResponse) asV1() *v1PayReqResponse {
 	if p.UmaMajorVersion != 1 {
 		return nil
@@ -136,6 +192,7 @@ func (p *PayReqResponse) asV1() *v1PayReqResponse {
 		PayeeData:      p.PayeeData,
 		Disposable:     p.Disposable,
 		SuccessAction:  p.SuccessAction,
+		InvoiceExpiry:  p.InvoiceExpiry,
 	}
 }
 
@@ -192,6 +249,7 @@ func (p *PayReqResponse) UnmarshalJSON(data []byte) error {
 		p.PayeeData = v0.PayeeData
 		p.Disposable = v0.Disposable
 		p.SuccessAction = v0.SuccessAction
+		p.InvoiceExpiry = v0.InvoiceExpiry
 		return nil
 	}
 
@@ -207,5 +265,6 @@ func (p *PayReqResponse) UnmarshalJSON(data []byte) error {
 	p.PayeeData = v1.PayeeData
 	p.Disposable = v1.Disposable
 	p.SuccessAction = v1.SuccessAction
+	p.InvoiceExpiry = v1.InvoiceExpiry
 	return nil
 }
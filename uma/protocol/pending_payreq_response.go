@@ -0,0 +1,57 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// PayReqStatusPending is the status of a PendingPayReqResponse.
+const PayReqStatusPending = "PENDING"
+
+// PendingPayReqResponse is returned by the receiving VASP, with the status code 202 Accepted, instead of a
+// PayReqResponse when it holds a pay request for review before creating an invoice. The sender retrieves the final
+// response by polling the pay request callback with the RetrievalToken, or when notified with a
+// PayReqResolutionWebhook.
+type PendingPayReqResponse struct {
+	// Status is always PayReqStatusPending.
+	Status string `json:"status"`
+	// RetrievalToken identifies the held pay request when polling for its final response.
+	RetrievalToken string `json:"retrievalToken"`
+	// Reason optionally describes why the pay request is under review.
+	Reason *string `json:"reason,omitempty"`
+	// RetryAfterSec is the number of seconds the sender should wait before polling again, if set.
+	RetryAfterSec *int64 `json:"retryAfterSec,omitempty"`
+}
+
+// PayReqResolutionWebhook is sent by the receiving VASP to the PendingCallback of a pay request it held for review,
+// once the review is complete. It carries no invoice: the sender retrieves the final response by polling the pay
+// request callback with the RetrievalToken.
+type PayReqResolutionWebhook struct {
+	// RetrievalToken is the token from the PendingPayReqResponse of the held pay request.
+	RetrievalToken string `json:"retrievalToken"`
+	// VaspDomain is the domain of the receiving VASP, which signs the webhook.
+	VaspDomain string `json:"vaspDomain"`
+	// Signature is the base64-encoded signature of sha256(RetrievalToken|VaspDomain|Nonce|Timestamp).
+	Signature string `json:"signature"`
+	// Nonce is a random string that is used to prevent replay attacks.
+	Nonce string `json:"signatureNonce"`
+	// Timestamp is the unix timestamp of when the webhook was sent. Used in the signature.
+	Timestamp int64 `json:"signatureTimestamp"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
+}
+
+func (w *PayReqResolutionWebhook) SignablePayload() ([]byte, error) {
+	if w.Nonce == "" || w.Timestamp == 0 {
+		return nil, errors.New("nonce and timestamp must be set")
+	}
+	payloadString := strings.Join([]string{
+		w.RetrievalToken,
+		w.VaspDomain,
+		w.Nonce,
+		strconv.FormatInt(w.Timestamp, 10),
+	}, "|")
+	return []byte(payloadString), nil
+}
@@ -19,6 +19,12 @@ type PostTransactionCallback struct {
 	Nonce *string `json:"signatureNonce,omitempty"`
 	// Timestamp is the unix timestamp of when the request was sent. Used in the signature.
 	Timestamp *int64 `json:"signatureTimestamp,omitempty"`
+	// IdempotencyKey optionally identifies this callback, so that the receiver can ignore duplicate deliveries rather
+	// than registering the same compliance event twice. It is not signed.
+	IdempotencyKey *string `json:"idempotencyKey,omitempty"`
+	// RawResponse is the exact JSON body this message was parsed from. It is only set when parsed with
+	// uma.WithRawPayloadCapture, and is never serialized.
+	RawResponse []byte `json:"-"`
 }
 
 // UtxoWithAmount is a pair of utxo and amount transferred over that corresponding channel.
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PostalAddress is a structured postal address of a payer or payee, sent in payer or payee data under the
+// "postalAddress" field, so that counterparties can parse addresses required by travel rule regulations.
+type PostalAddress struct {
+	// AddressLines are the street address lines, e.g. the street, house number and apartment, in the order in which
+	// they are printed.
+	AddressLines []string `json:"addressLines"`
+	// City is the city, town or locality.
+	City string `json:"city"`
+	// Region is the state, province or other first-level subdivision, if the country uses one in addresses.
+	Region *string `json:"region,omitempty"`
+	// PostalCode is the postal or ZIP code, if the country uses one.
+	PostalCode *string `json:"postalCode,omitempty"`
+	// CountryCode is the ISO 3166-1 alpha-2 code of the country, e.g. US.
+	CountryCode string `json:"countryCode"`
+}
+
+// Validate checks that the address has at least one address line and a city, and that its country code is an
+// assigned ISO 3166-1 alpha-2 code.
+func (a *PostalAddress) Validate() error {
+	hasAddressLine := false
+	for _, line := range a.AddressLines {
+		if strings.TrimSpace(line) != "" {
+			hasAddressLine = true
+		}
+	}
+	if !hasAddressLine {
+		return errors.New("postal address has no address lines")
+	}
+	if strings.TrimSpace(a.City) == "" {
+		return errors.New("postal address has no city")
+	}
+	if !IsValidCountryCode(a.CountryCode) {
+		return fmt.Errorf("%q is not an ISO 3166-1 alpha-2 country code", a.CountryCode)
+	}
+	return nil
+}
+
+func (a *PostalAddress) AsMap() (map[string]interface{}, error) {
+	addressJson, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	var addressMap map[string]interface{}
+	err = json.Unmarshal(addressJson, &addressMap)
+	if err != nil {
+		return nil, err
+	}
+	return addressMap, nil
+}
+
+// postalAddressFromField parses the postal address in a payer or payee data field, or returns nil if there is none.
+func postalAddressFromField(value interface{}, ok bool) (*PostalAddress, error) {
+	if !ok || value == nil {
+		return nil, nil
+	}
+	addressMap, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, errors.New("postal address must be an object")
+	}
+	addressJson, err := json.Marshal(addressMap)
+	if err != nil {
+		return nil, err
+	}
+	var address PostalAddress
+	if err = json.Unmarshal(addressJson, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// IsValidCountryCode returns whether a code is an officially assigned ISO 3166-1 alpha-2 country code. Codes must be
+// uppercase.
+func IsValidCountryCode(code string) bool {
+	return len(code) == 2 && strings.Contains(iso3166Alpha2Codes, " "+code+" ")
+}
+
+// iso3166Alpha2Codes are the officially assigned ISO 3166-1 alpha-2 codes, separated and surrounded by spaces.
+const iso3166Alpha2Codes = " " +
+	"AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ " +
+	"BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ " +
+	"CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ " +
+	"DE DJ DK DM DO DZ " +
+	"EC EE EG EH ER ES ET " +
+	"FI FJ FK FM FO FR " +
+	"GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY " +
+	"HK HM HN HR HT HU " +
+	"ID IE IL IM IN IO IQ IR IS IT " +
+	"JE JM JO JP " +
+	"KE KG KH KI KM KN KP KR KW KY KZ " +
+	"LA LB LC LI LK LR LS LT LU LV LY " +
+	"MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ " +
+	"NA NC NE NF NG NI NL NO NP NR NU NZ " +
+	"OM " +
+	"PA PE PF PG PH PK PL PM PN PR PS PT PW PY " +
+	"QA " +
+	"RE RO RS RU RW " +
+	"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ " +
+	"TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ " +
+	"UA UG UM US UY UZ " +
+	"VA VC VE VG VI VN VU " +
+	"WF WS " +
+	"YE YT " +
+	"ZA ZM ZW "
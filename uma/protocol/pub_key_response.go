@@ -4,9 +4,21 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"time"
+
 	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
 )
 
+// SigningAlgorithm is the signature algorithm of a VASP's signing keys.
+type SigningAlgorithm string
+
+const (
+	// SigningAlgorithmSecp256k1 is ECDSA over secp256k1 with SHA-256 and DER-encoded signatures. It is the default.
+	SigningAlgorithmSecp256k1 SigningAlgorithm = "secp256k1"
+	// SigningAlgorithmEd25519 is Ed25519 as defined in RFC 8032, with 64-byte signatures over the unhashed payload.
+	SigningAlgorithmEd25519 SigningAlgorithm = "ed25519"
+)
+
 // PubKeyResponse is sent from a VASP to another VASP to provide its public keys.
 // It is the response to GET requests at `/.well-known/lnurlpubkey`.
 type PubKeyResponse struct {
@@ -21,10 +33,57 @@ type PubKeyResponse struct {
 	// ExpirationTimestamp [Optional] Seconds since epoch at which these pub keys must be refreshed.
 	// They can be safely cached until this expiration (or forever if null).
 	ExpirationTimestamp *int64
+	// SigningKeyId [Optional] identifies the signing key in SigningCertChain or SigningPubKeyHex.
+	SigningKeyId *string
+	// SigningKeys [Optional] are additional signing keys which are also valid, so that a VASP can rotate its signing
+	// key without downtime by advertising the old and new keys at the same time. The signing key in SigningCertChain
+	// or SigningPubKeyHex is still required for counterparties which do not support multiple keys.
+	SigningKeys []SigningKey
+	// SigningAlgorithm [Optional] is the algorithm of the signing key in SigningPubKeyHex. Defaults to
+	// SigningAlgorithmSecp256k1, which is also the only algorithm supported for SigningCertChain.
+	SigningAlgorithm *SigningAlgorithm
+}
+
+// SigningKey is an additional signing key of a VASP, advertised in PubKeyResponse.SigningKeys.
+type SigningKey struct {
+	// KeyId identifies the key, so that counterparties can tell which key verified a signature.
+	KeyId string `json:"keyId"`
+	// PubKeyHex is the hex-encoded secp256k1 public key.
+	PubKeyHex string `json:"publicKey"`
+	// ExpirationTimestamp [Optional] Seconds since epoch after which signatures by this key must be rejected.
+	ExpirationTimestamp *int64 `json:"expirationTimestamp,omitempty"`
+	// Algorithm [Optional] is the algorithm of the key. Defaults to SigningAlgorithmSecp256k1.
+	Algorithm *SigningAlgorithm `json:"algorithm,omitempty"`
+}
+
+// IsExpired returns whether the key has expired at the given time.
+func (k *SigningKey) IsExpired(now time.Time) bool {
+	return k.ExpirationTimestamp != nil && now.Unix() >= *k.ExpirationTimestamp
+}
+
+// PubKey returns the decoded public key.
+func (k *SigningKey) PubKey() ([]byte, error) {
+	return hex.DecodeString(k.PubKeyHex)
+}
+
+// PubKeyAlgorithm returns the algorithm of the key.
+func (k *SigningKey) PubKeyAlgorithm() SigningAlgorithm {
+	if k.Algorithm == nil {
+		return SigningAlgorithmSecp256k1
+	}
+	return *k.Algorithm
+}
+
+// SigningPubKeyAlgorithm returns the algorithm of the key returned by SigningPubKey.
+func (r *PubKeyResponse) SigningPubKeyAlgorithm() SigningAlgorithm {
+	if r.SigningAlgorithm == nil {
+		return SigningAlgorithmSecp256k1
+	}
+	return *r.SigningAlgorithm
 }
 
 func (r *PubKeyResponse) SigningPubKey() ([]byte, error) {
-	if r.SigningCertChain != nil {
+	if r.SigningCertChain != nil && r.SigningPubKeyAlgorithm() == SigningAlgorithmSecp256k1 {
 		publicKey, err := utils.ExtractPubkeyFromPemCertificateChain(r.SigningCertChain)
 		if err != nil {
 			return nil, err
@@ -74,6 +133,9 @@ func (r *PubKeyResponse) MarshalJSON() ([]byte, error) {
 		r.SigningPubKeyHex,
 		r.EncryptionPubKeyHex,
 		r.ExpirationTimestamp,
+		r.SigningKeyId,
+		r.SigningKeys,
+		r.SigningAlgorithm,
 	}
 	return json.Marshal(m)
 }
@@ -96,13 +158,19 @@ func (r *PubKeyResponse) UnmarshalJSON(data []byte) error {
 	r.SigningPubKeyHex = temp.SigningPubKeyHex
 	r.EncryptionPubKeyHex = temp.EncryptionPubKeyHex
 	r.ExpirationTimestamp = temp.ExpirationTimestamp
+	r.SigningKeyId = temp.SigningKeyId
+	r.SigningKeys = temp.SigningKeys
+	r.SigningAlgorithm = temp.SigningAlgorithm
 	return nil
 }
 
 type pubKeyResponseJson struct {
-	SigningCertChainHexDer    *[]string `json:"signingCertChain,omitempty"`
-	EncryptionCertChainHexDer *[]string `json:"encryptionCertChain,omitempty"`
-	SigningPubKeyHex          *string   `json:"signingPubKey,omitempty"`
-	EncryptionPubKeyHex       *string   `json:"encryptionPubKey,omitempty"`
-	ExpirationTimestamp       *int64    `json:"expirationTimestamp,omitempty"`
+	SigningCertChainHexDer    *[]string         `json:"signingCertChain,omitempty"`
+	EncryptionCertChainHexDer *[]string         `json:"encryptionCertChain,omitempty"`
+	SigningPubKeyHex          *string           `json:"signingPubKey,omitempty"`
+	EncryptionPubKeyHex       *string           `json:"encryptionPubKey,omitempty"`
+	ExpirationTimestamp       *int64            `json:"expirationTimestamp,omitempty"`
+	SigningKeyId              *string           `json:"signingKeyId,omitempty"`
+	SigningKeys               []SigningKey      `json:"signingKeys,omitempty"`
+	SigningAlgorithm          *SigningAlgorithm `json:"signingAlgorithm,omitempty"`
 }
@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SettlementAccount is the bank account to which a receiving VASP settles a payment on fiat rails, sent in payee data
+// under the "settlementAccount" field. Since account details are sensitive, receiving VASPs should usually send it
+// encrypted to the sending VASP's encryption key under the "encryptedSettlementAccount" field instead.
+type SettlementAccount struct {
+	// AccountNumber is the domestic account number, if the account is not identified by an IBAN.
+	AccountNumber *string `json:"accountNumber,omitempty"`
+	// Iban is the international bank account number, without spaces, e.g. DE89370400440532013000.
+	Iban *string `json:"iban,omitempty"`
+	// ClearingSystem is the payment rail the account is reached over, e.g. ACH, SEPA or FPS.
+	ClearingSystem *string `json:"clearingSystem,omitempty"`
+	// ClearingCode identifies the bank within the clearing system, e.g. an ABA routing number, a UK sort code or a BIC.
+	ClearingCode *string `json:"clearingCode,omitempty"`
+	// AccountHolderName is the name of the account holder as known to the bank.
+	AccountHolderName *string `json:"accountHolderName,omitempty"`
+}
+
+// Validate checks that the account has an account number or an IBAN, and that the IBAN, if any, is well-formed and
+// has valid check digits.
+func (a *SettlementAccount) Validate() error {
+	if a.Iban == nil && a.AccountNumber == nil {
+		return errors.New("settlement account has neither an account number nor an IBAN")
+	}
+	if a.AccountNumber != nil && strings.TrimSpace(*a.AccountNumber) == "" {
+		return errors.New("settlement account has an empty account number")
+	}
+	if a.Iban != nil && !IsValidIban(*a.Iban) {
+		return fmt.Errorf("%q is not a valid IBAN", MaskAccountNumber(*a.Iban))
+	}
+	return nil
+}
+
+// MaskedAccountNumber returns the IBAN, or the account number if there is no IBAN, with all but its last four
+// characters replaced by *, so that it can be shown to users or logged. Returns an empty string if there is neither.
+func (a *SettlementAccount) MaskedAccountNumber() string {
+	if a.Iban != nil {
+		return MaskAccountNumber(*a.Iban)
+	}
+	if a.AccountNumber != nil {
+		return MaskAccountNumber(*a.AccountNumber)
+	}
+	return ""
+}
+
+func (a *SettlementAccount) AsMap() (map[string]interface{}, error) {
+	accountJson, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	var accountMap map[string]interface{}
+	err = json.Unmarshal(accountJson, &accountMap)
+	if err != nil {
+		return nil, err
+	}
+	return accountMap, nil
+}
+
+// MaskAccountNumber replaces all but the last four characters of an account number or IBAN by *. Numbers of four
+// characters or fewer are masked completely.
+func MaskAccountNumber(accountNumber string) string {
+	const visibleChars = 4
+	if len(accountNumber) <= visibleChars {
+		return strings.Repeat("*", len(accountNumber))
+	}
+	return strings.Repeat("*", len(accountNumber)-visibleChars) + accountNumber[len(accountNumber)-visibleChars:]
+}
+
+// IsValidIban returns whether an IBAN is well-formed, i.e. an ISO 3166-1 alpha-2 country code followed by two check
+// digits and up to 30 alphanumeric characters, and whether its check digits are valid as per ISO 7064 MOD 97-10.
+// Spaces are not allowed, and letters must be uppercase.
+func IsValidIban(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 || !IsValidCountryCode(iban[:2]) {
+		return false
+	}
+	if iban[2] < '0' || iban[2] > '9' || iban[3] < '0' || iban[3] > '9' {
+		return false
+	}
+	// Move the country code and check digits to the end, convert letters to numbers (A = 10, ..., Z = 35) and compute
+	// the remainder of the resulting number divided by 97 digit by digit.
+	remainder := 0
+	for _, char := range iban[4:] + iban[:4] {
+		switch {
+		case char >= '0' && char <= '9':
+			remainder = (remainder*10 + int(char-'0')) % 97
+		case char >= 'A' && char <= 'Z':
+			remainder = (remainder*100 + int(char-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// settlementAccountFromField parses the settlement account in a payee data field, or returns nil if there is none.
+func settlementAccountFromField(value interface{}, ok bool) (*SettlementAccount, error) {
+	if !ok || value == nil {
+		return nil, nil
+	}
+	accountMap, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, errors.New("settlement account must be an object")
+	}
+	accountJson, err := json.Marshal(accountMap)
+	if err != nil {
+		return nil, err
+	}
+	var account SettlementAccount
+	if err = json.Unmarshal(accountJson, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
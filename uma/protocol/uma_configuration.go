@@ -0,0 +1,16 @@
+package protocol
+
+// UmaConfiguration is the configuration document a VASP may publish at /.well-known/uma-configuration to advertise
+// which parts of the protocol it supports.
+type UmaConfiguration struct {
+	// UmaMajorVersions are the major versions of the UMA protocol the VASP supports.
+	UmaMajorVersions []int `json:"uma_major_versions"`
+	// UmaRequestEndpoint is the endpoint at which the VASP accepts UMA invoices to pay, if it supports them.
+	UmaRequestEndpoint *string `json:"uma_request_endpoint,omitempty"`
+	// Currencies are the currencies the VASP's users can generally receive, if it advertises them. The lnurlp response
+	// of each receiver remains the source of truth.
+	Currencies *[]Currency `json:"currencies,omitempty"`
+	// Features are optional protocol features the VASP supports, e.g. "payment_status_webhooks". See
+	// uma.CounterpartyFeature for the known features.
+	Features []string `json:"features,omitempty"`
+}
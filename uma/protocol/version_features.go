@@ -0,0 +1,60 @@
+package protocol
+
+import "fmt"
+
+// ProtocolFeature is a capability of the UMA protocol which was introduced in a specific protocol version.
+// Serialization is gated on these features, so that fields added in a later minor version are left out for older
+// counterparties instead of breaking them.
+type ProtocolFeature string
+
+const (
+	// ProtocolFeatureInvoices is support for UMA invoices, including the invoiceUUID field of pay requests.
+	ProtocolFeatureInvoices ProtocolFeature = "invoices"
+	// ProtocolFeaturePayeeBackingSignatures is support for backing signatures in the compliance payee data of pay
+	// request responses.
+	ProtocolFeaturePayeeBackingSignatures ProtocolFeature = "payee_backing_signatures"
+)
+
+type protocolVersion struct {
+	major int
+	minor int
+}
+
+// protocolFeatureVersions maps each ProtocolFeature to the version which introduced it. Add new features here along
+// with the minor version they ship in.
+var protocolFeatureVersions = map[ProtocolFeature]protocolVersion{
+	ProtocolFeatureInvoices:               {major: 1, minor: 0},
+	ProtocolFeaturePayeeBackingSignatures: {major: 1, minor: 0},
+}
+
+// VersionSupportsFeature returns whether a version of the UMA protocol, e.g. "1.0", supports a ProtocolFeature. Invalid
+// versions and unknown features are not supported.
+func VersionSupportsFeature(version string, feature ProtocolFeature) bool {
+	introducedIn, ok := protocolFeatureVersions[feature]
+	if !ok {
+		return false
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	return major > introducedIn.major || (major == introducedIn.major && minor >= introducedIn.minor)
+}
+
+// HasInvoiceSupport returns whether a version of the UMA protocol supports UMA invoices.
+func HasInvoiceSupport(version string) bool {
+	return VersionSupportsFeature(version, ProtocolFeatureInvoices)
+}
+
+// HasPayeeBackingSignatures returns whether a version of the UMA protocol supports backing signatures in the
+// compliance payee data of pay request responses.
+func HasPayeeBackingSignatures(version string) bool {
+	return VersionSupportsFeature(version, ProtocolFeaturePayeeBackingSignatures)
+}
+
+// majorVersionSupportsFeature returns whether every minor version of a major version supports a ProtocolFeature. It
+// is used for messages which only record their major version, so that features introduced in a later minor version
+// are left out.
+func majorVersionSupportsFeature(majorVersion int, feature ProtocolFeature) bool {
+	return VersionSupportsFeature(fmt.Sprintf("%d.0", majorVersion), feature)
+}
@@ -1,8 +1,10 @@
 package uma
 
 import (
-	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"sync"
 	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
 )
 
 // PublicKeyCache is an interface for a cache of public keys for other VASPs.
@@ -22,32 +24,63 @@ type PublicKeyCache interface {
 	Clear()
 }
 
+// LastKnownPublicKeyCache is implemented by PublicKeyCaches which can return the last public keys cached for a VASP,
+// even if they have expired. This lets the SDK compare them with newly fetched keys, see WithKeyChangeObserver.
+type LastKnownPublicKeyCache interface {
+	// LastKnownPublicKeyForVasp returns the last public key entry cached for a VASP, expired or not, or nil.
+	LastKnownPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse
+}
+
 type InMemoryPublicKeyCache struct {
+	mutex sync.RWMutex
 	cache map[string]*protocol.PubKeyResponse
+	clock Clock
 }
 
 func NewInMemoryPublicKeyCache() *InMemoryPublicKeyCache {
 	return &InMemoryPublicKeyCache{
 		cache: make(map[string]*protocol.PubKeyResponse),
+		clock: SystemClock,
 	}
 }
 
+// SetClock sets the Clock used to check whether cached keys have expired. Defaults to SystemClock.
+func (c *InMemoryPublicKeyCache) SetClock(clock Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clockOrSystem(clock)
+}
+
 func (c *InMemoryPublicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	entry := c.cache[vaspDomain]
-	if entry == nil || (entry.ExpirationTimestamp != nil && time.Unix(*entry.ExpirationTimestamp, 0).Before(time.Now())) {
+	if entry == nil || (entry.ExpirationTimestamp != nil && time.Unix(*entry.ExpirationTimestamp, 0).Before(c.clock.Now())) {
 		return nil
 	}
 	return entry
 }
 
+func (c *InMemoryPublicKeyCache) LastKnownPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cache[vaspDomain]
+}
+
 func (c *InMemoryPublicKeyCache) AddPublicKeyForVasp(vaspDomain string, pubKey *protocol.PubKeyResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.cache[vaspDomain] = pubKey
 }
 
 func (c *InMemoryPublicKeyCache) RemovePublicKeyForVasp(vaspDomain string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	delete(c.cache, vaspDomain)
 }
 
 func (c *InMemoryPublicKeyCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.cache = make(map[string]*protocol.PubKeyResponse)
 }
@@ -0,0 +1,94 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// defaultPrefetchParallelism is the number of public keys PrefetchPublicKeys fetches at once by default.
+const defaultPrefetchParallelism = 4
+
+// WithPrefetchParallelism sets how many public keys PrefetchPublicKeys and WarmPublicKeys fetch at once. Defaults to 4.
+func WithPrefetchParallelism(parallelism int) Option {
+	return func(o *options) {
+		o.prefetchParallelism = parallelism
+	}
+}
+
+// PrefetchPublicKeys Fetches the public keys of the given VASPs concurrently and adds them to the cache, so that the
+// first payment to a frequent counterparty does not wait for a cold public key fetch. Keys which are already cached
+// are not fetched again.
+//
+// Failures for individual domains do not stop the other fetches. The returned error joins the errors of all failed
+// domains, or is nil if all keys were fetched.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	vaspDomains: the domains of the VASPs whose keys to fetch.
+//	cache: the PublicKeyCache cache to fill.
+//	opts: optional settings such as WithPrefetchParallelism and WithRequestDoer.
+func PrefetchPublicKeys(ctx context.Context, vaspDomains []string, cache PublicKeyCache, opts ...Option) error {
+	o := newOptions(opts)
+	parallelism := o.prefetchParallelism
+	if parallelism <= 0 {
+		parallelism = defaultPrefetchParallelism
+	}
+
+	semaphore := make(chan struct{}, parallelism)
+	errs := make([]error, len(vaspDomains))
+	var wg sync.WaitGroup
+	for i, vaspDomain := range vaspDomains {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = fmt.Errorf("%s: %w", vaspDomain, ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func(i int, vaspDomain string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if _, err := FetchPublicKeyForVaspWithContext(ctx, vaspDomain, cache, opts...); err != nil {
+				o.log(ctx, slog.LevelWarn, LogEventPubKeyPrefetchFailed,
+					slog.String("counterparty", vaspDomain), slog.String("error", err.Error()))
+				errs[i] = fmt.Errorf("%s: %w", vaspDomain, err)
+			}
+		}(i, vaspDomain)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// WarmPublicKeys Keeps the public keys of the given VASPs in the cache by calling PrefetchPublicKeys immediately and
+// then an interval after each call, so that expired keys are fetched again before a payment needs them. It blocks
+// until ctx is done, so it is usually run in its own goroutine. Fetch failures are logged and retried at the next
+// interval.
+//
+// Args:
+//
+//	ctx: the context which stops the warmer when done.
+//	vaspDomains: the domains of the VASPs whose keys to keep warm.
+//	cache: the PublicKeyCache cache to fill.
+//	interval: the time between refreshes, measured with the Clock set with WithClock if it is a TimerClock.
+//	opts: optional settings such as WithPrefetchParallelism, WithClock and WithLogger.
+func WarmPublicKeys(
+	ctx context.Context,
+	vaspDomains []string,
+	cache PublicKeyCache,
+	interval time.Duration,
+	opts ...Option,
+) {
+	o := newOptions(opts)
+	for {
+		// Failures are logged by PrefetchPublicKeys.
+		_ = PrefetchPublicKeys(ctx, vaspDomains, cache, opts...)
+		if sleepWithContext(ctx, o.clock, interval) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package uma
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// Quote is the conversion rate commitment a receiving VASP makes in a pay request response. For volatile currencies,
+// receiving VASPs can keep their FX exposure short by only committing to the rate until ExpiresAt.
+type Quote struct {
+	// Id identifies the quote, for example so that it can be referenced in support requests. Optional.
+	Id string
+	// ExpiresAt is the time until which the receiving VASP commits to the conversion rate.
+	ExpiresAt time.Time
+}
+
+// WithQuote sets the quote id and rate expiry advertised in the PaymentInfo of pay request responses. The invoice
+// should expire no later than expiresAt, since senders reject responses whose invoice outlives the quote.
+func WithQuote(quoteId string, expiresAt time.Time) Option {
+	return func(o *options) {
+		o.quote = &Quote{Id: quoteId, ExpiresAt: expiresAt}
+	}
+}
+
+func (o *options) applyQuote(response *protocol.PayReqResponse) {
+	if o.quote == nil || response.PaymentInfo == nil {
+		return
+	}
+	if o.quote.Id != "" {
+		quoteId := o.quote.Id
+		response.PaymentInfo.QuoteId = &quoteId
+	}
+	expiresAt := o.quote.ExpiresAt.Unix()
+	response.PaymentInfo.ExpiresAt = &expiresAt
+}
+
+// ValidateQuoteExpiry Checks that the quote in a pay request response has not expired, and that its invoice does not
+// expire after the quote does, so that the sender cannot pay at a rate the receiver no longer honors. Responses
+// without a quote expiry are always valid. This is called by SendPayRequest.
+//
+// Args:
+//
+//	response: the pay request response from the receiving VASP.
+//	opts: optional settings such as WithClock.
+func ValidateQuoteExpiry(response *protocol.PayReqResponse, opts ...Option) error {
+	if response.PaymentInfo == nil || response.PaymentInfo.ExpiresAt == nil {
+		return nil
+	}
+	quoteExpiresAt := time.Unix(*response.PaymentInfo.ExpiresAt, 0)
+	if quoteExpiresAt.Before(newOptions(opts).clock.Now()) {
+		return fmt.Errorf("quote expired at %s", quoteExpiresAt.UTC().Format(time.RFC3339))
+	}
+	invoice, err := utils.DecodeBolt11(response.EncodedInvoice)
+	if err != nil {
+		return fmt.Errorf("unable to decode invoice to check its expiry against the quote: %w", err)
+	}
+	if invoice.ExpiresAt().After(quoteExpiresAt) {
+		return fmt.Errorf("invoice expires at %s, after the quote expires at %s",
+			invoice.ExpiresAt().UTC().Format(time.RFC3339), quoteExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
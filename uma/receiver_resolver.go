@@ -0,0 +1,202 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ErrReceiverNotFound is returned by a ReceiverResolver for addresses which do not belong to any account. The
+// LnurlpRequestHandler responds to requests for them with 404 Not Found.
+var ErrReceiverNotFound = errors.New("receiver not found")
+
+// ReceiverResolver maps the addresses of receivers, e.g. $bob@vasp2.com, to the IDs of their accounts at the VASP, so
+// that a VASP serving several domains can handle all of them with the same code.
+type ReceiverResolver interface {
+	// ResolveReceiver returns the ID of the account receiving payments at the given address, or ErrReceiverNotFound.
+	ResolveReceiver(ctx context.Context, receiverAddress string) (string, error)
+}
+
+// WithReceiverResolver sets the ReceiverResolver an LnurlpRequestHandler uses to look up the account of each
+// receiver. The account ID is available to the LnurlpResponseFunc via ReceiverAccountIdFromContext.
+func WithReceiverResolver(resolver ReceiverResolver) Option {
+	return func(o *options) {
+		o.receiverResolver = resolver
+	}
+}
+
+type receiverAccountIdKey struct{}
+
+// ReceiverAccountIdFromContext returns the account ID resolved by the ReceiverResolver set with WithReceiverResolver,
+// if any.
+func ReceiverAccountIdFromContext(ctx context.Context) (string, bool) {
+	accountId, ok := ctx.Value(receiverAccountIdKey{}).(string)
+	return accountId, ok
+}
+
+// PayeeIdentifierRewriter maps the public address of a receiver, e.g. $bob@vasp2.com, to the internal identifier of
+// the sub-account which should receive a pay request, e.g. for a custodial VASP which holds a balance per currency
+// behind a single address. It can use the pay request, e.g. its receiving currency, to pick the sub-account.
+type PayeeIdentifierRewriter func(ctx context.Context, payeeIdentifier string, request *protocol.PayRequest) (string, error)
+
+// WithPayeeIdentifierRewriter sets the PayeeIdentifierRewriter used by GetPayReqResponse and
+// GetPayReqResponseWithSigner. The InvoiceCreator is called with the rewritten internal identifier, while the payee
+// data and the signed compliance data keep the public address, so that the response still matches the
+// ReceiverIdentifier of the lnurlp response and its signature verifies for the sender.
+func WithPayeeIdentifierRewriter(rewriter PayeeIdentifierRewriter) Option {
+	return func(o *options) {
+		o.payeeIdentifierRewriter = rewriter
+	}
+}
+
+// invoiceReceiverIdentifier returns the identifier to create the invoice of a pay request for: the payee identifier
+// rewritten by the PayeeIdentifierRewriter, if one is set.
+func (o *options) invoiceReceiverIdentifier(
+	ctx context.Context,
+	payeeIdentifier *string,
+	request *protocol.PayRequest,
+) (*string, error) {
+	if o.payeeIdentifierRewriter == nil || payeeIdentifier == nil {
+		return payeeIdentifier, nil
+	}
+	internalIdentifier, err := o.payeeIdentifierRewriter(ctx, *payeeIdentifier, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewrite payee identifier: %w", err)
+	}
+	return &internalIdentifier, nil
+}
+
+// maxAliasHops limits how many aliases are followed when resolving an address, to protect against alias loops.
+const maxAliasHops = 8
+
+// ReceiverRoutingTable is an in-memory ReceiverResolver. Addresses are matched case-insensitively, with or without the
+// leading $ of UMA addresses. Routes can use a * wildcard for either part of the address:
+//
+//   - bob@vasp2.com matches only that address.
+//   - bob@* matches bob at any domain.
+//   - *@vasp2.com matches any user at vasp2.com. A * in its account ID is replaced by the username, so that
+//     *@vasp2.com routed to "user:*" resolves bob@vasp2.com to "user:bob".
+//
+// Exact routes take precedence over bob@* routes, which take precedence over *@vasp2.com routes. Aliases rewrite an
+// address or a whole domain before routes are matched.
+//
+// A ReceiverRoutingTable is safe for concurrent use.
+type ReceiverRoutingTable struct {
+	mutex   sync.RWMutex
+	routes  map[string]string
+	aliases map[string]string
+}
+
+// NewReceiverRoutingTable Creates an empty ReceiverRoutingTable.
+func NewReceiverRoutingTable() *ReceiverRoutingTable {
+	return &ReceiverRoutingTable{
+		routes:  make(map[string]string),
+		aliases: make(map[string]string),
+	}
+}
+
+// AddRoute routes an address pattern to an account, replacing any route added for the pattern before.
+//
+// Args:
+//
+//	pattern: the address to route, e.g. $bob@vasp2.com, bob@* or *@vasp2.com.
+//	accountId: the ID of the account. For *@domain patterns, a * is replaced by the username.
+func (t *ReceiverRoutingTable) AddRoute(pattern string, accountId string) error {
+	username, domain, err := splitReceiverAddress(pattern)
+	if err != nil {
+		return err
+	}
+	if username == "*" && domain == "*" {
+		return errors.New("routes must not use a wildcard for both the username and the domain")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.routes[username+"@"+domain] = accountId
+	return nil
+}
+
+// AddAlias makes an address or a domain resolve like another one. For example, $bobby@vasp2.com can be an alias of
+// $bob@vasp2.com, and brand.com an alias of vasp2.com, so that $bob@brand.com resolves like $bob@vasp2.com.
+//
+// Args:
+//
+//	alias: the alias address or domain.
+//	target: the address or domain the alias resolves like. It must be an address if the alias is one, and a domain
+//		otherwise.
+func (t *ReceiverRoutingTable) AddAlias(alias string, target string) error {
+	aliasIsAddress := strings.Contains(alias, "@")
+	if aliasIsAddress != strings.Contains(target, "@") {
+		return errors.New("an address can only be an alias of an address, and a domain of a domain")
+	}
+	if aliasIsAddress {
+		aliasUsername, aliasDomain, err := splitReceiverAddress(alias)
+		if err != nil {
+			return err
+		}
+		targetUsername, targetDomain, err := splitReceiverAddress(target)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(alias+target, "*") {
+			return errors.New("aliases must not use wildcards")
+		}
+		alias = aliasUsername + "@" + aliasDomain
+		target = targetUsername + "@" + targetDomain
+	} else {
+		alias = strings.ToLower(alias)
+		target = strings.ToLower(target)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.aliases[alias] = target
+	return nil
+}
+
+func (t *ReceiverRoutingTable) ResolveReceiver(_ context.Context, receiverAddress string) (string, error) {
+	username, domain, err := splitReceiverAddress(receiverAddress)
+	if err != nil {
+		return "", err
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	for hops := 0; ; hops++ {
+		if hops > maxAliasHops {
+			return "", fmt.Errorf("too many aliases resolving %s", receiverAddress)
+		}
+		if target, ok := t.aliases[username+"@"+domain]; ok {
+			username, domain, _ = strings.Cut(target, "@")
+			continue
+		}
+		if target, ok := t.aliases[domain]; ok {
+			domain = target
+			continue
+		}
+		break
+	}
+
+	if accountId, ok := t.routes[username+"@"+domain]; ok {
+		return accountId, nil
+	}
+	if accountId, ok := t.routes[username+"@*"]; ok {
+		return accountId, nil
+	}
+	if accountId, ok := t.routes["*@"+domain]; ok {
+		return strings.ReplaceAll(accountId, "*", username), nil
+	}
+	return "", ErrReceiverNotFound
+}
+
+// splitReceiverAddress splits an address into its normalized username, without the leading $, and domain.
+func splitReceiverAddress(address string) (string, string, error) {
+	username, domain, ok := strings.Cut(strings.ToLower(address), "@")
+	username = strings.TrimPrefix(username, "$")
+	if !ok || username == "" || domain == "" {
+		return "", "", fmt.Errorf("invalid receiver address %q", address)
+	}
+	return username, domain, nil
+}
@@ -0,0 +1,151 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures retries of outbound requests to counterparty VASPs. Retries are opt-in via WithRetryPolicy.
+//
+// Only transient failures are retried: network errors, timeouts, and 408, 429 and 5xx responses. By default, retries
+// resend the exact same signed request (same nonce and timestamp), and requests which are not idempotent (e.g. the
+// payreq POST, which creates an invoice) are not retried at all.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after each retry.
+	Multiplier float64
+	// Jitter is the fraction (between 0 and 1) of each backoff which is randomized to avoid retry storms.
+	Jitter float64
+	// Budget optionally limits the number of retries across all requests sharing it. Nil means unlimited.
+	Budget *RetryBudget
+	// RetryNonIdempotent allows retrying requests which are not idempotent, such as pay requests.
+	RetryNonIdempotent bool
+	// ResignWith, if set, is used to re-sign signed messages with a fresh nonce and timestamp before each retry. This is
+	// useful when the counterparty may have recorded the nonce of a failed attempt.
+	ResignWith UmaSigner
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with 3 attempts and exponential backoff starting at 200ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// WithRetryPolicy enables retries of outbound requests using the given policy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = &policy
+	}
+}
+
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, idempotent bool, statusCode int, err error) bool {
+	if attempt >= p.MaxAttempts || (!idempotent && !p.RetryNonIdempotent) {
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) ||
+			errors.As(err, &ResponseTooLargeError{}) {
+			return false
+		}
+	} else if !isRetryableStatus(statusCode) {
+		return false
+	}
+	return p.Budget == nil || p.Budget.tryAcquire()
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// RetryBudget limits the rate of retries across many requests, so that a struggling counterparty is not overwhelmed
+// by retries. It is a token bucket which holds up to maxRetries tokens and refills at refillPerSecond.
+//
+// A RetryBudget is safe for concurrent use.
+type RetryBudget struct {
+	mu              sync.Mutex
+	tokens          float64
+	maxTokens       float64
+	refillPerSecond float64
+	lastRefill      time.Time
+	clock           Clock
+}
+
+func NewRetryBudget(maxRetries int, refillPerSecond float64) *RetryBudget {
+	return &RetryBudget{
+		tokens:          float64(maxRetries),
+		maxTokens:       float64(maxRetries),
+		refillPerSecond: refillPerSecond,
+		lastRefill:      time.Now(),
+		clock:           SystemClock,
+	}
+}
+
+// SetClock sets the Clock used to refill the budget. Defaults to SystemClock.
+func (b *RetryBudget) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clockOrSystem(clock)
+	b.lastRefill = b.clock.Now()
+}
+
+func (b *RetryBudget) tryAcquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	b.tokens = math.Min(b.maxTokens, b.tokens+now.Sub(b.lastRefill).Seconds()*b.refillPerSecond)
+	b.lastRefill = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sleepWithContext waits for the duration to pass on the clock if it is a TimerClock, or on the system clock
+// otherwise. It returns the error of the context if it is done first.
+func sleepWithContext(ctx context.Context, clock Clock, duration time.Duration) error {
+	var elapsed <-chan time.Time
+	if timerClock, ok := clock.(TimerClock); ok {
+		elapsed = timerClock.After(duration)
+	} else {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		elapsed = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-elapsed:
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package uma
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// RotatingSigningKey is one of the signing keys of a RotatingSigner.
+type RotatingSigningKey struct {
+	// KeyId identifies the key in PubKeyResponse.SigningKeyId and PubKeyResponse.SigningKeys.
+	KeyId string
+	// Signer signs with the key.
+	Signer UmaSigner
+	// PubKey is the SEC1-encoded secp256k1 public key.
+	PubKey []byte
+}
+
+// RotatingSigner is an UmaSigner which moves from an old signing key to a new one without downtime. Counterparties
+// only accept signatures by keys in the PubKeyResponse they cached, so the rotation happens in three phases:
+//
+//  1. Until switchAt, it signs with the old key while advertising both keys, so that counterparties pick up the new
+//     key before it is used.
+//  2. From switchAt, it signs with the new key while still advertising the old key until oldKeyExpiresAt, so that
+//     messages signed with the old key just before the switch can still be verified.
+//  3. From oldKeyExpiresAt, only the new key is advertised, and the RotatingSigner can be replaced by the new
+//     key's signer.
+//
+// Use GetPubKeyResponse to serve the PubKeyResponse matching the current phase.
+type RotatingSigner struct {
+	oldKey          RotatingSigningKey
+	newKey          RotatingSigningKey
+	switchAt        time.Time
+	oldKeyExpiresAt time.Time
+	clock           Clock
+}
+
+// NewRotatingSigner Creates a RotatingSigner.
+//
+// Args:
+//
+//	oldKey: the signing key currently in use.
+//	newKey: the signing key to rotate to.
+//	switchAt: when to start signing with the new key. It must be after every PubKeyResponse which counterparties
+//		cached before the rotation started has expired, i.e. after its ExpirationTimestamp.
+//	oldKeyExpiresAt: when to stop advertising the old key. It must be after switchAt, by at least as long as messages
+//		signed with the old key may take to be verified.
+func NewRotatingSigner(
+	oldKey RotatingSigningKey,
+	newKey RotatingSigningKey,
+	switchAt time.Time,
+	oldKeyExpiresAt time.Time,
+) (*RotatingSigner, error) {
+	if oldKey.Signer == nil || newKey.Signer == nil {
+		return nil, errors.New("missing signer")
+	}
+	if oldKey.KeyId == newKey.KeyId {
+		return nil, errors.New("the old and new keys must have different key IDs")
+	}
+	if !oldKeyExpiresAt.After(switchAt) {
+		return nil, errors.New("the old key must expire after the switch to the new key")
+	}
+	return &RotatingSigner{
+		oldKey:          oldKey,
+		newKey:          newKey,
+		switchAt:        switchAt,
+		oldKeyExpiresAt: oldKeyExpiresAt,
+		clock:           SystemClock,
+	}, nil
+}
+
+// SetClock sets the Clock used to determine the rotation phase. Defaults to SystemClock. It must be called before the
+// RotatingSigner is used.
+func (s *RotatingSigner) SetClock(clock Clock) {
+	s.clock = clockOrSystem(clock)
+}
+
+func (s *RotatingSigner) Sign(payload []byte) ([]byte, error) {
+	return s.currentKey(s.clock.Now()).Signer.Sign(payload)
+}
+
+// CurrentKeyId returns the ID of the key which is currently used to sign.
+func (s *RotatingSigner) CurrentKeyId() string {
+	return s.currentKey(s.clock.Now()).KeyId
+}
+
+func (s *RotatingSigner) currentKey(now time.Time) RotatingSigningKey {
+	if now.Before(s.switchAt) {
+		return s.oldKey
+	}
+	return s.newKey
+}
+
+// GetPubKeyResponse Creates the public key response advertising the signing keys of the current rotation phase. The
+// response expires at the end of the phase, so that counterparties fetch the keys of the next phase in time. Once the
+// old key has expired, the response has no expiration.
+//
+// Args:
+//
+//	encryptionPubKey: the SEC1-encoded secp256k1 public key used to encrypt travel rule information sent to the VASP.
+func (s *RotatingSigner) GetPubKeyResponse(encryptionPubKey []byte) *protocol.PubKeyResponse {
+	now := s.clock.Now()
+	currentKey := s.currentKey(now)
+	signingPubKeyHex := hex.EncodeToString(currentKey.PubKey)
+	encryptionPubKeyHex := hex.EncodeToString(encryptionPubKey)
+	response := &protocol.PubKeyResponse{
+		SigningPubKeyHex:    &signingPubKeyHex,
+		EncryptionPubKeyHex: &encryptionPubKeyHex,
+		SigningKeyId:        &currentKey.KeyId,
+	}
+	switch {
+	case now.Before(s.switchAt):
+		expirationTimestamp := s.switchAt.Unix()
+		response.ExpirationTimestamp = &expirationTimestamp
+		response.SigningKeys = []protocol.SigningKey{{
+			KeyId:     s.newKey.KeyId,
+			PubKeyHex: hex.EncodeToString(s.newKey.PubKey),
+		}}
+	case now.Before(s.oldKeyExpiresAt):
+		expirationTimestamp := s.oldKeyExpiresAt.Unix()
+		response.ExpirationTimestamp = &expirationTimestamp
+		response.SigningKeys = []protocol.SigningKey{{
+			KeyId:               s.oldKey.KeyId,
+			PubKeyHex:           hex.EncodeToString(s.oldKey.PubKey),
+			ExpirationTimestamp: &expirationTimestamp,
+		}}
+	}
+	return response
+}
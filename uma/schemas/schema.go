@@ -0,0 +1,387 @@
+// Package schemas generates JSON Schema documents for the UMA protocol messages from the Go structs of the protocol
+// package, so that VASPs implementing UMA in other languages can validate against the exact shapes this SDK emits.
+// The schemas describe the UMA v1 wire format, and allow additional properties for forwards compatibility.
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document, or a subschema of one. Only the keywords used by the generated schemas are
+// supported.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Id                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 TypeSet            `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// TypeSet is the value of the type keyword of a Schema. It is serialized as a string if it has a single type.
+type TypeSet []string
+
+func (t TypeSet) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *TypeSet) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = TypeSet{single}
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(data, &types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// Messages returns an instance of each protocol message, keyed by message name. Pass them to Generate to get their
+// schemas, or use All.
+func Messages() map[string]interface{} {
+	return map[string]interface{}{
+		"LnurlpResponse":           protocol.LnurlpResponse{},
+		"PayRequest":               protocol.PayRequest{},
+		"PayReqResponse":           protocol.PayReqResponse{},
+		"PubKeyResponse":           protocol.PubKeyResponse{},
+		"PostTransactionCallback":  protocol.PostTransactionCallback{},
+		"UmaConfiguration":         protocol.UmaConfiguration{},
+		"PaymentStatusWebhook":     protocol.PaymentStatusWebhook{},
+		"PaymentReceipt":           protocol.PaymentReceipt{},
+		"PayReqCancellation":       protocol.PayReqCancellation{},
+		"PaymentMandate":           protocol.PaymentMandate{},
+		"PaymentPullRequest":       protocol.PaymentPullRequest{},
+		"PaymentMandateRevocation": protocol.PaymentMandateRevocation{},
+		"PendingPayReqResponse":    protocol.PendingPayReqResponse{},
+		"PayReqResolutionWebhook":  protocol.PayReqResolutionWebhook{},
+	}
+}
+
+// All generates the schemas of all protocol messages, keyed by message name.
+func All() (map[string]*Schema, error) {
+	schemas := make(map[string]*Schema)
+	for name, message := range Messages() {
+		schema, err := Generate(message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate schema of %s: %w", name, err)
+		}
+		schemas[name] = schema
+	}
+	return schemas, nil
+}
+
+// Generate Creates the JSON Schema document of the JSON encoding of a value, usually a protocol message. Structs
+// nested in the value are described in the $defs of the document.
+//
+// Args:
+//
+//	value: the value, or a pointer to it, whose type to describe.
+func Generate(value interface{}) (*Schema, error) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return nil, errors.New("cannot generate a schema for nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	g := &generator{defs: make(map[string]*Schema), defined: make(map[reflect.Type]string)}
+	schema, err := g.inline(t)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = Draft
+	schema.Title = t.Name()
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema, nil
+}
+
+// generator collects the definitions of the structs described by a schema.
+type generator struct {
+	defs    map[string]*Schema
+	defined map[reflect.Type]string
+}
+
+// override describes a type whose JSON encoding differs from its struct fields, because of a custom MarshalJSON.
+type override func(g *generator) (*Schema, error)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// overrides are the schemas of types with a custom JSON encoding. They are set in init, since they refer back to the
+// generator.
+var overrides map[reflect.Type]override
+
+func init() {
+	overrides = map[reflect.Type]override{
+		timeType: func(*generator) (*Schema, error) {
+			return &Schema{Type: TypeSet{"string"}, Format: "date-time"}, nil
+		},
+		reflect.TypeOf(protocol.KycStatus(0)): func(*generator) (*Schema, error) {
+			return &Schema{Type: TypeSet{"string"}, Enum: []interface{}{
+				protocol.KycStatusUnknown.StringValue(),
+				protocol.KycStatusNotVerified.StringValue(),
+				protocol.KycStatusPending.StringValue(),
+				protocol.KycStatusVerified.StringValue(),
+			}}, nil
+		},
+		reflect.TypeOf(protocol.PaymentStatus("")): func(*generator) (*Schema, error) {
+			return &Schema{Type: TypeSet{"string"}, Enum: []interface{}{
+				string(protocol.PaymentStatusPaid),
+				string(protocol.PaymentStatusFailed),
+				string(protocol.PaymentStatusExpired),
+			}}, nil
+		},
+		reflect.TypeOf(protocol.TravelRuleFormat{}): func(*generator) (*Schema, error) {
+			return &Schema{Type: TypeSet{"string"}, Pattern: `^[^@]+(@[^@]+)?$`}, nil
+		},
+		reflect.TypeOf(protocol.PayerData{}): func(g *generator) (*Schema, error) {
+			return g.counterPartyData(reflect.TypeOf(protocol.CompliancePayerData{}))
+		},
+		reflect.TypeOf(protocol.PayeeData{}): func(g *generator) (*Schema, error) {
+			return g.counterPartyData(reflect.TypeOf(protocol.CompliancePayeeData{}))
+		},
+		reflect.TypeOf(protocol.PayRequest{}): func(g *generator) (*Schema, error) {
+			schema, err := g.structSchema(reflect.TypeOf(payRequestWire{}))
+			if err != nil {
+				return nil, err
+			}
+			schema.Properties["amount"].Pattern = `^[0-9]+(\.[A-Za-z0-9]+)?$`
+			return schema, nil
+		},
+		reflect.TypeOf(protocol.PayReqResponse{}): func(g *generator) (*Schema, error) {
+			return g.structSchema(reflect.TypeOf(payReqResponseWire{}))
+		},
+		reflect.TypeOf(protocol.Currency{}): func(g *generator) (*Schema, error) {
+			return g.structSchema(reflect.TypeOf(currencyWire{}))
+		},
+		reflect.TypeOf(protocol.PubKeyResponse{}): func(g *generator) (*Schema, error) {
+			return g.structSchema(reflect.TypeOf(pubKeyResponseWire{}))
+		},
+	}
+}
+
+// The following structs mirror the v1 JSON encoding of the protocol messages with a custom MarshalJSON.
+
+type payRequestWire struct {
+	ReceivingCurrencyCode *string                           `json:"convert,omitempty"`
+	Amount                string                            `json:"amount"`
+	PayerData             *protocol.PayerData               `json:"payerData,omitempty"`
+	RequestedPayeeData    *protocol.CounterPartyDataOptions `json:"payeeData,omitempty"`
+	Comment               *string                           `json:"comment,omitempty"`
+	InvoiceUUID           *string                           `json:"invoiceUUID,omitempty"`
+	IdempotencyKey        *string                           `json:"idempotencyKey,omitempty"`
+	PendingCallback       *string                           `json:"pendingCallback,omitempty"`
+}
+
+type payReqResponseWire struct {
+	EncodedInvoice string                              `json:"pr"`
+	Routes         []protocol.Route                    `json:"routes"`
+	PaymentInfo    *protocol.PayReqResponsePaymentInfo `json:"converted,omitempty"`
+	PayeeData      *protocol.PayeeData                 `json:"payeeData,omitempty"`
+	Disposable     *bool                               `json:"disposable,omitempty"`
+	SuccessAction  *map[string]string                  `json:"successAction,omitempty"`
+	InvoiceExpiry  *int64                              `json:"expiry,omitempty"`
+}
+
+type currencyWire struct {
+	Code                string                       `json:"code"`
+	Name                string                       `json:"name"`
+	Symbol              string                       `json:"symbol"`
+	MillisatoshiPerUnit float64                      `json:"multiplier"`
+	Convertible         protocol.ConvertibleCurrency `json:"convertible"`
+	Decimals            int                          `json:"decimals"`
+}
+
+type pubKeyResponseWire struct {
+	SigningCertChain    *[]string             `json:"signingCertChain,omitempty"`
+	EncryptionCertChain *[]string             `json:"encryptionCertChain,omitempty"`
+	SigningPubKeyHex    *string               `json:"signingPubKey,omitempty"`
+	EncryptionPubKeyHex *string               `json:"encryptionPubKey,omitempty"`
+	ExpirationTimestamp *int64                `json:"expirationTimestamp,omitempty"`
+	SigningKeyId        *string               `json:"signingKeyId,omitempty"`
+	SigningKeys         []protocol.SigningKey `json:"signingKeys,omitempty"`
+}
+
+// counterPartyData describes payerData or payeeData: an object of counterparty data fields, whose compliance field
+// has the given type.
+func (g *generator) counterPartyData(complianceType reflect.Type) (*Schema, error) {
+	compliance, err := g.schemaFor(complianceType)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{
+		Type: TypeSet{"object"},
+		Properties: map[string]*Schema{
+			protocol.CounterPartyDataFieldIdentifier.String(): {Type: TypeSet{"string"}},
+			protocol.CounterPartyDataFieldCompliance.String(): compliance,
+		},
+	}, nil
+}
+
+// schemaFor returns the schema of a type, referencing the definition of named structs and overridden types.
+func (g *generator) schemaFor(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	_, isOverridden := overrides[t]
+	if t.Name() == "" || (t.Kind() != reflect.Struct && !isOverridden) || t == timeType {
+		return g.inline(t)
+	}
+	if name, ok := g.defined[t]; ok {
+		return &Schema{Ref: "#/$defs/" + name}, nil
+	}
+	name := t.Name()
+	for i := 2; g.defs[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", t.Name(), i)
+	}
+	// Reserve the name before generating the definition, in case the type is recursive.
+	g.defined[t] = name
+	g.defs[name] = &Schema{}
+	schema, err := g.inline(t)
+	if err != nil {
+		return nil, err
+	}
+	g.defs[name] = schema
+	return &Schema{Ref: "#/$defs/" + name}, nil
+}
+
+// inline returns the schema of a type, without referencing a definition for the type itself.
+func (g *generator) inline(t reflect.Type) (*Schema, error) {
+	if override, ok := overrides[t]; ok {
+		return override(g)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: TypeSet{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeSet{"integer"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeSet{"number"}}, nil
+	case reflect.String:
+		return &Schema{Type: TypeSet{"string"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings.
+			return &Schema{Type: TypeSet{"string"}}, nil
+		}
+		items, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: TypeSet{"array"}, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: TypeSet{"object"}, AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// structSchema returns the schema of the JSON object encoding a struct. Fields of embedded structs are promoted as
+// by encoding/json, with the fields of the outer struct taking precedence.
+func (g *generator) structSchema(t reflect.Type) (*Schema, error) {
+	schema := &Schema{Type: TypeSet{"object"}, Properties: make(map[string]*Schema)}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, tagOptions, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				embedded = append(embedded, embeddedType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldSchema, err := g.schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+		omitEmpty := strings.Contains(","+tagOptions+",", ",omitempty,")
+		if !omitEmpty {
+			schema.Required = append(schema.Required, name)
+			if isNullable(field.Type) {
+				fieldSchema = &Schema{AnyOf: []*Schema{fieldSchema, {Type: TypeSet{"null"}}}}
+			}
+		}
+		schema.Properties[name] = fieldSchema
+	}
+	for _, embeddedType := range embedded {
+		embeddedSchema, err := g.inline(embeddedType)
+		if err != nil {
+			return nil, err
+		}
+		for name, property := range embeddedSchema.Properties {
+			if _, ok := schema.Properties[name]; ok {
+				continue
+			}
+			schema.Properties[name] = property
+			for _, required := range embeddedSchema.Required {
+				if required == name {
+					schema.Required = append(schema.Required, name)
+				}
+			}
+		}
+	}
+	return schema, nil
+}
+
+// isNullable returns whether a field of the given type is encoded as null when it is not set.
+func isNullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,196 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError is returned by ValidateAgainstSchema when a document does not match a schema.
+type ValidationError struct {
+	// Path is the JSON pointer to the invalid value in the document, e.g. "/payerData/compliance/kycStatus".
+	Path string
+	// Message describes why the value is invalid.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// ValidateAgainstSchema validates a JSON document against a schema generated by Generate, returning a
+// *ValidationError for the first value which does not match.
+//
+// Args:
+//
+//	schema: the schema document to validate against.
+//	document: the JSON document to validate, e.g. the body of a message received from another VASP.
+func ValidateAgainstSchema(schema *Schema, document []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return (&validator{root: schema}).validate(schema, value, "")
+}
+
+// validator validates values against the subschemas of a root schema.
+type validator struct {
+	root *Schema
+}
+
+func (v *validator) validate(schema *Schema, value interface{}, path string) error {
+	if schema.Ref != "" {
+		resolved, err := v.resolve(schema.Ref)
+		if err != nil {
+			return &ValidationError{Path: path, Message: err.Error()}
+		}
+		return v.validate(resolved, value, path)
+	}
+	if len(schema.AnyOf) > 0 {
+		var firstErr error
+		for _, option := range schema.AnyOf {
+			err := v.validate(option, value, path)
+			if err == nil {
+				firstErr = nil
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return firstErr
+		}
+	}
+	if len(schema.Type) > 0 && !matchesAnyType(schema.Type, value) {
+		return &ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, got %s", strings.Join(schema.Type, " or "), jsonType(value)),
+		}
+	}
+	if len(schema.Enum) > 0 && !isInEnum(schema.Enum, value) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("%v is not one of %v", value, schema.Enum)}
+	}
+
+	switch typedValue := value.(type) {
+	case string:
+		if schema.Pattern != "" {
+			pattern, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("invalid pattern: %v", err)}
+			}
+			if !pattern.MatchString(typedValue) {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("%q does not match %s", typedValue, schema.Pattern)}
+			}
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, typedValue); err != nil {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("%q is not a date-time", typedValue)}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := typedValue[name]; !ok {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+		names := make([]string, 0, len(typedValue))
+		for name := range typedValue {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propertyValue := typedValue[name]
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				propertySchema = schema.AdditionalProperties
+			}
+			if propertySchema == nil {
+				continue
+			}
+			if err := v.validate(propertySchema, propertyValue, path+"/"+escapePointerToken(name)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typedValue {
+				if err := v.validate(schema.Items, item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the definition referenced by a $ref of the form "#/$defs/Name".
+func (v *validator) resolve(ref string) (*Schema, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	definition, ok := v.root.Defs[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined $ref %q", ref)
+	}
+	return definition, nil
+}
+
+func matchesAnyType(types []string, value interface{}) bool {
+	valueType := jsonType(value)
+	for _, t := range types {
+		if t == valueType || (t == "number" && valueType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := typedValue.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func isInEnum(enum []interface{}, value interface{}) bool {
+	for _, option := range enum {
+		if reflect.DeepEqual(option, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePointerToken escapes a property name for use in a JSON pointer, as in RFC 6901.
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
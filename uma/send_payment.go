@@ -0,0 +1,222 @@
+package uma
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// ErrNotUmaReceiver is returned by SendPayment when the receiver is a plain LNURL-pay service rather than an UMA VASP,
+// and AllowNonUmaFallback was not set.
+var ErrNotUmaReceiver = errors.New("the receiver is not an UMA VASP")
+
+// AllowNonUmaFallback lets SendPayment pay receivers which are not UMA VASPs as a plain LNURL-pay sender, without
+// compliance data. Without it, SendPayment returns ErrNotUmaReceiver for them.
+func AllowNonUmaFallback() Option {
+	return func(o *options) {
+		o.allowNonUmaFallback = true
+	}
+}
+
+// SendPaymentParams are the parameters of SendPayment.
+type SendPaymentParams struct {
+	// AmountMsats is the amount to send to non-UMA receivers, in millisatoshis. It must be within the sendable range of
+	// the lnurlp response.
+	AmountMsats int64
+	// Comment is an optional comment sent to non-UMA receivers, see LUD-12. It must not be longer than the
+	// lnurlp response allows.
+	Comment *string
+	// CreateUmaPayRequest creates the signed pay request for UMA receivers, for example with GetUmaPayRequest, using
+	// the currencies and encryption key of the receiver. It is not called for non-UMA receivers.
+	CreateUmaPayRequest func(ctx context.Context, lnurlpResponse protocol.UmaLnurlpResponse) (*protocol.PayRequest, error)
+}
+
+// PaymentResult is the result of SendPayment: an *UmaPaymentResult for UMA receivers, or a *LnurlPaymentResult for
+// non-UMA receivers paid with AllowNonUmaFallback.
+type PaymentResult interface {
+	isPaymentResult()
+}
+
+// UmaPaymentResult is the PaymentResult of a payment to an UMA receiver.
+type UmaPaymentResult struct {
+	// PayRequest is the pay request sent to the receiver.
+	PayRequest *protocol.PayRequest
+	// PayReqResponse is the receiver's response. Its signature must still be verified with
+	// VerifyPayReqResponseSignature before paying its invoice.
+	PayReqResponse *protocol.PayReqResponse
+}
+
+func (*UmaPaymentResult) isPaymentResult() {}
+
+// LnurlPaymentResult is the PaymentResult of a payment to a plain LNURL-pay receiver. It has no compliance data, and
+// its invoice was checked against the amount and metadata of the lnurlp response as required by LUD-06.
+type LnurlPaymentResult struct {
+	// AmountMsats is the amount of the invoice, in millisatoshis.
+	AmountMsats int64
+	// PayReqResponse is the receiver's response, holding the invoice to pay and any LUD-09 success action.
+	PayReqResponse *protocol.PayReqResponse
+}
+
+func (*LnurlPaymentResult) isPaymentResult() {}
+
+// SendPayment Sends the pay request of a payment to the receiver of an lnurlp response, as an UMA sender if the
+// receiver is an UMA VASP, or as a plain LNURL-pay sender if it is not and AllowNonUmaFallback is set. Callers
+// distinguish the two with a type switch on the result:
+//
+//	switch result := result.(type) {
+//	case *uma.UmaPaymentResult:
+//		// Verify result.PayReqResponse, then pay it with travel rule data.
+//	case *uma.LnurlPaymentResult:
+//		// Pay result.PayReqResponse.EncodedInvoice without compliance checks.
+//	}
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	lnurlpResponse: the receiver's lnurlp response. For UMA receivers, its signature must already be verified.
+//	params: the parameters of the payment.
+//	opts: optional settings such as AllowNonUmaFallback, WithNetwork and WithRequestDoer.
+func SendPayment(
+	ctx context.Context,
+	lnurlpResponse *protocol.LnurlpResponse,
+	params SendPaymentParams,
+	opts ...Option,
+) (PaymentResult, error) {
+	if umaLnurlpResponse := lnurlpResponse.AsUmaResponse(); umaLnurlpResponse != nil {
+		return sendUmaPayment(ctx, *umaLnurlpResponse, params, opts...)
+	}
+	o := newOptions(opts)
+	if !o.allowNonUmaFallback {
+		return nil, ErrNotUmaReceiver
+	}
+	return o.sendLnurlPayment(ctx, lnurlpResponse, params)
+}
+
+func sendUmaPayment(
+	ctx context.Context,
+	lnurlpResponse protocol.UmaLnurlpResponse,
+	params SendPaymentParams,
+	opts ...Option,
+) (*UmaPaymentResult, error) {
+	if params.CreateUmaPayRequest == nil {
+		return nil, errors.New("missing CreateUmaPayRequest for an UMA receiver")
+	}
+	request, err := params.CreateUmaPayRequest(ctx, lnurlpResponse)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePayRequestAmount(lnurlpResponse.LnurlpResponse, request); err != nil {
+		return nil, err
+	}
+	response, err := SendPayRequest(ctx, lnurlpResponse.Callback, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsUmaResponse() {
+		return nil, errors.New("the receiver responded to an UMA pay request without compliance data")
+	}
+	return &UmaPaymentResult{PayRequest: request, PayReqResponse: response}, nil
+}
+
+// sendLnurlPayment sends a plain LNURL pay request. Unlike SendPayRequest, it skips the ComplianceProvider, since there
+// is no compliance data to screen.
+func (o *options) sendLnurlPayment(
+	ctx context.Context,
+	lnurlpResponse *protocol.LnurlpResponse,
+	params SendPaymentParams,
+) (*LnurlPaymentResult, error) {
+	if err := validateLnurlpResponseForPayment(lnurlpResponse, params.AmountMsats, params.Comment); err != nil {
+		return nil, err
+	}
+	request := protocol.PayRequest{Amount: params.AmountMsats, Comment: params.Comment}
+	outbound, err := newPayReqOutboundRequest(lnurlpResponse.Callback, request)
+	if err != nil {
+		return nil, err
+	}
+	o.log(ctx, slog.LevelInfo, LogEventPayReqSent, slog.String("url", redactQuery(lnurlpResponse.Callback)),
+		slog.Bool("uma", false))
+	responseBodyBytes, err := o.sendRequest(ctx, *outbound)
+	if err != nil {
+		return nil, err
+	}
+	var lnurlError struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(responseBodyBytes, &lnurlError) == nil && lnurlError.Status == "ERROR" {
+		return nil, fmt.Errorf("the receiver rejected the pay request: %s", lnurlError.Reason)
+	}
+	var response protocol.PayReqResponse
+	if err = response.UnmarshalJSON(responseBodyBytes); err != nil {
+		return nil, err
+	}
+	if err = o.validateLnurlInvoice(response.EncodedInvoice, params.AmountMsats, lnurlpResponse.EncodedMetadata); err != nil {
+		return nil, err
+	}
+	return &LnurlPaymentResult{AmountMsats: params.AmountMsats, PayReqResponse: &response}, nil
+}
+
+// validateLnurlpResponseForPayment checks an lnurlp response and the payment to send to it against LUD-06 and LUD-12.
+func validateLnurlpResponseForPayment(response *protocol.LnurlpResponse, amountMsats int64, comment *string) error {
+	if response.Tag != "payRequest" {
+		return fmt.Errorf("unexpected lnurl tag %q", response.Tag)
+	}
+	callbackUrl, err := url.Parse(response.Callback)
+	if err != nil || callbackUrl.Host == "" {
+		return fmt.Errorf("invalid callback URL %q", response.Callback)
+	}
+	if response.MinSendable <= 0 || response.MinSendable > response.MaxSendable {
+		return fmt.Errorf("invalid sendable range %d-%d msats", response.MinSendable, response.MaxSendable)
+	}
+	if err = ValidatePayAmount(*response, amountMsats, "", false); err != nil {
+		return err
+	}
+	var metadata [][]interface{}
+	if err = json.Unmarshal([]byte(response.EncodedMetadata), &metadata); err != nil {
+		return fmt.Errorf("invalid lnurl metadata: %w", err)
+	}
+	hasPlainText := false
+	for _, entry := range metadata {
+		if len(entry) == 2 && entry[0] == "text/plain" {
+			hasPlainText = true
+		}
+	}
+	if !hasPlainText {
+		return errors.New("lnurl metadata has no text/plain entry")
+	}
+	if comment != nil {
+		if response.CommentCharsAllowed == nil || utf8.RuneCountInString(*comment) > *response.CommentCharsAllowed {
+			return errors.New("comment is longer than the receiver allows")
+		}
+	}
+	return nil
+}
+
+// validateLnurlInvoice checks that an LNURL-pay invoice is for the configured Network and the requested amount, and
+// commits to the metadata of the lnurlp response, as required by LUD-06.
+func (o *options) validateLnurlInvoice(encodedInvoice string, amountMsats int64, encodedMetadata string) error {
+	invoice, err := utils.DecodeBolt11(encodedInvoice)
+	if err != nil {
+		return fmt.Errorf("unable to decode invoice: %w", err)
+	}
+	if err = o.checkInvoiceNetwork(invoice); err != nil {
+		return err
+	}
+	if invoice.AmountMsats == nil || *invoice.AmountMsats != amountMsats {
+		return fmt.Errorf("invoice amount does not match the requested %d msats", amountMsats)
+	}
+	metadataHash := sha256.Sum256([]byte(encodedMetadata))
+	if !bytes.Equal(invoice.DescriptionHash, metadataHash[:]) {
+		return errors.New("invoice description hash does not match the lnurl metadata")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package uma
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// PayerIdentifierDomainMismatchError is returned by VerifyPayReqSignature when the payer identifier of a pay request
+// belongs to a different domain than the VASP whose signing key verified it. This means that a VASP signed a pay
+// request on behalf of a payer of another VASP. SenderVaspDomain is empty if the domain of the signing VASP is not
+// known and the payer identifier is not an UMA address.
+type PayerIdentifierDomainMismatchError struct {
+	PayerIdentifier  string
+	SenderVaspDomain string
+}
+
+func (e PayerIdentifierDomainMismatchError) Error() string {
+	if e.SenderVaspDomain == "" {
+		return fmt.Sprintf("payer identifier %s is not an UMA address", e.PayerIdentifier)
+	}
+	return fmt.Sprintf("payer identifier %s does not belong to the signing VASP %s", e.PayerIdentifier, e.SenderVaspDomain)
+}
+
+// WithSenderVaspDomain sets the domain of the VASP whose PubKeyResponse is passed to VerifyPayReqSignature, i.e. the
+// domain the public keys were fetched from. VerifyPayReqSignature then checks that the payer identifier belongs to
+// that domain, to detect payer identifiers spoofed by other VASPs. Verifier and PayRequestHandler set it to the domain
+// they fetched the keys from.
+//
+// Without it, VerifyPayReqSignature assumes that the public keys were fetched from the domain of the payer
+// identifier, and only checks that the payer identifier is an UMA address, so that its domain is well-defined. The
+// SDK cannot check that assumption, so callers which fetched the keys from any other domain must set it.
+func WithSenderVaspDomain(domain string) Option {
+	return func(o *options) {
+		o.senderVaspDomain = domain
+	}
+}
+
+// WithoutPayerIdentifierDomainCheck disables the payer identifier check of VerifyPayReqSignature. Use this when
+// receiving pay requests from intermediaries which legitimately sign pay requests for payers of other domains.
+func WithoutPayerIdentifierDomainCheck() Option {
+	return func(o *options) {
+		o.skipPayerIdentifierDomainCheck = true
+	}
+}
+
+// checkPayerIdentifierDomain checks that the payer identifier is an UMA address of the sender VASP domain, or of any
+// domain if the sender VASP domain is not known.
+func (o *options) checkPayerIdentifierDomain(payerIdentifier string) error {
+	if o.skipPayerIdentifierDomainCheck {
+		return nil
+	}
+	payerDomain, err := GetVaspDomainFromUmaAddress(payerIdentifier)
+	if err == nil && (o.senderVaspDomain == "" || strings.EqualFold(payerDomain, o.senderVaspDomain)) {
+		return nil
+	}
+	o.log(context.Background(), slog.LevelWarn, LogEventPayerIdentifierDomainMismatch,
+		slog.String("payer_identifier", payerIdentifier), slog.String("counterparty", o.senderVaspDomain))
+	return PayerIdentifierDomainMismatchError{PayerIdentifier: payerIdentifier, SenderVaspDomain: o.senderVaspDomain}
+}
@@ -0,0 +1,65 @@
+package uma
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// AddEncryptedSettlementAccount Encrypts a settlement account to the sending VASP's encryption key and adds it to
+// payee data under the "encryptedSettlementAccount" field, so that only the sending VASP can read the account details.
+// Any unencrypted settlement account in the payee data is removed.
+//
+// Args:
+//
+//	payeeData: the payee data of a pay request response.
+//	account: the account to which the receiving VASP settles the payment.
+//	senderEncryptionPubKey: the encryption public key of the sending VASP, from PubKeyResponse.EncryptionPubKey.
+func AddEncryptedSettlementAccount(
+	payeeData protocol.PayeeData,
+	account protocol.SettlementAccount,
+	senderEncryptionPubKey []byte,
+) error {
+	if err := account.Validate(); err != nil {
+		return err
+	}
+	accountJson, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	encryptedAccount, err := eciesEncryptHex(senderEncryptionPubKey, accountJson)
+	if err != nil {
+		return err
+	}
+	delete(payeeData, protocol.CounterPartyDataFieldSettlementAccount.String())
+	payeeData[protocol.CounterPartyDataFieldEncryptedSettlementAccount.String()] = encryptedAccount
+	return nil
+}
+
+// DecryptSettlementAccount Decrypts the settlement account in payee data with the sending VASP's encryption private
+// key. Unencrypted settlement accounts are returned as they are. Returns nil if the payee data has no settlement
+// account.
+//
+// Args:
+//
+//	payeeData: the payee data of a pay request response.
+//	encryptionPrivateKey: the encryption private key of the sending VASP.
+func DecryptSettlementAccount(
+	payeeData protocol.PayeeData,
+	encryptionPrivateKey []byte,
+) (*protocol.SettlementAccount, error) {
+	encryptedAccountHex := payeeData.EncryptedSettlementAccount()
+	if encryptedAccountHex == nil {
+		return payeeData.SettlementAccount()
+	}
+	accountJson, err := eciesDecryptHex(encryptionPrivateKey, *encryptedAccountHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt settlement account: %w", err)
+	}
+	var account protocol.SettlementAccount
+	if err = json.Unmarshal(accountJson, &account); err != nil {
+		return nil, fmt.Errorf("invalid encrypted settlement account: %w", err)
+	}
+	return &account, nil
+}
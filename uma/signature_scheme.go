@@ -0,0 +1,180 @@
+package uma
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// SignatureScheme determines which bytes of a message are signed.
+type SignatureScheme string
+
+const (
+	// SignatureSchemeDelimited signs a pipe-delimited subset of the fields of a message, e.g.
+	// sha256(EncodedInvoice|Status|Nonce|Timestamp) for payment status webhooks. It is the scheme of the UMA
+	// specification and the default.
+	SignatureSchemeDelimited SignatureScheme = "delimited"
+	// SignatureSchemeJcs signs the whole JSON message without its signature, canonicalized with the JSON
+	// Canonicalization Scheme (RFC 8785), so that no field can be altered in transit. Only use it with counterparties
+	// which support FeatureJcsSignatures.
+	SignatureSchemeJcs SignatureScheme = "jcs"
+)
+
+// Messages which can be signed with SignatureSchemeJcs. Other messages are always signed with
+// SignatureSchemeDelimited.
+var jcsSignedMessageTypes = []MessageType{
+	MessageTypePayRequest,
+	MessageTypePaymentStatusWebhook,
+	MessageTypePayReqCancellation,
+	MessageTypePaymentReceipt,
+	MessageTypePayReqResolutionWebhook,
+}
+
+// payRequestUnsignedFields are the fields of a pay request which are not signed with SignatureSchemeJcs. Backing
+// signatures are added by other VASPs after signing, and the idempotency key and pending callback are not signed.
+var payRequestUnsignedFields = []string{
+	"payerData.compliance.signature",
+	"payerData.compliance.backingSignatures",
+	"idempotencyKey",
+	"pendingCallback",
+}
+
+// WithSignatureScheme sets the SignatureScheme used to sign pay requests, payment status webhooks, pay request
+// cancellations, payment receipts and pay request resolution webhooks. Defaults to SignatureSchemeDelimited. Use
+// CounterpartyCapabilities.PreferredSignatureScheme to pick the scheme for a counterparty.
+func WithSignatureScheme(scheme SignatureScheme) Option {
+	return func(o *options) {
+		o.signatureScheme = scheme
+	}
+}
+
+// WithAcceptedSignatureSchemes sets the SignatureSchemes accepted when verifying messages which can be signed with
+// either scheme. By default both are accepted, so that counterparties can migrate to SignatureSchemeJcs at their own
+// pace. Once they have, accept only SignatureSchemeJcs to close the migration window.
+func WithAcceptedSignatureSchemes(schemes ...SignatureScheme) Option {
+	return func(o *options) {
+		o.acceptedSignatureSchemes = schemes
+	}
+}
+
+// PreferredSignatureScheme returns SignatureSchemeJcs if the VASP supports FeatureJcsSignatures, and
+// SignatureSchemeDelimited otherwise.
+func (c *CounterpartyCapabilities) PreferredSignatureScheme() SignatureScheme {
+	if c.SupportsFeature(FeatureJcsSignatures) {
+		return SignatureSchemeJcs
+	}
+	return SignatureSchemeDelimited
+}
+
+// schemePayload is the signable payload of a message under a SignatureScheme.
+type schemePayload struct {
+	scheme  SignatureScheme
+	payload []byte
+}
+
+// signablePayload returns the payload to sign a message with, in the SignatureScheme set with WithSignatureScheme.
+//
+// Args:
+//
+//	messageType: the type of the message, which must be in jcsSignedMessageTypes for SignatureSchemeJcs.
+//	delimitedPayload: returns the payload of the message under SignatureSchemeDelimited.
+//	message: the message to sign, which is serialized for SignatureSchemeJcs.
+//	unsignedFields: the dot-separated paths of the fields of the JSON message which are not signed, starting with the
+//		signature itself, e.g. payerData.compliance.signature.
+func (o *options) signablePayload(
+	messageType MessageType,
+	delimitedPayload func() ([]byte, error),
+	message interface{},
+	unsignedFields ...string,
+) ([]byte, error) {
+	switch o.signatureScheme {
+	case "", SignatureSchemeDelimited:
+		return delimitedPayload()
+	case SignatureSchemeJcs:
+		if !slices.Contains(jcsSignedMessageTypes, messageType) {
+			return nil, fmt.Errorf("%s messages cannot be signed with %s", messageType, SignatureSchemeJcs)
+		}
+		return jcsSignablePayload(message, unsignedFields...)
+	default:
+		return nil, fmt.Errorf("unknown signature scheme %s", o.signatureScheme)
+	}
+}
+
+// schemePayloads returns the signable payloads of a message under each SignatureScheme.
+func schemePayloads(delimitedPayload []byte, message interface{}, unsignedFields ...string) ([]schemePayload, error) {
+	jcsPayload, err := jcsSignablePayload(message, unsignedFields...)
+	if err != nil {
+		return nil, err
+	}
+	return []schemePayload{
+		{scheme: SignatureSchemeDelimited, payload: delimitedPayload},
+		{scheme: SignatureSchemeJcs, payload: jcsPayload},
+	}, nil
+}
+
+// jcsSignablePayload serializes a message to JSON, removes its unsigned fields and canonicalizes the result with
+// RFC 8785.
+func jcsSignablePayload(message interface{}, unsignedFields ...string) ([]byte, error) {
+	messageJson, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(messageJson))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err = decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	for _, unsignedField := range unsignedFields {
+		path := strings.Split(unsignedField, ".")
+		parent := fields
+		for _, key := range path[:len(path)-1] {
+			parent, _ = parent[key].(map[string]interface{})
+		}
+		delete(parent, path[len(path)-1])
+	}
+	unsignedJson, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return utils.CanonicalizeJson(unsignedJson)
+}
+
+func (o *options) acceptsSignatureScheme(scheme SignatureScheme) bool {
+	return o.acceptedSignatureSchemes == nil || slices.Contains(o.acceptedSignatureSchemes, scheme)
+}
+
+// verifySignatureWithSchemes verifies a signature against the payloads of the accepted SignatureSchemes, and returns
+// the id of the matching key and the payload which was signed. Messages with a single payload are always verified
+// against it, since they can only be signed with one scheme.
+func (o *options) verifySignatureWithSchemes(
+	payloads []schemePayload,
+	signature string,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+) (string, []byte, error) {
+	var firstPayload []byte
+	var lastErr error
+	for _, payload := range payloads {
+		if len(payloads) > 1 && !o.acceptsSignatureScheme(payload.scheme) {
+			continue
+		}
+		if firstPayload == nil {
+			firstPayload = payload.payload
+		}
+		keyId, err := o.verifySignature(payload.payload, signature, otherVaspPubKeyResponse)
+		if err == nil {
+			return keyId, payload.payload, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		return "", payloads[0].payload, errors.New("no accepted signature scheme")
+	}
+	return "", firstPayload, lastErr
+}
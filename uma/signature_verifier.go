@@ -0,0 +1,361 @@
+package uma
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxDerSignatureLength is the largest DER encoding of a secp256k1 ECDSA signature.
+const maxDerSignatureLength = 72
+
+// SignatureVerifier verifies secp256k1 ECDSA signatures on UMA payloads. The SDK uses DefaultSignatureVerifier, which
+// is pure Go. High-volume receivers can plug in a faster backend with WithSignatureVerifier, for example one wrapping
+// btcec or libsecp256k1 through cgo.
+//
+// Implementations of this interface should be thread-safe.
+type SignatureVerifier interface {
+	// Verify reports whether derSignature is a valid DER-encoded signature over hash, the SHA-256 hash of a payload,
+	// by publicKey, a SEC1-encoded secp256k1 public key. It should return an error only if the signature or key is
+	// malformed.
+	Verify(hash [sha256.Size]byte, derSignature []byte, publicKey []byte) (bool, error)
+}
+
+// DefaultSignatureVerifier is the SignatureVerifier used unless WithSignatureVerifier is set. It uses
+// github.com/decred/dcrd/dcrec/secp256k1.
+var DefaultSignatureVerifier SignatureVerifier = DecredSignatureVerifier{}
+
+// DecredSignatureVerifier is a SignatureVerifier using the pure Go github.com/decred/dcrd/dcrec/secp256k1 package.
+// The package allocates while parsing the signature and the key and while verifying, so each verification makes a
+// few small heap allocations.
+type DecredSignatureVerifier struct{}
+
+func (DecredSignatureVerifier) Verify(hash [sha256.Size]byte, derSignature []byte, publicKey []byte) (bool, error) {
+	parsedSignature, err := ecdsa.ParseDERSignature(derSignature)
+	if err != nil {
+		return false, err
+	}
+	parsedKey, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return false, err
+	}
+	return parsedSignature.Verify(hash[:], parsedKey), nil
+}
+
+// WithSignatureVerifier sets the SignatureVerifier used to verify signatures from other VASPs. Defaults to
+// DefaultSignatureVerifier.
+func WithSignatureVerifier(verifier SignatureVerifier) Option {
+	return func(o *options) {
+		o.signatureVerifier = verifier
+	}
+}
+
+// SignatureEncoding determines which encodings of secp256k1 ECDSA signatures from other VASPs are accepted. Some
+// SignatureVerifiers, such as ones wrapping btcec, accept encodings which others reject, so checking the encoding in
+// the SDK keeps verification consistent whichever SignatureVerifier is used.
+type SignatureEncoding string
+
+const (
+	// SignatureEncodingStrictDer rejects signatures which are not canonical DER: lengths must match, integers must be
+	// positive and minimally encoded, and there must be no trailing bytes. It is the default.
+	SignatureEncodingStrictDer SignatureEncoding = "strict_der"
+	// SignatureEncodingLowS rejects signatures which are not canonical DER, and signatures whose S value is greater
+	// than half the curve order. For every valid signature (R, S), (R, N-S) is also valid, so requiring a low S value
+	// makes signatures non-malleable. The SDK's signers always produce low S signatures.
+	SignatureEncodingLowS SignatureEncoding = "low_s"
+	// SignatureEncodingLenient passes signatures to the SignatureVerifier without checking their encoding.
+	SignatureEncodingLenient SignatureEncoding = "lenient"
+)
+
+// WithSignatureEncoding sets which encodings of secp256k1 signatures from other VASPs are accepted. Defaults to
+// SignatureEncodingStrictDer.
+func WithSignatureEncoding(encoding SignatureEncoding) Option {
+	return func(o *options) {
+		o.signatureEncoding = encoding
+	}
+}
+
+// WithSigningKeyMatchHandler sets a function which is called with the ID of the signing key which verified each
+// signature from another VASP, so that operators can tell when a counterparty has moved to a new key. The key ID is
+// empty for keys without an ID.
+func WithSigningKeyMatchHandler(handler func(keyId string)) Option {
+	return func(o *options) {
+		o.signingKeyMatchHandler = handler
+	}
+}
+
+// verifySignedMessage checks the nonce and the signature of a message, logging and auditing the result.
+func (o *options) verifySignedMessage(
+	messageType MessageType,
+	counterparty string,
+	nonceCache NonceCache,
+	nonce string,
+	timestamp time.Time,
+	payload []byte,
+	signature string,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+) error {
+	return o.verifySignedMessageWithSchemes(messageType, counterparty, nonceCache, nonce, timestamp,
+		[]schemePayload{{scheme: SignatureSchemeDelimited, payload: payload}}, signature, otherVaspPubKeyResponse)
+}
+
+// verifySignedMessageWithSchemes is verifySignedMessage for messages which can be signed with several
+// SignatureSchemes, given the signable payload for each.
+func (o *options) verifySignedMessageWithSchemes(
+	messageType MessageType,
+	counterparty string,
+	nonceCache NonceCache,
+	nonce string,
+	timestamp time.Time,
+	payloads []schemePayload,
+	signature string,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+) error {
+	if nonceCache == nil {
+		return ErrNonceCacheRequired
+	}
+	return o.verifyMessage(messageType, counterparty, nonceCache, nonce, timestamp, payloads, signature,
+		otherVaspPubKeyResponse)
+}
+
+// verifyMessage checks the nonce and the signature of a message, logging and auditing the result. Unlike
+// verifySignedMessageWithSchemes, it skips the nonce check if nonceCache is nil, for messages which have no nonce or
+// whose nonce is checked separately with checkNonce.
+func (o *options) verifyMessage(
+	messageType MessageType,
+	counterparty string,
+	nonceCache NonceCache,
+	nonce string,
+	timestamp time.Time,
+	payloads []schemePayload,
+	signature string,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+) error {
+	ctx, span := o.startSpan(context.Background(), "uma.VerifySignature",
+		attribute.String("uma.message_type", string(messageType)), attribute.String("uma.counterparty", counterparty))
+	var err error
+	defer func() { endSpan(span, err) }()
+	attrs := []slog.Attr{slog.String("message_type", string(messageType)), slog.String("counterparty", counterparty)}
+	auditRecord := VerificationAuditRecord{
+		MessageType:      messageType,
+		Counterparty:     counterparty,
+		Nonce:            nonce,
+		MessageTimestamp: timestamp,
+	}
+	if nonceCache != nil {
+		if err = o.checkNonce(ctx, messageType, counterparty, nonceCache, nonce, timestamp, payloads[0].payload); err != nil {
+			return err
+		}
+	}
+	keyId, payload, err := o.verifySignatureWithSchemes(payloads, signature, otherVaspPubKeyResponse)
+	if err != nil {
+		o.log(ctx, slog.LevelWarn, LogEventSignatureInvalid, append(attrs, slog.String("error", err.Error()))...)
+		o.recordSignatureFailure(ctx, messageType)
+		auditRecord.Decision = AuditDecisionSignatureInvalid
+		_ = o.writeAuditRecord(ctx, auditRecord, payload, err)
+		return err
+	}
+	auditRecord.Decision = AuditDecisionAccepted
+	auditRecord.KeyId = keyId
+	if err = o.writeAuditRecord(ctx, auditRecord, payload, nil); err != nil {
+		return err
+	}
+	if o.signingKeyMatchHandler != nil {
+		o.signingKeyMatchHandler(keyId)
+	}
+	if keyId != "" {
+		attrs = append(attrs, slog.String("key_id", keyId))
+	}
+	o.log(ctx, slog.LevelInfo, LogEventSignatureVerified, attrs...)
+	return nil
+}
+
+// checkNonce checks and saves the nonce of a signed message, logging and auditing a rejection. It is called by
+// verifyMessage, or on its own for messages whose signature was verified without a NonceCache.
+func (o *options) checkNonce(
+	ctx context.Context,
+	messageType MessageType,
+	counterparty string,
+	nonceCache NonceCache,
+	nonce string,
+	timestamp time.Time,
+	payload []byte,
+) error {
+	err := nonceCache.CheckAndSaveNonce(nonce, timestamp)
+	if err == nil {
+		return nil
+	}
+	o.log(ctx, slog.LevelWarn, LogEventNonceRejected, slog.String("message_type", string(messageType)),
+		slog.String("counterparty", counterparty), slog.String("error", err.Error()))
+	o.recordNonceReplay(ctx, messageType)
+	auditRecord := VerificationAuditRecord{
+		MessageType:      messageType,
+		Counterparty:     counterparty,
+		Nonce:            nonce,
+		MessageTimestamp: timestamp,
+		Decision:         AuditDecisionNonceRejected,
+	}
+	_ = o.writeAuditRecord(ctx, auditRecord, payload, err)
+	return err
+}
+
+// verifySignature Verifies the signature of the uma request. The signature is checked against the signing key of the
+// PubKeyResponse and its additional unexpired signing keys, each with the algorithm it is advertised with, and the ID
+// of the key which verified it is returned.
+//
+// Args:
+//
+//	payload: the payload that was signed.
+//	signature: the hex-encoded signature.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the VASP who signed the payload.
+func (o *options) verifySignature(
+	payload []byte,
+	signature string,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+) (string, error) {
+	// Decode into a stack buffer, since signatures are verified on every request.
+	var signatureBuffer [maxDerSignatureLength]byte
+	if hex.DecodedLen(len(signature)) > len(signatureBuffer) {
+		return "", errors.New("signature is too long")
+	}
+	signatureLength, err := hex.Decode(signatureBuffer[:], []byte(signature))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(payload)
+	signatureBytes := signatureBuffer[:signatureLength]
+
+	pubKey, err := otherVaspPubKeyResponse.SigningPubKey()
+	if err == nil {
+		var verified bool
+		verified, err = o.verifyWithAlgorithm(
+			otherVaspPubKeyResponse.SigningPubKeyAlgorithm(), payload, hash, signatureBytes, pubKey)
+		if verified {
+			keyId := ""
+			if otherVaspPubKeyResponse.SigningKeyId != nil {
+				keyId = *otherVaspPubKeyResponse.SigningKeyId
+			}
+			return keyId, nil
+		}
+	}
+	now := o.clock.Now()
+	for _, signingKey := range otherVaspPubKeyResponse.SigningKeys {
+		if signingKey.IsExpired(now) {
+			continue
+		}
+		additionalPubKey, keyErr := signingKey.PubKey()
+		if keyErr != nil {
+			continue
+		}
+		verified, _ := o.verifyWithAlgorithm(signingKey.PubKeyAlgorithm(), payload, hash, signatureBytes, additionalPubKey)
+		if verified {
+			return signingKey.KeyId, nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", errors.New("invalid uma signature")
+}
+
+// verifyWithAlgorithm verifies a signature by a public key of the given algorithm. secp256k1 signatures are verified
+// over the hash with the SignatureVerifier, and Ed25519 signatures over the payload itself.
+func (o *options) verifyWithAlgorithm(
+	algorithm protocol.SigningAlgorithm,
+	payload []byte,
+	hash [sha256.Size]byte,
+	signature []byte,
+	publicKey []byte,
+) (bool, error) {
+	switch algorithm {
+	case protocol.SigningAlgorithmSecp256k1:
+		if err := o.checkSignatureEncoding(signature); err != nil {
+			return false, err
+		}
+		return o.signatureVerifier.Verify(hash, signature, publicKey)
+	case protocol.SigningAlgorithmEd25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return false, errors.New("invalid ed25519 public key length")
+		}
+		return ed25519.Verify(publicKey, payload, signature), nil
+	default:
+		return false, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+	}
+}
+
+// checkSignatureEncoding checks the encoding of a DER secp256k1 signature against the SignatureEncoding set with
+// WithSignatureEncoding.
+func (o *options) checkSignatureEncoding(signature []byte) error {
+	switch o.signatureEncoding {
+	case "", SignatureEncodingStrictDer:
+		_, err := parseCanonicalDerSignature(signature)
+		return err
+	case SignatureEncodingLowS:
+		s, err := parseCanonicalDerSignature(signature)
+		if err != nil {
+			return err
+		}
+		var sScalar secp256k1.ModNScalar
+		if overflow := sScalar.SetByteSlice(s); overflow || sScalar.IsOverHalfOrder() {
+			return errors.New("signature S value is not low")
+		}
+		return nil
+	case SignatureEncodingLenient:
+		return nil
+	default:
+		return fmt.Errorf("unknown signature encoding %s", o.signatureEncoding)
+	}
+}
+
+// parseCanonicalDerSignature checks that a signature is the canonical DER encoding of a SEQUENCE of two INTEGERs, R
+// and S, and returns the minimal big-endian bytes of S.
+func parseCanonicalDerSignature(signature []byte) ([]byte, error) {
+	// 0x30 len 0x02 rLen R 0x02 sLen S, with R and S between 1 and 33 bytes.
+	if len(signature) < 8 || len(signature) > maxDerSignatureLength {
+		return nil, errors.New("invalid DER signature length")
+	}
+	if signature[0] != 0x30 || int(signature[1]) != len(signature)-2 {
+		return nil, errors.New("invalid DER signature sequence")
+	}
+	rest := signature[2:]
+	var integers [2][]byte
+	for i := range integers {
+		if len(rest) < 2 || rest[0] != 0x02 {
+			return nil, errors.New("invalid DER signature integer")
+		}
+		length := int(rest[1])
+		if length == 0 || len(rest) < 2+length {
+			return nil, errors.New("invalid DER signature integer length")
+		}
+		integer := rest[2 : 2+length]
+		if integer[0]&0x80 != 0 {
+			return nil, errors.New("negative DER signature integer")
+		}
+		if length > 1 && integer[0] == 0 && integer[1]&0x80 == 0 {
+			return nil, errors.New("DER signature integer is not minimally encoded")
+		}
+		if length > 33 {
+			return nil, errors.New("DER signature integer is too long")
+		}
+		integers[i] = integer
+		rest = rest[2+length:]
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing bytes after DER signature")
+	}
+	s := integers[1]
+	if len(s) == 33 {
+		s = s[1:]
+	}
+	return s, nil
+}
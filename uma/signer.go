@@ -0,0 +1,317 @@
+package uma
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// UmaSigner signs UMA protocol payloads on behalf of a VASP.
+//
+// Implementations must return a DER-encoded secp256k1 ECDSA signature over the SHA-256 hash of the payload, or an
+// Ed25519 signature over the payload if the VASP advertises protocol.SigningAlgorithmEd25519 in its PubKeyResponse.
+// This allows keys to live in memory, in an HSM, or behind a remote signing service. secp256k1 signatures should have a
+// low S value, since counterparties may reject other signatures with SignatureEncodingLowS.
+//
+// Implementations of this interface should be thread-safe.
+type UmaSigner interface {
+	// Sign signs the given payload and returns the DER-encoded signature.
+	Sign(payload []byte) ([]byte, error)
+}
+
+// InMemorySigner is an UmaSigner which holds a secp256k1 private key in memory.
+type InMemorySigner struct {
+	privateKey *secp256k1.PrivateKey
+}
+
+// NewInMemorySigner creates an UmaSigner from a serialized secp256k1 private key.
+func NewInMemorySigner(privateKeyBytes []byte) (*InMemorySigner, error) {
+	if len(privateKeyBytes) != secp256k1.PrivKeyBytesLen {
+		return nil, errors.New("invalid private key length")
+	}
+	return &InMemorySigner{privateKey: secp256k1.PrivKeyFromBytes(privateKeyBytes)}, nil
+}
+
+func (s *InMemorySigner) Sign(payload []byte) ([]byte, error) {
+	return signSecp256k1(s.privateKey, payload), nil
+}
+
+// signSecp256k1 signs the SHA-256 hash of a payload with an RFC 6979 nonce. The signature is canonical DER with a low S
+// value, so that it is accepted under every SignatureEncoding.
+func signSecp256k1(privateKey *secp256k1.PrivateKey, payload []byte) []byte {
+	hash := sha256.Sum256(payload)
+	return ecdsa.Sign(privateKey, hash[:]).Serialize()
+}
+
+// Ed25519Signer is an UmaSigner which holds an Ed25519 private key in memory. Counterparties can only verify its
+// signatures if the VASP advertises protocol.SigningAlgorithmEd25519 in its PubKeyResponse, e.g. with
+// GetEd25519PubKeyResponse.
+type Ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates an UmaSigner from an Ed25519 private key.
+func NewEd25519Signer(privateKey ed25519.PrivateKey) (*Ed25519Signer, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid private key length")
+	}
+	return &Ed25519Signer{privateKey: privateKey}, nil
+}
+
+func (s *Ed25519Signer) Sign(payload []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, payload), nil
+}
+
+func signWithSigner(payload []byte, signer UmaSigner) (*string, error) {
+	if signer == nil {
+		return nil, errors.New("missing signer")
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	signatureString := hex.EncodeToString(signature)
+	return &signatureString, nil
+}
+
+// SignLnurlpRequest Signs an lnurlp request, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	request: the unsigned request. ReceiverAddress, VaspDomain, IsSubjectToTravelRule and UmaVersion should be set.
+//		If UmaVersion is nil, the latest version will be used. If IsSubjectToTravelRule is nil, it is set by the
+//		TravelRulePolicy, or to false if there is none.
+//	signer: the UmaSigner of the VASP that is sending the payment.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithTravelRulePolicy.
+func SignLnurlpRequest(
+	request protocol.LnurlpRequest,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.LnurlpRequest, error) {
+	if request.VaspDomain == nil {
+		return nil, errors.New("missing vaspDomain")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	if request.UmaVersion == nil {
+		umaVersion := UmaProtocolVersion
+		request.UmaVersion = &umaVersion
+	}
+	if request.IsSubjectToTravelRule == nil {
+		decision, err := o.evaluateTravelRule(TravelRulePayment{ReceiverAddress: request.ReceiverAddress})
+		if err != nil {
+			return nil, err
+		}
+		isSubjectToTravelRule := decision != nil && decision.IsSubjectToTravelRule
+		request.IsSubjectToTravelRule = &isSubjectToTravelRule
+	}
+	now := o.clock.Now()
+	request.Timestamp = &now
+	request.Nonce = nonce
+	signablePayload, err := request.SignablePayload()
+	if err != nil {
+		return nil, err
+	}
+	request.Signature, err = signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// SignLnurlpResponse Signs the compliance block of an lnurlp response, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	response: the unsigned response. Compliance.ReceiverIdentifier must be set.
+//	signer: the UmaSigner of the VASP that is receiving the payment.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignLnurlpResponse(
+	response protocol.LnurlpResponse,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.LnurlpResponse, error) {
+	if response.Compliance == nil {
+		return nil, errors.New("missing compliance data")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	compliance := *response.Compliance
+	compliance.Nonce = *nonce
+	compliance.Timestamp = o.clock.Now().Unix()
+	signablePayload := (&protocol.UmaLnurlpResponse{Compliance: compliance}).SignablePayload()
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	compliance.Signature = *signature
+	response.Compliance = &compliance
+	return &response, nil
+}
+
+// SignPayRequest Signs the compliance payer data of a pay request, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	request: the unsigned request. PayerData must contain the identifier and compliance fields.
+//	signer: the UmaSigner of the VASP that is sending the payment.
+//	opts: optional settings such as WithClock, WithNonceGenerator and WithSignatureScheme.
+func SignPayRequest(
+	request protocol.PayRequest,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PayRequest, error) {
+	complianceData, err := request.PayerData.Compliance()
+	if err != nil {
+		return nil, err
+	}
+	if complianceData == nil {
+		return nil, errors.New("missing compliance data")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	complianceData.SignatureNonce = *nonce
+	complianceData.SignatureTimestamp = o.clock.Now().Unix()
+	request.PayerData, err = payerDataWithCompliance(*request.PayerData, complianceData)
+	if err != nil {
+		return nil, err
+	}
+	signablePayload, err := o.signablePayload(MessageTypePayRequest, request.SignablePayload, &request,
+		payRequestUnsignedFields...)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	complianceData.Signature = *signature
+	request.PayerData, err = payerDataWithCompliance(*request.PayerData, complianceData)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// SignPayReqResponse Signs the compliance payee data of a pay request response, generating a fresh nonce and
+// timestamp.
+//
+// Args:
+//
+//	response: the unsigned response. PayeeData must contain the compliance field.
+//	payerIdentifier: the identifier of the sender. For example, $alice@vasp1.com
+//	payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
+//	signer: the UmaSigner of the VASP that is receiving the payment.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignPayReqResponse(
+	response protocol.PayReqResponse,
+	payerIdentifier string,
+	payeeIdentifier string,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PayReqResponse, error) {
+	complianceData, err := response.PayeeData.Compliance()
+	if err != nil {
+		return nil, err
+	}
+	if complianceData == nil {
+		return nil, errors.New("missing compliance data")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	timestamp := o.clock.Now().Unix()
+	complianceData.SignatureNonce = nonce
+	complianceData.SignatureTimestamp = &timestamp
+	signablePayload, err := complianceData.SignablePayload(payerIdentifier, payeeIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	complianceData.Signature, err = signWithSigner(signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	response.PayeeData, err = payeeDataWithCompliance(*response.PayeeData, complianceData)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SignPostTransactionCallback Signs a post transaction callback, generating a fresh nonce and timestamp.
+//
+// Args:
+//
+//	callback: the unsigned callback. Utxos and VaspDomain should be set.
+//	signer: the UmaSigner of the VASP initiating the callback.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
+func SignPostTransactionCallback(
+	callback protocol.PostTransactionCallback,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.PostTransactionCallback, error) {
+	if callback.VaspDomain == nil {
+		return nil, errors.New("missing vaspDomain")
+	}
+	o := newOptions(opts)
+	nonce, err := o.generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	timestamp := o.clock.Now().Unix()
+	callback.Nonce = nonce
+	callback.Timestamp = &timestamp
+	signablePayload, err := callback.SignablePayload()
+	if err != nil {
+		return nil, err
+	}
+	callback.Signature, err = signWithSigner(*signablePayload, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &callback, nil
+}
+
+// payerDataWithCompliance returns a copy of the payer data with the compliance field replaced.
+func payerDataWithCompliance(payerData protocol.PayerData, complianceData *protocol.CompliancePayerData) (*protocol.PayerData, error) {
+	complianceDataMap, err := complianceData.AsMap()
+	if err != nil {
+		return nil, err
+	}
+	result := make(protocol.PayerData, len(payerData)+1)
+	for k, v := range payerData {
+		result[k] = v
+	}
+	result[protocol.CounterPartyDataFieldCompliance.String()] = complianceDataMap
+	return &result, nil
+}
+
+// payeeDataWithCompliance returns a copy of the payee data with the compliance field replaced.
+func payeeDataWithCompliance(payeeData protocol.PayeeData, complianceData *protocol.CompliancePayeeData) (*protocol.PayeeData, error) {
+	complianceDataMap, err := complianceData.AsMap()
+	if err != nil {
+		return nil, err
+	}
+	result := make(protocol.PayeeData, len(payeeData)+1)
+	for k, v := range payeeData {
+		result[k] = v
+	}
+	result[protocol.CounterPartyDataFieldCompliance.String()] = complianceDataMap
+	return &result, nil
+}
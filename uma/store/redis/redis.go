@@ -0,0 +1,265 @@
+// Package redis implements uma.NonceCache and uma.PublicKeyCache on top of Redis, so that VASPs running several
+// instances share their nonces and cached public keys. Unlike uma.InMemoryNonceCache, the NonceCache detects a nonce
+// replayed to a different instance.
+//
+// The package does not depend on a Redis client library. Instead, it uses the small Client interface, which is easy
+// to implement with any client. For example, with github.com/redis/go-redis/v9:
+//
+//	type goRedisClient struct{ *goredis.Client }
+//
+//	func (c goRedisClient) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+//		return c.Client.SetNX(ctx, key, value, ttl).Result()
+//	}
+//
+//	func (c goRedisClient) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+//		return c.Client.Set(ctx, key, value, ttl).Err()
+//	}
+//
+//	func (c goRedisClient) Get(ctx context.Context, key string) (string, error) {
+//		value, err := c.Client.Get(ctx, key).Result()
+//		if errors.Is(err, goredis.Nil) {
+//			return "", redis.ErrNil
+//		}
+//		return value, err
+//	}
+//
+//	func (c goRedisClient) Del(ctx context.Context, keys ...string) error {
+//		return c.Client.Del(ctx, keys...).Err()
+//	}
+//
+//	func (c goRedisClient) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+//		return c.Client.Scan(ctx, cursor, match, count).Result()
+//	}
+//
+//	func (c goRedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return c.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ErrNil is returned by Client.Get for keys which do not exist.
+var ErrNil = errors.New("redis: nil")
+
+// Client is the subset of Redis commands used by the caches.
+type Client interface {
+	// SetNX sets a key with a TTL if it does not exist yet, and returns whether it was set.
+	SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	// Set sets a key with a TTL. A TTL of 0 means that the key does not expire.
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// Get returns the value of a key, or ErrNil if it does not exist.
+	Get(ctx context.Context, key string) (string, error)
+	// Del deletes keys.
+	Del(ctx context.Context, keys ...string) error
+	// Scan returns some of the keys matching a glob-style pattern, starting at a cursor, and the cursor to continue
+	// from, which is 0 once all keys were returned. Count is a hint of how many keys to return.
+	Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, nextCursor uint64, err error)
+	// Eval runs a Lua script with the given keys and arguments, and returns its result.
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// maxNonceClockSkew is how far in the future the timestamp of a signature checked by the NonceCache may be.
+const maxNonceClockSkew = 5 * time.Minute
+
+// clearBatchSize is the number of keys PublicKeyCache.Clear scans and deletes at a time.
+const clearBatchSize = 100
+
+// DefaultPublicKeyCacheTtl is how long a PublicKeyCache created without a positive TTL caches public keys at most.
+const DefaultPublicKeyCacheTtl = 24 * time.Hour
+
+// setIfGreaterScript sets KEYS[1] to ARGV[1] unless it already holds a greater or equal integer, so that the oldest
+// valid timestamp of a NonceCache never moves back, whichever instance purges first.
+const setIfGreaterScript = `
+local current = tonumber(redis.call('GET', KEYS[1]))
+if current == nil or tonumber(ARGV[1]) > current then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 0
+`
+
+// NonceCache is a uma.NonceCache storing nonces in Redis. Each nonce is stored with SETNX, so checking and saving it
+// is atomic across instances, and expires once its signature is older than the TTL of the cache. Since expired nonces
+// can no longer be detected, signatures older than the TTL are rejected, and so are signatures more than five minutes
+// in the future, which would have to be kept for longer. The oldest valid timestamp set by PurgeNoncesOlderThan is
+// stored in Redis at the key prefix itself, so that it is shared by all instances and survives restarts.
+type NonceCache struct {
+	client    Client
+	keyPrefix string
+	ttl       time.Duration
+	clock     uma.Clock
+}
+
+// NewNonceCache Creates a NonceCache.
+//
+// Args:
+//
+//	client: the Redis client.
+//	keyPrefix: the prefix of the keys of the nonces, e.g. "uma:nonce:".
+//	ttl: how long nonces are kept after their signature timestamp. Signatures older than this are rejected, so it
+//		should be longer than the clock skew and delivery delays tolerated between VASPs, e.g. one hour.
+func NewNonceCache(client Client, keyPrefix string, ttl time.Duration) *NonceCache {
+	return &NonceCache{client: client, keyPrefix: keyPrefix, ttl: ttl, clock: uma.SystemClock}
+}
+
+// SetClock sets the Clock used to reject signatures older than the TTL or too far in the future. Defaults to
+// uma.SystemClock. It must be called before the NonceCache is used.
+func (c *NonceCache) SetClock(clock uma.Clock) {
+	if clock != nil {
+		c.clock = clock
+	}
+}
+
+func (c *NonceCache) CheckAndSaveNonce(nonce string, timestamp time.Time) error {
+	if nonce == "" {
+		return errors.New("missing nonce")
+	}
+	ctx := context.Background()
+	oldestValidTimestamp, err := c.oldestValidTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	now := c.clock.Now()
+	if now.Add(maxNonceClockSkew).Before(timestamp) {
+		return errors.New("timestamp too far in the future")
+	}
+	// Keep the nonce until the signature is too old, rather than for the TTL from now.
+	ttl := timestamp.Add(c.ttl).Sub(now)
+	if timestamp.Before(oldestValidTimestamp) || ttl <= 0 {
+		return errors.New("timestamp too old")
+	}
+	saved, err := c.client.SetNX(ctx, c.keyPrefix+nonce, "1", ttl)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return errors.New("nonce already used")
+	}
+	return nil
+}
+
+// PurgeNoncesOlderThan makes the cache reject signatures older than the given timestamp, on every instance sharing
+// the Redis database. The nonces themselves are purged by Redis when their TTL expires. Since uma.NonceCache does not
+// return errors here, a failure to store the timestamp only leaves the previous one in place.
+func (c *NonceCache) PurgeNoncesOlderThan(timestamp time.Time) {
+	_, _ = c.client.Eval(context.Background(), setIfGreaterScript, []string{c.keyPrefix},
+		strconv.FormatInt(timestamp.UnixMilli(), 10))
+}
+
+// oldestValidTimestamp returns the oldest valid timestamp stored by PurgeNoncesOlderThan, or the zero time if none
+// was stored yet.
+func (c *NonceCache) oldestValidTimestamp(ctx context.Context) (time.Time, error) {
+	value, err := c.client.Get(ctx, c.keyPrefix)
+	if errors.Is(err, ErrNil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	unixMilli, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(unixMilli), nil
+}
+
+// PublicKeyCache is a uma.PublicKeyCache storing the public keys of other VASPs in Redis. Keys are stored until their
+// ExpirationTimestamp, or for the TTL of the cache if that is earlier, so that rotated keys are always refetched.
+// Since uma.PublicKeyCache does not return errors, Redis errors are treated as cache misses, so that the keys are
+// fetched from the VASP instead.
+type PublicKeyCache struct {
+	client    Client
+	keyPrefix string
+	ttl       time.Duration
+	clock     uma.Clock
+}
+
+// NewPublicKeyCache Creates a PublicKeyCache.
+//
+// Args:
+//
+//	client: the Redis client.
+//	keyPrefix: the prefix of the keys of the cached public keys, e.g. "uma:pubkey:".
+//	ttl: how long public keys are cached at most, so that rotated keys are picked up, e.g. one day. Defaults to
+//		DefaultPublicKeyCacheTtl if it is not positive.
+func NewPublicKeyCache(client Client, keyPrefix string, ttl time.Duration) *PublicKeyCache {
+	if ttl <= 0 {
+		ttl = DefaultPublicKeyCacheTtl
+	}
+	return &PublicKeyCache{client: client, keyPrefix: keyPrefix, ttl: ttl, clock: uma.SystemClock}
+}
+
+// SetClock sets the Clock used to check whether cached keys have expired. Defaults to uma.SystemClock. It must be
+// called before the PublicKeyCache is used.
+func (c *PublicKeyCache) SetClock(clock uma.Clock) {
+	if clock != nil {
+		c.clock = clock
+	}
+}
+
+func (c *PublicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse {
+	pubKeyJson, err := c.client.Get(context.Background(), c.keyPrefix+vaspDomain)
+	if err != nil {
+		return nil
+	}
+	var pubKeyResponse protocol.PubKeyResponse
+	if err = json.Unmarshal([]byte(pubKeyJson), &pubKeyResponse); err != nil {
+		return nil
+	}
+	if pubKeyResponse.ExpirationTimestamp != nil && time.Unix(*pubKeyResponse.ExpirationTimestamp, 0).Before(c.clock.Now()) {
+		return nil
+	}
+	return &pubKeyResponse
+}
+
+func (c *PublicKeyCache) AddPublicKeyForVasp(vaspDomain string, pubKey *protocol.PubKeyResponse) {
+	ttl := c.ttl
+	if pubKey.ExpirationTimestamp != nil {
+		untilExpiration := time.Unix(*pubKey.ExpirationTimestamp, 0).Sub(c.clock.Now())
+		if untilExpiration <= 0 {
+			return
+		}
+		if untilExpiration < ttl {
+			ttl = untilExpiration
+		}
+	}
+	pubKeyJson, err := json.Marshal(pubKey)
+	if err != nil {
+		return
+	}
+	_ = c.client.Set(context.Background(), c.keyPrefix+vaspDomain, string(pubKeyJson), ttl)
+}
+
+func (c *PublicKeyCache) RemovePublicKeyForVasp(vaspDomain string) {
+	_ = c.client.Del(context.Background(), c.keyPrefix+vaspDomain)
+}
+
+// Clear deletes the cached public keys in batches with SCAN, rather than KEYS, which would block Redis while it
+// goes through all keys.
+func (c *PublicKeyCache) Clear() {
+	ctx := context.Background()
+	var cursor uint64
+	for {
+		keys, nextCursor, err := c.client.Scan(ctx, cursor, c.keyPrefix+"*", clearBatchSize)
+		if err != nil {
+			return
+		}
+		if len(keys) > 0 {
+			if err = c.client.Del(ctx, keys...); err != nil {
+				return
+			}
+		}
+		if nextCursor == 0 {
+			return
+		}
+		cursor = nextCursor
+	}
+}
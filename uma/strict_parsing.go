@@ -0,0 +1,276 @@
+package uma
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// ParseOptions configures how the Parse functions handle messages which deviate from the UMA spec.
+type ParseOptions struct {
+	// Strict rejects messages with unknown fields, standard fields of the wrong type, unrecognized KYC statuses, and
+	// missing fields which are optional for backwards compatibility but expected from spec-compliant counterparties.
+	// When false (the default), such messages are parsed as leniently as before, so that older or non-compliant
+	// counterparties keep working. Strict mode helps detect counterparties drifting from the spec.
+	Strict bool
+}
+
+// WithParseOptions sets the ParseOptions used by the Parse functions. See ParseOptions.Strict.
+func WithParseOptions(parseOptions ParseOptions) Option {
+	return func(o *options) {
+		o.parseOptions = parseOptions
+	}
+}
+
+// StrictParsingError is returned by the Parse functions in strict mode when a message deviates from the spec. It lists
+// every violation found, not just the first one.
+type StrictParsingError struct {
+	MessageType MessageType
+	Violations  []string
+}
+
+func (e StrictParsingError) Error() string {
+	return fmt.Sprintf("strict parsing of %s failed: %s", e.MessageType, strings.Join(e.Violations, "; "))
+}
+
+// checkStrict returns a StrictParsingError with the violations found by the given checks in strict mode. The checks
+// are only run in strict mode.
+func (o *options) checkStrict(messageType MessageType, checks ...func() []string) error {
+	if !o.parseOptions.Strict {
+		return nil
+	}
+	var violations []string
+	for _, check := range checks {
+		violations = append(violations, check()...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return StrictParsingError{MessageType: messageType, Violations: violations}
+}
+
+// unknownJsonFields finds the fields of a JSON message which were dropped when parsing it, by serializing the parsed
+// message again. Free-form objects such as payer data are kept in full, so only fields unknown to the spec are found.
+func unknownJsonFields(data []byte, parsed interface{}) func() []string {
+	return func() []string {
+		var input interface{}
+		if err := json.Unmarshal(data, &input); err != nil {
+			return []string{err.Error()}
+		}
+		reencoded, err := json.Marshal(parsed)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		var output interface{}
+		if err = json.Unmarshal(reencoded, &output); err != nil {
+			return []string{err.Error()}
+		}
+		var violations []string
+		for _, path := range droppedJsonFields(input, output, "") {
+			violations = append(violations, "unknown field "+path)
+		}
+		return violations
+	}
+}
+
+func droppedJsonFields(input interface{}, output interface{}, path string) []string {
+	var dropped []string
+	switch inputValue := input.(type) {
+	case map[string]interface{}:
+		outputMap, _ := output.(map[string]interface{})
+		keys := make([]string, 0, len(inputValue))
+		for key := range inputValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			outputFieldValue, ok := outputMap[key]
+			if !ok {
+				if inputValue[key] != nil {
+					dropped = append(dropped, path+key)
+				}
+				continue
+			}
+			dropped = append(dropped, droppedJsonFields(inputValue[key], outputFieldValue, path+key+".")...)
+		}
+	case []interface{}:
+		outputSlice, _ := output.([]interface{})
+		for i, element := range inputValue {
+			if i < len(outputSlice) {
+				dropped = append(dropped, droppedJsonFields(element, outputSlice[i], fmt.Sprintf("%s%d.", path, i))...)
+			}
+		}
+	}
+	return dropped
+}
+
+// counterPartyDataViolations checks the types of the standard payer or payee data fields, and the KYC status in their
+// compliance data.
+func counterPartyDataViolations(name string, data map[string]interface{}) func() []string {
+	return func() []string {
+		var violations []string
+		for _, field := range []protocol.CounterPartyDataField{
+			protocol.CounterPartyDataFieldIdentifier,
+			protocol.CounterPartyDataFieldName,
+			protocol.CounterPartyDataFieldEmail,
+		} {
+			if value, ok := data[field.String()]; ok && value != nil {
+				if _, isString := value.(string); !isString {
+					violations = append(violations, fmt.Sprintf("%s.%s must be a string", name, field))
+				}
+			}
+		}
+		if value, ok := data[protocol.CounterPartyDataFieldCompliance.String()]; ok && value != nil {
+			compliance, isMap := value.(map[string]interface{})
+			if !isMap {
+				return append(violations, name+".compliance must be an object")
+			}
+			violations = append(violations, kycStatusViolations(name+".compliance", compliance)...)
+		}
+		return violations
+	}
+}
+
+// kycStatusViolations checks that the kycStatus of a compliance object is recognized, since lenient parsing maps
+// unrecognized statuses to KycStatusUnknown.
+func kycStatusViolations(name string, compliance map[string]interface{}) []string {
+	kycStatus, ok := compliance["kycStatus"]
+	if !ok {
+		return nil
+	}
+	kycStatusString, isString := kycStatus.(string)
+	if !isString {
+		return []string{name + ".kycStatus must be a string"}
+	}
+	if _, err := protocol.ParseKycStatus(kycStatusString); err != nil {
+		return []string{fmt.Sprintf("%s.kycStatus: %v", name, err)}
+	}
+	return nil
+}
+
+// missingFields reports each named field whose presence check fails.
+func missingFields(fields map[string]bool) func() []string {
+	return func() []string {
+		var violations []string
+		for field, present := range fields {
+			if !present {
+				violations = append(violations, "missing field "+field)
+			}
+		}
+		sort.Strings(violations)
+		return violations
+	}
+}
+
+// rawJsonObject decodes a JSON object for the strict checks, returning nil if it is not an object.
+func rawJsonObject(data []byte) map[string]interface{} {
+	var object map[string]interface{}
+	_ = json.Unmarshal(data, &object)
+	return object
+}
+
+func lnurlpRequestViolations(query url.Values) func() []string {
+	return func() []string {
+		umaParameters := []string{"signature", "vaspDomain", "nonce", "isSubjectToTravelRule", "timestamp", "umaVersion"}
+		var violations []string
+		var presentParameters []string
+		for parameter := range query {
+			known := false
+			for _, umaParameter := range umaParameters {
+				known = known || parameter == umaParameter
+			}
+			if !known {
+				violations = append(violations, "unknown query parameter "+parameter)
+			}
+		}
+		sort.Strings(violations)
+		for _, parameter := range umaParameters {
+			if query.Has(parameter) {
+				presentParameters = append(presentParameters, parameter)
+			}
+		}
+		if len(presentParameters) > 0 {
+			// A request with some UMA parameters is meant to be an UMA request, so it should have all of them.
+			for _, parameter := range umaParameters {
+				if !query.Has(parameter) {
+					violations = append(violations, "missing query parameter "+parameter)
+				}
+			}
+		}
+		if isSubjectToTravelRule := query.Get("isSubjectToTravelRule"); query.Has("isSubjectToTravelRule") &&
+			isSubjectToTravelRule != "true" && isSubjectToTravelRule != "false" {
+			violations = append(violations, "isSubjectToTravelRule must be true or false")
+		}
+		return violations
+	}
+}
+
+func lnurlpResponseViolations(data []byte, response *protocol.LnurlpResponse) []func() []string {
+	checks := []func() []string{unknownJsonFields(data, response)}
+	if response.Compliance == nil {
+		return checks
+	}
+	if compliance, ok := rawJsonObject(data)["compliance"].(map[string]interface{}); ok {
+		checks = append(checks, func() []string { return kycStatusViolations("compliance", compliance) })
+	}
+	// A response with compliance data is meant to be an UMA response, so it should have the other UMA fields.
+	return append(checks, missingFields(map[string]bool{
+		"currencies": response.Currencies != nil,
+		"payerData":  response.RequiredPayerData != nil,
+		"umaVersion": response.UmaVersion != nil,
+	}))
+}
+
+func payRequestViolations(data []byte, request *protocol.PayRequest) []func() []string {
+	checks := []func() []string{unknownJsonFields(data, request)}
+	if request.PayerData == nil {
+		return checks
+	}
+	checks = append(checks, counterPartyDataViolations("payerData", *request.PayerData))
+	if compliance, _ := request.PayerData.Compliance(); compliance != nil {
+		checks = append(checks, missingFields(map[string]bool{
+			"payerData.identifier": request.PayerData.Identifier() != nil,
+			"convert":              request.UmaMajorVersion == 0 || request.ReceivingCurrencyCode != nil,
+		}))
+	}
+	return checks
+}
+
+func payReqResponseViolations(data []byte, response *protocol.PayReqResponse) []func() []string {
+	checks := []func() []string{unknownJsonFields(data, response)}
+	if response.PayeeData == nil {
+		return checks
+	}
+	checks = append(checks, counterPartyDataViolations("payeeData", *response.PayeeData))
+	if compliance, _ := response.PayeeData.Compliance(); compliance != nil {
+		paymentInfoField := "converted"
+		if response.UmaMajorVersion == 0 {
+			paymentInfoField = "paymentInfo"
+		}
+		fields := map[string]bool{paymentInfoField: response.PaymentInfo != nil}
+		if response.UmaMajorVersion >= 1 {
+			fields["payeeData.compliance.signature"] = compliance.Signature != nil
+			fields["payeeData.compliance.signatureNonce"] = compliance.SignatureNonce != nil
+			fields["payeeData.compliance.signatureTimestamp"] = compliance.SignatureTimestamp != nil
+			fields["converted.amount"] = response.PaymentInfo == nil || response.PaymentInfo.Amount != nil
+		}
+		checks = append(checks, missingFields(fields))
+	}
+	return checks
+}
+
+func postTransactionCallbackViolations(data []byte, callback *protocol.PostTransactionCallback) []func() []string {
+	return []func() []string{
+		unknownJsonFields(data, callback),
+		missingFields(map[string]bool{
+			"vaspDomain":         callback.VaspDomain != nil,
+			"signature":          callback.Signature != nil,
+			"signatureNonce":     callback.Nonce != nil,
+			"signatureTimestamp": callback.Timestamp != nil,
+		}),
+	}
+}
@@ -0,0 +1,132 @@
+package uma
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the OpenTelemetry tracer and meter used by the SDK.
+const InstrumentationName = "github.com/uma-universal-money-address/uma-go-sdk/uma"
+
+// Metrics recorded by the SDK when a MeterProvider is set with WithMeterProvider.
+const (
+	MetricSignatureFailures = "uma.signature.failures"
+	MetricVersionDowngrades = "uma.version.downgrades"
+	MetricNonceReplays      = "uma.nonce.replays"
+	MetricPayReqDuration    = "uma.payreq.duration"
+)
+
+// WithTracerProvider enables OpenTelemetry spans for outbound requests and signature verification. By default no
+// spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider enables OpenTelemetry metrics for signature failures, version downgrades, nonce replays and pay
+// request latency. By default no metrics are recorded.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = provider
+	}
+}
+
+type instruments struct {
+	signatureFailures metric.Int64Counter
+	versionDowngrades metric.Int64Counter
+	nonceReplays      metric.Int64Counter
+	payReqDuration    metric.Float64Histogram
+}
+
+// instrumentsByProvider caches instruments per MeterProvider, since options are created on every call.
+var instrumentsByProvider sync.Map
+
+func (o *options) instruments() *instruments {
+	if o.meterProvider == nil {
+		return nil
+	}
+	if cached, ok := instrumentsByProvider.Load(o.meterProvider); ok {
+		return cached.(*instruments)
+	}
+	meter := o.meterProvider.Meter(InstrumentationName)
+	// Instrument creation only fails on invalid names or options, which are constant here, so errors are ignored and
+	// the returned no-op instruments are used instead.
+	signatureFailures, _ := meter.Int64Counter(MetricSignatureFailures,
+		metric.WithDescription("Number of counterparty signatures which failed verification."))
+	versionDowngrades, _ := meter.Int64Counter(MetricVersionDowngrades,
+		metric.WithDescription("Number of exchanges which used a lower UMA version than requested."))
+	nonceReplays, _ := meter.Int64Counter(MetricNonceReplays,
+		metric.WithDescription("Number of messages rejected for reusing a nonce or having a stale timestamp."))
+	payReqDuration, _ := meter.Float64Histogram(MetricPayReqDuration,
+		metric.WithDescription("Duration of outbound pay requests."), metric.WithUnit("s"))
+	cached, _ := instrumentsByProvider.LoadOrStore(o.meterProvider, &instruments{
+		signatureFailures: signatureFailures,
+		versionDowngrades: versionDowngrades,
+		nonceReplays:      nonceReplays,
+		payReqDuration:    payReqDuration,
+	})
+	return cached.(*instruments)
+}
+
+// startSpan starts a span if a TracerProvider is set. The returned span is a no-op otherwise.
+func (o *options) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if o.tracerProvider == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	if correlationId := o.correlationIdFor(ctx); correlationId != "" {
+		attrs = append(attrs, attribute.String("uma.correlation_id", correlationId))
+	}
+	return o.tracerProvider.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (o *options) recordSignatureFailure(ctx context.Context, messageType MessageType) {
+	if instruments := o.instruments(); instruments != nil {
+		instruments.signatureFailures.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("message_type", string(messageType))))
+	}
+	if o.metricsRecorder != nil {
+		o.metricsRecorder.RecordVerificationFailure(ctx, messageType, VerificationFailureInvalidSignature)
+	}
+}
+
+func (o *options) recordNonceReplay(ctx context.Context, messageType MessageType) {
+	if instruments := o.instruments(); instruments != nil {
+		instruments.nonceReplays.Add(ctx, 1, metric.WithAttributes(attribute.String("message_type", string(messageType))))
+	}
+	if o.metricsRecorder != nil {
+		o.metricsRecorder.RecordVerificationFailure(ctx, messageType, VerificationFailureNonceRejected)
+	}
+}
+
+func (o *options) recordVersionDowngrade(ctx context.Context, requestedVersion string) {
+	if instruments := o.instruments(); instruments != nil {
+		instruments.versionDowngrades.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("requested_version", requestedVersion)))
+	}
+}
+
+func (o *options) recordPayReqDuration(ctx context.Context, start time.Time, err error) {
+	duration := time.Since(start)
+	if instruments := o.instruments(); instruments != nil {
+		instruments.payReqDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(attribute.Bool("error", err != nil)))
+	}
+	if o.metricsRecorder != nil {
+		o.metricsRecorder.RecordPayReqDuration(ctx, duration, err)
+	}
+}
@@ -0,0 +1,199 @@
+package uma
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxProxiedBodyBytes limits the size of request and response bodies forwarded by a TenantProxy.
+const maxProxiedBodyBytes = 1024 * 1024
+
+// ErrUnknownTenant is returned by a TenantKeyResolver or TenantBackendResolver for domains which are not hosted by
+// the platform. The TenantProxy responds to requests for them with 404 Not Found.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// TenantKeyResolver looks up the signing keys of the VASPs hosted by a multi-tenant platform.
+type TenantKeyResolver interface {
+	// ResolveTenantSigner returns the UmaSigner of the hosted VASP with the given domain, or ErrUnknownTenant.
+	ResolveTenantSigner(ctx context.Context, vaspDomain string) (UmaSigner, error)
+}
+
+// TenantBackendResolver looks up the backends serving the VASPs hosted by a multi-tenant platform.
+type TenantBackendResolver interface {
+	// ResolveTenantBackend returns the base URL of the backend serving the hosted VASP with the given domain, or
+	// ErrUnknownTenant. Request paths are appended to it, e.g. https://tenant1.internal/uma.
+	ResolveTenantBackend(ctx context.Context, vaspDomain string) (*url.URL, error)
+}
+
+// TenantProxy is an http.Handler which lets a platform hosting many VASP domains terminate their UMA endpoints in one
+// place. The tenant is identified by the Host of each request. Requests are forwarded to the tenant's backend with the
+// same method, path, query and body, and successful UMA responses are re-signed with the tenant's key:
+//
+//   - lnurlp responses to requests at /.well-known/lnurlp/<username> are signed with SignLnurlpResponse.
+//   - pay request responses to UMA pay requests sent to any other path, i.e. callbacks, are signed with
+//     SignPayReqResponse.
+//
+// Other responses, including errors and non-UMA responses, are passed through unchanged. Backends are trusted and
+// can therefore leave UMA responses unsigned, but must set the compliance data, including the payee identifier of pay
+// request responses.
+type TenantProxy struct {
+	keyResolver     TenantKeyResolver
+	backendResolver TenantBackendResolver
+	opts            []Option
+}
+
+// NewTenantProxy Creates a TenantProxy.
+//
+// Args:
+//
+//	keyResolver: looks up the signing key of each tenant.
+//	backendResolver: looks up the backend of each tenant.
+//	opts: optional settings such as WithRequestDoer, used to send requests to the backends.
+func NewTenantProxy(
+	keyResolver TenantKeyResolver,
+	backendResolver TenantBackendResolver,
+	opts ...Option,
+) *TenantProxy {
+	return &TenantProxy{
+		keyResolver:     keyResolver,
+		backendResolver: backendResolver,
+		opts:            opts,
+	}
+}
+
+func (p *TenantProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vaspDomain := r.Host
+	signer, err := p.keyResolver.ResolveTenantSigner(ctx, vaspDomain)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	backend, err := p.backendResolver.ResolveTenantBackend(ctx, vaspDomain)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	requestBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProxiedBodyBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+		return
+	}
+
+	response, responseBody, err := p.forward(ctx, r, backend, requestBody)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to reach tenant backend: %w", err))
+		return
+	}
+	if response.StatusCode == http.StatusOK {
+		if strings.HasPrefix(r.URL.Path, "/.well-known/lnurlp/") {
+			responseBody, err = p.resignLnurlpResponse(responseBody, signer)
+		} else {
+			responseBody, err = p.resignPayReqResponse(requestBody, responseBody, signer)
+		}
+		if err != nil {
+			writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("invalid response from tenant backend: %w", err))
+			return
+		}
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(response.StatusCode)
+	_, _ = w.Write(responseBody)
+}
+
+// forward sends a request to a tenant's backend and reads the response.
+func (p *TenantProxy) forward(
+	ctx context.Context,
+	r *http.Request,
+	backend *url.URL,
+	body []byte,
+) (*http.Response, []byte, error) {
+	backendUrl := backend.JoinPath(r.URL.Path)
+	backendUrl.RawQuery = r.URL.RawQuery
+	request, err := http.NewRequestWithContext(ctx, r.Method, backendUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, header := range []string{"Accept", "Content-Type"} {
+		if value := r.Header.Get(header); value != "" {
+			request.Header.Set(header, value)
+		}
+	}
+	request.Header.Set("X-Forwarded-Host", r.Host)
+	request.Header.Set("X-Forwarded-For", remoteIp(r))
+
+	response, err := newOptions(p.opts).requestDoer.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, maxProxiedBodyBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(responseBody) > maxProxiedBodyBytes {
+		return nil, nil, errors.New("response body too large")
+	}
+	return response, responseBody, nil
+}
+
+// resignLnurlpResponse signs an lnurlp response from a backend with the tenant's key. Non-UMA responses are returned
+// unchanged.
+func (p *TenantProxy) resignLnurlpResponse(responseBody []byte, signer UmaSigner) ([]byte, error) {
+	response, err := ParseLnurlpResponse(responseBody, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Compliance == nil {
+		return responseBody, nil
+	}
+	signedResponse, err := SignLnurlpResponse(*response, signer, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(signedResponse)
+}
+
+// resignPayReqResponse signs a pay request response from a backend with the tenant's key, if both the request and
+// response are UMA messages. Other responses are returned unchanged.
+func (p *TenantProxy) resignPayReqResponse(requestBody []byte, responseBody []byte, signer UmaSigner) ([]byte, error) {
+	request, err := ParsePayRequest(requestBody, p.opts...)
+	if err != nil || !request.IsUmaRequest() {
+		return responseBody, nil
+	}
+	response, err := ParsePayReqResponse(responseBody, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsUmaResponse() {
+		return responseBody, nil
+	}
+	payeeIdentifier := response.PayeeData.Identifier()
+	if payeeIdentifier == nil {
+		return nil, errors.New("missing payee identifier")
+	}
+	signedResponse, err := SignPayReqResponse(
+		*response, *request.PayerData.Identifier(), *payeeIdentifier, signer, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(signedResponse)
+}
+
+// writeTenantError writes the response for a failed tenant lookup.
+func writeTenantError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownTenant) {
+		writeStatusResponse(w, http.StatusNotFound, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusInternalServerError, fmt.Errorf("failed to resolve tenant: %w", err))
+}
@@ -0,0 +1,83 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+func readAuditRecords(t *testing.T, buffer *bytes.Buffer) []uma.VerificationAuditRecord {
+	var records []uma.VerificationAuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		var record uma.VerificationAuditRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestVerificationAuditRecords(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	nonceCache := uma.NewInMemoryNonceCache(time.Now().AddDate(0, 0, -7))
+	var buffer bytes.Buffer
+	auditWriter := uma.WithAuditWriter(uma.NewJsonLinesAuditWriter(&buffer))
+
+	request := createSignedLnurlpRequest(t, privateKey).AsUmaRequest()
+	require.NoError(t, uma.VerifyUmaLnurlpQuerySignature(*request, pubKeyResponse, nonceCache, auditWriter))
+	require.Error(t, uma.VerifyUmaLnurlpQuerySignature(*request, pubKeyResponse, nonceCache, auditWriter))
+	forgedRequest := createSignedLnurlpRequest(t, otherPrivateKey).AsUmaRequest()
+	require.Error(t, uma.VerifyUmaLnurlpQuerySignature(*forgedRequest, pubKeyResponse, nonceCache, auditWriter))
+
+	records := readAuditRecords(t, &buffer)
+	require.Len(t, records, 3)
+	require.Equal(t, uma.AuditDecisionAccepted, records[0].Decision)
+	require.Equal(t, uma.MessageTypeLnurlpRequest, records[0].MessageType)
+	require.Equal(t, "vasp1.com", records[0].Counterparty)
+	require.Equal(t, request.Nonce, records[0].Nonce)
+	require.Len(t, records[0].MessageDigest, 64)
+	require.Empty(t, records[0].Error)
+	require.Equal(t, uma.AuditDecisionNonceRejected, records[1].Decision)
+	require.Equal(t, records[0].MessageDigest, records[1].MessageDigest)
+	require.Equal(t, uma.AuditDecisionSignatureInvalid, records[2].Decision)
+	require.NotEmpty(t, records[2].Error)
+
+	var exported bytes.Buffer
+	require.NoError(t, uma.ExportNonceCacheEntries(&exported, nonceCache.Entries()))
+	// The nonce of the forged request was saved before its signature was checked.
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry uma.NonceCacheEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Contains(t, []string{request.Nonce, forgedRequest.Nonce}, entry.Nonce)
+}
+
+func TestFailedAuditRecordRejectsMessage(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	failingWriter := auditWriterFunc(func(context.Context, uma.VerificationAuditRecord) error {
+		return errors.New("audit log unavailable")
+	})
+
+	request := createSignedLnurlpRequest(t, privateKey).AsUmaRequest()
+	err = uma.VerifyUmaLnurlpQuerySignature(*request, getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithAuditWriter(failingWriter))
+	require.ErrorContains(t, err, "audit log unavailable")
+}
+
+type auditWriterFunc func(ctx context.Context, record uma.VerificationAuditRecord) error
+
+func (f auditWriterFunc) WriteAuditRecord(ctx context.Context, record uma.VerificationAuditRecord) error {
+	return f(ctx, record)
+}
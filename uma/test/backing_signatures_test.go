@@ -0,0 +1,100 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestPayRequestBackingSignatures(t *testing.T) {
+	senderPrivateKey, _ := createSigner(t)
+	receiverEncryptionPrivateKey, _ := createSigner(t)
+	backingPrivateKey, backingSigner := createSigner(t)
+
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderPrivateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	err = uma.AppendPayRequestBackingSignature(payreq, backingSigner, "backingvasp.com")
+	require.NoError(t, err)
+
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	parsedPayreq, err := uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	complianceData, err := parsedPayreq.PayerData.Compliance()
+	require.NoError(t, err)
+	require.Len(t, *complianceData.BackingSignatures, 1)
+	require.Equal(t, "backingvasp.com", (*complianceData.BackingSignatures)[0].Domain)
+
+	// The primary signature is unaffected by backing signatures.
+	err = uma.VerifyPayReqSignature(parsedPayreq, getPubKeyResponse(senderPrivateKey), getNonceCache())
+	require.NoError(t, err)
+
+	cache := uma.NewInMemoryPublicKeyCache()
+	backingPubKeyResponse := getPubKeyResponse(backingPrivateKey)
+	cache.AddPublicKeyForVasp("backingvasp.com", &backingPubKeyResponse)
+	err = uma.VerifyPayReqBackingSignatures(parsedPayreq, cache)
+	require.NoError(t, err)
+
+	// A backing signature from the wrong key should fail.
+	wrongPubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	cache.AddPublicKeyForVasp("backingvasp.com", &wrongPubKeyResponse)
+	err = uma.VerifyPayReqBackingSignatures(parsedPayreq, cache)
+	require.Error(t, err)
+}
+
+func TestPayReqResponseBackingSignatures(t *testing.T) {
+	receiverPrivateKey, receiverSigner := createSigner(t)
+	backingPrivateKey, backingSigner := createSigner(t)
+	payeeCompliance := umaprotocol.CompliancePayeeData{Utxos: []string{"abcdef12345"}}
+	payeeComplianceMap, err := payeeCompliance.AsMap()
+	require.NoError(t, err)
+	response, err := uma.SignPayReqResponse(umaprotocol.PayReqResponse{
+		EncodedInvoice:  "lnbcrt100n1p0z9j",
+		PayeeData:       &umaprotocol.PayeeData{"compliance": payeeComplianceMap},
+		UmaMajorVersion: 1,
+	}, "$alice@vasp1.com", "$bob@vasp2.com", receiverSigner)
+	require.NoError(t, err)
+	err = uma.AppendPayReqResponseBackingSignature(response, backingSigner, "backingvasp.com", "$alice@vasp1.com", "$bob@vasp2.com")
+	require.NoError(t, err)
+
+	responseJson, err := json.Marshal(response)
+	require.NoError(t, err)
+	parsedResponse, err := uma.ParsePayReqResponse(responseJson)
+	require.NoError(t, err)
+
+	err = uma.VerifyPayReqResponseSignature(
+		parsedResponse,
+		getPubKeyResponse(receiverPrivateKey),
+		getNonceCache(),
+		"$alice@vasp1.com",
+		"$bob@vasp2.com",
+	)
+	require.NoError(t, err)
+
+	cache := uma.NewInMemoryPublicKeyCache()
+	backingPubKeyResponse := getPubKeyResponse(backingPrivateKey)
+	cache.AddPublicKeyForVasp("backingvasp.com", &backingPubKeyResponse)
+	err = uma.VerifyPayReqResponseBackingSignatures(parsedResponse, cache, "$alice@vasp1.com", "$bob@vasp2.com")
+	require.NoError(t, err)
+}
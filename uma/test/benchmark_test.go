@@ -0,0 +1,214 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umacurrency"
+)
+
+// Allocation budgets of the hot paths, checked by TestAllocationBudgets. They have some headroom over the current
+// counts, so that only real regressions fail the test. Lower them when an optimization lands.
+const (
+	encodeToUrlAllocsBudget         = 30
+	payRequestMarshalAllocsBudget   = 30
+	payRequestUnmarshalAllocsBudget = 130
+	signAllocsBudget                = 40
+	verifyAllocsBudget              = 30
+	decredVerifyAllocsBudget        = 20
+	formatAmountAllocsBudget        = 8
+)
+
+var benchmarkCurrency = umaprotocol.Currency{
+	Code:                "USD",
+	Name:                "US Dollar",
+	Symbol:              "$",
+	MillisatoshiPerUnit: 24_150,
+	Decimals:            2,
+}
+
+func createBenchmarkPayRequestJson(tb testing.TB) []byte {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(tb, privateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(tb, err)
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(tb, err)
+	return payreqJson
+}
+
+func createBenchmarkSigner(tb testing.TB) uma.UmaSigner {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	signer, err := uma.NewInMemorySigner(privateKey.Serialize())
+	require.NoError(tb, err)
+	return signer
+}
+
+func createBenchmarkLnurlpRequest(tb testing.TB) *umaprotocol.LnurlpRequest {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	return createSignedLnurlpRequest(tb, privateKey)
+}
+
+func TestAllocationBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation counts are not meaningful in short mode")
+	}
+	lnurlpRequest := createBenchmarkLnurlpRequest(t)
+	payreqJson := createBenchmarkPayRequestJson(t)
+	var payreq umaprotocol.PayRequest
+	require.NoError(t, json.Unmarshal(payreqJson, &payreq))
+	signer := createBenchmarkSigner(t)
+	payload := []byte("$alice@vasp1.com|1234|1700000000")
+	callback, pubKeyResponse := createSignedCallback(t)
+	hash, signature, publicKey := createSignedHash(t)
+
+	budgets := []struct {
+		name   string
+		budget float64
+		run    func()
+	}{
+		{"EncodeToUrl", encodeToUrlAllocsBudget, func() { _, _ = lnurlpRequest.EncodeToUrl() }},
+		{"PayRequest marshal", payRequestMarshalAllocsBudget, func() { _, _ = json.Marshal(&payreq) }},
+		{"PayRequest unmarshal", payRequestUnmarshalAllocsBudget, func() {
+			var parsed umaprotocol.PayRequest
+			_ = json.Unmarshal(payreqJson, &parsed)
+		}},
+		{"Sign", signAllocsBudget, func() { _, _ = signer.Sign(payload) }},
+		{"Verify", verifyAllocsBudget, func() {
+			require.NoError(t, uma.VerifyPostTransactionCallbackSignature(callback, pubKeyResponse, acceptingNonceCache{}))
+		}},
+		{"DecredSignatureVerifier", decredVerifyAllocsBudget, func() {
+			_, _ = uma.DecredSignatureVerifier{}.Verify(hash, signature, publicKey)
+		}},
+		{"FormatAmount", formatAmountAllocsBudget, func() { _ = umacurrency.FormatAmount(595, benchmarkCurrency) }},
+	}
+	for _, b := range budgets {
+		allocs := testing.AllocsPerRun(100, b.run)
+		require.LessOrEqual(t, allocs, b.budget, "%s allocates %v times per run", b.name, allocs)
+	}
+}
+
+func BenchmarkEncodeToUrl(b *testing.B) {
+	request := createBenchmarkLnurlpRequest(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := request.EncodeToUrl(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPayRequestMarshal(b *testing.B) {
+	var payreq umaprotocol.PayRequest
+	require.NoError(b, json.Unmarshal(createBenchmarkPayRequestJson(b), &payreq))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(&payreq); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPayRequestUnmarshal(b *testing.B) {
+	payreqJson := createBenchmarkPayRequestJson(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var payreq umaprotocol.PayRequest
+		if err := json.Unmarshal(payreqJson, &payreq); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	signer := createBenchmarkSigner(b)
+	payload := []byte("$alice@vasp1.com|1234|1700000000")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := signer.Sign(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCurrencyConversion measures a pay request response for an amount in the receiving currency, which is
+// converted to millisatoshis with the conversion rate.
+func BenchmarkCurrencyConversion(b *testing.B) {
+	sendingCurrencyCode := "USD"
+	payreq := umaprotocol.PayRequest{
+		SendingAmountCurrencyCode: &sendingCurrencyCode,
+		ReceivingCurrencyCode:     &sendingCurrencyCode,
+		Amount:                    1000,
+	}
+	metadata, err := createMetadataForBob()
+	require.NoError(b, err)
+	receivingCurrencyDecimals := benchmarkCurrency.Decimals
+	conversionRate := benchmarkCurrency.MillisatoshiPerUnit
+	fee := int64(100_000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := uma.GetPayReqResponse(
+			payreq,
+			&FakeInvoiceCreator{},
+			metadata,
+			&sendingCurrencyCode,
+			&receivingCurrencyDecimals,
+			&conversionRate,
+			&fee,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormatAmount(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = umacurrency.FormatAmount(595, benchmarkCurrency)
+	}
+}
@@ -0,0 +1,95 @@
+package uma_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/bech32"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// These invoices are from the BOLT11 specification examples.
+const bolt11AnyAmountInvoice = "lnbc1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq9qrsgq357wnc5r2ueh7ck6q93dj32dlqnls087fxdwk8qakdyafkq3yap9us6v52vjjsrvywa6rt52cm9r9zqt8r2t7mlcwspyetp5h2tztugp9lfyql"
+const bolt11ExpiringInvoice = "lnbc2500u1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpu9qrsgquk0rl77nj30yxdy8j9vdx85fkpmdla2087ne0xh8nhedh8w27kyke0lp53ut353s06fv3qfegext0eh0ymjpf39tuven09sam30g4vgpfna3rh"
+
+func TestDecodeBolt11(t *testing.T) {
+	invoice, err := utils.DecodeBolt11(bolt11AnyAmountInvoice)
+	require.NoError(t, err)
+	require.Equal(t, "bc", invoice.Network)
+	require.Nil(t, invoice.AmountMsats)
+	require.Equal(t, int64(1496314658), invoice.Timestamp.Unix())
+	require.Equal(t, time.Hour, invoice.Expiry)
+	require.Equal(t, "0001020304050607080900010203040506070809000102030405060708090102",
+		hex.EncodeToString(invoice.PaymentHash))
+
+	invoice, err = utils.DecodeBolt11("lightning:" + bolt11ExpiringInvoice)
+	require.NoError(t, err)
+	require.Equal(t, int64(250_000_000), *invoice.AmountMsats)
+	require.Equal(t, time.Minute, invoice.Expiry)
+	require.Equal(t, int64(1496314658+60), invoice.ExpiresAt().Unix())
+
+	invoice, err = utils.DecodeBolt11(newTestBolt11Invoice(t, "lnbcrt10p", time.Unix(1700000000, 0), 600))
+	require.NoError(t, err)
+	require.Equal(t, "bcrt", invoice.Network)
+	require.Equal(t, int64(1), *invoice.AmountMsats)
+	require.Equal(t, 10*time.Minute, invoice.Expiry)
+
+	descriptionHash := sha256.Sum256([]byte("metadata"))
+	invoice, err = utils.DecodeBolt11(newTestLnurlInvoice(t, "lnbcrt10n", descriptionHash[:]))
+	require.NoError(t, err)
+	require.Equal(t, descriptionHash[:], invoice.DescriptionHash)
+
+	_, err = utils.DecodeBolt11("lnbcrt100n1p0z9j")
+	require.Error(t, err)
+	_, err = utils.DecodeBolt11(newTestBolt11Invoice(t, "lnbc1p", time.Now(), 600))
+	require.Error(t, err)
+}
+
+// newTestBolt11Invoice encodes a BOLT11 invoice with the given human-readable part, timestamp and expiry. Only the
+// expiry field is included, and the signature is zeroed, which is enough for the SDK's validation.
+func newTestBolt11Invoice(t *testing.T, hrp string, timestamp time.Time, expirySecs uint64) string {
+	var data []byte
+	for i := 6; i >= 0; i-- {
+		data = append(data, byte(timestamp.Unix()>>(5*i))&31)
+	}
+	var expiry []byte
+	for value := expirySecs; value > 0; value >>= 5 {
+		expiry = append([]byte{byte(value & 31)}, expiry...)
+	}
+	data = append(data, 6, byte(len(expiry)>>5), byte(len(expiry)&31))
+	data = append(data, expiry...)
+	data = append(data, make([]byte, 104)...)
+	invoice, err := bech32.Encode(hrp, data)
+	require.NoError(t, err)
+	return invoice
+}
+
+// newTestLnurlInvoice encodes a BOLT11 invoice with the given human-readable part and description hash, as created by
+// LNURL-pay services. The signature is zeroed.
+func newTestLnurlInvoice(t *testing.T, hrp string, descriptionHash []byte) string {
+	return newTestHashInvoice(t, hrp, 23, descriptionHash)
+}
+
+// newTestPaymentHashInvoice encodes a BOLT11 invoice with the given human-readable part and payment hash. The
+// signature is zeroed.
+func newTestPaymentHashInvoice(t *testing.T, hrp string, paymentHash []byte) string {
+	return newTestHashInvoice(t, hrp, 1, paymentHash)
+}
+
+func newTestHashInvoice(t *testing.T, hrp string, tag byte, hash []byte) string {
+	var data []byte
+	for i := 6; i >= 0; i-- {
+		data = append(data, byte(time.Now().Unix()>>(5*i))&31)
+	}
+	hashGroups, err := bech32.ConvertBits(hash, 8, 5, true)
+	require.NoError(t, err)
+	data = append(data, tag, byte(len(hashGroups)>>5), byte(len(hashGroups)&31))
+	data = append(data, hashGroups...)
+	data = append(data, make([]byte, 104)...)
+	invoice, err := bech32.Encode(hrp, data)
+	require.NoError(t, err)
+	return invoice
+}
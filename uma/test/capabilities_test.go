@@ -0,0 +1,96 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// serveCapabilities serves public keys and, if configuration is non-nil, an UMA configuration, and returns the domain
+// of the server and a pointer to the number of requests it received.
+func serveCapabilities(t *testing.T, configuration *umaprotocol.UmaConfiguration) (string, *int) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var response interface{}
+		switch r.URL.Path {
+		case "/.well-known/lnurlpubkey":
+			response = pubKeyResponse
+		case "/.well-known/uma-configuration":
+			if configuration == nil {
+				http.NotFound(w, r)
+				return
+			}
+			response = configuration
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return serverUrl.Host, &requests
+}
+
+func TestGetCounterpartyCapabilities(t *testing.T) {
+	requestEndpoint := "https://vasp2.com/uma/request_pay_invoice"
+	domain, requests := serveCapabilities(t, &umaprotocol.UmaConfiguration{
+		UmaMajorVersions:   []int{1},
+		UmaRequestEndpoint: &requestEndpoint,
+		Currencies: &[]umaprotocol.Currency{
+			{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 34_150, Decimals: 2},
+		},
+		Features: []string{"payment_status_webhooks", "invoices"},
+	})
+	cache := uma.NewInMemoryCapabilitiesCache(time.Hour)
+
+	capabilities, err := uma.GetCounterpartyCapabilities(context.Background(), domain, cache)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, capabilities.UmaMajorVersions)
+	require.True(t, capabilities.SupportsMajorVersion(1))
+	require.False(t, capabilities.SupportsMajorVersion(0))
+	require.Equal(t, "USD", capabilities.Currencies[0].Code)
+	require.Equal(t, []uma.CounterpartyFeature{uma.FeatureInvoices, uma.FeaturePaymentStatusWebhooks}, capabilities.Features)
+	require.False(t, capabilities.SupportsFeature(uma.FeaturePayReqCancellation))
+	require.Equal(t, 2, *requests)
+
+	cachedCapabilities, err := uma.GetCounterpartyCapabilities(context.Background(), domain, cache)
+	require.NoError(t, err)
+	require.Same(t, capabilities, cachedCapabilities)
+	require.Equal(t, 2, *requests)
+}
+
+func TestGetCounterpartyCapabilitiesWithoutConfiguration(t *testing.T) {
+	domain, _ := serveCapabilities(t, nil)
+	capabilities, err := uma.GetCounterpartyCapabilities(
+		context.Background(), domain, uma.NewInMemoryCapabilitiesCache(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, capabilities.Configuration)
+	require.Nil(t, capabilities.UmaMajorVersions)
+	require.True(t, capabilities.SupportsMajorVersion(1))
+	require.Empty(t, capabilities.Features)
+	require.NotNil(t, capabilities.PubKeyResponse)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	_, err = uma.GetCounterpartyCapabilities(
+		context.Background(), serverUrl.Host, uma.NewInMemoryCapabilitiesCache(time.Hour))
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package uma_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestCircuitBreakerOpensAfterFailures(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := uma.CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Hour}
+	doer := uma.NewCircuitBreakerRequestDoer(server.Client(), config, nil)
+	domain := strings.TrimPrefix(server.URL, "http://")
+	for i := 0; i < 2; i++ {
+		_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+		var invalidResponseError uma.InvalidResponseError
+		require.ErrorAs(t, err, &invalidResponseError)
+	}
+
+	// The circuit is now open, so retries shouldn't reach the server either.
+	_, err := uma.FetchPublicKeyForVasp(
+		domain,
+		uma.NewInMemoryPublicKeyCache(),
+		uma.WithRequestDoer(doer),
+		uma.WithRetryPolicy(fastRetryPolicy()),
+	)
+	require.ErrorIs(t, err, uma.ErrCircuitOpen)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"signingPubKey":"abcd","encryptionPubKey":"abcd"}`))
+	}))
+	defer server.Close()
+
+	config := uma.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Minute}
+	doer := uma.NewCircuitBreakerRequestDoer(server.Client(), config, nil)
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	doer.SetClock(clock)
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+	require.Error(t, err)
+	_, err = uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+	require.ErrorIs(t, err, uma.ErrCircuitOpen)
+
+	clock.Advance(time.Minute)
+	fail.Store(false)
+	_, err = uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+	require.NoError(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"signingPubKey":"abcd","encryptionPubKey":"abcd"}`))
+	}))
+	defer server.Close()
+
+	config := uma.CircuitBreakerConfig{RequestsPerSecond: 0.001, Burst: 2}
+	doer := uma.NewCircuitBreakerRequestDoer(server.Client(), config, uma.NewInMemoryCircuitBreakerStore())
+	domain := strings.TrimPrefix(server.URL, "http://")
+	for i := 0; i < 2; i++ {
+		_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+		require.NoError(t, err)
+	}
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRequestDoer(doer))
+	require.ErrorIs(t, err, uma.ErrRateLimited)
+}
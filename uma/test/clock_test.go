@@ -0,0 +1,83 @@
+package uma_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestSignWithClock(t *testing.T) {
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer, uma.WithClock(clock))
+	require.NoError(t, err)
+	require.Equal(t, int64(1_700_000_000), request.Timestamp.Unix())
+
+	clock.Advance(time.Minute)
+	cancellation, err := uma.SignPayReqCancellation(umaprotocol.PayReqCancellation{
+		EncodedInvoice: umatest.FakeInvoice,
+		VaspDomain:     vaspDomain,
+	}, signer, uma.WithClock(clock))
+	require.NoError(t, err)
+	require.Equal(t, int64(1_700_000_060), cancellation.Timestamp)
+}
+
+func TestValidateQuoteExpiryWithClock(t *testing.T) {
+	now := time.Now()
+	clock := umatest.NewFakeClock(now)
+	expiresAt := now.Add(time.Minute).Unix()
+	response := umaprotocol.PayReqResponse{
+		EncodedInvoice: newTestBolt11Invoice(t, "lnbcrt1u", now, 30),
+		PaymentInfo: &umaprotocol.PayReqResponsePaymentInfo{
+			CurrencyCode: "USD", Multiplier: 34_150, Decimals: 2, ExpiresAt: &expiresAt,
+		},
+		UmaMajorVersion: 1,
+	}
+	require.NoError(t, uma.ValidateQuoteExpiry(&response, uma.WithClock(clock)))
+
+	clock.Advance(2 * time.Minute)
+	require.ErrorContains(t, uma.ValidateQuoteExpiry(&response, uma.WithClock(clock)), "quote expired")
+}
+
+func TestInMemoryPublicKeyCacheWithClock(t *testing.T) {
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	cache := uma.NewInMemoryPublicKeyCache()
+	cache.SetClock(clock)
+	expirationTimestamp := clock.Now().Add(time.Hour).Unix()
+	pubKeyResponse := umatest.SenderKeypair().PubKeyResponse()
+	pubKeyResponse.ExpirationTimestamp = &expirationTimestamp
+	cache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	require.NotNil(t, cache.FetchPublicKeyForVasp("vasp1.com"))
+
+	clock.Advance(2 * time.Hour)
+	require.Nil(t, cache.FetchPublicKeyForVasp("vasp1.com"))
+}
+
+func TestRotatingSignerWithClock(t *testing.T) {
+	now := time.Now()
+	clock := umatest.NewFakeClock(now)
+	signer, _, _ := createRotatingSigner(t, now.Add(time.Hour), now.Add(2*time.Hour))
+	signer.SetClock(clock)
+	require.Equal(t, "key-1", signer.CurrentKeyId())
+
+	clock.Advance(90 * time.Minute)
+	require.Equal(t, "key-2", signer.CurrentKeyId())
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	elapsed := clock.After(time.Minute)
+	clock.Advance(59 * time.Second)
+	require.Len(t, elapsed, 0)
+	clock.Advance(time.Second)
+	require.Equal(t, time.Unix(1_700_000_060, 0), <-elapsed)
+	require.Len(t, clock.After(0), 1)
+}
@@ -0,0 +1,153 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+type recordingComplianceProvider struct {
+	blockedPayee  string
+	blockedUtxo   string
+	screenings    []uma.UtxoScreeningRequest
+	registrations []uma.PaymentRegistration
+}
+
+func (p *recordingComplianceProvider) PreScreenPayee(_ context.Context, payeeIdentifier string) error {
+	if payeeIdentifier == p.blockedPayee {
+		return fmt.Errorf("%w: sanctioned payee", uma.ErrComplianceRejected)
+	}
+	return nil
+}
+
+func (p *recordingComplianceProvider) ScreenUtxos(_ context.Context, request uma.UtxoScreeningRequest) error {
+	p.screenings = append(p.screenings, request)
+	for _, utxo := range request.Utxos {
+		if utxo == p.blockedUtxo {
+			return fmt.Errorf("%w: high risk utxo", uma.ErrComplianceRejected)
+		}
+	}
+	return nil
+}
+
+func (p *recordingComplianceProvider) RegisterPayment(_ context.Context, registration uma.PaymentRegistration) error {
+	p.registrations = append(p.registrations, registration)
+	return nil
+}
+
+func TestComplianceProviderPreScreensPayee(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	receiverAddress := "$bob@" + strings.TrimPrefix(server.URL, "http://")
+	provider := &recordingComplianceProvider{blockedPayee: receiverAddress}
+	_, err := uma.SendLnurlpRequest(
+		context.Background(),
+		umaprotocol.LnurlpRequest{ReceiverAddress: receiverAddress},
+		uma.WithComplianceProvider(provider),
+	)
+	require.ErrorIs(t, err, uma.ErrComplianceRejected)
+	require.Equal(t, 0, requests)
+}
+
+func TestComplianceProviderScreensPayReqFlow(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	payerNodePubKey := "payerNode"
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		&[]string{"payerUtxo"},
+		&payerNodePubKey,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}
+	getResponse := func(provider uma.ComplianceProvider) (*umaprotocol.PayReqResponse, error) {
+		return uma.GetPayReqResponseWithSigner(
+			*payreq,
+			&FakeInvoiceCreator{},
+			metadata,
+			currency,
+			24_150,
+			100_000,
+			[]string{"payeeUtxo"},
+			"$bob@vasp2.com",
+			receiverSigner,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			uma.WithComplianceProvider(provider),
+		)
+	}
+
+	receiverProvider := &recordingComplianceProvider{}
+	payreqResponse, err := getResponse(receiverProvider)
+	require.NoError(t, err)
+	require.Equal(t, []uma.UtxoScreeningRequest{{
+		Direction:              uma.PaymentDirectionIncoming,
+		CounterpartyIdentifier: "$alice@vasp1.com",
+		Utxos:                  []string{"payerUtxo"},
+		NodePubKey:             &payerNodePubKey,
+	}}, receiverProvider.screenings)
+	require.Len(t, receiverProvider.registrations, 1)
+	require.Equal(t, payreqResponse.EncodedInvoice, receiverProvider.registrations[0].EncodedInvoice)
+
+	_, err = getResponse(&recordingComplianceProvider{blockedUtxo: "payerUtxo"})
+	require.ErrorIs(t, err, uma.ErrComplianceRejected)
+
+	responseJson, err := json.Marshal(payreqResponse)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(responseJson)
+	}))
+	defer server.Close()
+
+	senderProvider := &recordingComplianceProvider{}
+	_, err = uma.SendPayRequest(context.Background(), server.URL, payreq, uma.WithComplianceProvider(senderProvider))
+	require.NoError(t, err)
+	require.Len(t, senderProvider.screenings, 1)
+	require.Equal(t, uma.PaymentDirectionOutgoing, senderProvider.screenings[0].Direction)
+	require.Equal(t, "$bob@vasp2.com", senderProvider.screenings[0].CounterpartyIdentifier)
+	require.Equal(t, []string{"payeeUtxo"}, senderProvider.screenings[0].Utxos)
+	require.Len(t, senderProvider.registrations, 1)
+
+	_, err = uma.SendPayRequest(
+		context.Background(),
+		server.URL,
+		payreq,
+		uma.WithComplianceProvider(&recordingComplianceProvider{blockedUtxo: "payeeUtxo"}),
+	)
+	require.ErrorIs(t, err, uma.ErrComplianceRejected)
+}
@@ -0,0 +1,76 @@
+package uma_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestConversionReceipt(t *testing.T) {
+	payreq, response, receiverPrivateKey := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	quoteId := "quote-1"
+	response.PaymentInfo.QuoteId = &quoteId
+	now := time.Unix(1_700_000_000, 0)
+	clock := uma.WithClock(uma.ClockFunc(func() time.Time { return now }))
+
+	receipt, err := uma.NewConversionReceipt(response, clock)
+	require.NoError(t, err)
+	require.Equal(t, &uma.ConversionReceipt{
+		InputAmount:           1000,
+		InputCurrencyCode:     "USD",
+		InputCurrencyDecimals: 2,
+		Multiplier:            24_150,
+		FeesMillisatoshi:      100_000,
+		OutputMillisatoshi:    1000*24_150 + 100_000,
+		Timestamp:             now,
+		QuoteId:               "quote-1",
+	}, receipt)
+
+	// UMA v0 responses do not include the receiving amount, so it is derived from the invoice.
+	response.PaymentInfo.Amount = nil
+	receipt, err = uma.NewConversionReceipt(response, clock)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), receipt.InputAmount)
+
+	// Senders get the receipt with the InvoiceValidatedEvent.
+	_, response, receiverPrivateKey = createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	sink := &recordingEventSink{}
+	err = uma.VerifyPayReqResponse(response, uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com"),
+		getPubKeyResponse(receiverPrivateKey), getNonceCache(), "$alice@vasp1.com", regtest, uma.WithEventSink(sink))
+	require.NoError(t, err)
+	require.Len(t, sink.events, 1)
+	event := sink.events[0].(*uma.InvoiceValidatedEvent)
+	require.Equal(t, int64(1000*24_150+100_000), event.Conversion.OutputMillisatoshi)
+
+	// Receivers get it with the PaymentRegisteredEvent.
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	sink = &recordingEventSink{}
+	_, err = uma.GetPayReqResponseWithSigner(
+		*payreq,
+		bolt11InvoiceCreator{t: t},
+		metadata,
+		umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2},
+		24_150,
+		100_000,
+		[]string{"abcdef12345"},
+		"$bob@vasp2.com",
+		receiverSigner,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		clock,
+		uma.WithEventSink(sink),
+	)
+	require.NoError(t, err)
+	registeredEvent := sink.events[len(sink.events)-1].(*uma.PaymentRegisteredEvent)
+	require.Equal(t, int64(1000), registeredEvent.Conversion.InputAmount)
+	require.Equal(t, int64(1000*24_150+100_000), registeredEvent.Conversion.OutputMillisatoshi)
+	require.Equal(t, now, registeredEvent.Conversion.Timestamp)
+}
@@ -0,0 +1,83 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+// correlationIdRegistrar records the correlation ID of the context of each registration.
+type correlationIdRegistrar struct {
+	correlationIds []string
+}
+
+func (r *correlationIdRegistrar) RegisterTransaction(ctx context.Context, _ uma.TransactionRegistration) error {
+	r.correlationIds = append(r.correlationIds, uma.CorrelationIdFromContext(ctx))
+	return nil
+}
+
+func TestCorrelationIdPropagation(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	registrar := &correlationIdRegistrar{}
+	server := httptest.NewServer(uma.NewUtxoCallbackHandler(uma.NewInMemoryPublicKeyCache(), getNonceCache(), registrar))
+	defer server.Close()
+	signCallback := func() umaprotocol.PostTransactionCallback {
+		callback, err := uma.SignPostTransactionCallback(umaprotocol.PostTransactionCallback{
+			Utxos:      []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345:1", Amount: 1_000_000}},
+			VaspDomain: &sender.Domain,
+		}, sender.Signer)
+		require.NoError(t, err)
+		return *callback
+	}
+
+	err := uma.SendPostTransactionCallback(
+		context.Background(), server.URL, signCallback(), uma.WithCorrelationId("payment-123"))
+	require.NoError(t, err)
+	ctx := uma.ContextWithCorrelationId(context.Background(), "payment-456")
+	require.NoError(t, uma.SendPostTransactionCallback(ctx, server.URL, signCallback()))
+	require.Equal(t, []string{"payment-123", "payment-456"}, registrar.correlationIds)
+
+	// Requests without a valid correlation ID get a generated one, which is echoed on the response.
+	for _, correlationId := range []string{"", "bad id", strings.Repeat("a", 129)} {
+		body, err := json.Marshal(signCallback())
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set(uma.CorrelationIdHeader, correlationId)
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		generatedId := resp.Header.Get(uma.CorrelationIdHeader)
+		require.NotEmpty(t, generatedId)
+		require.NotEqual(t, correlationId, generatedId)
+		require.Equal(t, generatedId, registrar.correlationIds[len(registrar.correlationIds)-1])
+	}
+}
+
+func TestInvalidResponseErrorCorrelationId(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	callback := umaprotocol.PostTransactionCallback{}
+
+	err := uma.SendPostTransactionCallback(context.Background(), server.URL, callback, uma.WithCorrelationId("payment-123"))
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, "payment-123", invalidResponseError.CorrelationId)
+	require.EqualError(t, err, "invalid response from VASP: status 500 (correlation ID payment-123)")
+
+	err = uma.SendPostTransactionCallback(context.Background(), server.URL, callback)
+	require.EqualError(t, err, "invalid response from VASP: status 500")
+}
@@ -0,0 +1,89 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func newTestCorridorPolicy(jurisdiction string) uma.CurrencyCorridorPolicy {
+	return uma.CurrencyCorridorPolicy{
+		ResolveJurisdiction: func(_ context.Context, senderVaspDomain string) (string, error) {
+			return jurisdiction, nil
+		},
+		RestrictedCurrencies: map[string][]string{
+			"GB": {"USD"},
+			"KP": {uma.AllCurrencies},
+		},
+	}
+}
+
+func newTestCorridorLnurlpHandler(t *testing.T, senderPrivateKey *secp256k1.PrivateKey, policy uma.CurrencyCorridorPolicy) http.Handler {
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	_, receiverSigner := createSigner(t)
+	return uma.NewLnurlpRequestHandler(pubKeyCache, getNonceCache(),
+		func(_ context.Context, request *umaprotocol.LnurlpRequest) (*umaprotocol.LnurlpResponse, error) {
+			metadata, err := createMetadataForBob()
+			require.NoError(t, err)
+			return uma.GetLnurlpResponseWithSigner(
+				*request,
+				"https://vasp2.com/api/lnurl/payreq/$bob",
+				metadata,
+				[]umaprotocol.Currency{
+					{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 34_150, Decimals: 2},
+					{Code: "EUR", Name: "Euro", Symbol: "€", MillisatoshiPerUnit: 36_200, Decimals: 2},
+				},
+				umaprotocol.CounterPartyDataOptions{"compliance": {Mandatory: true}},
+				umaprotocol.KycStatusVerified,
+				receiverSigner,
+				true,
+				nil,
+				nil,
+				uma.WithCurrencyCorridorPolicy(policy),
+			)
+		})
+}
+
+func TestCurrencyCorridorPolicyFiltersCurrencies(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	recorder := serveLnurlpRequest(t, newTestCorridorLnurlpHandler(t, senderPrivateKey, newTestCorridorPolicy("GB")), senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	response, err := uma.ParseLnurlpResponse(recorder.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, *response.Currencies, 1)
+	require.Equal(t, "EUR", (*response.Currencies)[0].Code)
+
+	recorder = serveLnurlpRequest(t, newTestCorridorLnurlpHandler(t, senderPrivateKey, newTestCorridorPolicy("US")), senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	response, err = uma.ParseLnurlpResponse(recorder.Body.Bytes())
+	require.NoError(t, err)
+	require.Len(t, *response.Currencies, 2)
+}
+
+func TestRestrictedCurrencyCorridor(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	recorder := serveLnurlpRequest(t, newTestCorridorLnurlpHandler(t, senderPrivateKey, newTestCorridorPolicy("KP")), senderPrivateKey)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	var status map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	require.Equal(t, "ERROR", status["status"])
+	require.Contains(t, status["reason"], "restricted")
+
+	policy := newTestCorridorPolicy("GB")
+	require.ErrorIs(t, policy.CheckCurrency(context.Background(), "vasp1.com", "USD"), uma.ErrCorridorRestricted)
+	require.NoError(t, policy.CheckCurrency(context.Background(), "vasp1.com", "EUR"))
+	// Non-UMA senders have no jurisdiction.
+	require.NoError(t, policy.CheckCurrency(context.Background(), "", "USD"))
+}
@@ -0,0 +1,231 @@
+package uma_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+type fakeResolver struct {
+	addresses map[string][]net.IPAddr
+}
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addresses, ok := r.addresses[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addresses, nil
+}
+
+func TestDomainPolicyAllowAndDenyLists(t *testing.T) {
+	policy := &utils.DomainPolicy{
+		AllowedDomains: []string{"vasp.com", "*.trusted.com"},
+		DeniedDomains:  []string{"bad.trusted.com"},
+	}
+	require.NoError(t, policy.CheckDomain("vasp.com"))
+	require.NoError(t, policy.CheckDomain("VASP.com:443"))
+	require.NoError(t, policy.CheckDomain("pay.trusted.com"))
+	require.Error(t, policy.CheckDomain("bad.trusted.com"))
+	require.Error(t, policy.CheckDomain("trusted.com"))
+	require.Error(t, policy.CheckDomain("evil.com"))
+
+	var nilPolicy *utils.DomainPolicy
+	require.NoError(t, nilPolicy.CheckDomain("evil.com"))
+}
+
+func TestDomainPolicyScheme(t *testing.T) {
+	var nilPolicy *utils.DomainPolicy
+	require.Equal(t, "http", nilPolicy.Scheme("localhost:8080"))
+	require.Equal(t, "https", nilPolicy.Scheme("vasp.com"))
+	policy := &utils.DomainPolicy{RequireHttps: true}
+	require.Equal(t, "https", policy.Scheme("localhost:8080"))
+	require.Error(t, policy.CheckUrl(context.Background(), "http://localhost:8080/callback"))
+	require.Error(t, policy.CheckUrl(context.Background(), "http://vasp.com/callback"))
+	require.Error(t, (&utils.DomainPolicy{}).CheckUrl(context.Background(), "file:///etc/passwd"))
+	require.NoError(t, policy.CheckUrl(context.Background(), "https://vasp.com/callback"))
+}
+
+func TestDomainPolicyStagingDomains(t *testing.T) {
+	var nilPolicy *utils.DomainPolicy
+	require.Equal(t, "http", nilPolicy.Scheme("vaspexample.onion"))
+
+	policy := &utils.DomainPolicy{
+		AllowedPorts: []int{8443},
+		SchemeOverrides: map[string]string{
+			"*.staging.vasp.com":   "http",
+			"tls.staging.vasp.com": "https",
+		},
+		BlockPrivateIps: true,
+		Resolver:        fakeResolver{},
+	}
+	require.Equal(t, "http", policy.Scheme("api.staging.vasp.com:8443"))
+	require.Equal(t, "https", policy.Scheme("tls.staging.vasp.com"))
+	require.Equal(t, "https", policy.Scheme("vasp.com"))
+	ctx := context.Background()
+	require.NoError(t, policy.CheckDomain("api.staging.vasp.com:8443"))
+	require.Error(t, policy.CheckDomain("api.staging.vasp.com:8080"))
+	// Onion services are not resolved with DNS.
+	require.NoError(t, policy.CheckUrl(ctx, "http://vaspexample.onion/callback"))
+
+	request := umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@api.staging.vasp.com:8443"}
+	requestUrl, err := request.EncodeToUrlWithPolicy(policy)
+	require.NoError(t, err)
+	require.Equal(t, "http://api.staging.vasp.com:8443/.well-known/lnurlp/$bob", requestUrl.String())
+
+	policy.RequireHttps = true
+	require.Equal(t, "https", policy.Scheme("api.staging.vasp.com:8443"))
+	require.Equal(t, "https", policy.Scheme("vaspexample.onion"))
+}
+
+func TestDomainPolicyBlocksPrivateIps(t *testing.T) {
+	policy := &utils.DomainPolicy{
+		BlockPrivateIps: true,
+		Resolver: fakeResolver{addresses: map[string][]net.IPAddr{
+			"public.com":   {{IP: net.ParseIP("93.184.216.34")}},
+			"internal.com": {{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.5")}},
+		}},
+	}
+	ctx := context.Background()
+	require.NoError(t, policy.CheckUrl(ctx, "https://public.com/.well-known/lnurlpubkey"))
+	require.Error(t, policy.CheckUrl(ctx, "https://internal.com/.well-known/lnurlpubkey"))
+	require.Error(t, policy.CheckUrl(ctx, "https://169.254.169.254/latest/meta-data"))
+	require.Error(t, policy.CheckUrl(ctx, "https://[::1]:8443/callback"))
+	require.Error(t, policy.CheckUrl(ctx, "https://unknown.com/callback"))
+}
+
+func TestLnurlpRequestEncodeToUrlWithPolicy(t *testing.T) {
+	request := umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@localhost:8080"}
+	requestUrl, err := request.EncodeToUrlWithPolicy(&utils.DomainPolicy{RequireHttps: true})
+	require.NoError(t, err)
+	require.Equal(t, "https", requestUrl.Scheme)
+
+	_, err = request.EncodeToUrlWithPolicy(&utils.DomainPolicy{DeniedDomains: []string{"localhost"}})
+	var policyError utils.DomainPolicyError
+	require.ErrorAs(t, err, &policyError)
+}
+
+func TestToAsciiDomain(t *testing.T) {
+	testCases := []struct {
+		domain   string
+		expected string
+	}{
+		{"vasp2.com", "vasp2.com"},
+		{"localhost:8080", "localhost:8080"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"MÜNCHEN.de:443", "xn--mnchen-3ya.de:443"},
+		{"日本語.jp", "xn--wgv71a119e.jp"},
+		// Japanese labels may mix Han, Hiragana and Katakana.
+		{"例えテスト.jp", "xn--r8jwmjbj5840b.jp"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+	}
+	for _, testCase := range testCases {
+		asciiDomain, err := utils.ToAsciiDomain(testCase.domain, false)
+		require.NoError(t, err, testCase.domain)
+		require.Equal(t, testCase.expected, asciiDomain)
+	}
+
+	// "pаypal" with a Cyrillic "а", both directly and already in punycode.
+	for _, domain := range []string{"p\u0430ypal.com", "xn--pypal-4ve.com"} {
+		_, err := utils.ToAsciiDomain(domain, false)
+		require.ErrorContains(t, err, "mix scripts")
+		_, err = utils.ToAsciiDomain(domain, true)
+		require.NoError(t, err)
+	}
+
+	for _, domain := range []string{"xn--bcher-kv!.example", "-bücher.example", "bü cher.example"} {
+		_, err := utils.ToAsciiDomain(domain, true)
+		require.Error(t, err, domain)
+	}
+}
+
+func TestLnurlpRequestEncodeToUrlWithIdnDomain(t *testing.T) {
+	request := umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@bücher.example"}
+	requestUrl, err := request.EncodeToUrl()
+	require.NoError(t, err)
+	require.Equal(t, "https://xn--bcher-kva.example/.well-known/lnurlp/$bob", requestUrl.String())
+
+	_, err = request.EncodeToUrlWithPolicy(&utils.DomainPolicy{DeniedDomains: []string{"bücher.example"}})
+	var policyError utils.DomainPolicyError
+	require.ErrorAs(t, err, &policyError)
+
+	homograph := umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@p\u0430ypal.com"}
+	_, err = homograph.EncodeToUrl()
+	require.Error(t, err)
+	requestUrl, err = homograph.EncodeToUrlWithPolicy(&utils.DomainPolicy{AllowMixedScriptDomains: true})
+	require.NoError(t, err)
+	require.Equal(t, "xn--pypal-4ve.com", requestUrl.Host)
+}
+
+func TestFetchHelpersEnforceDomainPolicy(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	domain := strings.TrimPrefix(server.URL, "http://")
+	withPolicy := uma.WithDomainPolicy(utils.DomainPolicy{BlockPrivateIps: true})
+
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), withPolicy)
+	var policyError utils.DomainPolicyError
+	require.ErrorAs(t, err, &policyError)
+
+	_, err = uma.SendLnurlpRequest(context.Background(), umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@" + domain}, withPolicy)
+	require.ErrorAs(t, err, &policyError)
+
+	_, err = uma.SendPayRequest(context.Background(), server.URL+"/callback", &umaprotocol.PayRequest{}, withPolicy)
+	require.ErrorAs(t, err, &policyError)
+	require.Equal(t, 0, requests)
+
+	_, err = uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache())
+	require.Error(t, err)
+	require.Equal(t, 1, requests)
+}
+
+func TestDomainPolicyAppliesToRedirects(t *testing.T) {
+	var blockedRequests int
+	blockedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		blockedRequests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer blockedServer.Close()
+	redirectingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, blockedServer.URL+"/internal", http.StatusTemporaryRedirect)
+	}))
+	defer redirectingServer.Close()
+	redirectingUrl, err := url.Parse(redirectingServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(redirectingUrl.Port())
+	require.NoError(t, err)
+	withPolicy := uma.WithDomainPolicy(utils.DomainPolicy{AllowedPorts: []int{port}})
+
+	var policyError utils.DomainPolicyError
+	for _, doer := range []uma.RequestDoer{uma.DefaultRequestDoer, uma.NewRequestDoer(http.DefaultTransport)} {
+		_, err = uma.SendPayRequest(context.Background(), redirectingServer.URL+"/callback", &umaprotocol.PayRequest{},
+			withPolicy, uma.WithRequestDoer(doer))
+		require.ErrorAs(t, err, &policyError)
+		require.Equal(t, 0, blockedRequests)
+	}
+
+	// The response of other RequestDoers which follow redirects is rejected.
+	_, err = uma.SendPayRequest(context.Background(), redirectingServer.URL+"/callback", &umaprotocol.PayRequest{},
+		withPolicy, uma.WithRequestDoer(uma.RequestDoerFunc(http.DefaultClient.Do)))
+	require.ErrorAs(t, err, &policyError)
+
+	// Without a policy, redirects are followed.
+	_, err = uma.SendPayRequest(context.Background(), redirectingServer.URL+"/callback", &umaprotocol.PayRequest{})
+	require.False(t, errors.As(err, &policyError))
+	require.Equal(t, 2, blockedRequests)
+}
@@ -0,0 +1,154 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestEnvelopeRoundTripLnurlpRequest(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(t, err)
+	request, err := uma.ParseLnurlpRequest(*queryUrl)
+	require.NoError(t, err)
+
+	encoded, err := uma.EncodeEnvelope(request, uma.WithCorrelationId("payment-123"))
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypeLnurlpRequest, envelope.Type)
+	require.Equal(t, "payment-123", envelope.CorrelationId)
+
+	decoded, ok := envelope.Message.(*umaprotocol.LnurlpRequest)
+	require.True(t, ok)
+	require.Equal(t, "$bob@vasp2.com", decoded.ReceiverAddress)
+	err = uma.VerifyUmaLnurlpQuerySignature(*decoded.AsUmaRequest(), getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestEnvelopeRoundTripPayRequestAndResponse(t *testing.T) {
+	payreq, payreqResponse, receiverPrivateKey := createPayReqAndResponse(t, 1000, true, &FakeInvoiceCreator{})
+
+	encoded, err := uma.EncodeEnvelope(payreq)
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded, uma.WithRawPayloadCapture())
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePayRequest, envelope.Type)
+	require.Empty(t, envelope.CorrelationId)
+	decodedPayreq, ok := envelope.Message.(*umaprotocol.PayRequest)
+	require.True(t, ok)
+	require.Equal(t, []byte(envelope.Payload), decodedPayreq.RawResponse)
+	require.Equal(t, payreq.Amount, decodedPayreq.Amount)
+	require.Equal(t, *payreq.ReceivingCurrencyCode, *decodedPayreq.ReceivingCurrencyCode)
+
+	encoded, err = uma.EncodeEnvelope(payreqResponse)
+	require.NoError(t, err)
+	envelope, err = uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePayReqResponse, envelope.Type)
+	decodedResponse, ok := envelope.Message.(*umaprotocol.PayReqResponse)
+	require.True(t, ok)
+	err = uma.VerifyPayReqResponseSignature(
+		decodedResponse, getPubKeyResponse(receiverPrivateKey), getNonceCache(), "$alice@vasp1.com", "$bob@vasp2.com")
+	require.NoError(t, err)
+}
+
+func TestEnvelopeRoundTripSignedMessages(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	callback, err := uma.GetPostTransactionCallback(
+		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		"vasp1.com",
+		createPrivateKeyHandle(t, privateKey),
+	)
+	require.NoError(t, err)
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusPaid,
+		VaspDomain:     "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+
+	encoded, err := uma.EncodeEnvelope(callback)
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePostTransactionCallback, envelope.Type)
+	decodedCallback := envelope.Message.(*umaprotocol.PostTransactionCallback)
+	err = uma.VerifyPostTransactionCallbackSignature(decodedCallback, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	encoded, err = uma.EncodeEnvelope(webhook)
+	require.NoError(t, err)
+	envelope, err = uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePaymentStatusWebhook, envelope.Type)
+	decodedWebhook := envelope.Message.(*umaprotocol.PaymentStatusWebhook)
+	err = uma.VerifyPaymentStatusWebhookSignature(decodedWebhook, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestEnvelopeRoundTripPubKeyResponse(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+
+	encoded, err := uma.EncodeEnvelope(&pubKeyResponse)
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePubKeyResponse, envelope.Type)
+	require.Equal(t, *pubKeyResponse.SigningPubKeyHex, *envelope.Message.(*umaprotocol.PubKeyResponse).SigningPubKeyHex)
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+	_, err := uma.EncodeEnvelope("not a message")
+	require.Error(t, err)
+
+	unknown, err := json.Marshal(map[string]interface{}{"type": "refund", "payload": map[string]string{}})
+	require.NoError(t, err)
+	_, err = uma.DecodeEnvelope(unknown)
+	require.ErrorContains(t, err, "unknown envelope message type")
+
+	_, err = uma.DecodeEnvelope([]byte(`{"type":"payreq"}`))
+	require.Error(t, err)
+
+	_, err = uma.DecodeEnvelope([]byte(`{"type":"payment_status_webhook","payload":{"status":"REFUNDED"}}`))
+	require.ErrorContains(t, err, "invalid payment_status_webhook payload")
+}
+
+func TestEnvelopeWithExternalFraming(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	callback, err := uma.GetPostTransactionCallback(
+		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		"vasp1.com",
+		createPrivateKeyHandle(t, privateKey),
+	)
+	require.NoError(t, err)
+	envelope, err := uma.NewEnvelope(callback, uma.WithCorrelationId("payment-123"))
+	require.NoError(t, err)
+	require.Equal(t, callback, envelope.Message)
+
+	// Simulates copying the fields into and out of a protobuf UmaEnvelope.
+	received := uma.Envelope{
+		Type:          uma.MessageType(string(envelope.Type)),
+		CorrelationId: envelope.CorrelationId,
+		Payload:       append([]byte(nil), envelope.Payload...),
+	}
+	require.NoError(t, received.DecodeMessage())
+	require.Equal(t, "payment-123", received.CorrelationId)
+	decodedCallback := received.Message.(*umaprotocol.PostTransactionCallback)
+	err = uma.VerifyPostTransactionCallbackSignature(decodedCallback, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	received.Payload = []byte(`{"utxos":"not a list"}`)
+	require.Error(t, received.DecodeMessage())
+	require.Nil(t, received.Message)
+}
@@ -0,0 +1,99 @@
+package uma_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+type recordingEventSink struct {
+	mutex  sync.Mutex
+	events []uma.Event
+}
+
+func (s *recordingEventSink) HandleEvent(_ context.Context, event uma.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *recordingEventSink) types() []uma.EventType {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var types []uma.EventType
+	for _, event := range s.events {
+		types = append(types, event.Type())
+	}
+	return types
+}
+
+func TestLnurlpVerifiedEvent(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	sink := &recordingEventSink{}
+	handler := newTestLnurlpRequestHandler(t, senderPrivateKey, uma.WithEventSink(sink), uma.WithCorrelationId("flow1"))
+
+	recorder := serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, []uma.EventType{uma.EventTypeLnurlpVerified}, sink.types())
+	event := sink.events[0].(*uma.LnurlpVerifiedEvent)
+	require.Equal(t, "vasp1.com", event.SenderVaspDomain)
+	require.Equal(t, "$bob@vasp2.com", event.ReceiverAddress)
+	require.Equal(t, "flow1", event.Metadata().CorrelationId)
+
+	// Requests failing verification are not reported.
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	recorder = serveLnurlpRequest(t, handler, otherPrivateKey)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Len(t, sink.types(), 1)
+}
+
+func TestPayReqBuiltEvent(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	sink := &recordingEventSink{}
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+
+	_, err = uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderPrivateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+		uma.WithEventSink(uma.EventSinkFunc(sink.HandleEvent)),
+		uma.WithClock(clock),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []uma.EventType{uma.EventTypePayReqBuilt}, sink.types())
+	require.Equal(t, &uma.PayReqBuiltEvent{
+		EventMetadata:             uma.EventMetadata{Timestamp: clock.Now()},
+		PayerIdentifier:           "$alice@vasp1.com",
+		Amount:                    1000,
+		AmountInReceivingCurrency: true,
+		ReceivingCurrencyCode:     "USD",
+		UmaMajorVersion:           1,
+	}, sink.events[0])
+}
@@ -0,0 +1,208 @@
+package uma_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// regtest accepts the regtest invoices created by bolt11InvoiceCreator and newTestBolt11Invoice.
+var regtest = uma.WithNetwork(uma.NetworkRegtest)
+
+// bolt11InvoiceCreator creates BOLT11 invoices for the requested amount, offset by amountOffsetMsats.
+type bolt11InvoiceCreator struct {
+	t                 *testing.T
+	amountOffsetMsats int64
+}
+
+func (b bolt11InvoiceCreator) CreateInvoice(amountMsats int64, _ string, _ *string) (*string, error) {
+	hrp := "lnbcrt" + strconv.FormatInt((amountMsats+b.amountOffsetMsats)*10, 10) + "p"
+	encodedInvoice := newTestBolt11Invoice(b.t, hrp, time.Now(), 600)
+	return &encodedInvoice, nil
+}
+
+func createPayReqAndResponse(
+	t *testing.T,
+	amount int64,
+	isAmountInReceivingCurrency bool,
+	invoiceCreator uma.InvoiceCreator,
+) (*umaprotocol.PayRequest, *umaprotocol.PayReqResponse, *secp256k1.PrivateKey) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverSigningPrivateKey, receiverSigner := createSigner(t)
+	payreq, err := uma.GetUmaPayRequest(
+		amount,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"USD",
+		isAmountInReceivingCurrency,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		&umaprotocol.CounterPartyDataOptions{"name": {Mandatory: false}},
+		nil,
+	)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}
+	payreqResponse, err := uma.GetPayReqResponseWithSigner(
+		*payreq,
+		invoiceCreator,
+		metadata,
+		currency,
+		24_150,
+		100_000,
+		[]string{"abcdef12345"},
+		"$bob@vasp2.com",
+		receiverSigner,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	return payreq, payreqResponse, receiverSigningPrivateKey
+}
+
+func TestVerifyPayReqResponse(t *testing.T) {
+	payreq, response, receiverPrivateKey := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	expected := uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com")
+	require.Equal(t, "USD", expected.ReceivingCurrencyCode)
+	require.True(t, expected.AmountInReceivingCurrency)
+	verify := func(response *umaprotocol.PayReqResponse, expected uma.ExpectedPayment) error {
+		return uma.VerifyPayReqResponse(
+			response, expected, getPubKeyResponse(receiverPrivateKey), getNonceCache(), "$alice@vasp1.com", regtest)
+	}
+	require.NoError(t, verify(response, expected))
+
+	// A replayed response is rejected by the nonce cache.
+	nonceCache := getNonceCache()
+	require.NoError(t, uma.VerifyPayReqResponse(
+		response, expected, getPubKeyResponse(receiverPrivateKey), nonceCache, "$alice@vasp1.com", regtest))
+	require.Error(t, uma.VerifyPayReqResponse(
+		response, expected, getPubKeyResponse(receiverPrivateKey), nonceCache, "$alice@vasp1.com", regtest))
+
+	wrongPayee := expected
+	wrongPayee.PayeeIdentifier = "$carol@vasp2.com"
+	require.Error(t, verify(response, wrongPayee))
+
+	wrongCurrency := expected
+	wrongCurrency.ReceivingCurrencyCode = "EUR"
+	require.ErrorContains(t, verify(response, wrongCurrency), "expected currency EUR")
+
+	wrongAmount := expected
+	wrongAmount.Amount = 999
+	require.ErrorContains(t, verify(response, wrongAmount), "expected the receiver to receive 999")
+
+	requiresName := expected
+	requiresName.RequestedPayeeData = &umaprotocol.CounterPartyDataOptions{"name": {Mandatory: true}}
+	require.ErrorContains(t, verify(response, requiresName), "missing mandatory payee data fields: name")
+
+	disposable := false
+	response.Disposable = &disposable
+	require.ErrorContains(t, verify(response, expected), "disposable")
+	response.Disposable = nil
+
+	expiredAt := time.Now().Add(-time.Minute).Unix()
+	response.PaymentInfo.ExpiresAt = &expiredAt
+	require.ErrorContains(t, verify(response, expected), "quote expired")
+	response.PaymentInfo.ExpiresAt = nil
+
+	response.PaymentInfo.Multiplier = 25_000
+	require.ErrorContains(t, verify(response, expected), "does not match the payment info")
+}
+
+func TestVerifyPayReqResponseInvoiceNetwork(t *testing.T) {
+	payreq, response, receiverPrivateKey := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	expected := uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com")
+	verify := func(opts ...uma.Option) error {
+		return uma.VerifyPayReqResponse(
+			response, expected, getPubKeyResponse(receiverPrivateKey), getNonceCache(), "$alice@vasp1.com", opts...)
+	}
+	// Test invoices are rejected unless the network is set explicitly.
+	var networkErr uma.InvoiceNetworkError
+	require.ErrorAs(t, verify(), &networkErr)
+	require.Equal(t, uma.NetworkMainnet, networkErr.Expected)
+	require.Equal(t, "bcrt", networkErr.InvoicePrefix)
+	require.ErrorAs(t, verify(uma.WithNetwork(uma.NetworkTestnet)), &networkErr)
+	require.NoError(t, verify(regtest))
+
+	// Receivers which set the network check the invoices they create.
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	createResponse := func(opts ...uma.Option) error {
+		_, err := uma.GetPayReqResponseWithSigner(
+			*payreq,
+			bolt11InvoiceCreator{t: t},
+			metadata,
+			umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2},
+			24_150,
+			100_000,
+			[]string{"abcdef12345"},
+			"$bob@vasp2.com",
+			receiverSigner,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			opts...,
+		)
+		return err
+	}
+	require.NoError(t, createResponse())
+	require.ErrorAs(t, createResponse(uma.WithNetwork(uma.NetworkSignet)), &networkErr)
+	require.NoError(t, createResponse(regtest))
+}
+
+func TestVerifyPayReqResponseInvoiceAmount(t *testing.T) {
+	payreq, response, receiverPrivateKey := createPayReqAndResponse(
+		t, 1000, true, bolt11InvoiceCreator{t: t, amountOffsetMsats: 5})
+	expected := uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com")
+	verify := func(expected uma.ExpectedPayment) error {
+		return uma.VerifyPayReqResponse(
+			response, expected, getPubKeyResponse(receiverPrivateKey), getNonceCache(), "$alice@vasp1.com", regtest)
+	}
+	require.ErrorContains(t, verify(expected), "does not match the payment info")
+	expected.AmountToleranceMsats = 5
+	require.NoError(t, verify(expected))
+
+	// The tolerance can also be set for all payments, in absolute or relative terms.
+	expected.AmountToleranceMsats = 0
+	verifyWithTolerance := func(tolerance uma.InvoiceAmountTolerance) error {
+		return uma.VerifyPayReqResponse(response, expected, getPubKeyResponse(receiverPrivateKey), getNonceCache(),
+			"$alice@vasp1.com", regtest, uma.WithInvoiceAmountTolerance(tolerance))
+	}
+	require.NoError(t, verifyWithTolerance(uma.InvoiceAmountTolerance{Msats: 5}))
+	require.ErrorContains(t, verifyWithTolerance(uma.InvoiceAmountTolerance{Msats: 4}), "does not match the payment info")
+	// 24,250,000 msats are implied, so that 1 bps is 2,425 msats.
+	require.NoError(t, verifyWithTolerance(uma.InvoiceAmountTolerance{Bps: 1}))
+
+	// When the sender locks the amount in msats, the receiving amount is rounded, and the invoice must be exact.
+	payreq, response, receiverPrivateKey = createPayReqAndResponse(t, 1_000_000, false, bolt11InvoiceCreator{t: t})
+	expected = uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com")
+	require.False(t, expected.AmountInReceivingCurrency)
+	require.NoError(t, verify(expected))
+	expected.Amount = 1_000_001
+	require.ErrorContains(t, verify(expected), "expected an invoice for 1000001 msats")
+
+	_, response, receiverPrivateKey = createPayReqAndResponse(t, 1000, true, &FakeInvoiceCreator{})
+	require.ErrorContains(t, verify(uma.ExpectedPaymentFromPayRequest(*payreq, "$bob@vasp2.com")), "unable to decode invoice")
+}
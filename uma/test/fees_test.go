@@ -0,0 +1,89 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func createPayReqResponseWithFees(t *testing.T, fees umaprotocol.PaymentFees) (*umaprotocol.PayReqResponse, error) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	return uma.GetPayReqResponseWithSigner(
+		*createSignedPayRequest(t, senderPrivateKey),
+		bolt11InvoiceCreator{t: t},
+		metadata,
+		umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2},
+		24_150,
+		100_000,
+		[]string{"abcdef12345"},
+		"$bob@vasp2.com",
+		receiverSigner,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		uma.WithFeeBreakdown(fees),
+	)
+}
+
+func TestFeeBreakdown(t *testing.T) {
+	networkFeeEstimate := int64(5_000)
+	fees := umaprotocol.PaymentFees{
+		ExchangeFeeMillisatoshi:        60_000,
+		ServiceFeeMillisatoshi:         40_000,
+		NetworkFeeEstimateMillisatoshi: &networkFeeEstimate,
+	}
+	response, err := createPayReqResponseWithFees(t, fees)
+	require.NoError(t, err)
+
+	responseJson, err := json.Marshal(response)
+	require.NoError(t, err)
+	parsedResponse, err := uma.ParsePayReqResponse(responseJson, strictParsing)
+	require.NoError(t, err)
+	require.Equal(t, &fees, parsedResponse.PaymentInfo.Fees)
+
+	summary, err := uma.GetPaymentCostSummary(parsedResponse, 2_000)
+	require.NoError(t, err)
+	require.Equal(t, uma.PaymentCostSummary{
+		ReceivingAmount:                parsedResponse.PaymentInfo.Amount,
+		ReceivingCurrencyCode:          "USD",
+		ReceivingCurrencyDecimals:      2,
+		InvoiceAmountMillisatoshi:      1000*24_150 + 100_000,
+		ExchangeFeeMillisatoshi:        60_000,
+		ServiceFeeMillisatoshi:         40_000,
+		NetworkFeeEstimateMillisatoshi: 5_000,
+		SenderFeeMillisatoshi:          2_000,
+		TotalFeesMillisatoshi:          107_000,
+		TotalCostMillisatoshi:          1000*24_150 + 107_000,
+	}, *summary)
+
+	// Without a breakdown, all of the receiver's fees are reported as exchange fees.
+	parsedResponse.PaymentInfo.Fees = nil
+	summary, err = uma.GetPaymentCostSummary(parsedResponse, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(100_000), summary.ExchangeFeeMillisatoshi)
+	require.Equal(t, int64(1000*24_150+100_000), summary.TotalCostMillisatoshi)
+}
+
+func TestFeeBreakdownMustAddUp(t *testing.T) {
+	_, err := createPayReqResponseWithFees(t, umaprotocol.PaymentFees{
+		ExchangeFeeMillisatoshi: 60_000,
+		ServiceFeeMillisatoshi:  10_000,
+	})
+	require.ErrorContains(t, err, "do not add up")
+
+	response, err := createPayReqResponseWithFees(t, umaprotocol.PaymentFees{ExchangeFeeMillisatoshi: 100_000})
+	require.NoError(t, err)
+	response.PaymentInfo.Fees.ServiceFeeMillisatoshi = 1_000
+	_, err = uma.GetPaymentCostSummary(response, 0)
+	require.ErrorContains(t, err, "do not add up")
+}
@@ -0,0 +1,317 @@
+package uma_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+type recordingRequestDoer struct {
+	requests []*http.Request
+	next     uma.RequestDoer
+}
+
+func (d *recordingRequestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+	return d.next.Do(req)
+}
+
+func TestFetchPublicKeyWithRequestDoer(t *testing.T) {
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/.well-known/lnurlpubkey", r.URL.Path)
+		responseJson, err := json.Marshal(&pubKeyResponse)
+		require.NoError(t, err)
+		_, _ = w.Write(responseJson)
+	}))
+	defer server.Close()
+
+	doer := &recordingRequestDoer{next: server.Client()}
+	cache := uma.NewInMemoryPublicKeyCache()
+	domain := strings.TrimPrefix(server.URL, "http://")
+	fetched, err := uma.FetchPublicKeyForVasp(domain, cache, uma.WithRequestDoer(doer))
+	require.NoError(t, err)
+	require.Equal(t, *pubKeyResponse.SigningPubKeyHex, *fetched.SigningPubKeyHex)
+	require.Len(t, doer.requests, 1)
+
+	// The second fetch should be served from the cache.
+	_, err = uma.FetchPublicKeyForVasp(domain, cache, uma.WithRequestDoer(doer))
+	require.NoError(t, err)
+	require.Len(t, doer.requests, 1)
+}
+
+func TestFetchPublicKeyInvalidStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache())
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, http.StatusNotFound, invalidResponseError.StatusCode)
+}
+
+func TestFetchPublicKeyRejectsRedirectToAnotherDomain(t *testing.T) {
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	otherVaspServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responseJson, err := json.Marshal(&pubKeyResponse)
+		require.NoError(t, err)
+		_, _ = w.Write(responseJson)
+	}))
+	defer otherVaspServer.Close()
+	redirectingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherVaspUrl := strings.Replace(otherVaspServer.URL, "127.0.0.1", "localhost", 1)
+		http.Redirect(w, r, otherVaspUrl+r.URL.Path, http.StatusFound)
+	}))
+	defer redirectingServer.Close()
+
+	cache := uma.NewInMemoryPublicKeyCache()
+	domain := strings.TrimPrefix(redirectingServer.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, cache)
+	require.ErrorContains(t, err, "not on its registrable domain")
+	require.Nil(t, cache.FetchPublicKeyForVasp(domain))
+}
+
+func TestSendLnurlpRequestUnsupportedVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		_, _ = w.Write([]byte(`{"unsupportedVersion":"2.0","supportedMajorVersions":[1,0]}`))
+	}))
+	defer server.Close()
+
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	_, err = uma.SendLnurlpRequest(context.Background(), *request)
+	var unsupportedVersionError uma.UnsupportedVersionError
+	require.ErrorAs(t, err, &unsupportedVersionError)
+	require.Equal(t, []int{1, 0}, unsupportedVersionError.SupportedMajorVersions)
+}
+
+func TestSendLnurlpRequestWithNegotiation(t *testing.T) {
+	// The receiver only supports 0.3, which the sender supports for backwards compatibility.
+	supportedMajorVersions := `[0]`
+	var requestedVersions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := r.URL.Query().Get("umaVersion")
+		requestedVersions = append(requestedVersions, version)
+		if version != "0.3" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`{"unsupportedVersion":"` + version + `","supportedMajorVersions":` +
+				supportedMajorVersions + `}`))
+			return
+		}
+		_, _ = io.WriteString(w, `{"tag":"payRequest","callback":"https://vasp2.com/payreq","minSendable":1,`+
+			`"maxSendable":1000,"metadata":"[]","umaVersion":"0.3"}`)
+	}))
+	defer server.Close()
+
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request := umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+		VaspDomain:      &vaspDomain,
+	}
+	response, result, err := uma.SendLnurlpRequestWithNegotiation(context.Background(), request, signer)
+	require.NoError(t, err)
+	require.Equal(t, "0.3", *response.UmaVersion)
+	require.Equal(t, &uma.VersionNegotiationResult{
+		RequestedVersion:                   uma.UmaProtocolVersion,
+		ChosenVersion:                      "0.3",
+		CounterpartySupportedMajorVersions: []int{0},
+		Downgraded:                         true,
+	}, result)
+	require.Equal(t, []string{uma.UmaProtocolVersion, "0.3"}, requestedVersions)
+
+	// A request at a supported version is not downgraded.
+	requestedVersions = nil
+	oldVersion := "0.3"
+	request.UmaVersion = &oldVersion
+	_, result, err = uma.SendLnurlpRequestWithNegotiation(context.Background(), request, signer)
+	require.NoError(t, err)
+	require.False(t, result.Downgraded)
+	require.Nil(t, result.CounterpartySupportedMajorVersions)
+	require.Equal(t, []string{"0.3"}, requestedVersions)
+
+	// Without a common major version, the receiver's supported versions are still reported.
+	supportedMajorVersions = `[2]`
+	request.UmaVersion = nil
+	_, result, err = uma.SendLnurlpRequestWithNegotiation(context.Background(), request, signer)
+	var unsupportedVersionError uma.UnsupportedVersionError
+	require.ErrorAs(t, err, &unsupportedVersionError)
+	require.Equal(t, []int{2}, result.CounterpartySupportedMajorVersions)
+}
+
+func TestSendPayRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "1000", r.URL.Query().Get("amount"))
+		require.Equal(t, "abc", r.URL.Query().Get("k"))
+		_, _ = io.WriteString(w, `{"pr":"lnbcrt100n1p0z9j","routes":[]}`)
+	}))
+	defer server.Close()
+
+	response, err := uma.SendPayRequest(
+		context.Background(),
+		server.URL+"/api/lnurl/payreq/$bob?k=abc",
+		&umaprotocol.PayRequest{Amount: 1000, UmaMajorVersion: 1},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "lnbcrt100n1p0z9j", response.EncodedInvoice)
+}
+
+func TestSendPayRequestHtmlResponse(t *testing.T) {
+	errorPage := "<!DOCTYPE html><html><body>" + strings.Repeat("Service unavailable. ", 50) + "</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, errorPage)
+	}))
+	defer server.Close()
+
+	_, err := uma.SendPayRequest(
+		context.Background(),
+		server.URL+"/api/lnurl/payreq/$bob",
+		&umaprotocol.PayRequest{Amount: 1000, UmaMajorVersion: 1},
+	)
+	require.ErrorIs(t, err, uma.ErrCounterpartyMalformedResponse)
+	var malformedResponseError uma.CounterpartyMalformedResponseError
+	require.ErrorAs(t, err, &malformedResponseError)
+	require.Equal(t, http.StatusOK, malformedResponseError.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", malformedResponseError.ContentType)
+	require.Len(t, malformedResponseError.BodySnippet, 256)
+	require.True(t, strings.HasPrefix(errorPage, malformedResponseError.BodySnippet))
+}
+
+func TestSendLnurlpRequestNonJsonResponse(t *testing.T) {
+	// A captive portal which does not set a content type.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		_, _ = io.WriteString(w, "  Please log in to the network")
+	}))
+	defer server.Close()
+
+	_, err := uma.SendLnurlpRequest(context.Background(), umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+	})
+	var malformedResponseError uma.CounterpartyMalformedResponseError
+	require.ErrorAs(t, err, &malformedResponseError)
+	require.Equal(t, "Please log in to the network", malformedResponseError.BodySnippet)
+}
+
+func TestSendPayRequestGzipResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(w)
+		_, _ = io.WriteString(gzipWriter, `{"pr":"lnbcrt100n1p0z9j","routes":[]}`)
+		_ = gzipWriter.Close()
+	}))
+	defer server.Close()
+
+	response, err := uma.SendPayRequest(
+		context.Background(),
+		server.URL+"/api/lnurl/payreq/$bob",
+		&umaprotocol.PayRequest{Amount: 1000, UmaMajorVersion: 1},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "lnbcrt100n1p0z9j", response.EncodedInvoice)
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+	var compressedBomb bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressedBomb)
+	_, err := gzipWriter.Write(make([]byte, 2*uma.DefaultMaxResponseBodySize))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(compressedBomb.Bytes())
+			return
+		}
+		// Stream a chunked body without a Content-Length.
+		_, _ = io.WriteString(w, `{"pr":"`)
+		for i := 0; i < 100; i++ {
+			_, _ = w.Write(bytes.Repeat([]byte("a"), 1024))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	request := &umaprotocol.PayRequest{Amount: 1000, UmaMajorVersion: 1}
+	_, err = uma.SendPayRequest(context.Background(), server.URL+"/payreq", request, uma.WithMaxResponseBodySize(64*1024))
+	var responseTooLargeError uma.ResponseTooLargeError
+	require.ErrorAs(t, err, &responseTooLargeError)
+	require.Equal(t, int64(64*1024), responseTooLargeError.Limit)
+
+	_, err = uma.SendPayRequest(context.Background(), server.URL+"/payreq?gzip=true", request)
+	require.ErrorAs(t, err, &responseTooLargeError)
+	require.Equal(t, int64(uma.DefaultMaxResponseBodySize), responseTooLargeError.Limit)
+}
+
+func TestInterceptors(t *testing.T) {
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		responseJson, err := json.Marshal(&pubKeyResponse)
+		require.NoError(t, err)
+		_, _ = w.Write(responseJson)
+	}))
+	defer server.Close()
+
+	var calls []string
+	namedInterceptor := func(name string) uma.Interceptor {
+		return func(next uma.RequestDoer) uma.RequestDoer {
+			return uma.RequestDoerFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				response, err := next.Do(req)
+				calls = append(calls, name+" response")
+				return response, err
+			})
+		}
+	}
+	authInterceptor := func(next uma.RequestDoer) uma.RequestDoer {
+		return uma.RequestDoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer token")
+			return next.Do(req)
+		})
+	}
+
+	doer := &recordingRequestDoer{next: server.Client()}
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(),
+		uma.WithInterceptors(namedInterceptor("outer"), authInterceptor),
+		uma.WithInterceptors(namedInterceptor("inner")),
+		uma.WithRequestDoer(doer),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"outer request", "inner request", "inner response", "outer response"}, calls)
+	require.Len(t, doer.requests, 1)
+
+	faultInjector := func(uma.RequestDoer) uma.RequestDoer {
+		return uma.RequestDoerFunc(func(*http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		})
+	}
+	_, err = uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithInterceptors(faultInjector))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,102 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestHttpMessageSignatureHandler(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	var signedBy []string
+	var bodies []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		signedBy = append(signedBy, uma.HttpSignatureVaspDomain(r.Context()))
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(uma.NewHttpMessageSignatureHandler(next, uma.NewInMemoryPublicKeyCache(), getNonceCache()))
+	defer server.Close()
+
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusPaid,
+		VaspDomain:     sender.Domain,
+	}, sender.Signer)
+	require.NoError(t, err)
+	err = uma.SendPaymentStatusWebhook(context.Background(), server.URL, *webhook,
+		uma.WithInterceptors(uma.HttpMessageSigningInterceptor(sender.Domain, sender.Signer)))
+	require.NoError(t, err)
+	require.Equal(t, []string{sender.Domain}, signedBy)
+	require.Contains(t, bodies[0], umatest.FakeInvoice)
+
+	// Unsigned requests are rejected.
+	err = uma.SendPaymentStatusWebhook(context.Background(), server.URL, *webhook)
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, http.StatusUnauthorized, invalidResponseError.StatusCode)
+	require.Len(t, signedBy, 1)
+}
+
+func TestVerifyHttpRequestSignatureRejectsTampering(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	server := httptest.NewServer(uma.NewHttpMessageSignatureHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }),
+		uma.NewInMemoryPublicKeyCache(),
+		getNonceCache(),
+	))
+	defer server.Close()
+
+	newSignedRequest := func(path string, body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		require.NoError(t, uma.SignHttpRequest(req, sender.Domain, sender.Signer))
+		require.Contains(t, req.Header.Get("Signature-Input"), `keyid="`+sender.Domain+`"`)
+		return req
+	}
+	send := func(req *http.Request) int {
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		return response.StatusCode
+	}
+
+	req := newSignedRequest("/callback", `{"amount":1}`)
+	require.Equal(t, http.StatusOK, send(req))
+	// Replays are rejected by the nonce cache.
+	req.Body, _ = req.GetBody()
+	require.Equal(t, http.StatusUnauthorized, send(req))
+
+	req = newSignedRequest("/callback", `{"amount":1}`)
+	req.Body = io.NopCloser(bytes.NewReader([]byte(`{"amount":2}`)))
+	req.ContentLength = int64(len(`{"amount":2}`))
+	require.Equal(t, http.StatusUnauthorized, send(req))
+
+	req = newSignedRequest("/callback", `{"amount":1}`)
+	req.URL.Path = "/other"
+	require.Equal(t, http.StatusUnauthorized, send(req))
+
+	req = newSignedRequest("/callback", `{"amount":1}`)
+	req.Header.Set("Content-Type", "text/plain")
+	require.Equal(t, http.StatusUnauthorized, send(req))
+
+	_, otherSigner := createSigner(t)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/callback", nil)
+	require.NoError(t, err)
+	require.NoError(t, uma.SignHttpRequest(req, sender.Domain, otherSigner))
+	require.Equal(t, http.StatusUnauthorized, send(req))
+}
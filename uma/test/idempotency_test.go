@@ -0,0 +1,88 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestProcessIdempotently(t *testing.T) {
+	ctx := context.Background()
+	store := uma.NewInMemoryIdempotencyStore()
+	calls := 0
+	process := func() ([]byte, error) {
+		calls++
+		return []byte(`{"pr":"lnbc1"}`), nil
+	}
+	key := uma.GenerateIdempotencyKey()
+	require.NotEqual(t, key, uma.GenerateIdempotencyKey())
+
+	response, err := uma.ProcessIdempotently(ctx, store, &key, process)
+	require.NoError(t, err)
+	duplicateResponse, err := uma.ProcessIdempotently(ctx, store, &key, process)
+	require.NoError(t, err)
+	require.Equal(t, response, duplicateResponse)
+	require.Equal(t, 1, calls)
+
+	// Requests without a key are always processed.
+	_, err = uma.ProcessIdempotently(ctx, store, nil, process)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// Failed requests release their key so that they can be retried.
+	failingKey := uma.GenerateIdempotencyKey()
+	_, err = uma.ProcessIdempotently(ctx, store, &failingKey, func() ([]byte, error) {
+		return nil, errors.New("node unavailable")
+	})
+	require.ErrorContains(t, err, "node unavailable")
+	_, err = uma.ProcessIdempotently(ctx, store, &failingKey, process)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	inProgressKey := uma.GenerateIdempotencyKey()
+	claimed, _, err := store.Claim(ctx, inProgressKey)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	_, err = uma.ProcessIdempotently(ctx, store, &inProgressKey, process)
+	require.ErrorIs(t, err, uma.ErrIdempotentRequestInProgress)
+
+	store.PurgeKeysOlderThan(time.Now().Add(time.Second))
+	_, err = uma.ProcessIdempotently(ctx, store, &key, process)
+	require.NoError(t, err)
+	require.Equal(t, 4, calls)
+}
+
+func TestIdempotencyKeySerialization(t *testing.T) {
+	key := uma.GenerateIdempotencyKey()
+	receivingCurrencyCode := "USD"
+	payreq := umaprotocol.PayRequest{
+		ReceivingCurrencyCode: &receivingCurrencyCode,
+		Amount:                1000,
+		IdempotencyKey:        &key,
+		UmaMajorVersion:       1,
+	}
+	payreqJson, err := payreq.MarshalJSON()
+	require.NoError(t, err)
+	parsedPayreq, err := uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	require.Equal(t, key, *parsedPayreq.IdempotencyKey)
+
+	params, err := payreq.EncodeAsUrlParams()
+	require.NoError(t, err)
+	parsedPayreq, err = umaprotocol.ParsePayRequestFromQueryParams(*params)
+	require.NoError(t, err)
+	require.Equal(t, key, *parsedPayreq.IdempotencyKey)
+
+	callback := umaprotocol.PostTransactionCallback{IdempotencyKey: &key}
+	callbackJson, err := json.Marshal(callback)
+	require.NoError(t, err)
+	parsedCallback, err := uma.ParsePostTransactionCallback(callbackJson)
+	require.NoError(t, err)
+	require.Equal(t, key, *parsedCallback.IdempotencyKey)
+}
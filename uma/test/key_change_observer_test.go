@@ -0,0 +1,48 @@
+package uma_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+func TestKeyChangeObserver(t *testing.T) {
+	oldPrivateKey, _ := createSigner(t)
+	newPrivateKey, _ := createSigner(t)
+	publishedKeys := getPubKeyResponse(newPrivateKey)
+	domain := servePubKeyResponse(t, &publishedKeys)
+
+	var changes []uma.KeyChange
+	observer := uma.WithKeyChangeObserver(func(_ context.Context, change uma.KeyChange) {
+		changes = append(changes, change)
+	})
+	expiredAt := time.Now().Add(-time.Minute).Unix()
+	cachedKeys := getPubKeyResponse(oldPrivateKey)
+	cachedKeys.ExpirationTimestamp = &expiredAt
+	cache := uma.NewInMemoryPublicKeyCache()
+	cache.AddPublicKeyForVasp(domain, &cachedKeys)
+
+	fetched, err := uma.FetchPublicKeyForVasp(domain, cache, observer)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, domain, changes[0].VaspDomain)
+	require.Equal(t, &cachedKeys, changes[0].Previous)
+	require.Equal(t, fetched, changes[0].Current)
+	require.Equal(t, "http://"+domain+"/.well-known/lnurlpubkey", changes[0].Url)
+	require.WithinDuration(t, time.Now(), changes[0].FetchedAt, time.Minute)
+
+	// Fetching the same keys again after they expired is not a change.
+	fetched.ExpirationTimestamp = &expiredAt
+	_, err = uma.FetchPublicKeyForVasp(domain, cache, observer)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	// Nor is the first fetch of a VASP's keys.
+	cache.Clear()
+	_, err = uma.FetchPublicKeyForVasp(domain, cache, observer)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+}
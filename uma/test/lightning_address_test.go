@@ -0,0 +1,49 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+func TestFetchLnurlpForLightningAddress(t *testing.T) {
+	var requestUrls []string
+	tag := "payRequest"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestUrls = append(requestUrls, r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"tag":         tag,
+			"callback":    "https://wallet.com/lnurl/callback/carol",
+			"minSendable": 1_000,
+			"maxSendable": 10_000_000,
+			"metadata":    lnurlMetadata,
+		}))
+	}))
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	response, err := uma.FetchLnurlpForLightningAddress("lightning:carol@" + serverUrl.Host)
+	require.NoError(t, err)
+	require.Nil(t, response.AsUmaResponse())
+	require.Equal(t, "https://wallet.com/lnurl/callback/carol", response.Callback)
+	require.Equal(t, int64(10_000_000), response.MaxSendable)
+	require.Equal(t, []string{"/.well-known/lnurlp/carol"}, requestUrls)
+
+	tag = "withdrawRequest"
+	_, err = uma.FetchLnurlpForLightningAddressWithContext(context.Background(), "carol@"+serverUrl.Host)
+	require.ErrorContains(t, err, "unexpected lnurl tag")
+
+	_, err = uma.FetchLnurlpForLightningAddress("carol")
+	require.Error(t, err)
+	_, err = uma.FetchLnurlpForLightningAddress("car/ol@" + serverUrl.Host)
+	require.Error(t, err)
+	require.Len(t, requestUrls, 2)
+}
@@ -0,0 +1,216 @@
+package uma_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/lightning"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestLightsparkInvoiceCreator(t *testing.T) {
+	metadata := "[[\"text/plain\",\"Pay to bob\"]]"
+	metadataHash := sha256.Sum256([]byte(metadata))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "client", username)
+		require.Equal(t, "secret", password)
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "node1", body.Variables["node_id"])
+		require.Equal(t, float64(1000), body.Variables["amount_msats"])
+		require.Equal(t, hex.EncodeToString(metadataHash[:]), body.Variables["metadata_hash"])
+		require.Equal(t, float64(300), body.Variables["expiry_secs"])
+		_, _ = w.Write([]byte(`{"data":{"create_uma_invoice":{"invoice":{"data":{"encoded_payment_request":"lnbc1lightspark"}}}}}`))
+	}))
+	defer server.Close()
+
+	creator := lightning.NewLightsparkInvoiceCreator("client", "secret", "node1")
+	creator.ApiEndpoint = server.URL
+	var invoiceCreator uma.ExpiringInvoiceCreator = creator
+	expirySecs := int64(300)
+	invoice, err := invoiceCreator.CreateInvoiceWithExpiry(1000, metadata, nil, &expirySecs)
+	require.NoError(t, err)
+	require.Equal(t, "lnbc1lightspark", *invoice)
+}
+
+func TestLightsparkInvoiceCreatorGraphqlError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"create_uma_invoice":null},"errors":[{"message":"node not found"}]}`))
+	}))
+	defer server.Close()
+
+	creator := lightning.NewLightsparkInvoiceCreator("client", "secret", "node1")
+	creator.ApiEndpoint = server.URL
+	_, err := creator.CreateInvoice(1000, "[]", nil)
+	require.ErrorContains(t, err, "node not found")
+}
+
+func TestLndInvoiceCreator(t *testing.T) {
+	metadata := "[[\"text/plain\",\"Pay to bob\"]]"
+	metadataHash := sha256.Sum256([]byte(metadata))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/invoices", r.URL.Path)
+		require.Equal(t, "abcdef", r.Header.Get("Grpc-Metadata-macaroon"))
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "1000", body["value_msat"])
+		require.Equal(t, base64.StdEncoding.EncodeToString(metadataHash[:]), body["description_hash"])
+		require.Equal(t, "600", body["expiry"])
+		_, _ = w.Write([]byte(`{"r_hash":"aGFzaA==","payment_request":"lnbc1lnd","add_index":"1"}`))
+	}))
+	defer server.Close()
+
+	creator := lightning.NewLndInvoiceCreator(server.URL+"/", "abcdef", nil)
+	expirySecs := int64(600)
+	creator.DefaultExpirySecs = &expirySecs
+	invoice, err := creator.CreateInvoice(1000, metadata, nil)
+	require.NoError(t, err)
+	require.Equal(t, "lnbc1lnd", *invoice)
+}
+
+func TestClnRestInvoiceCreator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/invoice", r.URL.Path)
+		require.Equal(t, "rune123", r.Header.Get("Rune"))
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, float64(1000), body["amount_msat"])
+		require.Equal(t, true, body["deschashonly"])
+		require.Equal(t, "[]", body["description"])
+		require.NotEmpty(t, body["label"])
+		_, _ = w.Write([]byte(`{"bolt11":"lnbc1clnrest"}`))
+	}))
+	defer server.Close()
+
+	invoice, err := lightning.NewClnRestInvoiceCreator(server.URL, "rune123", nil).CreateInvoice(1000, "[]", nil)
+	require.NoError(t, err)
+	require.Equal(t, "lnbc1clnrest", *invoice)
+}
+
+func TestClnRpcInvoiceCreator(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "lightning-rpc")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var request struct {
+				Id     int64                  `json:"id"`
+				Method string                 `json:"method"`
+				Params map[string]interface{} `json:"params"`
+			}
+			_ = json.NewDecoder(conn).Decode(&request)
+			if request.Params["expiry"] == float64(60) {
+				_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+					"jsonrpc": "2.0",
+					"id":      request.Id,
+					"result":  map[string]string{"bolt11": "lnbc1clnrpc"},
+				})
+			} else {
+				_ = json.NewEncoder(conn).Encode(map[string]interface{}{
+					"jsonrpc": "2.0",
+					"id":      request.Id,
+					"error":   map[string]interface{}{"code": 900, "message": "bad expiry"},
+				})
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	creator := lightning.NewClnRpcInvoiceCreator(socketPath)
+	expirySecs := int64(60)
+	invoice, err := creator.CreateInvoiceWithExpiry(1000, "[]", nil, &expirySecs)
+	require.NoError(t, err)
+	require.Equal(t, "lnbc1clnrpc", *invoice)
+
+	_, err = creator.CreateInvoice(1000, "[]", nil)
+	require.ErrorContains(t, err, "bad expiry")
+}
+
+func TestLndUtxoProvider(t *testing.T) {
+	paymentHash := "0102"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "abcdef", r.Header.Get("Grpc-Metadata-macaroon"))
+		switch r.URL.Path {
+		case "/v1/channels":
+			if r.URL.Query().Get("active_only") == "true" {
+				_, _ = w.Write([]byte(`{"channels":[{"chan_id":"1","channel_point":"txid1:0"}]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"channels":[{"chan_id":"1","channel_point":"txid1:0"},{"chan_id":"2","channel_point":"txid2:1"}]}`))
+			}
+		case "/v1/invoice/" + paymentHash:
+			_, _ = w.Write([]byte(`{"htlcs":[{"chan_id":"2","amt_msat":"5000","state":"SETTLED"},{"chan_id":"1","amt_msat":"7000","state":"CANCELED"}]}`))
+		case "/v2/router/track/AQI=":
+			_, _ = w.Write([]byte(`{"result":{"status":"SUCCEEDED","htlcs":[{"status":"SUCCEEDED","route":{"total_amt_msat":"3000","hops":[{"chan_id":"1"}]}}]}}` + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := lightning.NewLndUtxoProvider(server.URL, "abcdef", nil)
+	channelUtxos, err := provider.GetChannelUtxos(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"txid1:0"}, channelUtxos)
+
+	incomingUtxos, err := provider.GetPaymentUtxos(context.Background(), paymentHash, uma.PaymentDirectionIncoming)
+	require.NoError(t, err)
+	require.Equal(t, []umaprotocol.UtxoWithAmount{{Utxo: "txid2:1", Amount: 5000}}, incomingUtxos)
+
+	outgoingUtxos, err := provider.GetPaymentUtxos(context.Background(), paymentHash, uma.PaymentDirectionOutgoing)
+	require.NoError(t, err)
+	require.Equal(t, []umaprotocol.UtxoWithAmount{{Utxo: "txid1:0", Amount: 3000}}, outgoingUtxos)
+}
+
+func TestLightsparkUtxoProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.OperationName {
+		case "NodeUmaPrescreeningUtxos":
+			require.Equal(t, "node1", body.Variables["node_id"])
+			_, _ = w.Write([]byte(`{"data":{"entity":{"uma_prescreening_utxos":["txid1:0","txid2:1"]}}}`))
+		case "IncomingPaymentsForPaymentHash":
+			require.Equal(t, "abcd", body.Variables["payment_hash"])
+			_, _ = w.Write([]byte(`{"data":{"incoming_payments_for_payment_hash":{"payments":[{"uma_post_transaction_data":[
+				{"utxo":"txid1:0","amount":{"original_value":12,"original_unit":"SATOSHI"}}]}]}}}`))
+		default:
+			_, _ = w.Write([]byte(`{"data":{"outgoing_payments_for_payment_hash":{"payments":[]}}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := lightning.NewLightsparkUtxoProvider("client", "secret", "node1")
+	provider.ApiEndpoint = server.URL
+	channelUtxos, err := provider.GetChannelUtxos(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"txid1:0", "txid2:1"}, channelUtxos)
+
+	paymentUtxos, err := provider.GetPaymentUtxos(context.Background(), "abcd", uma.PaymentDirectionIncoming)
+	require.NoError(t, err)
+	require.Equal(t, []umaprotocol.UtxoWithAmount{{Utxo: "txid1:0", Amount: 12_000}}, paymentUtxos)
+
+	_, err = provider.GetPaymentUtxos(context.Background(), "abcd", uma.PaymentDirectionOutgoing)
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package uma_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+// These are the example URL and LNURL from LUD-01.
+const lud01Url = "https://service.com/api?q=3fc3645b439ce8e7f2553a69e5267081d96dcd340693afabe04be7b0ccd178df"
+const lud01Lnurl = "LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS"
+
+func TestEncodeLnurl(t *testing.T) {
+	lnurl, err := utils.EncodeLnurl(lud01Url)
+	require.NoError(t, err)
+	require.Equal(t, lud01Lnurl, lnurl)
+
+	_, err = utils.EncodeLnurl("http://service.com/api")
+	require.Error(t, err)
+	_, err = utils.EncodeLnurl("/api")
+	require.Error(t, err)
+	_, err = utils.EncodeLnurl("http://service.onion/api")
+	require.NoError(t, err)
+}
+
+func TestDecodeLnurl(t *testing.T) {
+	for _, lnurl := range []string{
+		lud01Lnurl,
+		"lightning:" + lud01Lnurl,
+		"LIGHTNING:" + lud01Lnurl,
+		"https://service.com/giftcard?lightning=" + lud01Lnurl,
+	} {
+		decoded, err := utils.DecodeLnurl(lnurl)
+		require.NoError(t, err, lnurl)
+		require.Equal(t, lud01Url, decoded.String())
+	}
+	decoded, err := utils.DecodeLnurl(strings.ToLower(lud01Lnurl))
+	require.NoError(t, err)
+	require.Equal(t, lud01Url, decoded.String())
+
+	_, err = utils.DecodeLnurl(lud01Lnurl[:len(lud01Lnurl)-1] + "Q")
+	require.Error(t, err)
+	_, err = utils.DecodeLnurl(bolt11ExpiringInvoice)
+	require.Error(t, err)
+}
+
+func TestLnurlpRequestEncodeToLnurl(t *testing.T) {
+	request := umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@vasp2.com"}
+	lnurl, err := request.EncodeToLnurl()
+	require.NoError(t, err)
+	decoded, err := utils.DecodeLnurl(lnurl)
+	require.NoError(t, err)
+	parsed, err := uma.ParseLnurlpRequest(*decoded)
+	require.NoError(t, err)
+	require.Equal(t, "$bob@vasp2.com", parsed.ReceiverAddress)
+}
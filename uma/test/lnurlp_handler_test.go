@@ -0,0 +1,102 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func newTestLnurlpRequestHandler(t *testing.T, senderPrivateKey *secp256k1.PrivateKey, opts ...uma.Option) *uma.LnurlpRequestHandler {
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	_, receiverSigner := createSigner(t)
+	return uma.NewLnurlpRequestHandler(pubKeyCache, getNonceCache(),
+		func(_ context.Context, request *umaprotocol.LnurlpRequest) (*umaprotocol.LnurlpResponse, error) {
+			metadata, err := createMetadataForBob()
+			require.NoError(t, err)
+			return uma.GetLnurlpResponseWithSigner(
+				*request,
+				"https://vasp2.com/api/lnurl/payreq/$bob",
+				metadata,
+				[]umaprotocol.Currency{{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 34_150, Decimals: 2}},
+				umaprotocol.CounterPartyDataOptions{"compliance": {Mandatory: true}},
+				umaprotocol.KycStatusVerified,
+				receiverSigner,
+				true,
+				nil,
+				nil,
+			)
+		}, opts...)
+}
+
+func serveLnurlpRequest(t *testing.T, handler http.Handler, privateKey *secp256k1.PrivateKey) *httptest.ResponseRecorder {
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, queryUrl.String(), nil))
+	return recorder
+}
+
+func TestLnurlpRequestHandler(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	handler := newTestLnurlpRequestHandler(t, senderPrivateKey)
+
+	recorder := serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	response, err := uma.ParseLnurlpResponse(recorder.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, "$bob@vasp2.com", response.Compliance.ReceiverIdentifier)
+
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	recorder = serveLnurlpRequest(t, handler, otherPrivateKey)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestLnurlpRequestHooks(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	signatureVerifier := &countingSignatureVerifier{}
+	var infos []uma.LnurlpRequestInfo
+	observe := uma.WithLnurlpRequestHook(func(_ context.Context, info uma.LnurlpRequestInfo) error {
+		infos = append(infos, info)
+		return nil
+	})
+	denylist := uma.WithLnurlpRequestHook(func(_ context.Context, info uma.LnurlpRequestInfo) error {
+		if info.VaspDomain == "vasp1.com" {
+			return errors.New("sender is denylisted")
+		}
+		return nil
+	})
+	handler := newTestLnurlpRequestHandler(t, senderPrivateKey,
+		uma.WithSignatureVerifier(signatureVerifier), observe, denylist)
+
+	recorder := serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	var status map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	require.Equal(t, "sender is denylisted", status["reason"])
+	require.Equal(t, []uma.LnurlpRequestInfo{{
+		VaspDomain:      "vasp1.com",
+		ReceiverAddress: "$bob@vasp2.com",
+		RemoteIp:        "192.0.2.1",
+	}}, infos)
+	// The request was rejected before its signature was verified.
+	require.Zero(t, signatureVerifier.calls)
+
+	throttle := uma.WithLnurlpRequestHook(func(context.Context, uma.LnurlpRequestInfo) error {
+		return uma.RequestRejectedError{StatusCode: http.StatusTooManyRequests, Reason: "slow down"}
+	})
+	recorder = serveLnurlpRequest(t, newTestLnurlpRequestHandler(t, senderPrivateKey, throttle), senderPrivateKey)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
@@ -0,0 +1,96 @@
+package uma_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func newJsonLogger() (*slog.Logger, *bytes.Buffer) {
+	var buffer bytes.Buffer
+	return slog.New(slog.NewJSONHandler(&buffer, nil)), &buffer
+}
+
+func readLogEvents(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestLoggerReceivesVerificationEvents(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	logger, buffer := newJsonLogger()
+	nonceCache := getNonceCache()
+	opts := []uma.Option{uma.WithLogger(logger), uma.WithCorrelationId("payment-1")}
+
+	err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), getPubKeyResponse(privateKey), nonceCache, opts...)
+	require.NoError(t, err)
+	err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), getPubKeyResponse(privateKey), nonceCache, opts...)
+	require.Error(t, err)
+
+	events := readLogEvents(t, buffer)
+	require.Len(t, events, 2)
+	require.Equal(t, uma.LogEventSignatureVerified, events[0]["msg"])
+	require.Equal(t, "lnurlp_request", events[0]["message_type"])
+	require.Equal(t, "vasp1.com", events[0]["counterparty"])
+	require.Equal(t, "payment-1", events[0]["correlation_id"])
+	require.Equal(t, uma.LogEventNonceRejected, events[1]["msg"])
+	require.Equal(t, "WARN", events[1]["level"])
+}
+
+func TestLoggerReceivesVersionNegotiation(t *testing.T) {
+	_, senderSigner := createSigner(t)
+	_, receiverSigner := createSigner(t)
+	vaspDomain := "vasp1.com"
+	umaVersion := "0.3"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+		UmaVersion:      &umaVersion,
+	}, senderSigner)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	logger, buffer := newJsonLogger()
+
+	response, err := uma.GetLnurlpResponseWithSigner(
+		*request,
+		"https://vasp2.com/api/lnurl/payreq/$bob",
+		metadata,
+		[]umaprotocol.Currency{{Code: "SAT", MillisatoshiPerUnit: 1000, Convertible: umaprotocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10}}},
+		umaprotocol.CounterPartyDataOptions{},
+		umaprotocol.KycStatusVerified,
+		receiverSigner,
+		false,
+		nil,
+		nil,
+		uma.WithLogger(logger),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "0.3", *response.UmaVersion)
+
+	events := readLogEvents(t, buffer)
+	require.Len(t, events, 1)
+	require.Equal(t, uma.LogEventVersionNegotiated, events[0]["msg"])
+	require.Equal(t, "0.3", events[0]["requested_version"])
+	require.Equal(t, "0.3", events[0]["version"])
+}
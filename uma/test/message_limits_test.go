@@ -0,0 +1,72 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func requireMessageLimitError(t *testing.T, err error, field string, limit int) {
+	var limitErr uma.MessageLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, field, limitErr.Field)
+	require.Equal(t, limit, limitErr.Limit)
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	callback, _ := createSignedCallback(t)
+	callbackJson, err := json.Marshal(callback)
+	require.NoError(t, err)
+	_, err = uma.ParsePostTransactionCallback(callbackJson)
+	require.NoError(t, err)
+
+	_, err = uma.ParsePostTransactionCallback(callbackJson, uma.WithMessageLimits(uma.MessageLimits{MaxMessageBytes: 10}))
+	requireMessageLimitError(t, err, "", 10)
+
+	padding := strings.Repeat(" ", uma.DefaultMessageLimits.MaxMessageBytes)
+	_, err = uma.ParsePostTransactionCallback(append(callbackJson, padding...))
+	requireMessageLimitError(t, err, "", uma.DefaultMessageLimits.MaxMessageBytes)
+}
+
+func TestFieldLengthLimits(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payreq := createSignedPayRequest(t, privateKey)
+	comment := strings.Repeat("é", 11)
+	payreq.Comment = &comment
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	_, err = uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	// Comments are limited in characters rather than bytes.
+	_, err = uma.ParsePayRequest(payreqJson, uma.WithMessageLimits(uma.MessageLimits{MaxCommentLength: 11}))
+	require.NoError(t, err)
+	_, err = uma.ParsePayRequest(payreqJson, uma.WithMessageLimits(uma.MessageLimits{MaxCommentLength: 10}))
+	requireMessageLimitError(t, err, "comment", 10)
+	_, err = uma.ParsePayRequest(payreqJson, uma.WithMessageLimits(uma.MessageLimits{MaxCounterPartyDataBytes: 100}))
+	requireMessageLimitError(t, err, "payerData", 100)
+
+	callback, _ := createSignedCallback(t)
+	callback.Utxos = append(callback.Utxos, umaprotocol.UtxoWithAmount{Utxo: "fedcba54321", Amount: 2000})
+	callbackJson, err := json.Marshal(callback)
+	require.NoError(t, err)
+	_, err = uma.ParsePostTransactionCallback(callbackJson, uma.WithMessageLimits(uma.MessageLimits{MaxUtxos: 1}))
+	requireMessageLimitError(t, err, "utxos", 1)
+
+	lnurlpResponseJson, err := json.Marshal(umaprotocol.LnurlpResponse{
+		Tag:      "payRequest",
+		Callback: "https://vasp2.com/api/lnurl/payreq/$bob",
+		Currencies: &[]umaprotocol.Currency{
+			{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 34_150, Decimals: 2},
+			{Code: "EUR", Name: "Euro", Symbol: "€", MillisatoshiPerUnit: 37_000, Decimals: 2},
+		},
+	})
+	require.NoError(t, err)
+	_, err = uma.ParseLnurlpResponse(lnurlpResponseJson, uma.WithMessageLimits(uma.MessageLimits{MaxCurrencies: 1}))
+	requireMessageLimitError(t, err, "currencies", 1)
+}
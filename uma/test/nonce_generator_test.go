@@ -0,0 +1,84 @@
+package uma_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestNewNonceGenerator(t *testing.T) {
+	generator, err := uma.NewNonceGenerator(nil, 16, "0123456789abcdef")
+	require.NoError(t, err)
+	nonce, err := generator.GenerateNonce()
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile("^[0-9a-f]{16}$"), nonce)
+
+	otherNonce, err := generator.GenerateNonce()
+	require.NoError(t, err)
+	require.NotEqual(t, nonce, otherNonce)
+}
+
+func TestNewNonceGeneratorWithRandomSource(t *testing.T) {
+	newGenerator := func(random []byte) uma.NonceGenerator {
+		generator, err := uma.NewNonceGenerator(bytes.NewReader(random), 16, "0123456789abcdef")
+		require.NoError(t, err)
+		return generator
+	}
+	random := bytes.Repeat([]byte{0x2a}, 64)
+	nonce, err := newGenerator(random).GenerateNonce()
+	require.NoError(t, err)
+	otherNonce, err := newGenerator(random).GenerateNonce()
+	require.NoError(t, err)
+	require.Equal(t, nonce, otherNonce)
+
+	_, err = newGenerator(nil).GenerateNonce()
+	require.Error(t, err)
+}
+
+func TestNewNonceGeneratorRejectsWeakSettings(t *testing.T) {
+	_, err := uma.NewNonceGenerator(nil, 15, "0123456789abcdef")
+	require.ErrorContains(t, err, "bits of entropy")
+	_, err = uma.NewNonceGenerator(nil, 64, "a")
+	require.Error(t, err)
+	_, err = uma.NewNonceGenerator(nil, 64, "0123456789abcdef0")
+	require.ErrorContains(t, err, "duplicate nonce character")
+	_, err = uma.NewNonceGenerator(nil, 64, "0123456789abcdef|")
+	require.ErrorContains(t, err, "invalid nonce character")
+	_, err = uma.NewNonceGenerator(nil, 64, "0123456789abcdef ")
+	require.ErrorContains(t, err, "invalid nonce character")
+}
+
+func TestUuidV7NonceGenerator(t *testing.T) {
+	generator := uma.NewUuidV7NonceGenerator(nil)
+	nonce, err := generator.GenerateNonce()
+	require.NoError(t, err)
+	parsedNonce, err := uuid.Parse(nonce)
+	require.NoError(t, err)
+	require.Equal(t, uuid.Version(7), parsedNonce.Version())
+
+	otherNonce, err := generator.GenerateNonce()
+	require.NoError(t, err)
+	require.Less(t, nonce, otherNonce)
+}
+
+func TestSignWithNonceGenerator(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	generator := uma.NewUuidV7NonceGenerator(nil)
+	vaspDomain := "vasp1.com"
+	cancellation, err := uma.SignPayReqCancellation(umaprotocol.PayReqCancellation{
+		EncodedInvoice: umatest.FakeInvoice,
+		VaspDomain:     vaspDomain,
+	}, signer, uma.WithNonceGenerator(generator))
+	require.NoError(t, err)
+	_, err = uuid.Parse(cancellation.Nonce)
+	require.NoError(t, err)
+
+	err = uma.VerifyPayReqCancellationSignature(cancellation, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
@@ -0,0 +1,68 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/openapi"
+)
+
+// collectRefs returns the values of all $ref keywords in a decoded JSON document.
+func collectRefs(value interface{}) []string {
+	var refs []string
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				refs = append(refs, ref)
+			} else {
+				refs = append(refs, collectRefs(child)...)
+			}
+		}
+	case []interface{}:
+		for _, child := range typedValue {
+			refs = append(refs, collectRefs(child)...)
+		}
+	}
+	return refs
+}
+
+func TestGenerateOpenApi(t *testing.T) {
+	document, err := openapi.Generate(openapi.Config{
+		ServerUrls:     []string{"https://vasp2.com"},
+		PayRequestPath: "/uma/payreq/{accountId}",
+	})
+	require.NoError(t, err)
+	require.Equal(t, openapi.Version, document.OpenApi)
+	require.Equal(t, "UMA", document.Info.Title)
+	require.Contains(t, document.Paths, "/.well-known/lnurlp/{username}")
+	require.Contains(t, document.Paths, "/.well-known/lnurlpubkey")
+	require.Contains(t, document.Paths, openapi.DefaultPostTransactionCallbackPath)
+
+	payRequest := document.Paths["/uma/payreq/{accountId}"]
+	require.NotNil(t, payRequest)
+	require.Equal(t, "accountId", payRequest.Post.Parameters[0].Name)
+	require.Equal(t, "path", payRequest.Post.Parameters[0].In)
+	require.Equal(t, "#/components/schemas/PayRequest", payRequest.Post.RequestBody.Content["application/json"].Schema.Ref)
+	require.Equal(t, "#/components/schemas/PayReqResponse", payRequest.Post.Responses["200"].Content["application/json"].Schema.Ref)
+
+	documentJson, err := json.Marshal(document)
+	require.NoError(t, err)
+	var decodedDocument map[string]interface{}
+	require.NoError(t, json.Unmarshal(documentJson, &decodedDocument))
+	refs := collectRefs(decodedDocument)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		require.True(t, ok, ref)
+		require.Contains(t, document.Components.Schemas, name)
+	}
+	require.NotContains(t, string(documentJson), "$defs")
+}
+
+func TestGenerateOpenApiInvalidPath(t *testing.T) {
+	_, err := openapi.Generate(openapi.Config{PayRequestPath: "payreq"})
+	require.Error(t, err)
+}
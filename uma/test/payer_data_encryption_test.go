@@ -0,0 +1,58 @@
+package uma_test
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestEncryptPayerDataFields(t *testing.T) {
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPubKey := receiverEncryptionPrivateKey.PubKey().SerializeUncompressed()
+	address := map[string]interface{}{
+		"addressLines": []interface{}{"1 Rue de Rivoli"},
+		"city":         "Paris",
+		"countryCode":  "FR",
+	}
+	payerData := umaprotocol.PayerData{
+		"identifier":    "$alice@vasp1.com",
+		"name":          "Alice",
+		"email":         "alice@vasp1.com",
+		"postalAddress": address,
+		"compliance":    map[string]interface{}{"kycStatus": "VERIFIED"},
+	}
+
+	err = uma.EncryptPayerDataFields(payerData, receiverEncryptionPubKey,
+		umaprotocol.CounterPartyDataFieldName, umaprotocol.CounterPartyDataFieldEmail)
+	require.NoError(t, err)
+	err = uma.EncryptPayerDataFields(payerData, receiverEncryptionPubKey,
+		umaprotocol.CounterPartyDataFieldPostalAddress, umaprotocol.CounterPartyDataFieldCountryCode)
+	require.NoError(t, err)
+	require.Equal(t, "$alice@vasp1.com", *payerData.Identifier())
+	require.Nil(t, payerData.Name())
+	require.Nil(t, payerData.Email())
+	require.NotContains(t, payerData, "postalAddress")
+	require.NotContains(t, payerData, "countryCode")
+	require.Len(t, payerData["encryptedFields"], 3)
+
+	err = uma.EncryptPayerDataFields(payerData, receiverEncryptionPubKey, umaprotocol.CounterPartyDataFieldIdentifier)
+	require.ErrorContains(t, err, "identifier field cannot be encrypted")
+
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	require.Error(t, uma.DecryptPayerDataFields(payerData, otherPrivateKey.Serialize()))
+	require.Contains(t, payerData, "encryptedFields")
+
+	require.NoError(t, uma.DecryptPayerDataFields(payerData, receiverEncryptionPrivateKey.Serialize()))
+	require.Equal(t, umaprotocol.PayerData{
+		"identifier":    "$alice@vasp1.com",
+		"name":          "Alice",
+		"email":         "alice@vasp1.com",
+		"postalAddress": address,
+		"compliance":    map[string]interface{}{"kycStatus": "VERIFIED"},
+	}, payerData)
+}
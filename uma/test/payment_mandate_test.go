@@ -0,0 +1,117 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func createSignedPaymentMandate(t *testing.T, signer uma.UmaSigner) *umaprotocol.PaymentMandate {
+	mandate, err := uma.SignPaymentMandate(umaprotocol.PaymentMandate{
+		MandateId:           "mandate-1",
+		PayerIdentifier:     "$alice@vasp1.com",
+		PayeeIdentifier:     "$shop@vasp2.com",
+		CurrencyCode:        "USD",
+		MaxAmountPerPull:    1500,
+		IntervalSeconds:     30 * 24 * 60 * 60,
+		ExpirationTimestamp: time.Now().Add(365 * 24 * time.Hour).Unix(),
+		VaspDomain:          "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+	return mandate
+}
+
+func TestSignAndVerifyPaymentMandate(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	mandate := createSignedPaymentMandate(t, signer)
+	mandateJson, err := json.Marshal(mandate)
+	require.NoError(t, err)
+	parsedMandate, err := uma.ParsePaymentMandate(mandateJson)
+	require.NoError(t, err)
+	nonceCache := getNonceCache()
+	err = uma.VerifyPaymentMandateSignature(parsedMandate, getPubKeyResponse(privateKey), nonceCache)
+	require.NoError(t, err)
+	err = uma.VerifyPaymentMandateSignature(parsedMandate, getPubKeyResponse(privateKey), nonceCache)
+	require.Error(t, err, "replayed mandates must be rejected")
+
+	parsedMandate.MaxAmountPerPull = 150_000
+	err = uma.VerifyPaymentMandateSignature(parsedMandate, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+
+	_, err = uma.SignPaymentMandate(umaprotocol.PaymentMandate{
+		MandateId:        "mandate-2",
+		PayerIdentifier:  "$alice@vasp3.com",
+		PayeeIdentifier:  "$shop@vasp2.com",
+		CurrencyCode:     "USD",
+		MaxAmountPerPull: 1500,
+		IntervalSeconds:  60,
+		VaspDomain:       "vasp1.com",
+	}, signer)
+	require.ErrorAs(t, err, &uma.PayerIdentifierDomainMismatchError{})
+}
+
+func TestPaymentPullRequest(t *testing.T) {
+	_, senderSigner := createSigner(t)
+	mandate := createSignedPaymentMandate(t, senderSigner)
+	receiverPrivateKey, receiverSigner := createSigner(t)
+	request, err := uma.SignPaymentPullRequest(umaprotocol.PaymentPullRequest{
+		MandateId:      mandate.MandateId,
+		PullId:         "pull-1",
+		Amount:         1000,
+		EncodedInvoice: umatest.FakeInvoice,
+		VaspDomain:     "vasp2.com",
+	}, receiverSigner)
+	require.NoError(t, err)
+	requestJson, err := json.Marshal(request)
+	require.NoError(t, err)
+	parsedRequest, err := uma.ParsePaymentPullRequest(requestJson)
+	require.NoError(t, err)
+	err = uma.VerifyPaymentPullRequestSignature(parsedRequest, getPubKeyResponse(receiverPrivateKey), getNonceCache())
+	require.NoError(t, err)
+
+	require.NoError(t, uma.ValidatePaymentPull(*mandate, *parsedRequest, nil))
+	lastPullAt := time.Now().Add(-31 * 24 * time.Hour)
+	require.NoError(t, uma.ValidatePaymentPull(*mandate, *parsedRequest, &lastPullAt))
+	lastPullAt = time.Now().Add(-24 * time.Hour)
+	require.ErrorContains(t, uma.ValidatePaymentPull(*mandate, *parsedRequest, &lastPullAt), "next pull")
+
+	tooLarge := *parsedRequest
+	tooLarge.Amount = 2000
+	require.Error(t, uma.ValidatePaymentPull(*mandate, tooLarge, nil))
+	otherVasp := *parsedRequest
+	otherVasp.VaspDomain = "vasp3.com"
+	require.Error(t, uma.ValidatePaymentPull(*mandate, otherVasp, nil))
+	expiredMandate := *mandate
+	expiredMandate.ExpirationTimestamp = time.Now().Add(-time.Hour).Unix()
+	require.ErrorContains(t, uma.ValidatePaymentPull(expiredMandate, *parsedRequest, nil), "expired")
+}
+
+func TestPaymentMandateRevocation(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	reason := "subscription cancelled"
+	revocation, err := uma.SignPaymentMandateRevocation(umaprotocol.PaymentMandateRevocation{
+		MandateId:  "mandate-1",
+		Reason:     &reason,
+		VaspDomain: "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+
+	encoded, err := uma.EncodeEnvelope(revocation)
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePaymentMandateRevocation, envelope.Type)
+	parsedRevocation := envelope.Message.(*umaprotocol.PaymentMandateRevocation)
+	require.Equal(t, reason, *parsedRevocation.Reason)
+	err = uma.VerifyPaymentMandateRevocationSignature(parsedRevocation, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	parsedRevocation.MandateId = "mandate-2"
+	err = uma.VerifyPaymentMandateRevocationSignature(parsedRevocation, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+}
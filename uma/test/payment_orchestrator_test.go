@@ -0,0 +1,198 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// serveUmaReceiver serves the lnurlp and payreq endpoints of a receiving VASP, and returns the signed lnurlp request
+// to send to it and the pay request to send in the payment.
+func serveUmaReceiver(
+	t *testing.T,
+	payreqHandler http.HandlerFunc,
+) (*umaprotocol.LnurlpRequest, *umaprotocol.PayRequest) {
+	payreq, payreqResponse, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	_, senderSigner := createSigner(t)
+	_, receiverSigner := createSigner(t)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	var lnurlpRequest *umaprotocol.LnurlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/payreq" {
+			if payreqHandler != nil {
+				payreqHandler(w, r)
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(payreqResponse))
+			return
+		}
+		lnurlpResponse, err := uma.GetLnurlpResponseWithSigner(
+			*lnurlpRequest,
+			"http://"+r.Host+"/payreq",
+			metadata,
+			[]umaprotocol.Currency{{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}},
+			umaprotocol.CounterPartyDataOptions{"compliance": {Mandatory: true}},
+			umaprotocol.KycStatusVerified,
+			receiverSigner,
+			true,
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(w).Encode(lnurlpResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	vaspDomain := "vasp1.com"
+	lnurlpRequest, err = uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+		VaspDomain:      &vaspDomain,
+	}, senderSigner)
+	require.NoError(t, err)
+	return lnurlpRequest, payreq
+}
+
+func newSendUmaPaymentParams(
+	lnurlpRequest *umaprotocol.LnurlpRequest,
+	payreq *umaprotocol.PayRequest,
+	cancelled *[]string,
+) uma.SendUmaPaymentParams {
+	return uma.SendUmaPaymentParams{
+		LnurlpRequest: *lnurlpRequest,
+		VerifyLnurlpResponse: func(context.Context, umaprotocol.UmaLnurlpResponse) error {
+			return nil
+		},
+		CreatePayRequest: func(context.Context, umaprotocol.UmaLnurlpResponse) (*umaprotocol.PayRequest, error) {
+			return payreq, nil
+		},
+		VerifyPayReqResponse: func(context.Context, *umaprotocol.PayRequest, *umaprotocol.PayReqResponse) error {
+			return nil
+		},
+		PayInvoice: func(context.Context, *umaprotocol.PayReqResponse) (string, error) {
+			return "paymenthash", nil
+		},
+		CancelPayRequest: func(_ context.Context, _ *umaprotocol.PayRequest, response *umaprotocol.PayReqResponse) error {
+			*cancelled = append(*cancelled, response.EncodedInvoice)
+			return nil
+		},
+	}
+}
+
+func TestSendUmaPayment(t *testing.T) {
+	lnurlpRequest, payreq := serveUmaReceiver(t, nil)
+	var cancelled []string
+	params := newSendUmaPaymentParams(lnurlpRequest, payreq, &cancelled)
+	var postTransactionHash string
+	params.SendPostTransactionCallback = func(_ context.Context, _ *umaprotocol.PayReqResponse, paymentHash string) error {
+		postTransactionHash = paymentHash
+		return nil
+	}
+
+	result, err := uma.SendUmaPayment(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, lnurlpRequest.ReceiverAddress, result.LnurlpResponse.Compliance.ReceiverIdentifier)
+	require.Equal(t, payreq, result.PayRequest)
+	require.Equal(t, "paymenthash", result.PaymentHash)
+	require.Equal(t, "paymenthash", postTransactionHash)
+	require.Empty(t, cancelled)
+}
+
+func TestSendUmaPaymentCompensatesFailedPayment(t *testing.T) {
+	lnurlpRequest, payreq := serveUmaReceiver(t, nil)
+	var cancelled []string
+	params := newSendUmaPaymentParams(lnurlpRequest, payreq, &cancelled)
+	payErr := errors.New("no route")
+	params.PayInvoice = func(context.Context, *umaprotocol.PayReqResponse) (string, error) {
+		return "", payErr
+	}
+
+	_, err := uma.SendUmaPayment(context.Background(), params)
+	var stageErr *uma.PaymentStageError
+	require.ErrorAs(t, err, &stageErr)
+	require.Equal(t, uma.PaymentStagePay, stageErr.Stage)
+	require.ErrorIs(t, err, payErr)
+	require.NoError(t, stageErr.CompensationErr)
+	require.Len(t, cancelled, 1)
+}
+
+func TestSendUmaPaymentDoesNotCompensateBeforeInvoice(t *testing.T) {
+	lnurlpRequest, payreq := serveUmaReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	var cancelled []string
+	params := newSendUmaPaymentParams(lnurlpRequest, payreq, &cancelled)
+
+	_, err := uma.SendUmaPayment(context.Background(), params)
+	var stageErr *uma.PaymentStageError
+	require.ErrorAs(t, err, &stageErr)
+	require.Equal(t, uma.PaymentStagePayRequest, stageErr.Stage)
+	require.Empty(t, cancelled)
+}
+
+func TestSendUmaPaymentDeadline(t *testing.T) {
+	lnurlpRequest, payreq := serveUmaReceiver(t, nil)
+	var cancelled []string
+	params := newSendUmaPaymentParams(lnurlpRequest, payreq, &cancelled)
+	params.Timeout = 50 * time.Millisecond
+	params.PayInvoice = func(ctx context.Context, _ *umaprotocol.PayReqResponse) (string, error) {
+		<-ctx.Done()
+		return "", errors.New("payment abandoned")
+	}
+	var compensationCtxErr error
+	params.CancelPayRequest = func(ctx context.Context, _ *umaprotocol.PayRequest, _ *umaprotocol.PayReqResponse) error {
+		compensationCtxErr = ctx.Err()
+		return nil
+	}
+
+	_, err := uma.SendUmaPayment(context.Background(), params)
+	var stageErr *uma.PaymentStageError
+	require.ErrorAs(t, err, &stageErr)
+	require.Equal(t, uma.PaymentStagePay, stageErr.Stage)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, compensationCtxErr)
+}
+
+func TestSendUmaPaymentCorrelationId(t *testing.T) {
+	var receivedCorrelationIds []string
+	lnurlpRequest, payreq := serveUmaReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		receivedCorrelationIds = append(receivedCorrelationIds, r.Header.Get(uma.CorrelationIdHeader))
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	var cancelled []string
+	params := newSendUmaPaymentParams(lnurlpRequest, payreq, &cancelled)
+	var hookCorrelationId string
+	params.VerifyLnurlpResponse = func(ctx context.Context, _ umaprotocol.UmaLnurlpResponse) error {
+		hookCorrelationId = uma.CorrelationIdFromContext(ctx)
+		return nil
+	}
+
+	// A correlation ID is generated for the payment, and sent with each request.
+	sink := &recordingEventSink{}
+	_, err := uma.SendUmaPayment(context.Background(), params, uma.WithEventSink(sink))
+	var stageErr *uma.PaymentStageError
+	require.ErrorAs(t, err, &stageErr)
+	require.NotEmpty(t, stageErr.CorrelationId)
+	require.Equal(t, []string{stageErr.CorrelationId}, receivedCorrelationIds)
+	require.Equal(t, stageErr.CorrelationId, hookCorrelationId)
+	require.Equal(t, stageErr.CorrelationId, sink.events[0].Metadata().CorrelationId)
+	require.ErrorContains(t, err, "correlation ID "+stageErr.CorrelationId)
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, stageErr.CorrelationId, invalidResponseError.CorrelationId)
+
+	_, err = uma.SendUmaPayment(context.Background(), params, uma.WithCorrelationId("payment-123"))
+	require.ErrorAs(t, err, &stageErr)
+	require.Equal(t, "payment-123", stageErr.CorrelationId)
+	require.Equal(t, "payment-123", receivedCorrelationIds[1])
+}
@@ -0,0 +1,112 @@
+package uma_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestGetPaymentHashAndVerifyPreimage(t *testing.T) {
+	paymentHash, err := uma.GetPaymentHash(bolt11ExpiringInvoice)
+	require.NoError(t, err)
+	require.Equal(t, "0001020304050607080900010203040506070809000102030405060708090102", paymentHash)
+	_, err = uma.GetPaymentHash(newTestBolt11Invoice(t, "lnbcrt10n", time.Now(), 600))
+	require.Error(t, err)
+
+	preimage := []byte("preimage of a settled payment!!!")
+	hash := sha256.Sum256(preimage)
+	require.NoError(t, uma.VerifyPaymentPreimage(hex.EncodeToString(hash[:]), hex.EncodeToString(preimage)))
+	require.Error(t, uma.VerifyPaymentPreimage(paymentHash, hex.EncodeToString(preimage)))
+	require.Error(t, uma.VerifyPaymentPreimage(paymentHash, "not hex"))
+}
+
+// createTestPaymentReceipt creates an unsigned receipt for a 1000 msats payment from $alice@vasp1.com to
+// $bob@vasp2.com, created by the given VASP.
+func createTestPaymentReceipt(t *testing.T, vaspDomain string) *umaprotocol.PaymentReceipt {
+	payreq, response, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	preimage := []byte("preimage of a settled payment!!!")
+	paymentHash := sha256.Sum256(preimage)
+	response.EncodedInvoice = newTestPaymentHashInvoice(t, "lnbcrt10n", paymentHash[:])
+	preimageHex := hex.EncodeToString(preimage)
+	receipt, err := uma.NewPaymentReceipt(*payreq, *response, &preimageHex, time.Now(), vaspDomain)
+	require.NoError(t, err)
+	return receipt
+}
+
+func TestSignAndVerifyPaymentReceipt(t *testing.T) {
+	receipt := createTestPaymentReceipt(t, "vasp1.com")
+	require.Equal(t, int64(1000), receipt.AmountMsats)
+	require.Equal(t, "$alice@vasp1.com", receipt.SenderIdentifier)
+	require.Equal(t, "$bob@vasp2.com", receipt.ReceiverIdentifier)
+	require.Equal(t, "USD", *receipt.ReceivedCurrencyCode)
+
+	privateKey, signer := createSigner(t)
+	signedReceipt, err := uma.SignPaymentReceipt(*receipt, signer)
+	require.NoError(t, err)
+	receiptJson, err := json.Marshal(signedReceipt)
+	require.NoError(t, err)
+	parsedReceipt, err := uma.ParsePaymentReceipt(receiptJson)
+	require.NoError(t, err)
+	err = uma.VerifyPaymentReceiptSignature(parsedReceipt, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	parsedReceipt.AmountMsats = 2000
+	err = uma.VerifyPaymentReceiptSignature(parsedReceipt, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+
+	encoded, err := uma.EncodeEnvelope(signedReceipt)
+	require.NoError(t, err)
+	envelope, err := uma.DecodeEnvelope(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uma.MessageTypePaymentReceipt, envelope.Type)
+	require.Equal(t, signedReceipt.PaymentHash, envelope.Message.(*umaprotocol.PaymentReceipt).PaymentHash)
+
+	wrongPreimage := hex.EncodeToString([]byte("wrong"))
+	receipt.Preimage = &wrongPreimage
+	_, err = uma.SignPaymentReceipt(*receipt, signer)
+	require.ErrorContains(t, err, "preimage does not match")
+	receipt.Preimage = nil
+	receipt.VaspDomain = "vasp3.com"
+	_, err = uma.SignPaymentReceipt(*receipt, signer)
+	require.Error(t, err)
+}
+
+func TestPaymentReceiptHandler(t *testing.T) {
+	receiver := umatest.NewMockSendingVasp()
+	defer receiver.Close()
+	var received []*umaprotocol.PaymentReceipt
+	handler := uma.NewPaymentReceiptHandler(
+		uma.NewInMemoryPublicKeyCache(),
+		getNonceCache(),
+		func(_ context.Context, receipt *umaprotocol.PaymentReceipt) error {
+			received = append(received, receipt)
+			return nil
+		},
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	receipt := createTestPaymentReceipt(t, "vasp2.com")
+	receipt.ReceiverIdentifier = "$bob@" + receiver.Domain
+	receipt.VaspDomain = receiver.Domain
+	signedReceipt, err := uma.SignPaymentReceipt(*receipt, receiver.Signer)
+	require.NoError(t, err)
+	err = uma.SendPaymentReceipt(context.Background(), server.URL, *signedReceipt)
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	require.Equal(t, signedReceipt.PaymentHash, received[0].PaymentHash)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/receipts", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -0,0 +1,91 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestSignAndVerifyPaymentStatusWebhook(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	failureReason := "invoice expired"
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusExpired,
+		FailureReason:  &failureReason,
+		VaspDomain:     "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+	webhookJson, err := json.Marshal(webhook)
+	require.NoError(t, err)
+	parsedWebhook, err := uma.ParsePaymentStatusWebhook(webhookJson)
+	require.NoError(t, err)
+	require.Equal(t, failureReason, *parsedWebhook.FailureReason)
+	err = uma.VerifyPaymentStatusWebhookSignature(parsedWebhook, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	parsedWebhook.Status = umaprotocol.PaymentStatusPaid
+	err = uma.VerifyPaymentStatusWebhookSignature(parsedWebhook, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+
+	_, err = uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         "REFUNDED",
+		VaspDomain:     "vasp1.com",
+	}, signer)
+	require.Error(t, err)
+	_, err = uma.ParsePaymentStatusWebhook([]byte(`{"invoice":"lnbc1","status":"REFUNDED"}`))
+	require.Error(t, err)
+}
+
+func TestPaymentStatusWebhookHandler(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	var received []*umaprotocol.PaymentStatusWebhook
+	handler := uma.NewPaymentStatusWebhookHandler(
+		uma.NewInMemoryPublicKeyCache(),
+		getNonceCache(),
+		func(_ context.Context, webhook *umaprotocol.PaymentStatusWebhook) error {
+			received = append(received, webhook)
+			return nil
+		},
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusPaid,
+		VaspDomain:     sender.Domain,
+	}, sender.Signer)
+	require.NoError(t, err)
+	require.NoError(t, uma.SendPaymentStatusWebhook(context.Background(), server.URL, *webhook))
+	require.Len(t, received, 1)
+	require.Equal(t, umaprotocol.PaymentStatusPaid, received[0].Status)
+
+	// Replays are rejected by the nonce cache.
+	err = uma.SendPaymentStatusWebhook(context.Background(), server.URL, *webhook)
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, http.StatusBadRequest, invalidResponseError.StatusCode)
+
+	_, otherSigner := createSigner(t)
+	forgedWebhook, err := uma.SignPaymentStatusWebhook(*webhook, otherSigner)
+	require.NoError(t, err)
+	err = uma.SendPaymentStatusWebhook(context.Background(), server.URL, *forgedWebhook)
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, http.StatusBadRequest, invalidResponseError.StatusCode)
+	require.Len(t, received, 1)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
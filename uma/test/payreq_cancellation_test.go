@@ -0,0 +1,84 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestSignAndVerifyPayReqCancellation(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	reason := "declined by sender"
+	cancellation, err := uma.SignPayReqCancellation(umaprotocol.PayReqCancellation{
+		EncodedInvoice: umatest.FakeInvoice,
+		Reason:         &reason,
+		VaspDomain:     "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+	cancellationJson, err := json.Marshal(cancellation)
+	require.NoError(t, err)
+	parsedCancellation, err := uma.ParsePayReqCancellation(cancellationJson)
+	require.NoError(t, err)
+	require.Equal(t, reason, *parsedCancellation.Reason)
+	err = uma.VerifyPayReqCancellationSignature(parsedCancellation, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	parsedCancellation.EncodedInvoice = "lnbc1other"
+	err = uma.VerifyPayReqCancellationSignature(parsedCancellation, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+
+	_, err = uma.SignPayReqCancellation(umaprotocol.PayReqCancellation{VaspDomain: "vasp1.com"}, signer)
+	require.Error(t, err)
+	_, err = uma.ParsePayReqCancellation([]byte(`{"invoice":"lnbc1"}`))
+	require.Error(t, err)
+}
+
+func TestPayReqCancellationHandler(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	var received []*umaprotocol.PayReqCancellation
+	handler := uma.NewPayReqCancellationHandler(
+		uma.NewInMemoryPublicKeyCache(),
+		getNonceCache(),
+		func(_ context.Context, cancellation *umaprotocol.PayReqCancellation) error {
+			received = append(received, cancellation)
+			return nil
+		},
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	cancellation, err := uma.SignPayReqCancellation(umaprotocol.PayReqCancellation{
+		EncodedInvoice: umatest.FakeInvoice,
+		VaspDomain:     sender.Domain,
+	}, sender.Signer)
+	require.NoError(t, err)
+	require.NoError(t, uma.SendPayReqCancellation(context.Background(), server.URL, *cancellation))
+	require.Len(t, received, 1)
+	require.Equal(t, umatest.FakeInvoice, received[0].EncodedInvoice)
+
+	// Replays are rejected by the nonce cache.
+	err = uma.SendPayReqCancellation(context.Background(), server.URL, *cancellation)
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, http.StatusBadRequest, invalidResponseError.StatusCode)
+
+	_, otherSigner := createSigner(t)
+	forgedCancellation, err := uma.SignPayReqCancellation(*cancellation, otherSigner)
+	require.NoError(t, err)
+	err = uma.SendPayReqCancellation(context.Background(), server.URL, *forgedCancellation)
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Len(t, received, 1)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
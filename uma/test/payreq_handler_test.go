@@ -0,0 +1,436 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+// newTestPayRequest creates an UMA pay request from $alice@vasp1.com, and a PublicKeyCache holding the keys of
+// vasp1.com.
+func newTestPayRequest(t *testing.T) (*umaprotocol.PayRequest, uma.PublicKeyCache) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	return createSignedPayRequest(t, senderPrivateKey), pubKeyCache
+}
+
+// servePayRequestConcurrently sends the same pay request to a handler from several goroutines, as senders retrying
+// against several instances would, and returns the responses.
+func servePayRequestConcurrently(
+	t *testing.T,
+	handler http.Handler,
+	payreq *umaprotocol.PayRequest,
+	deliveries int,
+) []*httptest.ResponseRecorder {
+	body, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	recorders := make([]*httptest.ResponseRecorder, deliveries)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(recorder *httptest.ResponseRecorder) {
+			defer wg.Done()
+			request := httptest.NewRequest(http.MethodPost, "https://vasp2.com/api/uma/payreq/$bob", bytes.NewReader(body))
+			handler.ServeHTTP(recorder, request)
+		}(recorders[i])
+	}
+	wg.Wait()
+	return recorders
+}
+
+func countingPayReqResponseFunc(invoices *atomic.Int64) uma.PayReqResponseFunc {
+	return func(_ context.Context, request *umaprotocol.PayRequest) (*umaprotocol.PayReqResponse, error) {
+		invoice := "lnbcrt" + strconv.FormatInt(invoices.Add(1), 10)
+		return &umaprotocol.PayReqResponse{EncodedInvoice: invoice, Routes: []umaprotocol.Route{}}, nil
+	}
+}
+
+func TestPayRequestHandler(t *testing.T) {
+	payreq, pubKeyCache := newTestPayRequest(t)
+	var invoices atomic.Int64
+	handler := uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), countingPayReqResponseFunc(&invoices))
+
+	recorders := servePayRequestConcurrently(t, handler, payreq, 1)
+	require.Equal(t, http.StatusOK, recorders[0].Code, recorders[0].Body.String())
+	var response umaprotocol.PayReqResponse
+	require.NoError(t, json.Unmarshal(recorders[0].Body.Bytes(), &response))
+	require.Equal(t, "lnbcrt1", response.EncodedInvoice)
+
+	recorders = servePayRequestConcurrently(t, handler, payreq, 1)
+	require.Equal(t, http.StatusBadRequest, recorders[0].Code)
+	require.Contains(t, recorders[0].Body.String(), "nonce")
+	require.Equal(t, int64(1), invoices.Load())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "https://vasp2.com/api/uma/payreq/$bob", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+// countingLocker is a Locker which signals each call to Lock, so that tests can wait until requests contend for a
+// lock.
+type countingLocker struct {
+	uma.Locker
+	locking chan struct{}
+}
+
+func (l *countingLocker) Lock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	l.locking <- struct{}{}
+	return l.Locker.Lock(ctx, key, ttl)
+}
+
+func TestPayRequestHandlerIssuesOneInvoicePerPayRequest(t *testing.T) {
+	const deliveries = 5
+	payreq, pubKeyCache := newTestPayRequest(t)
+	idempotencyKey := uma.GenerateIdempotencyKey()
+	payreq.IdempotencyKey = &idempotencyKey
+	var invoices atomic.Int64
+	locker := &countingLocker{Locker: uma.NewInMemoryLocker(), locking: make(chan struct{}, deliveries)}
+	var allDelivered sync.Once
+	createInvoice := countingPayReqResponseFunc(&invoices)
+	respond := func(ctx context.Context, request *umaprotocol.PayRequest) (*umaprotocol.PayReqResponse, error) {
+		// Hold the lock until every delivery is waiting for it.
+		allDelivered.Do(func() {
+			for i := 0; i < deliveries; i++ {
+				<-locker.locking
+			}
+		})
+		return createInvoice(ctx, request)
+	}
+	handler := uma.NewPayRequestHandler(
+		pubKeyCache,
+		uma.NewInMemoryNonceCache(time.Now().Add(-time.Hour)),
+		respond,
+		uma.WithLocker(locker),
+		uma.WithIdempotencyStore(uma.NewInMemoryIdempotencyStore()),
+	)
+
+	recorders := servePayRequestConcurrently(t, handler, payreq, deliveries)
+	for _, recorder := range recorders {
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		require.Equal(t, recorders[0].Body.String(), recorder.Body.String())
+	}
+	require.Equal(t, int64(1), invoices.Load())
+}
+
+func TestPayRequestHandlerIdempotencyKeyReuse(t *testing.T) {
+	senderPrivateKey, senderSigner := createSigner(t)
+	otherSenderPrivateKey, otherSenderSigner := createSigner(t)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	otherPubKeyResponse := getPubKeyResponse(otherSenderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp3.com", &otherPubKeyResponse)
+	var invoices atomic.Int64
+	handler := uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), countingPayReqResponseFunc(&invoices),
+		uma.WithIdempotencyStore(uma.NewInMemoryIdempotencyStore()))
+	serve := func(payreq *umaprotocol.PayRequest) *httptest.ResponseRecorder {
+		return servePayRequestConcurrently(t, handler, payreq, 1)[0]
+	}
+
+	idempotencyKey := uma.GenerateIdempotencyKey()
+	payreq := createSignedPayRequest(t, senderPrivateKey)
+	payreq.IdempotencyKey = &idempotencyKey
+	original := serve(payreq)
+	require.Equal(t, http.StatusOK, original.Code, original.Body.String())
+
+	// A retry signed again with a fresh nonce gets the original response.
+	retry, err := uma.SignPayRequest(*payreq, senderSigner)
+	require.NoError(t, err)
+	recorder := serve(retry)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.JSONEq(t, original.Body.String(), recorder.Body.String())
+
+	// A replay of the key signed by another VASP on behalf of the sender is rejected before the stored response is
+	// looked up.
+	forged, err := uma.SignPayRequest(*payreq, otherSenderSigner)
+	require.NoError(t, err)
+	recorder = serve(forged)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.NotContains(t, recorder.Body.String(), "lnbcrt")
+
+	// The key cannot be reused for a different pay request, whether tampered with or signed by the sender.
+	tampered := *retry
+	tampered.Amount = 1_000_000
+	different, err := uma.SignPayRequest(tampered, senderSigner)
+	require.NoError(t, err)
+	for _, request := range []*umaprotocol.PayRequest{&tampered, different} {
+		recorder = serve(request)
+		require.Equal(t, http.StatusConflict, recorder.Code)
+		require.Contains(t, recorder.Body.String(), uma.ErrIdempotencyKeyReused.Error())
+	}
+
+	// Keys are scoped by sender, so another sender using the same key gets its own invoice.
+	otherPayerData := umaprotocol.PayerData{}
+	for key, value := range *payreq.PayerData {
+		otherPayerData[key] = value
+	}
+	otherPayerData[umaprotocol.CounterPartyDataFieldIdentifier.String()] = "$carol@vasp3.com"
+	otherPayreq := *payreq
+	otherPayreq.PayerData = &otherPayerData
+	otherSenderPayreq, err := uma.SignPayRequest(otherPayreq, otherSenderSigner)
+	require.NoError(t, err)
+	recorder = serve(otherSenderPayreq)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NotEqual(t, original.Body.String(), recorder.Body.String())
+	require.Equal(t, int64(2), invoices.Load())
+}
+
+func TestInMemoryLocker(t *testing.T) {
+	locker := uma.NewInMemoryLocker()
+	unlock, err := locker.Lock(context.Background(), "key", time.Second)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(ctx, "key", time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	unlockOther, err := locker.Lock(context.Background(), "other key", time.Second)
+	require.NoError(t, err)
+	unlockOther()
+
+	unlock()
+	unlock()
+	unlock, err = locker.Lock(context.Background(), "key", time.Second)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestPayRequestHandlerSenderConcurrencyLimit(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	var invoices atomic.Int64
+	createInvoice := countingPayReqResponseFunc(&invoices)
+	responding := make(chan struct{}, 1)
+	release := make(chan struct{})
+	respond := func(ctx context.Context, request *umaprotocol.PayRequest) (*umaprotocol.PayReqResponse, error) {
+		responding <- struct{}{}
+		<-release
+		return createInvoice(ctx, request)
+	}
+	handler := uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), respond, uma.WithSenderConcurrencyLimit(1))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)[0]
+	}()
+	<-responding
+
+	// Pay requests from the sender are rejected while it has one in progress.
+	recorders := servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 2)
+	for _, recorder := range recorders {
+		require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	}
+
+	close(release)
+	recorder := <-done
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, int64(1), invoices.Load())
+
+	recorders = servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)
+	require.Equal(t, http.StatusOK, recorders[0].Code, recorders[0].Body.String())
+	require.Equal(t, int64(2), invoices.Load())
+}
+
+func TestPayRequestHandlerSenderConcurrencyLimitIsKeyedByVerifiedSender(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	responding := make(chan struct{})
+	release := make(chan struct{})
+	respond := func(_ context.Context, _ *umaprotocol.PayRequest) (*umaprotocol.PayReqResponse, error) {
+		close(responding)
+		<-release
+		return &umaprotocol.PayReqResponse{EncodedInvoice: "lnbcrt1", Routes: []umaprotocol.Route{}}, nil
+	}
+	handler := uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), respond, uma.WithSenderConcurrencyLimit(1))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)[0]
+	}()
+	<-responding
+
+	// Pay requests claiming to come from the sender without its signature do not count against its limit.
+	recorders := servePayRequestConcurrently(t, handler, createSignedPayRequest(t, otherPrivateKey), 1)
+	require.Equal(t, http.StatusBadRequest, recorders[0].Code)
+	recorders = servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)
+	require.Equal(t, http.StatusTooManyRequests, recorders[0].Code)
+	require.Contains(t, recorders[0].Body.String(), uma.ErrSenderConcurrencyLimitExceeded.Error())
+
+	close(release)
+	recorder := <-done
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+// blockingPublicKeyCache is a PublicKeyCache whose lookups wait until release is closed.
+type blockingPublicKeyCache struct {
+	uma.PublicKeyCache
+	fetching chan struct{}
+	release  chan struct{}
+}
+
+func (c *blockingPublicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *umaprotocol.PubKeyResponse {
+	select {
+	case c.fetching <- struct{}{}:
+	default:
+	}
+	<-c.release
+	return c.PublicKeyCache.FetchPublicKeyForVasp(vaspDomain)
+}
+
+func TestPayRequestHandlerVerificationConcurrencyLimit(t *testing.T) {
+	payreq, pubKeyCache := newTestPayRequest(t)
+	blockingCache := &blockingPublicKeyCache{
+		PublicKeyCache: pubKeyCache,
+		fetching:       make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	var invoices atomic.Int64
+	handler := uma.NewPayRequestHandler(blockingCache, getNonceCache(), countingPayReqResponseFunc(&invoices),
+		uma.WithVerificationConcurrencyLimit(1))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- servePayRequestConcurrently(t, handler, payreq, 1)[0]
+	}()
+	<-blockingCache.fetching
+
+	recorders := servePayRequestConcurrently(t, handler, payreq, 1)
+	require.Equal(t, http.StatusTooManyRequests, recorders[0].Code)
+	require.Contains(t, recorders[0].Body.String(), uma.ErrVerificationConcurrencyLimitExceeded.Error())
+
+	close(blockingCache.release)
+	recorder := <-done
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, int64(1), invoices.Load())
+}
+
+func TestPayRequestHandlerInvoiceQuota(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	var invoices atomic.Int64
+	handler := uma.NewPayRequestHandler(
+		pubKeyCache,
+		getNonceCache(),
+		countingPayReqResponseFunc(&invoices),
+		uma.WithInvoiceQuota(uma.NewInMemoryInvoiceQuota(2, time.Hour), nil),
+	)
+
+	for i := 0; i < 2; i++ {
+		recorders := servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)
+		require.Equal(t, http.StatusOK, recorders[0].Code, recorders[0].Body.String())
+	}
+	recorders := servePayRequestConcurrently(t, handler, createSignedPayRequest(t, senderPrivateKey), 1)
+	require.Equal(t, http.StatusTooManyRequests, recorders[0].Code)
+	require.Contains(t, recorders[0].Body.String(), "quota")
+	require.Equal(t, int64(2), invoices.Load())
+}
+
+func TestInMemoryInvoiceQuota(t *testing.T) {
+	ctx := context.Background()
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	quota := uma.NewInMemoryInvoiceQuota(1, time.Minute)
+	quota.SetClock(clock)
+	require.NoError(t, quota.ConsumeInvoice(ctx, "bob"))
+	require.ErrorIs(t, quota.ConsumeInvoice(ctx, "bob"), uma.ErrInvoiceQuotaExceeded)
+	require.NoError(t, quota.ConsumeInvoice(ctx, "alice"))
+
+	clock.Advance(time.Minute)
+	require.NoError(t, quota.ConsumeInvoice(ctx, "bob"))
+}
+
+func TestPayRequestHandlerComplianceDecider(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	newPayRequest := func(amount int64) *umaprotocol.PayRequest {
+		trInfo := `{"originator":"Alice"}`
+		payreq, err := uma.GetUmaPayRequest(
+			amount,
+			receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+			createPrivateKeyHandle(t, senderPrivateKey),
+			"USD",
+			true,
+			"$alice@vasp1.com",
+			1,
+			nil,
+			nil,
+			&trInfo,
+			nil,
+			umaprotocol.KycStatusVerified,
+			nil,
+			nil,
+			"/api/lnurl/utxocallback?txid=1234",
+			nil,
+			nil,
+		)
+		require.NoError(t, err)
+		return payreq
+	}
+
+	var decisionRequests []uma.ComplianceDecisionRequest
+	decider := func(_ context.Context, request uma.ComplianceDecisionRequest) (uma.ComplianceVerdict, error) {
+		decisionRequests = append(decisionRequests, request)
+		switch {
+		case request.Amount >= 1_000_000:
+			return uma.ComplianceVerdict{Decision: uma.ComplianceDecisionReject, Reason: "amount too large"}, nil
+		case request.Amount >= 10_000:
+			return uma.ComplianceVerdict{Decision: uma.ComplianceDecisionHold}, nil
+		default:
+			return uma.ComplianceVerdict{Decision: uma.ComplianceDecisionAccept}, nil
+		}
+	}
+	var invoices atomic.Int64
+	handler := uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), countingPayReqResponseFunc(&invoices),
+		uma.WithComplianceDecider(decider, receiverEncryptionPrivateKey.Serialize()))
+
+	recorders := servePayRequestConcurrently(t, handler, newPayRequest(1000), 1)
+	require.Equal(t, http.StatusOK, recorders[0].Code, recorders[0].Body.String())
+	require.Len(t, decisionRequests, 1)
+	require.Equal(t, `{"originator":"Alice"}`, *decisionRequests[0].TravelRuleInfo)
+	require.Equal(t, "$alice@vasp1.com", *decisionRequests[0].PayerData.Identifier())
+	require.Equal(t, "USD", *decisionRequests[0].SendingAmountCurrencyCode)
+
+	recorders = servePayRequestConcurrently(t, handler, newPayRequest(1_000_000), 1)
+	require.Equal(t, http.StatusForbidden, recorders[0].Code)
+	var statusResponse map[string]string
+	require.NoError(t, json.Unmarshal(recorders[0].Body.Bytes(), &statusResponse))
+	require.Equal(t, "ERROR", statusResponse["status"])
+	require.Equal(t, "rejected by compliance screening: amount too large", statusResponse["reason"])
+
+	recorders = servePayRequestConcurrently(t, handler, newPayRequest(10_000), 1)
+	require.Equal(t, http.StatusConflict, recorders[0].Code)
+	require.Contains(t, recorders[0].Body.String(), uma.ErrComplianceHeld.Error())
+	require.Equal(t, int64(1), invoices.Load())
+}
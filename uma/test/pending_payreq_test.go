@@ -0,0 +1,123 @@
+package uma_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func holdingComplianceDecider(_ context.Context, _ uma.ComplianceDecisionRequest) (uma.ComplianceVerdict, error) {
+	return uma.ComplianceVerdict{Decision: uma.ComplianceDecisionHold, Reason: "manual review"}, nil
+}
+
+func TestPendingPayRequest(t *testing.T) {
+	store := uma.NewInMemoryHeldPayRequestStore()
+	var invoices atomic.Int64
+	payreq, pubKeyCache := newTestPayRequest(t)
+	server := httptest.NewServer(uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), countingPayReqResponseFunc(&invoices),
+		uma.WithComplianceDecider(holdingComplianceDecider, nil),
+		uma.WithHeldPayRequestStore(store, 30*time.Second),
+	))
+	defer server.Close()
+	callback := server.URL + "/api/uma/payreq/$bob"
+
+	_, err := uma.SendPayRequest(context.Background(), callback, payreq)
+	var pendingErr *uma.PayReqPendingError
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, umaprotocol.PayReqStatusPending, pendingErr.Response.Status)
+	require.Equal(t, "manual review", *pendingErr.Response.Reason)
+	require.Equal(t, int64(30), *pendingErr.Response.RetryAfterSec)
+	retrievalToken := pendingErr.Response.RetrievalToken
+	require.NotEmpty(t, retrievalToken)
+	require.Equal(t, int64(0), invoices.Load())
+
+	_, err = uma.PollPendingPayRequest(context.Background(), callback, retrievalToken)
+	require.ErrorAs(t, err, &pendingErr)
+	_, err = uma.PollPendingPayRequest(context.Background(), callback, "unknown")
+	var invalidResponseErr uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseErr)
+	require.Equal(t, http.StatusNotFound, invalidResponseErr.StatusCode)
+
+	pending, err := uma.ResolveHeldPayRequest(context.Background(), store, retrievalToken,
+		&umaprotocol.PayReqResponse{EncodedInvoice: "lnbcrt1", Routes: []umaprotocol.Route{}})
+	require.NoError(t, err)
+	require.Equal(t, uma.HeldPayRequestStateAccepted, pending.State)
+	require.Equal(t, *payreq.PayerData.Identifier(), *pending.Request.PayerData.Identifier())
+	_, err = uma.RejectHeldPayRequest(context.Background(), store, retrievalToken, "too late")
+	require.Error(t, err)
+
+	response, err := uma.WaitForPendingPayRequest(context.Background(), callback,
+		umaprotocol.PendingPayReqResponse{RetrievalToken: retrievalToken}, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "lnbcrt1", response.EncodedInvoice)
+}
+
+func TestRejectedPendingPayRequest(t *testing.T) {
+	store := uma.NewInMemoryHeldPayRequestStore()
+	var invoices atomic.Int64
+	payreq, pubKeyCache := newTestPayRequest(t)
+	server := httptest.NewServer(uma.NewPayRequestHandler(pubKeyCache, getNonceCache(), countingPayReqResponseFunc(&invoices),
+		uma.WithComplianceDecider(holdingComplianceDecider, nil),
+		uma.WithHeldPayRequestStore(store, 0),
+	))
+	defer server.Close()
+	callback := server.URL + "/api/uma/payreq/$bob"
+
+	_, err := uma.SendPayRequest(context.Background(), callback, payreq)
+	var pendingErr *uma.PayReqPendingError
+	require.ErrorAs(t, err, &pendingErr)
+	require.Nil(t, pendingErr.Response.RetryAfterSec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = uma.WaitForPendingPayRequest(ctx, callback, pendingErr.Response, time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = uma.RejectHeldPayRequest(context.Background(), store, pendingErr.Response.RetrievalToken, "sanctioned")
+	require.NoError(t, err)
+	_, err = uma.PollPendingPayRequest(context.Background(), callback, pendingErr.Response.RetrievalToken)
+	var invalidResponseErr uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseErr)
+	require.Equal(t, http.StatusForbidden, invalidResponseErr.StatusCode)
+	require.Contains(t, string(invalidResponseErr.Body), "sanctioned")
+	require.Equal(t, int64(0), invoices.Load())
+}
+
+func TestPayReqResolutionWebhook(t *testing.T) {
+	// Any VASP serving its public keys can sign webhooks, so the mock sending VASP stands in for the receiver.
+	receiver := umatest.NewMockSendingVasp()
+	defer receiver.Close()
+	var resolved []string
+	server := httptest.NewServer(uma.NewPayReqResolutionWebhookHandler(uma.NewInMemoryPublicKeyCache(), getNonceCache(),
+		func(_ context.Context, webhook *umaprotocol.PayReqResolutionWebhook) error {
+			resolved = append(resolved, webhook.RetrievalToken)
+			return nil
+		},
+	))
+	defer server.Close()
+
+	webhook, err := uma.SignPayReqResolutionWebhook(umaprotocol.PayReqResolutionWebhook{
+		RetrievalToken: "token",
+		VaspDomain:     receiver.Domain,
+	}, receiver.Signer)
+	require.NoError(t, err)
+	require.NoError(t, uma.SendPayReqResolutionWebhook(context.Background(), server.URL, *webhook))
+	require.Equal(t, []string{"token"}, resolved)
+
+	// Replays are rejected by the nonce cache.
+	err = uma.SendPayReqResolutionWebhook(context.Background(), server.URL, *webhook)
+	var invalidResponseErr uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseErr)
+	require.Equal(t, http.StatusBadRequest, invalidResponseErr.StatusCode)
+
+	_, err = uma.SignPayReqResolutionWebhook(umaprotocol.PayReqResolutionWebhook{VaspDomain: receiver.Domain}, receiver.Signer)
+	require.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package uma_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+func TestInMemoryPersistenceAdapter(t *testing.T) {
+	testPersistenceAdapter(t, uma.NewInMemoryPersistenceAdapter())
+}
+
+// testPersistenceAdapter checks the behavior every PersistenceAdapter must have.
+func testPersistenceAdapter(t *testing.T, adapter uma.PersistenceAdapter) {
+	ctx := context.Background()
+
+	nonceCache := adapter.NonceCache()
+	require.NoError(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now().Add(time.Second)))
+	require.Error(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now().Add(time.Second)))
+	require.Error(t, nonceCache.CheckAndSaveNonce("nonce2", time.Now().Add(-time.Hour)))
+	nonceCache.PurgeNoncesOlderThan(time.Now().Add(time.Minute))
+	require.Error(t, nonceCache.CheckAndSaveNonce("nonce3", time.Now().Add(time.Second)))
+	require.NoError(t, nonceCache.CheckAndSaveNonce("nonce3", time.Now().Add(2*time.Minute)))
+
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	adapter.PublicKeyCache().AddPublicKeyForVasp("vasp2.com", &pubKeyResponse)
+	require.NotNil(t, adapter.PublicKeyCache().FetchPublicKeyForVasp("vasp2.com"))
+	adapter.PublicKeyCache().RemovePublicKeyForVasp("vasp2.com")
+	require.Nil(t, adapter.PublicKeyCache().FetchPublicKeyForVasp("vasp2.com"))
+
+	payreq, response, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	pending := uma.PendingPayRequest{
+		Id:              "payment1",
+		PayeeIdentifier: "$bob@vasp2.com",
+		PayRequest:      *payreq,
+		CreatedAt:       time.Unix(1700000000, 0),
+	}
+	require.NoError(t, adapter.PendingPayRequests().SavePendingPayRequest(ctx, pending))
+	pending.PayReqResponse = response
+	require.NoError(t, adapter.PendingPayRequests().SavePendingPayRequest(ctx, pending))
+	savedPending, err := adapter.PendingPayRequests().GetPendingPayRequest(ctx, "payment1")
+	require.NoError(t, err)
+	require.Equal(t, payreq.Amount, savedPending.PayRequest.Amount)
+	require.Equal(t, response.EncodedInvoice, savedPending.PayReqResponse.EncodedInvoice)
+	require.Equal(t, "$bob@vasp2.com", savedPending.ExpectedPayment().PayeeIdentifier)
+	require.NoError(t, adapter.PendingPayRequests().DeletePendingPayRequest(ctx, "payment1"))
+	_, err = adapter.PendingPayRequests().GetPendingPayRequest(ctx, "payment1")
+	require.ErrorIs(t, err, uma.ErrRecordNotFound)
+
+	quote := uma.StoredQuote{
+		Quote:          uma.Quote{Id: "quote1", ExpiresAt: time.Unix(1700000600, 0)},
+		EncodedInvoice: response.EncodedInvoice,
+		PaymentInfo:    *response.PaymentInfo,
+		CreatedAt:      time.Unix(1700000000, 0),
+	}
+	require.NoError(t, adapter.Quotes().SaveQuote(ctx, quote))
+	savedQuote, err := adapter.Quotes().GetQuote(ctx, "quote1")
+	require.NoError(t, err)
+	require.Equal(t, quote, *savedQuote)
+	require.NoError(t, adapter.Quotes().PurgeQuotesExpiredBefore(ctx, time.Unix(1700000000, 0)))
+	_, err = adapter.Quotes().GetQuote(ctx, "quote1")
+	require.NoError(t, err)
+	require.NoError(t, adapter.Quotes().PurgeQuotesExpiredBefore(ctx, time.Unix(1700001000, 0)))
+	_, err = adapter.Quotes().GetQuote(ctx, "quote1")
+	require.ErrorIs(t, err, uma.ErrRecordNotFound)
+
+	receipt := createTestPaymentReceipt(t, "vasp1.com")
+	require.NoError(t, adapter.PaymentReceipts().SavePaymentReceipt(ctx, *receipt))
+	receipt.VaspDomain = "vasp2.com"
+	require.NoError(t, adapter.PaymentReceipts().SavePaymentReceipt(ctx, *receipt))
+	require.NoError(t, adapter.PaymentReceipts().SavePaymentReceipt(ctx, *receipt))
+	receipts, err := adapter.PaymentReceipts().GetPaymentReceipts(ctx, receipt.PaymentHash)
+	require.NoError(t, err)
+	require.Len(t, receipts, 2)
+	receipts, err = adapter.PaymentReceipts().GetPaymentReceipts(ctx, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, receipts)
+}
@@ -42,6 +42,22 @@ func TestParseV0Currency(t *testing.T) {
 	require.Equal(t, currency, reserializedCurrency)
 }
 
+func TestCurrencyConvertibleRange(t *testing.T) {
+	var currency umaprotocol.Currency
+	err := json.Unmarshal([]byte(`{"code":"USD","name":"US Dollar","symbol":"$","multiplier":24150,`+
+		`"convertible":{"min":100,"max":10000},"decimals":2}`), &currency)
+	require.NoError(t, err)
+	require.Equal(t, umaprotocol.ConvertibleCurrency{MinSendable: 100, MaxSendable: 10_000}, currency.Convertible)
+	require.True(t, currency.Convertible.HasRange())
+	require.True(t, currency.Convertible.Contains(100))
+	require.True(t, currency.Convertible.Contains(10_000))
+	require.False(t, currency.Convertible.Contains(99))
+	require.False(t, currency.Convertible.Contains(10_001))
+
+	require.False(t, umaprotocol.ConvertibleCurrency{}.HasRange())
+	require.True(t, umaprotocol.ConvertibleCurrency{}.Contains(1_000_000))
+}
+
 func TestV0LnurlpResponse(t *testing.T) {
 	currencies := []umaprotocol.Currency{
 		{
@@ -190,6 +206,51 @@ func TestParseV0PayReqResponse(t *testing.T) {
 	require.Equal(t, 0, payReqResp.UmaMajorVersion)
 }
 
+func TestProtocolFeatureVersions(t *testing.T) {
+	require.True(t, umaprotocol.HasInvoiceSupport("1.0"))
+	require.True(t, umaprotocol.HasInvoiceSupport("1.3"))
+	require.True(t, umaprotocol.HasPayeeBackingSignatures("2.0"))
+	require.False(t, umaprotocol.HasInvoiceSupport("0.3"))
+	require.False(t, umaprotocol.HasPayeeBackingSignatures("0.3"))
+	require.False(t, umaprotocol.HasInvoiceSupport("invalid"))
+	require.False(t, umaprotocol.VersionSupportsFeature("1.0", "unknown_feature"))
+}
+
+func TestSerializationGatedOnProtocolFeatures(t *testing.T) {
+	invoiceUUID := "c7c07fec-cf00-431c-916f-6c13fc4b69f9"
+	payRequest := umaprotocol.PayRequest{Amount: 1000, InvoiceUUID: &invoiceUUID, UmaMajorVersion: 1}
+	payRequestJson, err := payRequest.MarshalJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(payRequestJson), invoiceUUID)
+	payRequest.UmaMajorVersion = 0
+	payRequestJson, err = payRequest.MarshalJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(payRequestJson), invoiceUUID)
+
+	payeeData := umaprotocol.PayeeData{
+		"identifier": "$bob@vasp2.com",
+		"compliance": map[string]interface{}{
+			"utxos":             []string{"txid"},
+			"signature":         "sig",
+			"backingSignatures": []umaprotocol.BackingSignature{{Domain: "backer.com", Signature: "backing"}},
+		},
+	}
+	response := umaprotocol.PayReqResponse{EncodedInvoice: "lnbc1", Routes: []umaprotocol.Route{}, PayeeData: &payeeData}
+	response.UmaMajorVersion = 1
+	responseJson, err := json.Marshal(&response)
+	require.NoError(t, err)
+	require.Contains(t, string(responseJson), "backer.com")
+	response.UmaMajorVersion = 0
+	responseJson, err = json.Marshal(&response)
+	require.NoError(t, err)
+	require.NotContains(t, string(responseJson), "backer.com")
+	require.Contains(t, string(responseJson), `"signature":"sig"`)
+	// The response itself is not modified.
+	compliance, err := response.PayeeData.Compliance()
+	require.NoError(t, err)
+	require.Len(t, *compliance.BackingSignatures, 1)
+}
+
 func TestEncodeAndParsePubKeyResponse(t *testing.T) {
 	pubKeyHex := "04419c5467ea563f0010fd614f85e885ac99c21b8e8d416241175fdd5efd2244fe907e2e6fa3dd6631b1b17cd28798da8d882a34c4776d44cc4090781c7aadea1b"
 	pemCertChain := `-----BEGIN CERTIFICATE-----
@@ -315,3 +376,185 @@ func TestUMAInvoiceTLVAndBech32(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, invoice, *invoice3)
 }
+
+func TestKycStatusMarshaling(t *testing.T) {
+	for _, status := range []umaprotocol.KycStatus{
+		umaprotocol.KycStatusUnknown,
+		umaprotocol.KycStatusNotVerified,
+		umaprotocol.KycStatusPending,
+		umaprotocol.KycStatusVerified,
+	} {
+		statusJson, err := json.Marshal(status)
+		require.NoError(t, err)
+		var parsedStatus umaprotocol.KycStatus
+		require.NoError(t, json.Unmarshal(statusJson, &parsedStatus))
+		require.Equal(t, status, parsedStatus)
+	}
+
+	_, err := json.Marshal(umaprotocol.KycStatus(42))
+	require.ErrorIs(t, err, umaprotocol.ErrInvalidKycStatus)
+
+	var status umaprotocol.KycStatus = umaprotocol.KycStatusVerified
+	require.NoError(t, json.Unmarshal([]byte(`"SOMETHING_NEW"`), &status))
+	require.Equal(t, umaprotocol.KycStatusUnknown, status)
+
+	umaprotocol.StrictKycStatusParsing = true
+	defer func() { umaprotocol.StrictKycStatusParsing = false }()
+	err = json.Unmarshal([]byte(`"SOMETHING_NEW"`), &status)
+	require.ErrorIs(t, err, umaprotocol.ErrInvalidKycStatus)
+	require.NoError(t, json.Unmarshal([]byte(`"PENDING"`), &status))
+	require.Equal(t, umaprotocol.KycStatusPending, status)
+}
+
+func TestKycStatusPredicates(t *testing.T) {
+	require.True(t, umaprotocol.KycStatusVerified.RequiresTravelRule())
+	require.False(t, umaprotocol.KycStatusPending.RequiresTravelRule())
+	require.False(t, umaprotocol.KycStatusUnknown.RequiresTravelRule())
+	require.True(t, umaprotocol.KycStatusNotVerified.IsValid())
+	require.False(t, umaprotocol.KycStatus(-1).IsValid())
+	_, err := umaprotocol.ParseKycStatus("verified")
+	require.ErrorIs(t, err, umaprotocol.ErrInvalidKycStatus)
+	require.Equal(t, umaprotocol.KycStatusNotVerified, umaprotocol.ParseKycStatusLenient("NOT_VERIFIED"))
+}
+
+func TestPayeeDataGetters(t *testing.T) {
+	payeeData := umaprotocol.PayeeData{
+		"identifier": "$bob@vasp2.com",
+		"name":       "Bob",
+		"email":      42,
+	}
+	require.Equal(t, "$bob@vasp2.com", *payeeData.Identifier())
+	require.Equal(t, "Bob", *payeeData.Name())
+	require.Nil(t, payeeData.Email())
+
+	var nilPayeeData *umaprotocol.PayeeData
+	require.Nil(t, nilPayeeData.Identifier())
+}
+
+func TestValidatePayeeData(t *testing.T) {
+	requested := umaprotocol.CounterPartyDataOptions{
+		"identifier": {Mandatory: true},
+		"compliance": {Mandatory: true},
+		"name":       {Mandatory: true},
+		"email":      {Mandatory: false},
+	}
+	received := umaprotocol.PayeeData{
+		"identifier": "$bob@vasp2.com",
+		"compliance": map[string]interface{}{"utxos": []interface{}{}},
+		"name":       "Bob",
+	}
+	require.NoError(t, uma.ValidatePayeeData(requested, received))
+
+	delete(received, "name")
+	received["compliance"] = nil
+	err := uma.ValidatePayeeData(requested, received)
+	require.EqualError(t, err, "missing mandatory payee data fields: compliance, name")
+
+	received["compliance"] = map[string]interface{}{"utxos": []interface{}{}}
+	received["name"] = "Bob"
+	received["email"] = true
+	require.Error(t, uma.ValidatePayeeData(requested, received))
+
+	received["email"] = "bob@vasp2.com"
+	received["compliance"] = "not an object"
+	require.Error(t, uma.ValidatePayeeData(requested, received))
+}
+
+func TestValidateCounterPartyData(t *testing.T) {
+	options := umaprotocol.CounterPartyDataOptions{
+		"identifier":  {Mandatory: true},
+		"name":        {Mandatory: true},
+		"email":       {Mandatory: false},
+		"countryCode": {Mandatory: false},
+		"compliance":  {Mandatory: true},
+	}
+	payerData := umaprotocol.PayerData{
+		"identifier":  "$alice@vasp1.com",
+		"name":        "Alice",
+		"email":       "alice@vasp1.com",
+		"countryCode": "US",
+		"compliance":  map[string]interface{}{"kycStatus": "VERIFIED"},
+		// Fields which were not requested are allowed.
+		"customField": 42,
+	}
+	require.Nil(t, uma.ValidateCounterPartyData(options, payerData))
+
+	// All violations are returned at once.
+	fieldErrors := uma.ValidateCounterPartyData(options, map[string]interface{}{
+		"identifier":  "alice",
+		"name":        " ",
+		"email":       "Alice <alice@vasp1.com>",
+		"countryCode": "usa",
+	})
+	require.Equal(t, []uma.FieldError{
+		{Field: "compliance", Message: "is mandatory but missing"},
+		{Field: "name", Message: "is mandatory but missing"},
+		{Field: "identifier", Message: `"alice" is not a valid UMA address`},
+		{Field: "email", Message: `"Alice <alice@vasp1.com>" is not a valid email address`},
+		{Field: "countryCode", Message: `"usa" is not an ISO 3166-1 alpha-2 country code`},
+	}, fieldErrors)
+	require.EqualError(t, fieldErrors[0], "compliance is mandatory but missing")
+
+	fieldErrors = uma.ValidateCounterPartyData(options, map[string]interface{}{
+		"identifier": "$alice@vasp1.com",
+		"name":       42,
+		"email":      "not-an-email",
+		"compliance": "not an object",
+	})
+	require.Equal(t, []uma.FieldError{
+		{Field: "name", Message: "must be a string"},
+		{Field: "email", Message: `"not-an-email" is not a valid email address`},
+		{Field: "compliance", Message: "must be an object"},
+	}, fieldErrors)
+}
+
+func TestPostalAddress(t *testing.T) {
+	region := "CA"
+	postalCode := "94105"
+	address := umaprotocol.PostalAddress{
+		AddressLines: []string{"123 Market St", "Apt 4"},
+		City:         "San Francisco",
+		Region:       &region,
+		PostalCode:   &postalCode,
+		CountryCode:  "US",
+	}
+	require.NoError(t, address.Validate())
+	addressMap, err := address.AsMap()
+	require.NoError(t, err)
+	payerData := umaprotocol.PayerData{"postalAddress": addressMap}
+	parsedAddress, err := payerData.PostalAddress()
+	require.NoError(t, err)
+	require.Equal(t, address, *parsedAddress)
+
+	addressJson, err := json.Marshal(address)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"addressLines": ["123 Market St", "Apt 4"],
+		"city": "San Francisco",
+		"region": "CA",
+		"postalCode": "94105",
+		"countryCode": "US"
+	}`, string(addressJson))
+
+	address.CountryCode = "XX"
+	require.ErrorContains(t, address.Validate(), "ISO 3166-1")
+	address.CountryCode = "us"
+	require.Error(t, address.Validate())
+	require.Error(t, (&umaprotocol.PostalAddress{City: "Paris", CountryCode: "FR"}).Validate())
+
+	payeeData := umaprotocol.PayeeData{"postalAddress": "123 Market St, San Francisco"}
+	_, err = payeeData.PostalAddress()
+	require.Error(t, err)
+	noAddress, err := (&umaprotocol.PayeeData{}).PostalAddress()
+	require.NoError(t, err)
+	require.Nil(t, noAddress)
+
+	options := umaprotocol.CounterPartyDataOptions{"postalAddress": {Mandatory: true}}
+	require.Nil(t, uma.ValidateCounterPartyData(options, payerData))
+	fieldErrors := uma.ValidateCounterPartyData(options, map[string]interface{}{
+		"postalAddress": map[string]interface{}{"addressLines": []interface{}{"1 Rue de Rivoli"}, "city": "Paris", "countryCode": "ZZ"},
+	})
+	require.Equal(t, []uma.FieldError{
+		{Field: "postalAddress", Message: `"ZZ" is not an ISO 3166-1 alpha-2 country code`},
+	}, fieldErrors)
+}
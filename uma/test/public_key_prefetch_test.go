@@ -0,0 +1,129 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+// pubKeyRequestDoer serves public keys for every host except failingHost, and records the peak number of concurrent
+// requests. If overlap is set, each of the first expectedRequests requests waits until overlap requests are in
+// flight, or until no more are expected, so that the requests overlap.
+type pubKeyRequestDoer struct {
+	t                *testing.T
+	failingHost      string
+	overlap          int
+	expectedRequests int
+
+	mutex       sync.Mutex
+	inFlightSet *sync.Cond
+	requests    map[string]int
+	started     int
+	inFlight    int
+	maxInFlight int
+}
+
+func (d *pubKeyRequestDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mutex.Lock()
+	if d.inFlightSet == nil {
+		d.inFlightSet = sync.NewCond(&d.mutex)
+	}
+	d.requests[req.URL.Host]++
+	d.started++
+	d.inFlight++
+	if d.inFlight > d.maxInFlight {
+		d.maxInFlight = d.inFlight
+	}
+	d.inFlightSet.Broadcast()
+	for d.inFlight < d.overlap && d.started < d.expectedRequests {
+		d.inFlightSet.Wait()
+	}
+	d.mutex.Unlock()
+	defer func() {
+		d.mutex.Lock()
+		d.inFlight--
+		d.mutex.Unlock()
+	}()
+
+	if req.URL.Host == d.failingHost {
+		return nil, errors.New("connection refused")
+	}
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(d.t, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	body, err := json.Marshal(&pubKeyResponse)
+	require.NoError(d.t, err)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestPrefetchPublicKeys(t *testing.T) {
+	domains := []string{"vasp1.com", "vasp2.com", "vasp3.com", "vasp4.com", "vasp5.com", "down.com"}
+	doer := &pubKeyRequestDoer{
+		t:                t,
+		failingHost:      "down.com",
+		overlap:          2,
+		expectedRequests: len(domains),
+		requests:         map[string]int{},
+	}
+	cache := uma.NewInMemoryPublicKeyCache()
+
+	err := uma.PrefetchPublicKeys(context.Background(), domains, cache,
+		uma.WithRequestDoer(doer), uma.WithPrefetchParallelism(2))
+	require.ErrorContains(t, err, "down.com")
+	require.NotContains(t, err.Error(), "vasp1.com")
+	require.Equal(t, 2, doer.maxInFlight)
+	for _, domain := range domains[:5] {
+		require.NotNil(t, cache.FetchPublicKeyForVasp(domain))
+	}
+	require.Nil(t, cache.FetchPublicKeyForVasp("down.com"))
+
+	// Cached keys are not fetched again.
+	err = uma.PrefetchPublicKeys(context.Background(), domains[:5], cache, uma.WithRequestDoer(doer))
+	require.NoError(t, err)
+	for _, domain := range domains[:5] {
+		require.Equal(t, 1, doer.requests[domain])
+	}
+}
+
+func TestPrefetchPublicKeysCanceled(t *testing.T) {
+	doer := &pubKeyRequestDoer{t: t, requests: map[string]int{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := uma.PrefetchPublicKeys(ctx, []string{"vasp1.com", "vasp2.com"}, uma.NewInMemoryPublicKeyCache(),
+		uma.WithRequestDoer(doer), uma.WithPrefetchParallelism(1))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWarmPublicKeys(t *testing.T) {
+	doer := &pubKeyRequestDoer{t: t, requests: map[string]int{}}
+	cache := uma.NewInMemoryPublicKeyCache()
+	clock := umatest.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		uma.WarmPublicKeys(ctx, []string{"vasp1.com"}, cache, time.Hour,
+			uma.WithRequestDoer(doer), uma.WithClock(clock))
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return cache.FetchPublicKeyForVasp("vasp1.com") != nil }, time.Second, time.Millisecond)
+	// Keys removed from the cache are fetched again at the next interval.
+	cache.RemovePublicKeyForVasp("vasp1.com")
+	require.Eventually(t, func() bool {
+		clock.Advance(time.Hour)
+		return cache.FetchPublicKeyForVasp("vasp1.com") != nil
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
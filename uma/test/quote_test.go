@@ -0,0 +1,66 @@
+package uma_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+type fixedInvoiceCreator struct {
+	encodedInvoice string
+}
+
+func (f fixedInvoiceCreator) CreateInvoice(int64, string, *string) (*string, error) {
+	return &f.encodedInvoice, nil
+}
+
+func TestQuoteExpiryRoundTrip(t *testing.T) {
+	receiver := umatest.NewMockReceivingVasp()
+	defer receiver.Close()
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	quoteExpiresAt := time.Now().Add(5 * time.Minute)
+	receiver.Options = []uma.Option{uma.WithQuote("quote-1", quoteExpiresAt)}
+	receiver.InvoiceCreator = fixedInvoiceCreator{newTestBolt11Invoice(t, "lnbcrt1u", time.Now(), 120)}
+
+	response, err := sender.Pay(context.Background(), receiver.Address("bob"), 1000, "USD")
+	require.NoError(t, err)
+	require.Equal(t, "quote-1", *response.PaymentInfo.QuoteId)
+	require.Equal(t, quoteExpiresAt.Unix(), *response.PaymentInfo.ExpiresAt)
+
+	receiver.InvoiceCreator = fixedInvoiceCreator{newTestBolt11Invoice(t, "lnbcrt1u", time.Now(), 600)}
+	_, err = sender.Pay(context.Background(), receiver.Address("bob"), 1000, "USD")
+	require.ErrorContains(t, err, "after the quote expires")
+}
+
+func TestValidateQuoteExpiry(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	response := umaprotocol.PayReqResponse{
+		EncodedInvoice:  umatest.FakeInvoice,
+		PaymentInfo:     &umaprotocol.PayReqResponsePaymentInfo{CurrencyCode: "USD", Multiplier: 34_150, Decimals: 2},
+		UmaMajorVersion: 1,
+	}
+	// Responses without a quote expiry are not checked.
+	require.NoError(t, uma.ValidateQuoteExpiry(&response))
+
+	response.PaymentInfo.ExpiresAt = &expiresAt
+	require.ErrorContains(t, uma.ValidateQuoteExpiry(&response), "unable to decode invoice")
+
+	response.EncodedInvoice = newTestBolt11Invoice(t, "lnbcrt1u", time.Now(), 30)
+	require.NoError(t, uma.ValidateQuoteExpiry(&response))
+
+	expiredAt := time.Now().Add(-time.Second).Unix()
+	response.PaymentInfo.ExpiresAt = &expiredAt
+	require.ErrorContains(t, uma.ValidateQuoteExpiry(&response), "quote expired")
+
+	responseJson, err := response.MarshalJSON()
+	require.NoError(t, err)
+	parsedResponse, err := uma.ParsePayReqResponse(responseJson)
+	require.NoError(t, err)
+	require.Equal(t, expiredAt, *parsedResponse.PaymentInfo.ExpiresAt)
+}
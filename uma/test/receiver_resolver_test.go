@@ -0,0 +1,137 @@
+package uma_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestReceiverRoutingTable(t *testing.T) {
+	table := uma.NewReceiverRoutingTable()
+	require.NoError(t, table.AddRoute("$bob@vasp2.com", "account-bob"))
+	require.NoError(t, table.AddRoute("alice@*", "account-alice"))
+	require.NoError(t, table.AddRoute("*@vasp2.com", "user:*"))
+	require.NoError(t, table.AddAlias("$bobby@vasp2.com", "$bob@vasp2.com"))
+	require.NoError(t, table.AddAlias("brand.com", "vasp2.com"))
+	require.Error(t, table.AddRoute("*@*", "account"))
+	require.Error(t, table.AddAlias("brand.com", "$bob@vasp2.com"))
+
+	ctx := context.Background()
+	for address, expected := range map[string]string{
+		"$bob@vasp2.com":   "account-bob",
+		"$BOB@Vasp2.com":   "account-bob",
+		"bob@vasp2.com":    "account-bob",
+		"$bobby@vasp2.com": "account-bob",
+		"$bob@brand.com":   "account-bob",
+		"$alice@vasp3.com": "account-alice",
+		"$carol@vasp2.com": "user:carol",
+		"$carol@brand.com": "user:carol",
+	} {
+		accountId, err := table.ResolveReceiver(ctx, address)
+		require.NoError(t, err, address)
+		require.Equal(t, expected, accountId, address)
+	}
+	_, err := table.ResolveReceiver(ctx, "$carol@vasp3.com")
+	require.ErrorIs(t, err, uma.ErrReceiverNotFound)
+
+	require.NoError(t, table.AddAlias("loop1.com", "loop2.com"))
+	require.NoError(t, table.AddAlias("loop2.com", "loop1.com"))
+	_, err = table.ResolveReceiver(ctx, "$bob@loop1.com")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, uma.ErrReceiverNotFound))
+}
+
+func TestLnurlpRequestHandlerResolvesReceiver(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(senderPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	table := uma.NewReceiverRoutingTable()
+	var accountIds []string
+	handler := uma.NewLnurlpRequestHandler(pubKeyCache, getNonceCache(),
+		func(ctx context.Context, _ *umaprotocol.LnurlpRequest) (*umaprotocol.LnurlpResponse, error) {
+			accountId, ok := uma.ReceiverAccountIdFromContext(ctx)
+			require.True(t, ok)
+			accountIds = append(accountIds, accountId)
+			return &umaprotocol.LnurlpResponse{Tag: "payRequest", Callback: "https://vasp2.com/api/lnurl/payreq/$bob"}, nil
+		}, uma.WithReceiverResolver(table))
+
+	recorder := serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Empty(t, accountIds)
+
+	require.NoError(t, table.AddRoute("$bob@vasp2.com", "account-bob"))
+	recorder = serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, []string{"account-bob"}, accountIds)
+}
+
+type identifierRecordingInvoiceCreator struct {
+	receiverIdentifier *string
+}
+
+func (c *identifierRecordingInvoiceCreator) CreateInvoice(_ int64, _ string, receiverIdentifier *string) (*string, error) {
+	c.receiverIdentifier = receiverIdentifier
+	encodedInvoice := "lnbcrt100n1p0z9j"
+	return &encodedInvoice, nil
+}
+
+func TestPayeeIdentifierRewriter(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverPrivateKey, receiverSigner := createSigner(t)
+	payreq := createSignedPayRequest(t, senderPrivateKey)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}
+	invoiceCreator := &identifierRecordingInvoiceCreator{}
+	rewriter := func(_ context.Context, payeeIdentifier string, request *umaprotocol.PayRequest) (string, error) {
+		require.Equal(t, "$bob@vasp2.com", payeeIdentifier)
+		return "bob/" + *request.ReceivingCurrencyCode, nil
+	}
+
+	response, err := uma.GetPayReqResponseWithSigner(
+		*payreq,
+		invoiceCreator,
+		metadata,
+		currency,
+		24_150,
+		100_000,
+		[]string{"abcdef12345"},
+		"$bob@vasp2.com",
+		receiverSigner,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		uma.WithPayeeIdentifierRewriter(rewriter),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "bob/USD", *invoiceCreator.receiverIdentifier)
+	// The sender still sees, and verifies the signature for, the public address.
+	require.Equal(t, "$bob@vasp2.com", (*response.PayeeData)["identifier"])
+	err = uma.VerifyPayReqResponseSignature(
+		response,
+		getPubKeyResponse(receiverPrivateKey),
+		getNonceCache(),
+		"$alice@vasp1.com",
+		"$bob@vasp2.com",
+	)
+	require.NoError(t, err)
+
+	failingRewriter := func(context.Context, string, *umaprotocol.PayRequest) (string, error) {
+		return "", uma.ErrReceiverNotFound
+	}
+	_, err = uma.GetPayReqResponseWithSigner(*payreq, invoiceCreator, metadata, currency, 24_150, 100_000,
+		[]string{"abcdef12345"}, "$bob@vasp2.com", receiverSigner, nil, nil, nil, nil, nil,
+		uma.WithPayeeIdentifierRewriter(failingRewriter))
+	require.ErrorIs(t, err, uma.ErrReceiverNotFound)
+}
@@ -0,0 +1,201 @@
+package uma_test
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/store/redis"
+)
+
+// fakeRedisClient is an in-memory redis.Client.
+type fakeRedisClient struct {
+	mutex   sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	// scanCursors are the last keys scanned before each cursor returned by Scan.
+	scanCursors []string
+}
+
+func newFakeRedisClient() *fakeRedisClient {
+	return &fakeRedisClient{values: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+func (c *fakeRedisClient) expire(key string) {
+	if expiresAt, ok := c.expires[key]; ok && !time.Now().Before(expiresAt) {
+		delete(c.values, key)
+		delete(c.expires, key)
+	}
+}
+
+func (c *fakeRedisClient) SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	c.mutex.Lock()
+	c.expire(key)
+	_, exists := c.values[key]
+	c.mutex.Unlock()
+	if exists {
+		return false, nil
+	}
+	return true, c.Set(ctx, key, value, ttl)
+}
+
+func (c *fakeRedisClient) Set(_ context.Context, key string, value string, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[key] = value
+	delete(c.expires, key)
+	if ttl > 0 {
+		c.expires[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (c *fakeRedisClient) Get(_ context.Context, key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire(key)
+	value, ok := c.values[key]
+	if !ok {
+		return "", redis.ErrNil
+	}
+	return value, nil
+}
+
+func (c *fakeRedisClient) Del(_ context.Context, keys ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range keys {
+		delete(c.values, key)
+		delete(c.expires, key)
+	}
+	return nil
+}
+
+// Scan returns the matching keys in sorted order. Each cursor refers to the last key scanned before it, so that keys
+// deleted during the scan do not cause others to be skipped.
+func (c *fakeRedisClient) Scan(_ context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	allKeys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		if cursor == 0 || key > c.scanCursors[cursor-1] {
+			allKeys = append(allKeys, key)
+		}
+	}
+	sort.Strings(allKeys)
+	var keys []string
+	for i, key := range allKeys {
+		if int64(i) == count {
+			c.scanCursors = append(c.scanCursors, allKeys[i-1])
+			return keys, uint64(len(c.scanCursors)), nil
+		}
+		if matched, _ := path.Match(match, key); matched {
+			keys = append(keys, key)
+		}
+	}
+	return keys, 0, nil
+}
+
+// Eval runs the set-if-greater script used by redis.NonceCache, the only script used by the caches: it sets keys[0] to
+// args[0] unless it already holds a greater or equal integer.
+func (c *fakeRedisClient) Eval(_ context.Context, _ string, keys []string, args ...interface{}) (interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, err := strconv.ParseInt(args[0].(string), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if current, err := strconv.ParseInt(c.values[keys[0]], 10, 64); err != nil || value > current {
+		c.values[keys[0]] = args[0].(string)
+	}
+	return int64(0), nil
+}
+
+func TestRedisNonceCache(t *testing.T) {
+	client := newFakeRedisClient()
+	var cache uma.NonceCache = redis.NewNonceCache(client, "uma:nonce:", time.Hour)
+	otherInstanceCache := redis.NewNonceCache(client, "uma:nonce:", time.Hour)
+
+	require.NoError(t, cache.CheckAndSaveNonce("nonce1", time.Now()))
+	require.ErrorContains(t, otherInstanceCache.CheckAndSaveNonce("nonce1", time.Now()), "nonce already used")
+	require.ErrorContains(t, cache.CheckAndSaveNonce("nonce2", time.Now().Add(-2*time.Hour)), "timestamp too old")
+
+	cache.PurgeNoncesOlderThan(time.Now().Add(-time.Minute))
+	require.Error(t, cache.CheckAndSaveNonce("nonce3", time.Now().Add(-2*time.Minute)))
+	require.NoError(t, cache.CheckAndSaveNonce("nonce3", time.Now()))
+
+	// Nonces are kept until their signature is too old, so that they cannot be replayed once they expire.
+	signedAt := time.Now().Add(-30 * time.Second)
+	require.NoError(t, cache.CheckAndSaveNonce("nonce4", signedAt))
+	require.WithinDuration(t, signedAt.Add(time.Hour), client.expires["uma:nonce:nonce4"], time.Second)
+	require.ErrorContains(t, cache.CheckAndSaveNonce("nonce5", time.Now().Add(2*time.Hour)), "too far in the future")
+	require.NoError(t, cache.CheckAndSaveNonce("nonce5", time.Now().Add(time.Minute)))
+}
+
+func TestRedisNonceCacheSharesOldestValidTimestamp(t *testing.T) {
+	client := newFakeRedisClient()
+	cache := redis.NewNonceCache(client, "uma:nonce:", time.Hour)
+	otherInstanceCache := redis.NewNonceCache(client, "uma:nonce:", time.Hour)
+
+	cache.PurgeNoncesOlderThan(time.Now().Add(-time.Minute))
+	// The oldest valid timestamp applies to other instances, and to instances created after a restart.
+	require.ErrorContains(t, otherInstanceCache.CheckAndSaveNonce("nonce1", time.Now().Add(-2*time.Minute)),
+		"timestamp too old")
+	restartedCache := redis.NewNonceCache(client, "uma:nonce:", time.Hour)
+	require.ErrorContains(t, restartedCache.CheckAndSaveNonce("nonce1", time.Now().Add(-2*time.Minute)),
+		"timestamp too old")
+
+	// The oldest valid timestamp never moves back.
+	otherInstanceCache.PurgeNoncesOlderThan(time.Now().Add(-time.Hour))
+	require.ErrorContains(t, cache.CheckAndSaveNonce("nonce1", time.Now().Add(-2*time.Minute)), "timestamp too old")
+	require.NoError(t, cache.CheckAndSaveNonce("nonce1", time.Now()))
+}
+
+func TestRedisPublicKeyCache(t *testing.T) {
+	client := newFakeRedisClient()
+	var cache uma.PublicKeyCache = redis.NewPublicKeyCache(client, "uma:pubkey:", time.Hour)
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+
+	cache.AddPublicKeyForVasp("vasp2.com", &pubKeyResponse)
+	cachedPubKeyResponse := cache.FetchPublicKeyForVasp("vasp2.com")
+	require.NotNil(t, cachedPubKeyResponse)
+	signingKey, err := cachedPubKeyResponse.SigningPubKey()
+	require.NoError(t, err)
+	expectedSigningKey, err := pubKeyResponse.SigningPubKey()
+	require.NoError(t, err)
+	require.Equal(t, expectedSigningKey, signingKey)
+
+	expiration := time.Now().Add(time.Minute).Unix()
+	pubKeyResponse.ExpirationTimestamp = &expiration
+	cache.AddPublicKeyForVasp("vasp3.com", &pubKeyResponse)
+	require.NotNil(t, cache.FetchPublicKeyForVasp("vasp3.com"))
+	require.WithinDuration(t, time.Unix(expiration, 0), client.expires["uma:pubkey:vasp3.com"], time.Second)
+
+	// Without a positive TTL, keys are still refetched eventually.
+	redis.NewPublicKeyCache(client, "uma:pubkey:", 0).AddPublicKeyForVasp("vasp4.com", &pubKeyResponse)
+	require.WithinDuration(t, time.Unix(expiration, 0), client.expires["uma:pubkey:vasp4.com"], time.Second)
+	pubKeyResponse.ExpirationTimestamp = nil
+	redis.NewPublicKeyCache(client, "uma:pubkey:", 0).AddPublicKeyForVasp("vasp4.com", &pubKeyResponse)
+	require.WithinDuration(t, time.Now().Add(redis.DefaultPublicKeyCacheTtl), client.expires["uma:pubkey:vasp4.com"],
+		time.Second)
+
+	cache.RemovePublicKeyForVasp("vasp3.com")
+	require.Nil(t, cache.FetchPublicKeyForVasp("vasp3.com"))
+	for i := 0; i < 250; i++ {
+		cache.AddPublicKeyForVasp("vasp"+strconv.Itoa(i+4)+".com", &pubKeyResponse)
+	}
+	require.NoError(t, client.Set(context.Background(), "uma:nonce:nonce1", "1", 0))
+	cache.Clear()
+	require.Nil(t, cache.FetchPublicKeyForVasp("vasp2.com"))
+	require.Nil(t, cache.FetchPublicKeyForVasp("vasp253.com"))
+	require.Greater(t, len(client.scanCursors), 1)
+	_, err = client.Get(context.Background(), "uma:nonce:nonce1")
+	require.NoError(t, err)
+}
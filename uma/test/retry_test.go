@@ -0,0 +1,109 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func fastRetryPolicy() uma.RetryPolicy {
+	policy := uma.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestFetchPublicKeyRetriesTransientFailures(t *testing.T) {
+	privateKey, _ := createSigner(t)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		responseJson, _ := json.Marshal(&pubKeyResponse)
+		_, _ = w.Write(responseJson)
+	}))
+	defer server.Close()
+
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache())
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRetryPolicy(fastRetryPolicy()))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryDoesNotRetryClientErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRetryPolicy(fastRetryPolicy()))
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryBudgetLimitsRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	policy := fastRetryPolicy()
+	policy.MaxAttempts = 10
+	policy.Budget = uma.NewRetryBudget(2, 0)
+	domain := strings.TrimPrefix(server.URL, "http://")
+	_, err := uma.FetchPublicKeyForVasp(domain, uma.NewInMemoryPublicKeyCache(), uma.WithRetryPolicy(policy))
+	require.Error(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryResignsLnurlpRequest(t *testing.T) {
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.URL.Query().Get("nonce"))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+
+	_, err = uma.SendLnurlpRequest(context.Background(), *request, uma.WithRetryPolicy(fastRetryPolicy()))
+	require.Error(t, err)
+	require.Len(t, nonces, 3)
+	require.Equal(t, nonces[0], nonces[1])
+
+	nonces = nil
+	policy := fastRetryPolicy()
+	policy.ResignWith = signer
+	_, err = uma.SendLnurlpRequest(context.Background(), *request, uma.WithRetryPolicy(policy))
+	require.Error(t, err)
+	require.Len(t, nonces, 3)
+	require.NotEqual(t, nonces[0], nonces[1])
+}
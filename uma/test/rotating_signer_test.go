@@ -0,0 +1,91 @@
+package uma_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func createRotatingSigner(t *testing.T, switchAt time.Time, oldKeyExpiresAt time.Time) (*uma.RotatingSigner, uma.RotatingSigningKey, uma.RotatingSigningKey) {
+	oldPrivateKey, oldSigner := createSigner(t)
+	newPrivateKey, newSigner := createSigner(t)
+	oldKey := uma.RotatingSigningKey{KeyId: "key-1", Signer: oldSigner, PubKey: oldPrivateKey.PubKey().SerializeUncompressed()}
+	newKey := uma.RotatingSigningKey{KeyId: "key-2", Signer: newSigner, PubKey: newPrivateKey.PubKey().SerializeUncompressed()}
+	signer, err := uma.NewRotatingSigner(oldKey, newKey, switchAt, oldKeyExpiresAt)
+	require.NoError(t, err)
+	return signer, oldKey, newKey
+}
+
+// verifyRotatingSignature signs a request with the RotatingSigner and returns the ID of the key which verified it.
+func verifyRotatingSignature(t *testing.T, signer uma.UmaSigner, pubKeyResponse umaprotocol.PubKeyResponse) (string, error) {
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	var matchedKeyId string
+	err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), pubKeyResponse, getNonceCache(),
+		uma.WithSigningKeyMatchHandler(func(keyId string) { matchedKeyId = keyId }))
+	return matchedKeyId, err
+}
+
+func TestRotatingSignerPhases(t *testing.T) {
+	now := time.Now()
+	encryptionPrivateKey, _ := createSigner(t)
+	encryptionPubKey := encryptionPrivateKey.PubKey().SerializeUncompressed()
+
+	// Before the switch, the old key signs and both keys are advertised.
+	signer, oldKey, newKey := createRotatingSigner(t, now.Add(time.Hour), now.Add(2*time.Hour))
+	require.Equal(t, "key-1", signer.CurrentKeyId())
+	pubKeyResponse := signer.GetPubKeyResponse(encryptionPubKey)
+	require.Equal(t, "key-1", *pubKeyResponse.SigningKeyId)
+	require.Equal(t, now.Add(time.Hour).Unix(), *pubKeyResponse.ExpirationTimestamp)
+	require.Len(t, pubKeyResponse.SigningKeys, 1)
+	require.Equal(t, "key-2", pubKeyResponse.SigningKeys[0].KeyId)
+	keyId, err := verifyRotatingSignature(t, signer, *pubKeyResponse)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", keyId)
+	// Signatures by the new key would already be accepted.
+	keyId, err = verifyRotatingSignature(t, newKey.Signer, *pubKeyResponse)
+	require.NoError(t, err)
+	require.Equal(t, "key-2", keyId)
+
+	// After the switch, the new key signs and the old key is still advertised until it expires.
+	signer, oldKey, _ = createRotatingSigner(t, now.Add(-time.Hour), now.Add(time.Hour))
+	require.Equal(t, "key-2", signer.CurrentKeyId())
+	pubKeyResponse = signer.GetPubKeyResponse(encryptionPubKey)
+	require.Equal(t, "key-2", *pubKeyResponse.SigningKeyId)
+	require.Equal(t, now.Add(time.Hour).Unix(), *pubKeyResponse.ExpirationTimestamp)
+	require.Len(t, pubKeyResponse.SigningKeys, 1)
+	require.Equal(t, now.Add(time.Hour).Unix(), *pubKeyResponse.SigningKeys[0].ExpirationTimestamp)
+	keyId, err = verifyRotatingSignature(t, signer, *pubKeyResponse)
+	require.NoError(t, err)
+	require.Equal(t, "key-2", keyId)
+	keyId, err = verifyRotatingSignature(t, oldKey.Signer, *pubKeyResponse)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", keyId)
+
+	// Once the old key has expired, only the new key is advertised.
+	signer, oldKey, _ = createRotatingSigner(t, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	pubKeyResponse = signer.GetPubKeyResponse(encryptionPubKey)
+	require.Equal(t, "key-2", *pubKeyResponse.SigningKeyId)
+	require.Nil(t, pubKeyResponse.ExpirationTimestamp)
+	require.Empty(t, pubKeyResponse.SigningKeys)
+	_, err = verifyRotatingSignature(t, oldKey.Signer, *pubKeyResponse)
+	require.Error(t, err)
+}
+
+func TestNewRotatingSignerValidation(t *testing.T) {
+	now := time.Now()
+	_, oldKey, newKey := createRotatingSigner(t, now, now.Add(time.Hour))
+	_, err := uma.NewRotatingSigner(oldKey, newKey, now, now)
+	require.Error(t, err)
+	_, err = uma.NewRotatingSigner(oldKey, oldKey, now, now.Add(time.Hour))
+	require.Error(t, err)
+	_, err = uma.NewRotatingSigner(oldKey, uma.RotatingSigningKey{KeyId: "key-3"}, now, now.Add(time.Hour))
+	require.Error(t, err)
+}
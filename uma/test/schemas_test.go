@@ -0,0 +1,91 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/schemas"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func newSchemaTestPayRequest(kycStatus string) umaprotocol.PayRequest {
+	currencyCode := "USD"
+	return umaprotocol.PayRequest{
+		SendingAmountCurrencyCode: &currencyCode,
+		ReceivingCurrencyCode:     &currencyCode,
+		Amount:                    1000,
+		PayerData: &umaprotocol.PayerData{
+			"identifier": "$alice@vasp1.com",
+			"compliance": map[string]interface{}{
+				"kycStatus":          kycStatus,
+				"signature":          "signature",
+				"signatureNonce":     "12345",
+				"signatureTimestamp": 1_700_000_000,
+				"utxoCallback":       "https://vasp1.com/api/lnurl/utxocallback",
+			},
+		},
+		UmaMajorVersion: 1,
+	}
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	schema, err := schemas.Generate(umaprotocol.PayRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "PayRequest", schema.Title)
+	require.Contains(t, schema.Defs, "CompliancePayerData")
+
+	request := newSchemaTestPayRequest("VERIFIED")
+	requestJson, err := json.Marshal(&request)
+	require.NoError(t, err)
+	require.NoError(t, schemas.ValidateAgainstSchema(schema, requestJson))
+
+	request = newSchemaTestPayRequest("MAYBE")
+	requestJson, err = json.Marshal(&request)
+	require.NoError(t, err)
+	err = schemas.ValidateAgainstSchema(schema, requestJson)
+	var validationErr *schemas.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "/payerData/compliance/kycStatus", validationErr.Path)
+
+	err = schemas.ValidateAgainstSchema(schema, []byte(`{"convert":"USD"}`))
+	require.ErrorAs(t, err, &validationErr)
+	require.Contains(t, validationErr.Message, `"amount"`)
+	err = schemas.ValidateAgainstSchema(schema, []byte(`{"amount":"ten"}`))
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "/amount", validationErr.Path)
+}
+
+func TestValidateAgainstSchemaRoundTrip(t *testing.T) {
+	pubKeyResponse := umatest.SenderKeypair().PubKeyResponse()
+	pubKeyResponseJson, err := json.Marshal(&pubKeyResponse)
+	require.NoError(t, err)
+	schema, err := schemas.Generate(&pubKeyResponse)
+	require.NoError(t, err)
+
+	// Schemas can be published as JSON and loaded by other tools.
+	schemaJson, err := json.Marshal(schema)
+	require.NoError(t, err)
+	var parsedSchema schemas.Schema
+	require.NoError(t, json.Unmarshal(schemaJson, &parsedSchema))
+	require.NoError(t, schemas.ValidateAgainstSchema(&parsedSchema, pubKeyResponseJson))
+	require.Error(t, schemas.ValidateAgainstSchema(&parsedSchema, []byte(`{"signingPubKey":1}`)))
+}
+
+func TestAllSchemas(t *testing.T) {
+	allSchemas, err := schemas.All()
+	require.NoError(t, err)
+	require.Len(t, allSchemas, len(schemas.Messages()))
+	for name, schema := range allSchemas {
+		require.Equal(t, name, schema.Title)
+		require.Equal(t, schemas.Draft, schema.Schema)
+	}
+
+	lnurlpResponse := allSchemas["LnurlpResponse"]
+	require.ElementsMatch(t, []string{"tag", "callback", "minSendable", "maxSendable", "metadata"}, lnurlpResponse.Required)
+	webhook := allSchemas["PaymentStatusWebhook"]
+	require.NoError(t, schemas.ValidateAgainstSchema(webhook, []byte(
+		`{"invoice":"lnbc1","status":"PAID","vaspDomain":"vasp1.com","signature":"sig","signatureNonce":"1","signatureTimestamp":1}`,
+	)))
+}
@@ -0,0 +1,150 @@
+package uma_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+const lnurlMetadata = `[["text/plain","Pay to carol"],["text/identifier","carol@lnurl.com"]]`
+
+// serveLnurlPay serves a plain LNURL-pay callback which responds with the given invoice, and returns its lnurlp
+// response.
+func serveLnurlPay(t *testing.T, invoice string) (*umaprotocol.LnurlpResponse, *[]string) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"pr": invoice, "routes": []string{}}))
+	}))
+	t.Cleanup(server.Close)
+	commentChars := 10
+	return &umaprotocol.LnurlpResponse{
+		Tag:                 "payRequest",
+		Callback:            server.URL + "/callback",
+		MinSendable:         1_000,
+		MaxSendable:         10_000_000,
+		EncodedMetadata:     lnurlMetadata,
+		CommentCharsAllowed: &commentChars,
+	}, &queries
+}
+
+func TestSendPaymentNonUmaFallback(t *testing.T) {
+	metadataHash := sha256.Sum256([]byte(lnurlMetadata))
+	lnurlpResponse, queries := serveLnurlPay(t, newTestLnurlInvoice(t, "lnbcrt10u", metadataHash[:]))
+	ctx := context.Background()
+	comment := "thanks!"
+	params := uma.SendPaymentParams{AmountMsats: 1_000_000, Comment: &comment}
+
+	_, err := uma.SendPayment(ctx, lnurlpResponse, params)
+	require.ErrorIs(t, err, uma.ErrNotUmaReceiver)
+	require.Empty(t, *queries)
+
+	result, err := uma.SendPayment(ctx, lnurlpResponse, params, uma.AllowNonUmaFallback(), regtest)
+	require.NoError(t, err)
+	lnurlResult, ok := result.(*uma.LnurlPaymentResult)
+	require.True(t, ok)
+	require.Equal(t, int64(1_000_000), lnurlResult.AmountMsats)
+	require.Nil(t, lnurlResult.PayReqResponse.PayeeData)
+	require.Equal(t, []string{"amount=1000000&comment=thanks%21"}, *queries)
+
+	params.AmountMsats = 20_000_000
+	_, err = uma.SendPayment(ctx, lnurlpResponse, params, uma.AllowNonUmaFallback(), regtest)
+	require.ErrorContains(t, err, "outside the sendable range")
+
+	longComment := "a much longer comment"
+	params = uma.SendPaymentParams{AmountMsats: 1_000_000, Comment: &longComment}
+	_, err = uma.SendPayment(ctx, lnurlpResponse, params, uma.AllowNonUmaFallback(), regtest)
+	require.ErrorContains(t, err, "comment")
+	require.Len(t, *queries, 1)
+}
+
+func TestSendPaymentNonUmaFallbackRejectsInvalidInvoices(t *testing.T) {
+	ctx := context.Background()
+	metadataHash := sha256.Sum256([]byte(lnurlMetadata))
+	otherHash := sha256.Sum256([]byte("other metadata"))
+	for name, invoice := range map[string]string{
+		"wrong amount":           newTestLnurlInvoice(t, "lnbcrt20u", metadataHash[:]),
+		"wrong description hash": newTestLnurlInvoice(t, "lnbcrt10u", otherHash[:]),
+	} {
+		lnurlpResponse, _ := serveLnurlPay(t, invoice)
+		_, err := uma.SendPayment(ctx, lnurlpResponse, uma.SendPaymentParams{AmountMsats: 1_000_000},
+			uma.AllowNonUmaFallback(), regtest)
+		require.Error(t, err, name)
+	}
+}
+
+func TestSendPaymentUma(t *testing.T) {
+	payreq, payreqResponse, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(payreqResponse))
+	}))
+	defer server.Close()
+
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	lnurlpResponse, err := uma.GetLnurlpResponseWithSigner(
+		*createSignedLnurlpRequest(t, senderPrivateKey),
+		server.URL+"/payreq",
+		metadata,
+		[]umaprotocol.Currency{{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}},
+		umaprotocol.CounterPartyDataOptions{"compliance": {Mandatory: true}},
+		umaprotocol.KycStatusVerified,
+		receiverSigner,
+		true,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+
+	result, err := uma.SendPayment(context.Background(), lnurlpResponse, uma.SendPaymentParams{
+		CreateUmaPayRequest: func(_ context.Context, response umaprotocol.UmaLnurlpResponse) (*umaprotocol.PayRequest, error) {
+			require.Equal(t, "$bob@vasp2.com", response.Compliance.ReceiverIdentifier)
+			return payreq, nil
+		},
+	})
+	require.NoError(t, err)
+	umaResult, ok := result.(*uma.UmaPaymentResult)
+	require.True(t, ok)
+	require.Equal(t, payreq, umaResult.PayRequest)
+	require.Equal(t, payreqResponse.EncodedInvoice, umaResult.PayReqResponse.EncodedInvoice)
+}
+
+func TestValidatePayAmount(t *testing.T) {
+	response := umaprotocol.LnurlpResponse{
+		MinSendable: 1_000,
+		MaxSendable: 1_000_000_000,
+		Currencies: &[]umaprotocol.Currency{
+			{Code: "USD", MillisatoshiPerUnit: 24_150, Decimals: 2,
+				Convertible: umaprotocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10_000}},
+			{Code: "EUR", MillisatoshiPerUnit: 26_000, Decimals: 2},
+		},
+	}
+	require.NoError(t, uma.ValidatePayAmount(response, 10_000, "USD", true))
+	require.NoError(t, uma.ValidatePayAmount(response, 1_000, "USD", false))
+	// EUR does not advertise a convertible range.
+	require.NoError(t, uma.ValidatePayAmount(response, 1_000_000, "EUR", true))
+
+	var outOfRangeError uma.AmountOutOfRangeError
+	require.ErrorAs(t, uma.ValidatePayAmount(response, 10_001, "USD", true), &outOfRangeError)
+	require.Equal(t, uma.AmountOutOfRangeError{Amount: 10_001, CurrencyCode: "USD", Min: 1, Max: 10_000}, outOfRangeError)
+	require.ErrorAs(t, uma.ValidatePayAmount(response, 999, "USD", false), &outOfRangeError)
+	require.Equal(t, uma.AmountOutOfRangeError{Amount: 999, Min: 1_000, Max: 1_000_000_000}, outOfRangeError)
+
+	var unsupportedCurrencyError uma.UnsupportedCurrencyError
+	require.ErrorAs(t, uma.ValidatePayAmount(response, 100, "GBP", true), &unsupportedCurrencyError)
+	require.Equal(t, "GBP", unsupportedCurrencyError.CurrencyCode)
+}
@@ -0,0 +1,90 @@
+package uma_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestVerifyPayReqSignatureChecksPayerIdentifierDomain(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+
+	payreq := createSignedPayRequest(t, privateKey)
+	require.NoError(t, uma.VerifyPayReqSignature(payreq, pubKeyResponse, getNonceCache(), uma.WithSenderVaspDomain("VASP1.com")))
+
+	// vasp3.com signed a pay request for a payer of vasp1.com.
+	err = uma.VerifyPayReqSignature(payreq, pubKeyResponse, getNonceCache(), uma.WithSenderVaspDomain("vasp3.com"))
+	var mismatchErr uma.PayerIdentifierDomainMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	require.Equal(t, "$alice@vasp1.com", mismatchErr.PayerIdentifier)
+	require.Equal(t, "vasp3.com", mismatchErr.SenderVaspDomain)
+
+	// Intermediaries can opt out of the check.
+	require.NoError(t, uma.VerifyPayReqSignature(payreq, pubKeyResponse, getNonceCache(),
+		uma.WithSenderVaspDomain("vasp3.com"), uma.WithoutPayerIdentifierDomainCheck()))
+	// Without the sender VASP domain, the payer identifier must still be an UMA address.
+	require.NoError(t, uma.VerifyPayReqSignature(payreq, pubKeyResponse, getNonceCache()))
+	nonUmaPayreq := signPayRequestForPayer(t, payreq, privateKey, "vasp1.com")
+	err = uma.VerifyPayReqSignature(nonUmaPayreq, pubKeyResponse, getNonceCache())
+	require.ErrorAs(t, err, &mismatchErr)
+	require.Equal(t, "payer identifier vasp1.com is not an UMA address", err.Error())
+	require.NoError(t, uma.VerifyPayReqSignature(nonUmaPayreq, pubKeyResponse, getNonceCache(),
+		uma.WithoutPayerIdentifierDomainCheck()))
+
+	// The signature is checked first.
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	err = uma.VerifyPayReqSignature(payreq, getPubKeyResponse(otherPrivateKey), getNonceCache(),
+		uma.WithSenderVaspDomain("vasp3.com"))
+	require.Error(t, err)
+	require.False(t, errors.As(err, &mismatchErr))
+}
+
+// signPayRequestForPayer returns a copy of a pay request with another payer identifier, signed again.
+func signPayRequestForPayer(
+	t *testing.T,
+	payreq *umaprotocol.PayRequest,
+	privateKey *secp256k1.PrivateKey,
+	payerIdentifier string,
+) *umaprotocol.PayRequest {
+	payerData := umaprotocol.PayerData{}
+	for key, value := range *payreq.PayerData {
+		payerData[key] = value
+	}
+	payerData[umaprotocol.CounterPartyDataFieldIdentifier.String()] = payerIdentifier
+	unsignedPayreq := *payreq
+	unsignedPayreq.PayerData = &payerData
+	signer, err := uma.NewInMemorySigner(privateKey.Serialize())
+	require.NoError(t, err)
+	signedPayreq, err := uma.SignPayRequest(unsignedPayreq, signer)
+	require.NoError(t, err)
+	return signedPayreq
+}
+
+func TestVerifierChecksPayerIdentifierDomain(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+
+	// The keys are fetched from the payer identifier, which must be an UMA address of that domain.
+	payreq := signPayRequestForPayer(t, createSignedPayRequest(t, privateKey), privateKey, "vasp1.com")
+	var mismatchErr uma.PayerIdentifierDomainMismatchError
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache())
+	require.ErrorAs(t, verifier.VerifyPayRequest(context.Background(), payreq), &mismatchErr)
+	require.Equal(t, "vasp1.com", mismatchErr.SenderVaspDomain)
+	verifier = uma.NewVerifier(pubKeyCache, getNonceCache())
+	errs := verifier.VerifyBatch(context.Background(), []uma.SignedMessage{uma.PayRequestMessage(payreq)})
+	require.ErrorAs(t, errs[0], &mismatchErr)
+
+	verifier = uma.NewVerifier(pubKeyCache, getNonceCache(), uma.WithoutPayerIdentifierDomainCheck())
+	require.NoError(t, verifier.VerifyPayRequest(context.Background(), payreq))
+}
@@ -0,0 +1,84 @@
+package uma_test
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func TestSettlementAccountValidation(t *testing.T) {
+	iban := "DE89370400440532013000"
+	clearingSystem := "SEPA"
+	account := umaprotocol.SettlementAccount{Iban: &iban, ClearingSystem: &clearingSystem}
+	require.NoError(t, account.Validate())
+	require.Equal(t, "******************3000", account.MaskedAccountNumber())
+
+	badIban := "DE89370400440532013001"
+	account.Iban = &badIban
+	err := account.Validate()
+	require.ErrorContains(t, err, "is not a valid IBAN")
+	require.NotContains(t, err.Error(), "0532013001")
+
+	require.True(t, umaprotocol.IsValidIban("GB82WEST12345698765432"))
+	require.False(t, umaprotocol.IsValidIban("gb82west12345698765432"))
+	require.False(t, umaprotocol.IsValidIban("GB82 WEST 1234 5698 7654 32"))
+	require.False(t, umaprotocol.IsValidIban("XX82WEST12345698765432"))
+
+	accountNumber := "123456789"
+	routingNumber := "021000021"
+	account = umaprotocol.SettlementAccount{AccountNumber: &accountNumber, ClearingCode: &routingNumber}
+	require.NoError(t, account.Validate())
+	require.Equal(t, "*****6789", account.MaskedAccountNumber())
+	require.Equal(t, "***", umaprotocol.MaskAccountNumber("123"))
+	require.Error(t, (&umaprotocol.SettlementAccount{ClearingCode: &routingNumber}).Validate())
+
+	accountMap, err := account.AsMap()
+	require.NoError(t, err)
+	payeeData := umaprotocol.PayeeData{"settlementAccount": accountMap}
+	parsedAccount, err := payeeData.SettlementAccount()
+	require.NoError(t, err)
+	require.Equal(t, account, *parsedAccount)
+
+	options := umaprotocol.CounterPartyDataOptions{"settlementAccount": {Mandatory: true}}
+	require.Nil(t, uma.ValidateCounterPartyData(options, payeeData))
+	fieldErrors := uma.ValidateCounterPartyData(options, map[string]interface{}{
+		"settlementAccount": map[string]interface{}{"clearingSystem": "ACH"},
+	})
+	require.Equal(t, []uma.FieldError{
+		{Field: "settlementAccount", Message: "settlement account has neither an account number nor an IBAN"},
+	}, fieldErrors)
+}
+
+func TestEncryptedSettlementAccount(t *testing.T) {
+	senderEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	iban := "GB82WEST12345698765432"
+	clearingCode := "WEST"
+	account := umaprotocol.SettlementAccount{Iban: &iban, ClearingCode: &clearingCode}
+	accountMap, err := account.AsMap()
+	require.NoError(t, err)
+
+	payeeData := umaprotocol.PayeeData{"identifier": "$bob@vasp2.com", "settlementAccount": accountMap}
+	err = uma.AddEncryptedSettlementAccount(
+		payeeData, account, senderEncryptionPrivateKey.PubKey().SerializeUncompressed())
+	require.NoError(t, err)
+	require.NotContains(t, payeeData, "settlementAccount")
+	require.NotNil(t, payeeData.EncryptedSettlementAccount())
+	require.NotContains(t, *payeeData.EncryptedSettlementAccount(), iban)
+
+	decryptedAccount, err := uma.DecryptSettlementAccount(payeeData, senderEncryptionPrivateKey.Serialize())
+	require.NoError(t, err)
+	require.Equal(t, account, *decryptedAccount)
+
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, err = uma.DecryptSettlementAccount(payeeData, otherPrivateKey.Serialize())
+	require.Error(t, err)
+
+	noAccount, err := uma.DecryptSettlementAccount(umaprotocol.PayeeData{}, senderEncryptionPrivateKey.Serialize())
+	require.NoError(t, err)
+	require.Nil(t, noAccount)
+}
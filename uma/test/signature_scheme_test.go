@@ -0,0 +1,93 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+func TestCanonicalizeJson(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`{ "b": 1, "a": [true, null, "x"] }`, `{"a":[true,null,"x"],"b":1}`},
+		{`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000001,1e-7,1e21,-0]}`,
+			`{"numbers":[333333333.3333333,1e+30,4.5,0.002,0.000001,1e-7,1e+21,0]}`},
+		{`{"string":"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/"}`, `{"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		// Keys are sorted by UTF-16 code units, so the emoji sorts before U+FB33.
+		{`{"\ufb33":1,"\ud83d\ude00":2,"\u00e9":3,"1":4}`, "{\"1\":4,\"\u00e9\":3,\"\U0001f600\":2,\"\ufb33\":1}"},
+	}
+	for _, testCase := range testCases {
+		canonical, err := utils.CanonicalizeJson([]byte(testCase.input))
+		require.NoError(t, err)
+		require.Equal(t, testCase.expected, string(canonical))
+	}
+
+	_, err := utils.CanonicalizeJson([]byte(`{"a":1} {"b":2}`))
+	require.Error(t, err)
+}
+
+func TestJcsSignedPaymentStatusWebhook(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	failureReason := "invoice expired"
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusExpired,
+		FailureReason:  &failureReason,
+		VaspDomain:     "vasp1.com",
+	}, signer, uma.WithSignatureScheme(uma.SignatureSchemeJcs))
+	require.NoError(t, err)
+
+	// Both schemes are accepted by default during the migration window.
+	err = uma.VerifyPaymentStatusWebhookSignature(webhook, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+	err = uma.VerifyPaymentStatusWebhookSignature(webhook, getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithAcceptedSignatureSchemes(uma.SignatureSchemeDelimited))
+	require.Error(t, err)
+
+	// Unlike the delimited scheme, the JCS scheme also covers the failure reason.
+	tamperedReason := "payment declined"
+	tampered := *webhook
+	tampered.FailureReason = &tamperedReason
+	err = uma.VerifyPaymentStatusWebhookSignature(&tampered, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+}
+
+func TestJcsSignedPayRequest(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	payreq, err := uma.SignPayRequest(*createSignedPayRequest(t, privateKey), signer,
+		uma.WithSignatureScheme(uma.SignatureSchemeJcs))
+	require.NoError(t, err)
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	parsedPayreq, err := uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	err = uma.VerifyPayReqSignature(parsedPayreq, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	parsedPayreq.Amount = 2000
+	err = uma.VerifyPayReqSignature(parsedPayreq, getPubKeyResponse(privateKey), getNonceCache())
+	require.Error(t, err)
+}
+
+func TestAcceptedSignatureSchemes(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusPaid,
+		VaspDomain:     "vasp1.com",
+	}, signer)
+	require.NoError(t, err)
+	err = uma.VerifyPaymentStatusWebhookSignature(webhook, getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithAcceptedSignatureSchemes(uma.SignatureSchemeJcs))
+	require.Error(t, err)
+
+	capabilities := uma.CounterpartyCapabilities{Features: []uma.CounterpartyFeature{uma.FeatureJcsSignatures}}
+	require.Equal(t, uma.SignatureSchemeJcs, capabilities.PreferredSignatureScheme())
+}
@@ -0,0 +1,194 @@
+package uma_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// acceptingNonceCache is a NonceCache which accepts every nonce, so that benchmarks can verify the same message
+// repeatedly.
+type acceptingNonceCache struct{}
+
+func (acceptingNonceCache) CheckAndSaveNonce(string, time.Time) error { return nil }
+
+func (acceptingNonceCache) PurgeNoncesOlderThan(time.Time) {}
+
+// countingSignatureVerifier wraps the default verifier, counting calls and optionally rejecting every signature.
+type countingSignatureVerifier struct {
+	calls     int32
+	rejectAll bool
+}
+
+func (v *countingSignatureVerifier) Verify(hash [sha256.Size]byte, derSignature []byte, publicKey []byte) (bool, error) {
+	atomic.AddInt32(&v.calls, 1)
+	if v.rejectAll {
+		return false, nil
+	}
+	return uma.DefaultSignatureVerifier.Verify(hash, derSignature, publicKey)
+}
+
+func createSignedCallback(tb testing.TB) (*umaprotocol.PostTransactionCallback, umaprotocol.PubKeyResponse) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	callback, err := uma.GetPostTransactionCallback(
+		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		"vasp1.com",
+		createPrivateKeyHandle(tb, privateKey),
+	)
+	require.NoError(tb, err)
+	return callback, getPubKeyResponse(privateKey)
+}
+
+func TestWithSignatureVerifier(t *testing.T) {
+	callback, pubKeyResponse := createSignedCallback(t)
+
+	verifier := &countingSignatureVerifier{}
+	err := uma.VerifyPostTransactionCallbackSignature(
+		callback, pubKeyResponse, getNonceCache(), uma.WithSignatureVerifier(verifier))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), verifier.calls)
+
+	rejectingVerifier := &countingSignatureVerifier{rejectAll: true}
+	err = uma.VerifyPostTransactionCallbackSignature(
+		callback, pubKeyResponse, getNonceCache(), uma.WithSignatureVerifier(rejectingVerifier))
+	require.ErrorContains(t, err, "invalid uma signature")
+	require.Equal(t, int32(1), rejectingVerifier.calls)
+}
+
+func TestVerifySignatureRequiresNonceCache(t *testing.T) {
+	callback, pubKeyResponse := createSignedCallback(t)
+	err := uma.VerifyPostTransactionCallbackSignature(callback, pubKeyResponse, nil)
+	require.ErrorIs(t, err, uma.ErrNonceCacheRequired)
+
+	payreq, _, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	err = uma.VerifyPayReqSignature(payreq, pubKeyResponse, nil)
+	require.ErrorIs(t, err, uma.ErrNonceCacheRequired)
+}
+
+func TestVerifySignatureRejectsMalformedSignatures(t *testing.T) {
+	callback, pubKeyResponse := createSignedCallback(t)
+
+	tooLong := *callback
+	tooLongSignature := *callback.Signature + "00"
+	tooLong.Signature = &tooLongSignature
+	err := uma.VerifyPostTransactionCallbackSignature(&tooLong, pubKeyResponse, getNonceCache())
+	require.Error(t, err)
+
+	notHex := *callback
+	notHexSignature := "zz" + (*callback.Signature)[2:]
+	notHex.Signature = &notHexSignature
+	err = uma.VerifyPostTransactionCallbackSignature(&notHex, pubKeyResponse, getNonceCache())
+	require.Error(t, err)
+}
+
+// encodeDerSignature encodes R and S as a DER signature, with minimally encoded integers.
+func encodeDerSignature(r []byte, s []byte) []byte {
+	integer := func(value []byte) []byte {
+		value = bytes.TrimLeft(value, "\x00")
+		if value[0]&0x80 != 0 {
+			value = append([]byte{0}, value...)
+		}
+		return append([]byte{0x02, byte(len(value))}, value...)
+	}
+	body := append(integer(r), integer(s)...)
+	return append([]byte{0x30, byte(len(body))}, body...)
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	callback, pubKeyResponse := createSignedCallback(t)
+	signature, err := hex.DecodeString(*callback.Signature)
+	require.NoError(t, err)
+	rLength := int(signature[3])
+	r := signature[4 : 4+rLength]
+	s := signature[6+rLength:]
+	withSignature := func(signature []byte) *umaprotocol.PostTransactionCallback {
+		signatureHex := hex.EncodeToString(signature)
+		modified := *callback
+		modified.Signature = &signatureHex
+		return &modified
+	}
+
+	// The SDK signs with a low S value, which is accepted under every encoding.
+	for _, encoding := range []uma.SignatureEncoding{
+		uma.SignatureEncodingStrictDer, uma.SignatureEncodingLowS, uma.SignatureEncodingLenient,
+	} {
+		err = uma.VerifyPostTransactionCallbackSignature(
+			callback, pubKeyResponse, getNonceCache(), uma.WithSignatureEncoding(encoding))
+		require.NoError(t, err)
+	}
+
+	// (R, N-S) is also a valid signature, but it is malleable.
+	var sScalar secp256k1.ModNScalar
+	sScalar.SetByteSlice(s)
+	highS := sScalar.Negate().Bytes()
+	highSCallback := withSignature(encodeDerSignature(r, highS[:]))
+	err = uma.VerifyPostTransactionCallbackSignature(highSCallback, pubKeyResponse, getNonceCache())
+	require.NoError(t, err)
+	err = uma.VerifyPostTransactionCallbackSignature(
+		highSCallback, pubKeyResponse, getNonceCache(), uma.WithSignatureEncoding(uma.SignatureEncodingLowS))
+	require.ErrorContains(t, err, "S value is not low")
+
+	// A zero-padded R is rejected before it reaches the SignatureVerifier, unless the encoding is lenient.
+	paddedR := append([]byte{0x02, byte(len(r) + 1), 0}, r...)
+	body := append(paddedR, signature[4+rLength:]...)
+	paddedCallback := withSignature(append([]byte{0x30, byte(len(body))}, body...))
+	verifier := &countingSignatureVerifier{}
+	err = uma.VerifyPostTransactionCallbackSignature(
+		paddedCallback, pubKeyResponse, getNonceCache(), uma.WithSignatureVerifier(verifier))
+	require.ErrorContains(t, err, "not minimally encoded")
+	require.Equal(t, int32(0), verifier.calls)
+	err = uma.VerifyPostTransactionCallbackSignature(paddedCallback, pubKeyResponse, getNonceCache(),
+		uma.WithSignatureVerifier(verifier), uma.WithSignatureEncoding(uma.SignatureEncodingLenient))
+	require.Error(t, err)
+	require.Equal(t, int32(1), verifier.calls)
+
+	trailingCallback := withSignature(append(append([]byte{}, signature...), 0))
+	err = uma.VerifyPostTransactionCallbackSignature(trailingCallback, pubKeyResponse, getNonceCache())
+	require.Error(t, err)
+}
+
+// createSignedHash signs a payload, and returns its hash, the DER signature and the public key of the signer.
+func createSignedHash(tb testing.TB) ([sha256.Size]byte, []byte, []byte) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(tb, err)
+	signer, err := uma.NewInMemorySigner(privateKey.Serialize())
+	require.NoError(tb, err)
+	payload := []byte("$alice@vasp1.com|1234|1700000000")
+	signature, err := signer.Sign(payload)
+	require.NoError(tb, err)
+	return sha256.Sum256(payload), signature, privateKey.PubKey().SerializeUncompressed()
+}
+
+func BenchmarkDecredSignatureVerifier(b *testing.B) {
+	hash, signature, publicKey := createSignedHash(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		verified, err := uma.DecredSignatureVerifier{}.Verify(hash, signature, publicKey)
+		if err != nil || !verified {
+			b.Fatal("signature did not verify")
+		}
+	}
+}
+
+func BenchmarkVerifyPostTransactionCallbackSignature(b *testing.B) {
+	callback, pubKeyResponse := createSignedCallback(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := uma.VerifyPostTransactionCallbackSignature(callback, pubKeyResponse, acceptingNonceCache{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
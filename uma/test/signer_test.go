@@ -0,0 +1,185 @@
+package uma_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func createSigner(t *testing.T) (*secp256k1.PrivateKey, uma.UmaSigner) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	signer, err := uma.NewInMemorySigner(privateKey.Serialize())
+	require.NoError(t, err)
+	return privateKey, signer
+}
+
+// createPrivateKeyHandle returns a PrivateKeyHandle for a private key, which is closed when the test ends.
+func createPrivateKeyHandle(tb testing.TB, privateKey *secp256k1.PrivateKey) *uma.PrivateKeyHandle {
+	handle, err := uma.NewPrivateKeyHandle(privateKey.Serialize(), false)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { require.NoError(tb, handle.Close()) })
+	return handle
+}
+
+func TestNewInMemorySignerInvalidKey(t *testing.T) {
+	_, err := uma.NewInMemorySigner([]byte{1, 2, 3})
+	require.Error(t, err)
+}
+
+func TestPrivateKeyHandle(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	keyBytes := privateKey.Serialize()
+	handle, err := uma.NewPrivateKeyHandle(keyBytes, false)
+	require.NoError(t, err)
+
+	// The key is never printed.
+	keyHex := hex.EncodeToString(keyBytes)
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%x"} {
+		printed := fmt.Sprintf(format, handle)
+		require.NotContains(t, printed, keyHex)
+		require.Equal(t, "PrivateKeyHandle(REDACTED)", printed)
+	}
+
+	pubKey, err := handle.PubKey()
+	require.NoError(t, err)
+	require.True(t, privateKey.PubKey().IsEqual(pubKey))
+	callback, err := uma.GetPostTransactionCallback(nil, "vasp1.com", handle)
+	require.NoError(t, err)
+	err = uma.VerifyPostTransactionCallbackSignature(callback, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+
+	require.NoError(t, handle.Close())
+	require.NoError(t, handle.Close())
+	_, err = handle.Sign([]byte("payload"))
+	require.ErrorIs(t, err, uma.ErrPrivateKeyHandleClosed)
+	_, err = uma.GetPostTransactionCallback(nil, "vasp1.com", handle)
+	require.ErrorIs(t, err, uma.ErrPrivateKeyHandleClosed)
+	_, err = uma.GetPostTransactionCallback(nil, "vasp1.com", nil)
+	require.Error(t, err)
+
+	_, err = uma.NewPrivateKeyHandle([]byte{1, 2, 3}, false)
+	require.Error(t, err)
+}
+
+func TestLockedPrivateKeyHandle(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	handle, err := uma.NewPrivateKeyHandle(privateKey.Serialize(), true)
+	if err != nil {
+		t.Skipf("locking memory is not available: %v", err)
+	}
+	_, err = handle.Sign([]byte("payload"))
+	require.NoError(t, err)
+	require.NoError(t, handle.Close())
+}
+
+func TestSignLnurlpRequest(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	require.True(t, request.IsUmaRequest())
+	require.Equal(t, uma.UmaProtocolVersion, *request.UmaVersion)
+
+	queryUrl, err := request.EncodeToUrl()
+	require.NoError(t, err)
+	parsedRequest, err := uma.ParseLnurlpRequest(*queryUrl)
+	require.NoError(t, err)
+	err = uma.VerifyUmaLnurlpQuerySignature(*parsedRequest.AsUmaRequest(), getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestSignLnurlpResponse(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	umaVersion := uma.UmaProtocolVersion
+	response, err := uma.SignLnurlpResponse(umaprotocol.LnurlpResponse{
+		Tag:               "payRequest",
+		Callback:          "https://vasp2.com/api/lnurl/payreq/$bob",
+		Currencies:        &[]umaprotocol.Currency{},
+		RequiredPayerData: &umaprotocol.CounterPartyDataOptions{},
+		UmaVersion:        &umaVersion,
+		Compliance: &umaprotocol.LnurlComplianceResponse{
+			KycStatus:          umaprotocol.KycStatusVerified,
+			ReceiverIdentifier: "$bob@vasp2.com",
+		},
+	}, signer)
+	require.NoError(t, err)
+	require.NotEmpty(t, response.Compliance.Signature)
+	err = uma.VerifyUmaLnurlpResponseSignature(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestSignPayRequestAndResponse(t *testing.T) {
+	senderPrivateKey, senderSigner := createSigner(t)
+	receiverPrivateKey, receiverSigner := createSigner(t)
+	compliance := umaprotocol.CompliancePayerData{
+		KycStatus:    umaprotocol.KycStatusVerified,
+		UtxoCallback: "/api/lnurl/utxocallback?txid=1234",
+	}
+	complianceMap, err := compliance.AsMap()
+	require.NoError(t, err)
+	receivingCurrencyCode := "USD"
+	request, err := uma.SignPayRequest(umaprotocol.PayRequest{
+		ReceivingCurrencyCode: &receivingCurrencyCode,
+		Amount:                1000,
+		PayerData: &umaprotocol.PayerData{
+			"identifier": "$alice@vasp1.com",
+			"compliance": complianceMap,
+		},
+		UmaMajorVersion: 1,
+	}, senderSigner)
+	require.NoError(t, err)
+	err = uma.VerifyPayReqSignature(request, getPubKeyResponse(senderPrivateKey), getNonceCache())
+	require.NoError(t, err)
+
+	payeeCompliance := umaprotocol.CompliancePayeeData{Utxos: []string{"abcdef12345"}}
+	payeeComplianceMap, err := payeeCompliance.AsMap()
+	require.NoError(t, err)
+	response, err := uma.SignPayReqResponse(umaprotocol.PayReqResponse{
+		EncodedInvoice:  "lnbcrt100n1p0z9j",
+		PayeeData:       &umaprotocol.PayeeData{"compliance": payeeComplianceMap},
+		UmaMajorVersion: 1,
+	}, "$alice@vasp1.com", "$bob@vasp2.com", receiverSigner)
+	require.NoError(t, err)
+	err = uma.VerifyPayReqResponseSignature(
+		response,
+		getPubKeyResponse(receiverPrivateKey),
+		getNonceCache(),
+		"$alice@vasp1.com",
+		"$bob@vasp2.com",
+	)
+	require.NoError(t, err)
+}
+
+func TestSignPostTransactionCallback(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	callback, err := uma.SignPostTransactionCallback(umaprotocol.PostTransactionCallback{
+		Utxos:      []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		VaspDomain: &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	err = uma.VerifyPostTransactionCallbackSignature(callback, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestSignMissingCompliance(t *testing.T) {
+	_, signer := createSigner(t)
+	_, err := uma.SignPayRequest(umaprotocol.PayRequest{
+		Amount:    1000,
+		PayerData: &umaprotocol.PayerData{"identifier": "$alice@vasp1.com"},
+	}, signer)
+	require.Error(t, err)
+	_, err = uma.SignLnurlpResponse(umaprotocol.LnurlpResponse{}, signer)
+	require.Error(t, err)
+}
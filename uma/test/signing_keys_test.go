@@ -0,0 +1,134 @@
+package uma_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestVerifySignatureWithAdditionalSigningKeys(t *testing.T) {
+	oldPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	newPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	oldKeyId := "key-1"
+	pubKeyResponse := getPubKeyResponse(oldPrivateKey)
+	pubKeyResponse.SigningKeyId = &oldKeyId
+	pubKeyResponse.SigningKeys = []umaprotocol.SigningKey{{
+		KeyId:     "key-2",
+		PubKeyHex: hex.EncodeToString(newPrivateKey.PubKey().SerializeCompressed()),
+	}}
+
+	var matchedKeyId string
+	keyMatched := uma.WithSigningKeyMatchHandler(func(keyId string) { matchedKeyId = keyId })
+	verify := func(privateKey *secp256k1.PrivateKey, pubKeyResponse umaprotocol.PubKeyResponse) error {
+		request := createSignedLnurlpRequest(t, privateKey).AsUmaRequest()
+		return uma.VerifyUmaLnurlpQuerySignature(*request, pubKeyResponse, getNonceCache(), keyMatched)
+	}
+	require.NoError(t, verify(oldPrivateKey, pubKeyResponse))
+	require.Equal(t, "key-1", matchedKeyId)
+	require.NoError(t, verify(newPrivateKey, pubKeyResponse))
+	require.Equal(t, "key-2", matchedKeyId)
+
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	require.ErrorContains(t, verify(otherPrivateKey, pubKeyResponse), "invalid uma signature")
+
+	// Expired keys are no longer accepted.
+	expiredAt := time.Now().Add(-time.Minute).Unix()
+	pubKeyResponse.SigningKeys[0].ExpirationTimestamp = &expiredAt
+	require.Error(t, verify(newPrivateKey, pubKeyResponse))
+
+	// The Verifier keeps the additional keys when it parses the signing key.
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	pubKeyResponse.SigningKeys[0].ExpirationTimestamp = &expiresAt
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache(), keyMatched)
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, newPrivateKey)))
+	require.Equal(t, "key-2", matchedKeyId)
+}
+
+func TestPubKeyResponseSigningKeysJson(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	keyId := "key-1"
+	expiresAt := int64(1_900_000_000)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyResponse.SigningKeyId = &keyId
+	pubKeyResponse.SigningKeys = []umaprotocol.SigningKey{{
+		KeyId:               "key-2",
+		PubKeyHex:           *pubKeyResponse.SigningPubKeyHex,
+		ExpirationTimestamp: &expiresAt,
+	}}
+
+	pubKeyResponseJson, err := json.Marshal(&pubKeyResponse)
+	require.NoError(t, err)
+	require.Contains(t, string(pubKeyResponseJson), `"signingKeyId":"key-1"`)
+	require.Contains(t, string(pubKeyResponseJson), `"keyId":"key-2"`)
+	var parsed umaprotocol.PubKeyResponse
+	require.NoError(t, json.Unmarshal(pubKeyResponseJson, &parsed))
+	require.Equal(t, pubKeyResponse, parsed)
+}
+
+func TestEd25519SigningKeys(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := uma.NewEd25519Signer(privateKey)
+	require.NoError(t, err)
+	secp256k1PrivateKey, secp256k1Signer := createSigner(t)
+	signWebhook := func(signer uma.UmaSigner) *umaprotocol.PaymentStatusWebhook {
+		webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+			EncodedInvoice: umatest.FakeInvoice,
+			Status:         umaprotocol.PaymentStatusPaid,
+			VaspDomain:     "vasp1.com",
+		}, signer)
+		require.NoError(t, err)
+		return webhook
+	}
+
+	publicKeyHex := hex.EncodeToString(publicKey)
+	ed25519Algorithm := umaprotocol.SigningAlgorithmEd25519
+	pubKeyResponse := umaprotocol.PubKeyResponse{SigningPubKeyHex: &publicKeyHex, SigningAlgorithm: &ed25519Algorithm}
+	pubKeyResponseJson, err := json.Marshal(&pubKeyResponse)
+	require.NoError(t, err)
+	require.Contains(t, string(pubKeyResponseJson), `"signingAlgorithm":"ed25519"`)
+	var parsed umaprotocol.PubKeyResponse
+	require.NoError(t, json.Unmarshal(pubKeyResponseJson, &parsed))
+	require.Equal(t, umaprotocol.SigningAlgorithmEd25519, parsed.SigningPubKeyAlgorithm())
+
+	require.NoError(t, uma.VerifyPaymentStatusWebhookSignature(signWebhook(signer), parsed, getNonceCache()))
+	require.Error(t, uma.VerifyPaymentStatusWebhookSignature(signWebhook(secp256k1Signer), parsed, getNonceCache()))
+	// Without the algorithm, the key is treated as a secp256k1 key.
+	parsed.SigningAlgorithm = nil
+	require.Error(t, uma.VerifyPaymentStatusWebhookSignature(signWebhook(signer), parsed, getNonceCache()))
+
+	// An Ed25519 key can also be advertised as an additional signing key while migrating from secp256k1.
+	migratingResponse := getPubKeyResponse(secp256k1PrivateKey)
+	migratingResponse.SigningKeys = []umaprotocol.SigningKey{{
+		KeyId:     "ed25519-key",
+		PubKeyHex: publicKeyHex,
+		Algorithm: &ed25519Algorithm,
+	}}
+	var matchedKeyId string
+	keyMatched := uma.WithSigningKeyMatchHandler(func(keyId string) { matchedKeyId = keyId })
+	require.NoError(t, uma.VerifyPaymentStatusWebhookSignature(signWebhook(signer), migratingResponse, getNonceCache(),
+		keyMatched))
+	require.Equal(t, "ed25519-key", matchedKeyId)
+	require.NoError(t, uma.VerifyPaymentStatusWebhookSignature(signWebhook(secp256k1Signer), migratingResponse,
+		getNonceCache(), keyMatched))
+	require.Empty(t, matchedKeyId)
+
+	_, err = uma.NewEd25519Signer(privateKey[:10])
+	require.Error(t, err)
+}
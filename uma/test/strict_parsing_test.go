@@ -0,0 +1,199 @@
+package uma_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+var strictParsing = uma.WithParseOptions(uma.ParseOptions{Strict: true})
+
+// addJsonField adds a field to a JSON object at the given path of nested objects.
+func addJsonField(t *testing.T, data []byte, value interface{}, path ...string) []byte {
+	var object map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &object))
+	parent := object
+	for _, key := range path[:len(path)-1] {
+		parent = parent[key].(map[string]interface{})
+	}
+	parent[path[len(path)-1]] = value
+	modified, err := json.Marshal(object)
+	require.NoError(t, err)
+	return modified
+}
+
+func requireStrictViolations(t *testing.T, err error, violations ...string) {
+	var strictErr uma.StrictParsingError
+	require.ErrorAs(t, err, &strictErr)
+	require.Equal(t, violations, strictErr.Violations)
+}
+
+func TestStrictParsingAcceptsSdkMessages(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(t, err)
+	lnurlpRequest, err := uma.ParseLnurlpRequest(*queryUrl, strictParsing)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	lnurlpResponse, err := uma.GetLnurlpResponseWithSigner(
+		*lnurlpRequest,
+		"https://vasp2.com/api/lnurl/payreq/$bob",
+		metadata,
+		[]umaprotocol.Currency{{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 34_150, Decimals: 2}},
+		umaprotocol.CounterPartyDataOptions{"compliance": {Mandatory: true}},
+		umaprotocol.KycStatusVerified,
+		signer,
+		true,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	lnurlpResponseJson, err := json.Marshal(lnurlpResponse)
+	require.NoError(t, err)
+	_, err = uma.ParseLnurlpResponse(lnurlpResponseJson, strictParsing)
+	require.NoError(t, err)
+
+	payreq, payreqResponse, _ := createPayReqAndResponse(t, 1000, true, &FakeInvoiceCreator{})
+	invoiceUUID := "4b7a9c2e-1f3d-4e5a-8b6c-7d8e9f0a1b2c"
+	payreq.InvoiceUUID = &invoiceUUID
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	parsedPayreq, err := uma.ParsePayRequest(payreqJson, strictParsing)
+	require.NoError(t, err)
+	require.Equal(t, invoiceUUID, *parsedPayreq.InvoiceUUID)
+	payreqResponseJson, err := json.Marshal(payreqResponse)
+	require.NoError(t, err)
+	_, err = uma.ParsePayReqResponse(payreqResponseJson, strictParsing)
+	require.NoError(t, err)
+
+	callback, err := uma.GetPostTransactionCallback(
+		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		"vasp2.com",
+		createPrivateKeyHandle(t, privateKey),
+	)
+	require.NoError(t, err)
+	callbackJson, err := json.Marshal(callback)
+	require.NoError(t, err)
+	_, err = uma.ParsePostTransactionCallback(callbackJson, strictParsing)
+	require.NoError(t, err)
+
+	webhook, err := uma.SignPaymentStatusWebhook(umaprotocol.PaymentStatusWebhook{
+		EncodedInvoice: umatest.FakeInvoice,
+		Status:         umaprotocol.PaymentStatusPaid,
+		VaspDomain:     "vasp2.com",
+	}, signer)
+	require.NoError(t, err)
+	webhookJson, err := json.Marshal(webhook)
+	require.NoError(t, err)
+	_, err = uma.ParsePaymentStatusWebhook(webhookJson, strictParsing)
+	require.NoError(t, err)
+}
+
+func TestStrictParsingRejectsUnknownFields(t *testing.T) {
+	payreq, payreqResponse, _ := createPayReqAndResponse(t, 1000, true, &FakeInvoiceCreator{})
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	payreqJson = addJsonField(t, payreqJson, "x", "extra")
+	// Payer data is free-form, so custom fields are allowed.
+	payreqJson = addJsonField(t, payreqJson, "x", "payerData", "customField")
+
+	_, err = uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	_, err = uma.ParsePayRequest(payreqJson, strictParsing)
+	requireStrictViolations(t, err, "unknown field extra")
+	require.ErrorContains(t, err, "strict parsing of payreq failed: unknown field extra")
+
+	payreqResponseJson, err := json.Marshal(payreqResponse)
+	require.NoError(t, err)
+	payreqResponseJson = addJsonField(t, payreqResponseJson, 5, "converted", "rebate")
+	_, err = uma.ParsePayReqResponse(payreqResponseJson)
+	require.NoError(t, err)
+	_, err = uma.ParsePayReqResponse(payreqResponseJson, strictParsing)
+	requireStrictViolations(t, err, "unknown field converted.rebate")
+}
+
+func TestStrictParsingRejectsWrongTypes(t *testing.T) {
+	payreq, _, _ := createPayReqAndResponse(t, 1000, true, &FakeInvoiceCreator{})
+	payreqJson, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	payreqJson = addJsonField(t, payreqJson, 42, "payerData", "name")
+	payreqJson = addJsonField(t, payreqJson, "SUPER_VERIFIED", "payerData", "compliance", "kycStatus")
+
+	parsed, err := uma.ParsePayRequest(payreqJson)
+	require.NoError(t, err)
+	compliance, err := parsed.PayerData.Compliance()
+	require.NoError(t, err)
+	require.Equal(t, umaprotocol.KycStatusUnknown, compliance.KycStatus)
+
+	_, err = uma.ParsePayRequest(payreqJson, strictParsing)
+	var strictErr uma.StrictParsingError
+	require.ErrorAs(t, err, &strictErr)
+	require.Len(t, strictErr.Violations, 2)
+	require.Equal(t, "payerData.name must be a string", strictErr.Violations[0])
+	require.Contains(t, strictErr.Violations[1], "payerData.compliance.kycStatus")
+}
+
+func TestStrictParsingRejectsMissingFields(t *testing.T) {
+	callbackJson := []byte(`{"utxos":[{"utxo":"abcdef12345","amountMsats":1000}],"vaspDomain":"vasp2.com"}`)
+	_, err := uma.ParsePostTransactionCallback(callbackJson)
+	require.NoError(t, err)
+	_, err = uma.ParsePostTransactionCallback(callbackJson, strictParsing)
+	requireStrictViolations(t, err,
+		"missing field signature", "missing field signatureNonce", "missing field signatureTimestamp")
+
+	lnurlpResponseJson := []byte(`{
+		"tag": "payRequest",
+		"callback": "https://vasp2.com/api/lnurl/payreq/$bob",
+		"minSendable": 1,
+		"maxSendable": 10000000,
+		"metadata": "[]",
+		"compliance": {
+			"kycStatus": "VERIFIED",
+			"signature": "abcd",
+			"signatureNonce": "1234",
+			"signatureTimestamp": 1700000000,
+			"isSubjectToTravelRule": true,
+			"receiverIdentifier": "$bob@vasp2.com"
+		},
+		"umaVersion": "1.0"
+	}`)
+	_, err = uma.ParseLnurlpResponse(lnurlpResponseJson)
+	require.NoError(t, err)
+	_, err = uma.ParseLnurlpResponse(lnurlpResponseJson, strictParsing)
+	requireStrictViolations(t, err, "missing field currencies", "missing field payerData")
+}
+
+func TestStrictParsingLnurlpRequest(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(t, err)
+
+	query := queryUrl.Query()
+	query.Set("isSubjectToTravelRule", "yes")
+	query.Set("referrer", "vasp3.com")
+	query.Del("nonce")
+	modifiedUrl := *queryUrl
+	modifiedUrl.RawQuery = query.Encode()
+	_, err = uma.ParseLnurlpRequest(modifiedUrl)
+	require.NoError(t, err)
+	_, err = uma.ParseLnurlpRequest(modifiedUrl, strictParsing)
+	requireStrictViolations(t, err,
+		"unknown query parameter referrer",
+		"missing query parameter nonce",
+		"isSubjectToTravelRule must be true or false",
+	)
+
+	// Plain LNURL requests have no UMA parameters at all.
+	plainUrl, err := url.Parse("https://vasp2.com/.well-known/lnurlp/$bob")
+	require.NoError(t, err)
+	_, err = uma.ParseLnurlpRequest(*plainUrl, strictParsing)
+	require.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package uma_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func collectSum(t *testing.T, reader *sdkmetric.ManualReader, name string) int64 {
+	var resourceMetrics metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &resourceMetrics))
+	var total int64
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		for _, m := range scopeMetrics.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				total += point.Value
+			}
+		}
+	}
+	return total
+}
+
+func TestTelemetryRecordsVerificationFailures(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	otherPrivateKey, _ := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	spanRecorder := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	opts := []uma.Option{
+		uma.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))),
+		uma.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	}
+	nonceCache := getNonceCache()
+
+	err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), getPubKeyResponse(privateKey), nonceCache, opts...)
+	require.NoError(t, err)
+	err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), getPubKeyResponse(privateKey), nonceCache, opts...)
+	require.Error(t, err)
+	err = uma.VerifyUmaLnurlpQuerySignature(
+		*request.AsUmaRequest(), getPubKeyResponse(otherPrivateKey), getNonceCache(), opts...)
+	require.Error(t, err)
+
+	require.Equal(t, int64(1), collectSum(t, reader, uma.MetricNonceReplays))
+	require.Equal(t, int64(1), collectSum(t, reader, uma.MetricSignatureFailures))
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 3)
+	require.Equal(t, "uma.VerifySignature", spans[0].Name())
+	require.Empty(t, spans[0].Events())
+	require.Len(t, spans[2].Events(), 1)
+}
+
+func TestTelemetryRecordsVersionDowngrades(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		_, _ = w.Write([]byte(`{"unsupportedVersion":"2.0","supportedMajorVersions":[1,0]}`))
+	}))
+	defer server.Close()
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@" + strings.TrimPrefix(server.URL, "http://"),
+		VaspDomain:      &vaspDomain,
+	}, signer)
+	require.NoError(t, err)
+	spanRecorder := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+
+	_, err = uma.SendLnurlpRequest(
+		context.Background(),
+		*request,
+		uma.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))),
+		uma.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	var unsupportedVersionError uma.UnsupportedVersionError
+	require.ErrorAs(t, err, &unsupportedVersionError)
+
+	require.Equal(t, int64(1), collectSum(t, reader, uma.MetricVersionDowngrades))
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "uma.SendRequest", spans[0].Name())
+}
@@ -0,0 +1,115 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+type tenantResolver struct {
+	signers  map[string]uma.UmaSigner
+	backends map[string]*url.URL
+}
+
+func (r tenantResolver) ResolveTenantSigner(_ context.Context, vaspDomain string) (uma.UmaSigner, error) {
+	signer, ok := r.signers[vaspDomain]
+	if !ok {
+		return nil, uma.ErrUnknownTenant
+	}
+	return signer, nil
+}
+
+func (r tenantResolver) ResolveTenantBackend(_ context.Context, vaspDomain string) (*url.URL, error) {
+	backend, ok := r.backends[vaspDomain]
+	if !ok {
+		return nil, uma.ErrUnknownTenant
+	}
+	return backend, nil
+}
+
+func newTestTenantProxy(t *testing.T, backend http.Handler) (*uma.TenantProxy, *secp256k1.PrivateKey) {
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	backendUrl, err := url.Parse(server.URL + "/tenants/vasp2")
+	require.NoError(t, err)
+	tenantPrivateKey, tenantSigner := createSigner(t)
+	resolver := tenantResolver{
+		signers:  map[string]uma.UmaSigner{"vasp2.com": tenantSigner},
+		backends: map[string]*url.URL{"vasp2.com": backendUrl},
+	}
+	return uma.NewTenantProxy(resolver, resolver), tenantPrivateKey
+}
+
+func TestTenantProxyLnurlp(t *testing.T) {
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	lnurlpHandler := newTestLnurlpRequestHandler(t, senderPrivateKey)
+	var backendPath string
+	proxy, tenantPrivateKey := newTestTenantProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendPath = r.URL.Path
+		require.Equal(t, "vasp2.com", r.Header.Get("X-Forwarded-Host"))
+		r.Host = r.Header.Get("X-Forwarded-Host")
+		http.StripPrefix("/tenants/vasp2", lnurlpHandler).ServeHTTP(w, r)
+	}))
+
+	recorder := serveLnurlpRequest(t, proxy, senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, "/tenants/vasp2/.well-known/lnurlp/$bob", backendPath)
+	response, err := uma.ParseLnurlpResponse(recorder.Body.Bytes())
+	require.NoError(t, err)
+	umaResponse := response.AsUmaResponse()
+	require.NotNil(t, umaResponse)
+	err = uma.VerifyUmaLnurlpResponseSignature(*umaResponse, getPubKeyResponse(tenantPrivateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestTenantProxyPayRequest(t *testing.T) {
+	payreq, backendResponse, _ := createPayReqAndResponse(t, 1000, true, bolt11InvoiceCreator{t: t})
+	proxy, tenantPrivateKey := newTestTenantProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/tenants/vasp2/api/lnurl/payreq/$bob", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(backendResponse))
+	}))
+
+	requestBody, err := json.Marshal(payreq)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "https://vasp2.com/api/lnurl/payreq/$bob", bytes.NewReader(requestBody))
+	proxy.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	response, err := uma.ParsePayReqResponse(recorder.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, backendResponse.EncodedInvoice, response.EncodedInvoice)
+	err = uma.VerifyPayReqResponseSignature(
+		response, getPubKeyResponse(tenantPrivateKey), getNonceCache(), "$alice@vasp1.com", "$bob@vasp2.com")
+	require.NoError(t, err)
+}
+
+func TestTenantProxyPassesThroughErrors(t *testing.T) {
+	proxy, _ := newTestTenantProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"status":"ERROR","reason":"user not found"}`))
+	}))
+
+	recorder := httptest.NewRecorder()
+	proxy.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "https://vasp2.com/.well-known/lnurlp/carol", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.JSONEq(t, `{"status":"ERROR","reason":"user not found"}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	proxy.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "https://vasp3.com/.well-known/lnurlp/carol", nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Contains(t, recorder.Body.String(), uma.ErrUnknownTenant.Error())
+}
@@ -0,0 +1,31 @@
+package uma_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/testvectors"
+)
+
+func TestBundledTestVectors(t *testing.T) {
+	vectors, err := testvectors.Bundled()
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors)
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			require.NoError(t, vector.Check())
+		})
+	}
+}
+
+func TestTestVectorMismatchIsReported(t *testing.T) {
+	vectors, err := testvectors.Load(strings.NewReader(`{
+		"generator": "test",
+		"vectors": [{"name": "bad", "type": "invoice", "signingPubKeyHex": "", "message": "\"uma1invalid\"", "valid": true}]
+	}`))
+	require.NoError(t, err)
+	errs := testvectors.CheckAll(vectors)
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "bad: expected valid signature")
+}
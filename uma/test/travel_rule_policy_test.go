@@ -0,0 +1,125 @@
+package uma_test
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+var testTravelRulePolicy = uma.ThresholdTravelRulePolicy{
+	Thresholds: []uma.TravelRuleThreshold{
+		{CurrencyCode: "USD", Amount: 300_000, PayerDataFields: []string{"name", "email"}},
+		{Jurisdiction: "EU", CurrencyCode: "EUR", Amount: 0, PayerDataFields: []string{"name"}},
+	},
+	PayerDataFields: []string{"email"},
+}
+
+func TestThresholdTravelRulePolicy(t *testing.T) {
+	evaluate := func(jurisdiction string, amount *int64, currencyCode string) uma.TravelRuleDecision {
+		decision, err := testTravelRulePolicy.EvaluateTravelRule(uma.TravelRulePayment{
+			Jurisdiction: jurisdiction,
+			Amount:       amount,
+			CurrencyCode: currencyCode,
+		})
+		require.NoError(t, err)
+		return decision
+	}
+	amount := func(amount int64) *int64 { return &amount }
+
+	require.Equal(t, uma.TravelRuleDecision{PayerDataFields: []string{"email"}}, evaluate("", amount(299_999), "USD"))
+	require.Equal(t, uma.TravelRuleDecision{IsSubjectToTravelRule: true, PayerDataFields: []string{"name", "email"}},
+		evaluate("", amount(300_000), "USD"))
+	require.Equal(t, uma.TravelRuleDecision{IsSubjectToTravelRule: true, PayerDataFields: []string{"name"}},
+		evaluate("EU", amount(1), "EUR"))
+	// Jurisdictions without a threshold for a currency fall back to the thresholds for all jurisdictions.
+	require.False(t, evaluate("EU", amount(1), "USD").IsSubjectToTravelRule)
+	// Currencies without a threshold are subject to the travel rule.
+	require.True(t, evaluate("", amount(1), uma.MillisatoshiCurrencyCode).IsSubjectToTravelRule)
+	// Before the amount is known, payments are subject to the travel rule if the jurisdiction has thresholds.
+	require.True(t, evaluate("US", nil, "").IsSubjectToTravelRule)
+	decision, err := uma.ThresholdTravelRulePolicy{}.EvaluateTravelRule(uma.TravelRulePayment{Amount: amount(1)})
+	require.NoError(t, err)
+	require.False(t, decision.IsSubjectToTravelRule)
+}
+
+func TestSignLnurlpRequestWithTravelRulePolicy(t *testing.T) {
+	_, signer := createSigner(t)
+	vaspDomain := "vasp1.com"
+	var payments []uma.TravelRulePayment
+	policy := uma.TravelRulePolicyFunc(func(payment uma.TravelRulePayment) (uma.TravelRuleDecision, error) {
+		payments = append(payments, payment)
+		return uma.TravelRuleDecision{IsSubjectToTravelRule: true}, nil
+	})
+	request, err := uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress: "$bob@vasp2.com",
+		VaspDomain:      &vaspDomain,
+	}, signer, uma.WithTravelRulePolicy(policy), uma.WithTravelRuleJurisdiction("US"))
+	require.NoError(t, err)
+	require.True(t, *request.IsSubjectToTravelRule)
+	require.Equal(t, []uma.TravelRulePayment{{ReceiverAddress: "$bob@vasp2.com", Jurisdiction: "US"}}, payments)
+
+	// An explicit IsSubjectToTravelRule takes precedence over the policy.
+	isSubjectToTravelRule := false
+	request, err = uma.SignLnurlpRequest(umaprotocol.LnurlpRequest{
+		ReceiverAddress:       "$bob@vasp2.com",
+		VaspDomain:            &vaspDomain,
+		IsSubjectToTravelRule: &isSubjectToTravelRule,
+	}, signer, uma.WithTravelRulePolicy(policy))
+	require.NoError(t, err)
+	require.False(t, *request.IsSubjectToTravelRule)
+	require.Len(t, payments, 1)
+}
+
+func TestPayRequestWithTravelRulePolicy(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	getPayRequest := func(amount int64) *umaprotocol.PayRequest {
+		name := "Alice"
+		email := "alice@vasp1.com"
+		trInfo := "some TR info for VASP2"
+		payreq, err := uma.GetUmaPayRequest(
+			amount,
+			receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+			createPrivateKeyHandle(t, senderSigningPrivateKey),
+			"USD",
+			true,
+			"$alice@vasp1.com",
+			1,
+			&name,
+			&email,
+			&trInfo,
+			nil,
+			umaprotocol.KycStatusVerified,
+			nil,
+			nil,
+			"/api/lnurl/utxocallback?txid=1234",
+			nil,
+			nil,
+			uma.WithTravelRulePolicy(testTravelRulePolicy),
+		)
+		require.NoError(t, err)
+		return payreq
+	}
+
+	payreq := getPayRequest(1000)
+	require.NotContains(t, *payreq.PayerData, "name")
+	require.Equal(t, "alice@vasp1.com", *(*payreq.PayerData)["email"].(*string))
+	require.Equal(t, "$alice@vasp1.com", *payreq.PayerData.Identifier())
+	complianceData, err := payreq.PayerData.Compliance()
+	require.NoError(t, err)
+	require.Nil(t, complianceData.EncryptedTravelRuleInfo)
+	require.NoError(t, uma.VerifyPayReqSignature(payreq, getPubKeyResponse(senderSigningPrivateKey), getNonceCache()))
+
+	payreq = getPayRequest(500_000)
+	require.Contains(t, *payreq.PayerData, "name")
+	require.Contains(t, *payreq.PayerData, "email")
+	complianceData, err = payreq.PayerData.Compliance()
+	require.NoError(t, err)
+	require.NotNil(t, complianceData.EncryptedTravelRuleInfo)
+}
@@ -0,0 +1,86 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// servePubKeyResponse serves the given public keys at /.well-known/lnurlpubkey, returning the server's domain.
+func servePubKeyResponse(t *testing.T, pubKeyResponse *umaprotocol.PubKeyResponse) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responseJson, err := json.Marshal(pubKeyResponse)
+		require.NoError(t, err)
+		_, _ = w.Write(responseJson)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestTrustStorePinnedKeysTakePrecedence(t *testing.T) {
+	pinnedPrivateKey, _ := createSigner(t)
+	publishedPrivateKey, _ := createSigner(t)
+	publishedKeys := getPubKeyResponse(publishedPrivateKey)
+	domain := servePubKeyResponse(t, &publishedKeys)
+
+	pinnedKeys := getPubKeyResponse(pinnedPrivateKey)
+	trustStore := uma.NewTrustStore()
+	require.NoError(t, trustStore.Pin(domain, &pinnedKeys))
+	cache := uma.NewInMemoryPublicKeyCache()
+	fetched, err := uma.FetchPublicKeyForVasp(domain, cache, uma.WithTrustStore(trustStore))
+	require.NoError(t, err)
+	require.Equal(t, *pinnedKeys.SigningPubKeyHex, *fetched.SigningPubKeyHex)
+	require.Nil(t, cache.FetchPublicKeyForVasp(domain))
+
+	// Signatures by the published key are rejected while the pinned key is in use.
+	lnurlpRequest := createSignedLnurlpRequest(t, publishedPrivateKey)
+	require.NoError(t, uma.VerifyUmaLnurlpQuerySignature(*lnurlpRequest.AsUmaRequest(), publishedKeys, getNonceCache()))
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &publishedKeys)
+	require.NoError(t, trustStore.Pin("vasp1.com", &pinnedKeys))
+	verifier := uma.NewVerifier(pubKeyCache, nil, uma.WithTrustStore(trustStore))
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), lnurlpRequest))
+
+	trustStore.Unpin(domain)
+	fetched, err = uma.FetchPublicKeyForVasp(domain, cache, uma.WithTrustStore(trustStore))
+	require.NoError(t, err)
+	require.Equal(t, *publishedKeys.SigningPubKeyHex, *fetched.SigningPubKeyHex)
+}
+
+func TestTrustStoreCheckPins(t *testing.T) {
+	privateKey, _ := createSigner(t)
+	otherPrivateKey, _ := createSigner(t)
+	publishedKeys := getPubKeyResponse(privateKey)
+	matchingDomain := servePubKeyResponse(t, &publishedKeys)
+	hijackedKeys := getPubKeyResponse(otherPrivateKey)
+	hijackedDomain := servePubKeyResponse(t, &hijackedKeys)
+
+	// Pins only need the keys, in the PubKeyResponse format. Compressed keys match their uncompressed form.
+	config := fmt.Sprintf(`{%q: {"signingPubKey": %q}, %q: {"signingPubKey": %q}}`,
+		matchingDomain, fmt.Sprintf("%x", privateKey.PubKey().SerializeCompressed()),
+		hijackedDomain, *publishedKeys.SigningPubKeyHex)
+	trustStore, err := uma.LoadTrustStore([]byte(config))
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	err = trustStore.CheckPins(context.Background(), uma.WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	var mismatchErr uma.PinMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	require.Equal(t, hijackedDomain, mismatchErr.VaspDomain)
+	require.Equal(t, *hijackedKeys.SigningPubKeyHex, *mismatchErr.Fetched.SigningPubKeyHex)
+	require.NotContains(t, err.Error(), matchingDomain)
+	require.Contains(t, logs.String(), uma.LogEventPubKeyPinMismatch)
+
+	_, err = uma.LoadTrustStore([]byte(`{"vasp1.com": {}}`))
+	require.ErrorContains(t, err, "vasp1.com")
+}
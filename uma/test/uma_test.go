@@ -6,6 +6,7 @@ import (
 	"math"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -116,7 +117,7 @@ func TestIsUmaQueryUnsupportedVersion(t *testing.T) {
 func TestSignAndVerifyLnurlpRequest(t *testing.T) {
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp2.com", "vasp1.com", true, nil)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	query, err := uma.ParseLnurlpRequest(*queryUrl)
 	require.NoError(t, err)
@@ -128,7 +129,7 @@ func TestSignAndVerifyLnurlpRequest(t *testing.T) {
 func TestSignAndVerifyLnurlpRequestReplacingDomain(t *testing.T) {
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp3.com", "vasp1.com", true, nil)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp3.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	queryUrl.Host = "vasp2.com"
 	query, err := uma.ParseLnurlpRequestWithReceiverDomain(*queryUrl, "vasp3.com")
@@ -143,7 +144,7 @@ func TestParseLnurlpRequestUnsupportedVersion(t *testing.T) {
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
 	version := "1000.0"
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp2.com", "vasp1.com", true, &version)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, &version)
 	require.NoError(t, err)
 	_, err = uma.ParseLnurlpRequest(*queryUrl)
 	var unsupportedVersionError uma.UnsupportedVersionError
@@ -152,6 +153,29 @@ func TestParseLnurlpRequestUnsupportedVersion(t *testing.T) {
 	require.Equal(t, unsupportedVersionError.SupportedMajorVersions, []int{1, 0})
 }
 
+func TestGetSignedLnurlpRequestUrlAtNegotiatedVersion(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(t, err)
+	query, err := uma.ParseLnurlpRequest(*queryUrl)
+	require.NoError(t, err)
+
+	// The receiver only supports v0, so the request is regenerated at the negotiated version.
+	negotiatedVersion := uma.SelectHighestSupportedVersion([]int{0})
+	require.NotNil(t, negotiatedVersion)
+	laterTime := time.Now().Add(time.Minute).Truncate(time.Second)
+	retryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com",
+		true, negotiatedVersion, uma.WithClock(uma.ClockFunc(func() time.Time { return laterTime })))
+	require.NoError(t, err)
+	retry, err := uma.ParseLnurlpRequest(*retryUrl)
+	require.NoError(t, err)
+	require.Equal(t, "0.3", *retry.UmaVersion)
+	require.NotEqual(t, *query.Nonce, *retry.Nonce)
+	require.Equal(t, laterTime.Unix(), retry.Timestamp.Unix())
+	require.NoError(t, uma.VerifyUmaLnurlpQuerySignature(*retry.AsUmaRequest(), getPubKeyResponse(privateKey), getNonceCache()))
+}
+
 func TestSignAndVerifyLnurlpRequestInvalidSignature(t *testing.T) {
 	invalidPubKeyHex := "invalid pub key"
 	invalidPubKeyResponse := umaprotocol.PubKeyResponse{
@@ -163,7 +187,7 @@ func TestSignAndVerifyLnurlpRequestInvalidSignature(t *testing.T) {
 	}
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp2.com", "vasp1.com", true, nil)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	query, err := uma.ParseLnurlpRequest(*queryUrl)
 	require.NoError(t, err)
@@ -174,7 +198,7 @@ func TestSignAndVerifyLnurlpRequestInvalidSignature(t *testing.T) {
 func TestSignAndVerifyLnurlpRequestOldSignature(t *testing.T) {
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp2.com", "vasp1.com", true, nil)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	query, err := uma.ParseLnurlpRequest(*queryUrl)
 	require.NoError(t, err)
@@ -186,7 +210,7 @@ func TestSignAndVerifyLnurlpRequestOldSignature(t *testing.T) {
 func TestSignAndVerifyLnurlpRequestDuplicateNonce(t *testing.T) {
 	privateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(privateKey.Serialize(), "$bob@vasp2.com", "vasp1.com", true, nil)
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	query, err := uma.ParseLnurlpRequest(*queryUrl)
 	require.NoError(t, err)
@@ -202,8 +226,7 @@ func TestSignAndVerifyLnurlpResponse(t *testing.T) {
 	require.NoError(t, err)
 	receiverSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
-	serializedPrivateKey := receiverSigningPrivateKey.Serialize()
-	request := createLnurlpRequest(t, senderSigningPrivateKey.Serialize())
+	request := createLnurlpRequest(t, senderSigningPrivateKey)
 	metadata, err := createMetadataForBob()
 	require.NoError(t, err)
 	isSubjectToTravelRule := true
@@ -214,7 +237,7 @@ func TestSignAndVerifyLnurlpResponse(t *testing.T) {
 		metadata,
 		1,
 		10_000_000,
-		&serializedPrivateKey,
+		createPrivateKeyHandle(t, receiverSigningPrivateKey),
 		&isSubjectToTravelRule,
 		&umaprotocol.CounterPartyDataOptions{
 			"name":       umaprotocol.CounterPartyDataOption{Mandatory: false},
@@ -248,6 +271,204 @@ func TestSignAndVerifyLnurlpResponse(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestLnurlpResponseWithSigner(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverSigningPrivateKey, receiverSigner := createSigner(t)
+	request := createLnurlpRequest(t, senderSigningPrivateKey)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currencies := []umaprotocol.Currency{
+		{
+			Code:                "USD",
+			Name:                "US Dollar",
+			Symbol:              "$",
+			MillisatoshiPerUnit: 34_150,
+			Convertible:         umaprotocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10_000},
+			Decimals:            2,
+		},
+		{
+			Code:                "SAT",
+			Name:                "Satoshis",
+			Symbol:              "SAT",
+			MillisatoshiPerUnit: 1000,
+			Convertible:         umaprotocol.ConvertibleCurrency{MinSendable: 10, MaxSendable: 1_000_000},
+			Decimals:            0,
+		},
+	}
+	payerDataOptions := umaprotocol.CounterPartyDataOptions{"name": {Mandatory: false}}
+
+	response, err := uma.GetLnurlpResponseWithSigner(
+		request,
+		"https://vasp2.com/api/lnurl/payreq/$bob",
+		metadata,
+		currencies,
+		payerDataOptions,
+		umaprotocol.KycStatusVerified,
+		receiverSigner,
+		true,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(10_000), response.MinSendable)
+	require.Equal(t, int64(1_000_000_000), response.MaxSendable)
+	require.Equal(t, uma.UmaProtocolVersion, *response.UmaVersion)
+	require.True(t, (*response.RequiredPayerData)["compliance"].Mandatory)
+	require.NotContains(t, payerDataOptions, "compliance")
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(
+		*response.AsUmaResponse(),
+		getPubKeyResponse(receiverSigningPrivateKey),
+		getNonceCache(),
+		request.ReceiverAddress,
+	)
+	require.NoError(t, err)
+
+	unsupportedVersion := "2.0"
+	request.UmaVersion = &unsupportedVersion
+	_, err = uma.GetLnurlpResponseWithSigner(
+		request,
+		"https://vasp2.com/api/lnurl/payreq/$bob",
+		metadata,
+		currencies,
+		payerDataOptions,
+		umaprotocol.KycStatusVerified,
+		receiverSigner,
+		true,
+		nil,
+		nil,
+	)
+	var unsupportedVersionError uma.UnsupportedVersionError
+	require.ErrorAs(t, err, &unsupportedVersionError)
+	require.Equal(t, uma.GetSupportedMajorVersions(), unsupportedVersionError.SupportedMajorVersions)
+}
+
+func TestLnurlpResponseCurrenciesForVersion(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	request := createLnurlpRequest(t, senderSigningPrivateKey)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currencies := []umaprotocol.Currency{{
+		Code:                "USD",
+		Name:                "US Dollar",
+		Symbol:              "$",
+		MillisatoshiPerUnit: 34_150,
+		Convertible:         umaprotocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10_000},
+		Decimals:            2,
+		UmaMajorVersion:     1,
+	}}
+	getResponse := func(umaVersion string) (*umaprotocol.LnurlpResponse, error) {
+		request.UmaVersion = &umaVersion
+		return uma.GetLnurlpResponseWithSigner(request, "https://vasp2.com/api/lnurl/payreq/$bob", metadata,
+			currencies, umaprotocol.CounterPartyDataOptions{}, umaprotocol.KycStatusVerified, receiverSigner, true,
+			nil, nil)
+	}
+
+	response, err := getResponse(uma.UmaProtocolVersion)
+	require.NoError(t, err)
+	require.Equal(t, 1, (*response.Currencies)[0].UmaMajorVersion)
+	currencyJson, err := json.Marshal(&(*response.Currencies)[0])
+	require.NoError(t, err)
+	require.Contains(t, string(currencyJson), `"convertible"`)
+
+	// Currencies are serialized in the v0 format for v0 requests, without changing the caller's currencies.
+	response, err = getResponse("0.3")
+	require.NoError(t, err)
+	require.Equal(t, "0.3", *response.UmaVersion)
+	require.Equal(t, 0, (*response.Currencies)[0].UmaMajorVersion)
+	currencyJson, err = json.Marshal(&(*response.Currencies)[0])
+	require.NoError(t, err)
+	require.Contains(t, string(currencyJson), `"minSendable":1`)
+	require.NotContains(t, string(currencyJson), `"convertible"`)
+	require.Equal(t, 1, currencies[0].UmaMajorVersion)
+
+	// Unparsable versions are rejected before any currency is changed.
+	_, err = getResponse("not-a-version")
+	require.Error(t, err)
+	require.Equal(t, 1, currencies[0].UmaMajorVersion)
+	requiresTravelRuleInfo := true
+	kycStatus := umaprotocol.KycStatusVerified
+	_, err = uma.GetLnurlpResponse(request, "https://vasp2.com/api/lnurl/payreq/$bob", metadata, 1, 10_000,
+		createPrivateKeyHandle(t, senderSigningPrivateKey), &requiresTravelRuleInfo, &umaprotocol.CounterPartyDataOptions{}, &currencies, &kycStatus,
+		nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 1, currencies[0].UmaMajorVersion)
+}
+
+func TestVerifyLnurlpResponseSignatureForReceiver(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	umaVersion := uma.UmaProtocolVersion
+	response, err := uma.SignLnurlpResponse(umaprotocol.LnurlpResponse{
+		Tag:               "payRequest",
+		Callback:          "https://vasp2.com/api/lnurl/payreq/$bob",
+		Currencies:        &[]umaprotocol.Currency{},
+		RequiredPayerData: &umaprotocol.CounterPartyDataOptions{},
+		UmaVersion:        &umaVersion,
+		Compliance: &umaprotocol.LnurlComplianceResponse{
+			KycStatus:          umaprotocol.KycStatusVerified,
+			ReceiverIdentifier: "$bob@vasp2.com",
+		},
+	}, signer)
+	require.NoError(t, err)
+
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(), "$mallory@vasp2.com")
+	require.ErrorIs(t, err, uma.ErrReceiverIdentifierMismatch)
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(), "Bob@vasp2.com")
+	require.NoError(t, err)
+
+	response.Callback = "https://attacker.com/api/lnurl/payreq/$bob"
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(), "$bob@vasp2.com")
+	var callbackUrlError uma.CallbackUrlError
+	require.ErrorAs(t, err, &callbackUrlError)
+	err = uma.VerifyUmaLnurlpResponseSignature(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithExpectedReceiver("$bob@vasp2.com"))
+	require.ErrorAs(t, err, &callbackUrlError)
+
+	response.Callback = "https://vasp2.com/api/lnurl/payreq/$bob"
+	err = uma.VerifyUmaLnurlpResponseSignature(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithExpectedReceiver("$mallory@vasp2.com"))
+	require.ErrorIs(t, err, uma.ErrReceiverIdentifierMismatch)
+	err = uma.VerifyUmaLnurlpResponseSignature(*response.AsUmaResponse(), getPubKeyResponse(privateKey), getNonceCache(),
+		uma.WithExpectedReceiver("$bob@vasp2.com"))
+	require.NoError(t, err)
+}
+
+func TestValidateLnurlpCallback(t *testing.T) {
+	valid := []struct {
+		callback string
+		receiver string
+		opts     []uma.Option
+	}{
+		{"https://vasp2.com/api/lnurl/payreq/$bob", "$bob@vasp2.com", nil},
+		{"https://api.vasp2.com/payreq", "$bob@vasp2.com", nil},
+		{"https://api.vasp2.co.uk/payreq", "$bob@pay.vasp2.co.uk", nil},
+		{"http://localhost:8080/payreq", "$bob@localhost:8080", nil},
+		{"https://payments.example.com/payreq", "$bob@vasp2.com", []uma.Option{uma.WithAllowedCallbackDomains("*.example.com")}},
+	}
+	for _, c := range valid {
+		require.NoError(t, uma.ValidateLnurlpCallback(c.callback, c.receiver, c.opts...), c.callback)
+	}
+
+	invalid := []struct {
+		callback string
+		receiver string
+	}{
+		{"http://vasp2.com/payreq", "$bob@vasp2.com"},
+		{"https://vasp2.com.attacker.com/payreq", "$bob@vasp2.com"},
+		{"https://other.co.uk/payreq", "$bob@vasp2.co.uk"},
+		{"https://attacker.co.th/payreq", "$bob@victim.co.th"},
+		{"https://10.0.0.1/payreq", "$bob@10.0.0.1"},
+		{"http://localhost/payreq", "$bob@vasp2.com"},
+		{"/api/lnurl/payreq/$bob", "$bob@vasp2.com"},
+	}
+	for _, c := range invalid {
+		var callbackUrlError uma.CallbackUrlError
+		require.ErrorAs(t, uma.ValidateLnurlpCallback(c.callback, c.receiver), &callbackUrlError, c.callback)
+	}
+}
+
 func TestPayReqCreationAndParsing(t *testing.T) {
 	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
@@ -263,7 +484,7 @@ func TestPayReqCreationAndParsing(t *testing.T) {
 	payreq, err := uma.GetUmaPayRequest(
 		1000,
 		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
-		senderSigningPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
 		"USD",
 		true,
 		"$alice@vasp1.com",
@@ -327,7 +548,7 @@ func TestMsatsPayReqCreationAndParsing(t *testing.T) {
 	payreq, err := uma.GetUmaPayRequest(
 		1000,
 		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
-		senderSigningPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
 		"USD",
 		false,
 		"$alice@vasp1.com",
@@ -360,6 +581,86 @@ func TestMsatsPayReqCreationAndParsing(t *testing.T) {
 	require.NotNil(t, payreq)
 }
 
+func TestPayRequestLockedAmounts(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	lockedSending, err := uma.NewPayRequestLockedSending(
+		1_000_000,
+		"USD",
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_000_000), lockedSending.Amount)
+	require.Nil(t, lockedSending.SendingAmountCurrencyCode)
+	require.Equal(t, "USD", *lockedSending.ReceivingCurrencyCode)
+	require.NoError(t, uma.VerifyPayReqSignature(lockedSending, getPubKeyResponse(senderSigningPrivateKey), getNonceCache()))
+
+	lockedReceiving, err := uma.NewPayRequestLockedReceiving(
+		500,
+		"EUR",
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	payreqJson, err := json.Marshal(lockedReceiving)
+	require.NoError(t, err)
+	parsedJsonMap := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(payreqJson, &parsedJsonMap))
+	require.Equal(t, "500.EUR", parsedJsonMap["amount"])
+	require.Equal(t, "EUR", parsedJsonMap["convert"])
+
+	_, err = uma.NewPayRequestLockedReceiving(
+		500,
+		"",
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+		nil,
+	)
+	require.Error(t, err)
+}
+
 type FakeInvoiceCreator struct{}
 
 func (f *FakeInvoiceCreator) CreateInvoice(int64, string, *string) (*string, error) {
@@ -367,6 +668,196 @@ func (f *FakeInvoiceCreator) CreateInvoice(int64, string, *string) (*string, err
 	return &encodedInvoice, nil
 }
 
+type recordingInvoiceCreator struct {
+	amountMsats int64
+	metadata    string
+}
+
+func (r *recordingInvoiceCreator) CreateInvoice(amountMsats int64, metadata string, _ *string) (*string, error) {
+	r.amountMsats = amountMsats
+	r.metadata = metadata
+	encodedInvoice := "lnbcrt100n1p0z9j"
+	return &encodedInvoice, nil
+}
+
+func TestPayReqResponseWithSigner(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverSigningPrivateKey, receiverSigner := createSigner(t)
+
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	invoiceCreator := &recordingInvoiceCreator{}
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}
+
+	payreqResponse, err := uma.GetPayReqResponseWithSigner(
+		*payreq,
+		invoiceCreator,
+		metadata,
+		currency,
+		24_150,
+		100_000,
+		[]string{"abcdef12345"},
+		"$bob@vasp2.com",
+		receiverSigner,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000*24_150+100_000), invoiceCreator.amountMsats)
+	require.True(t, strings.HasPrefix(invoiceCreator.metadata, metadata))
+	require.Equal(t, "USD", payreqResponse.PaymentInfo.CurrencyCode)
+	require.Equal(t, 2, payreqResponse.PaymentInfo.Decimals)
+	require.Equal(t, "$bob@vasp2.com", (*payreqResponse.PayeeData)["identifier"])
+	err = uma.VerifyPayReqResponseSignature(
+		payreqResponse,
+		getPubKeyResponse(receiverSigningPrivateKey),
+		getNonceCache(),
+		"$alice@vasp1.com",
+		"$bob@vasp2.com",
+	)
+	require.NoError(t, err)
+}
+
+func TestPayReqResponseOutsideConvertibleRange(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	payreq := createSignedPayRequest(t, senderSigningPrivateKey)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{
+		Code:                "USD",
+		Name:                "US Dollar",
+		Symbol:              "$",
+		MillisatoshiPerUnit: 24_150,
+		Convertible:         umaprotocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 999},
+		Decimals:            2,
+	}
+
+	_, err = uma.GetPayReqResponseWithSigner(*payreq, &FakeInvoiceCreator{}, metadata, currency, 24_150, 100_000,
+		[]string{"abcdef12345"}, "$bob@vasp2.com", receiverSigner, nil, nil, nil, nil, nil)
+	var outOfRangeError uma.AmountOutOfRangeError
+	require.ErrorAs(t, err, &outOfRangeError)
+	require.Equal(t, uma.AmountOutOfRangeError{Amount: 1000, CurrencyCode: "USD", Min: 1, Max: 999}, outOfRangeError)
+
+	currency.Convertible.MaxSendable = 1000
+	_, err = uma.GetPayReqResponseWithSigner(*payreq, &FakeInvoiceCreator{}, metadata, currency, 24_150, 100_000,
+		[]string{"abcdef12345"}, "$bob@vasp2.com", receiverSigner, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+}
+
+type expiringInvoiceCreator struct {
+	recordingInvoiceCreator
+	expirySecs *int64
+}
+
+func (e *expiringInvoiceCreator) CreateInvoiceWithExpiry(
+	amountMsats int64,
+	metadata string,
+	receiverIdentifier *string,
+	expirySecs *int64,
+) (*string, error) {
+	e.expirySecs = expirySecs
+	return e.CreateInvoice(amountMsats, metadata, receiverIdentifier)
+}
+
+func TestPayReqResponseWithInvoiceExpiry(t *testing.T) {
+	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, receiverSigner := createSigner(t)
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	currency := umaprotocol.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", MillisatoshiPerUnit: 24_150, Decimals: 2}
+	getResponse := func(invoiceCreator uma.InvoiceCreator, opts ...uma.Option) (*umaprotocol.PayReqResponse, error) {
+		return uma.GetPayReqResponseWithSigner(
+			*payreq,
+			invoiceCreator,
+			metadata,
+			currency,
+			24_150,
+			100_000,
+			[]string{"abcdef12345"},
+			"$bob@vasp2.com",
+			receiverSigner,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			opts...,
+		)
+	}
+
+	invoiceCreator := &expiringInvoiceCreator{}
+	payreqResponse, err := getResponse(invoiceCreator, uma.WithInvoiceExpiry(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(120), *invoiceCreator.expirySecs)
+	require.Equal(t, int64(120), *payreqResponse.InvoiceExpiry)
+
+	payreqResponseJson, err := json.Marshal(payreqResponse)
+	require.NoError(t, err)
+	require.Contains(t, string(payreqResponseJson), `"expiry":120`)
+	parsedResponse, err := uma.ParsePayReqResponse(payreqResponseJson)
+	require.NoError(t, err)
+	require.Equal(t, int64(120), *parsedResponse.InvoiceExpiry)
+
+	// Without an expiry, the expiry hint is omitted.
+	payreqResponse, err = getResponse(&expiringInvoiceCreator{})
+	require.NoError(t, err)
+	require.Nil(t, payreqResponse.InvoiceExpiry)
+
+	_, err = getResponse(&FakeInvoiceCreator{}, uma.WithInvoiceExpiry(2*time.Minute))
+	require.ErrorContains(t, err, "ExpiringInvoiceCreator")
+}
+
 func TestPayReqResponseAndParsing(t *testing.T) {
 	senderSigningPrivateKey, err := secp256k1.GeneratePrivateKey()
 	require.NoError(t, err)
@@ -387,7 +878,7 @@ func TestPayReqResponseAndParsing(t *testing.T) {
 	payreq, err := uma.GetUmaPayRequest(
 		1000,
 		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
-		senderSigningPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
 		"USD",
 		true,
 		"$alice@vasp1.com",
@@ -414,7 +905,6 @@ func TestPayReqResponseAndParsing(t *testing.T) {
 	receivingCurrencyDecimals := 2
 	conversionRate := float64(24_150)
 	fee := int64(100_000)
-	serializedPrivateKey := receiverSigningPrivateKey.Serialize()
 	payeeIdentifier := "$bob@vasp2.com"
 	utxoCallback := "/api/lnurl/utxocallback?txid=1234"
 	payreqResponse, err := uma.GetPayReqResponse(
@@ -429,7 +919,7 @@ func TestPayReqResponseAndParsing(t *testing.T) {
 		nil,
 		&utxoCallback,
 		&payeeData,
-		&serializedPrivateKey,
+		createPrivateKeyHandle(t, receiverSigningPrivateKey),
 		&payeeIdentifier,
 		nil,
 		nil,
@@ -479,7 +969,7 @@ func TestMsatsPayReqResponseAndParsing(t *testing.T) {
 	payreq, err := uma.GetUmaPayRequest(
 		1_000_000,
 		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
-		senderSigningPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
 		"USD",
 		false,
 		"$alice@vasp1.com",
@@ -508,7 +998,6 @@ func TestMsatsPayReqResponseAndParsing(t *testing.T) {
 	conversionRate := float64(24_150)
 	utxoCallback := "/api/lnurl/utxocallback?txid=1234"
 	payeeIdentifier := "$bob@vasp2.com"
-	serializedPrivateKey := receiverSigningPrivateKey.Serialize()
 	payreqResponse, err := uma.GetPayReqResponse(
 		*payreq,
 		client,
@@ -521,7 +1010,7 @@ func TestMsatsPayReqResponseAndParsing(t *testing.T) {
 		nil,
 		&utxoCallback,
 		&payeeData,
-		&serializedPrivateKey,
+		createPrivateKeyHandle(t, receiverSigningPrivateKey),
 		&payeeIdentifier,
 		nil,
 		nil,
@@ -568,7 +1057,7 @@ func TestV0PayReqResponseAndParsing(t *testing.T) {
 	payreq, err := uma.GetUmaPayRequest(
 		1_000_000,
 		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
-		senderSigningPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, senderSigningPrivateKey),
 		"USD",
 		false,
 		"$alice@vasp1.com",
@@ -597,7 +1086,6 @@ func TestV0PayReqResponseAndParsing(t *testing.T) {
 	conversionRate := float64(24_150)
 	utxoCallback := "/api/lnurl/utxocallback?txid=1234"
 	payeeIdentifier := "$bob@vasp2.com"
-	serializedPrivateKey := receiverSigningPrivateKey.Serialize()
 	payreqResponse, err := uma.GetPayReqResponse(
 		*payreq,
 		client,
@@ -610,7 +1098,7 @@ func TestV0PayReqResponseAndParsing(t *testing.T) {
 		nil,
 		&utxoCallback,
 		&payeeData,
-		&serializedPrivateKey,
+		createPrivateKeyHandle(t, receiverSigningPrivateKey),
 		&payeeIdentifier,
 		nil,
 		nil,
@@ -638,7 +1126,7 @@ func TestSignAndVerifyPostTransactionCallback(t *testing.T) {
 	callback, err := uma.GetPostTransactionCallback(
 		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
 		"my-vasp.com",
-		signingPrivateKey.Serialize(),
+		createPrivateKeyHandle(t, signingPrivateKey),
 	)
 	require.NoError(t, err)
 	callbackJson, err := json.Marshal(callback)
@@ -649,6 +1137,47 @@ func TestSignAndVerifyPostTransactionCallback(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestRawPayloadCapture(t *testing.T) {
+	signingPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	callback, err := uma.GetPostTransactionCallback(
+		[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+		"my-vasp.com",
+		createPrivateKeyHandle(t, signingPrivateKey),
+	)
+	require.NoError(t, err)
+	callbackJson, err := json.Marshal(callback)
+	require.NoError(t, err)
+
+	parsedCallback, err := uma.ParsePostTransactionCallback(callbackJson)
+	require.NoError(t, err)
+	require.Nil(t, parsedCallback.RawResponse)
+
+	wireBytes := append([]byte(nil), callbackJson...)
+	parsedCallback, err = uma.ParsePostTransactionCallback(wireBytes, uma.WithRawPayloadCapture())
+	require.NoError(t, err)
+	require.Equal(t, callbackJson, parsedCallback.RawResponse)
+	// The captured bytes must not alias the caller's buffer.
+	wireBytes[0] = 'x'
+	require.Equal(t, callbackJson, parsedCallback.RawResponse)
+	rawJson, err := json.Marshal(parsedCallback)
+	require.NoError(t, err)
+	require.NotContains(t, string(rawJson), "RawResponse")
+
+	archivedCallback, err := uma.ParsePostTransactionCallback(parsedCallback.RawResponse)
+	require.NoError(t, err)
+	err = uma.VerifyPostTransactionCallbackSignature(archivedCallback, getPubKeyResponse(signingPrivateKey), getNonceCache())
+	require.NoError(t, err)
+
+	urlString := "https://vasp2.com/.well-known/lnurlp/bob?signature=signature&nonce=12345&vaspDomain=vasp1.com&umaVersion=1.0&isSubjectToTravelRule=true&timestamp=1690497968"
+	urlObj, err := url.Parse(urlString)
+	require.NoError(t, err)
+	request, err := uma.ParseLnurlpRequest(*urlObj, uma.WithRawPayloadCapture())
+	require.NoError(t, err)
+	require.Equal(t, urlString, string(request.RawResponse))
+	require.Equal(t, urlString, string(request.AsUmaRequest().RawResponse))
+}
+
 func TestParsePayReqFromQueryParamsNoOptionalFields(t *testing.T) {
 	amount := "1000"
 	params := url.Values{
@@ -774,8 +1303,8 @@ usLY8crt6ys3KQ==
 	require.Equal(t, *newPemCert, pemCert)
 }
 
-func createLnurlpRequest(t *testing.T, signingPrivateKey []byte) umaprotocol.LnurlpRequest {
-	queryUrl, err := uma.GetSignedLnurlpRequestUrl(signingPrivateKey, "$bob@vasp2.com", "vasp1.com", true, nil)
+func createLnurlpRequest(t *testing.T, signingPrivateKey *secp256k1.PrivateKey) umaprotocol.LnurlpRequest {
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(t, signingPrivateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
 	require.NoError(t, err)
 	query, err := uma.ParseLnurlpRequest(*queryUrl)
 	require.NoError(t, err)
@@ -838,7 +1367,7 @@ func TestUMAInvoice(t *testing.T) {
 		&kyc,
 		nil,
 		nil,
-		privateKey.Serialize(),
+		createPrivateKeyHandle(t, privateKey),
 	)
 	require.NoError(t, err)
 
@@ -866,3 +1395,30 @@ func TestUMAInvoice(t *testing.T) {
 	err = uma.VerifyUmaInvoiceSignature(*decodedInvoice, publicKeyResponse)
 	require.NoError(t, err)
 }
+
+func TestTrimPayerDataToRequested(t *testing.T) {
+	payerData := umaprotocol.PayerData{
+		"identifier":  "$alice@vasp1.com",
+		"name":        "Alice",
+		"email":       "alice@vasp1.com",
+		"countryCode": "US",
+		"compliance":  map[string]interface{}{"kycStatus": "VERIFIED"},
+	}
+	requested := umaprotocol.CounterPartyDataOptions{
+		"identifier": {Mandatory: true},
+		"compliance": {Mandatory: true},
+		"name":       {Mandatory: false},
+		"phone":      {Mandatory: false},
+	}
+	trimmedPayerData := uma.TrimPayerDataToRequested(payerData, requested)
+	require.Equal(t, umaprotocol.PayerData{
+		"identifier": "$alice@vasp1.com",
+		"name":       "Alice",
+		"compliance": map[string]interface{}{"kycStatus": "VERIFIED"},
+	}, trimmedPayerData)
+	// The original payer data is left unchanged.
+	require.Len(t, payerData, 5)
+
+	require.Empty(t, uma.TrimPayerDataToRequested(payerData, nil))
+	require.Nil(t, uma.TrimPayerDataToRequested(nil, requested))
+}
@@ -0,0 +1,43 @@
+package uma_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umacurrency"
+)
+
+func TestFormatAmount(t *testing.T) {
+	usd := umaprotocol.Currency{Code: "USD", Symbol: "$", Decimals: 2}
+	require.Equal(t, "5.95 USD", umacurrency.FormatAmount(595, usd))
+	require.Equal(t, "0.05 USD", umacurrency.FormatAmount(5, usd))
+	require.Equal(t, "0.00 USD", umacurrency.FormatAmount(0, usd))
+	require.Equal(t, "-5.95 USD", umacurrency.FormatAmount(-595, usd))
+	require.Equal(t, "$5.95", umacurrency.FormatAmount(595, usd, umacurrency.WithSymbol()))
+	require.Equal(t, "-$5.95", umacurrency.FormatAmount(-595, usd, umacurrency.WithSymbol()))
+	require.Equal(t, "1234567.89 USD", umacurrency.FormatAmount(123_456_789, usd))
+	require.Equal(t, "1,234,567.89 USD", umacurrency.FormatAmount(123_456_789, usd, umacurrency.WithLocale(umacurrency.LocaleEnUS)))
+	require.Equal(t, "1.234.567,89 USD", umacurrency.FormatAmount(123_456_789, usd, umacurrency.WithLocale(umacurrency.LocaleDeDE)))
+	require.Equal(t, "1\u202f234\u202f567,89 USD", umacurrency.FormatAmount(123_456_789, usd, umacurrency.WithLocale(umacurrency.LocaleFrFR)))
+
+	jpy := umaprotocol.Currency{Code: "JPY", Decimals: 0}
+	require.Equal(t, "595 JPY", umacurrency.FormatAmount(595, jpy))
+	// Currencies without a symbol fall back to their code.
+	require.Equal(t, "595 JPY", umacurrency.FormatAmount(595, jpy, umacurrency.WithSymbol()))
+	require.Equal(t, "-9223372036854775808 JPY", umacurrency.FormatAmount(math.MinInt64, jpy))
+}
+
+func TestFormatBitcoinAmounts(t *testing.T) {
+	require.Equal(t, "1234 sats", umacurrency.FormatSats(1_234_000))
+	require.Equal(t, "1234.5 sats", umacurrency.FormatSats(1_234_500))
+	require.Equal(t, "1 sat", umacurrency.FormatSats(1_000))
+	require.Equal(t, "0.001 sats", umacurrency.FormatSats(1))
+	require.Equal(t, "1,234 sats", umacurrency.FormatSats(1_234_000, umacurrency.WithLocale(umacurrency.LocaleEnUS)))
+
+	require.Equal(t, "0.00001234 BTC", umacurrency.FormatBtc(1_234_000))
+	require.Equal(t, "0.00001234567 BTC", umacurrency.FormatBtc(1_234_567))
+	require.Equal(t, "1.00000000 BTC", umacurrency.FormatBtc(100_000_000_000))
+	require.Equal(t, "0,00001234 BTC", umacurrency.FormatBtc(1_234_000, umacurrency.WithLocale(umacurrency.LocaleDeDE)))
+}
@@ -0,0 +1,56 @@
+package uma_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umametrics"
+)
+
+// gatheredCounters returns the values of the counters in a registry, keyed by metric name and label values.
+func gatheredCounters(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	counters := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if metric.GetCounter() == nil {
+				continue
+			}
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += "," + label.GetName() + "=" + label.GetValue()
+			}
+			counters[key] = metric.GetCounter().GetValue()
+		}
+	}
+	return counters
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector, err := umametrics.NewCollector(registry)
+	require.NoError(t, err)
+	senderPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	handler := newTestLnurlpRequestHandler(t, senderPrivateKey, collector.Option())
+
+	recorder := serveLnurlpRequest(t, handler, senderPrivateKey)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	recorder = serveLnurlpRequest(t, handler, otherPrivateKey)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	require.Equal(t, map[string]float64{
+		"uma_public_key_cache_lookups_total,result=hit":                                        2,
+		"uma_verification_failures_total,message_type=lnurlp_request,reason=invalid_signature": 1,
+	}, gatheredCounters(t, registry))
+
+	// Registering the metrics twice fails.
+	_, err = umametrics.NewCollector(registry)
+	require.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package uma_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umaqr"
+)
+
+func TestAddressPayload(t *testing.T) {
+	payload, err := umaqr.AddressPayload("$bob@vasp2.com")
+	require.NoError(t, err)
+	require.Equal(t, "uma://$bob@vasp2.com", payload)
+
+	payload, err = umaqr.AddressPayload("$bob@vasp2.com", umaqr.WithAmount(1000, "usd"))
+	require.NoError(t, err)
+	require.Equal(t, "uma://$bob@vasp2.com?amount=1000&currency=USD", payload)
+	parsed, err := umaqr.ParsePayload(payload)
+	require.NoError(t, err)
+	require.Equal(t, "$bob@vasp2.com", parsed.UmaAddress)
+	require.Equal(t, int64(1000), *parsed.Amount)
+	require.Equal(t, "USD", parsed.CurrencyCode)
+
+	payload, err = umaqr.AddressPayload("$bob@vasp2.com", umaqr.WithLnurlFallback())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(payload, "lightning:LNURL1"))
+	parsed, err = umaqr.ParsePayload(payload)
+	require.NoError(t, err)
+	require.Equal(t, "https://vasp2.com/.well-known/lnurlp/$bob", parsed.LnurlpUrl.String())
+
+	_, err = umaqr.AddressPayload("$bob@vasp2.com", umaqr.WithLnurlFallback(), umaqr.WithAmount(1000, "USD"))
+	require.Error(t, err)
+	_, err = umaqr.AddressPayload("$bob@vasp2.com", umaqr.WithAmount(0, "USD"))
+	require.Error(t, err)
+	_, err = umaqr.AddressPayload("bob@vasp2.com")
+	require.Error(t, err)
+	_, err = umaqr.ParsePayload("https://vasp2.com")
+	require.Error(t, err)
+	_, err = umaqr.ParsePayload("uma://$bob@vasp2.com?amount=ten&currency=USD")
+	require.Error(t, err)
+}
+
+func TestInvoicePayload(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	invoice, err := uma.CreateUmaInvoice(
+		"$bob@vasp2.com",
+		1000,
+		umaprotocol.InvoiceCurrency{Code: "USD", Name: "US Dollar", Symbol: "$", Decimals: 2},
+		1721081249,
+		"https://vasp2.com/api/lnurl/payreq/$bob",
+		true,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		createPrivateKeyHandle(t, privateKey),
+	)
+	require.NoError(t, err)
+
+	payload, err := umaqr.InvoicePayload(*invoice)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(payload, "uma://uma1"))
+	parsed, err := umaqr.ParsePayload(strings.ToUpper(payload))
+	require.NoError(t, err)
+	require.Equal(t, invoice.InvoiceUUID, parsed.Invoice.InvoiceUUID)
+	require.Equal(t, uint64(1000), parsed.Invoice.Amount)
+	require.NoError(t, uma.VerifyUmaInvoiceSignature(*parsed.Invoice, getPubKeyResponse(privateKey)))
+}
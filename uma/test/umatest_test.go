@@ -0,0 +1,126 @@
+package uma_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestMockVaspsRoundTrip(t *testing.T) {
+	receiver := umatest.NewMockReceivingVasp()
+	defer receiver.Close()
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+
+	response, err := sender.Pay(context.Background(), receiver.Address("bob"), 1000, "USD")
+	require.NoError(t, err)
+	require.Equal(t, umatest.FakeInvoice, response.EncodedInvoice)
+	require.Equal(t, "USD", response.PaymentInfo.CurrencyCode)
+	require.Equal(t, int64(1000), *response.PaymentInfo.Amount)
+	require.Equal(t, receiver.Address("bob"), (*response.PayeeData)["identifier"])
+}
+
+func TestMockVaspsRejectsInvalidSignature(t *testing.T) {
+	receiver := umatest.NewMockReceivingVasp()
+	defer receiver.Close()
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	_, otherSigner := createSigner(t)
+	sender.Signer = otherSigner
+
+	_, err := sender.Lnurlp(context.Background(), receiver.Address("bob"))
+	var invalidResponseError uma.InvalidResponseError
+	require.ErrorAs(t, err, &invalidResponseError)
+	require.Equal(t, 400, invalidResponseError.StatusCode)
+}
+
+func TestMockVaspsNegotiatesVersion(t *testing.T) {
+	receiver := umatest.NewMockReceivingVasp()
+	defer receiver.Close()
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	sender.UmaVersion = "0.3"
+
+	response, err := sender.Lnurlp(context.Background(), receiver.Address("bob"))
+	require.NoError(t, err)
+	require.Equal(t, "0.3", *response.UmaVersion)
+}
+
+func TestMockVaspsPostTransactionCallback(t *testing.T) {
+	receiver := umatest.NewMockReceivingVasp()
+	defer receiver.Close()
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	utxos := []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345:1", Amount: 1000}}
+
+	err := receiver.SendPostTransactionCallback(context.Background(), sender.UtxoCallbackUrl(), utxos)
+	require.NoError(t, err)
+	callbacks := sender.ReceivedCallbacks()
+	require.Len(t, callbacks, 1)
+	require.Equal(t, utxos, callbacks[0].Utxos)
+	require.Equal(t, receiver.Domain, *callbacks[0].VaspDomain)
+}
+
+func TestKeypairFromSeedIsDeterministic(t *testing.T) {
+	require.Equal(t, umatest.KeypairFromSeed("seed").PubKeyHex(), umatest.KeypairFromSeed("seed").PubKeyHex())
+	require.NotEqual(t, umatest.KeypairFromSeed("seed").PubKeyHex(), umatest.KeypairFromSeed("other").PubKeyHex())
+	require.NotEqual(t, umatest.GenerateKeypair().PubKeyHex(), umatest.GenerateKeypair().PubKeyHex())
+}
+
+func TestFixturesVerify(t *testing.T) {
+	senderPubKeys := umatest.SenderKeypair().PubKeyResponse()
+	receiverPubKeys := umatest.ReceiverKeypair().PubKeyResponse()
+
+	lnurlpRequest := umatest.SignedLnurlpRequestFixture(umatest.LnurlpRequestFixtureOptions{})
+	require.NoError(t, uma.VerifyUmaLnurlpQuerySignature(*lnurlpRequest.AsUmaRequest(), senderPubKeys, getNonceCache()))
+
+	lnurlpResponse := umatest.SignedLnurlpResponseFixture(umatest.LnurlpResponseFixtureOptions{})
+	require.NoError(t, uma.VerifyUmaLnurlpResponseSignatureForReceiver(
+		*lnurlpResponse.AsUmaResponse(), receiverPubKeys, getNonceCache(), umatest.DefaultReceiverAddress))
+	require.Equal(t, "https://vasp2.com/api/uma/payreq/bob", lnurlpResponse.Callback)
+
+	payReq := umatest.PayReqFixture(umatest.PayReqFixtureOptions{Amount: 500})
+	require.NoError(t, uma.VerifyPayReqSignature(payReq, senderPubKeys, getNonceCache()))
+	require.Equal(t, int64(500), payReq.Amount)
+
+	payReqResponse := umatest.PayReqResponseFixture(umatest.PayReqResponseFixtureOptions{})
+	require.NoError(t, uma.VerifyPayReqResponseSignature(
+		payReqResponse, receiverPubKeys, getNonceCache(), umatest.DefaultPayerIdentifier, umatest.DefaultReceiverAddress))
+
+	callback := umatest.PostTransactionCallbackFixture(umatest.PostTransactionCallbackFixtureOptions{})
+	require.NoError(t, uma.VerifyPostTransactionCallbackSignature(callback, receiverPubKeys, getNonceCache()))
+}
+
+func TestFixtureSignatureOverrides(t *testing.T) {
+	senderPubKeys := umatest.SenderKeypair().PubKeyResponse()
+
+	corrupted := umatest.PayReqFixture(umatest.PayReqFixtureOptions{
+		SignatureOverrides: umatest.SignatureOverrides{CorruptSignature: true},
+	})
+	require.ErrorContains(t, uma.VerifyPayReqSignature(corrupted, senderPubKeys, getNonceCache()), "invalid uma signature")
+
+	otherKeypair := umatest.GenerateKeypair()
+	wrongKey := umatest.SignedLnurlpRequestFixture(umatest.LnurlpRequestFixtureOptions{
+		SignatureOverrides: umatest.SignatureOverrides{Keypair: &otherKeypair},
+	})
+	require.Error(t, uma.VerifyUmaLnurlpQuerySignature(*wrongKey.AsUmaRequest(), senderPubKeys, getNonceCache()))
+
+	expired := umatest.SignedLnurlpRequestFixture(umatest.LnurlpRequestFixtureOptions{
+		SignatureOverrides: umatest.SignatureOverrides{Timestamp: time.Now().Add(-2 * time.Hour)},
+	})
+	expiryNonceCache := uma.NewInMemoryNonceCache(time.Now().Add(-time.Hour))
+	require.Error(t, uma.VerifyUmaLnurlpQuerySignature(*expired.AsUmaRequest(), senderPubKeys, expiryNonceCache))
+
+	replayed := umatest.PostTransactionCallbackFixture(umatest.PostTransactionCallbackFixtureOptions{
+		SignatureOverrides: umatest.SignatureOverrides{Nonce: "1234"},
+	})
+	receiverPubKeys := umatest.ReceiverKeypair().PubKeyResponse()
+	nonceCache := getNonceCache()
+	require.NoError(t, uma.VerifyPostTransactionCallbackSignature(replayed, receiverPubKeys, nonceCache))
+	require.Error(t, uma.VerifyPostTransactionCallbackSignature(replayed, receiverPubKeys, nonceCache))
+}
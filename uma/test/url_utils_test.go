@@ -0,0 +1,47 @@
+package uma_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	cases := map[string]string{
+		"vasp.com":                "vasp.com",
+		"API.Vasp.com:8443":       "vasp.com",
+		"pay.vasp.co.uk":          "vasp.co.uk",
+		"vasp.co.uk":              "vasp.co.uk",
+		"co.uk":                   "co.uk",
+		"alice.github.io":         "alice.github.io",
+		"api.alice.herokuapp.com": "alice.herokuapp.com",
+		"10.0.0.1:8080":           "10.0.0.1",
+		"[::1]:8080":              "::1",
+		"pay.bücher.example":      "xn--bcher-kva.example",
+		"localhost":               "localhost",
+		"vasp.com.":               "vasp.com",
+		"pay.victim.co.th":        "victim.co.th",
+		"evil.com.pl":             "evil.com.pl",
+		"a.s3.amazonaws.com":      "a.s3.amazonaws.com",
+	}
+	for domain, expected := range cases {
+		require.Equal(t, expected, utils.RegistrableDomain(domain), domain)
+	}
+
+	require.True(t, utils.IsSameRegistrableDomain("api.vasp2.com", "vasp2.com:443"))
+	require.False(t, utils.IsSameRegistrableDomain("vasp2.com.attacker.com", "vasp2.com"))
+	require.False(t, utils.IsSameRegistrableDomain("alice.github.io", "mallory.github.io"))
+	require.False(t, utils.IsSameRegistrableDomain("attacker.co.th", "victim.co.th"))
+	require.False(t, utils.IsSameRegistrableDomain("evil.com.pl", "victim.com.pl"))
+	require.False(t, utils.IsSameRegistrableDomain("a.s3.amazonaws.com", "b.s3.amazonaws.com"))
+}
+
+func TestGetVaspDomainFromUmaAddress(t *testing.T) {
+	domain, err := uma.GetVaspDomainFromUmaAddress("$bob@vasp2.com:8080")
+	require.NoError(t, err)
+	require.Equal(t, "vasp2.com:8080", domain)
+	_, err = uma.GetVaspDomainFromUmaAddress("$bob")
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package uma_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+type recordingTransactionRegistrar struct {
+	mutex         sync.Mutex
+	registrations []uma.TransactionRegistration
+	err           error
+}
+
+func (r *recordingTransactionRegistrar) RegisterTransaction(_ context.Context, registration uma.TransactionRegistration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.registrations = append(r.registrations, registration)
+	return nil
+}
+
+func postUtxoCallback(t *testing.T, callbackUrl string, callback *umaprotocol.PostTransactionCallback) int {
+	body, err := json.Marshal(callback)
+	require.NoError(t, err)
+	resp, err := http.Post(callbackUrl, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp.StatusCode
+}
+
+func TestUtxoCallbackHandler(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	registrar := &recordingTransactionRegistrar{}
+	server := httptest.NewServer(uma.NewUtxoCallbackHandler(uma.NewInMemoryPublicKeyCache(), getNonceCache(), registrar))
+	defer server.Close()
+	callbackUrl := server.URL + "/api/lnurl/utxocallback?txid=1234"
+
+	utxos := []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345:1", Amount: 1_000_000}}
+	callback, err := uma.SignPostTransactionCallback(
+		umaprotocol.PostTransactionCallback{Utxos: utxos, VaspDomain: &sender.Domain}, sender.Signer)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, postUtxoCallback(t, callbackUrl, callback))
+	require.Len(t, registrar.registrations, 1)
+	require.Equal(t, sender.Domain, registrar.registrations[0].VaspDomain)
+	require.Equal(t, utxos, registrar.registrations[0].Utxos)
+	require.Equal(t, "1234", registrar.registrations[0].CallbackQuery.Get("txid"))
+
+	// Replays are rejected by the nonce cache.
+	require.Equal(t, http.StatusBadRequest, postUtxoCallback(t, callbackUrl, callback))
+
+	_, otherSigner := createSigner(t)
+	forgedCallback, err := uma.SignPostTransactionCallback(*callback, otherSigner)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, postUtxoCallback(t, callbackUrl, forgedCallback))
+
+	unsignedCallback := umaprotocol.PostTransactionCallback{Utxos: utxos, VaspDomain: &sender.Domain}
+	require.Equal(t, http.StatusBadRequest, postUtxoCallback(t, callbackUrl, &unsignedCallback))
+	require.Len(t, registrar.registrations, 1)
+
+	registrar.err = errors.New("KYT provider unavailable")
+	callback, err = uma.SignPostTransactionCallback(*callback, sender.Signer)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, postUtxoCallback(t, callbackUrl, callback))
+
+	resp, err := http.Get(callbackUrl)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestUtxoCallbackHandlerIdempotency(t *testing.T) {
+	sender := umatest.NewMockSendingVasp()
+	defer sender.Close()
+	registrar := &recordingTransactionRegistrar{}
+	server := httptest.NewServer(uma.NewUtxoCallbackHandler(uma.NewInMemoryPublicKeyCache(), getNonceCache(), registrar,
+		uma.WithIdempotencyStore(uma.NewInMemoryIdempotencyStore())))
+	defer server.Close()
+
+	idempotencyKey := uma.GenerateIdempotencyKey()
+	unsignedCallback := umaprotocol.PostTransactionCallback{
+		Utxos:          []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345:1", Amount: 1_000_000}},
+		VaspDomain:     &sender.Domain,
+		IdempotencyKey: &idempotencyKey,
+	}
+	// Each delivery is signed with a fresh nonce, but is only registered once.
+	for i := 0; i < 2; i++ {
+		callback, err := uma.SignPostTransactionCallback(unsignedCallback, sender.Signer)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, postUtxoCallback(t, server.URL, callback))
+	}
+	require.Len(t, registrar.registrations, 1)
+	require.Equal(t, idempotencyKey, *registrar.registrations[0].IdempotencyKey)
+
+	unsignedCallback.Utxos = []umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345:2", Amount: 1_000_000}}
+	callback, err := uma.SignPostTransactionCallback(unsignedCallback, sender.Signer)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, postUtxoCallback(t, server.URL, callback))
+
+	// Keys are scoped by the counterparty, so another VASP using the same key is registered separately.
+	otherSender := umatest.NewMockSendingVasp()
+	defer otherSender.Close()
+	unsignedCallback.VaspDomain = &otherSender.Domain
+	callback, err = uma.SignPostTransactionCallback(unsignedCallback, otherSender.Signer)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, postUtxoCallback(t, server.URL, callback))
+	require.Len(t, registrar.registrations, 2)
+}
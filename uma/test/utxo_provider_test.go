@@ -0,0 +1,87 @@
+package uma_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+type fakeUtxoProvider struct {
+	channelUtxos []string
+	paymentUtxos []umaprotocol.UtxoWithAmount
+}
+
+func (p *fakeUtxoProvider) GetChannelUtxos(context.Context) ([]string, error) {
+	return p.channelUtxos, nil
+}
+
+func (p *fakeUtxoProvider) GetPaymentUtxos(context.Context, string, uma.PaymentDirection) ([]umaprotocol.UtxoWithAmount, error) {
+	return p.paymentUtxos, nil
+}
+
+func TestGetPostTransactionCallbackForPayment(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	provider := &fakeUtxoProvider{paymentUtxos: []umaprotocol.UtxoWithAmount{{Utxo: "txid1:0", Amount: 1000}}}
+	callback, err := uma.GetPostTransactionCallbackForPayment(
+		context.Background(),
+		provider,
+		"abcd",
+		uma.PaymentDirectionIncoming,
+		"vasp2.com",
+		signer,
+	)
+	require.NoError(t, err)
+	require.Equal(t, provider.paymentUtxos, callback.Utxos)
+	err = uma.VerifyPostTransactionCallbackSignature(callback, getPubKeyResponse(privateKey), getNonceCache())
+	require.NoError(t, err)
+}
+
+func TestPayReqResponseUsesUtxoProvider(t *testing.T) {
+	privateKey, signer := createSigner(t)
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		privateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, privateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	metadata, err := createMetadataForBob()
+	require.NoError(t, err)
+	response, err := uma.GetPayReqResponseWithSigner(
+		*payreq,
+		&FakeInvoiceCreator{},
+		metadata,
+		umaprotocol.Currency{Code: "USD", Decimals: 2, MillisatoshiPerUnit: 24_150},
+		24_150,
+		0,
+		nil,
+		"$bob@vasp2.com",
+		signer,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		uma.WithUtxoProvider(&fakeUtxoProvider{channelUtxos: []string{"txid1:0"}}),
+	)
+	require.NoError(t, err)
+	compliance, err := response.PayeeData.Compliance()
+	require.NoError(t, err)
+	require.Equal(t, []string{"txid1:0"}, compliance.Utxos)
+}
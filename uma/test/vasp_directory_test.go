@@ -0,0 +1,109 @@
+package uma_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+func TestHttpVaspDirectoryClient(t *testing.T) {
+	var lookups []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups = append(lookups, r.URL.Path)
+		if r.URL.Path != "/vasps/vasp2.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(uma.VaspRecord{
+			Domain:           "vasp2.com",
+			Name:             "VASP 2",
+			RegulatoryStatus: uma.RegulatoryStatusLicensed,
+			Jurisdictions:    []string{"US", "CA"},
+		})
+	}))
+	defer server.Close()
+
+	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+	client := uma.NewVaspDirectoryClient(uma.NewHttpVaspDirectory(server.URL+"/"), time.Minute)
+	client.SetClock(clock)
+
+	status, err := client.RegulatoryStatus(context.Background(), "VASP2.com")
+	require.NoError(t, err)
+	require.Equal(t, uma.RegulatoryStatusLicensed, status)
+	jurisdictions, err := client.Jurisdictions(context.Background(), "vasp2.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"US", "CA"}, jurisdictions)
+
+	status, err = client.RegulatoryStatus(context.Background(), "vasp3.com")
+	require.NoError(t, err)
+	require.Equal(t, uma.RegulatoryStatusUnknown, status)
+	_, err = client.LookupVasp(context.Background(), "vasp3.com")
+	require.ErrorIs(t, err, uma.ErrVaspNotFound)
+	require.Equal(t, []string{"/vasps/vasp2.com", "/vasps/vasp3.com"}, lookups)
+
+	clock.Advance(2 * time.Minute)
+	_, err = client.LookupVasp(context.Background(), "vasp2.com")
+	require.NoError(t, err)
+	require.Len(t, lookups, 3)
+}
+
+type recordingPreScreenProvider struct {
+	uma.NoopComplianceProvider
+	records []*uma.VaspRecord
+}
+
+func (p *recordingPreScreenProvider) PreScreenPayee(ctx context.Context, _ string) error {
+	record, _ := uma.VaspRecordFromContext(ctx)
+	p.records = append(p.records, record)
+	if record != nil && record.RegulatoryStatus == uma.RegulatoryStatusSuspended {
+		return uma.ErrComplianceRejected
+	}
+	return nil
+}
+
+func TestSendLnurlpRequestWithVaspDirectory(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	receiverDomain := strings.TrimPrefix(server.URL, "http://")
+	receiverAddress := "$bob@" + receiverDomain
+
+	provider := &recordingPreScreenProvider{}
+	directory := uma.NewStaticVaspDirectory(uma.VaspRecord{
+		Domain:           receiverDomain,
+		RegulatoryStatus: uma.RegulatoryStatusSuspended,
+	})
+	_, err := uma.SendLnurlpRequest(context.Background(), umaprotocol.LnurlpRequest{ReceiverAddress: receiverAddress},
+		uma.WithComplianceProvider(provider), uma.WithVaspDirectory(directory))
+	require.ErrorIs(t, err, uma.ErrComplianceRejected)
+	require.Equal(t, receiverDomain, provider.records[0].Domain)
+	require.Equal(t, 0, requests)
+
+	failingDirectory := uma.VaspDirectoryFunc(func(context.Context, string) (*uma.VaspRecord, error) {
+		return nil, errors.New("directory unavailable")
+	})
+	_, err = uma.SendLnurlpRequest(context.Background(), umaprotocol.LnurlpRequest{ReceiverAddress: receiverAddress},
+		uma.WithComplianceProvider(provider), uma.WithVaspDirectory(failingDirectory))
+	require.ErrorContains(t, err, "directory unavailable")
+	require.Len(t, provider.records, 1)
+
+	// VASPs missing from the directory are left to the compliance provider.
+	_, err = uma.SendLnurlpRequest(context.Background(), umaprotocol.LnurlpRequest{ReceiverAddress: receiverAddress},
+		uma.WithComplianceProvider(provider), uma.WithVaspDirectory(uma.NewStaticVaspDirectory()))
+	require.Error(t, err)
+	require.Len(t, provider.records, 2)
+	require.Nil(t, provider.records[1])
+	require.Equal(t, 1, requests)
+}
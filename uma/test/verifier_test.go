@@ -0,0 +1,247 @@
+package uma_test
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	umaprotocol "github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+func createSignedLnurlpRequest(tb testing.TB, privateKey *secp256k1.PrivateKey) *umaprotocol.LnurlpRequest {
+	queryUrl, err := uma.GetSignedLnurlpRequestUrl(createPrivateKeyHandle(tb, privateKey), "$bob@vasp2.com", "vasp1.com", true, nil)
+	require.NoError(tb, err)
+	request, err := uma.ParseLnurlpRequest(*queryUrl)
+	require.NoError(tb, err)
+	return request
+}
+
+func createSignedPayRequest(t *testing.T, privateKey *secp256k1.PrivateKey) *umaprotocol.PayRequest {
+	receiverEncryptionPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payreq, err := uma.GetUmaPayRequest(
+		1000,
+		receiverEncryptionPrivateKey.PubKey().SerializeUncompressed(),
+		createPrivateKeyHandle(t, privateKey),
+		"USD",
+		true,
+		"$alice@vasp1.com",
+		1,
+		nil,
+		nil,
+		nil,
+		nil,
+		umaprotocol.KycStatusVerified,
+		nil,
+		nil,
+		"/api/lnurl/utxocallback?txid=1234",
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+	return payreq
+}
+
+func TestVerifier(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache())
+
+	lnurlpRequest := createSignedLnurlpRequest(t, privateKey)
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), lnurlpRequest))
+	// The nonce cache rejects replays.
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), lnurlpRequest))
+
+	payRequest := createSignedPayRequest(t, privateKey)
+	require.NoError(t, verifier.VerifyPayRequest(context.Background(), payRequest))
+
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, otherPrivateKey)))
+}
+
+func TestVerifierPicksUpRotatedKeys(t *testing.T) {
+	oldPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	oldPubKeyResponse := getPubKeyResponse(oldPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &oldPubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache())
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, oldPrivateKey)))
+
+	newPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	newPubKeyResponse := getPubKeyResponse(newPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &newPubKeyResponse)
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, newPrivateKey)))
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, oldPrivateKey)))
+}
+
+// copyingPublicKeyCache returns a copy of the cached PubKeyResponse on every lookup, like caches which store keys
+// outside the process.
+type copyingPublicKeyCache struct {
+	uma.PublicKeyCache
+}
+
+func (c copyingPublicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *umaprotocol.PubKeyResponse {
+	pubKeyResponse := c.PublicKeyCache.FetchPublicKeyForVasp(vaspDomain)
+	if pubKeyResponse == nil {
+		return nil
+	}
+	pubKeyResponseCopy := *pubKeyResponse
+	return &pubKeyResponseCopy
+}
+
+func TestVerifierWithCopyingPublicKeyCache(t *testing.T) {
+	oldPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := copyingPublicKeyCache{uma.NewInMemoryPublicKeyCache()}
+	oldPubKeyResponse := getPubKeyResponse(oldPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &oldPubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache())
+	for i := 0; i < 3; i++ {
+		request := createSignedLnurlpRequest(t, oldPrivateKey)
+		require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), request))
+	}
+
+	newPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	newPubKeyResponse := getPubKeyResponse(newPrivateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &newPubKeyResponse)
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, newPrivateKey)))
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, oldPrivateKey)))
+}
+
+func TestVerifierWithSignatureVerifier(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+
+	signatureVerifier := &countingSignatureVerifier{}
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache(), uma.WithSignatureVerifier(signatureVerifier))
+	require.NoError(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, privateKey)))
+	require.Equal(t, int32(1), signatureVerifier.calls)
+
+	rejectingVerifier := &countingSignatureVerifier{rejectAll: true}
+	verifier = uma.NewVerifier(pubKeyCache, getNonceCache(), uma.WithSignatureVerifier(rejectingVerifier))
+	require.Error(t, verifier.VerifyLnurlpRequest(context.Background(), createSignedLnurlpRequest(t, privateKey)))
+}
+
+func TestVerifierRejectsNonUmaRequests(t *testing.T) {
+	verifier := uma.NewVerifier(uma.NewInMemoryPublicKeyCache(), getNonceCache())
+	err := verifier.VerifyLnurlpRequest(context.Background(), &umaprotocol.LnurlpRequest{ReceiverAddress: "$bob@vasp2.com"})
+	require.Error(t, err)
+	err = verifier.VerifyPayRequest(context.Background(), &umaprotocol.PayRequest{Amount: 1000})
+	require.ErrorContains(t, err, "missing payer identifier")
+}
+
+// countingPublicKeyCache counts the lookups of a PublicKeyCache.
+type countingPublicKeyCache struct {
+	uma.PublicKeyCache
+	lookups atomic.Int32
+}
+
+func (c *countingPublicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *umaprotocol.PubKeyResponse {
+	c.lookups.Add(1)
+	return c.PublicKeyCache.FetchPublicKeyForVasp(vaspDomain)
+}
+
+func TestVerifyBatch(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	otherPrivateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	pubKeyCache := &countingPublicKeyCache{PublicKeyCache: uma.NewInMemoryPublicKeyCache()}
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, getNonceCache(), uma.WithVerifyBatchWorkers(4))
+
+	var messages []uma.SignedMessage
+	for i := 0; i < 20; i++ {
+		callback, err := uma.GetPostTransactionCallback(
+			[]umaprotocol.UtxoWithAmount{{Utxo: "abcdef12345", Amount: 1000}},
+			"vasp1.com",
+			createPrivateKeyHandle(t, privateKey),
+		)
+		require.NoError(t, err)
+		messages = append(messages, uma.PostTransactionCallbackMessage(callback))
+	}
+	forgedCallback, err := uma.GetPostTransactionCallback(nil, "vasp1.com", createPrivateKeyHandle(t, otherPrivateKey))
+	require.NoError(t, err)
+	messages = append(messages,
+		uma.PostTransactionCallbackMessage(forgedCallback),
+		uma.PostTransactionCallbackMessage(&umaprotocol.PostTransactionCallback{}),
+		uma.LnurlpRequestMessage(createSignedLnurlpRequest(t, privateKey)),
+	)
+
+	errs := verifier.VerifyBatch(context.Background(), messages)
+	require.Len(t, errs, len(messages))
+	for i := 0; i < 20; i++ {
+		require.NoError(t, errs[i])
+	}
+	require.ErrorContains(t, errs[20], "invalid uma signature")
+	require.ErrorContains(t, errs[21], "missing vaspDomain")
+	require.NoError(t, errs[22])
+	// The keys of the VASP are looked up once for the whole batch.
+	require.Equal(t, int32(1), pubKeyCache.lookups.Load())
+
+	// Replayed messages are rejected by the nonce cache.
+	for _, err := range verifier.VerifyBatch(context.Background(), messages[:20]) {
+		require.Error(t, err)
+	}
+	require.Empty(t, verifier.VerifyBatch(context.Background(), nil))
+}
+
+// setBenchmarkGoroutines makes RunParallel use about the given number of goroutines.
+func setBenchmarkGoroutines(b *testing.B, goroutines int) {
+	procs := runtime.GOMAXPROCS(0)
+	b.SetParallelism((goroutines + procs - 1) / procs)
+}
+
+func BenchmarkVerifierLnurlpRequest64Goroutines(b *testing.B) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(b, err)
+	pubKeyCache := uma.NewInMemoryPublicKeyCache()
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	pubKeyCache.AddPublicKeyForVasp("vasp1.com", &pubKeyResponse)
+	verifier := uma.NewVerifier(pubKeyCache, acceptingNonceCache{})
+	request := createSignedLnurlpRequest(b, privateKey)
+
+	setBenchmarkGoroutines(b, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := verifier.VerifyLnurlpRequest(context.Background(), request); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkVerifyUmaLnurlpQuerySignature64Goroutines(b *testing.B) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(b, err)
+	pubKeyResponse := getPubKeyResponse(privateKey)
+	request := createSignedLnurlpRequest(b, privateKey).AsUmaRequest()
+
+	setBenchmarkGoroutines(b, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := uma.VerifyUmaLnurlpQuerySignature(*request, pubKeyResponse, acceptingNonceCache{}); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
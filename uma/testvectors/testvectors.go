@@ -0,0 +1,188 @@
+// Package testvectors loads UMA test vectors in the JSON format shared between the UMA SDKs, and checks this SDK
+// against them. A vector is a signed protocol message, the public key of the VASP which signed it, and whether its
+// signature is expected to verify. Running the same vectors through every SDK catches interop regressions, such as a
+// change to a signable payload, before they reach production.
+//
+// The vectors bundled with this package were generated by this SDK from fixed test keys. Vectors produced by other
+// SDKs can be checked by loading them with Load or LoadFile.
+package testvectors
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// MessageType identifies the kind of protocol message in a Vector.
+type MessageType string
+
+const (
+	// MessageTypeLnurlpRequest is a signed lnurlp request. The message is the full request URL as a JSON string.
+	MessageTypeLnurlpRequest MessageType = "lnurlp_request"
+	// MessageTypeLnurlpResponse is a signed lnurlp response JSON object.
+	MessageTypeLnurlpResponse MessageType = "lnurlp_response"
+	// MessageTypePayRequest is a signed pay request JSON object.
+	MessageTypePayRequest MessageType = "payreq"
+	// MessageTypePayReqResponse is a signed pay request response JSON object. PayerIdentifier and PayeeIdentifier
+	// must be set, since they are part of the signed payload.
+	MessageTypePayReqResponse MessageType = "payreq_response"
+	// MessageTypePostTransactionCallback is a signed post transaction callback JSON object.
+	MessageTypePostTransactionCallback MessageType = "post_transaction_callback"
+	// MessageTypeInvoice is a signed UMA invoice. The message is the bech32-encoded invoice as a JSON string.
+	MessageTypeInvoice MessageType = "invoice"
+)
+
+// Vector is a single test vector.
+type Vector struct {
+	// Name describes the vector, e.g. "payreq with tampered amount".
+	Name string `json:"name"`
+	// Type is the kind of message in Message.
+	Type MessageType `json:"type"`
+	// SigningPubKeyHex is the hex-encoded public key of the VASP which signed the message.
+	SigningPubKeyHex string `json:"signingPubKeyHex"`
+	// Message is the signed message. See the MessageType constants for its format.
+	Message json.RawMessage `json:"message"`
+	// PayerIdentifier is the identifier of the sender, used by payreq_response vectors.
+	PayerIdentifier string `json:"payerIdentifier,omitempty"`
+	// PayeeIdentifier is the identifier of the receiver, used by payreq_response vectors.
+	PayeeIdentifier string `json:"payeeIdentifier,omitempty"`
+	// Valid is whether the signature is expected to verify.
+	Valid bool `json:"valid"`
+}
+
+// File is the top-level structure of a test vector file.
+type File struct {
+	// Generator identifies the SDK and version which produced the vectors.
+	Generator string `json:"generator"`
+	// Vectors are the test vectors in the file.
+	Vectors []Vector `json:"vectors"`
+}
+
+//go:embed vectors.json
+var bundledVectors []byte
+
+// Bundled returns the test vectors bundled with this package.
+func Bundled() ([]Vector, error) {
+	return Load(bytes.NewReader(bundledVectors))
+}
+
+// Load reads test vectors in the shared JSON format.
+func Load(reader io.Reader) ([]Vector, error) {
+	var file File
+	if err := json.NewDecoder(reader).Decode(&file); err != nil {
+		return nil, err
+	}
+	return file.Vectors, nil
+}
+
+// LoadFile reads test vectors from a file in the shared JSON format.
+func LoadFile(path string) ([]Vector, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	return Load(file)
+}
+
+// Verify parses the vector's message and verifies its signature with this SDK. A nil error means the signature is
+// valid, regardless of Valid.
+func (v Vector) Verify() error {
+	pubKeyResponse := protocol.PubKeyResponse{SigningPubKeyHex: &v.SigningPubKeyHex}
+	// Vectors are signed with fixed timestamps, and each is checked against a fresh cache so that it can be re-run.
+	nonceCache := uma.NewInMemoryNonceCache(time.Unix(0, 0))
+	switch v.Type {
+	case MessageTypeLnurlpRequest:
+		var rawUrl string
+		if err := json.Unmarshal(v.Message, &rawUrl); err != nil {
+			return err
+		}
+		requestUrl, err := url.Parse(rawUrl)
+		if err != nil {
+			return err
+		}
+		request, err := uma.ParseLnurlpRequest(*requestUrl)
+		if err != nil {
+			return err
+		}
+		umaRequest := request.AsUmaRequest()
+		if umaRequest == nil {
+			return errors.New("not an UMA lnurlp request")
+		}
+		return uma.VerifyUmaLnurlpQuerySignature(*umaRequest, pubKeyResponse, nonceCache)
+	case MessageTypeLnurlpResponse:
+		response, err := uma.ParseLnurlpResponse(v.Message)
+		if err != nil {
+			return err
+		}
+		umaResponse := response.AsUmaResponse()
+		if umaResponse == nil {
+			return errors.New("not an UMA lnurlp response")
+		}
+		return uma.VerifyUmaLnurlpResponseSignature(*umaResponse, pubKeyResponse, nonceCache)
+	case MessageTypePayRequest:
+		request, err := uma.ParsePayRequest(v.Message)
+		if err != nil {
+			return err
+		}
+		return uma.VerifyPayReqSignature(request, pubKeyResponse, nonceCache)
+	case MessageTypePayReqResponse:
+		response, err := uma.ParsePayReqResponse(v.Message)
+		if err != nil {
+			return err
+		}
+		return uma.VerifyPayReqResponseSignature(response, pubKeyResponse, nonceCache, v.PayerIdentifier, v.PayeeIdentifier)
+	case MessageTypePostTransactionCallback:
+		callback, err := uma.ParsePostTransactionCallback(v.Message)
+		if err != nil {
+			return err
+		}
+		return uma.VerifyPostTransactionCallbackSignature(callback, pubKeyResponse, nonceCache)
+	case MessageTypeInvoice:
+		var encodedInvoice string
+		if err := json.Unmarshal(v.Message, &encodedInvoice); err != nil {
+			return err
+		}
+		invoice, err := uma.DecodeUmaInvoice(encodedInvoice)
+		if err != nil {
+			return err
+		}
+		return uma.VerifyUmaInvoiceSignature(*invoice, pubKeyResponse)
+	default:
+		return fmt.Errorf("unknown message type: %s", v.Type)
+	}
+}
+
+// Check verifies the vector and returns an error if the result does not match Valid.
+func (v Vector) Check() error {
+	err := v.Verify()
+	if v.Valid && err != nil {
+		return fmt.Errorf("%s: expected valid signature, got error: %w", v.Name, err)
+	}
+	if !v.Valid && err == nil {
+		return fmt.Errorf("%s: expected invalid signature, but it verified", v.Name)
+	}
+	return nil
+}
+
+// CheckAll checks every vector and returns the errors for the vectors whose result did not match.
+func CheckAll(vectors []Vector) []error {
+	var errs []error
+	for _, vector := range vectors {
+		if err := vector.Check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
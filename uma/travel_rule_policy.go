@@ -0,0 +1,163 @@
+package uma
+
+import (
+	"slices"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// MillisatoshiCurrencyCode is the currency code of TravelRulePayment amounts which are in millisatoshis, i.e. when the
+// pay request amount is not in the receiving currency.
+const MillisatoshiCurrencyCode = "MSAT"
+
+// TravelRulePayment describes an outgoing payment for a TravelRulePolicy.
+type TravelRulePayment struct {
+	// ReceiverAddress is the UMA address of the receiver, if known. For example, $bob@vasp2.com.
+	ReceiverAddress string
+	// Jurisdiction is the jurisdiction of the payment set with WithTravelRuleJurisdiction, if any. For example, the
+	// country code of the receiving VASP.
+	Jurisdiction string
+	// Amount is the amount of the payment in the smallest unit of CurrencyCode. It is nil when building the lnurlp
+	// request, before the amount is known.
+	Amount *int64
+	// CurrencyCode is the currency of Amount: the receiving currency code, or MillisatoshiCurrencyCode.
+	CurrencyCode string
+}
+
+// TravelRuleDecision is the result of evaluating a TravelRulePolicy for a payment.
+type TravelRuleDecision struct {
+	// IsSubjectToTravelRule is whether the payment is subject to the travel rule. When building an lnurlp request, it
+	// is whether the sending VASP may need travel rule information for payments to the receiver. Travel rule
+	// information is only included in pay requests which are subject to the travel rule.
+	IsSubjectToTravelRule bool
+	// PayerDataFields are the optional payer data fields to include in the pay request, such as "name" and "email".
+	// The identifier and compliance fields are always included.
+	PayerDataFields []string
+}
+
+// TravelRulePolicy decides whether outgoing payments are subject to the travel rule and which payer data is sent for
+// them. Set it with WithTravelRulePolicy to have SignLnurlpRequest and GetUmaPayRequest apply it automatically.
+// Implementations of this interface should be thread-safe.
+type TravelRulePolicy interface {
+	EvaluateTravelRule(payment TravelRulePayment) (TravelRuleDecision, error)
+}
+
+// TravelRulePolicyFunc adapts a function to a TravelRulePolicy.
+type TravelRulePolicyFunc func(payment TravelRulePayment) (TravelRuleDecision, error)
+
+func (f TravelRulePolicyFunc) EvaluateTravelRule(payment TravelRulePayment) (TravelRuleDecision, error) {
+	return f(payment)
+}
+
+// TravelRuleThreshold is the amount from which payments in a currency are subject to the travel rule.
+type TravelRuleThreshold struct {
+	// Jurisdiction is the jurisdiction the threshold applies to. If empty, it applies to all jurisdictions which have
+	// no threshold of their own for the currency.
+	Jurisdiction string
+	// CurrencyCode is the currency of Amount, or MillisatoshiCurrencyCode.
+	CurrencyCode string
+	// Amount is the smallest amount, in the smallest unit of the currency, which is subject to the travel rule.
+	Amount int64
+	// PayerDataFields are the optional payer data fields to include in payments which are subject to the travel rule.
+	PayerDataFields []string
+}
+
+// ThresholdTravelRulePolicy is a TravelRulePolicy configured with amount thresholds per currency and jurisdiction.
+//
+// Payments in a jurisdiction without any threshold are not subject to the travel rule. Payments in a jurisdiction
+// with thresholds, but none for the currency of the payment, are subject to the travel rule, so that unexpected
+// currencies fail safe.
+type ThresholdTravelRulePolicy struct {
+	Thresholds []TravelRuleThreshold
+	// PayerDataFields are the optional payer data fields to include in payments which are not subject to the travel
+	// rule.
+	PayerDataFields []string
+}
+
+func (p ThresholdTravelRulePolicy) EvaluateTravelRule(payment TravelRulePayment) (TravelRuleDecision, error) {
+	thresholds := p.applicableThresholds(payment.Jurisdiction)
+	if len(thresholds) == 0 {
+		return TravelRuleDecision{PayerDataFields: p.PayerDataFields}, nil
+	}
+	if payment.Amount == nil {
+		return TravelRuleDecision{IsSubjectToTravelRule: true}, nil
+	}
+	for _, threshold := range thresholds {
+		if threshold.CurrencyCode != payment.CurrencyCode {
+			continue
+		}
+		if *payment.Amount < threshold.Amount {
+			return TravelRuleDecision{PayerDataFields: p.PayerDataFields}, nil
+		}
+		return TravelRuleDecision{IsSubjectToTravelRule: true, PayerDataFields: threshold.PayerDataFields}, nil
+	}
+	var payerDataFields []string
+	for _, threshold := range thresholds {
+		payerDataFields = append(payerDataFields, threshold.PayerDataFields...)
+	}
+	return TravelRuleDecision{IsSubjectToTravelRule: true, PayerDataFields: payerDataFields}, nil
+}
+
+// applicableThresholds returns the thresholds of a jurisdiction, followed by the thresholds for all jurisdictions in
+// currencies the jurisdiction has no threshold for.
+func (p ThresholdTravelRulePolicy) applicableThresholds(jurisdiction string) []TravelRuleThreshold {
+	var thresholds []TravelRuleThreshold
+	var currencies []string
+	if jurisdiction != "" {
+		for _, threshold := range p.Thresholds {
+			if threshold.Jurisdiction == jurisdiction {
+				thresholds = append(thresholds, threshold)
+				currencies = append(currencies, threshold.CurrencyCode)
+			}
+		}
+	}
+	for _, threshold := range p.Thresholds {
+		if threshold.Jurisdiction == "" && !slices.Contains(currencies, threshold.CurrencyCode) {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	return thresholds
+}
+
+// WithTravelRulePolicy sets the TravelRulePolicy which SignLnurlpRequest uses to set IsSubjectToTravelRule when it is
+// not set, and which GetUmaPayRequest uses to decide whether to include travel rule information and which optional
+// payer data fields to send.
+func WithTravelRulePolicy(policy TravelRulePolicy) Option {
+	return func(o *options) {
+		o.travelRulePolicy = policy
+	}
+}
+
+// WithTravelRuleJurisdiction sets the jurisdiction passed to the TravelRulePolicy for a payment, for example the
+// country code of the receiving VASP.
+func WithTravelRuleJurisdiction(jurisdiction string) Option {
+	return func(o *options) {
+		o.travelRuleJurisdiction = jurisdiction
+	}
+}
+
+// evaluateTravelRule evaluates the TravelRulePolicy for a payment, or returns nil if there is no policy.
+func (o *options) evaluateTravelRule(payment TravelRulePayment) (*TravelRuleDecision, error) {
+	if o.travelRulePolicy == nil {
+		return nil, nil
+	}
+	payment.Jurisdiction = o.travelRuleJurisdiction
+	decision, err := o.travelRulePolicy.EvaluateTravelRule(payment)
+	if err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+// trimPayerDataToDecision removes the optional payer data fields which the decision does not include.
+func trimPayerDataToDecision(payerData protocol.PayerData, decision TravelRuleDecision) {
+	for field := range payerData {
+		if field == protocol.CounterPartyDataFieldIdentifier.String() ||
+			field == protocol.CounterPartyDataFieldCompliance.String() {
+			continue
+		}
+		if !slices.Contains(decision.PayerDataFields, field) {
+			delete(payerData, field)
+		}
+	}
+}
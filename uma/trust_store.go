@@ -0,0 +1,192 @@
+package uma
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// TrustStore holds public keys of counterparty VASPs which are pinned, for example from configuration, rather than
+// fetched from their /.well-known/lnurlpubkey endpoint. When set with WithTrustStore, pinned keys take precedence over
+// cached and fetched keys, which protects against DNS hijacks of a counterparty's domain. Use CheckPins to detect when
+// the keys a counterparty publishes no longer match the pinned ones.
+//
+// A TrustStore is safe for concurrent use.
+type TrustStore struct {
+	mutex sync.RWMutex
+	pins  map[string]*protocol.PubKeyResponse
+}
+
+// NewTrustStore Creates an empty TrustStore.
+func NewTrustStore() *TrustStore {
+	return &TrustStore{pins: make(map[string]*protocol.PubKeyResponse)}
+}
+
+// LoadTrustStore Creates a TrustStore from a JSON object mapping VASP domains to their pinned public keys, in the
+// same format as the PubKeyResponse served at /.well-known/lnurlpubkey. For example:
+//
+//	{"vasp1.com": {"signingPubKey": "04...", "encryptionPubKey": "04..."}}
+//
+// Args:
+//
+//	data: the JSON configuration.
+func LoadTrustStore(data []byte) (*TrustStore, error) {
+	var pins map[string]*protocol.PubKeyResponse
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, err
+	}
+	store := NewTrustStore()
+	for vaspDomain, pubKeyResponse := range pins {
+		if err := store.Pin(vaspDomain, pubKeyResponse); err != nil {
+			return nil, fmt.Errorf("%s: %w", vaspDomain, err)
+		}
+	}
+	return store, nil
+}
+
+// Pin pins the public keys of a VASP, replacing any keys pinned for it before.
+//
+// Args:
+//
+//	vaspDomain: the domain of the VASP.
+//	pubKeyResponse: the public keys to pin. The signing key must be set.
+func (s *TrustStore) Pin(vaspDomain string, pubKeyResponse *protocol.PubKeyResponse) error {
+	if pubKeyResponse == nil {
+		return errors.New("missing public keys")
+	}
+	if _, err := pubKeyResponse.SigningPubKey(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pins[vaspDomain] = pubKeyResponse
+	return nil
+}
+
+// Unpin removes the pinned public keys of a VASP, so that its keys are fetched again.
+func (s *TrustStore) Unpin(vaspDomain string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pins, vaspDomain)
+}
+
+// pinnedKeys returns the pinned public keys of a VASP, or nil if none are pinned. It is safe to call on a nil
+// TrustStore.
+func (s *TrustStore) pinnedKeys(vaspDomain string) *protocol.PubKeyResponse {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.pins[vaspDomain]
+}
+
+// WithTrustStore sets a TrustStore whose pinned keys are used instead of cached or fetched keys.
+func WithTrustStore(store *TrustStore) Option {
+	return func(o *options) {
+		o.trustStore = store
+	}
+}
+
+// PinMismatchError is returned by CheckPins when the public keys a VASP publishes differ from the pinned ones. This
+// happens when the VASP rotated its keys, but may also mean that its domain was hijacked.
+type PinMismatchError struct {
+	VaspDomain string
+	Pinned     *protocol.PubKeyResponse
+	Fetched    *protocol.PubKeyResponse
+}
+
+func (e PinMismatchError) Error() string {
+	return fmt.Sprintf("public keys published by %s do not match the pinned keys", e.VaspDomain)
+}
+
+// CheckPins Fetches the public keys published by every pinned VASP, bypassing the TrustStore and any cache, and
+// compares them with the pinned keys. Each mismatch is logged as LogEventPubKeyPinMismatch and returned as a
+// PinMismatchError, so that operators can be alerted and review the change before updating the pin.
+//
+// The returned error joins the mismatches and the errors of failed fetches, or is nil if all keys match.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	opts: optional settings such as WithLogger and WithRequestDoer.
+func (s *TrustStore) CheckPins(ctx context.Context, opts ...Option) error {
+	o := newOptions(opts)
+	s.mutex.RLock()
+	vaspDomains := make([]string, 0, len(s.pins))
+	for vaspDomain := range s.pins {
+		vaspDomains = append(vaspDomains, vaspDomain)
+	}
+	s.mutex.RUnlock()
+	sort.Strings(vaspDomains)
+
+	var errs []error
+	for _, vaspDomain := range vaspDomains {
+		pinned := s.pinnedKeys(vaspDomain)
+		if pinned == nil {
+			continue
+		}
+		fetched, err := o.fetchPublicKey(ctx, vaspDomain)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", vaspDomain, err))
+			continue
+		}
+		if publishedKeysMatchPin(pinned, fetched, o.clock.Now()) {
+			continue
+		}
+		o.log(ctx, slog.LevelError, LogEventPubKeyPinMismatch, slog.String("counterparty", vaspDomain))
+		errs = append(errs, PinMismatchError{VaspDomain: vaspDomain, Pinned: pinned, Fetched: fetched})
+	}
+	return errors.Join(errs...)
+}
+
+// publishedKeysMatchPin returns whether the signing key a VASP publishes is one of its pinned signing keys, and its
+// encryption key is the pinned one, if any. Pinned signing keys which expired before now do not match.
+func publishedKeysMatchPin(pinned *protocol.PubKeyResponse, fetched *protocol.PubKeyResponse, now time.Time) bool {
+	fetchedSigningKey, err := fetched.SigningPubKey()
+	if err != nil {
+		return false
+	}
+	signingKeyMatches := false
+	if pinnedSigningKey, err := pinned.SigningPubKey(); err == nil {
+		signingKeyMatches = samePubKey(pinnedSigningKey, fetchedSigningKey)
+	}
+	for _, signingKey := range pinned.SigningKeys {
+		additionalKey, err := signingKey.PubKey()
+		if err == nil && !signingKey.IsExpired(now) && samePubKey(additionalKey, fetchedSigningKey) {
+			signingKeyMatches = true
+		}
+	}
+	if !signingKeyMatches {
+		return false
+	}
+	pinnedEncryptionKey, err := pinned.EncryptionPubKey()
+	if err != nil {
+		// Only the signing key is pinned.
+		return true
+	}
+	fetchedEncryptionKey, err := fetched.EncryptionPubKey()
+	return err == nil && samePubKey(pinnedEncryptionKey, fetchedEncryptionKey)
+}
+
+// samePubKey returns whether two SEC1-encoded secp256k1 public keys are the same, even if one is compressed.
+func samePubKey(a []byte, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	parsedA, err := secp256k1.ParsePubKey(a)
+	if err != nil {
+		return false
+	}
+	parsedB, err := secp256k1.ParsePubKey(b)
+	return err == nil && parsedA.IsEqual(parsedB)
+}
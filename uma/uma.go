@@ -1,23 +1,21 @@
 package uma
 
 import (
-	"crypto"
-	"crypto/rand"
+	"context"
+	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
+	"log/slog"
 	"math"
-	"math/big"
 	"net/http"
 	"net/url"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/decred/dcrd/dcrec/secp256k1/v4"
-	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	eciesgo "github.com/ecies/go/v2"
 	"github.com/google/uuid"
 	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
@@ -26,8 +24,9 @@ import (
 
 // FetchPublicKeyForVasp fetches the public key for another VASP.
 //
-// If the public key is not in the cache, it will be fetched from the VASP's domain.
-// The public key will be cached for future use.
+// If the public key is not in the cache, it will be fetched from the VASP's domain. Keys served from another
+// registrable domain after a redirect are rejected. The public key will be cached for future use. Keys pinned in a
+// TrustStore set with WithTrustStore take precedence over both.
 //
 // NOTE: localhost domains will be fetched over HTTP for testing purposes, all other
 // domains will be fetched over HTTPS.
@@ -36,33 +35,52 @@ import (
 //
 //	vaspDomain: the domain of the VASP.
 //	cache: the PublicKeyCache cache to use. You can use the InMemoryPublicKeyCache struct, or implement your own persistent cache with any storage type.
-func FetchPublicKeyForVasp(vaspDomain string, cache PublicKeyCache) (*protocol.PubKeyResponse, error) {
+//	opts: optional settings such as WithRequestDoer.
+func FetchPublicKeyForVasp(vaspDomain string, cache PublicKeyCache, opts ...Option) (*protocol.PubKeyResponse, error) {
+	return FetchPublicKeyForVaspWithContext(context.Background(), vaspDomain, cache, opts...)
+}
+
+// FetchPublicKeyForVaspWithContext is the same as FetchPublicKeyForVasp, but uses the given context for the
+// outbound request.
+func FetchPublicKeyForVaspWithContext(
+	ctx context.Context,
+	vaspDomain string,
+	cache PublicKeyCache,
+	opts ...Option,
+) (*protocol.PubKeyResponse, error) {
+	o := newOptions(opts)
+	if pinnedKeys := o.trustStore.pinnedKeys(vaspDomain); pinnedKeys != nil {
+		return pinnedKeys, nil
+	}
 	publicKey := cache.FetchPublicKeyForVasp(vaspDomain)
+	o.recordPublicKeyCacheLookup(ctx, publicKey != nil)
 	if publicKey != nil {
 		return publicKey, nil
 	}
 
-	scheme := "https://"
-	if utils.IsDomainLocalhost(vaspDomain) {
-		scheme = "http://"
-	}
-	resp, err := http.Get(scheme + vaspDomain + "/.well-known/lnurlpubkey")
+	pubKeyResponse, err := o.fetchPublicKey(ctx, vaspDomain)
 	if err != nil {
 		return nil, err
 	}
+	o.observeKeyChange(ctx, vaspDomain, cache, pubKeyResponse)
+	cache.AddPublicKeyForVasp(vaspDomain, pubKeyResponse)
+	return pubKeyResponse, nil
+}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(resp.Body)
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New("invalid response from VASP")
-	}
+// publicKeyUrl returns the URL at which a VASP publishes its public keys.
+func (o *options) publicKeyUrl(vaspDomain string) string {
+	return o.domainPolicy.Scheme(vaspDomain) + "://" + vaspDomain + "/.well-known/lnurlpubkey"
+}
 
-	responseBodyBytes, err := io.ReadAll(resp.Body)
+// fetchPublicKey fetches the public keys of a VASP from its domain, bypassing any cache.
+func (o *options) fetchPublicKey(ctx context.Context, vaspDomain string) (*protocol.PubKeyResponse, error) {
+	responseBodyBytes, err := o.sendRequest(ctx, outboundRequest{
+		method:      http.MethodGet,
+		url:         o.publicKeyUrl(vaspDomain),
+		idempotent:  true,
+		expectsJson: true,
+		vaspDomain:  vaspDomain,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -72,8 +90,7 @@ func FetchPublicKeyForVasp(vaspDomain string, cache PublicKeyCache) (*protocol.P
 	if err != nil {
 		return nil, err
 	}
-
-	cache.AddPublicKeyForVasp(vaspDomain, &pubKeyResponse)
+	o.log(ctx, slog.LevelInfo, LogEventPubKeyFetched, slog.String("counterparty", vaspDomain))
 	return &pubKeyResponse, nil
 }
 
@@ -108,32 +125,53 @@ func GetPubKeyResponse(
 	}, nil
 }
 
-func GenerateNonce() (*string, error) {
-	randomBigInt, err := rand.Int(rand.Reader, big.NewInt(0xFFFFFFFF))
+// GetEd25519PubKeyResponse Creates a public key response advertising an Ed25519 signing key, for VASPs signing with
+// an Ed25519Signer. Encryption keys are always secp256k1.
+//
+// Args:
+//
+//	signingPubKey: The Ed25519 public key used to verify signatures from a VASP.
+//	encryptionCertChainPem: The PEM-encoded certificate chain used to encrypt TR info sent to a VASP.
+//	expirationTimestamp: Seconds since epoch at which these pub keys must be refreshed. It can be safely cached until this expiration (or forever if null).
+func GetEd25519PubKeyResponse(
+	signingPubKey ed25519.PublicKey,
+	encryptionCertChainPem string,
+	expirationTimestamp *int64,
+) (*protocol.PubKeyResponse, error) {
+	if len(signingPubKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key length")
+	}
+	encryptionPubKey, err := utils.ExtractPubkeyFromPemCertificateChain(&encryptionCertChainPem)
 	if err != nil {
 		return nil, err
 	}
-	nonce := strconv.FormatUint(randomBigInt.Uint64(), 10)
-	return &nonce, nil
+	signingPubKeyHex := hex.EncodeToString(signingPubKey)
+	encryptionPubKeyHex := hex.EncodeToString(encryptionPubKey.SerializeUncompressed())
+	signingAlgorithm := protocol.SigningAlgorithmEd25519
+	return &protocol.PubKeyResponse{
+		EncryptionCertChain: &encryptionCertChainPem,
+		SigningPubKeyHex:    &signingPubKeyHex,
+		EncryptionPubKeyHex: &encryptionPubKeyHex,
+		ExpirationTimestamp: expirationTimestamp,
+		SigningAlgorithm:    &signingAlgorithm,
+	}, nil
 }
 
-func signPayloadToBytes(payload []byte, privateKeyBytes []byte) ([]byte, error) {
-	privateKey := secp256k1.PrivKeyFromBytes(privateKeyBytes)
-	hash := crypto.SHA256.New()
-	_, err := hash.Write(payload)
-	if err != nil {
-		return nil, err
-	}
-	hashedPayload := hash.Sum(nil)
-	signature, err := privateKey.ToECDSA().Sign(rand.Reader, hashedPayload, crypto.SHA256)
-	if err != nil {
-		return nil, err
+// GenerateNonce returns a new nonce from DefaultNonceGenerator. Use WithNonceGenerator to change how the SDK generates
+// nonces.
+func GenerateNonce() (*string, error) {
+	return newOptions(nil).generateNonce()
+}
+
+func signPayloadToBytes(payload []byte, privateKey *PrivateKeyHandle) ([]byte, error) {
+	if privateKey == nil {
+		return nil, errors.New("missing private key")
 	}
-	return signature, nil
+	return privateKey.Sign(payload)
 }
 
-func signPayload(payload []byte, privateKeyBytes []byte) (*string, error) {
-	signature, err := signPayloadToBytes(payload, privateKeyBytes)
+func signPayload(payload []byte, privateKey *PrivateKeyHandle) (*string, error) {
+	signature, err := signPayloadToBytes(payload, privateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -144,12 +182,37 @@ func signPayload(payload []byte, privateKeyBytes []byte) (*string, error) {
 // VerifyPayReqSignature Verifies the signature on an uma pay request based on the public key of the VASP making the
 // request.
 //
+// A PubKeyResponse does not record the domain it was fetched from, so VerifyPayReqSignature cannot tell on its own
+// whether the signing VASP is the VASP of the payer. Callers must pass WithSenderVaspDomain with the domain they
+// fetched otherVaspPubKeyResponse from, unless they fetched it from the domain of the payer identifier. Otherwise, any
+// VASP whose keys are passed can sign pay requests for payers of other VASPs. Verifier and PayRequestHandler pass it.
+//
 // Args:
 //
 //	query: the signed query to verify.
 //	otherVaspPubKeyResponse: the PubKeyResponse of the VASP making this request.
 //	nonceCache: the NonceCache cache to use to prevent replay attacks.
-func VerifyPayReqSignature(query *protocol.PayRequest, otherVaspPubKeyResponse protocol.PubKeyResponse, nonceCache NonceCache) error {
+//	opts: optional settings such as WithLogger. Pass WithSenderVaspDomain to check that the payer identifier belongs
+//	  to the VASP the public keys were fetched from, or WithoutPayerIdentifierDomainCheck to skip that check.
+func VerifyPayReqSignature(
+	query *protocol.PayRequest,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	if nonceCache == nil {
+		return ErrNonceCacheRequired
+	}
+	return newOptions(opts).verifyPayReqSignature(query, otherVaspPubKeyResponse, nonceCache)
+}
+
+// verifyPayReqSignature is VerifyPayReqSignature, except that the nonce is not checked if nonceCache is nil, for
+// callers which check it separately with checkPayRequestNonce.
+func (o *options) verifyPayReqSignature(
+	query *protocol.PayRequest,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+) error {
 	complianceData, err := query.PayerData.Compliance()
 	if err != nil {
 		return err
@@ -157,103 +220,66 @@ func VerifyPayReqSignature(query *protocol.PayRequest, otherVaspPubKeyResponse p
 	if complianceData == nil {
 		return errors.New("missing compliance data")
 	}
-	err = nonceCache.CheckAndSaveNonce(
-		complianceData.SignatureNonce,
-		time.Unix(complianceData.SignatureTimestamp, 0),
-	)
-	if err != nil {
-		return err
-	}
 	signablePayload, err := query.SignablePayload()
 	if err != nil {
 		return err
 	}
-	return verifySignature(signablePayload, complianceData.Signature, otherVaspPubKeyResponse)
-}
-
-// verifySignature Verifies the signature of the uma request.
-//
-// Args:
-//
-//	payload: the payload that was signed.
-//	signature: the hex-encoded signature.
-//	otherVaspPubKeyResponse: the PubKeyResponse of the VASP who signed the payload.
-func verifySignature(payload []byte, signature string, otherVaspPubKeyResponse protocol.PubKeyResponse) error {
-	decodedSignature, err := hex.DecodeString(signature)
-	if err != nil {
-		return err
-	}
-	parsedSignature, err := ecdsa.ParseDERSignature(decodedSignature)
-	if err != nil {
-		return err
+	payerIdentifier := ""
+	if identifier := query.PayerData.Identifier(); identifier != nil {
+		payerIdentifier = *identifier
 	}
-	pubKey, err := otherVaspPubKeyResponse.SigningPubKey()
+	payloads, err := schemePayloads(signablePayload, query, payRequestUnsignedFields...)
 	if err != nil {
 		return err
 	}
-	secp256k1Key, err := secp256k1.ParsePubKey(pubKey)
-	if err != nil {
-		return err
-	}
-	sha256 := crypto.SHA256.New()
-	_, err = sha256.Write(payload)
+	err = o.verifyMessage(
+		MessageTypePayRequest,
+		domainOfIdentifier(payerIdentifier),
+		nonceCache,
+		complianceData.SignatureNonce,
+		time.Unix(complianceData.SignatureTimestamp, 0),
+		payloads,
+		complianceData.Signature,
+		otherVaspPubKeyResponse,
+	)
 	if err != nil {
 		return err
 	}
-	hashedPayload := sha256.Sum(nil)
-	verified := parsedSignature.Verify(hashedPayload, secp256k1Key)
-
-	if !verified {
-		return errors.New("invalid uma signature")
-	}
-
-	return nil
+	return o.checkPayerIdentifierDomain(payerIdentifier)
 }
 
 // GetSignedLnurlpRequestUrl Creates a signed uma request URL. Should only be used for UMA requests.
 //
+// Each call signs the request with a fresh nonce and timestamp, so after a version negotiation failure the sender can
+// regenerate the request at the negotiated version in one call, e.g. with the version returned by
+// SelectHighestSupportedVersion for the SupportedMajorVersions of the UnsupportedVersionError.
+//
 // Args:
 //
-//	signingPrivateKey: the private key of the VASP that is sending the payment. This will be used to sign the request.
+//	signingPrivateKey: a PrivateKeyHandle holding the private key of the VASP that is sending the payment. This will be used to sign the request.
 //	receiverAddress: the address of the receiver of the payment (i.e. $bob@vasp2).
 //	senderVaspDomain: the domain of the VASP that is sending the payment. It will be used by the receiver to fetch the public keys of the sender.
 //	isSubjectToTravelRule: whether the sending VASP is a financial institution that requires travel rule information.
 //	umaVersionOverride: the version of the UMA protocol to use. If not specified, the latest version will be used.
+//	opts: optional settings such as WithClock and WithNonceGenerator.
 func GetSignedLnurlpRequestUrl(
-	signingPrivateKey []byte,
+	signingPrivateKey *PrivateKeyHandle,
 	receiverAddress string,
 	senderVaspDomain string,
 	isSubjectToTravelRule bool,
 	umaVersionOverride *string,
+	opts ...Option,
 ) (*url.URL, error) {
-	nonce, err := GenerateNonce()
-	if err != nil {
-		return nil, err
-	}
-	umaVersion := UmaProtocolVersion
-	if umaVersionOverride != nil {
-		umaVersion = *umaVersionOverride
-	}
-	now := time.Now()
-	unsignedRequest := protocol.LnurlpRequest{
+	signedRequest, err := SignLnurlpRequest(protocol.LnurlpRequest{
 		ReceiverAddress:       receiverAddress,
 		IsSubjectToTravelRule: &isSubjectToTravelRule,
 		VaspDomain:            &senderVaspDomain,
-		Timestamp:             &now,
-		Nonce:                 nonce,
-		UmaVersion:            &umaVersion,
-	}
-	signablePayload, err := unsignedRequest.SignablePayload()
+		UmaVersion:            umaVersionOverride,
+	}, signerForHandle(signingPrivateKey), opts...)
 	if err != nil {
 		return nil, err
 	}
-	signature, err := signPayload(signablePayload, signingPrivateKey)
-	if err != nil {
-		return nil, err
-	}
-	unsignedRequest.Signature = signature
-
-	return unsignedRequest.EncodeToUrl()
+	return signedRequest.EncodeToUrl()
 }
 
 // IsUmaLnurlpQuery Checks if the given URL is a valid UMA request. If this returns false,
@@ -273,8 +299,9 @@ func IsUmaLnurlpQuery(url url.URL) bool {
 // Args:
 //
 //	url: the full URL of the uma request.
-func ParseLnurlpRequest(url url.URL) (*protocol.LnurlpRequest, error) {
-	return ParseLnurlpRequestWithReceiverDomain(url, url.Host)
+//	opts: optional settings such as WithRawPayloadCapture.
+func ParseLnurlpRequest(url url.URL, opts ...Option) (*protocol.LnurlpRequest, error) {
+	return ParseLnurlpRequestWithReceiverDomain(url, url.Host, opts...)
 }
 
 // ParseLnurlpRequestWithReceiverDomain Parses the message into an LnurlpRequest object using an overridden receiver UMA domain.
@@ -285,8 +312,13 @@ func ParseLnurlpRequest(url url.URL) (*protocol.LnurlpRequest, error) {
 //
 //	url: the full URL of the uma request.
 //	receiverDomain: the domain of the receiver UMA of the payment. This is used to override the domain in the URL.
-func ParseLnurlpRequestWithReceiverDomain(url url.URL, receiverDomain string) (*protocol.LnurlpRequest, error) {
+//	opts: optional settings such as WithRawPayloadCapture.
+func ParseLnurlpRequestWithReceiverDomain(url url.URL, receiverDomain string, opts ...Option) (*protocol.LnurlpRequest, error) {
+	o := newOptions(opts)
 	query := url.Query()
+	if err := o.checkStrict(MessageTypeLnurlpRequest, lnurlpRequestViolations(query)); err != nil {
+		return nil, err
+	}
 	signature := query.Get("signature")
 	vaspDomain := query.Get("vaspDomain")
 	nonce := query.Get("nonce")
@@ -315,8 +347,7 @@ func ParseLnurlpRequestWithReceiverDomain(url url.URL, receiverDomain string) (*
 		return nil, errors.New("invalid uma request path")
 	}
 	username := pathParts[3]
-	var validUsernameRegex = regexp.MustCompile(`^[$a-zA-Z0-9._\-+]+$`)
-	if !validUsernameRegex.MatchString(username) {
+	if !umaUsernameRegex.MatchString(username) {
 		return nil, errors.New("invalid uma username")
 	}
 	receiverAddress := username + "@" + receiverDomain
@@ -336,6 +367,7 @@ func ParseLnurlpRequestWithReceiverDomain(url url.URL, receiverDomain string) (*
 		Nonce:                 nilIfEmpty(nonce),
 		Timestamp:             timestampAsTime,
 		IsSubjectToTravelRule: &isSubjectToTravelRule,
+		RawResponse:           o.rawPayload([]byte(url.String())),
 	}, nil
 }
 
@@ -346,16 +378,38 @@ func ParseLnurlpRequestWithReceiverDomain(url url.URL, receiverDomain string) (*
 //	query: the signed query to verify.
 //	otherVaspPubKeyResponse: the PubKeyResponse of the VASP making this request in bytes.
 //	nonceCache: the NonceCache cache to use to prevent replay attacks.
-func VerifyUmaLnurlpQuerySignature(query protocol.UmaLnurlpRequest, otherVaspPubKeyResponse protocol.PubKeyResponse, nonceCache NonceCache) error {
-	err := nonceCache.CheckAndSaveNonce(query.Nonce, query.Timestamp)
+//	opts: optional settings such as WithLogger and WithEventSink.
+func VerifyUmaLnurlpQuerySignature(
+	query protocol.UmaLnurlpRequest,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	signablePayload, err := query.SignablePayload()
 	if err != nil {
 		return err
 	}
-	signablePayload, err := query.SignablePayload()
+	o := newOptions(opts)
+	err = o.verifySignedMessage(
+		MessageTypeLnurlpRequest,
+		query.VaspDomain,
+		nonceCache,
+		query.Nonce,
+		query.Timestamp,
+		signablePayload,
+		query.Signature,
+		otherVaspPubKeyResponse,
+	)
 	if err != nil {
 		return err
 	}
-	return verifySignature(signablePayload, query.Signature, otherVaspPubKeyResponse)
+	o.emitEvent(context.Background(), &LnurlpVerifiedEvent{
+		EventMetadata:    o.eventMetadata(),
+		SenderVaspDomain: query.VaspDomain,
+		ReceiverAddress:  query.ReceiverAddress,
+		UmaVersion:       query.UmaVersion,
+	})
+	return nil
 }
 
 func GetLnurlpResponse(
@@ -364,7 +418,7 @@ func GetLnurlpResponse(
 	encodedMetadata string,
 	minSendableSats int64,
 	maxSendableSats int64,
-	privateKeyBytes *[]byte,
+	privateKey *PrivateKeyHandle,
 	requiresTravelRuleInfo *bool,
 	payerDataOptions *protocol.CounterPartyDataOptions,
 	currencyOptions *[]protocol.Currency,
@@ -372,13 +426,11 @@ func GetLnurlpResponse(
 	commentCharsAllowed *int,
 	nostrPubkey *string,
 ) (*protocol.LnurlpResponse, error) {
-	isUmaRequest := request.IsUmaRequest()
-	var complianceResponse *protocol.LnurlComplianceResponse
 	var umaVersion *string
-
-	if isUmaRequest {
+	var signer UmaSigner
+	if request.IsUmaRequest() {
 		requiredUmaFields := map[string]interface{}{
-			"privateKeyBytes":        privateKeyBytes,
+			"privateKey":             privateKey,
 			"requiresTravelRuleInfo": requiresTravelRuleInfo,
 			"payerDataOptions":       payerDataOptions,
 			"receiverKycStatus":      receiverKycStatus,
@@ -394,8 +446,177 @@ func GetLnurlpResponse(
 		if err != nil {
 			return nil, err
 		}
+		signer = signerForHandle(privateKey)
+	}
+	return getLnurlpResponse(
+		request,
+		callback,
+		encodedMetadata,
+		minSendableSats*1000,
+		maxSendableSats*1000,
+		signer,
+		umaVersion,
+		requiresTravelRuleInfo,
+		payerDataOptions,
+		currencyOptions,
+		receiverKycStatus,
+		commentCharsAllowed,
+		nostrPubkey,
+		newOptions(nil),
+	)
+}
+
+// GetLnurlpResponseWithSigner Creates an lnurlp response for the given request, signing the compliance data with an
+// UmaSigner. MinSendable and MaxSendable are derived from the convertible limits of the given currencies, and the UMA
+// version is negotiated against the version in the request. If the requested major version is not supported, an
+// UnsupportedVersionError is returned, which should be sent back to the sender with a 412 status.
+//
+// Args:
+//
+//	request: the lnurlp request from the sending VASP.
+//	callback: the URL which the sending VASP should call to make a pay request.
+//	encodedMetadata: the LNURL metadata for the receiver.
+//	currencies: the currencies which the receiver can receive. Must not be empty. With WithCurrencyCorridorPolicy,
+//		only the currencies available to the sending VASP are advertised, and an error wrapping ErrCorridorRestricted
+//		is returned if there are none.
+//	payerDataOptions: the payer data which the sending VASP must provide. Compliance and identifier fields are always
+//		required for UMA and will be added automatically.
+//	kycStatus: whether the receiving VASP has KYC information about the receiver.
+//	signer: the UmaSigner of the receiving VASP. Only used for UMA requests.
+//	requiresTravelRuleInfo: whether the receiving VASP requires travel rule information.
+//	commentCharsAllowed: the number of characters the sender may include in a comment. Nil if comments are disabled.
+//	nostrPubkey: an optional nostr pubkey used for nostr zaps (NIP-57).
+//	opts: optional settings such as WithLogger and WithCurrencyCorridorPolicy.
+func GetLnurlpResponseWithSigner(
+	request protocol.LnurlpRequest,
+	callback string,
+	encodedMetadata string,
+	currencies []protocol.Currency,
+	payerDataOptions protocol.CounterPartyDataOptions,
+	kycStatus protocol.KycStatus,
+	signer UmaSigner,
+	requiresTravelRuleInfo bool,
+	commentCharsAllowed *int,
+	nostrPubkey *string,
+	opts ...Option,
+) (*protocol.LnurlpResponse, error) {
+	if len(currencies) == 0 {
+		return nil, errors.New("at least one currency is required")
+	}
+	o := newOptions(opts)
+	if o.currencyCorridorPolicy != nil {
+		senderVaspDomain := ""
+		if request.VaspDomain != nil {
+			senderVaspDomain = *request.VaspDomain
+		}
+		var err error
+		currencies, err = o.currencyCorridorPolicy.FilterCurrencies(context.Background(), senderVaspDomain, currencies)
+		if err != nil {
+			return nil, err
+		}
+	}
+	minSendableMsats, maxSendableMsats := sendableRangeForCurrencies(currencies)
 
-		complianceResponse, err = getSignedLnurlpComplianceResponse(request, *privateKeyBytes, *requiresTravelRuleInfo, *receiverKycStatus)
+	var umaVersion *string
+	if request.IsUmaRequest() {
+		if signer == nil {
+			return nil, errors.New("missing required field for UMA: signer")
+		}
+		counterparty := slog.String("counterparty", *request.VaspDomain)
+		var err error
+		umaVersion, err = negotiateUmaVersion(*request.UmaVersion)
+		if err != nil {
+			o.log(context.Background(), slog.LevelWarn, LogEventVersionUnsupported,
+				counterparty, slog.String("requested_version", *request.UmaVersion))
+			o.recordVersionNegotiation(context.Background(), VersionNegotiationUnsupported)
+			return nil, err
+		}
+		o.log(context.Background(), slog.LevelInfo, LogEventVersionNegotiated,
+			counterparty, slog.String("requested_version", *request.UmaVersion), slog.String("version", *umaVersion))
+		if *umaVersion != *request.UmaVersion {
+			o.recordVersionDowngrade(context.Background(), *request.UmaVersion)
+			o.recordVersionNegotiation(context.Background(), VersionNegotiationDowngraded)
+		} else {
+			o.recordVersionNegotiation(context.Background(), VersionNegotiationMatched)
+		}
+	}
+	currenciesCopy := append([]protocol.Currency{}, currencies...)
+	payerDataOptionsCopy := protocol.CounterPartyDataOptions{}
+	for field, option := range payerDataOptions {
+		payerDataOptionsCopy[field] = option
+	}
+	return getLnurlpResponse(
+		request,
+		callback,
+		encodedMetadata,
+		minSendableMsats,
+		maxSendableMsats,
+		signer,
+		umaVersion,
+		&requiresTravelRuleInfo,
+		&payerDataOptionsCopy,
+		&currenciesCopy,
+		&kycStatus,
+		commentCharsAllowed,
+		nostrPubkey,
+		o,
+	)
+}
+
+// sendableRangeForCurrencies returns the widest range of millisatoshis which can be sent in any of the currencies.
+func sendableRangeForCurrencies(currencies []protocol.Currency) (int64, int64) {
+	minSendableMsats := int64(math.MaxInt64)
+	maxSendableMsats := int64(0)
+	for _, currency := range currencies {
+		currencyMin := int64(math.Ceil(float64(currency.Convertible.MinSendable) * currency.MillisatoshiPerUnit))
+		currencyMax := int64(math.Floor(float64(currency.Convertible.MaxSendable) * currency.MillisatoshiPerUnit))
+		if currencyMin < minSendableMsats {
+			minSendableMsats = currencyMin
+		}
+		if currencyMax > maxSendableMsats {
+			maxSendableMsats = currencyMax
+		}
+	}
+	return minSendableMsats, maxSendableMsats
+}
+
+// negotiateUmaVersion selects the version to respond with for a request at the given version.
+func negotiateUmaVersion(requestedVersion string) (*string, error) {
+	if !IsVersionSupported(requestedVersion) {
+		return nil, UnsupportedVersionError{
+			UnsupportedVersion:     requestedVersion,
+			SupportedMajorVersions: GetSupportedMajorVersions(),
+		}
+	}
+	parsedVersion, err := ParseVersion(requestedVersion)
+	if err != nil {
+		return nil, err
+	}
+	highestSupportedVersion := GetHighestSupportedVersionForMajorVersion(parsedVersion.Major)
+	return SelectLowerVersion(requestedVersion, highestSupportedVersion.String())
+}
+
+func getLnurlpResponse(
+	request protocol.LnurlpRequest,
+	callback string,
+	encodedMetadata string,
+	minSendableMsats int64,
+	maxSendableMsats int64,
+	signer UmaSigner,
+	umaVersion *string,
+	requiresTravelRuleInfo *bool,
+	payerDataOptions *protocol.CounterPartyDataOptions,
+	currencyOptions *[]protocol.Currency,
+	receiverKycStatus *protocol.KycStatus,
+	commentCharsAllowed *int,
+	nostrPubkey *string,
+	o *options,
+) (*protocol.LnurlpResponse, error) {
+	var complianceResponse *protocol.LnurlComplianceResponse
+	if request.IsUmaRequest() {
+		var err error
+		complianceResponse, err = getSignedLnurlpComplianceResponse(
+			request, signer, *requiresTravelRuleInfo, *receiverKycStatus, o)
 		if err != nil {
 			return nil, err
 		}
@@ -411,7 +632,7 @@ func GetLnurlpResponse(
 	// Ensure currencies are correctly serialized:
 	if umaVersion != nil {
 		umaVersionParsed, err := ParseVersion(*umaVersion)
-		if err != nil && umaVersionParsed != nil && umaVersionParsed.Major == 0 {
+		if err == nil && umaVersionParsed.Major == 0 {
 			for i := range *currencyOptions {
 				(*currencyOptions)[i].UmaMajorVersion = 0
 			}
@@ -426,8 +647,8 @@ func GetLnurlpResponse(
 	return &protocol.LnurlpResponse{
 		Tag:                 "protocol.PayRequest",
 		Callback:            callback,
-		MinSendable:         minSendableSats * 1000,
-		MaxSendable:         maxSendableSats * 1000,
+		MinSendable:         minSendableMsats,
+		MaxSendable:         maxSendableMsats,
 		EncodedMetadata:     encodedMetadata,
 		Currencies:          currencyOptions,
 		RequiredPayerData:   payerDataOptions,
@@ -441,17 +662,18 @@ func GetLnurlpResponse(
 
 func getSignedLnurlpComplianceResponse(
 	query protocol.LnurlpRequest,
-	privateKeyBytes []byte,
+	signer UmaSigner,
 	isSubjectToTravelRule bool,
 	receiverKycStatus protocol.KycStatus,
+	o *options,
 ) (*protocol.LnurlComplianceResponse, error) {
-	timestamp := time.Now().Unix()
-	nonce, err := GenerateNonce()
+	timestamp := o.clock.Now().Unix()
+	nonce, err := o.generateNonce()
 	if err != nil {
 		return nil, err
 	}
 	payloadString := strings.Join([]string{query.ReceiverAddress, *nonce, strconv.FormatInt(timestamp, 10)}, "|")
-	signature, err := signPayload([]byte(payloadString), privateKeyBytes)
+	signature, err := signWithSigner([]byte(payloadString), signer)
 	if err != nil {
 		return nil, err
 	}
@@ -467,25 +689,101 @@ func getSignedLnurlpComplianceResponse(
 
 // VerifyUmaLnurlpResponseSignature Verifies the signature on an uma Lnurlp response based on the public key of the VASP making the request.
 //
+// The signature only proves that the receiving VASP signed the response for the receiver identifier in it. Pass
+// WithExpectedReceiver, or use VerifyUmaLnurlpResponseSignatureForReceiver, to also check that the response is for the
+// receiver which was queried. Without it, the receiver identifier is not checked.
+//
 // Args:
 //
 //	response: the signed response to verify.
 //	otherVaspPubKeyResponse: the PubKeyResponse of the VASP making this request in bytes.
 //	nonceCache: the NonceCache cache to use to prevent replay attacks.
-func VerifyUmaLnurlpResponseSignature(response protocol.UmaLnurlpResponse, otherVaspPubKeyResponse protocol.PubKeyResponse, nonceCache NonceCache) error {
-	err := nonceCache.CheckAndSaveNonce(response.Compliance.Nonce, time.Unix(response.Compliance.Timestamp, 0))
-	if err != nil {
-		return err
+//	opts: optional settings such as WithExpectedReceiver and WithLogger.
+func VerifyUmaLnurlpResponseSignature(
+	response protocol.UmaLnurlpResponse,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts ...Option,
+) error {
+	o := newOptions(opts)
+	if o.expectedReceiver != "" {
+		if normalizeUmaAddress(response.Compliance.ReceiverIdentifier) != normalizeUmaAddress(o.expectedReceiver) {
+			return fmt.Errorf("%w: expected %s, got %s", ErrReceiverIdentifierMismatch, o.expectedReceiver,
+				response.Compliance.ReceiverIdentifier)
+		}
+		if err := ValidateLnurlpCallback(response.Callback, o.expectedReceiver, opts...); err != nil {
+			return err
+		}
+	}
+	return o.verifySignedMessage(
+		MessageTypeLnurlpResponse,
+		domainOfIdentifier(response.Compliance.ReceiverIdentifier),
+		nonceCache,
+		response.Compliance.Nonce,
+		time.Unix(response.Compliance.Timestamp, 0),
+		response.SignablePayload(),
+		response.Compliance.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// ErrReceiverIdentifierMismatch is returned when a signed lnurlp response is for a different receiver than the one
+// which was queried.
+var ErrReceiverIdentifierMismatch = errors.New("lnurlp response receiver identifier does not match the queried receiver")
+
+// WithExpectedReceiver makes VerifyUmaLnurlpResponseSignature check that the signed receiver identifier matches the
+// receiver address which was queried, e.g. $bob@vasp2.com, and validate the callback URL with ValidateLnurlpCallback.
+// This prevents a receiving VASP from substituting a different receiver than the one the sender intended to pay. The
+// leading $ is optional.
+func WithExpectedReceiver(receiverAddress string) Option {
+	return func(o *options) {
+		o.expectedReceiver = receiverAddress
 	}
-	return verifySignature(response.SignablePayload(), response.Compliance.Signature, otherVaspPubKeyResponse)
 }
 
-func ParseLnurlpResponse(bytes []byte) (*protocol.LnurlpResponse, error) {
+// VerifyUmaLnurlpResponseSignatureForReceiver Verifies the signature on an uma Lnurlp response, and also checks that
+// the signed receiver identifier matches the address the sender queried. It is VerifyUmaLnurlpResponseSignature with
+// WithExpectedReceiver.
+//
+// Args:
+//
+//	response: the signed response to verify.
+//	otherVaspPubKeyResponse: the PubKeyResponse of the receiving VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	expectedReceiver: the receiver address which was queried, e.g. $bob@vasp2.com. The leading $ is optional.
+//	opts: optional settings such as WithLogger and WithAllowedCallbackDomains.
+func VerifyUmaLnurlpResponseSignatureForReceiver(
+	response protocol.UmaLnurlpResponse,
+	otherVaspPubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	expectedReceiver string,
+	opts ...Option,
+) error {
+	return VerifyUmaLnurlpResponseSignature(response, otherVaspPubKeyResponse, nonceCache,
+		append(opts[:len(opts):len(opts)], WithExpectedReceiver(expectedReceiver))...)
+}
+
+func normalizeUmaAddress(address string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(address), "$"))
+}
+
+func ParseLnurlpResponse(bytes []byte, opts ...Option) (*protocol.LnurlpResponse, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypeLnurlpResponse, bytes); err != nil {
+		return nil, err
+	}
 	var response protocol.LnurlpResponse
 	err := json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
 	}
+	if err = o.checkLnurlpResponseLimits(&response); err != nil {
+		return nil, err
+	}
+	if err = o.checkStrict(MessageTypeLnurlpResponse, lnurlpResponseViolations(bytes, &response)...); err != nil {
+		return nil, err
+	}
+	response.RawResponse = o.rawPayload(bytes)
 	return &response, nil
 }
 
@@ -498,13 +796,43 @@ func GetVaspDomainFromUmaAddress(umaAddress string) (string, error) {
 	return addressParts[1], nil
 }
 
+// domainOfIdentifier returns the domain of an UMA address, or the identifier itself if it is not an UMA address.
+func domainOfIdentifier(identifier string) string {
+	domain, err := GetVaspDomainFromUmaAddress(identifier)
+	if err != nil {
+		return identifier
+	}
+	return domain
+}
+
+// TrimPayerDataToRequested Returns a copy of the payer data with only the fields that the receiving VASP requested in
+// its lnurlp response, so that no PII the receiver did not ask for is sent. Both mandatory and optional requested
+// fields are kept. UMA lnurlp responses always request the compliance and identifier fields.
+//
+// Args:
+//
+//	payerData: the payer data which the sending VASP would like to send.
+//	requested: the payer data options from the lnurlp response, i.e. LnurlpResponse.RequiredPayerData.
+func TrimPayerDataToRequested(payerData protocol.PayerData, requested protocol.CounterPartyDataOptions) protocol.PayerData {
+	if payerData == nil {
+		return nil
+	}
+	trimmedPayerData := protocol.PayerData{}
+	for field, value := range payerData {
+		if _, ok := requested[field]; ok {
+			trimmedPayerData[field] = value
+		}
+	}
+	return trimmedPayerData
+}
+
 // GetUmaPayRequest Creates a signed UMA pay request. For non-UMA LNURL requests, just construct a protocol.PayRequest directly.
 //
 // Args:
 //
 //			amount: the amount of the payment in the smallest unit of the specified currency (i.e. cents for USD).
 //			receiverEncryptionPubKey: the public key of the receiver that will be used to encrypt the travel rule information.
-//			sendingVaspPrivateKey: the private key of the VASP that is sending the payment. This will be used to sign the request.
+//			sendingVaspPrivateKey: a PrivateKeyHandle holding the private key of the VASP that is sending the payment. This will be used to sign the request.
 //			receivingCurrencyCode: the code of the currency that the receiver will receive for this payment.
 //			isAmountInReceivingCurrency: whether the amount field is specified in the smallest unit of the receiving
 //				currency or in msats (if false).
@@ -527,10 +855,11 @@ func GetVaspDomainFromUmaAddress(umaAddress string) (string, error) {
 //			comment: a comment that the sender would like to include with the payment. This can only be included
 //		        if the receiver included the `commentAllowed` field in the lnurlp response. The length of
 //		        the comment must be less than or equal to the value of `commentAllowed`.
+//			opts: optional settings such as WithClock and WithNonceGenerator.
 func GetUmaPayRequest(
 	amount int64,
 	receiverEncryptionPubKey []byte,
-	sendingVaspPrivateKey []byte,
+	sendingVaspPrivateKey *PrivateKeyHandle,
 	receivingCurrencyCode string,
 	isAmountInReceivingCurrency bool,
 	payerIdentifier string,
@@ -545,6 +874,7 @@ func GetUmaPayRequest(
 	utxoCallback string,
 	requestedPayeeData *protocol.CounterPartyDataOptions,
 	comment *string,
+	opts ...Option,
 ) (*protocol.PayRequest, error) {
 	return GetUmaPayRequestWithInvoice(
 		amount,
@@ -565,6 +895,7 @@ func GetUmaPayRequest(
 		requestedPayeeData,
 		comment,
 		nil,
+		opts...,
 	)
 }
 
@@ -574,7 +905,7 @@ func GetUmaPayRequest(
 //
 //				amount: the amount of the payment in the smallest unit of the specified currency (i.e. cents for USD).
 //				receiverEncryptionPubKey: the public key of the receiver that will be used to encrypt the travel rule information.
-//				sendingVaspPrivateKey: the private key of the VASP that is sending the payment. This will be used to sign the request.
+//				sendingVaspPrivateKey: a PrivateKeyHandle holding the private key of the VASP that is sending the payment. This will be used to sign the request.
 //				receivingCurrencyCode: the code of the currency that the receiver will receive for this payment.
 //				isAmountInReceivingCurrency: whether the amount field is specified in the smallest unit of the receiving
 //					currency or in msats (if false).
@@ -598,10 +929,13 @@ func GetUmaPayRequest(
 //			        if the receiver included the `commentAllowed` field in the lnurlp response. The length of
 //			        the comment must be less than or equal to the value of `commentAllowed`.
 //	         invoiceUUID: the UUID of the invoice that the sender is paying.
+//	         opts: optional settings such as WithClock and WithNonceGenerator. With WithTravelRulePolicy, trInfo is
+//	         only sent if the payment is subject to the travel rule, and only the payer data fields chosen by the
+//	         policy are sent.
 func GetUmaPayRequestWithInvoice(
 	amount int64,
 	receiverEncryptionPubKey []byte,
-	sendingVaspPrivateKey []byte,
+	sendingVaspPrivateKey *PrivateKeyHandle,
 	receivingCurrencyCode string,
 	isAmountInReceivingCurrency bool,
 	payerIdentifier string,
@@ -617,7 +951,21 @@ func GetUmaPayRequestWithInvoice(
 	requestedPayeeData *protocol.CounterPartyDataOptions,
 	comment *string,
 	invoiceUUID *string,
+	opts ...Option,
 ) (*protocol.PayRequest, error) {
+	o := newOptions(opts)
+	travelRuleCurrencyCode := receivingCurrencyCode
+	if !isAmountInReceivingCurrency {
+		travelRuleCurrencyCode = MillisatoshiCurrencyCode
+	}
+	travelRuleDecision, err := o.evaluateTravelRule(TravelRulePayment{Amount: &amount, CurrencyCode: travelRuleCurrencyCode})
+	if err != nil {
+		return nil, err
+	}
+	if travelRuleDecision != nil && !travelRuleDecision.IsSubjectToTravelRule {
+		trInfo = nil
+		trInfoFormat = nil
+	}
 	complianceData, err := getSignedCompliancePayerData(
 		receiverEncryptionPubKey,
 		sendingVaspPrivateKey,
@@ -628,6 +976,7 @@ func GetUmaPayRequestWithInvoice(
 		payerUtxos,
 		payerNodePubKey,
 		utxoCallback,
+		o,
 	)
 	if err != nil {
 		return nil, err
@@ -649,26 +998,147 @@ func GetUmaPayRequestWithInvoice(
 		return nil, err
 	}
 
+	payerData := protocol.PayerData{
+		protocol.CounterPartyDataFieldName.String():       payerName,
+		protocol.CounterPartyDataFieldEmail.String():      payerEmail,
+		protocol.CounterPartyDataFieldIdentifier.String(): payerIdentifier,
+		protocol.CounterPartyDataFieldCompliance.String(): complianceDataMap,
+	}
+	if travelRuleDecision != nil {
+		trimPayerDataToDecision(payerData, *travelRuleDecision)
+	}
+
+	o.emitEvent(context.Background(), &PayReqBuiltEvent{
+		EventMetadata:             o.eventMetadata(),
+		PayerIdentifier:           payerIdentifier,
+		Amount:                    amount,
+		AmountInReceivingCurrency: isAmountInReceivingCurrency,
+		ReceivingCurrencyCode:     receivingCurrencyCode,
+		UmaMajorVersion:           umaMajorVersion,
+	})
 	return &protocol.PayRequest{
 		SendingAmountCurrencyCode: sendingAmountCurrencyCode,
 		ReceivingCurrencyCode:     &receivingCurrencyCode,
 		Amount:                    amount,
-		PayerData: &protocol.PayerData{
-			protocol.CounterPartyDataFieldName.String():       payerName,
-			protocol.CounterPartyDataFieldEmail.String():      payerEmail,
-			protocol.CounterPartyDataFieldIdentifier.String(): payerIdentifier,
-			protocol.CounterPartyDataFieldCompliance.String(): complianceDataMap,
-		},
-		RequestedPayeeData: requestedPayeeData,
-		Comment:            comment,
-		UmaMajorVersion:    umaMajorVersion,
-		InvoiceUUID:        invoiceUUID,
+		PayerData:                 &payerData,
+		RequestedPayeeData:        requestedPayeeData,
+		Comment:                   comment,
+		UmaMajorVersion:           umaMajorVersion,
+		InvoiceUUID:               invoiceUUID,
 	}, nil
 }
 
+// NewPayRequestLockedSending Creates a signed UMA pay request for a fixed amount of millisatoshis. This is for senders
+// who have a specific amount in their own currency to send: the sending VASP converts it to millisatoshis itself, so
+// that its user sends a fixed amount regardless of the exchange rate on the receiving side. The receiver receives the
+// equivalent amount in the receiving currency at its own exchange rate.
+//
+// Args:
+//
+//	msats: the amount of the payment in millisatoshis.
+//	receivingCurrencyCode: the code of the currency that the receiver will receive for this payment.
+//	The other arguments are the same as for GetUmaPayRequestWithInvoice.
+func NewPayRequestLockedSending(
+	msats int64,
+	receivingCurrencyCode string,
+	receiverEncryptionPubKey []byte,
+	sendingVaspPrivateKey *PrivateKeyHandle,
+	payerIdentifier string,
+	umaMajorVersion int,
+	payerName *string,
+	payerEmail *string,
+	trInfo *string,
+	trInfoFormat *protocol.TravelRuleFormat,
+	payerKycStatus protocol.KycStatus,
+	payerUtxos *[]string,
+	payerNodePubKey *string,
+	utxoCallback string,
+	requestedPayeeData *protocol.CounterPartyDataOptions,
+	comment *string,
+	invoiceUUID *string,
+	opts ...Option,
+) (*protocol.PayRequest, error) {
+	return GetUmaPayRequestWithInvoice(
+		msats,
+		receiverEncryptionPubKey,
+		sendingVaspPrivateKey,
+		receivingCurrencyCode,
+		false,
+		payerIdentifier,
+		umaMajorVersion,
+		payerName,
+		payerEmail,
+		trInfo,
+		trInfoFormat,
+		payerKycStatus,
+		payerUtxos,
+		payerNodePubKey,
+		utxoCallback,
+		requestedPayeeData,
+		comment,
+		invoiceUUID,
+		opts...,
+	)
+}
+
+// NewPayRequestLockedReceiving Creates a signed UMA pay request for a fixed amount in the receiving currency. This is
+// for senders who want the receiver to receive a specific amount regardless of the exchange rate, for example when
+// paying for goods or services in a foreign currency. The receiver quotes the amount of millisatoshis to send.
+//
+// Args:
+//
+//	amount: the amount that the receiver will receive, in the smallest unit of the receiving currency (i.e. cents for USD).
+//	currencyCode: the code of the currency that the receiver will receive for this payment.
+//	The other arguments are the same as for GetUmaPayRequestWithInvoice.
+func NewPayRequestLockedReceiving(
+	amount int64,
+	currencyCode string,
+	receiverEncryptionPubKey []byte,
+	sendingVaspPrivateKey *PrivateKeyHandle,
+	payerIdentifier string,
+	umaMajorVersion int,
+	payerName *string,
+	payerEmail *string,
+	trInfo *string,
+	trInfoFormat *protocol.TravelRuleFormat,
+	payerKycStatus protocol.KycStatus,
+	payerUtxos *[]string,
+	payerNodePubKey *string,
+	utxoCallback string,
+	requestedPayeeData *protocol.CounterPartyDataOptions,
+	comment *string,
+	invoiceUUID *string,
+	opts ...Option,
+) (*protocol.PayRequest, error) {
+	if currencyCode == "" {
+		return nil, errors.New("the currency code is required when locking the receiving amount")
+	}
+	return GetUmaPayRequestWithInvoice(
+		amount,
+		receiverEncryptionPubKey,
+		sendingVaspPrivateKey,
+		currencyCode,
+		true,
+		payerIdentifier,
+		umaMajorVersion,
+		payerName,
+		payerEmail,
+		trInfo,
+		trInfoFormat,
+		payerKycStatus,
+		payerUtxos,
+		payerNodePubKey,
+		utxoCallback,
+		requestedPayeeData,
+		comment,
+		invoiceUUID,
+		opts...,
+	)
+}
+
 func getSignedCompliancePayerData(
 	receiverEncryptionPubKeyBytes []byte,
-	sendingVaspPrivateKeyBytes []byte,
+	sendingVaspPrivateKey *PrivateKeyHandle,
 	payerIdentifier string,
 	trInfo *string,
 	trInfoFormat *protocol.TravelRuleFormat,
@@ -676,9 +1146,10 @@ func getSignedCompliancePayerData(
 	payerUtxos *[]string,
 	payerNodePubKey *string,
 	utxoCallback string,
+	o *options,
 ) (*protocol.CompliancePayerData, error) {
-	timestamp := time.Now().Unix()
-	nonce, err := GenerateNonce()
+	timestamp := o.clock.Now().Unix()
+	nonce, err := o.generateNonce()
 	if err != nil {
 		return nil, err
 	}
@@ -690,7 +1161,7 @@ func getSignedCompliancePayerData(
 		}
 	}
 	payloadString := strings.Join([]string{payerIdentifier, *nonce, strconv.FormatInt(timestamp, 10)}, "|")
-	signature, err := signPayload([]byte(payloadString), sendingVaspPrivateKeyBytes)
+	signature, err := signPayload([]byte(payloadString), sendingVaspPrivateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -723,12 +1194,23 @@ func encryptTrInfo(trInfo string, receiverEncryptionPubKey []byte) (*string, err
 	return &encryptedTrInfoHex, nil
 }
 
-func ParsePayRequest(bytes []byte) (*protocol.PayRequest, error) {
+func ParsePayRequest(bytes []byte, opts ...Option) (*protocol.PayRequest, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePayRequest, bytes); err != nil {
+		return nil, err
+	}
 	var response protocol.PayRequest
 	err := json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
 	}
+	if err = o.checkPayRequestLimits(&response); err != nil {
+		return nil, err
+	}
+	if err = o.checkStrict(MessageTypePayRequest, payRequestViolations(bytes, &response)...); err != nil {
+		return nil, err
+	}
+	response.RawResponse = o.rawPayload(bytes)
 	return &response, nil
 }
 
@@ -736,6 +1218,50 @@ type InvoiceCreator interface {
 	CreateInvoice(amountMsats int64, metadata string, receiverIdentifier *string) (*string, error)
 }
 
+// ExpiringInvoiceCreator is an InvoiceCreator which can also set the expiry of the invoices it creates. See the
+// lightning package for implementations backed by common node backends.
+type ExpiringInvoiceCreator interface {
+	InvoiceCreator
+	// CreateInvoiceWithExpiry creates an invoice which expires after expirySecs. If expirySecs is nil, the backend's
+	// default expiry is used.
+	CreateInvoiceWithExpiry(amountMsats int64, metadata string, receiverIdentifier *string, expirySecs *int64) (*string, error)
+}
+
+// WithInvoiceExpiry sets the expiry of the invoices created for pay request responses, and advertises it in the
+// response's expiry hint. The InvoiceCreator must implement ExpiringInvoiceCreator. Short expiries keep the window in
+// which the receiving VASP is exposed to FX rate changes small. See also WithQuote.
+func WithInvoiceExpiry(expiry time.Duration) Option {
+	return func(o *options) {
+		o.invoiceExpiry = &expiry
+	}
+}
+
+// invoiceExpirySecs returns the invoice expiry set with WithInvoiceExpiry in seconds, or nil if none was set.
+func (o *options) invoiceExpirySecs() *int64 {
+	if o.invoiceExpiry == nil {
+		return nil
+	}
+	expirySecs := int64(o.invoiceExpiry.Seconds())
+	return &expirySecs
+}
+
+func createInvoice(
+	invoiceCreator InvoiceCreator,
+	amountMsats int64,
+	metadata string,
+	receiverIdentifier *string,
+	expirySecs *int64,
+) (*string, error) {
+	if expirySecs == nil {
+		return invoiceCreator.CreateInvoice(amountMsats, metadata, receiverIdentifier)
+	}
+	expiringInvoiceCreator, ok := invoiceCreator.(ExpiringInvoiceCreator)
+	if !ok {
+		return nil, errors.New("an invoice expiry was set, but the InvoiceCreator does not implement ExpiringInvoiceCreator")
+	}
+	return expiringInvoiceCreator.CreateInvoiceWithExpiry(amountMsats, metadata, receiverIdentifier, expirySecs)
+}
+
 func addInvoiceUUIDToMetadata(metadata string, invoiceUUID string) (string, error) {
 	var data [][]interface{}
 	err := json.Unmarshal([]byte(metadata), &data)
@@ -774,13 +1300,15 @@ func addInvoiceUUIDToMetadata(metadata string, invoiceUUID string) (string, erro
 //	    	receive the payment once it completes.
 //		payeeData: the payee data which was requested by the sender. Can be nil if no payee data was requested or is
 //			mandatory. The data provided does not need to include compliance data, as it will be added automatically.
-//		receivingVaspPrivateKey: the private key of the VASP that is receiving the payment. This will be used to sign the request.
+//		receivingVaspPrivateKey: a PrivateKeyHandle holding the private key of the VASP that is receiving the payment. This will be used to sign the request.
 //		payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
 //		disposable: This field may be used by a WALLET to decide whether the initial LNURL link will be stored locally
 //			for later reuse or erased. If disposable is null, it should be interpreted as true, so if SERVICE intends
 //			its LNURL links to be stored it must return `disposable: false`. UMA should never return
 //			`disposable: false`. See LUD-11.
 //		successAction: an optional action that the wallet should take once the payment is complete. See LUD-09.
+//		opts: optional settings such as WithQuote, WithFeeBreakdown, WithInvoiceExpiry and
+//			WithPayeeIdentifierRewriter.
 func GetPayReqResponse(
 	request protocol.PayRequest,
 	invoiceCreator InvoiceCreator,
@@ -793,10 +1321,196 @@ func GetPayReqResponse(
 	receiverNodePubKey *string,
 	utxoCallback *string,
 	payeeData *protocol.PayeeData,
-	receivingVaspPrivateKey *[]byte,
+	receivingVaspPrivateKey *PrivateKeyHandle,
+	payeeIdentifier *string,
+	disposable *bool,
+	successAction *map[string]string,
+	opts ...Option,
+) (*protocol.PayReqResponse, error) {
+	signer := signerForHandle(receivingVaspPrivateKey)
+	o := newOptions(opts)
+	response, err := getPayReqResponse(
+		request,
+		invoiceCreator,
+		metadata,
+		receivingCurrencyCode,
+		receivingCurrencyDecimals,
+		conversionRate,
+		receiverFeesMillisats,
+		receiverChannelUtxos,
+		receiverNodePubKey,
+		utxoCallback,
+		payeeData,
+		signer,
+		payeeIdentifier,
+		disposable,
+		successAction,
+		o.invoiceExpirySecs(),
+		o,
+	)
+	if err != nil {
+		return nil, err
+	}
+	o.applyQuote(response)
+	if err = o.applyFeeBreakdown(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetPayReqResponseWithSigner Creates an uma pay request response with an encoded invoice, signing the payee
+// compliance data with the given UmaSigner. Amounts in millisatoshis are computed from the conversion rate and fees,
+// and the invoice is requested from the InvoiceCreator. Amounts in the receiving currency must be within its
+// convertible range, or an AmountOutOfRangeError is returned.
+//
+// Args:
+//
+//	request: the uma pay request.
+//	invoiceCreator: the object that will create the invoice.
+//	metadata: the metadata that will be added to the invoice's metadata hash field. Note that this should not include
+//	    the extra payer data. That will be appended automatically.
+//	currency: the currency that the receiver will receive for this payment, as returned in the lnurlp response.
+//	conversionRate: milli-satoshis per the smallest unit of the specified currency. This rate is committed to by the
+//	    receiving VASP until the invoice expires.
+//	receiverFeesMillisats: the fees charged (in millisats) by the receiving VASP to convert to the target currency.
+//	receiverChannelUtxos: the list of UTXOs of the receiver's channels that might be used to fund the payment. If
+//	    nil and a UtxoProvider is set with WithUtxoProvider, the UTXOs are fetched from it.
+//	payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
+//	signer: the UmaSigner of the receiving VASP.
+//	receiverNodePubKey: If known, the public key of the receiver's node.
+//	utxoCallback: the URL that the receiving VASP will call to send UTXOs of the channel that the receiver used to
+//	    receive the payment once it completes.
+//	payeeData: the payee data which was requested by the sender. Can be nil.
+//	disposable: whether the initial LNURL link may be reused. See LUD-11.
+//	successAction: an optional action that the wallet should take once the payment is complete. See LUD-09.
+//	opts: optional settings such as WithComplianceProvider, which is used to screen the payer's UTXOs and register
+//		the payment, WithQuote, WithFeeBreakdown, WithInvoiceExpiry and WithPayeeIdentifierRewriter.
+func GetPayReqResponseWithSigner(
+	request protocol.PayRequest,
+	invoiceCreator InvoiceCreator,
+	metadata string,
+	currency protocol.Currency,
+	conversionRate float64,
+	receiverFeesMillisats int64,
+	receiverChannelUtxos []string,
+	payeeIdentifier string,
+	signer UmaSigner,
+	receiverNodePubKey *string,
+	utxoCallback *string,
+	payeeData *protocol.PayeeData,
+	disposable *bool,
+	successAction *map[string]string,
+	opts ...Option,
+) (*protocol.PayReqResponse, error) {
+	if signer == nil {
+		return nil, errors.New("missing signer")
+	}
+	if request.SendingAmountCurrencyCode != nil {
+		if err := checkConvertibleAmount(currency, request.Amount); err != nil {
+			return nil, err
+		}
+	}
+	o := newOptions(opts)
+	ctx := context.Background()
+	if receiverChannelUtxos == nil && o.utxoProvider != nil && request.IsUmaRequest() {
+		var err error
+		receiverChannelUtxos, err = o.utxoProvider.GetChannelUtxos(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	payerCompliance, err := request.PayerData.Compliance()
+	if err != nil {
+		return nil, err
+	}
+	payerIdentifier := ""
+	if identifier := request.PayerData.Identifier(); identifier != nil {
+		payerIdentifier = *identifier
+	}
+	if o.currencyCorridorPolicy != nil {
+		senderVaspDomain := ""
+		if request.IsUmaRequest() {
+			senderVaspDomain = domainOfIdentifier(payerIdentifier)
+		}
+		if err = o.currencyCorridorPolicy.CheckCurrency(ctx, senderVaspDomain, currency.Code); err != nil {
+			return nil, err
+		}
+	}
+	registration := PaymentRegistration{Direction: PaymentDirectionIncoming, CounterpartyIdentifier: payerIdentifier}
+	if payerCompliance != nil {
+		var payerUtxos []string
+		if payerCompliance.Utxos != nil {
+			payerUtxos = *payerCompliance.Utxos
+		}
+		err = o.complianceProvider.ScreenUtxos(ctx, UtxoScreeningRequest{
+			Direction:              PaymentDirectionIncoming,
+			CounterpartyIdentifier: payerIdentifier,
+			Utxos:                  payerUtxos,
+			NodePubKey:             payerCompliance.NodePubKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		registration.CounterpartyUtxos = payerUtxos
+		registration.CounterpartyNodePubKey = payerCompliance.NodePubKey
+	}
+	response, err := getPayReqResponse(
+		request,
+		invoiceCreator,
+		metadata,
+		&currency.Code,
+		&currency.Decimals,
+		&conversionRate,
+		&receiverFeesMillisats,
+		&receiverChannelUtxos,
+		receiverNodePubKey,
+		utxoCallback,
+		payeeData,
+		signer,
+		&payeeIdentifier,
+		disposable,
+		successAction,
+		o.invoiceExpirySecs(),
+		o,
+	)
+	if err != nil {
+		return nil, err
+	}
+	o.applyQuote(response)
+	if err = o.applyFeeBreakdown(response); err != nil {
+		return nil, err
+	}
+	registration.EncodedInvoice = response.EncodedInvoice
+	if err = o.complianceProvider.RegisterPayment(ctx, registration); err != nil {
+		return nil, err
+	}
+	event := &PaymentRegisteredEvent{EventMetadata: o.eventMetadata(), Registration: registration}
+	if response.PaymentInfo != nil {
+		msatsAmount := payReqMsatsAmount(request, conversionRate, receiverFeesMillisats)
+		event.Conversion = o.conversionReceipt(response.PaymentInfo, msatsAmount)
+	}
+	o.emitEvent(ctx, event)
+	return response, nil
+}
+
+func getPayReqResponse(
+	request protocol.PayRequest,
+	invoiceCreator InvoiceCreator,
+	metadata string,
+	receivingCurrencyCode *string,
+	receivingCurrencyDecimals *int,
+	conversionRate *float64,
+	receiverFeesMillisats *int64,
+	receiverChannelUtxos *[]string,
+	receiverNodePubKey *string,
+	utxoCallback *string,
+	payeeData *protocol.PayeeData,
+	signer UmaSigner,
 	payeeIdentifier *string,
 	disposable *bool,
 	successAction *map[string]string,
+	invoiceExpirySecs *int64,
+	o *options,
 ) (*protocol.PayReqResponse, error) {
 	if request.SendingAmountCurrencyCode != nil && *request.SendingAmountCurrencyCode != *receivingCurrencyCode {
 		return nil, errors.New("the sdk only supports sending in either SAT or the receiving currency")
@@ -814,8 +1528,8 @@ func GetPayReqResponse(
 		feesOrZero = *receiverFeesMillisats
 	}
 	msatsAmount := request.Amount
-	if receivingCurrencyCode != nil && request.SendingAmountCurrencyCode != nil {
-		msatsAmount = int64(math.Round(float64(request.Amount)*(conversionRateOrOne))) + feesOrZero
+	if receivingCurrencyCode != nil {
+		msatsAmount = payReqMsatsAmount(request, conversionRateOrOne, feesOrZero)
 	}
 
 	payerDataStr := ""
@@ -833,10 +1547,18 @@ func GetPayReqResponse(
 			return nil, err
 		}
 	}
-	encodedInvoice, err := invoiceCreator.CreateInvoice(msatsAmount, metadata+payerDataStr, payeeIdentifier)
+	invoiceReceiverIdentifier, err := o.invoiceReceiverIdentifier(context.Background(), payeeIdentifier, &request)
 	if err != nil {
 		return nil, err
 	}
+	encodedInvoice, err := createInvoice(
+		invoiceCreator, msatsAmount, metadata+payerDataStr, invoiceReceiverIdentifier, invoiceExpirySecs)
+	if err != nil {
+		return nil, err
+	}
+	if err = o.checkCreatedInvoiceNetwork(*encodedInvoice); err != nil {
+		return nil, err
+	}
 	var complianceData *protocol.CompliancePayeeData
 	if request.IsUmaRequest() {
 		err = validateUmaPayReqFields(
@@ -847,7 +1569,7 @@ func GetPayReqResponse(
 			receiverChannelUtxos,
 			receiverNodePubKey,
 			payeeIdentifier,
-			receivingVaspPrivateKey,
+			signer,
 		)
 		if err != nil {
 			return nil, err
@@ -859,12 +1581,13 @@ func GetPayReqResponse(
 			utxos = *receiverChannelUtxos
 		}
 		complianceData, err = getSignedCompliancePayeeData(
-			*receivingVaspPrivateKey,
+			signer,
 			*payerIdentifier,
 			*payeeIdentifier,
 			utxos,
 			receiverNodePubKey,
 			utxoCallback,
+			o,
 		)
 		if err != nil {
 			return nil, err
@@ -910,10 +1633,20 @@ func GetPayReqResponse(
 		PayeeData:       payeeData,
 		Disposable:      disposable,
 		SuccessAction:   successAction,
+		InvoiceExpiry:   invoiceExpirySecs,
 		UmaMajorVersion: request.UmaMajorVersion,
 	}, nil
 }
 
+// payReqMsatsAmount returns the amount of the invoice for a pay request, in millisatoshis, converting the amount if it
+// is in the receiving currency.
+func payReqMsatsAmount(request protocol.PayRequest, conversionRate float64, feesMillisats int64) int64 {
+	if request.SendingAmountCurrencyCode == nil {
+		return request.Amount
+	}
+	return int64(math.Round(float64(request.Amount)*conversionRate)) + feesMillisats
+}
+
 func validatePayReqCurrencyFields(
 	receivingCurrencyCode *string,
 	receivingCurrencyDecimals *int,
@@ -947,7 +1680,7 @@ func validateUmaPayReqFields(
 	receiverChannelUtxos *[]string,
 	receiverNodePubKey *string,
 	payeeIdentifier *string,
-	signingPrivateKeyBytes *[]byte,
+	signer UmaSigner,
 ) error {
 	if receivingCurrencyCode == nil || receivingCurrencyDecimals == nil || conversionRate == nil || receiverFeesMillisats == nil {
 		return errors.New("missing currency fields required for UMA")
@@ -957,7 +1690,7 @@ func validateUmaPayReqFields(
 		return errors.New("missing required UMA field payeeIdentifier")
 	}
 
-	if signingPrivateKeyBytes == nil {
+	if signer == nil {
 		return errors.New("missing required UMA field signingPrivateKeyBytes")
 	}
 
@@ -968,15 +1701,16 @@ func validateUmaPayReqFields(
 }
 
 func getSignedCompliancePayeeData(
-	receivingVaspPrivateKeyBytes []byte,
+	signer UmaSigner,
 	payerIdentifier string,
 	payeeIdentifier string,
 	receiverChannelUtxos []string,
 	receiverNodePubKey *string,
 	utxoCallback *string,
+	o *options,
 ) (*protocol.CompliancePayeeData, error) {
-	timestamp := time.Now().Unix()
-	nonce, err := GenerateNonce()
+	timestamp := o.clock.Now().Unix()
+	nonce, err := o.generateNonce()
 	if err != nil {
 		return nil, err
 	}
@@ -992,7 +1726,7 @@ func getSignedCompliancePayeeData(
 	if err != nil {
 		return nil, err
 	}
-	signature, err := signPayload([]byte(payloadString), receivingVaspPrivateKeyBytes)
+	signature, err := signWithSigner([]byte(payloadString), signer)
 	if err != nil {
 		return nil, err
 	}
@@ -1001,15 +1735,67 @@ func getSignedCompliancePayeeData(
 }
 
 // ParsePayReqResponse Parses the uma pay request response from a raw response body.
-func ParsePayReqResponse(bytes []byte) (*protocol.PayReqResponse, error) {
+func ParsePayReqResponse(bytes []byte, opts ...Option) (*protocol.PayReqResponse, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePayReqResponse, bytes); err != nil {
+		return nil, err
+	}
 	var response protocol.PayReqResponse
 	err := response.UnmarshalJSON(bytes)
 	if err != nil {
 		return nil, err
 	}
+	if err = o.checkPayReqResponseLimits(&response); err != nil {
+		return nil, err
+	}
+	if err = o.checkStrict(MessageTypePayReqResponse, payReqResponseViolations(bytes, &response)...); err != nil {
+		return nil, err
+	}
+	response.RawResponse = o.rawPayload(bytes)
 	return &response, nil
 }
 
+// ValidatePayeeData Checks that the payee data in a pay request response contains every field the sending VASP marked
+// as mandatory in its pay request, and that the standard fields have the expected types.
+//
+// Args:
+//
+//	requested: the payee data options from the pay request, i.e. PayRequest.RequestedPayeeData.
+//	received: the payee data from the pay request response.
+func ValidatePayeeData(requested protocol.CounterPartyDataOptions, received protocol.PayeeData) error {
+	var missingFields []string
+	for field, option := range requested {
+		if value, ok := received[field]; option.Mandatory && (!ok || value == nil) {
+			missingFields = append(missingFields, field)
+		}
+	}
+	if len(missingFields) > 0 {
+		sort.Strings(missingFields)
+		return fmt.Errorf("missing mandatory payee data fields: %s", strings.Join(missingFields, ", "))
+	}
+
+	for _, field := range []protocol.CounterPartyDataField{
+		protocol.CounterPartyDataFieldIdentifier,
+		protocol.CounterPartyDataFieldName,
+		protocol.CounterPartyDataFieldEmail,
+	} {
+		if value, ok := received[field.String()]; ok && value != nil {
+			if _, isString := value.(string); !isString {
+				return fmt.Errorf("invalid payee data field %s: expected a string", field)
+			}
+		}
+	}
+	if value, ok := received[protocol.CounterPartyDataFieldCompliance.String()]; ok && value != nil {
+		if _, isMap := value.(map[string]interface{}); !isMap {
+			return errors.New("invalid payee data field compliance: expected an object")
+		}
+		if _, err := received.Compliance(); err != nil {
+			return fmt.Errorf("invalid payee data field compliance: %w", err)
+		}
+	}
+	return nil
+}
+
 // VerifyPayReqResponseSignature Verifies the signature on an uma pay request response based on the public key of the
 // VASP making the request.
 //
@@ -1020,12 +1806,14 @@ func ParsePayReqResponse(bytes []byte) (*protocol.PayReqResponse, error) {
 //	nonceCache: the NonceCache cache to use to prevent replay attacks.
 //	payerIdentifier: the identifier of the sender. For example, $alice@vasp1.com
 //	payeeIdentifier: the identifier of the receiver. For example, $bob@vasp2.com
+//	opts: optional settings such as WithLogger.
 func VerifyPayReqResponseSignature(
 	response *protocol.PayReqResponse,
 	otherVaspPubKeyResponse protocol.PubKeyResponse,
 	nonceCache NonceCache,
 	payerIdentifier string,
 	payeeIdentifier string,
+	opts ...Option,
 ) error {
 	complianceData, err := response.PayeeData.Compliance()
 	if err != nil {
@@ -1037,18 +1825,20 @@ func VerifyPayReqResponseSignature(
 	if response.UmaMajorVersion == 0 {
 		return errors.New("signatures were added to payreq responses in UMA v1. This response is from an UMA v0 receiving VASP")
 	}
-	err = nonceCache.CheckAndSaveNonce(
-		*complianceData.SignatureNonce,
-		time.Unix(*complianceData.SignatureTimestamp, 0),
-	)
-	if err != nil {
-		return err
-	}
 	signablePayload, err := complianceData.SignablePayload(payerIdentifier, payeeIdentifier)
 	if err != nil {
 		return err
 	}
-	return verifySignature(signablePayload, *complianceData.Signature, otherVaspPubKeyResponse)
+	return newOptions(opts).verifySignedMessage(
+		MessageTypePayReqResponse,
+		domainOfIdentifier(payeeIdentifier),
+		nonceCache,
+		*complianceData.SignatureNonce,
+		time.Unix(*complianceData.SignatureTimestamp, 0),
+		signablePayload,
+		*complianceData.Signature,
+		otherVaspPubKeyResponse,
+	)
 }
 
 // GetPostTransactionCallback Creates a signed post transaction callback.
@@ -1057,41 +1847,36 @@ func VerifyPayReqResponseSignature(
 //
 //	utxos: UTXOs of the channels of the VASP initiating the callback.
 //	vaspDomain: the domain of the VASP initiating the callback.
-//	signingPrivateKey: the private key of the VASP initiating the callback. This will be used to sign the request.
+//	signingPrivateKey: a PrivateKeyHandle holding the private key of the VASP initiating the callback. This will be used to sign the request.
 func GetPostTransactionCallback(
 	utxos []protocol.UtxoWithAmount,
 	vaspDomain string,
-	signingPrivateKey []byte,
+	signingPrivateKey *PrivateKeyHandle,
 ) (*protocol.PostTransactionCallback, error) {
-	nonce, err := GenerateNonce()
-	if err != nil {
-		return nil, err
-	}
-	timestamp := time.Now().Unix()
-	unsignedCallback := protocol.PostTransactionCallback{
+	return SignPostTransactionCallback(protocol.PostTransactionCallback{
 		Utxos:      utxos,
 		VaspDomain: &vaspDomain,
-		Timestamp:  &timestamp,
-		Nonce:      nonce,
+	}, signerForHandle(signingPrivateKey))
+}
+
+func ParsePostTransactionCallback(bytes []byte, opts ...Option) (*protocol.PostTransactionCallback, error) {
+	o := newOptions(opts)
+	if err := o.checkMessageSize(MessageTypePostTransactionCallback, bytes); err != nil {
+		return nil, err
 	}
-	signablePayload, err := unsignedCallback.SignablePayload()
+	var callback protocol.PostTransactionCallback
+	err := json.Unmarshal(bytes, &callback)
 	if err != nil {
 		return nil, err
 	}
-	signature, err := signPayload(*signablePayload, signingPrivateKey)
-	if err != nil {
+	if err = o.checkPostTransactionCallbackLimits(&callback); err != nil {
 		return nil, err
 	}
-	unsignedCallback.Signature = signature
-	return &unsignedCallback, nil
-}
-
-func ParsePostTransactionCallback(bytes []byte) (*protocol.PostTransactionCallback, error) {
-	var callback protocol.PostTransactionCallback
-	err := json.Unmarshal(bytes, &callback)
-	if err != nil {
+	if err = o.checkStrict(
+		MessageTypePostTransactionCallback, postTransactionCallbackViolations(bytes, &callback)...); err != nil {
 		return nil, err
 	}
+	callback.RawResponse = o.rawPayload(bytes)
 	return &callback, nil
 }
 
@@ -1103,23 +1888,84 @@ func ParsePostTransactionCallback(bytes []byte) (*protocol.PostTransactionCallba
 //	callback: the signed callback to verify.
 //	otherVaspPubKeyResponse: the PubKeyResponse of the VASP making this request.
 //	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings such as WithLogger.
 func VerifyPostTransactionCallbackSignature(
 	callback *protocol.PostTransactionCallback,
 	otherVaspPubKeyResponse protocol.PubKeyResponse,
 	nonceCache NonceCache,
+	opts ...Option,
 ) error {
 	if callback.Signature == nil || callback.Nonce == nil || callback.Timestamp == nil {
 		return errors.New("missing signature. Is this a UMA v0 callback? UMA v0 does not require signatures")
 	}
-	err := nonceCache.CheckAndSaveNonce(*callback.Nonce, time.Unix(*callback.Timestamp, 0))
+	signablePayload, err := callback.SignablePayload()
 	if err != nil {
 		return err
 	}
-	signablePayload, err := callback.SignablePayload()
+	counterparty := ""
+	if callback.VaspDomain != nil {
+		counterparty = *callback.VaspDomain
+	}
+	return newOptions(opts).verifySignedMessage(
+		MessageTypePostTransactionCallback,
+		counterparty,
+		nonceCache,
+		*callback.Nonce,
+		time.Unix(*callback.Timestamp, 0),
+		*signablePayload,
+		*callback.Signature,
+		otherVaspPubKeyResponse,
+	)
+}
+
+// SendPostTransactionCallback Sends a signed post transaction callback to the utxoCallback of the counterparty VASP,
+// with the correlation ID of the payment, if any, so that the counterparty can join it to the rest of the payment.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	callbackUrl: the utxoCallback of the counterparty's pay request or pay request response.
+//	callback: the callback to send, signed with SignPostTransactionCallback.
+//	opts: optional settings such as WithRequestDoer, WithRetryPolicy and WithCorrelationId.
+func SendPostTransactionCallback(
+	ctx context.Context,
+	callbackUrl string,
+	callback protocol.PostTransactionCallback,
+	opts ...Option,
+) error {
+	o := newOptions(opts)
+	outbound, err := newPostTransactionCallbackOutboundRequest(callbackUrl, callback)
 	if err != nil {
 		return err
 	}
-	return verifySignature(*signablePayload, *callback.Signature, otherVaspPubKeyResponse)
+	o.log(ctx, slog.LevelInfo, LogEventPostTransactionCallbackSent, slog.String("url", redactQuery(callbackUrl)),
+		slog.Int("utxos", len(callback.Utxos)))
+	_, err = o.sendRequest(ctx, *outbound)
+	return err
+}
+
+func newPostTransactionCallbackOutboundRequest(
+	callbackUrl string,
+	callback protocol.PostTransactionCallback,
+) (*outboundRequest, error) {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return nil, err
+	}
+	return &outboundRequest{
+		method: http.MethodPost,
+		url:    callbackUrl,
+		body:   body,
+		// Receivers should deduplicate callbacks by their IdempotencyKey.
+		idempotent: callback.IdempotencyKey != nil,
+		resign: func(signer UmaSigner, clock Clock) (*outboundRequest, error) {
+			resignedCallback, err := SignPostTransactionCallback(callback, signer, WithClock(clock))
+			if err != nil {
+				return nil, err
+			}
+			return newPostTransactionCallbackOutboundRequest(callbackUrl, *resignedCallback)
+		},
+	}, nil
 }
 
 func CreateUmaInvoice(
@@ -1134,7 +1980,7 @@ func CreateUmaInvoice(
 	receiverKycStatus *protocol.KycStatus,
 	invoiceLimit *uint64,
 	senderUma *string,
-	signingPrivateKey []byte,
+	signingPrivateKey *PrivateKeyHandle,
 ) (*protocol.UmaInvoice, error) {
 	uuid := uuid.New().String()
 	invoice := protocol.UmaInvoice{
@@ -1168,11 +2014,16 @@ func CreateUmaInvoice(
 	return &invoice, nil
 }
 
-func DecodeUmaInvoice(invoice string) (*protocol.UmaInvoice, error) {
-	return protocol.FromBech32String(invoice)
+func DecodeUmaInvoice(invoice string, opts ...Option) (*protocol.UmaInvoice, error) {
+	decodedInvoice, err := protocol.FromBech32String(invoice)
+	if err != nil {
+		return nil, err
+	}
+	decodedInvoice.RawResponse = newOptions(opts).rawPayload([]byte(invoice))
+	return decodedInvoice, nil
 }
 
-func VerifyUmaInvoiceSignature(invoice protocol.UmaInvoice, otherVaspPubKeyResponse protocol.PubKeyResponse) error {
+func VerifyUmaInvoiceSignature(invoice protocol.UmaInvoice, otherVaspPubKeyResponse protocol.PubKeyResponse, opts ...Option) error {
 	unsignedInvoice := invoice
 	unsignedInvoice.Signature = nil
 	signablePayload, err := unsignedInvoice.MarshalTLV()
@@ -1180,5 +2031,15 @@ func VerifyUmaInvoiceSignature(invoice protocol.UmaInvoice, otherVaspPubKeyRespo
 		return err
 	}
 	signatureString := hex.EncodeToString(*invoice.Signature)
-	return verifySignature(signablePayload, signatureString, otherVaspPubKeyResponse)
+	// Invoices are shared with many payers and have no nonce.
+	return newOptions(opts).verifyMessage(
+		MessageTypeInvoice,
+		domainOfIdentifier(invoice.ReceiverUma),
+		nil,
+		"",
+		time.Time{},
+		[]schemePayload{{scheme: SignatureSchemeDelimited, payload: signablePayload}},
+		signatureString,
+		otherVaspPubKeyResponse,
+	)
 }
@@ -0,0 +1,157 @@
+// Package umacurrency formats currency amounts for display, so that wallets built on the SDK render amounts
+// consistently. Amounts are always in the smallest unit of their currency, as in the UMA protocol.
+package umacurrency
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+const (
+	millisatoshisPerSatoshi = 1000
+	// satoshiDecimals is the number of decimals of a satoshi amount in millisatoshis.
+	satoshiDecimals = 3
+	// bitcoinDecimals is the number of decimals of a bitcoin amount in millisatoshis.
+	bitcoinDecimals = 11
+	// bitcoinDisplayDecimals is the number of decimals always shown for bitcoin amounts, i.e. down to satoshis.
+	bitcoinDisplayDecimals = 8
+)
+
+// Locale holds the separators used to format numbers.
+type Locale struct {
+	// DecimalSeparator separates the integer part of a number from its fractional part.
+	DecimalSeparator string
+	// GroupSeparator separates groups of thousands in the integer part of a number. Empty means no grouping.
+	GroupSeparator string
+}
+
+var (
+	// LocaleDefault formats numbers without grouping, e.g. 1234.56. It is the default.
+	LocaleDefault = Locale{DecimalSeparator: "."}
+	// LocaleEnUS formats numbers as in American English, e.g. 1,234.56.
+	LocaleEnUS = Locale{DecimalSeparator: ".", GroupSeparator: ","}
+	// LocaleDeDE formats numbers as in German, e.g. 1.234,56.
+	LocaleDeDE = Locale{DecimalSeparator: ",", GroupSeparator: "."}
+	// LocaleFrFR formats numbers as in French, e.g. 1 234,56 with a narrow no-break space.
+	LocaleFrFR = Locale{DecimalSeparator: ",", GroupSeparator: "\u202f"}
+)
+
+// Option configures how amounts are formatted.
+type Option func(*options)
+
+type options struct {
+	locale     Locale
+	withSymbol bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{locale: LocaleDefault}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithLocale sets the separators used to format numbers. Defaults to LocaleDefault.
+func WithLocale(locale Locale) Option {
+	return func(o *options) {
+		o.locale = locale
+	}
+}
+
+// WithSymbol makes FormatAmount prefix amounts with the currency symbol, e.g. $5.95, instead of suffixing them with the
+// currency code. Currencies without a symbol are still formatted with their code.
+func WithSymbol() Option {
+	return func(o *options) {
+		o.withSymbol = true
+	}
+}
+
+// FormatAmount formats an amount in the smallest unit of a currency using its Decimals, e.g. 595 in USD is "5.95 USD".
+//
+// Args:
+//
+//	amount: the amount in the smallest unit of the currency (i.e. cents for USD).
+//	currency: the currency of the amount, as advertised in the lnurlp response.
+//	opts: optional settings such as WithLocale and WithSymbol.
+func FormatAmount(amount int64, currency protocol.Currency, opts ...Option) string {
+	o := newOptions(opts)
+	number := formatNumber(amount, currency.Decimals, currency.Decimals, o.locale)
+	if o.withSymbol && currency.Symbol != "" {
+		if strings.HasPrefix(number, "-") {
+			return "-" + currency.Symbol + number[1:]
+		}
+		return currency.Symbol + number
+	}
+	return number + " " + currency.Code
+}
+
+// FormatSats formats an amount of millisatoshis in satoshis, e.g. 1234500 is "1234.5 sats". Fractions of a satoshi
+// are only shown if present.
+//
+// Args:
+//
+//	msats: the amount in millisatoshis.
+//	opts: optional settings such as WithLocale.
+func FormatSats(msats int64, opts ...Option) string {
+	o := newOptions(opts)
+	unit := " sats"
+	if msats == millisatoshisPerSatoshi {
+		unit = " sat"
+	}
+	return formatNumber(msats, satoshiDecimals, 0, o.locale) + unit
+}
+
+// FormatBtc formats an amount of millisatoshis in bitcoin, e.g. 1234000 is "0.00001234 BTC". Bitcoin amounts always
+// show 8 decimals, and fractions of a satoshi are only shown if present.
+//
+// Args:
+//
+//	msats: the amount in millisatoshis.
+//	opts: optional settings such as WithLocale.
+func FormatBtc(msats int64, opts ...Option) string {
+	o := newOptions(opts)
+	return formatNumber(msats, bitcoinDecimals, bitcoinDisplayDecimals, o.locale) + " BTC"
+}
+
+// formatNumber formats an integer amount with the given number of decimals, trimming trailing zeros from the
+// fractional part down to minDecimals.
+func formatNumber(amount int64, decimals int, minDecimals int, locale Locale) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+	sign := ""
+	// Negating in uint64 also handles math.MinInt64.
+	absolute := uint64(amount)
+	if amount < 0 {
+		sign = "-"
+		absolute = -absolute
+	}
+	digits := strconv.FormatUint(absolute, 10)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	integerPart := digits[:len(digits)-decimals]
+	fractionalPart := digits[len(digits)-decimals:]
+	for len(fractionalPart) > minDecimals && strings.HasSuffix(fractionalPart, "0") {
+		fractionalPart = fractionalPart[:len(fractionalPart)-1]
+	}
+
+	var formatted strings.Builder
+	formatted.WriteString(sign)
+	for i, digit := range integerPart {
+		if i > 0 && (len(integerPart)-i)%3 == 0 {
+			formatted.WriteString(locale.GroupSeparator)
+		}
+		formatted.WriteRune(digit)
+	}
+	if fractionalPart != "" {
+		formatted.WriteString(locale.DecimalSeparator)
+		formatted.WriteString(fractionalPart)
+	}
+	return formatted.String()
+}
@@ -0,0 +1,104 @@
+// Package umametrics exports the measurements of the SDK as Prometheus metrics. Create a Collector against a
+// prometheus.Registerer, and pass its Option to the SDK calls and handlers of both the sending and receiving side:
+//
+//	collector, err := umametrics.NewCollector(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		return err
+//	}
+//	handler := uma.NewPayRequestHandler(publicKeyCache, nonceCache, respond, collector.Option())
+package umametrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+)
+
+// Names of the metrics registered by a Collector.
+const (
+	MetricVerificationFailures = "uma_verification_failures_total"
+	MetricVersionNegotiations  = "uma_version_negotiations_total"
+	MetricPayReqDuration       = "uma_payreq_duration_seconds"
+	MetricPublicKeyCacheLookup = "uma_public_key_cache_lookups_total"
+)
+
+// Collector is a uma.MetricsRecorder which records into Prometheus counters and histograms.
+type Collector struct {
+	verificationFailures  *prometheus.CounterVec
+	versionNegotiations   *prometheus.CounterVec
+	payReqDuration        *prometheus.HistogramVec
+	publicKeyCacheLookups *prometheus.CounterVec
+}
+
+// NewCollector Creates a Collector and registers its metrics.
+//
+// Args:
+//
+//	registerer: the registerer for the metrics, e.g. prometheus.DefaultRegisterer.
+func NewCollector(registerer prometheus.Registerer) (*Collector, error) {
+	c := &Collector{
+		verificationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricVerificationFailures,
+			Help: "Number of counterparty messages which failed verification, by message type and reason.",
+		}, []string{"message_type", "reason"}),
+		versionNegotiations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricVersionNegotiations,
+			Help: "Number of UMA version negotiations, by outcome.",
+		}, []string{"outcome"}),
+		payReqDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    MetricPayReqDuration,
+			Help:    "Duration of outbound pay requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"error"}),
+		publicKeyCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricPublicKeyCacheLookup,
+			Help: "Number of public key cache lookups, by result.",
+		}, []string{"result"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		c.verificationFailures,
+		c.versionNegotiations,
+		c.payReqDuration,
+		c.publicKeyCacheLookups,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Option returns the option which makes the SDK record into the Collector.
+func (c *Collector) Option() uma.Option {
+	return uma.WithMetricsRecorder(c)
+}
+
+func (c *Collector) RecordVerificationFailure(
+	_ context.Context,
+	messageType uma.MessageType,
+	reason uma.VerificationFailureReason,
+) {
+	c.verificationFailures.WithLabelValues(string(messageType), string(reason)).Inc()
+}
+
+func (c *Collector) RecordVersionNegotiation(_ context.Context, outcome uma.VersionNegotiationOutcome) {
+	c.versionNegotiations.WithLabelValues(string(outcome)).Inc()
+}
+
+func (c *Collector) RecordPayReqDuration(_ context.Context, duration time.Duration, err error) {
+	errorLabel := "false"
+	if err != nil {
+		errorLabel = "true"
+	}
+	c.payReqDuration.WithLabelValues(errorLabel).Observe(duration.Seconds())
+}
+
+func (c *Collector) RecordPublicKeyCacheLookup(_ context.Context, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.publicKeyCacheLookups.WithLabelValues(result).Inc()
+}
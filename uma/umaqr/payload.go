@@ -0,0 +1,173 @@
+// Package umaqr produces the payloads encoded in QR codes for UMA addresses and UMA invoices, so that wallets built on
+// the SDK show codes which other wallets can scan. UMA payloads use the uma:// URI scheme, e.g.
+// uma://$bob@vasp2.com?amount=1000&currency=USD, and wallets without UMA support can be given an LNURL payload instead.
+package umaqr
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/utils"
+)
+
+const (
+	// umaScheme is the URI scheme of UMA payloads.
+	umaScheme = "uma://"
+	// lightningScheme is the URI scheme of LNURL fallback payloads.
+	lightningScheme = "lightning:"
+)
+
+type options struct {
+	amount       *int64
+	currencyCode string
+	lnurl        bool
+}
+
+// Option configures a payload.
+type Option func(*options)
+
+// WithAmount embeds an amount in the payload, so that the scanning wallet pre-fills it.
+//
+// Args:
+//
+//	amount: the amount in the smallest unit of the currency, e.g. cents for USD.
+//	currencyCode: the code of the currency, e.g. USD. It must be one of the receiver's currencies, or SAT for
+//		millisatoshis.
+func WithAmount(amount int64, currencyCode string) Option {
+	return func(o *options) {
+		o.amount = &amount
+		o.currencyCode = currencyCode
+	}
+}
+
+// WithLnurlFallback produces a lightning:LNURL1... payload (LUD-01) instead of an uma:// one, for wallets which do not
+// support UMA. Amounts cannot be embedded in LNURL payloads.
+func WithLnurlFallback() Option {
+	return func(o *options) {
+		o.lnurl = true
+	}
+}
+
+// AddressPayload Creates the QR payload for an UMA address, e.g. uma://$bob@vasp2.com.
+//
+// Args:
+//
+//	umaAddress: the UMA address of the receiver, e.g. $bob@vasp2.com.
+//	opts: optional settings such as WithAmount and WithLnurlFallback.
+func AddressPayload(umaAddress string, opts ...Option) (string, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	username, domain, ok := strings.Cut(umaAddress, "@")
+	if !ok || !strings.HasPrefix(username, "$") || len(username) < 2 || domain == "" {
+		return "", fmt.Errorf("invalid UMA address %q", umaAddress)
+	}
+
+	if o.lnurl {
+		if o.amount != nil {
+			return "", errors.New("amounts cannot be embedded in LNURL payloads")
+		}
+		request := protocol.LnurlpRequest{ReceiverAddress: umaAddress}
+		lnurl, err := request.EncodeToLnurl()
+		if err != nil {
+			return "", err
+		}
+		return lightningScheme + lnurl, nil
+	}
+
+	payload := umaScheme + umaAddress
+	if o.amount != nil {
+		if *o.amount <= 0 {
+			return "", errors.New("amount must be positive")
+		}
+		if o.currencyCode == "" {
+			return "", errors.New("missing currency code")
+		}
+		query := url.Values{}
+		query.Set("amount", strconv.FormatInt(*o.amount, 10))
+		query.Set("currency", strings.ToUpper(o.currencyCode))
+		payload += "?" + query.Encode()
+	}
+	return payload, nil
+}
+
+// InvoicePayload Creates the QR payload for a signed UMA invoice, e.g. uma://uma1.... The amount and currency are part
+// of the invoice.
+//
+// Args:
+//
+//	invoice: the signed invoice, e.g. from uma.CreateUmaInvoice.
+func InvoicePayload(invoice protocol.UmaInvoice) (string, error) {
+	encodedInvoice, err := invoice.ToBech32String()
+	if err != nil {
+		return "", err
+	}
+	return umaScheme + encodedInvoice, nil
+}
+
+// Payload is a scanned QR payload.
+type Payload struct {
+	// UmaAddress is the UMA address of an address payload, or empty otherwise.
+	UmaAddress string
+	// Amount is the amount embedded in an address payload, in the smallest unit of CurrencyCode, or nil if none is.
+	Amount *int64
+	// CurrencyCode is the currency of Amount.
+	CurrencyCode string
+	// Invoice is the UMA invoice of an invoice payload, or nil otherwise. Its signature still needs to be verified
+	// with uma.VerifyUmaInvoiceSignature.
+	Invoice *protocol.UmaInvoice
+	// LnurlpUrl is the URL encoded in an LNURL payload, or nil otherwise.
+	LnurlpUrl *url.URL
+}
+
+// ParsePayload Parses a scanned QR payload, as produced by AddressPayload or InvoicePayload.
+//
+// Args:
+//
+//	payload: the scanned payload.
+func ParsePayload(payload string) (*Payload, error) {
+	payload = strings.TrimSpace(payload)
+	if len(payload) >= len(lightningScheme) && strings.EqualFold(payload[:len(lightningScheme)], lightningScheme) {
+		lnurlpUrl, err := utils.DecodeLnurl(payload)
+		if err != nil {
+			return nil, err
+		}
+		return &Payload{LnurlpUrl: lnurlpUrl}, nil
+	}
+	if len(payload) < len(umaScheme) || !strings.EqualFold(payload[:len(umaScheme)], umaScheme) {
+		return nil, errors.New("not an UMA payload")
+	}
+	payload = payload[len(umaScheme):]
+
+	if !strings.Contains(payload, "@") {
+		invoice, err := protocol.FromBech32String(strings.ToLower(payload))
+		if err != nil {
+			return nil, fmt.Errorf("invalid UMA invoice: %w", err)
+		}
+		return &Payload{Invoice: invoice}, nil
+	}
+
+	umaAddress, rawQuery, _ := strings.Cut(payload, "?")
+	parsed := &Payload{UmaAddress: umaAddress}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	if query.Has("amount") {
+		amount, err := strconv.ParseInt(query.Get("amount"), 10, 64)
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("invalid amount %q", query.Get("amount"))
+		}
+		if query.Get("currency") == "" {
+			return nil, errors.New("missing currency code")
+		}
+		parsed.Amount = &amount
+		parsed.CurrencyCode = strings.ToUpper(query.Get("currency"))
+	}
+	return parsed, nil
+}
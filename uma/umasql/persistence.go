@@ -0,0 +1,395 @@
+// Package umasql implements uma.PersistenceAdapter on top of database/sql, as a reference for VASPs storing the
+// protocol state in a SQL database. It works with any driver for PostgreSQL or SQLite; the driver is not a dependency
+// of the SDK.
+package umasql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// Dialect is the SQL dialect of a database.
+type Dialect int
+
+const (
+	// DialectPostgres is PostgreSQL, which uses $1, $2, ... placeholders.
+	DialectPostgres Dialect = iota
+	// DialectSqlite is SQLite, which uses ? placeholders.
+	DialectSqlite
+)
+
+// Schema is the schema of the tables used by PersistenceAdapter. It is valid in both PostgreSQL and SQLite, and is
+// applied by CreateTables.
+const Schema = `
+CREATE TABLE IF NOT EXISTS uma_nonces (
+	nonce TEXT PRIMARY KEY,
+	signed_at BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS uma_nonce_floor (
+	id INTEGER PRIMARY KEY,
+	oldest_valid_timestamp BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS uma_public_keys (
+	vasp_domain TEXT PRIMARY KEY,
+	pub_key_response TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS uma_pending_pay_requests (
+	id TEXT PRIMARY KEY,
+	payee_identifier TEXT NOT NULL,
+	pay_request TEXT NOT NULL,
+	pay_req_response TEXT,
+	created_at BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS uma_quotes (
+	quote_id TEXT PRIMARY KEY,
+	encoded_invoice TEXT NOT NULL,
+	payment_info TEXT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	created_at BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS uma_payment_receipts (
+	payment_hash TEXT NOT NULL,
+	vasp_domain TEXT NOT NULL,
+	receipt TEXT NOT NULL,
+	PRIMARY KEY (payment_hash, vasp_domain)
+);
+`
+
+// PersistenceAdapter is a uma.PersistenceAdapter storing the protocol state in a SQL database, in the tables of
+// Schema. It is safe for concurrent use, and can be shared between instances of a VASP using the same database.
+type PersistenceAdapter struct {
+	db      *sql.DB
+	dialect Dialect
+
+	// oldestValidTimestamp is the oldest signature timestamp accepted by the NonceCache until a later one is stored in
+	// uma_nonce_floor by PurgeNoncesOlderThan.
+	oldestValidTimestamp time.Time
+
+	clock uma.Clock
+}
+
+// NewPersistenceAdapter Creates a PersistenceAdapter. The tables must exist, see CreateTables.
+//
+// Args:
+//
+//	db: the database to store the state in.
+//	dialect: the SQL dialect of the database.
+//	oldestValidNonceTimestamp: the oldest signature timestamp accepted by the NonceCache, as for
+//		uma.NewInMemoryNonceCache. PurgeNoncesOlderThan stores a later timestamp in the database, so that it applies
+//		to every instance sharing the database, and after restarts.
+func NewPersistenceAdapter(db *sql.DB, dialect Dialect, oldestValidNonceTimestamp time.Time) *PersistenceAdapter {
+	return &PersistenceAdapter{
+		db:                   db,
+		dialect:              dialect,
+		oldestValidTimestamp: oldestValidNonceTimestamp,
+		clock:                uma.SystemClock,
+	}
+}
+
+// SetClock sets the Clock used to check whether cached public keys have expired. Defaults to uma.SystemClock. It must
+// be called before the PersistenceAdapter is used.
+func (a *PersistenceAdapter) SetClock(clock uma.Clock) {
+	if clock != nil {
+		a.clock = clock
+	}
+}
+
+// CreateTables Creates the tables of Schema if they do not exist yet.
+func (a *PersistenceAdapter) CreateTables(ctx context.Context) error {
+	for _, statement := range strings.Split(Schema, ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := a.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *PersistenceAdapter) NonceCache() uma.NonceCache {
+	return (*nonceCache)(a)
+}
+
+func (a *PersistenceAdapter) PublicKeyCache() uma.PublicKeyCache {
+	return (*publicKeyCache)(a)
+}
+
+func (a *PersistenceAdapter) PendingPayRequests() uma.PendingPayRequestStore {
+	return (*pendingPayRequestStore)(a)
+}
+
+func (a *PersistenceAdapter) Quotes() uma.QuoteStore {
+	return (*quoteStore)(a)
+}
+
+func (a *PersistenceAdapter) PaymentReceipts() uma.PaymentReceiptStore {
+	return (*paymentReceiptStore)(a)
+}
+
+// query rewrites the ? placeholders of a query for the dialect of the database.
+func (a *PersistenceAdapter) query(query string) string {
+	if a.dialect != DialectPostgres {
+		return query
+	}
+	var rewritten strings.Builder
+	placeholder := 0
+	for _, char := range query {
+		if char == '?' {
+			placeholder++
+			rewritten.WriteString(fmt.Sprintf("$%d", placeholder))
+			continue
+		}
+		rewritten.WriteRune(char)
+	}
+	return rewritten.String()
+}
+
+type nonceCache PersistenceAdapter
+
+func (c *nonceCache) CheckAndSaveNonce(nonce string, timestamp time.Time) error {
+	var oldestValidTimestamp int64
+	err := c.db.QueryRow("SELECT oldest_valid_timestamp FROM uma_nonce_floor WHERE id = 1").Scan(&oldestValidTimestamp)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if timestamp.Before(c.oldestValidTimestamp) || timestamp.Unix() < oldestValidTimestamp {
+		return errors.New("timestamp too old")
+	}
+	result, err := c.db.Exec(
+		(*PersistenceAdapter)(c).query("INSERT INTO uma_nonces (nonce, signed_at) VALUES (?, ?) ON CONFLICT DO NOTHING"),
+		nonce, timestamp.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return errors.New("nonce already used")
+	}
+	return nil
+}
+
+// PurgeNoncesOlderThan stores the given timestamp as the oldest valid timestamp, unless a later one is stored, and
+// deletes the nonces older than it. Errors are ignored, since the interface does not return them. The nonces are only
+// deleted once the timestamp is stored, so that they cannot be replayed, and nonces which were not deleted are
+// deleted by the next purge.
+func (c *nonceCache) PurgeNoncesOlderThan(timestamp time.Time) {
+	_, err := c.db.Exec((*PersistenceAdapter)(c).query(
+		"INSERT INTO uma_nonce_floor (id, oldest_valid_timestamp) VALUES (1, ?) ON CONFLICT (id) DO UPDATE "+
+			"SET oldest_valid_timestamp = excluded.oldest_valid_timestamp "+
+			"WHERE excluded.oldest_valid_timestamp > uma_nonce_floor.oldest_valid_timestamp"),
+		timestamp.Unix(),
+	)
+	if err != nil {
+		return
+	}
+	_, _ = c.db.Exec((*PersistenceAdapter)(c).query("DELETE FROM uma_nonces WHERE signed_at < ?"), timestamp.Unix())
+}
+
+// publicKeyCache is the uma.PublicKeyCache of a PersistenceAdapter. Since the interface does not return errors,
+// database errors are treated as cache misses, so that the keys are fetched from the VASP instead.
+type publicKeyCache PersistenceAdapter
+
+func (c *publicKeyCache) FetchPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse {
+	pubKeyResponse := c.LastKnownPublicKeyForVasp(vaspDomain)
+	if pubKeyResponse == nil || (pubKeyResponse.ExpirationTimestamp != nil &&
+		time.Unix(*pubKeyResponse.ExpirationTimestamp, 0).Before(c.clock.Now())) {
+		return nil
+	}
+	return pubKeyResponse
+}
+
+func (c *publicKeyCache) LastKnownPublicKeyForVasp(vaspDomain string) *protocol.PubKeyResponse {
+	var pubKeyJson string
+	err := c.db.QueryRow(
+		(*PersistenceAdapter)(c).query("SELECT pub_key_response FROM uma_public_keys WHERE vasp_domain = ?"),
+		vaspDomain,
+	).Scan(&pubKeyJson)
+	if err != nil {
+		return nil
+	}
+	var pubKeyResponse protocol.PubKeyResponse
+	if err = json.Unmarshal([]byte(pubKeyJson), &pubKeyResponse); err != nil {
+		return nil
+	}
+	return &pubKeyResponse
+}
+
+func (c *publicKeyCache) AddPublicKeyForVasp(vaspDomain string, pubKey *protocol.PubKeyResponse) {
+	pubKeyJson, err := json.Marshal(pubKey)
+	if err != nil {
+		return
+	}
+	_, _ = c.db.Exec((*PersistenceAdapter)(c).query(
+		"INSERT INTO uma_public_keys (vasp_domain, pub_key_response) VALUES (?, ?) "+
+			"ON CONFLICT (vasp_domain) DO UPDATE SET pub_key_response = excluded.pub_key_response"),
+		vaspDomain, string(pubKeyJson),
+	)
+}
+
+func (c *publicKeyCache) RemovePublicKeyForVasp(vaspDomain string) {
+	_, _ = c.db.Exec((*PersistenceAdapter)(c).query("DELETE FROM uma_public_keys WHERE vasp_domain = ?"), vaspDomain)
+}
+
+func (c *publicKeyCache) Clear() {
+	_, _ = c.db.Exec("DELETE FROM uma_public_keys")
+}
+
+type pendingPayRequestStore PersistenceAdapter
+
+func (s *pendingPayRequestStore) SavePendingPayRequest(ctx context.Context, pending uma.PendingPayRequest) error {
+	payRequestJson, err := json.Marshal(&pending.PayRequest)
+	if err != nil {
+		return err
+	}
+	var payReqResponseJson *string
+	if pending.PayReqResponse != nil {
+		responseJson, err := json.Marshal(pending.PayReqResponse)
+		if err != nil {
+			return err
+		}
+		responseString := string(responseJson)
+		payReqResponseJson = &responseString
+	}
+	_, err = s.db.ExecContext(ctx, (*PersistenceAdapter)(s).query(
+		"INSERT INTO uma_pending_pay_requests (id, payee_identifier, pay_request, pay_req_response, created_at) "+
+			"VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO UPDATE SET payee_identifier = excluded.payee_identifier, "+
+			"pay_request = excluded.pay_request, pay_req_response = excluded.pay_req_response, "+
+			"created_at = excluded.created_at"),
+		pending.Id, pending.PayeeIdentifier, string(payRequestJson), payReqResponseJson, pending.CreatedAt.Unix(),
+	)
+	return err
+}
+
+func (s *pendingPayRequestStore) GetPendingPayRequest(ctx context.Context, id string) (*uma.PendingPayRequest, error) {
+	var payRequestJson string
+	var payReqResponseJson sql.NullString
+	var createdAt int64
+	pending := uma.PendingPayRequest{Id: id}
+	err := s.db.QueryRowContext(ctx, (*PersistenceAdapter)(s).query(
+		"SELECT payee_identifier, pay_request, pay_req_response, created_at FROM uma_pending_pay_requests WHERE id = ?"),
+		id,
+	).Scan(&pending.PayeeIdentifier, &payRequestJson, &payReqResponseJson, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, uma.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(payRequestJson), &pending.PayRequest); err != nil {
+		return nil, err
+	}
+	if payReqResponseJson.Valid {
+		var response protocol.PayReqResponse
+		if err = json.Unmarshal([]byte(payReqResponseJson.String), &response); err != nil {
+			return nil, err
+		}
+		pending.PayReqResponse = &response
+	}
+	pending.CreatedAt = time.Unix(createdAt, 0)
+	return &pending, nil
+}
+
+func (s *pendingPayRequestStore) DeletePendingPayRequest(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, (*PersistenceAdapter)(s).query("DELETE FROM uma_pending_pay_requests WHERE id = ?"), id)
+	return err
+}
+
+type quoteStore PersistenceAdapter
+
+func (s *quoteStore) SaveQuote(ctx context.Context, quote uma.StoredQuote) error {
+	if quote.Quote.Id == "" {
+		return errors.New("missing quote id")
+	}
+	paymentInfoJson, err := json.Marshal(quote.PaymentInfo)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, (*PersistenceAdapter)(s).query(
+		"INSERT INTO uma_quotes (quote_id, encoded_invoice, payment_info, expires_at, created_at) "+
+			"VALUES (?, ?, ?, ?, ?) ON CONFLICT (quote_id) DO UPDATE SET encoded_invoice = excluded.encoded_invoice, "+
+			"payment_info = excluded.payment_info, expires_at = excluded.expires_at, created_at = excluded.created_at"),
+		quote.Quote.Id, quote.EncodedInvoice, string(paymentInfoJson), quote.Quote.ExpiresAt.Unix(), quote.CreatedAt.Unix(),
+	)
+	return err
+}
+
+func (s *quoteStore) GetQuote(ctx context.Context, quoteId string) (*uma.StoredQuote, error) {
+	var paymentInfoJson string
+	var expiresAt, createdAt int64
+	quote := uma.StoredQuote{Quote: uma.Quote{Id: quoteId}}
+	err := s.db.QueryRowContext(ctx, (*PersistenceAdapter)(s).query(
+		"SELECT encoded_invoice, payment_info, expires_at, created_at FROM uma_quotes WHERE quote_id = ?"),
+		quoteId,
+	).Scan(&quote.EncodedInvoice, &paymentInfoJson, &expiresAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, uma.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(paymentInfoJson), &quote.PaymentInfo); err != nil {
+		return nil, err
+	}
+	quote.Quote.ExpiresAt = time.Unix(expiresAt, 0)
+	quote.CreatedAt = time.Unix(createdAt, 0)
+	return &quote, nil
+}
+
+func (s *quoteStore) PurgeQuotesExpiredBefore(ctx context.Context, timestamp time.Time) error {
+	_, err := s.db.ExecContext(ctx, (*PersistenceAdapter)(s).query("DELETE FROM uma_quotes WHERE expires_at < ?"),
+		timestamp.Unix())
+	return err
+}
+
+type paymentReceiptStore PersistenceAdapter
+
+func (s *paymentReceiptStore) SavePaymentReceipt(ctx context.Context, receipt protocol.PaymentReceipt) error {
+	receiptJson, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, (*PersistenceAdapter)(s).query(
+		"INSERT INTO uma_payment_receipts (payment_hash, vasp_domain, receipt) VALUES (?, ?, ?) "+
+			"ON CONFLICT (payment_hash, vasp_domain) DO UPDATE SET receipt = excluded.receipt"),
+		receipt.PaymentHash, receipt.VaspDomain, string(receiptJson),
+	)
+	return err
+}
+
+func (s *paymentReceiptStore) GetPaymentReceipts(
+	ctx context.Context,
+	paymentHash string,
+) ([]protocol.PaymentReceipt, error) {
+	rows, err := s.db.QueryContext(ctx, (*PersistenceAdapter)(s).query(
+		"SELECT receipt FROM uma_payment_receipts WHERE payment_hash = ?"), paymentHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	receipts := []protocol.PaymentReceipt{}
+	for rows.Next() {
+		var receiptJson string
+		if err = rows.Scan(&receiptJson); err != nil {
+			return nil, err
+		}
+		var receipt protocol.PaymentReceipt
+		if err = json.Unmarshal([]byte(receiptJson), &receipt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
+}
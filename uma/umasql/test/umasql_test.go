@@ -0,0 +1,233 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umasql"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/umatest"
+)
+
+// testDatabase is a database the tests run against.
+type testDatabase struct {
+	name    string
+	dialect umasql.Dialect
+	open    func(t *testing.T) *sql.DB
+}
+
+var testDatabases = []testDatabase{
+	{name: "sqlite", dialect: umasql.DialectSqlite, open: openSqliteDb},
+	{name: "postgres", dialect: umasql.DialectPostgres, open: openPostgresDb},
+}
+
+// openSqliteDb opens a new SQLite database in a temporary file.
+func openSqliteDb(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "uma.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	return db
+}
+
+// openPostgresDb opens the PostgreSQL database at UMA_TEST_POSTGRES_URL without any of the tables of umasql.Schema,
+// or skips the test if it is not set.
+func openPostgresDb(t *testing.T) *sql.DB {
+	postgresUrl := os.Getenv("UMA_TEST_POSTGRES_URL")
+	if postgresUrl == "" {
+		t.Skip("UMA_TEST_POSTGRES_URL is not set")
+	}
+	db, err := sql.Open("postgres", postgresUrl)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	for _, table := range []string{
+		"uma_nonces", "uma_nonce_floor", "uma_public_keys", "uma_pending_pay_requests", "uma_quotes",
+		"uma_payment_receipts",
+	} {
+		_, err = db.Exec("DROP TABLE IF EXISTS " + table)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+// forEachDatabase runs a test against each database, with the tables of umasql.Schema created.
+func forEachDatabase(t *testing.T, test func(t *testing.T, db *sql.DB, dialect umasql.Dialect)) {
+	for _, database := range testDatabases {
+		t.Run(database.name, func(t *testing.T) {
+			db := database.open(t)
+			adapter := umasql.NewPersistenceAdapter(db, database.dialect, time.Time{})
+			require.NoError(t, adapter.CreateTables(context.Background()))
+			// Creating the tables again is a no-op.
+			require.NoError(t, adapter.CreateTables(context.Background()))
+			test(t, db, database.dialect)
+		})
+	}
+}
+
+func TestSqlNonceCache(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		nonceCache := umasql.NewPersistenceAdapter(db, dialect, time.Now().Add(-time.Hour)).NonceCache()
+		require.NoError(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now()))
+		require.EqualError(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now()), "nonce already used")
+		require.EqualError(t, nonceCache.CheckAndSaveNonce("nonce2", time.Now().Add(-2*time.Hour)), "timestamp too old")
+
+		nonceCache.PurgeNoncesOlderThan(time.Now().Add(time.Minute))
+		require.EqualError(t, nonceCache.CheckAndSaveNonce("nonce3", time.Now()), "timestamp too old")
+		require.NoError(t, nonceCache.CheckAndSaveNonce("nonce3", time.Now().Add(2*time.Minute)))
+		var nonceCount int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM uma_nonces").Scan(&nonceCount))
+		require.Equal(t, 1, nonceCount)
+	})
+}
+
+func TestSqlNonceCacheSharesOldestValidTimestamp(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		nonceCache := umasql.NewPersistenceAdapter(db, dialect, time.Now().Add(-time.Hour)).NonceCache()
+		otherInstanceCache := umasql.NewPersistenceAdapter(db, dialect, time.Now().Add(-time.Hour)).NonceCache()
+
+		require.NoError(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now().Add(-30*time.Minute)))
+		nonceCache.PurgeNoncesOlderThan(time.Now().Add(-time.Minute))
+		// The purged nonce cannot be replayed against another instance, or after a restart.
+		require.EqualError(t, otherInstanceCache.CheckAndSaveNonce("nonce1", time.Now().Add(-30*time.Minute)),
+			"timestamp too old")
+		restartedCache := umasql.NewPersistenceAdapter(db, dialect, time.Now().Add(-time.Hour)).NonceCache()
+		require.EqualError(t, restartedCache.CheckAndSaveNonce("nonce1", time.Now().Add(-30*time.Minute)),
+			"timestamp too old")
+
+		// The oldest valid timestamp never moves back.
+		otherInstanceCache.PurgeNoncesOlderThan(time.Now().Add(-time.Hour))
+		require.EqualError(t, nonceCache.CheckAndSaveNonce("nonce1", time.Now().Add(-30*time.Minute)),
+			"timestamp too old")
+		require.NoError(t, nonceCache.CheckAndSaveNonce("nonce2", time.Now()))
+		require.EqualError(t, otherInstanceCache.CheckAndSaveNonce("nonce2", time.Now()), "nonce already used")
+	})
+}
+
+func TestSqlPublicKeyCache(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		adapter := umasql.NewPersistenceAdapter(db, dialect, time.Time{})
+		clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+		adapter.SetClock(clock)
+		cache := adapter.PublicKeyCache()
+
+		pubKeyResponse := umatest.ReceiverKeypair().PubKeyResponse()
+		expiration := clock.Now().Add(time.Hour).Unix()
+		pubKeyResponse.ExpirationTimestamp = &expiration
+		cache.AddPublicKeyForVasp("vasp2.com", &pubKeyResponse)
+		cached := cache.FetchPublicKeyForVasp("vasp2.com")
+		require.NotNil(t, cached)
+		require.Equal(t, *pubKeyResponse.SigningPubKeyHex, *cached.SigningPubKeyHex)
+
+		// Updating the keys of a VASP replaces them.
+		otherPubKeyResponse := umatest.SenderKeypair().PubKeyResponse()
+		cache.AddPublicKeyForVasp("vasp2.com", &otherPubKeyResponse)
+		cached = cache.FetchPublicKeyForVasp("vasp2.com")
+		require.Equal(t, *otherPubKeyResponse.SigningPubKeyHex, *cached.SigningPubKeyHex)
+
+		// Expired keys are not returned, but are kept as the last known keys.
+		cache.AddPublicKeyForVasp("vasp3.com", &pubKeyResponse)
+		clock.Advance(2 * time.Hour)
+		require.Nil(t, cache.FetchPublicKeyForVasp("vasp3.com"))
+		lastKnownCache, ok := cache.(uma.LastKnownPublicKeyCache)
+		require.True(t, ok)
+		require.NotNil(t, lastKnownCache.LastKnownPublicKeyForVasp("vasp3.com"))
+
+		cache.RemovePublicKeyForVasp("vasp2.com")
+		require.Nil(t, cache.FetchPublicKeyForVasp("vasp2.com"))
+		cache.Clear()
+		require.Nil(t, lastKnownCache.LastKnownPublicKeyForVasp("vasp3.com"))
+	})
+}
+
+func TestSqlPendingPayRequestStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		ctx := context.Background()
+		store := umasql.NewPersistenceAdapter(db, dialect, time.Time{}).PendingPayRequests()
+
+		pending := uma.PendingPayRequest{
+			Id:              "payment1",
+			PayeeIdentifier: umatest.DefaultReceiverAddress,
+			PayRequest:      *umatest.PayReqFixture(umatest.PayReqFixtureOptions{}),
+			CreatedAt:       time.Unix(1_700_000_000, 0),
+		}
+		require.NoError(t, store.SavePendingPayRequest(ctx, pending))
+		saved, err := store.GetPendingPayRequest(ctx, "payment1")
+		require.NoError(t, err)
+		require.Nil(t, saved.PayReqResponse)
+
+		response := umatest.PayReqResponseFixture(umatest.PayReqResponseFixtureOptions{})
+		pending.PayReqResponse = response
+		require.NoError(t, store.SavePendingPayRequest(ctx, pending))
+		saved, err = store.GetPendingPayRequest(ctx, "payment1")
+		require.NoError(t, err)
+		require.Equal(t, pending.PayRequest.Amount, saved.PayRequest.Amount)
+		require.Equal(t, response.EncodedInvoice, saved.PayReqResponse.EncodedInvoice)
+		require.Equal(t, pending.CreatedAt, saved.CreatedAt)
+
+		require.NoError(t, store.DeletePendingPayRequest(ctx, "payment1"))
+		_, err = store.GetPendingPayRequest(ctx, "payment1")
+		require.ErrorIs(t, err, uma.ErrRecordNotFound)
+	})
+}
+
+func TestSqlQuoteStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		ctx := context.Background()
+		store := umasql.NewPersistenceAdapter(db, dialect, time.Time{}).Quotes()
+
+		response := umatest.PayReqResponseFixture(umatest.PayReqResponseFixtureOptions{})
+		quote := uma.StoredQuote{
+			Quote:          uma.Quote{Id: "quote1", ExpiresAt: time.Unix(1_700_000_600, 0)},
+			EncodedInvoice: response.EncodedInvoice,
+			PaymentInfo:    *response.PaymentInfo,
+			CreatedAt:      time.Unix(1_700_000_000, 0),
+		}
+		require.NoError(t, store.SaveQuote(ctx, quote))
+		saved, err := store.GetQuote(ctx, "quote1")
+		require.NoError(t, err)
+		require.Equal(t, quote, *saved)
+
+		require.NoError(t, store.PurgeQuotesExpiredBefore(ctx, time.Unix(1_700_000_000, 0)))
+		_, err = store.GetQuote(ctx, "quote1")
+		require.NoError(t, err)
+		require.NoError(t, store.PurgeQuotesExpiredBefore(ctx, time.Unix(1_700_001_000, 0)))
+		_, err = store.GetQuote(ctx, "quote1")
+		require.ErrorIs(t, err, uma.ErrRecordNotFound)
+	})
+}
+
+func TestSqlPaymentReceiptStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *sql.DB, dialect umasql.Dialect) {
+		ctx := context.Background()
+		store := umasql.NewPersistenceAdapter(db, dialect, time.Time{}).PaymentReceipts()
+
+		receipt := protocol.PaymentReceipt{
+			PaymentHash:        "8b2cdc1ea8d5d4ac7db3c3cc1b0bba43f0c7b68ba1ee49b8c1f2e44cbd1a71b6",
+			EncodedInvoice:     "lnbcrt10n1fake",
+			AmountMsats:        1000,
+			SenderIdentifier:   umatest.DefaultPayerIdentifier,
+			ReceiverIdentifier: umatest.DefaultReceiverAddress,
+			SettledAt:          1_700_000_000,
+			VaspDomain:         umatest.DefaultSenderVaspDomain,
+		}
+		require.NoError(t, store.SavePaymentReceipt(ctx, receipt))
+		receipt.VaspDomain = umatest.DefaultReceiverVaspDomain
+		require.NoError(t, store.SavePaymentReceipt(ctx, receipt))
+		// Saving a receipt again replaces it.
+		require.NoError(t, store.SavePaymentReceipt(ctx, receipt))
+
+		receipts, err := store.GetPaymentReceipts(ctx, receipt.PaymentHash)
+		require.NoError(t, err)
+		require.Len(t, receipts, 2)
+		receipts, err = store.GetPaymentReceipts(ctx, "unknown")
+		require.NoError(t, err)
+		require.Empty(t, receipts)
+	})
+}
@@ -0,0 +1,78 @@
+package umatest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a uma.Clock which only moves when told to, so that tests can check expiration logic without sleeping:
+//
+//	clock := umatest.NewFakeClock(time.Unix(1_700_000_000, 0))
+//	cache := uma.NewInMemoryPublicKeyCache()
+//	cache.SetClock(clock)
+//	clock.Advance(time.Hour)
+//
+// A FakeClock is a uma.TimerClock, so waits between retries, polls and refreshes also end when the clock is advanced
+// past them. A FakeClock is safe for concurrent use.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+// fakeClockWaiter is a channel returned by FakeClock.After, which receives the time once the clock reaches deadline.
+type fakeClockWaiter struct {
+	deadline time.Time
+	elapsed  chan time.Time
+}
+
+// NewFakeClock Creates a FakeClock frozen at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is frozen at.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set freezes the clock at the given time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+	c.fireWaiters()
+}
+
+// Advance moves the clock forward by the given duration.
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+	c.fireWaiters()
+}
+
+// After returns a channel which receives the time once the clock is advanced by the given duration.
+func (c *FakeClock) After(duration time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elapsed := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeClockWaiter{deadline: c.now.Add(duration), elapsed: elapsed})
+	c.fireWaiters()
+	return elapsed
+}
+
+// fireWaiters sends the time to the waiters whose deadline has passed. The mutex must be held.
+func (c *FakeClock) fireWaiters() {
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			pending = append(pending, waiter)
+		} else {
+			waiter.elapsed <- c.now
+		}
+	}
+	c.waiters = pending
+}
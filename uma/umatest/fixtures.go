@@ -0,0 +1,357 @@
+package umatest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// Default identities used by the fixtures.
+const (
+	DefaultSenderVaspDomain   = "vasp1.com"
+	DefaultReceiverVaspDomain = "vasp2.com"
+	DefaultPayerIdentifier    = "$alice@vasp1.com"
+	DefaultReceiverAddress    = "$bob@vasp2.com"
+)
+
+// SignatureOverrides control how a fixture is signed, so that tests can produce messages which fail verification,
+// e.g. with a bad signature or an expired timestamp. The zero value produces a valid signature.
+type SignatureOverrides struct {
+	// Keypair signs the message. Defaults to SenderKeypair for messages from the sending VASP and ReceiverKeypair for
+	// messages from the receiving VASP.
+	Keypair *Keypair
+	// Nonce is the signature nonce. Defaults to a random nonce.
+	Nonce string
+	// Timestamp is the signature timestamp. Defaults to now.
+	Timestamp time.Time
+	// CorruptSignature alters the signature after signing, so that it no longer matches the message.
+	CorruptSignature bool
+}
+
+func (s SignatureOverrides) nonceAndTimestamp() (string, time.Time) {
+	nonce := s.Nonce
+	if nonce == "" {
+		generatedNonce, err := uma.GenerateNonce()
+		if err != nil {
+			panic("umatest: failed to generate nonce: " + err.Error())
+		}
+		nonce = *generatedNonce
+	}
+	timestamp := s.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return nonce, timestamp
+}
+
+func (s SignatureOverrides) sign(payload []byte, defaultKeypair Keypair) string {
+	keypair := defaultKeypair
+	if s.Keypair != nil {
+		keypair = *s.Keypair
+	}
+	signature, err := keypair.Signer().Sign(payload)
+	if err != nil {
+		panic("umatest: failed to sign fixture: " + err.Error())
+	}
+	if s.CorruptSignature {
+		// Changing the last byte of S keeps the DER encoding valid, so that verification rather than parsing fails.
+		signature[len(signature)-1] ^= 0x01
+	}
+	return hex.EncodeToString(signature)
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic("umatest: failed to create fixture: " + err.Error())
+	}
+	return value
+}
+
+// LnurlpRequestFixtureOptions are the fields of a SignedLnurlpRequestFixture. Zero values are replaced by defaults.
+type LnurlpRequestFixtureOptions struct {
+	SignatureOverrides
+	// ReceiverAddress defaults to DefaultReceiverAddress.
+	ReceiverAddress string
+	// VaspDomain is the sending VASP's domain. Defaults to DefaultSenderVaspDomain.
+	VaspDomain string
+	// UmaVersion defaults to uma.UmaProtocolVersion.
+	UmaVersion string
+	// IsSubjectToTravelRule is whether the sending VASP requires travel rule information.
+	IsSubjectToTravelRule bool
+}
+
+// SignedLnurlpRequestFixture returns a signed lnurlp request from the sending VASP.
+func SignedLnurlpRequestFixture(opts LnurlpRequestFixtureOptions) *protocol.LnurlpRequest {
+	request := protocol.LnurlpRequest{
+		ReceiverAddress:       withDefault(opts.ReceiverAddress, DefaultReceiverAddress),
+		IsSubjectToTravelRule: &opts.IsSubjectToTravelRule,
+	}
+	vaspDomain := withDefault(opts.VaspDomain, DefaultSenderVaspDomain)
+	umaVersion := withDefault(opts.UmaVersion, uma.UmaProtocolVersion)
+	nonce, timestamp := opts.nonceAndTimestamp()
+	request.VaspDomain = &vaspDomain
+	request.UmaVersion = &umaVersion
+	request.Nonce = &nonce
+	request.Timestamp = &timestamp
+	signature := opts.sign(must(request.SignablePayload()), SenderKeypair())
+	request.Signature = &signature
+	return &request
+}
+
+// LnurlpResponseFixtureOptions are the fields of a SignedLnurlpResponseFixture. Zero values are replaced by defaults.
+type LnurlpResponseFixtureOptions struct {
+	SignatureOverrides
+	// ReceiverAddress defaults to DefaultReceiverAddress.
+	ReceiverAddress string
+	// Callback defaults to a pay request URL at the receiver's domain.
+	Callback string
+	// Currencies defaults to USD.
+	Currencies []protocol.Currency
+	// KycStatus defaults to protocol.KycStatusVerified.
+	KycStatus *protocol.KycStatus
+	// UmaVersion defaults to uma.UmaProtocolVersion.
+	UmaVersion string
+}
+
+// SignedLnurlpResponseFixture returns a signed lnurlp response from the receiving VASP.
+func SignedLnurlpResponseFixture(opts LnurlpResponseFixtureOptions) *protocol.LnurlpResponse {
+	receiverAddress := withDefault(opts.ReceiverAddress, DefaultReceiverAddress)
+	currencies := opts.Currencies
+	if currencies == nil {
+		currencies = []protocol.Currency{usdCurrency()}
+	}
+	kycStatus := protocol.KycStatusVerified
+	if opts.KycStatus != nil {
+		kycStatus = *opts.KycStatus
+	}
+	request := SignedLnurlpRequestFixture(LnurlpRequestFixtureOptions{
+		ReceiverAddress: receiverAddress,
+		UmaVersion:      opts.UmaVersion,
+	})
+	response := must(uma.GetLnurlpResponseWithSigner(
+		*request,
+		withDefault(opts.Callback, payReqCallback(receiverAddress)),
+		mustMetadata(receiverAddress),
+		currencies,
+		protocol.CounterPartyDataOptions{
+			"identifier": protocol.CounterPartyDataOption{Mandatory: true},
+			"compliance": protocol.CounterPartyDataOption{Mandatory: true},
+		},
+		kycStatus,
+		ReceiverKeypair().Signer(),
+		true,
+		nil,
+		nil,
+	))
+	nonce, timestamp := opts.nonceAndTimestamp()
+	compliance := *response.Compliance
+	compliance.Nonce = nonce
+	compliance.Timestamp = timestamp.Unix()
+	signablePayload := (&protocol.UmaLnurlpResponse{Compliance: compliance}).SignablePayload()
+	compliance.Signature = opts.sign(signablePayload, ReceiverKeypair())
+	response.Compliance = &compliance
+	return response
+}
+
+// PayReqFixtureOptions are the fields of a PayReqFixture. Zero values are replaced by defaults.
+type PayReqFixtureOptions struct {
+	SignatureOverrides
+	// PayerIdentifier defaults to DefaultPayerIdentifier.
+	PayerIdentifier string
+	// Amount is in the smallest unit of the receiving currency. Defaults to 1000.
+	Amount int64
+	// ReceivingCurrencyCode defaults to USD.
+	ReceivingCurrencyCode string
+	// UmaMajorVersion defaults to the major version of uma.UmaProtocolVersion.
+	UmaMajorVersion *int
+	// ReceiverEncryptionKeypair is used to encrypt TravelRuleInfo. Defaults to ReceiverKeypair.
+	ReceiverEncryptionKeypair *Keypair
+	// TravelRuleInfo is encrypted and included in the compliance data if set.
+	TravelRuleInfo *string
+	// KycStatus defaults to protocol.KycStatusVerified.
+	KycStatus *protocol.KycStatus
+	// Utxos are the sender's channel UTXOs.
+	Utxos []string
+	// UtxoCallback defaults to a callback URL at DefaultSenderVaspDomain.
+	UtxoCallback string
+}
+
+// PayReqFixture returns a signed UMA pay request from the sending VASP.
+func PayReqFixture(opts PayReqFixtureOptions) *protocol.PayRequest {
+	payerIdentifier := withDefault(opts.PayerIdentifier, DefaultPayerIdentifier)
+	amount := opts.Amount
+	if amount == 0 {
+		amount = 1000
+	}
+	umaMajorVersion := uma.MAJOR_VERSION
+	if opts.UmaMajorVersion != nil {
+		umaMajorVersion = *opts.UmaMajorVersion
+	}
+	encryptionKeypair := ReceiverKeypair()
+	if opts.ReceiverEncryptionKeypair != nil {
+		encryptionKeypair = *opts.ReceiverEncryptionKeypair
+	}
+	kycStatus := protocol.KycStatusVerified
+	if opts.KycStatus != nil {
+		kycStatus = *opts.KycStatus
+	}
+	utxos := append([]string{}, opts.Utxos...)
+	privateKey := SenderKeypair().PrivateKeyHandle()
+	defer privateKey.Close()
+	request := must(uma.GetUmaPayRequest(
+		amount,
+		encryptionKeypair.PrivateKey.PubKey().SerializeUncompressed(),
+		privateKey,
+		withDefault(opts.ReceivingCurrencyCode, "USD"),
+		true,
+		payerIdentifier,
+		umaMajorVersion,
+		nil,
+		nil,
+		opts.TravelRuleInfo,
+		nil,
+		kycStatus,
+		&utxos,
+		nil,
+		withDefault(opts.UtxoCallback, "https://"+DefaultSenderVaspDomain+"/api/uma/utxocallback"),
+		nil,
+		nil,
+	))
+	complianceData := must(request.PayerData.Compliance())
+	nonce, timestamp := opts.nonceAndTimestamp()
+	complianceData.SignatureNonce = nonce
+	complianceData.SignatureTimestamp = timestamp.Unix()
+	setCompliance(request.PayerData, complianceData)
+	complianceData.Signature = opts.sign(must(request.SignablePayload()), SenderKeypair())
+	setCompliance(request.PayerData, complianceData)
+	return request
+}
+
+// PayReqResponseFixtureOptions are the fields of a PayReqResponseFixture. Zero values are replaced by defaults.
+type PayReqResponseFixtureOptions struct {
+	SignatureOverrides
+	// PayerIdentifier defaults to DefaultPayerIdentifier.
+	PayerIdentifier string
+	// PayeeIdentifier defaults to DefaultReceiverAddress.
+	PayeeIdentifier string
+	// Amount is in the smallest unit of the receiving currency. Defaults to 1000.
+	Amount int64
+	// Currency defaults to USD.
+	Currency *protocol.Currency
+	// InvoiceCreator defaults to FakeInvoiceCreator.
+	InvoiceCreator uma.InvoiceCreator
+	// Utxos are the receiver's channel UTXOs.
+	Utxos []string
+}
+
+// PayReqResponseFixture returns a signed UMA pay request response from the receiving VASP.
+func PayReqResponseFixture(opts PayReqResponseFixtureOptions) *protocol.PayReqResponse {
+	payerIdentifier := withDefault(opts.PayerIdentifier, DefaultPayerIdentifier)
+	payeeIdentifier := withDefault(opts.PayeeIdentifier, DefaultReceiverAddress)
+	currency := usdCurrency()
+	if opts.Currency != nil {
+		currency = *opts.Currency
+	}
+	var invoiceCreator uma.InvoiceCreator = FakeInvoiceCreator{}
+	if opts.InvoiceCreator != nil {
+		invoiceCreator = opts.InvoiceCreator
+	}
+	request := PayReqFixture(PayReqFixtureOptions{
+		PayerIdentifier:       payerIdentifier,
+		Amount:                opts.Amount,
+		ReceivingCurrencyCode: currency.Code,
+	})
+	response := must(uma.GetPayReqResponseWithSigner(
+		*request,
+		invoiceCreator,
+		mustMetadata(payeeIdentifier),
+		currency,
+		currency.MillisatoshiPerUnit,
+		0,
+		append([]string{}, opts.Utxos...),
+		payeeIdentifier,
+		ReceiverKeypair().Signer(),
+		nil,
+		nil,
+		&protocol.PayeeData{"identifier": payeeIdentifier},
+		nil,
+		nil,
+	))
+	complianceData := must(response.PayeeData.Compliance())
+	nonce, timestamp := opts.nonceAndTimestamp()
+	unixTimestamp := timestamp.Unix()
+	complianceData.SignatureNonce = &nonce
+	complianceData.SignatureTimestamp = &unixTimestamp
+	signature := opts.sign(must(complianceData.SignablePayload(payerIdentifier, payeeIdentifier)), ReceiverKeypair())
+	complianceData.Signature = &signature
+	(*response.PayeeData)["compliance"] = must(complianceData.AsMap())
+	return response
+}
+
+// PostTransactionCallbackFixtureOptions are the fields of a PostTransactionCallbackFixture. Zero values are replaced
+// by defaults.
+type PostTransactionCallbackFixtureOptions struct {
+	SignatureOverrides
+	// VaspDomain is the domain of the VASP sending the callback. Defaults to DefaultReceiverVaspDomain.
+	VaspDomain string
+	// Utxos are the channel UTXOs and amounts used by the payment.
+	Utxos []protocol.UtxoWithAmount
+}
+
+// PostTransactionCallbackFixture returns a signed post transaction callback from the receiving VASP.
+func PostTransactionCallbackFixture(opts PostTransactionCallbackFixtureOptions) *protocol.PostTransactionCallback {
+	vaspDomain := withDefault(opts.VaspDomain, DefaultReceiverVaspDomain)
+	nonce, timestamp := opts.nonceAndTimestamp()
+	unixTimestamp := timestamp.Unix()
+	callback := protocol.PostTransactionCallback{
+		Utxos:      append([]protocol.UtxoWithAmount{}, opts.Utxos...),
+		VaspDomain: &vaspDomain,
+		Nonce:      &nonce,
+		Timestamp:  &unixTimestamp,
+	}
+	signature := opts.sign(*must(callback.SignablePayload()), ReceiverKeypair())
+	callback.Signature = &signature
+	return &callback
+}
+
+func setCompliance(payerData *protocol.PayerData, complianceData *protocol.CompliancePayerData) {
+	(*payerData)["compliance"] = must(complianceData.AsMap())
+}
+
+func payReqCallback(receiverAddress string) string {
+	user, domain, _ := strings.Cut(strings.TrimPrefix(receiverAddress, "$"), "@")
+	return "https://" + domain + "/api/uma/payreq/" + user
+}
+
+func withDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func usdCurrency() protocol.Currency {
+	return protocol.Currency{
+		Code:                "USD",
+		Name:                "US Dollar",
+		Symbol:              "$",
+		MillisatoshiPerUnit: 34_150,
+		Convertible:         protocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10_000_000},
+		Decimals:            2,
+	}
+}
+
+func mustMetadata(receiverAddress string) string {
+	metadata, err := json.Marshal([][]string{
+		{"text/plain", "Pay to " + receiverAddress},
+		{"text/identifier", receiverAddress},
+	})
+	if err != nil {
+		panic("umatest: failed to encode metadata: " + err.Error())
+	}
+	return string(metadata)
+}
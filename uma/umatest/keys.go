@@ -0,0 +1,79 @@
+package umatest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// Keypair is a secp256k1 key pair used by a test VASP for both signing and encryption.
+type Keypair struct {
+	PrivateKey *secp256k1.PrivateKey
+}
+
+// GenerateKeypair generates a random Keypair.
+func GenerateKeypair() Keypair {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		panic("umatest: failed to generate private key: " + err.Error())
+	}
+	return Keypair{PrivateKey: privateKey}
+}
+
+// KeypairFromSeed derives a Keypair from a seed, so that tests get the same keys on every run.
+func KeypairFromSeed(seed string) Keypair {
+	privateKeyBytes := sha256.Sum256([]byte(seed))
+	return Keypair{PrivateKey: secp256k1.PrivKeyFromBytes(privateKeyBytes[:])}
+}
+
+// SenderKeypair returns the deterministic Keypair used by default for messages from the sending VASP.
+func SenderKeypair() Keypair {
+	return KeypairFromSeed("umatest sending vasp")
+}
+
+// ReceiverKeypair returns the deterministic Keypair used by default for messages from the receiving VASP.
+func ReceiverKeypair() Keypair {
+	return KeypairFromSeed("umatest receiving vasp")
+}
+
+// PrivateKeyBytes returns the serialized private key, as accepted by the SDK functions which take an encryption
+// private key.
+func (k Keypair) PrivateKeyBytes() []byte {
+	return k.PrivateKey.Serialize()
+}
+
+// PrivateKeyHandle returns a PrivateKeyHandle for the private key, as accepted by the SDK functions which sign with a
+// private key.
+func (k Keypair) PrivateKeyHandle() *uma.PrivateKeyHandle {
+	handle, err := uma.NewPrivateKeyHandle(k.PrivateKeyBytes(), false)
+	if err != nil {
+		panic("umatest: failed to create private key handle: " + err.Error())
+	}
+	return handle
+}
+
+// PubKeyHex returns the hex-encoded uncompressed public key.
+func (k Keypair) PubKeyHex() string {
+	return hex.EncodeToString(k.PrivateKey.PubKey().SerializeUncompressed())
+}
+
+// Signer returns an UmaSigner for the private key.
+func (k Keypair) Signer() uma.UmaSigner {
+	signer, err := uma.NewInMemorySigner(k.PrivateKeyBytes())
+	if err != nil {
+		panic("umatest: failed to create signer: " + err.Error())
+	}
+	return signer
+}
+
+// PubKeyResponse returns a PubKeyResponse with the public key as both the signing and encryption key.
+func (k Keypair) PubKeyResponse() protocol.PubKeyResponse {
+	pubKeyHex := k.PubKeyHex()
+	return protocol.PubKeyResponse{
+		SigningPubKeyHex:    &pubKeyHex,
+		EncryptionPubKeyHex: &pubKeyHex,
+	}
+}
@@ -0,0 +1,269 @@
+package umatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// FakeInvoice is the encoded invoice returned by FakeInvoiceCreator.
+const FakeInvoice = "lnbcrt100n1p0z9j"
+
+// FakeInvoiceCreator is an InvoiceCreator which returns FakeInvoice for every payment.
+type FakeInvoiceCreator struct{}
+
+func (FakeInvoiceCreator) CreateInvoice(int64, string, *string) (*string, error) {
+	encodedInvoice := FakeInvoice
+	return &encodedInvoice, nil
+}
+
+// MockReceivingVasp is a receiving VASP which serves lnurlp and pay requests for any user. Its fields may be changed
+// before requests are sent to adjust how it responds.
+type MockReceivingVasp struct {
+	*MockVasp
+	// Currencies are the currencies offered in lnurlp responses. Pay requests are converted at their
+	// MillisatoshiPerUnit rate. Defaults to USD and SAT.
+	Currencies []protocol.Currency
+	// PayerDataOptions are the payer data fields requested in lnurlp responses. Defaults to the identifier, name,
+	// email and compliance fields.
+	PayerDataOptions protocol.CounterPartyDataOptions
+	// KycStatus is the KYC status of every user at the VASP. Defaults to verified.
+	KycStatus protocol.KycStatus
+	// ReceiverFeesMillisats is the fee charged for every payment.
+	ReceiverFeesMillisats int64
+	// InvoiceCreator creates the invoices returned in pay request responses. Defaults to FakeInvoiceCreator.
+	InvoiceCreator uma.InvoiceCreator
+	// ChannelUtxos are the receiver UTXOs included in pay request responses.
+	ChannelUtxos []string
+}
+
+// NewMockReceivingVasp starts a MockReceivingVasp with a fresh key pair. The caller should call Close when done.
+func NewMockReceivingVasp() *MockReceivingVasp {
+	mux := http.NewServeMux()
+	vasp := &MockReceivingVasp{
+		Currencies: []protocol.Currency{
+			{
+				Code:                "USD",
+				Name:                "US Dollar",
+				Symbol:              "$",
+				MillisatoshiPerUnit: 34_150,
+				Convertible:         protocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 10_000_000},
+				Decimals:            2,
+			},
+			{
+				Code:                "SAT",
+				Name:                "Satoshi",
+				Symbol:              "SAT",
+				MillisatoshiPerUnit: 1000,
+				Convertible:         protocol.ConvertibleCurrency{MinSendable: 1, MaxSendable: 100_000_000},
+				Decimals:            0,
+			},
+		},
+		PayerDataOptions: protocol.CounterPartyDataOptions{
+			"identifier": protocol.CounterPartyDataOption{Mandatory: true},
+			"name":       protocol.CounterPartyDataOption{Mandatory: false},
+			"email":      protocol.CounterPartyDataOption{Mandatory: false},
+			"compliance": protocol.CounterPartyDataOption{Mandatory: true},
+		},
+		KycStatus:      protocol.KycStatusVerified,
+		InvoiceCreator: FakeInvoiceCreator{},
+		ChannelUtxos:   []string{},
+	}
+	mux.HandleFunc("/.well-known/lnurlp/", vasp.handleLnurlpRequest)
+	mux.HandleFunc("/api/uma/payreq/", vasp.handlePayRequest)
+	vasp.MockVasp = newMockVasp(mux)
+	return vasp
+}
+
+func (v *MockReceivingVasp) handleLnurlpRequest(w http.ResponseWriter, r *http.Request) {
+	user := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/.well-known/lnurlp/"), "$")
+	request, err := uma.ParseLnurlpRequestWithReceiverDomain(*r.URL, v.Domain)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.IsUmaRequest() {
+		pubKeys, err := v.fetchPublicKey(*request.VaspDomain)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		err = uma.VerifyUmaLnurlpQuerySignature(*request.AsUmaRequest(), *pubKeys, v.NonceCache, v.Options...)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	metadata, err := v.metadata(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	response, err := uma.GetLnurlpResponseWithSigner(
+		*request,
+		v.Server.URL+"/api/uma/payreq/"+user,
+		metadata,
+		v.Currencies,
+		v.PayerDataOptions,
+		v.KycStatus,
+		v.Signer,
+		true,
+		nil,
+		nil,
+		v.Options...,
+	)
+	if err != nil {
+		var unsupportedVersionError uma.UnsupportedVersionError
+		if errors.As(err, &unsupportedVersionError) {
+			writeJson(w, http.StatusPreconditionFailed, &unsupportedVersionError)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+func (v *MockReceivingVasp) handlePayRequest(w http.ResponseWriter, r *http.Request) {
+	user := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/uma/payreq/"), "$")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	request, err := uma.ParsePayRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !request.IsUmaRequest() {
+		writeError(w, http.StatusBadRequest, errors.New("only UMA pay requests are supported"))
+		return
+	}
+	payerIdentifier := request.PayerData.Identifier()
+	if payerIdentifier == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing payer identifier"))
+		return
+	}
+	payerVaspDomain, err := uma.GetVaspDomainFromUmaAddress(*payerIdentifier)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	pubKeys, err := v.fetchPublicKey(payerVaspDomain)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	verifyOpts := append(v.Options[:len(v.Options):len(v.Options)], uma.WithSenderVaspDomain(payerVaspDomain))
+	if err = uma.VerifyPayReqSignature(request, *pubKeys, v.NonceCache, verifyOpts...); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	currency, err := v.currency(request.ReceivingCurrencyCode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	metadata, err := v.metadata(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	payeeIdentifier := v.Address(user)
+	response, err := uma.GetPayReqResponseWithSigner(
+		*request,
+		v.InvoiceCreator,
+		metadata,
+		*currency,
+		currency.MillisatoshiPerUnit,
+		v.ReceiverFeesMillisats,
+		v.ChannelUtxos,
+		payeeIdentifier,
+		v.Signer,
+		nil,
+		nil,
+		&protocol.PayeeData{"identifier": payeeIdentifier},
+		nil,
+		nil,
+		v.Options...,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+// SendPostTransactionCallback Signs and posts a post transaction callback to the sending VASP, as a receiving VASP does
+// once a payment completes.
+//
+// Args:
+//
+//	ctx: the context for the outbound request.
+//	utxoCallback: the utxoCallback URL from the payer's compliance data.
+//	utxos: the receiver's channel UTXOs used by the payment.
+func (v *MockReceivingVasp) SendPostTransactionCallback(
+	ctx context.Context,
+	utxoCallback string,
+	utxos []protocol.UtxoWithAmount,
+) error {
+	callback, err := uma.SignPostTransactionCallback(protocol.PostTransactionCallback{
+		Utxos:      utxos,
+		VaspDomain: &v.Domain,
+	}, v.Signer)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, utxoCallback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := v.Server.Client().Do(request)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(response.Body)
+		return uma.InvalidResponseError{StatusCode: response.StatusCode, Body: responseBody}
+	}
+	return nil
+}
+
+func (v *MockReceivingVasp) currency(code *string) (*protocol.Currency, error) {
+	if code == nil {
+		return nil, errors.New("missing receiving currency")
+	}
+	for _, currency := range v.Currencies {
+		if currency.Code == *code {
+			return &currency, nil
+		}
+	}
+	return nil, errors.New("unsupported currency: " + *code)
+}
+
+func (v *MockReceivingVasp) metadata(user string) (string, error) {
+	metadata, err := json.Marshal([][]string{
+		{"text/plain", "Pay to " + v.Domain + " user $" + user},
+		{"text/identifier", v.Address(user)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(metadata), nil
+}
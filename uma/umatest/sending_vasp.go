@@ -0,0 +1,200 @@
+package umatest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// MockSendingVasp is a sending VASP which pays UMA addresses and accepts post transaction callbacks. Its fields may
+// be changed before paying to adjust the requests it sends.
+type MockSendingVasp struct {
+	*MockVasp
+	// User is the user at this VASP who sends payments. Defaults to "alice".
+	User string
+	// KycStatus is the KYC status of User. Defaults to verified.
+	KycStatus protocol.KycStatus
+	// Utxos are the sender UTXOs included in pay requests.
+	Utxos []string
+	// UmaVersion is the UMA version of lnurlp requests. Defaults to uma.UmaProtocolVersion.
+	UmaVersion string
+
+	callbacksLock sync.Mutex
+	callbacks     []protocol.PostTransactionCallback
+}
+
+// NewMockSendingVasp starts a MockSendingVasp with a fresh key pair. The caller should call Close when done.
+func NewMockSendingVasp() *MockSendingVasp {
+	mux := http.NewServeMux()
+	vasp := &MockSendingVasp{
+		User:       "alice",
+		KycStatus:  protocol.KycStatusVerified,
+		Utxos:      []string{},
+		UmaVersion: uma.UmaProtocolVersion,
+	}
+	mux.HandleFunc("/api/uma/utxocallback", vasp.handlePostTransactionCallback)
+	vasp.MockVasp = newMockVasp(mux)
+	return vasp
+}
+
+// UtxoCallbackUrl returns the URL at which the VASP accepts post transaction callbacks.
+func (v *MockSendingVasp) UtxoCallbackUrl() string {
+	return v.Server.URL + "/api/uma/utxocallback"
+}
+
+// ReceivedCallbacks returns the verified post transaction callbacks received so far.
+func (v *MockSendingVasp) ReceivedCallbacks() []protocol.PostTransactionCallback {
+	v.callbacksLock.Lock()
+	defer v.callbacksLock.Unlock()
+	return append([]protocol.PostTransactionCallback{}, v.callbacks...)
+}
+
+// Lnurlp Sends a signed lnurlp request to the receiver's VASP and verifies the response.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	receiverAddress: the UMA address of the receiver, e.g. $bob@vasp2.com.
+func (v *MockSendingVasp) Lnurlp(ctx context.Context, receiverAddress string) (*protocol.LnurlpResponse, error) {
+	isSubjectToTravelRule := true
+	request, err := uma.SignLnurlpRequest(protocol.LnurlpRequest{
+		ReceiverAddress:       receiverAddress,
+		IsSubjectToTravelRule: &isSubjectToTravelRule,
+		VaspDomain:            &v.Domain,
+		UmaVersion:            &v.UmaVersion,
+	}, v.Signer)
+	if err != nil {
+		return nil, err
+	}
+	response, err := uma.SendLnurlpRequest(ctx, *request, v.Options...)
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsUmaResponse() {
+		return nil, errors.New("receiver did not respond with an UMA lnurlp response")
+	}
+	receiverDomain, err := uma.GetVaspDomainFromUmaAddress(receiverAddress)
+	if err != nil {
+		return nil, err
+	}
+	pubKeys, err := v.fetchPublicKey(receiverDomain)
+	if err != nil {
+		return nil, err
+	}
+	err = uma.VerifyUmaLnurlpResponseSignatureForReceiver(
+		*response.AsUmaResponse(), *pubKeys, v.NonceCache, receiverAddress, v.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Pay Runs the full sending flow against the receiver's VASP: the lnurlp request, the pay request and the
+// verification of both responses.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	receiverAddress: the UMA address of the receiver, e.g. $bob@vasp2.com.
+//	amount: the amount to send, in the smallest unit of the receiving currency.
+//	receivingCurrencyCode: the code of the currency that the receiver will receive.
+func (v *MockSendingVasp) Pay(
+	ctx context.Context,
+	receiverAddress string,
+	amount int64,
+	receivingCurrencyCode string,
+) (*protocol.PayReqResponse, error) {
+	lnurlpResponse, err := v.Lnurlp(ctx, receiverAddress)
+	if err != nil {
+		return nil, err
+	}
+	receiverDomain, err := uma.GetVaspDomainFromUmaAddress(receiverAddress)
+	if err != nil {
+		return nil, err
+	}
+	pubKeys, err := v.fetchPublicKey(receiverDomain)
+	if err != nil {
+		return nil, err
+	}
+	encryptionPubKey, err := pubKeys.EncryptionPubKey()
+	if err != nil {
+		return nil, err
+	}
+	umaVersion, err := uma.ParseVersion(*lnurlpResponse.UmaVersion)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := uma.NewPrivateKeyHandle(v.PrivateKey.Serialize(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer privateKey.Close()
+	payerIdentifier := v.Address(v.User)
+	payRequest, err := uma.GetUmaPayRequest(
+		amount,
+		encryptionPubKey,
+		privateKey,
+		receivingCurrencyCode,
+		true,
+		payerIdentifier,
+		umaVersion.Major,
+		nil,
+		nil,
+		nil,
+		nil,
+		v.KycStatus,
+		&v.Utxos,
+		nil,
+		v.UtxoCallbackUrl(),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	response, err := uma.SendPayRequest(ctx, lnurlpResponse.Callback, payRequest, v.Options...)
+	if err != nil {
+		return nil, err
+	}
+	err = uma.VerifyPayReqResponseSignature(
+		response, *pubKeys, v.NonceCache, payerIdentifier, receiverAddress, v.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (v *MockSendingVasp) handlePostTransactionCallback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	callback, err := uma.ParsePostTransactionCallback(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if callback.VaspDomain == nil {
+		writeError(w, http.StatusBadRequest, errors.New("missing vaspDomain"))
+		return
+	}
+	pubKeys, err := v.fetchPublicKey(*callback.VaspDomain)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if err = uma.VerifyPostTransactionCallbackSignature(callback, *pubKeys, v.NonceCache, v.Options...); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	v.callbacksLock.Lock()
+	v.callbacks = append(v.callbacks, *callback)
+	v.callbacksLock.Unlock()
+	writeJson(w, http.StatusOK, map[string]string{"status": "OK"})
+}
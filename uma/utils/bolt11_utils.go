@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/bech32"
+)
+
+// defaultBolt11Expiry is the invoice expiry used when a BOLT11 invoice has no expiry field.
+const defaultBolt11Expiry = time.Hour
+
+// bolt11PaymentHashTag is the type of the BOLT11 tagged field holding the SHA-256 payment hash ('p').
+const bolt11PaymentHashTag = 1
+
+// bolt11ExpiryTag is the type of the BOLT11 tagged field holding the expiry in seconds ('x').
+const bolt11ExpiryTag = 6
+
+// bolt11DescriptionHashTag is the type of the BOLT11 tagged field holding the SHA-256 hash of the description ('h').
+const bolt11DescriptionHashTag = 23
+
+// bolt11DescriptionHashLength is the length of the description hash and payment hash fields, in 5-bit groups.
+const bolt11DescriptionHashLength = 52
+
+// bolt11SignatureLength is the length of the signature and recovery id at the end of a BOLT11 invoice, in 5-bit groups.
+const bolt11SignatureLength = 104
+
+// Bolt11Invoice holds the fields of a BOLT11 invoice which the SDK needs to validate pay request responses. The
+// invoice signature is not checked, since the invoice is paid by the sender's node, which checks it anyway.
+type Bolt11Invoice struct {
+	// Network is the network prefix of the invoice, e.g. "bc" for mainnet or "bcrt" for regtest.
+	Network string
+	// AmountMsats is the amount of the invoice in millisatoshis, or nil if the invoice is for any amount.
+	AmountMsats *int64
+	// Timestamp is the time at which the invoice was created.
+	Timestamp time.Time
+	// Expiry is how long after Timestamp the invoice expires.
+	Expiry time.Duration
+	// DescriptionHash is the SHA-256 hash of the description, or nil if the invoice has a plain description. LNURL-pay
+	// invoices commit to the hash of the lnurlp response metadata.
+	DescriptionHash []byte
+	// PaymentHash is the SHA-256 hash of the payment preimage, which identifies the payment. The payer learns the
+	// preimage once the payment settles, which proves that it was paid.
+	PaymentHash []byte
+}
+
+// ExpiresAt returns the time at which the invoice expires.
+func (i *Bolt11Invoice) ExpiresAt() time.Time {
+	return i.Timestamp.Add(i.Expiry)
+}
+
+// DecodeBolt11 decodes the network, amount, timestamp, expiry, description hash and payment hash of a BOLT11 invoice.
+func DecodeBolt11(invoice string) (*Bolt11Invoice, error) {
+	hrp, data, err := bech32.DecodeNoLimit(strings.ToLower(strings.TrimPrefix(invoice, "lightning:")))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(hrp, "ln") {
+		return nil, errors.New("invalid bolt11 invoice prefix")
+	}
+	network, amountMsats, err := parseBolt11Amount(strings.TrimPrefix(hrp, "ln"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 7+bolt11SignatureLength {
+		return nil, errors.New("bolt11 invoice is too short")
+	}
+	decoded := Bolt11Invoice{
+		Network:     network,
+		AmountMsats: amountMsats,
+		Timestamp:   time.Unix(int64(bolt11Uint(data[:7])), 0),
+		Expiry:      defaultBolt11Expiry,
+	}
+	fields := data[7 : len(data)-bolt11SignatureLength]
+	for len(fields) > 0 {
+		if len(fields) < 3 {
+			return nil, errors.New("truncated bolt11 tagged field")
+		}
+		fieldLength := int(fields[1])<<5 | int(fields[2])
+		if len(fields) < 3+fieldLength {
+			return nil, errors.New("truncated bolt11 tagged field")
+		}
+		switch {
+		case fields[0] == bolt11ExpiryTag:
+			decoded.Expiry = time.Duration(bolt11Uint(fields[3:3+fieldLength])) * time.Second
+		case fields[0] == bolt11DescriptionHashTag && fieldLength == bolt11DescriptionHashLength:
+			decoded.DescriptionHash, err = bech32.ConvertBits(fields[3:3+fieldLength], 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bolt11 description hash: %w", err)
+			}
+		case fields[0] == bolt11PaymentHashTag && fieldLength == bolt11DescriptionHashLength:
+			decoded.PaymentHash, err = bech32.ConvertBits(fields[3:3+fieldLength], 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bolt11 payment hash: %w", err)
+			}
+		}
+		fields = fields[3+fieldLength:]
+	}
+	return &decoded, nil
+}
+
+// parseBolt11Amount splits the part of a BOLT11 human-readable part after "ln" into the network prefix and the amount
+// in millisatoshis.
+func parseBolt11Amount(hrp string) (string, *int64, error) {
+	amountStart := strings.IndexAny(hrp, "0123456789")
+	if amountStart == -1 {
+		return hrp, nil, nil
+	}
+	network, amount := hrp[:amountStart], hrp[amountStart:]
+	// Millisatoshis per unit of each multiplier, times 10 so that pico-bitcoin amounts stay integers.
+	multipliers := map[byte]int64{'m': 1_000_000_000, 'u': 1_000_000, 'n': 1_000, 'p': 1}
+	multiplier := int64(1_000_000_000_000)
+	if unitMultiplier, ok := multipliers[amount[len(amount)-1]]; ok {
+		multiplier = unitMultiplier
+		amount = amount[:len(amount)-1]
+	}
+	value, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid bolt11 amount: %w", err)
+	}
+	tenthsOfMsats := value * multiplier
+	if tenthsOfMsats/multiplier != value {
+		return "", nil, errors.New("bolt11 amount overflows")
+	}
+	if tenthsOfMsats%10 != 0 {
+		return "", nil, errors.New("bolt11 amount is not a whole number of millisatoshis")
+	}
+	amountMsats := tenthsOfMsats / 10
+	return network, &amountMsats, nil
+}
+
+// bolt11Uint reads a big-endian unsigned integer from 5-bit groups.
+func bolt11Uint(groups []byte) uint64 {
+	var value uint64
+	for _, group := range groups {
+		value = value<<5 | uint64(group)
+	}
+	return value
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// IpResolver resolves hostnames to IP addresses. *net.Resolver satisfies this interface.
+type IpResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DomainPolicy restricts which counterparty endpoints the SDK will talk to. It protects VASPs from SSRF via
+// attacker-controlled receiver addresses and callback URLs. A nil *DomainPolicy applies no restrictions beyond the
+// default scheme selection (HTTP for localhost and .onion domains, HTTPS otherwise).
+type DomainPolicy struct {
+	// RequireHttps disallows plain HTTP, even for localhost domains.
+	RequireHttps bool
+	// AllowedDomains, if non-empty, is the exhaustive list of domains which may be contacted. Entries of the form
+	// "*.example.com" match any subdomain of example.com.
+	AllowedDomains []string
+	// DeniedDomains is a list of domains which may never be contacted. Supports the same wildcards as AllowedDomains.
+	DeniedDomains []string
+	// BlockPrivateIps rejects domains which resolve to loopback, private, link-local, or unspecified addresses. The
+	// check happens before each request is sent, so it does not guard against DNS rebinding on its own. Pair it with a
+	// RequestDoer whose dialer validates addresses if that matters for your deployment.
+	BlockPrivateIps bool
+	// Resolver is used to resolve domains when BlockPrivateIps is set. Defaults to net.DefaultResolver.
+	Resolver IpResolver
+	// AllowedPorts, if non-empty, is the exhaustive list of ports which domains may specify explicitly, e.g. 8443 for
+	// a staging VASP at staging.example.com:8443. Domains without a port are always allowed.
+	AllowedPorts []int
+	// SchemeOverrides sets the scheme, "http" or "https", used to contact matching domains, e.g. to reach a staging
+	// VASP over plain HTTP. Keys support the same wildcards as AllowedDomains. RequireHttps takes precedence.
+	SchemeOverrides map[string]string
+	// AllowMixedScriptDomains accepts internationalized domains whose labels mix scripts, e.g. Latin and Cyrillic,
+	// which are rejected by default to mitigate homograph attacks. See ToAsciiDomain.
+	AllowMixedScriptDomains bool
+}
+
+// DomainPolicyError is returned when a domain or URL is rejected by a DomainPolicy.
+type DomainPolicyError struct {
+	Domain string
+	Reason string
+}
+
+func (e DomainPolicyError) Error() string {
+	return fmt.Sprintf("domain %s rejected by policy: %s", e.Domain, e.Reason)
+}
+
+// Scheme returns the URL scheme which should be used to contact the given domain.
+func (p *DomainPolicy) Scheme(domain string) string {
+	if p != nil && p.RequireHttps {
+		return "https"
+	}
+	if scheme, ok := p.schemeOverride(domain); ok {
+		return scheme
+	}
+	// Onion services are end-to-end encrypted by Tor and rarely have certificates.
+	if IsDomainLocalhost(domain) || IsDomainOnion(domain) {
+		return "http"
+	}
+	return "https"
+}
+
+// CheckDomain checks the allowlist and denylist for a domain, which may include a port. Internationalized domains
+// and patterns are compared in their ASCII form. It does not resolve the domain.
+func (p *DomainPolicy) CheckDomain(domain string) error {
+	if p == nil {
+		return nil
+	}
+	asciiDomain, err := p.ToAsciiDomain(domain)
+	if err != nil {
+		return DomainPolicyError{Domain: domain, Reason: err.Error()}
+	}
+	if err = p.checkPort(asciiDomain); err != nil {
+		return err
+	}
+	host := strings.ToLower(hostWithoutPort(asciiDomain))
+	for _, denied := range p.DeniedDomains {
+		if domainMatches(host, denied) {
+			return DomainPolicyError{Domain: domain, Reason: "domain is denied"}
+		}
+	}
+	if len(p.AllowedDomains) == 0 {
+		return nil
+	}
+	for _, allowed := range p.AllowedDomains {
+		if domainMatches(host, allowed) {
+			return nil
+		}
+	}
+	return DomainPolicyError{Domain: domain, Reason: "domain is not allowed"}
+}
+
+// CheckUrl checks that a URL may be requested under this policy, including its scheme, domain, and resolved IP
+// addresses.
+func (p *DomainPolicy) CheckUrl(ctx context.Context, rawUrl string) error {
+	if p == nil {
+		return nil
+	}
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	domain := parsedUrl.Host
+	if parsedUrl.Scheme != "https" && (parsedUrl.Scheme != "http" || p.Scheme(domain) != "http") {
+		return DomainPolicyError{Domain: domain, Reason: "scheme " + parsedUrl.Scheme + " is not allowed"}
+	}
+	if err := p.CheckDomain(domain); err != nil {
+		return err
+	}
+	// Onion services are resolved by the Tor proxy, not DNS.
+	if !p.BlockPrivateIps || IsDomainOnion(domain) {
+		return nil
+	}
+	host := hostWithoutPort(domain)
+	if ip := net.ParseIP(host); ip != nil {
+		if IsPrivateIp(ip) {
+			return DomainPolicyError{Domain: domain, Reason: "private IP addresses are not allowed"}
+		}
+		return nil
+	}
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addresses, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if IsPrivateIp(address.IP) {
+			return DomainPolicyError{Domain: domain, Reason: "domain resolves to a private IP address"}
+		}
+	}
+	return nil
+}
+
+// schemeOverride returns the scheme of the most specific SchemeOverrides pattern matching the domain.
+func (p *DomainPolicy) schemeOverride(domain string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	host := strings.ToLower(hostWithoutPort(domain))
+	bestPattern, bestScheme := "", ""
+	for pattern, scheme := range p.SchemeOverrides {
+		if domainMatches(host, pattern) && len(pattern) > len(bestPattern) {
+			bestPattern, bestScheme = pattern, scheme
+		}
+	}
+	return bestScheme, bestPattern != ""
+}
+
+func (p *DomainPolicy) checkPort(domain string) error {
+	if len(p.AllowedPorts) == 0 {
+		return nil
+	}
+	_, portString, err := net.SplitHostPort(domain)
+	if err != nil {
+		return nil
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return DomainPolicyError{Domain: domain, Reason: "invalid port"}
+	}
+	for _, allowedPort := range p.AllowedPorts {
+		if port == allowedPort {
+			return nil
+		}
+	}
+	return DomainPolicyError{Domain: domain, Reason: "port " + portString + " is not allowed"}
+}
+
+func hostWithoutPort(domain string) string {
+	host, _, err := net.SplitHostPort(domain)
+	if err != nil {
+		return strings.Trim(domain, "[]")
+	}
+	return host
+}
+
+func domainMatches(host string, pattern string) bool {
+	if asciiPattern, err := ToAsciiDomain(pattern, true); err == nil {
+		pattern = asciiPattern
+	}
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+// IsPrivateIp returns whether an IP address is loopback, private, link-local, or unspecified, and so should not be
+// contacted on behalf of a counterparty VASP.
+func IsPrivateIp(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsInterfaceLocalMulticast()
+}
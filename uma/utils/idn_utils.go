@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	acePrefix       = "xn--"
+	maxLabelLength  = 63
+	maxDomainLength = 253
+)
+
+// Scripts which may be mixed within a single label, following the "highly restrictive" level of Unicode Technical
+// Standard #39. Japanese, Chinese and Korean domains commonly mix Han with their own scripts and with Latin.
+var allowedScriptCombinations = [][]*unicode.RangeTable{
+	{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana},
+	{unicode.Latin, unicode.Han, unicode.Bopomofo},
+	{unicode.Latin, unicode.Han, unicode.Hangul},
+}
+
+// ToAsciiDomain converts an internationalized domain, which may include a port, to its ASCII form, encoding each
+// non-ASCII label with punycode (RFC 3492), e.g. bücher.example to xn--bcher-kva.example. Labels which are already
+// punycode are decoded and validated too. ASCII-only domains are returned unchanged.
+//
+// Args:
+//
+//	domain: the domain to convert.
+//	allowMixedScripts: whether to accept labels which mix scripts, e.g. Latin and Cyrillic. These are rejected by
+//		default since they are the usual vehicle for homograph attacks, e.g. a Cyrillic "а" in pаypal.com.
+func ToAsciiDomain(domain string, allowMixedScripts bool) (string, error) {
+	if isAscii(domain) && !strings.Contains(strings.ToLower(domain), acePrefix) {
+		return domain, nil
+	}
+	host, port, err := net.SplitHostPort(domain)
+	if err != nil {
+		host, port = domain, ""
+	}
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		unicodeLabel := label
+		if strings.HasPrefix(label, acePrefix) {
+			if unicodeLabel, err = decodePunycode(label[len(acePrefix):]); err != nil {
+				return "", fmt.Errorf("invalid domain %s: %w", domain, err)
+			}
+		}
+		if isAscii(unicodeLabel) {
+			continue
+		}
+		if err = validateUnicodeLabel(unicodeLabel, allowMixedScripts); err != nil {
+			return "", fmt.Errorf("invalid domain %s: %w", domain, err)
+		}
+		if labels[i], err = encodePunycode(unicodeLabel); err != nil {
+			return "", fmt.Errorf("invalid domain %s: %w", domain, err)
+		}
+		labels[i] = acePrefix + labels[i]
+		if len(labels[i]) > maxLabelLength {
+			return "", fmt.Errorf("invalid domain %s: label is too long", domain)
+		}
+	}
+	asciiHost := strings.Join(labels, ".")
+	if len(asciiHost) > maxDomainLength {
+		return "", fmt.Errorf("invalid domain %s: domain is too long", domain)
+	}
+	if port != "" {
+		return net.JoinHostPort(asciiHost, port), nil
+	}
+	return asciiHost, nil
+}
+
+// ToAsciiDomain converts an internationalized domain to its ASCII form with ToAsciiDomain, rejecting mixed-script
+// labels unless the policy sets AllowMixedScriptDomains. A nil policy rejects them.
+func (p *DomainPolicy) ToAsciiDomain(domain string) (string, error) {
+	return ToAsciiDomain(domain, p != nil && p.AllowMixedScriptDomains)
+}
+
+func isAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func validateUnicodeLabel(label string, allowMixedScripts bool) error {
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return errors.New("labels cannot start or end with a hyphen")
+	}
+	var scripts []*unicode.RangeTable
+	for _, r := range label {
+		if r != '-' && !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) {
+			return fmt.Errorf("character %q is not allowed in domains", r)
+		}
+		script := scriptOf(r)
+		if script == nil {
+			continue
+		}
+		if !containsTable(scripts, script) {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) <= 1 || allowMixedScripts {
+		return nil
+	}
+	for _, combination := range allowedScriptCombinations {
+		if containsAllTables(combination, scripts) {
+			return nil
+		}
+	}
+	return errors.New("labels cannot mix scripts")
+}
+
+// scriptOf returns the script of a rune, or nil for characters such as digits which are shared by all scripts.
+func scriptOf(r rune) *unicode.RangeTable {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return nil
+	}
+	for _, script := range unicode.Scripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+	return nil
+}
+
+func containsTable(tables []*unicode.RangeTable, table *unicode.RangeTable) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAllTables(tables []*unicode.RangeTable, subset []*unicode.RangeTable) bool {
+	for _, table := range subset {
+		if !containsTable(tables, table) {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters from RFC 3492 section 5.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+func punycodeAdapt(delta int, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeThreshold(k int, bias int) int {
+	switch {
+	case k <= bias:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	default:
+		return k - bias
+	}
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// encodePunycode encodes a label with punycode, without the ACE prefix.
+func encodePunycode(label string) (string, error) {
+	runes := []rune(label)
+	var output strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			output.WriteRune(r)
+		}
+	}
+	basicCount := output.Len()
+	handled := basicCount
+	if basicCount > 0 {
+		output.WriteByte('-')
+	}
+	n, delta, bias := punycodeInitialN, 0, punycodeInitialBias
+	for handled < len(runes) {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		if (m-n)*(handled+1) > maxDomainLength*punycodeBase*punycodeBase {
+			return "", errors.New("label is too long")
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := punycodeThreshold(k, bias)
+				if q < t {
+					break
+				}
+				output.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			output.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basicCount)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return output.String(), nil
+}
+
+// decodePunycode decodes a punycode label, without the ACE prefix.
+func decodePunycode(encoded string) (string, error) {
+	var output []rune
+	basicEnd := strings.LastIndexByte(encoded, '-')
+	if basicEnd > 0 {
+		for _, r := range encoded[:basicEnd] {
+			if r >= utf8.RuneSelf {
+				return "", errors.New("invalid punycode")
+			}
+			output = append(output, r)
+		}
+	}
+	n, i, bias := punycodeInitialN, 0, punycodeInitialBias
+	for position := basicEnd + 1; position < len(encoded); {
+		oldI, w := i, 1
+		for k := punycodeBase; ; k += punycodeBase {
+			if position >= len(encoded) {
+				return "", errors.New("invalid punycode")
+			}
+			c := encoded[position]
+			position++
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", errors.New("invalid punycode")
+			}
+			i += digit * w
+			t := punycodeThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punycodeBase - t
+			if i > int(unicode.MaxRune)*maxDomainLength || w > int(unicode.MaxRune)*maxDomainLength {
+				return "", errors.New("invalid punycode")
+			}
+		}
+		bias = punycodeAdapt(i-oldI, len(output)+1, oldI == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > unicode.MaxRune || len(output) >= maxLabelLength {
+			return "", errors.New("invalid punycode")
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
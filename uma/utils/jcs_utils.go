@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// CanonicalizeJson Serializes a JSON document with the JSON Canonicalization Scheme (RFC 8785): object members are
+// sorted by the UTF-16 code units of their names, whitespace is removed, and strings and numbers are serialized as
+// by ECMAScript's JSON.stringify. The result can be signed and verified independently of how the document was
+// formatted.
+func CanonicalizeJson(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	var buffer bytes.Buffer
+	if err := writeCanonicalJson(&buffer, value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeCanonicalJson(buffer *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		number, err := canonicalJsonNumber(v)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(number)
+	case string:
+		writeCanonicalJsonString(buffer, v)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeCanonicalJson(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUtf16(keys[i], keys[j])
+		})
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeCanonicalJsonString(buffer, key)
+			buffer.WriteByte(':')
+			if err := writeCanonicalJson(buffer, v[key]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", value)
+	}
+	return nil
+}
+
+// lessUtf16 compares strings by their UTF-16 code units, as required for sorting object members.
+func lessUtf16(a string, b string) bool {
+	aUnits := utf16.Encode([]rune(a))
+	bUnits := utf16.Encode([]rune(b))
+	for i := 0; i < len(aUnits) && i < len(bUnits); i++ {
+		if aUnits[i] != bUnits[i] {
+			return aUnits[i] < bUnits[i]
+		}
+	}
+	return len(aUnits) < len(bUnits)
+}
+
+func writeCanonicalJsonString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// canonicalJsonNumber serializes a number as ECMAScript's Number.prototype.toString does for IEEE 754 doubles.
+func canonicalJsonNumber(number json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid number %s: %w", number, err)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("number %s is out of range", number)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	// The shortest representation which round-trips, as d.ddde±x.
+	scientific := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponentString, _ := strings.Cut(scientific, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exponent, err := strconv.Atoi(exponentString)
+	if err != nil {
+		return "", err
+	}
+	// n is the position of the decimal point relative to the digits, as in the ECMAScript specification.
+	k, n := len(digits), exponent+1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	exponentSign := "+"
+	if n-1 < 0 {
+		exponentSign = "-"
+	}
+	result := digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+	return sign + result + "e" + exponentSign + strconv.Itoa(abs(n-1)), nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/decred/dcrd/bech32"
+)
+
+// lnurlHrp is the bech32 human-readable part of LNURL strings.
+const lnurlHrp = "lnurl"
+
+// EncodeLnurl encodes a URL as a bech32 LNURL string as specified in LUD-01, e.g. LNURL1DP68GURN8GHJ7.... The result
+// is uppercase, which makes for smaller QR codes.
+func EncodeLnurl(rawUrl string) (string, error) {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	if err = checkLnurlScheme(parsedUrl); err != nil {
+		return "", err
+	}
+	data, err := bech32.ConvertBits([]byte(rawUrl), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := bech32.Encode(lnurlHrp, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(encoded), nil
+}
+
+// DecodeLnurl decodes a bech32 LNURL string as specified in LUD-01 into the URL it encodes. The string may be in
+// either case, and may be prefixed with "lightning:" or be a fallback URL with the LNURL in its "lightning" query
+// parameter, e.g. https://vasp2.com/?lightning=LNURL1....
+func DecodeLnurl(lnurl string) (*url.URL, error) {
+	lnurl = strings.TrimSpace(lnurl)
+	if fallbackUrl, err := url.Parse(lnurl); err == nil && fallbackUrl.Query().Has("lightning") {
+		lnurl = fallbackUrl.Query().Get("lightning")
+	}
+	if len(lnurl) >= len("lightning:") && strings.EqualFold(lnurl[:len("lightning:")], "lightning:") {
+		lnurl = lnurl[len("lightning:"):]
+	}
+	hrp, data, err := bech32.DecodeNoLimit(strings.ToLower(lnurl))
+	if err != nil {
+		return nil, err
+	}
+	if hrp != lnurlHrp {
+		return nil, fmt.Errorf("invalid lnurl prefix %q", hrp)
+	}
+	urlBytes, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	parsedUrl, err := url.Parse(string(urlBytes))
+	if err != nil {
+		return nil, err
+	}
+	if err = checkLnurlScheme(parsedUrl); err != nil {
+		return nil, err
+	}
+	return parsedUrl, nil
+}
+
+// checkLnurlScheme checks that an LNURL uses https, or http for onion services and local development domains.
+func checkLnurlScheme(lnurlUrl *url.URL) error {
+	if lnurlUrl.Host == "" {
+		return errors.New("lnurl must be an absolute URL")
+	}
+	switch lnurlUrl.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if IsDomainOnion(lnurlUrl.Host) || IsDomainLocalhost(lnurlUrl.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("lnurl must use https, not %q", lnurlUrl.Scheme)
+}
@@ -1,6 +1,11 @@
 package utils
 
-import "strings"
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
 
 func IsDomainLocalhost(domain string) bool {
 	domainWithoutPort := strings.Split(domain, ":")[0]
@@ -8,3 +13,33 @@ func IsDomainLocalhost(domain string) bool {
 	tld := domainParts[len(domainParts)-1]
 	return domainWithoutPort == "localhost" || domainWithoutPort == "127.0.0.1" || tld == "local" || tld == "internal"
 }
+
+// IsDomainOnion returns true if the domain, which may include a port, is a Tor onion service.
+func IsDomainOnion(domain string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.Split(domain, ":")[0]), ".onion")
+}
+
+// RegistrableDomain returns the registrable domain, or eTLD+1, of a domain which may include a port, according to the
+// public suffix list (https://publicsuffix.org), e.g. vasp.com for api.vasp.com:8443, vasp.co.uk for pay.vasp.co.uk and
+// alice.github.io for api.alice.github.io. Internationalized domains are returned in their ASCII form. IP addresses
+// and domains which are themselves public suffixes are returned as is.
+func RegistrableDomain(domain string) string {
+	host := strings.TrimSuffix(strings.ToLower(hostWithoutPort(domain)), ".")
+	if asciiHost, err := ToAsciiDomain(host, true); err == nil {
+		host = asciiHost
+	}
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	registrableDomain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return registrableDomain
+}
+
+// IsSameRegistrableDomain returns whether two domains, which may include ports, have the same RegistrableDomain, i.e.
+// whether they belong to the same VASP.
+func IsSameRegistrableDomain(domain string, otherDomain string) bool {
+	return RegistrableDomain(domain) == RegistrableDomain(otherDomain)
+}
@@ -0,0 +1,153 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxPostTransactionCallbackBytes is the largest callback body accepted by UtxoCallbackHandler.
+const maxPostTransactionCallbackBytes = 64 * 1024
+
+// TransactionRegistration describes a completed payment reported by the counterparty VASP in a verified
+// post-transaction callback.
+type TransactionRegistration struct {
+	// VaspDomain is the domain of the counterparty VASP, whose signature was verified.
+	VaspDomain string
+	// Utxos are the UTXOs and amounts of the counterparty's channels used for the payment.
+	Utxos []protocol.UtxoWithAmount
+	// CallbackQuery is the query of the callback URL, e.g. the txid of "/api/lnurl/utxocallback?txid=1234", which
+	// identifies the payment when the VASP sets it in the utxoCallback of its pay requests or responses.
+	CallbackQuery url.Values
+	// IdempotencyKey is the idempotency key of the callback, if any.
+	IdempotencyKey *string
+}
+
+// TransactionRegistrar registers the counterparty UTXOs of completed payments with the VASP's KYT provider, completing
+// the compliance checks started with the ComplianceProvider before the payment.
+//
+// Implementations of this interface should be thread-safe.
+type TransactionRegistrar interface {
+	RegisterTransaction(ctx context.Context, registration TransactionRegistration) error
+}
+
+// UtxoCallbackHandler is an http.Handler which serves the utxoCallback endpoint of a VASP. It parses each
+// post-transaction callback, fetches the counterparty's public keys, verifies the signature, nonce and timestamp, and
+// passes the UTXOs on to a TransactionRegistrar. Unsigned UMA v0 callbacks are rejected.
+//
+// To register each callback once when the counterparty retries a delivery, set an IdempotencyStore with
+// WithIdempotencyStore: retries carrying the same IdempotencyKey get the original response. Keys are scoped by the
+// verified domain of the counterparty, and a key reused for a different callback is rejected with a conflict.
+type UtxoCallbackHandler struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	registrar      TransactionRegistrar
+	opts           []Option
+}
+
+// NewUtxoCallbackHandler Creates a UtxoCallbackHandler.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of the counterparty VASP.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	registrar: called with each verified callback. Returning an error responds with an internal server error, so
+//		that the counterparty can retry later.
+//	opts: optional settings such as WithIdempotencyStore, and settings used when fetching public keys and verifying
+//		signatures, such as WithLogger.
+func NewUtxoCallbackHandler(
+	publicKeyCache PublicKeyCache,
+	nonceCache NonceCache,
+	registrar TransactionRegistrar,
+	opts ...Option,
+) *UtxoCallbackHandler {
+	return &UtxoCallbackHandler{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		registrar:      registrar,
+		opts:           opts,
+	}
+}
+
+func (h *UtxoCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, opts := withRequestCorrelationId(w, r, h.opts)
+	if r.Method != http.MethodPost {
+		writeStatusResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPostTransactionCallbackBytes))
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	callback, err := ParsePostTransactionCallback(body, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if callback.VaspDomain == nil || *callback.VaspDomain == "" {
+		writeStatusResponse(w, http.StatusBadRequest, errors.New("missing vaspDomain"))
+		return
+	}
+
+	ctx := r.Context()
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(ctx, *callback.VaspDomain, h.publicKeyCache, opts...)
+	if err != nil {
+		writeStatusResponse(w, http.StatusBadGateway, fmt.Errorf("failed to fetch public keys: %w", err))
+		return
+	}
+	if err = VerifyPostTransactionCallbackSignature(callback, *pubKeyResponse, h.nonceCache, opts...); err != nil {
+		writeStatusResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	registration := TransactionRegistration{
+		VaspDomain:     *callback.VaspDomain,
+		Utxos:          callback.Utxos,
+		CallbackQuery:  r.URL.Query(),
+		IdempotencyKey: callback.IdempotencyKey,
+	}
+	register := func() ([]byte, error) {
+		if err := h.registrar.RegisterTransaction(ctx, registration); err != nil {
+			return nil, err
+		}
+		// The stored response must be non-nil, so that retries are not mistaken for requests in progress.
+		return json.Marshal(map[string]string{"status": "OK"})
+	}
+	o := newOptions(opts)
+	if o.idempotencyStore != nil && callback.IdempotencyKey != nil {
+		var requestHash string
+		requestHash, err = hashRequest(unsignedRegistration{
+			Utxos:         callback.Utxos,
+			CallbackQuery: registration.CallbackQuery,
+		})
+		if err == nil {
+			_, err = processIdempotentlyForSender(
+				ctx, o.idempotencyStore, registration.VaspDomain, callback.IdempotencyKey, requestHash, register)
+		}
+	} else {
+		_, err = register()
+	}
+	if errors.Is(err, ErrIdempotentRequestInProgress) || errors.Is(err, ErrIdempotencyKeyReused) {
+		writeStatusResponse(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeStatusResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeStatusResponse(w, http.StatusOK, nil)
+}
+
+// unsignedRegistration is the content of a post-transaction callback which is hashed to detect an idempotency key
+// reused for a different callback. It excludes the signature, nonce and timestamp, which change on each delivery.
+type unsignedRegistration struct {
+	Utxos         []protocol.UtxoWithAmount `json:"utxos"`
+	CallbackQuery url.Values                `json:"callbackQuery"`
+}
@@ -0,0 +1,57 @@
+package uma
+
+import (
+	"context"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// UtxoProvider fetches channel UTXOs from the VASP's Lightning node, so that compliance data and post-transaction
+// callbacks can be populated without each integrator querying their node themselves. See the lightning package for
+// implementations backed by common node backends.
+//
+// Implementations of this interface should be thread-safe.
+type UtxoProvider interface {
+	// GetChannelUtxos returns the funding outpoints, in the format <transaction_hash>:<output_index>, of the node's
+	// channels which are likely to be used for payments.
+	GetChannelUtxos(ctx context.Context) ([]string, error)
+	// GetPaymentUtxos returns the channel UTXOs and amounts used by a completed payment, identified by its hex-encoded
+	// payment hash.
+	GetPaymentUtxos(ctx context.Context, paymentHash string, direction PaymentDirection) ([]protocol.UtxoWithAmount, error)
+}
+
+// WithUtxoProvider sets the UtxoProvider used to fill in channel UTXOs when they are not passed explicitly.
+func WithUtxoProvider(provider UtxoProvider) Option {
+	return func(o *options) {
+		o.utxoProvider = provider
+	}
+}
+
+// GetPostTransactionCallbackForPayment Creates a signed post transaction callback, fetching the UTXOs used by the
+// payment from a UtxoProvider.
+//
+// Args:
+//
+//	ctx: the context for the UtxoProvider request.
+//	provider: the UtxoProvider for the VASP's node.
+//	paymentHash: the hex-encoded payment hash of the completed payment.
+//	direction: whether the payment was sent or received by this VASP.
+//	vaspDomain: the domain of the VASP initiating the callback.
+//	signer: the UmaSigner of the VASP initiating the callback.
+func GetPostTransactionCallbackForPayment(
+	ctx context.Context,
+	provider UtxoProvider,
+	paymentHash string,
+	direction PaymentDirection,
+	vaspDomain string,
+	signer UmaSigner,
+) (*protocol.PostTransactionCallback, error) {
+	utxos, err := provider.GetPaymentUtxos(ctx, paymentHash, direction)
+	if err != nil {
+		return nil, err
+	}
+	return SignPostTransactionCallback(protocol.PostTransactionCallback{
+		Utxos:      utxos,
+		VaspDomain: &vaspDomain,
+	}, signer)
+}
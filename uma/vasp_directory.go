@@ -0,0 +1,246 @@
+package uma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrVaspNotFound is returned by a VaspDirectory for domains it has no record of.
+var ErrVaspNotFound = errors.New("VASP not found in directory")
+
+// RegulatoryStatus is the regulatory status of a VASP in a VaspDirectory.
+type RegulatoryStatus string
+
+const (
+	// RegulatoryStatusLicensed means the VASP holds a license in its jurisdictions.
+	RegulatoryStatusLicensed RegulatoryStatus = "LICENSED"
+	// RegulatoryStatusRegistered means the VASP is registered with a regulator, without a license.
+	RegulatoryStatusRegistered RegulatoryStatus = "REGISTERED"
+	// RegulatoryStatusUnregulated means the VASP is known, but not regulated.
+	RegulatoryStatusUnregulated RegulatoryStatus = "UNREGULATED"
+	// RegulatoryStatusSuspended means the license or registration of the VASP is suspended or revoked.
+	RegulatoryStatusSuspended RegulatoryStatus = "SUSPENDED"
+	// RegulatoryStatusUnknown means the directory does not know the regulatory status of the VASP.
+	RegulatoryStatusUnknown RegulatoryStatus = "UNKNOWN"
+)
+
+// VaspRecord is the entry of a VASP in a VaspDirectory.
+type VaspRecord struct {
+	// Domain is the UMA domain of the VASP, e.g. vasp2.com.
+	Domain string `json:"domain"`
+	// Name is the legal name of the VASP, if known.
+	Name string `json:"name,omitempty"`
+	// RegulatoryStatus is the regulatory status of the VASP.
+	RegulatoryStatus RegulatoryStatus `json:"regulatoryStatus"`
+	// Jurisdictions are the jurisdictions the VASP operates in, e.g. ISO 3166 country codes.
+	Jurisdictions []string `json:"jurisdictions,omitempty"`
+}
+
+// VaspDirectory looks up counterparty VASPs in a registry, so that sending VASPs can take their regulatory status and
+// jurisdictions into account in compliance decisions. Implementations of this interface should be thread-safe.
+type VaspDirectory interface {
+	// LookupVasp returns the record of the VASP with the given domain, or ErrVaspNotFound.
+	LookupVasp(ctx context.Context, domain string) (*VaspRecord, error)
+}
+
+// VaspDirectoryFunc adapts a function to a VaspDirectory.
+type VaspDirectoryFunc func(ctx context.Context, domain string) (*VaspRecord, error)
+
+func (f VaspDirectoryFunc) LookupVasp(ctx context.Context, domain string) (*VaspRecord, error) {
+	return f(ctx, domain)
+}
+
+// StaticVaspDirectory is a VaspDirectory backed by a fixed list of records, for example loaded from a file. Domains
+// are matched case-insensitively.
+type StaticVaspDirectory map[string]VaspRecord
+
+// NewStaticVaspDirectory Creates a StaticVaspDirectory holding the given records.
+func NewStaticVaspDirectory(records ...VaspRecord) StaticVaspDirectory {
+	directory := make(StaticVaspDirectory, len(records))
+	for _, record := range records {
+		directory[strings.ToLower(record.Domain)] = record
+	}
+	return directory
+}
+
+func (d StaticVaspDirectory) LookupVasp(_ context.Context, domain string) (*VaspRecord, error) {
+	record, ok := d[strings.ToLower(domain)]
+	if !ok {
+		return nil, ErrVaspNotFound
+	}
+	return &record, nil
+}
+
+// HttpVaspDirectory is a VaspDirectory which queries a directory service over HTTP. Records are fetched with a GET
+// request to <baseUrl>/vasps/<domain>, which responds with a JSON VaspRecord, or 404 Not Found for unknown domains.
+type HttpVaspDirectory struct {
+	baseUrl string
+	opts    []Option
+}
+
+// NewHttpVaspDirectory Creates an HttpVaspDirectory.
+//
+// Args:
+//
+//	baseUrl: the base URL of the directory service, e.g. https://directory.example.com/api.
+//	opts: optional settings for the requests to the directory service, such as WithRequestDoer and WithRetryPolicy.
+func NewHttpVaspDirectory(baseUrl string, opts ...Option) *HttpVaspDirectory {
+	return &HttpVaspDirectory{baseUrl: strings.TrimSuffix(baseUrl, "/"), opts: opts}
+}
+
+func (d *HttpVaspDirectory) LookupVasp(ctx context.Context, domain string) (*VaspRecord, error) {
+	o := newOptions(d.opts)
+	responseBodyBytes, err := o.sendRequest(ctx, outboundRequest{
+		method:      http.MethodGet,
+		url:         d.baseUrl + "/vasps/" + url.PathEscape(strings.ToLower(domain)),
+		idempotent:  true,
+		expectsJson: true,
+	})
+	var invalidResponseError InvalidResponseError
+	if errors.As(err, &invalidResponseError) && invalidResponseError.StatusCode == http.StatusNotFound {
+		return nil, ErrVaspNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record VaspRecord
+	if err = json.Unmarshal(responseBodyBytes, &record); err != nil {
+		return nil, fmt.Errorf("invalid VASP record: %w", err)
+	}
+	if record.RegulatoryStatus == "" {
+		record.RegulatoryStatus = RegulatoryStatusUnknown
+	}
+	return &record, nil
+}
+
+// VaspDirectoryClient queries a VaspDirectory backend and caches its answers, including ErrVaspNotFound, for a fixed
+// time, so that directory lookups do not slow down every payment.
+//
+// A VaspDirectoryClient is safe for concurrent use.
+type VaspDirectoryClient struct {
+	backend VaspDirectory
+	ttl     time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]vaspDirectoryEntry
+	clock   Clock
+}
+
+type vaspDirectoryEntry struct {
+	record    *VaspRecord
+	expiresAt time.Time
+}
+
+// NewVaspDirectoryClient Creates a VaspDirectoryClient.
+//
+// Args:
+//
+//	backend: the directory to query, e.g. an HttpVaspDirectory.
+//	ttl: how long answers are cached. If zero, answers are not cached.
+func NewVaspDirectoryClient(backend VaspDirectory, ttl time.Duration) *VaspDirectoryClient {
+	return &VaspDirectoryClient{
+		backend: backend,
+		ttl:     ttl,
+		entries: make(map[string]vaspDirectoryEntry),
+		clock:   SystemClock,
+	}
+}
+
+// SetClock sets the Clock used to expire cached answers. Defaults to SystemClock.
+func (c *VaspDirectoryClient) SetClock(clock Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clockOrSystem(clock)
+}
+
+// LookupVasp returns the record of the VASP with the given domain, or ErrVaspNotFound.
+func (c *VaspDirectoryClient) LookupVasp(ctx context.Context, domain string) (*VaspRecord, error) {
+	domain = strings.ToLower(domain)
+	c.mutex.Lock()
+	entry, ok := c.entries[domain]
+	now := c.clock.Now()
+	c.mutex.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		if entry.record == nil {
+			return nil, ErrVaspNotFound
+		}
+		return entry.record, nil
+	}
+
+	record, err := c.backend.LookupVasp(ctx, domain)
+	if err != nil && !errors.Is(err, ErrVaspNotFound) {
+		return nil, err
+	}
+	if c.ttl > 0 {
+		c.mutex.Lock()
+		c.entries[domain] = vaspDirectoryEntry{record: record, expiresAt: now.Add(c.ttl)}
+		c.mutex.Unlock()
+	}
+	return record, err
+}
+
+// RegulatoryStatus returns the regulatory status of the VASP with the given domain, or RegulatoryStatusUnknown if it
+// is not in the directory.
+func (c *VaspDirectoryClient) RegulatoryStatus(ctx context.Context, domain string) (RegulatoryStatus, error) {
+	record, err := c.LookupVasp(ctx, domain)
+	if errors.Is(err, ErrVaspNotFound) {
+		return RegulatoryStatusUnknown, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return record.RegulatoryStatus, nil
+}
+
+// Jurisdictions returns the jurisdictions of the VASP with the given domain, or nil if it is not in the directory.
+func (c *VaspDirectoryClient) Jurisdictions(ctx context.Context, domain string) ([]string, error) {
+	record, err := c.LookupVasp(ctx, domain)
+	if errors.Is(err, ErrVaspNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.Jurisdictions, nil
+}
+
+// WithVaspDirectory sets the VaspDirectory which SendLnurlpRequest queries for the receiving VASP before contacting
+// it. The record is available to the ComplianceProvider's PreScreenPayee via VaspRecordFromContext. A failed lookup
+// aborts the request, while VASPs missing from the directory are left to the ComplianceProvider.
+func WithVaspDirectory(directory VaspDirectory) Option {
+	return func(o *options) {
+		o.vaspDirectory = directory
+	}
+}
+
+type vaspRecordKey struct{}
+
+// VaspRecordFromContext returns the record of the receiving VASP looked up in the VaspDirectory set with
+// WithVaspDirectory, if it is in the directory.
+func VaspRecordFromContext(ctx context.Context) (*VaspRecord, bool) {
+	record, ok := ctx.Value(vaspRecordKey{}).(*VaspRecord)
+	return record, ok
+}
+
+// withVaspRecord looks up the VASP with the given domain in the VaspDirectory, if any, and adds its record to the
+// context.
+func (o *options) withVaspRecord(ctx context.Context, domain string) (context.Context, error) {
+	if o.vaspDirectory == nil {
+		return ctx, nil
+	}
+	record, err := o.vaspDirectory.LookupVasp(ctx, domain)
+	if errors.Is(err, ErrVaspNotFound) {
+		return ctx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s in the VASP directory: %w", domain, err)
+	}
+	return context.WithValue(ctx, vaspRecordKey{}, record), nil
+}
@@ -0,0 +1,173 @@
+package uma
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// maxVerifierCacheEntries is the number of VASP domains and of parsed keys a Verifier keeps at most. When a cache is
+// full, an arbitrary entry is evicted, so that a sender rotating through many domains or keys cannot grow it without
+// bound.
+const maxVerifierCacheEntries = 4096
+
+// Verifier verifies signed requests from other VASPs under high load. It fetches each sender's public keys through a
+// PublicKeyCache like the Verify functions, but also keeps the decoded signing key of each VASP and the parsed
+// secp256k1 keys, so that hex keys and certificate chains are not decoded again for every request. Decoded keys are
+// matched on the signing key or certificate chain of the PubKeyResponse returned by the PublicKeyCache, so they are
+// reused with caches which return a new PubKeyResponse for every lookup, and decoded again once a VASP rotates its
+// key.
+//
+// A Verifier is safe for concurrent use. Unless WithSignatureVerifier is set, verification uses
+// github.com/decred/dcrd/dcrec/secp256k1 with the parsed keys.
+type Verifier struct {
+	publicKeyCache PublicKeyCache
+	nonceCache     NonceCache
+	opts           []Option
+
+	domainKeys *boundedCache[string, *verifierDomainKey]
+	// parsedKeys maps SEC1-encoded signing keys to their parsed form.
+	parsedKeys *boundedCache[string, *secp256k1.PublicKey]
+}
+
+// verifierDomainKey is the signing key of a VASP, decoded from the signing key or certificate chain of a
+// PubKeyResponse.
+type verifierDomainKey struct {
+	signingCertChain string
+	signingPubKeyHex string
+	// signingKeyHex is the hex-encoded signing key, which is cheap to decode.
+	signingKeyHex string
+}
+
+// matches returns whether the domain key was decoded from the signing key or certificate chain of the
+// PubKeyResponse.
+func (k *verifierDomainKey) matches(pubKeyResponse *protocol.PubKeyResponse) bool {
+	return k.signingCertChain == stringOrEmpty(pubKeyResponse.SigningCertChain) &&
+		k.signingPubKeyHex == stringOrEmpty(pubKeyResponse.SigningPubKeyHex)
+}
+
+// stringOrEmpty returns the string s points to, or "" if s is nil.
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// NewVerifier Creates a Verifier.
+//
+// Args:
+//
+//	publicKeyCache: the cache used when fetching the public keys of sending VASPs.
+//	nonceCache: the NonceCache cache to use to prevent replay attacks.
+//	opts: optional settings used when fetching public keys and verifying signatures, such as WithLogger.
+func NewVerifier(publicKeyCache PublicKeyCache, nonceCache NonceCache, opts ...Option) *Verifier {
+	v := &Verifier{
+		publicKeyCache: publicKeyCache,
+		nonceCache:     nonceCache,
+		domainKeys:     newBoundedCache[string, *verifierDomainKey](maxVerifierCacheEntries),
+		parsedKeys:     newBoundedCache[string, *secp256k1.PublicKey](maxVerifierCacheEntries),
+	}
+	v.opts = append([]Option{}, opts...)
+	// Parsed keys replace the default verifier only, which parses keys the same way.
+	if newOptions(opts).signatureVerifier == (DecredSignatureVerifier{}) {
+		v.opts = append(v.opts, WithSignatureVerifier(parsedKeySignatureVerifier{v}))
+	}
+	return v
+}
+
+// VerifyLnurlpRequest Verifies the signature on an UMA lnurlp request, fetching the public keys of the sending VASP
+// if needed.
+func (v *Verifier) VerifyLnurlpRequest(ctx context.Context, request *protocol.LnurlpRequest) error {
+	return v.Verify(ctx, LnurlpRequestMessage(request))
+}
+
+// VerifyPayRequest Verifies the signature on an UMA pay request, fetching the public keys of the VASP of the payer
+// identifier if needed.
+func (v *Verifier) VerifyPayRequest(ctx context.Context, request *protocol.PayRequest) error {
+	return v.Verify(ctx, PayRequestMessage(request))
+}
+
+func (v *Verifier) signingKeyForVasp(ctx context.Context, vaspDomain string) (*protocol.PubKeyResponse, error) {
+	pubKeyResponse, err := FetchPublicKeyForVaspWithContext(ctx, vaspDomain, v.publicKeyCache, v.opts...)
+	if err != nil {
+		return nil, err
+	}
+	domainKey, ok := v.domainKeys.get(vaspDomain)
+	if !ok || !domainKey.matches(pubKeyResponse) {
+		signingKey, err := pubKeyResponse.SigningPubKey()
+		if err != nil {
+			return nil, err
+		}
+		domainKey = &verifierDomainKey{
+			signingCertChain: stringOrEmpty(pubKeyResponse.SigningCertChain),
+			signingPubKeyHex: stringOrEmpty(pubKeyResponse.SigningPubKeyHex),
+			signingKeyHex:    hex.EncodeToString(signingKey),
+		}
+		v.domainKeys.put(vaspDomain, domainKey)
+	}
+	return &protocol.PubKeyResponse{
+		SigningPubKeyHex: &domainKey.signingKeyHex,
+		SigningKeyId:     pubKeyResponse.SigningKeyId,
+		SigningKeys:      pubKeyResponse.SigningKeys,
+		SigningAlgorithm: pubKeyResponse.SigningAlgorithm,
+	}, nil
+}
+
+// parsedKeySignatureVerifier is a SignatureVerifier which reuses the parsed keys of a Verifier.
+type parsedKeySignatureVerifier struct {
+	verifier *Verifier
+}
+
+func (p parsedKeySignatureVerifier) Verify(hash [sha256.Size]byte, derSignature []byte, publicKey []byte) (bool, error) {
+	parsedSignature, err := ecdsa.ParseDERSignature(derSignature)
+	if err != nil {
+		return false, err
+	}
+	parsedKey, ok := p.verifier.parsedKeys.get(string(publicKey))
+	if !ok {
+		parsedKey, err = secp256k1.ParsePubKey(publicKey)
+		if err != nil {
+			return false, err
+		}
+		p.verifier.parsedKeys.put(string(publicKey), parsedKey)
+	}
+	return parsedSignature.Verify(hash[:], parsedKey), nil
+}
+
+// boundedCache is a map safe for concurrent use which holds at most maxEntries entries, evicting an arbitrary entry
+// when it is full.
+type boundedCache[K comparable, V any] struct {
+	mutex      sync.RWMutex
+	entries    map[K]V
+	maxEntries int
+}
+
+func newBoundedCache[K comparable, V any](maxEntries int) *boundedCache[K, V] {
+	return &boundedCache[K, V]{entries: make(map[K]V), maxEntries: maxEntries}
+}
+
+func (c *boundedCache[K, V]) get(key K) (V, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	value, ok := c.entries[key]
+	return value, ok
+}
+
+func (c *boundedCache[K, V]) put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		// Map iteration order is random, so this evicts an arbitrary entry.
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+	c.entries[key] = value
+}
@@ -0,0 +1,210 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// SignedMessage is a signed message from another VASP which a Verifier can verify, for example in a batch with
+// VerifyBatch. Create one with LnurlpRequestMessage, PayRequestMessage, PostTransactionCallbackMessage or
+// PaymentStatusWebhookMessage.
+type SignedMessage interface {
+	// signingVaspDomain returns the domain of the VASP which signed the message.
+	signingVaspDomain() (string, error)
+	// verifyWithKeys verifies the signature of the message with the public keys of the VASP which signed it.
+	verifyWithKeys(pubKeyResponse protocol.PubKeyResponse, nonceCache NonceCache, opts []Option) error
+}
+
+type lnurlpRequestMessage struct {
+	request *protocol.LnurlpRequest
+}
+
+// LnurlpRequestMessage returns a SignedMessage for an UMA lnurlp request.
+func LnurlpRequestMessage(request *protocol.LnurlpRequest) SignedMessage {
+	return lnurlpRequestMessage{request: request}
+}
+
+func (m lnurlpRequestMessage) signingVaspDomain() (string, error) {
+	umaRequest := m.request.AsUmaRequest()
+	if umaRequest == nil {
+		return "", errors.New("not an UMA lnurlp request")
+	}
+	return umaRequest.VaspDomain, nil
+}
+
+func (m lnurlpRequestMessage) verifyWithKeys(
+	pubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts []Option,
+) error {
+	return VerifyUmaLnurlpQuerySignature(*m.request.AsUmaRequest(), pubKeyResponse, nonceCache, opts...)
+}
+
+type payRequestMessage struct {
+	request *protocol.PayRequest
+}
+
+// PayRequestMessage returns a SignedMessage for an UMA pay request. It is signed by the VASP of the payer identifier.
+func PayRequestMessage(request *protocol.PayRequest) SignedMessage {
+	return payRequestMessage{request: request}
+}
+
+func (m payRequestMessage) signingVaspDomain() (string, error) {
+	if m.request.PayerData == nil || m.request.PayerData.Identifier() == nil {
+		return "", errors.New("missing payer identifier")
+	}
+	return domainOfIdentifier(*m.request.PayerData.Identifier()), nil
+}
+
+func (m payRequestMessage) verifyWithKeys(
+	pubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts []Option,
+) error {
+	// The keys were fetched from the domain of the payer identifier.
+	vaspDomain, err := m.signingVaspDomain()
+	if err != nil {
+		return err
+	}
+	return VerifyPayReqSignature(m.request, pubKeyResponse, nonceCache,
+		append(opts[:len(opts):len(opts)], WithSenderVaspDomain(vaspDomain))...)
+}
+
+type postTransactionCallbackMessage struct {
+	callback *protocol.PostTransactionCallback
+}
+
+// PostTransactionCallbackMessage returns a SignedMessage for a post-transaction callback.
+func PostTransactionCallbackMessage(callback *protocol.PostTransactionCallback) SignedMessage {
+	return postTransactionCallbackMessage{callback: callback}
+}
+
+func (m postTransactionCallbackMessage) signingVaspDomain() (string, error) {
+	if m.callback.VaspDomain == nil {
+		return "", errors.New("missing vaspDomain")
+	}
+	return *m.callback.VaspDomain, nil
+}
+
+func (m postTransactionCallbackMessage) verifyWithKeys(
+	pubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts []Option,
+) error {
+	return VerifyPostTransactionCallbackSignature(m.callback, pubKeyResponse, nonceCache, opts...)
+}
+
+type paymentStatusWebhookMessage struct {
+	webhook *protocol.PaymentStatusWebhook
+}
+
+// PaymentStatusWebhookMessage returns a SignedMessage for a payment status webhook.
+func PaymentStatusWebhookMessage(webhook *protocol.PaymentStatusWebhook) SignedMessage {
+	return paymentStatusWebhookMessage{webhook: webhook}
+}
+
+func (m paymentStatusWebhookMessage) signingVaspDomain() (string, error) {
+	if m.webhook.VaspDomain == "" {
+		return "", errors.New("missing vaspDomain")
+	}
+	return m.webhook.VaspDomain, nil
+}
+
+func (m paymentStatusWebhookMessage) verifyWithKeys(
+	pubKeyResponse protocol.PubKeyResponse,
+	nonceCache NonceCache,
+	opts []Option,
+) error {
+	return VerifyPaymentStatusWebhookSignature(m.webhook, pubKeyResponse, nonceCache, opts...)
+}
+
+// WithVerifyBatchWorkers sets the number of messages Verifier.VerifyBatch verifies concurrently. Defaults to
+// GOMAXPROCS.
+func WithVerifyBatchWorkers(workers int) Option {
+	return func(o *options) {
+		o.verifyBatchWorkers = workers
+	}
+}
+
+// Verify Verifies the signature on a SignedMessage, fetching the public keys of the VASP which signed it if needed.
+func (v *Verifier) Verify(ctx context.Context, message SignedMessage) error {
+	vaspDomain, err := message.signingVaspDomain()
+	if err != nil {
+		return err
+	}
+	pubKeyResponse, err := v.signingKeyForVasp(ctx, vaspDomain)
+	if err != nil {
+		return err
+	}
+	return message.verifyWithKeys(*pubKeyResponse, v.nonceCache, v.optsForVasp(vaspDomain))
+}
+
+// optsForVasp returns the options of the Verifier for verifying messages with the keys of a VASP, so that the payer
+// identifier of pay requests is checked against the domain the keys were fetched from.
+func (v *Verifier) optsForVasp(vaspDomain string) []Option {
+	return append(v.opts[:len(v.opts):len(v.opts)], WithSenderVaspDomain(vaspDomain))
+}
+
+// VerifyBatch Verifies the signatures on many messages concurrently, for example a burst of post-transaction
+// callbacks. The public keys of each VASP are fetched at most once per batch, and its parsed signing key is shared
+// with every other verification by the Verifier.
+//
+// Args:
+//
+//	ctx: the context used when fetching public keys.
+//	messages: the messages to verify.
+//
+// Returns the error of each message, in the same order as messages. The error is nil if the message was verified.
+func (v *Verifier) VerifyBatch(ctx context.Context, messages []SignedMessage) []error {
+	errs := make([]error, len(messages))
+	// Fetch the keys of each VASP once, rather than in every worker verifying one of its messages.
+	type batchKey struct {
+		once           sync.Once
+		pubKeyResponse *protocol.PubKeyResponse
+		err            error
+	}
+	keys := make(map[string]*batchKey)
+	vaspDomains := make([]string, len(messages))
+	for i, message := range messages {
+		vaspDomains[i], errs[i] = message.signingVaspDomain()
+		if errs[i] == nil && keys[vaspDomains[i]] == nil {
+			keys[vaspDomains[i]] = &batchKey{}
+		}
+	}
+
+	workers := newOptions(v.opts).verifyBatchWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(messages); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				key := keys[vaspDomains[i]]
+				key.once.Do(func() {
+					key.pubKeyResponse, key.err = v.signingKeyForVasp(ctx, vaspDomains[i])
+				})
+				if key.err != nil {
+					errs[i] = key.err
+					continue
+				}
+				errs[i] = messages[i].verifyWithKeys(*key.pubKeyResponse, v.nonceCache, v.optsForVasp(vaspDomains[i]))
+			}
+		}()
+	}
+	for i := range messages {
+		if errs[i] == nil {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,92 @@
+package uma
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/uma-universal-money-address/uma-go-sdk/uma/protocol"
+)
+
+// VersionNegotiationResult describes how the UMA version of an lnurlp exchange was negotiated with the receiving
+// VASP. VASPs can use it to alert when a counterparty forces an older protocol version.
+type VersionNegotiationResult struct {
+	// RequestedVersion is the version the lnurlp request was first sent at.
+	RequestedVersion string
+	// ChosenVersion is the version of the lnurlp response, which the rest of the exchange uses.
+	ChosenVersion string
+	// CounterpartySupportedMajorVersions are the major versions the receiving VASP reported supporting when it
+	// rejected the requested version, or nil if it accepted it.
+	CounterpartySupportedMajorVersions []int
+	// Downgraded is true if ChosenVersion is lower than RequestedVersion.
+	Downgraded bool
+}
+
+// SendLnurlpRequestWithNegotiation Signs and sends an UMA lnurlp request to the receiving VASP, and negotiates the
+// UMA version: if the receiving VASP does not support the requested version, the request is re-signed at the highest
+// major version both VASPs support and sent again. The result reports the chosen version and whether a downgrade
+// happened, alongside the response.
+//
+// Args:
+//
+//	ctx: the context for the outbound requests.
+//	request: the unsigned lnurlp request to send. Its UmaVersion defaults to UmaProtocolVersion.
+//	signer: the signer used to sign the request, and re-sign it at a lower version.
+//	opts: optional settings such as WithRequestDoer, WithLogger and WithClock.
+//
+// If the VASPs share no major version, the UnsupportedVersionError of the receiving VASP is returned along with the
+// result, which holds the major versions the receiving VASP supports.
+func SendLnurlpRequestWithNegotiation(
+	ctx context.Context,
+	request protocol.LnurlpRequest,
+	signer UmaSigner,
+	opts ...Option,
+) (*protocol.LnurlpResponse, *VersionNegotiationResult, error) {
+	if request.UmaVersion == nil {
+		umaVersion := UmaProtocolVersion
+		request.UmaVersion = &umaVersion
+	}
+	result := &VersionNegotiationResult{RequestedVersion: *request.UmaVersion}
+	signedRequest, err := SignLnurlpRequest(request, signer, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := SendLnurlpRequest(ctx, *signedRequest, opts...)
+	var unsupportedVersionError UnsupportedVersionError
+	if errors.As(err, &unsupportedVersionError) {
+		result.CounterpartySupportedMajorVersions = unsupportedVersionError.SupportedMajorVersions
+		negotiatedVersion := SelectHighestSupportedVersion(unsupportedVersionError.SupportedMajorVersions)
+		if negotiatedVersion == nil {
+			return nil, result, err
+		}
+		request.UmaVersion = negotiatedVersion
+		if signedRequest, err = SignLnurlpRequest(request, signer, opts...); err != nil {
+			return nil, nil, err
+		}
+		response, err = SendLnurlpRequest(ctx, *signedRequest, opts...)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.ChosenVersion = *request.UmaVersion
+	if response.UmaVersion != nil {
+		result.ChosenVersion = *response.UmaVersion
+	}
+	lowerVersion, err := SelectLowerVersion(result.ChosenVersion, result.RequestedVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Downgraded = *lowerVersion != result.RequestedVersion
+	o := newOptions(opts)
+	level := slog.LevelInfo
+	if result.Downgraded {
+		level = slog.LevelWarn
+	}
+	o.log(ctx, level, LogEventVersionNegotiated,
+		slog.String("counterparty", domainOfIdentifier(request.ReceiverAddress)),
+		slog.String("requested_version", result.RequestedVersion),
+		slog.String("chosen_version", result.ChosenVersion),
+		slog.Bool("downgraded", result.Downgraded))
+	return response, result, nil
+}